@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"ids/internal/cache"
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/embeddings"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// cliOptions holds the parsed command-line flags
+type cliOptions struct {
+	QueriesPath string // File with one query per line; blank lines and "#" comments are skipped
+	Limit       int    // Results requested per query
+	Iterations  int    // Times each query is run
+	Cache       bool   // Cache query embeddings, so repeated iterations measure only the vector search
+	Mock        bool   // Use the in-process mock searcher instead of OpenAI and PostgreSQL (CI)
+}
+
+// parseFlags parses command-line arguments into cliOptions
+func parseFlags(args []string) (cliOptions, error) {
+	var opts cliOptions
+	fs := flag.NewFlagSet("bench-search", flag.ContinueOnError)
+	fs.StringVar(&opts.QueriesPath, "queries", "", "Path to a file with one search query per line")
+	fs.IntVar(&opts.Limit, "limit", 10, "Number of results requested per query")
+	fs.IntVar(&opts.Iterations, "iterations", 1, "Number of times each query is run")
+	fs.BoolVar(&opts.Cache, "cache", false, "Cache query embeddings between iterations (measures search without embedding latency)")
+	fs.BoolVar(&opts.Mock, "mock", false, "Run against the mock searcher (no OpenAI or database needed)")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if opts.QueriesPath == "" {
+		return opts, errors.New("-queries is required")
+	}
+	if opts.Limit <= 0 || opts.Iterations <= 0 {
+		return opts, errors.New("-limit and -iterations must be positive")
+	}
+	return opts, nil
+}
+
+// run executes the command and returns the process exit code
+func run(args []string, stdout io.Writer) int {
+	opts, err := parseFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench-search: %v\n", err)
+		return 2
+	}
+
+	queries, err := loadQueries(opts.QueriesPath)
+	if err != nil {
+		log.Printf("ERROR: Failed to load queries: %v", err)
+		return 1
+	}
+	if len(queries) == 0 {
+		log.Printf("ERROR: No queries found in %s", opts.QueriesPath)
+		return 1
+	}
+
+	// Search logging goes to stderr so the report on stdout stays readable
+	os.Stdout = os.Stderr
+
+	search := mockSearch
+	if !opts.Mock {
+		var cleanup func()
+		search, cleanup, err = newProductSearch(config.Load(), opts.Cache)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			return 1
+		}
+		defer cleanup()
+	}
+
+	report := benchmark(context.Background(), search, queries, opts.Limit, opts.Iterations)
+	writeReport(stdout, report)
+	if report.Errors == report.Runs {
+		return 1
+	}
+	return 0
+}
+
+// searchFunc runs one product search and returns the number of results
+type searchFunc func(ctx context.Context, query string, limit int) (int, error)
+
+// newProductSearch wires SearchSimilarProducts the same way the server does
+func newProductSearch(cfg *config.Config, cacheEmbeddings bool) (searchFunc, func(), error) {
+	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create write database client: %v", err)
+	}
+	cleanup := func() {
+		if err := writeClient.Close(); err != nil {
+			log.Printf("Error closing write client: %v", err)
+		}
+	}
+
+	var embeddingCache *cache.Cache
+	if cacheEmbeddings {
+		embeddingCache = cache.New()
+	}
+	service, err := embeddings.NewEmbeddingService(cfg, nil, writeClient, embeddingCache)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create embedding service: %v", err)
+	}
+
+	return func(ctx context.Context, query string, limit int) (int, error) {
+		results, _, err := service.SearchSimilarProducts(ctx, query, limit)
+		return len(results), err
+	}, cleanup, nil
+}
+
+// mockSearch stands in for SearchSimilarProducts in CI: it needs no OpenAI key or database and
+// returns one result per query word up to limit, so the harness and report can be checked end to end
+func mockSearch(_ context.Context, query string, limit int) (int, error) {
+	return min(len(strings.Fields(query)), limit), nil
+}
+
+// loadQueries reads one query per line, skipping blank lines and "#" comments
+func loadQueries(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Error closing queries file: %v", err)
+		}
+	}()
+
+	var queries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	return queries, scanner.Err()
+}
+
+// benchReport summarizes the latencies and result counts of a benchmark run
+type benchReport struct {
+	Queries    int
+	Runs       int
+	Errors     int
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	AvgResults float64
+}
+
+// benchmark runs every query iterations times, in order, and summarizes the successful runs
+func benchmark(ctx context.Context, search searchFunc, queries []string, limit, iterations int) benchReport {
+	report := benchReport{Queries: len(queries)}
+	var latencies []time.Duration
+	totalResults := 0
+
+	for i := 0; i < iterations; i++ {
+		for _, query := range queries {
+			report.Runs++
+			start := time.Now()
+			count, err := search(ctx, query, limit)
+			elapsed := time.Since(start)
+			if err != nil {
+				report.Errors++
+				log.Printf("Warning: Search failed for %q: %v", query, err)
+				continue
+			}
+			latencies = append(latencies, elapsed)
+			totalResults += count
+		}
+	}
+
+	if len(latencies) == 0 {
+		return report
+	}
+	sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+	report.P50 = percentile(latencies, 50)
+	report.P95 = percentile(latencies, 95)
+	report.P99 = percentile(latencies, 99)
+	report.Max = latencies[len(latencies)-1]
+	report.AvgResults = float64(totalResults) / float64(len(latencies))
+	return report
+}
+
+// percentile returns the nearest-rank pth percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// writeReport prints the benchmark summary
+func writeReport(w io.Writer, report benchReport) {
+	_, _ = fmt.Fprintf(w, "=== SEARCH BENCHMARK ===\n")
+	_, _ = fmt.Fprintf(w, "Queries: %d, runs: %d, errors: %d\n", report.Queries, report.Runs, report.Errors)
+	_, _ = fmt.Fprintf(w, "Latency p50: %v, p95: %v, p99: %v, max: %v\n",
+		report.P50.Round(time.Microsecond), report.P95.Round(time.Microsecond),
+		report.P99.Round(time.Microsecond), report.Max.Round(time.Microsecond))
+	_, _ = fmt.Fprintf(w, "Average results per query: %.2f\n", report.AvgResults)
+}
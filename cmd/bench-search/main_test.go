@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	assert.Equal(t, 95*time.Millisecond, percentile(latencies, 95))
+	assert.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+
+	assert.Equal(t, 7*time.Millisecond, percentile([]time.Duration{7 * time.Millisecond}, 99))
+	assert.Zero(t, percentile(nil, 50))
+}
+
+func TestLoadQueries_SkipsBlankLinesAndComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# regression set\nplate carrier\n\n  tactical gloves  \n"), 0o644))
+
+	queries, err := loadQueries(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plate carrier", "tactical gloves"}, queries)
+}
+
+func TestBenchmark_CountsErrorsAndAveragesResults(t *testing.T) {
+	calls := 0
+	search := func(_ context.Context, query string, limit int) (int, error) {
+		calls++
+		if query == "broken" {
+			return 0, errors.New("timeout")
+		}
+		return mockSearch(context.Background(), query, limit)
+	}
+
+	report := benchmark(context.Background(), search, []string{"plate carrier", "broken", "helmet"}, 10, 2)
+	assert.Equal(t, 6, calls)
+	assert.Equal(t, 3, report.Queries)
+	assert.Equal(t, 6, report.Runs)
+	assert.Equal(t, 2, report.Errors)
+	assert.InDelta(t, 1.5, report.AvgResults, 1e-9)
+	assert.LessOrEqual(t, report.P50, report.P99)
+}
+
+func TestRun_MockSearcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.txt")
+	require.NoError(t, os.WriteFile(path, []byte("plate carrier\nhelmet\n"), 0o644))
+
+	stdout := os.Stdout
+	t.Cleanup(func() { os.Stdout = stdout })
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, run([]string{"-mock", "-queries", path, "-iterations", "3"}, &out))
+	assert.Contains(t, out.String(), "Queries: 2, runs: 6, errors: 0")
+	assert.Contains(t, out.String(), "Average results per query: 1.50")
+}
+
+func TestParseFlags_RequiresQueries(t *testing.T) {
+	_, err := parseFlags([]string{"-mock"})
+	assert.Error(t, err)
+
+	_, err = parseFlags([]string{"-queries", "q.txt", "-limit", "0"})
+	assert.Error(t, err)
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"ids/internal/analytics"
 	"ids/internal/config"
@@ -14,11 +17,15 @@ import (
 	"ids/internal/models"
 )
 
+// importBatchSize is the number of emails stored and embedded together
+const importBatchSize = 100
+
 func main() {
 	// Parse command line flags
 	emlPath := flag.String("eml", "", "Path to EML file or directory containing EML files")
 	mboxPath := flag.String("mbox", "", "Path to MBOX file")
 	generateEmbeddings := flag.Bool("embeddings", true, "Generate embeddings after import")
+	summarizeThreads := flag.Bool("summaries", false, "Summarize threads without a summary using GPT (billable)")
 	flag.Parse()
 
 	if *emlPath == "" && *mboxPath == "" {
@@ -27,14 +34,19 @@ func main() {
 		fmt.Println("  Import directory:  import-emails -eml /path/to/directory")
 		fmt.Println("  Import MBOX:       import-emails -mbox /path/to/file.mbox")
 		fmt.Println("  Skip embeddings:   import-emails -eml /path -embeddings=false")
+		fmt.Println("  Summarize threads: import-emails -eml /path -summaries")
 		os.Exit(1)
 	}
 
+	// A deleted k8s job (SIGTERM) or Ctrl-C stops the import between emails
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Load configuration
 	cfg := config.Load()
 
 	// Create write database client (local MariaDB for embeddings)
-	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL)
+	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
 	if err != nil {
 		log.Fatalf("Failed to create database client: %v", err)
 	}
@@ -57,6 +69,8 @@ func main() {
 		log.Fatalf("Failed to create email service: %v", err)
 	}
 
+	emailService.SetAnalyticsService(analyticsService)
+
 	// Create tables if they don't exist
 	fmt.Println("Creating email tables...")
 	if err := emailService.CreateEmailTables(); err != nil {
@@ -65,6 +79,31 @@ func main() {
 
 	var parsedEmails []*models.Email
 	var parseErr error
+	parsedCount := 0
+	successCount := 0
+	errorCount := 0
+	emailEmbeddingsCount := 0
+
+	// importBatch stores a batch and, if requested, embeds only the emails it just stored
+	importBatch := func(batch []*models.Email) {
+		storedIDs, stored, failed := storeEmails(emailService, batch, parsedCount)
+		parsedCount += len(batch)
+		successCount += stored
+		errorCount += failed
+
+		// Emails stored after cancellation are embedded by the next run
+		if !*generateEmbeddings || len(storedIDs) == 0 || ctx.Err() != nil {
+			return
+		}
+		emailStats, err := emailService.GenerateEmbeddingsForEmails(storedIDs)
+		if err != nil {
+			log.Printf("Warning: Failed to generate email embeddings: %v", err)
+		}
+		if emailStats != nil {
+			// Failed batches are reported in err; the rest of the batch is still embedded
+			emailEmbeddingsCount += emailStats.EmailsProcessed
+		}
+	}
 
 	// Parse emails based on input type
 	if *emlPath != "" {
@@ -88,45 +127,49 @@ func main() {
 		} else {
 			log.Fatalf("Invalid file type. Expected .eml file or directory")
 		}
+
+		if parseErr != nil {
+			log.Fatalf("Failed to parse emails: %v", parseErr)
+		}
+
+		fmt.Printf("Successfully parsed %d emails\n", len(parsedEmails))
+		fmt.Println("Storing emails in database...")
+		for i := 0; i < len(parsedEmails) && ctx.Err() == nil; i += importBatchSize {
+			end := i + importBatchSize
+			if end > len(parsedEmails) {
+				end = len(parsedEmails)
+			}
+			importBatch(parsedEmails[i:end])
+		}
 	} else if *mboxPath != "" {
 		fmt.Printf("Parsing MBOX file: %s\n", *mboxPath)
-		parsedEmails, parseErr = emails.ParseMBOXFile(*mboxPath)
+		parseErr = importMBOX(ctx, *mboxPath, importBatchSize, importBatch)
+		if parseErr != nil && ctx.Err() == nil {
+			log.Fatalf("Failed to parse emails: %v", parseErr)
+		}
 	}
 
-	if parseErr != nil {
-		log.Fatalf("Failed to parse emails: %v", parseErr)
+	if ctx.Err() != nil {
+		// Stored emails are kept; a re-run skips those already embedded
+		log.Fatalf("Import interrupted after storing %d emails (%d embedded): %v", successCount, emailEmbeddingsCount, ctx.Err())
 	}
 
-	fmt.Printf("Successfully parsed %d emails\n", len(parsedEmails))
-
-	// Store emails in database
-	fmt.Println("Storing emails in database...")
-	successCount := 0
-	errorCount := 0
+	fmt.Printf("Stored %d emails successfully (%d errors)\n", successCount, errorCount)
 
-	for i, email := range parsedEmails {
-		if err := emailService.StoreEmail(email); err != nil {
-			fmt.Printf("Warning: Failed to store email %d: %v\n", i+1, err)
-			errorCount++
-		} else {
-			successCount++
+	// Stored emails can land in existing threads, so optionally correct the thread counts, dates and participants
+	if cfg.RebuildThreadAggregates {
+		fmt.Println("Rebuilding email thread aggregates...")
+		if _, err := emailService.RebuildThreadAggregates(); err != nil {
+			log.Printf("Warning: Failed to rebuild thread aggregates: %v", err)
+		}
+		if _, err := emailService.RebuildThreadParticipants(); err != nil {
+			log.Printf("Warning: Failed to rebuild thread participants: %v", err)
 		}
 	}
 
-	fmt.Printf("Stored %d emails successfully (%d errors)\n", successCount, errorCount)
-
-	// Generate embeddings if requested
-	emailEmbeddingsCount := 0
+	// Thread embeddings need the full thread, so they are generated once after all batches
 	threadEmbeddingsCount := 0
 	if *generateEmbeddings {
-		fmt.Println("\nGenerating embeddings for individual emails...")
-		emailStats, err := emailService.GenerateEmailEmbeddingsWithStats()
-		if err != nil {
-			log.Printf("Warning: Failed to generate email embeddings: %v", err)
-		} else if emailStats != nil {
-			emailEmbeddingsCount = emailStats.EmailsProcessed
-		}
-
 		fmt.Println("\nGenerating embeddings for email threads...")
 		threadCount, err := emailService.GenerateThreadEmbeddingsWithStats()
 		if err != nil {
@@ -148,11 +191,58 @@ func main() {
 		fmt.Println("Embedding generation complete!")
 	}
 
+	// Summaries replace raw thread emails in the chat context
+	threadSummariesCount := 0
+	if *summarizeThreads {
+		fmt.Println("\nSummarizing email threads...")
+		summarized, err := emailService.SummarizeMissingThreads()
+		if err != nil {
+			log.Printf("Warning: Failed to summarize threads: %v", err)
+		}
+		threadSummariesCount = summarized
+	}
+
 	fmt.Println("\n✓ Email import complete!")
-	fmt.Printf("  - Parsed: %d emails\n", len(parsedEmails))
+	fmt.Printf("  - Parsed: %d emails\n", parsedCount)
 	fmt.Printf("  - Stored: %d emails\n", successCount)
 	if *generateEmbeddings {
 		fmt.Printf("  - Email embeddings: %d\n", emailEmbeddingsCount)
 		fmt.Printf("  - Thread embeddings: %d\n", threadEmbeddingsCount)
 	}
+	if *summarizeThreads {
+		fmt.Printf("  - Thread summaries: %d\n", threadSummariesCount)
+	}
+}
+
+// importMBOX streams the MBOX file at path to importBatch in batches of batchSize
+// It stops between emails once ctx is canceled and returns the cancellation error.
+func importMBOX(ctx context.Context, path string, batchSize int, importBatch func([]*models.Email)) error {
+	return emails.ParseMBOXFileStreamingContext(ctx, path, batchSize, func(batch []*models.Email, progress emails.MBOXProgress) error {
+		importBatch(batch)
+		fmt.Printf("[MBOX_IMPORT] Imported batch: %d emails (total: %d, %.1f%%)\n",
+			len(batch), progress.EmailsProcessed, progress.PercentComplete)
+		return nil
+	})
+}
+
+// storeEmails stores a batch of emails and returns the IDs of those stored successfully
+// offset is the number of emails already processed, used for log numbering
+func storeEmails(emailService *emails.EmailEmbeddingService, batch []*models.Email, offset int) ([]int, int, int) {
+	var storedIDs []int
+	successCount := 0
+	errorCount := 0
+
+	for i, email := range batch {
+		if err := emailService.StoreEmail(email); err != nil {
+			fmt.Printf("Warning: Failed to store email %d: %v\n", offset+i+1, err)
+			errorCount++
+			continue
+		}
+		successCount++
+		if email.ID > 0 {
+			storedIDs = append(storedIDs, email.ID)
+		}
+	}
+
+	return storedIDs, successCount, errorCount
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeMBOX writes count minimal messages to an MBOX file and returns its path
+func writeMBOX(t *testing.T, count int) string {
+	t.Helper()
+	var mbox strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&mbox, "From buyer@example.com Mon Feb  2 10:00:00 2026\n"+
+			"Message-ID: <%d@example.com>\nFrom: buyer@example.com\nTo: support@ids.com\nSubject: Order %d\n\nWhere is order %d?\n\n", i, i, i)
+	}
+	path := filepath.Join(t.TempDir(), "inbox.mbox")
+	require.NoError(t, os.WriteFile(path, []byte(mbox.String()), 0o644))
+	return path
+}
+
+func TestImportMBOX_ImportsAllBatches(t *testing.T) {
+	var sizes []int
+	err := importMBOX(context.Background(), writeMBOX(t, 3), 2, func(batch []*models.Email) {
+		sizes = append(sizes, len(batch))
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, sizes)
+}
+
+func TestImportMBOX_StopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The job is stopped while the first batch is imported; the final batch is never imported
+	var sizes []int
+	err := importMBOX(ctx, writeMBOX(t, 3), 2, func(batch []*models.Email) {
+		sizes = append(sizes, len(batch))
+		cancel()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int{2}, sizes)
+}
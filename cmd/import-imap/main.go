@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"ids/internal/analytics"
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/emails"
+)
+
+func main() {
+	// Parse command line flags
+	generateEmbeddings := flag.Bool("embeddings", true, "Generate embeddings after import")
+	summarizeThreads := flag.Bool("summaries", false, "Summarize threads without a summary using GPT (billable)")
+	flag.Parse()
+
+	// Load configuration
+	cfg := config.Load()
+	if !cfg.HasIMAP() {
+		fmt.Println("Usage:")
+		fmt.Println("  IMAP_HOST=imap.example.com IMAP_USERNAME=support@example.com IMAP_PASSWORD=... import-imap")
+		fmt.Println("  Skip embeddings:   import-imap -embeddings=false")
+		fmt.Println("  Summarize threads: import-imap -summaries")
+		fmt.Println("Optional: IMAP_PORT (default 993), IMAP_MAILBOX (default INBOX), IMAP_BATCH_SIZE (default 50)")
+		log.Fatalf("IMAP not configured: set IMAP_HOST, IMAP_USERNAME and IMAP_PASSWORD")
+	}
+
+	// Create write database client
+	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
+	if err != nil {
+		log.Fatalf("Failed to create database client: %v", err)
+	}
+	defer func() {
+		if err := writeClient.Close(); err != nil {
+			log.Printf("Error closing write client: %v", err)
+		}
+	}()
+
+	// Initialize analytics service
+	var analyticsService *analytics.Service
+	analyticsService, err = analytics.NewService(writeClient)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize analytics service: %v", err)
+	}
+
+	// Create email embedding service
+	emailService, err := emails.NewEmailEmbeddingService(cfg, writeClient)
+	if err != nil {
+		log.Fatalf("Failed to create email service: %v", err)
+	}
+
+	emailService.SetAnalyticsService(analyticsService)
+
+	// Create tables if they don't exist
+	fmt.Println("Creating email tables...")
+	if err := emailService.CreateEmailTables(); err != nil {
+		log.Fatalf("Failed to create email tables: %v", err)
+	}
+
+	// Embed each batch as soon as it is stored, so an interrupted import leaves no unembedded emails behind
+	emailEmbeddingsCount := 0
+	embedBatch := func(storedIDs []int) error {
+		if !*generateEmbeddings || len(storedIDs) == 0 {
+			return nil
+		}
+		emailStats, err := emailService.GenerateEmbeddingsForEmails(storedIDs)
+		if err != nil {
+			log.Printf("Warning: Failed to generate email embeddings: %v", err)
+		}
+		if emailStats != nil {
+			// Failed batches are reported in err; the rest of the batch is still embedded
+			emailEmbeddingsCount += emailStats.EmailsProcessed
+		}
+		return nil
+	}
+
+	fmt.Printf("Importing new messages from %s (%s)...\n", cfg.IMAPHost, cfg.IMAPMailbox)
+	stats, importErr := emails.ImportFromIMAP(cfg, emailService, embedBatch)
+	if stats == nil {
+		log.Fatalf("Failed to import from IMAP: %v", importErr)
+	}
+	if importErr != nil {
+		// Progress through the last completed batch is saved; the next run resumes from there
+		log.Printf("Warning: IMAP import stopped early: %v", importErr)
+	}
+
+	fmt.Printf("Stored %d emails successfully (%d skipped)\n", stats.Stored, stats.Skipped)
+
+	// Stored emails can land in existing threads, so optionally correct the thread counts, dates and participants
+	if cfg.RebuildThreadAggregates && stats.Stored > 0 {
+		fmt.Println("Rebuilding email thread aggregates...")
+		if _, err := emailService.RebuildThreadAggregates(); err != nil {
+			log.Printf("Warning: Failed to rebuild thread aggregates: %v", err)
+		}
+		if _, err := emailService.RebuildThreadParticipants(); err != nil {
+			log.Printf("Warning: Failed to rebuild thread participants: %v", err)
+		}
+	}
+
+	// Thread embeddings need the full thread, so they are generated once after all batches
+	threadEmbeddingsCount := 0
+	if *generateEmbeddings && stats.Stored > 0 {
+		fmt.Println("\nGenerating embeddings for email threads...")
+		threadCount, err := emailService.GenerateThreadEmbeddingsWithStats()
+		if err != nil {
+			log.Printf("Warning: Failed to generate thread embeddings: %v", err)
+		} else {
+			threadEmbeddingsCount = threadCount
+		}
+
+		// Track email embeddings analytics
+		if analyticsService != nil {
+			if err := analyticsService.TrackEmailEmbeddings(emailEmbeddingsCount, true); err != nil {
+				log.Printf("Warning: Failed to track email embeddings: %v", err)
+			}
+			if err := analyticsService.TrackThreadEmbeddings(threadEmbeddingsCount, true); err != nil {
+				log.Printf("Warning: Failed to track thread embeddings: %v", err)
+			}
+		}
+
+		fmt.Println("Embedding generation complete!")
+	}
+
+	// Summaries replace raw thread emails in the chat context
+	threadSummariesCount := 0
+	if *summarizeThreads {
+		fmt.Println("\nSummarizing email threads...")
+		summarized, err := emailService.SummarizeMissingThreads()
+		if err != nil {
+			log.Printf("Warning: Failed to summarize threads: %v", err)
+		}
+		threadSummariesCount = summarized
+	}
+
+	fmt.Println("\n✓ IMAP import complete!")
+	fmt.Printf("  - Fetched: %d messages\n", stats.Fetched)
+	fmt.Printf("  - Stored: %d emails\n", stats.Stored)
+	fmt.Printf("  - Synced through UID: %d\n", stats.LastUID)
+	if *generateEmbeddings {
+		fmt.Printf("  - Email embeddings: %d\n", emailEmbeddingsCount)
+		fmt.Printf("  - Thread embeddings: %d\n", threadEmbeddingsCount)
+	}
+	if *summarizeThreads {
+		fmt.Printf("  - Thread summaries: %d\n", threadSummariesCount)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"ids/internal/analytics"
@@ -9,6 +11,7 @@ import (
 	"ids/internal/database"
 	"ids/internal/embeddings"
 	"ids/internal/vectordb"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -20,14 +23,55 @@ import (
 )
 
 func main() {
-	// Parse command-line flags
-	runOnce := flag.Bool("once", false, "Run embeddings generation once and exit (default: false, runs continuously)")
-	flag.Parse()
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// cliOptions holds the parsed command-line flags
+type cliOptions struct {
+	RunOnce    bool // Run embeddings generation once and exit
+	JSONOutput bool // Print a machine-readable summary instead of human text (implies RunOnce)
+
+	RollbackMigrations int // Revert this many of the latest schema migrations and exit (0 = none)
+}
+
+// parseFlags parses command-line arguments into cliOptions
+func parseFlags(args []string) (cliOptions, error) {
+	var opts cliOptions
+	fs := flag.NewFlagSet("init-embeddings-write", flag.ContinueOnError)
+	fs.BoolVar(&opts.RunOnce, "once", false, "Run embeddings generation once and exit (default: false, runs continuously)")
+	fs.BoolVar(&opts.JSONOutput, "json", false, "Print a JSON summary of the run to stdout (implies -once)")
+	fs.IntVar(&opts.RollbackMigrations, "rollback-migrations", 0, "Revert the latest N schema migrations and exit (they are applied again on the next run)")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if opts.RollbackMigrations < 0 {
+		return opts, fmt.Errorf("-rollback-migrations must not be negative")
+	}
+	if opts.JSONOutput {
+		opts.RunOnce = true
+	}
+	return opts, nil
+}
+
+// run executes the command and returns the process exit code
+func run(args []string, stdout io.Writer) int {
+	opts, err := parseFlags(args)
+	if err != nil {
+		return 2
+	}
 
-	printStartupMessage(*runOnce)
+	if opts.JSONOutput {
+		// Keep stdout clean for the JSON summary; progress output goes to stderr
+		os.Stdout = os.Stderr
+	} else {
+		printStartupMessage(opts.RunOnce)
+	}
 
 	// Load configuration
 	cfg := config.Load()
+	if opts.RollbackMigrations > 0 {
+		return rollbackMigrations(cfg, opts.RollbackMigrations)
+	}
 	scheduleInterval := time.Duration(cfg.EmbeddingScheduleHours) * time.Hour
 	scheduleDescription := formatScheduleDescription(cfg.EmbeddingScheduleHours)
 
@@ -51,27 +95,95 @@ func main() {
 	}
 
 	// Initialize embedding service
-	embeddingService := initializeEmbeddingService(cfg, readDB, writeClient, *runOnce)
+	embeddingService := initializeEmbeddingService(cfg, readDB, writeClient)
 	if embeddingService != nil {
 		createEmbeddingsTable(embeddingService)
+	} else if opts.RunOnce {
+		// Quota exceeded during initialization; not treated as a failure
+		return finishOnce(opts, stdout, nil, 0, fmt.Errorf("embedding service unavailable: OpenAI quota exceeded"), 0)
 	}
 
 	// Set up signal handling
-	sigChan := setupSignalHandling()
+	ctx, stop := setupSignalHandling()
+	defer stop()
 
 	// Run initial embedding generation if service is available
+	var stats *embeddings.EmbeddingStats
+	var genErr error
+	var duration time.Duration
+	exitCode := 0
 	if embeddingService != nil {
-		handleInitialGeneration(embeddingService, analyticsService, *runOnce)
+		start := time.Now()
+		stats, exitCode, genErr = handleInitialGeneration(ctx, embeddingService, analyticsService)
+		duration = time.Since(start)
 	}
 
-	// If running once, exit cleanly
-	if *runOnce {
-		fmt.Println("One-time run completed. Exiting.")
-		return
+	// If running once (or shutting down), exit cleanly
+	if opts.RunOnce {
+		return finishOnce(opts, stdout, stats, duration, genErr, exitCode)
+	}
+	if ctx.Err() != nil {
+		return 0
 	}
 
 	// Run scheduled mode
-	runScheduledMode(cfg, scheduleInterval, scheduleDescription, readDB, writeClient, embeddingService, analyticsService, sigChan)
+	runScheduledMode(ctx, cfg, scheduleInterval, scheduleDescription, readDB, writeClient, embeddingService, analyticsService)
+	return 0
+}
+
+// finishOnce reports the result of a one-time run and returns the exit code
+func finishOnce(opts cliOptions, stdout io.Writer, stats *embeddings.EmbeddingStats, duration time.Duration, genErr error, exitCode int) int {
+	if !opts.JSONOutput {
+		if exitCode == 0 && isQuotaError(genErr) {
+			fmt.Println("One-time run completed with warnings (quota exceeded). Exiting.")
+		} else if exitCode == 0 {
+			fmt.Println("One-time run completed. Exiting.")
+		}
+		return exitCode
+	}
+
+	if err := writeSummary(stdout, newGenerationSummary(stats, duration, genErr)); err != nil {
+		log.Printf("ERROR: Failed to write JSON summary: %v", err)
+		return 1
+	}
+	return exitCode
+}
+
+// generationSummary is the machine-readable result of a one-time run (-json)
+type generationSummary struct {
+	TotalProducts    int     `json:"total_products"`
+	ChangedProducts  int     `json:"changed_products"`
+	DeletedProducts  int     `json:"deleted_products"`
+	BatchesProcessed int     `json:"batches_processed"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Success          bool    `json:"success"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// newGenerationSummary builds the JSON summary from the run's EmbeddingStats
+// stats may be nil when the embedding service could not be initialized
+func newGenerationSummary(stats *embeddings.EmbeddingStats, duration time.Duration, err error) generationSummary {
+	summary := generationSummary{DurationSeconds: duration.Seconds()}
+	if stats != nil {
+		summary.TotalProducts = stats.TotalProducts
+		summary.ChangedProducts = stats.ChangedProducts
+		summary.DeletedProducts = stats.DeletedProducts
+		summary.BatchesProcessed = stats.BatchesProcessed
+		summary.Provider = stats.Provider
+		summary.Model = stats.Model
+		summary.Success = stats.Success && err == nil
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return summary
+}
+
+// writeSummary writes the summary as a single JSON object
+func writeSummary(w io.Writer, summary generationSummary) error {
+	return json.NewEncoder(w).Encode(summary)
 }
 
 // printStartupMessage prints the startup message based on run mode
@@ -114,7 +226,7 @@ func initializeDatabases(cfg *config.Config) (*sqlx.DB, *database.WriteClient) {
 	}
 
 	fmt.Println("Connecting to embeddings database with write access...")
-	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL)
+	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
 	if err != nil {
 		log.Fatal("Failed to connect to embeddings database with write access:", err)
 	}
@@ -133,6 +245,8 @@ func closeDatabases(readDB *sqlx.DB, writeClient *database.WriteClient) {
 }
 
 // isQuotaError checks if an error is related to OpenAI quota
+// The unified client already retries 429s (OPENAI_MAX_RETRIES), so this only sees quota
+// errors that outlasted the retries
 func isQuotaError(err error) bool {
 	if err == nil {
 		return false
@@ -142,7 +256,7 @@ func isQuotaError(err error) bool {
 }
 
 // initializeEmbeddingService initializes the embedding service with quota error handling
-func initializeEmbeddingService(cfg *config.Config, readDB *sqlx.DB, writeClient *database.WriteClient, runOnce bool) *embeddings.WriteEmbeddingService {
+func initializeEmbeddingService(cfg *config.Config, readDB *sqlx.DB, writeClient *database.WriteClient) *embeddings.WriteEmbeddingService {
 	fmt.Println("Initializing embedding service...")
 
 	// Initialize Qdrant client if URL is configured
@@ -170,11 +284,7 @@ func initializeEmbeddingService(cfg *config.Config, readDB *sqlx.DB, writeClient
 	embeddingService, err := embeddings.NewWriteEmbeddingService(cfg, readDB.DB, writeClient, qdrantClient)
 	if err != nil {
 		if isQuotaError(err) {
-			log.Printf("WARNING: OpenAI API quota exceeded. Embedding generation skipped. Error: %v", err)
-			if runOnce {
-				fmt.Println("One-time run completed with warnings (quota exceeded). Exiting.")
-				os.Exit(0)
-			}
+			log.Printf("WARNING: OpenAI API quota exceeded after retries. Embedding generation skipped. Error: %v", err)
 			log.Printf("Will retry on next scheduled run. Current time: %s", time.Now().Format(time.RFC3339))
 			return nil
 		}
@@ -183,68 +293,129 @@ func initializeEmbeddingService(cfg *config.Config, readDB *sqlx.DB, writeClient
 	return embeddingService
 }
 
-// createEmbeddingsTable creates the embeddings table if it doesn't exist
+// createEmbeddingsTable applies the schema migrations, which create the embeddings tables if they don't exist
 func createEmbeddingsTable(embeddingService *embeddings.WriteEmbeddingService) {
-	fmt.Println("Creating embeddings table...")
+	fmt.Println("Creating embeddings table and applying schema migrations...")
 	if err := embeddingService.CreateEmbeddingsTable(); err != nil {
 		log.Printf("WARNING: Failed to create embeddings table: %v", err)
 		// Don't exit - table might already exist
 	}
 }
 
-// setupSignalHandling sets up signal handling for graceful shutdown
-func setupSignalHandling() chan os.Signal {
+// rollbackMigrations reverts the latest steps schema migrations and returns the process exit code
+// Only the embeddings database is needed, so the SSH tunnel and the product database are skipped.
+func rollbackMigrations(cfg *config.Config, steps int) int {
+	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
+	if err != nil {
+		log.Printf("ERROR: Failed to connect to embeddings database with write access: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := writeClient.Close(); err != nil {
+			log.Printf("Error closing write client: %v", err)
+		}
+	}()
+
+	fmt.Printf("Rolling back %d schema migrations...\n", steps)
+	// The vector size only matters when the baseline is applied, not when it is rolled back
+	reverted, err := writeClient.RollbackMigrations(database.SchemaMigrations(vectordb.VectorDimensions), steps)
+	fmt.Printf("Rolled back %d schema migrations\n", reverted)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// setupSignalHandling returns a context that is canceled on SIGINT/SIGTERM for graceful shutdown
+// In-flight embedding generation stops at the next batch boundary
+func setupSignalHandling() (context.Context, context.CancelFunc) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	return sigChan
+	return shutdownContext(sigChan)
+}
+
+// shutdownContext returns a context canceled when a signal arrives on sigChan
+func shutdownContext(sigChan <-chan os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case sig := <-sigChan:
+			fmt.Printf("\nReceived signal %v, shutting down gracefully...\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }
 
 // handleInitialGeneration runs the initial embedding generation
-func handleInitialGeneration(embeddingService *embeddings.WriteEmbeddingService, analyticsService *analytics.Service, runOnce bool) {
+// It returns the run's stats, the exit code for a one-time run and the error (if any)
+func handleInitialGeneration(ctx context.Context, embeddingService *embeddings.WriteEmbeddingService, analyticsService *analytics.Service) (*embeddings.EmbeddingStats, int, error) {
 	fmt.Println("Running embedding generation...")
-	if err := runEmbeddingGeneration(embeddingService, analyticsService); err != nil {
+	stats, err := runEmbeddingGeneration(ctx, embeddingService, analyticsService)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("Embedding generation stopped by shutdown; completed batches are saved")
+			return stats, 0, err
+		}
 		if isQuotaError(err) {
 			log.Printf("WARNING: Embedding generation skipped due to OpenAI quota: %v", err)
-			if runOnce {
-				fmt.Println("One-time run completed with warnings (quota exceeded). Exiting.")
-				os.Exit(0)
-			}
-		} else {
-			log.Printf("ERROR: Embedding generation failed: %v", err)
-			if runOnce {
-				os.Exit(1)
-			}
+			return stats, 0, err
 		}
-	} else {
-		fmt.Println("Embedding generation completed successfully")
+		log.Printf("ERROR: Embedding generation failed: %v", err)
+		return stats, 1, err
 	}
+	fmt.Println("Embedding generation completed successfully")
+	return stats, 0, nil
 }
 
+// defaultScheduleInterval is used when the configured interval is not positive
+const defaultScheduleInterval = 168 * time.Hour
+
 // runScheduledMode runs the scheduled embedding generation loop
-func runScheduledMode(cfg *config.Config, scheduleInterval time.Duration, scheduleDescription string,
+func runScheduledMode(ctx context.Context, cfg *config.Config, scheduleInterval time.Duration, scheduleDescription string,
 	readDB *sqlx.DB, writeClient *database.WriteClient,
-	embeddingService *embeddings.WriteEmbeddingService, analyticsService *analytics.Service, sigChan chan os.Signal) {
-	ticker := time.NewTicker(scheduleInterval)
-	defer ticker.Stop()
+	embeddingService *embeddings.WriteEmbeddingService, analyticsService *analytics.Service) {
+	scheduleInterval = safeScheduleInterval(scheduleInterval)
 
 	fmt.Printf("\nEmbedding service is now running in scheduled mode.\n")
 	fmt.Printf("Will regenerate embeddings %s.\n", scheduleDescription)
 	fmt.Printf("Schedule interval: %d hours (%v)\n", cfg.EmbeddingScheduleHours, scheduleInterval)
 	fmt.Println("Press Ctrl+C to stop the service.")
 
+	runScheduleLoop(ctx, scheduleInterval, func() {
+		handleScheduledGeneration(ctx, cfg, readDB, writeClient, &embeddingService, analyticsService)
+	})
+}
+
+// safeScheduleInterval returns the interval, or the default if it is zero or negative
+// time.NewTicker panics on non-positive durations
+func safeScheduleInterval(scheduleInterval time.Duration) time.Duration {
+	if scheduleInterval <= 0 {
+		log.Printf("WARNING: Invalid schedule interval %v, using default of %v", scheduleInterval, defaultScheduleInterval)
+		return defaultScheduleInterval
+	}
+	return scheduleInterval
+}
+
+// runScheduleLoop calls run on every tick until ctx is canceled
+func runScheduleLoop(ctx context.Context, scheduleInterval time.Duration, run func()) {
+	ticker := time.NewTicker(safeScheduleInterval(scheduleInterval))
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ticker.C:
-			handleScheduledGeneration(cfg, readDB, writeClient, &embeddingService, analyticsService)
-		case sig := <-sigChan:
-			fmt.Printf("\nReceived signal %v, shutting down gracefully...\n", sig)
+			run()
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
 // handleScheduledGeneration handles a scheduled embedding generation run
-func handleScheduledGeneration(cfg *config.Config, readDB *sqlx.DB, writeClient *database.WriteClient,
+func handleScheduledGeneration(ctx context.Context, cfg *config.Config, readDB *sqlx.DB, writeClient *database.WriteClient,
 	embeddingService **embeddings.WriteEmbeddingService, analyticsService *analytics.Service) {
 	fmt.Printf("\n=== SCHEDULED EMBEDDING GENERATION TRIGGERED ===\n")
 	fmt.Printf("Starting at: %s\n", time.Now().Format(time.RFC3339))
@@ -258,7 +429,7 @@ func handleScheduledGeneration(cfg *config.Config, readDB *sqlx.DB, writeClient
 	}
 
 	// Run embedding generation
-	if err := runEmbeddingGeneration(*embeddingService, analyticsService); err != nil {
+	if _, err := runEmbeddingGeneration(ctx, *embeddingService, analyticsService); err != nil {
 		handleScheduledGenerationError(err, embeddingService)
 	} else {
 		fmt.Printf("Scheduled embedding generation completed successfully at: %s\n", time.Now().Format(time.RFC3339))
@@ -306,7 +477,9 @@ func reinitializeEmbeddingService(cfg *config.Config, readDB *sqlx.DB, writeClie
 
 // handleScheduledGenerationError handles errors during scheduled generation
 func handleScheduledGenerationError(err error, embeddingService **embeddings.WriteEmbeddingService) {
-	if isQuotaError(err) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("Scheduled embedding generation stopped by shutdown; completed batches are saved")
+	} else if isQuotaError(err) {
 		log.Printf("WARNING: Scheduled embedding generation skipped due to OpenAI quota: %v", err)
 		// Set service to nil so we retry initialization next time
 		*embeddingService = nil
@@ -317,23 +490,23 @@ func handleScheduledGenerationError(err error, embeddingService **embeddings.Wri
 }
 
 // runEmbeddingGeneration runs the embedding generation process
-func runEmbeddingGeneration(embeddingService *embeddings.WriteEmbeddingService, analyticsService *analytics.Service) error {
+func runEmbeddingGeneration(ctx context.Context, embeddingService *embeddings.WriteEmbeddingService, analyticsService *analytics.Service) (*embeddings.EmbeddingStats, error) {
 	if embeddingService == nil {
-		return fmt.Errorf("embedding service is not initialized")
+		return nil, fmt.Errorf("embedding service is not initialized")
 	}
 
 	start := time.Now()
 
-	stats, err := embeddingService.GenerateProductEmbeddingsWithStats()
+	stats, err := embeddingService.GenerateProductEmbeddingsWithStats(ctx)
 	if err != nil {
 		// Track failed embedding generation
 		if analyticsService != nil && stats != nil {
 			_ = analyticsService.TrackProductEmbeddings(stats.TotalProducts, stats.ChangedProducts, false)
 		}
 		if isQuotaError(err) {
-			return fmt.Errorf("OpenAI quota exceeded: %v", err)
+			return stats, fmt.Errorf("OpenAI quota exceeded: %v", err)
 		}
-		return fmt.Errorf("failed to generate product embeddings: %v", err)
+		return stats, fmt.Errorf("failed to generate product embeddings: %w", err)
 	}
 
 	// Track successful embedding generation
@@ -344,8 +517,8 @@ func runEmbeddingGeneration(embeddingService *embeddings.WriteEmbeddingService,
 	}
 
 	duration := time.Since(start)
-	fmt.Printf("Successfully generated embeddings in %v (total: %d, changed: %d)\n", duration, stats.TotalProducts, stats.ChangedProducts)
-	return nil
+	fmt.Printf("Successfully generated embeddings in %v (total: %d, changed: %d, deleted: %d)\n", duration, stats.TotalProducts, stats.ChangedProducts, stats.DeletedProducts)
+	return stats, nil
 }
 
 // formatScheduleDescription returns a human-readable description of the schedule
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"ids/internal/embeddings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeScheduleInterval(t *testing.T) {
+	assert.Equal(t, defaultScheduleInterval, safeScheduleInterval(0))
+	assert.Equal(t, defaultScheduleInterval, safeScheduleInterval(-time.Hour))
+	assert.Equal(t, 24*time.Hour, safeScheduleInterval(24*time.Hour))
+}
+
+func TestRunScheduleLoop_ZeroIntervalDoesNotPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runs := 0
+	assert.NotPanics(t, func() {
+		runScheduleLoop(ctx, 0, func() { runs++ })
+	})
+	// The fallback interval is a week, so nothing runs before the shutdown is handled
+	assert.Equal(t, 0, runs)
+}
+
+func TestRunScheduleLoop_RunsOnTick(t *testing.T) {
+	sigChan := make(chan os.Signal, 1)
+	ctx, cancel := shutdownContext(sigChan)
+	defer cancel()
+	done := make(chan struct{})
+
+	runs := 0
+	go func() {
+		runScheduleLoop(ctx, time.Millisecond, func() {
+			runs++
+			if runs == 2 {
+				sigChan <- syscall.SIGTERM
+			}
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("schedule loop did not stop after signal")
+	}
+	assert.GreaterOrEqual(t, runs, 2)
+}
+
+func TestShutdownContext_CanceledBySignal(t *testing.T) {
+	sigChan := make(chan os.Signal, 1)
+	ctx, cancel := shutdownContext(sigChan)
+	defer cancel()
+
+	assert.NoError(t, ctx.Err())
+	sigChan <- syscall.SIGINT
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("context was not canceled after signal")
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	opts, err := parseFlags(nil)
+	require.NoError(t, err)
+	assert.Equal(t, cliOptions{}, opts)
+
+	opts, err = parseFlags([]string{"-once"})
+	require.NoError(t, err)
+	assert.Equal(t, cliOptions{RunOnce: true}, opts)
+
+	opts, err = parseFlags([]string{"-json"})
+	require.NoError(t, err)
+	assert.Equal(t, cliOptions{RunOnce: true, JSONOutput: true}, opts)
+
+	opts, err = parseFlags([]string{"-rollback-migrations", "2"})
+	require.NoError(t, err)
+	assert.Equal(t, cliOptions{RollbackMigrations: 2}, opts)
+
+	_, err = parseFlags([]string{"-rollback-migrations", "-1"})
+	assert.Error(t, err)
+}
+
+func TestRun_InvalidFlag(t *testing.T) {
+	var stdout bytes.Buffer
+	assert.Equal(t, 2, run([]string{"-bogus"}, &stdout))
+	assert.Empty(t, stdout.String())
+}
+
+func TestWriteSummary_JSONShape(t *testing.T) {
+	stats := &embeddings.EmbeddingStats{
+		TotalProducts:    1200,
+		ChangedProducts:  250,
+		DeletedProducts:  7,
+		BatchesProcessed: 3,
+		Provider:         "Azure OpenAI",
+		Model:            "text-embedding-3-small",
+		Success:          true,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSummary(&buf, newGenerationSummary(stats, 1500*time.Millisecond, nil)))
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, map[string]interface{}{
+		"total_products":    float64(1200),
+		"changed_products":  float64(250),
+		"deleted_products":  float64(7),
+		"batches_processed": float64(3),
+		"duration_seconds":  1.5,
+		"provider":          "Azure OpenAI",
+		"model":             "text-embedding-3-small",
+		"success":           true,
+	}, got)
+}
+
+func TestNewGenerationSummary_Failure(t *testing.T) {
+	stats := &embeddings.EmbeddingStats{TotalProducts: 10, ChangedProducts: 4, BatchesProcessed: 1}
+	summary := newGenerationSummary(stats, time.Second, errors.New("failed to process batch 100-200"))
+	assert.False(t, summary.Success)
+	assert.Equal(t, "failed to process batch 100-200", summary.Error)
+	assert.Equal(t, 1, summary.BatchesProcessed)
+
+	summary = newGenerationSummary(nil, 0, errors.New("embedding service unavailable"))
+	assert.False(t, summary.Success)
+	assert.Equal(t, 0, summary.TotalProducts)
+}
+
+func TestFinishOnce_HumanModeWritesNoJSON(t *testing.T) {
+	var stdout bytes.Buffer
+	assert.Equal(t, 1, finishOnce(cliOptions{RunOnce: true}, &stdout, nil, 0, errors.New("boom"), 1))
+	assert.Empty(t, stdout.String())
+
+	assert.Equal(t, 0, finishOnce(cliOptions{RunOnce: true, JSONOutput: true}, io.Discard, &embeddings.EmbeddingStats{Success: true}, 0, nil, 0))
+}
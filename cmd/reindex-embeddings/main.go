@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/embeddings"
+	"ids/internal/vectordb"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// cliOptions holds the parsed command-line flags
+type cliOptions struct {
+	M              int // Max connections per HNSW graph node
+	EfConstruction int // Candidate list size while building the graph
+}
+
+// parseFlags parses command-line arguments into cliOptions
+func parseFlags(args []string) (cliOptions, error) {
+	var opts cliOptions
+	fs := flag.NewFlagSet("reindex-embeddings", flag.ContinueOnError)
+	fs.IntVar(&opts.M, "m", vectordb.DefaultHNSWM, "HNSW m: connections per node (higher = better recall, bigger index)")
+	fs.IntVar(&opts.EfConstruction, "ef-construction", vectordb.DefaultHNSWEfConstruction, "HNSW ef_construction: build candidate list size (higher = better recall, slower build)")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if err := vectordb.ValidateHNSWParams(opts.M, opts.EfConstruction); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// run executes the command and returns the process exit code
+func run(args []string, stdout io.Writer) int {
+	opts, err := parseFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reindex-embeddings: %v\n", err)
+		return 2
+	}
+
+	cfg := config.Load()
+	if cfg.IndexType != config.IndexTypeHNSW {
+		// Building an HNSW index would leave it next to the configured one
+		log.Printf("ERROR: VECTOR_INDEX_TYPE is %s; reindex-embeddings only rebuilds HNSW indexes", cfg.IndexType)
+		return 1
+	}
+	writeClient, err := database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
+	if err != nil {
+		log.Printf("ERROR: Failed to create write database client: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := writeClient.Close(); err != nil {
+			log.Printf("Error closing write client: %v", err)
+		}
+	}()
+
+	fmt.Fprintf(stdout, "Rebuilding the product_embeddings HNSW index (%s, m = %d, ef_construction = %d); searches wait until it finishes\n",
+		cfg.DistanceMetric, opts.M, opts.EfConstruction)
+	if err := embeddings.ReindexProductEmbeddings(writeClient, cfg.DistanceMetric, opts.M, opts.EfConstruction); err != nil {
+		log.Printf("ERROR: Failed to rebuild the HNSW index: %v", err)
+		return 1
+	}
+	return 0
+}
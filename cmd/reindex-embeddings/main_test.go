@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags(t *testing.T) {
+	opts, err := parseFlags(nil)
+	require.NoError(t, err)
+	assert.Equal(t, cliOptions{M: 16, EfConstruction: 100}, opts)
+
+	opts, err = parseFlags([]string{"-m", "32", "-ef-construction", "200"})
+	require.NoError(t, err)
+	assert.Equal(t, cliOptions{M: 32, EfConstruction: 200}, opts)
+
+	_, err = parseFlags([]string{"-m", "1"})
+	assert.ErrorContains(t, err, "m must be between 2 and 100")
+	_, err = parseFlags([]string{"-m", "64"})
+	assert.ErrorContains(t, err, "at least 2*m")
+}
+
+func TestRun_InvalidFlagsExitWithUsageError(t *testing.T) {
+	assert.Equal(t, 2, run([]string{"-ef-construction", "2000"}, nil))
+}
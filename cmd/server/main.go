@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"ids/docs"
 	"ids/internal/config"
 	"ids/internal/database"
 	"ids/internal/server"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
+// shutdownTimeout bounds how long in-flight requests may finish after SIGTERM
+const shutdownTimeout = 20 * time.Second
+
 // waitForTunnel waits for the SSH tunnel to be ready
 func waitForTunnel(logger *zerolog.Logger) {
 	tunnelReadyFile := "/shared/tunnel-ready"
@@ -60,7 +68,7 @@ func main() {
 	db, err := database.New(cfg.DatabaseURL)
 	if err != nil {
 		logger.Warn().Err(err).Msg("Database connection failed")
-		logger.Info().Msg("Starting server without database connection")
+		logger.Info().Msg("Starting server without database connection, reconnecting in the background")
 	} else {
 		logger.Info().Msg("Database connection established successfully")
 	}
@@ -69,8 +77,23 @@ func main() {
 	srv := server.New(cfg, db, logger)
 	srv.Initialize()
 
+	// Stop on SIGINT or SIGTERM (e.g. a Kubernetes rollout)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start server
-	if err := srv.Start(); err != nil {
-		logger.Fatal().Err(err).Msg("Server failed to start")
+	go func() {
+		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal().Err(err).Msg("Server failed to start")
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info().Msg("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Server did not shut down cleanly")
 	}
 }
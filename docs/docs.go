@@ -539,6 +539,11 @@ const docTemplate = `{
                     "type": "string",
                     "example": ""
                 },
+                "has_more_products": {
+                    "description": "Whether more products matched than were shown to the AI",
+                    "type": "boolean",
+                    "example": false
+                },
                 "products": {
                     "description": "Product name to SKU mapping for link generation",
                     "type": "object",
@@ -555,6 +560,11 @@ const docTemplate = `{
                     "description": "AI response message",
                     "type": "string",
                     "example": "Hello! How can I help you today?"
+                },
+                "total_products": {
+                    "description": "Number of matching products after stock filtering",
+                    "type": "integer",
+                    "example": 8
                 }
             }
         },
@@ -2,7 +2,9 @@ package analytics
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -22,8 +24,14 @@ const (
 	EventProductEmbeddings    = "product_embeddings"
 	EventEmailEmbeddings      = "email_embeddings"
 	EventThreadEmbeddings     = "thread_embeddings"
-	EventQueryEmbedding       = "query_embedding"       // Per-search embedding generation (billable)
-	EventSupportSummarization = "support_summarization" // GPT call for support summary (billable)
+	EventQueryEmbedding       = "query_embedding"           // Per-search embedding generation (billable)
+	EventSupportSummarization = "support_summarization"     // GPT call for support summary (billable)
+	EventPromptInjection      = "prompt_injection"          // User query flagged as a prompt-injection attempt
+	EventLanguageCorrection   = "language_correction"       // Corrective GPT re-prompt for a wrong-language reply (billable)
+	EventSessionTokenCap      = "session_token_cap"         // Chat reply refused because the session used up MAX_SESSION_TOKENS
+	EventEmbeddingRegen       = "embedding_regen"           // Admin-triggered product embedding run started or finished
+	EventSearchLatency        = "search_latency"            // Duration of one chat product or email search (metadata duration_ms)
+	EventConversationSaveFail = "conversation_save_failure" // Chat transcript that could not be saved to chat_sessions
 )
 
 // Period constants for analytics queries
@@ -32,8 +40,12 @@ const (
 	PeriodYesterday  = "yesterday"
 	PeriodLast7Days  = "last_7_days"
 	PeriodLast30Days = "last_30_days"
+	PeriodCustom     = "custom" // Arbitrary date range (GetSummaryRange)
 )
 
+// ErrInvalidRange is returned for a date range whose start is after its end
+var ErrInvalidRange = errors.New("start date is after end date")
+
 // Service handles analytics tracking and retrieval
 type Service struct {
 	writeClient *database.WriteClient
@@ -46,54 +58,10 @@ func NewService(writeClient *database.WriteClient) (*Service, error) {
 		return nil, fmt.Errorf("write client is required for analytics service")
 	}
 
-	service := &Service{
+	// The analytics tables are created by the schema migrations (database.SchemaMigrations)
+	return &Service{
 		writeClient: writeClient,
-	}
-
-	// Create analytics tables if they don't exist
-	if err := service.createTables(); err != nil {
-		return nil, fmt.Errorf("failed to create analytics tables: %w", err)
-	}
-
-	return service, nil
-}
-
-// createTables creates the analytics tables in the database
-func (s *Service) createTables() error {
-	queries := []string{
-		// Analytics events table
-		`CREATE TABLE IF NOT EXISTS analytics_events (
-			id SERIAL PRIMARY KEY,
-			event_type VARCHAR(50) NOT NULL,
-			count INT DEFAULT 1,
-			metadata JSONB,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		// Create indexes
-		`CREATE INDEX IF NOT EXISTS idx_analytics_event_type ON analytics_events(event_type)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_created_at ON analytics_events(created_at)`,
-		// Daily aggregates table for faster queries
-		`CREATE TABLE IF NOT EXISTS analytics_daily (
-			id SERIAL PRIMARY KEY,
-			date DATE NOT NULL,
-			event_type VARCHAR(50) NOT NULL,
-			total_count INT DEFAULT 0,
-			metadata JSONB,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(date, event_type)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_daily_date ON analytics_daily(date)`,
-	}
-
-	for _, query := range queries {
-		if _, err := s.writeClient.ExecuteWriteQuery(query); err != nil {
-			// Ignore "already exists" errors
-			continue
-		}
-	}
-
-	return nil
+	}, nil
 }
 
 // TrackEvent records an analytics event
@@ -232,6 +200,16 @@ func (s *Service) TrackQueryEmbedding(queryType string, model string) error {
 	return s.TrackEvent(EventQueryEmbedding, 1, metadata)
 }
 
+// TrackSearchLatency records how long one chat search took
+// searchType is "product_search" or "email_search", matching TrackQueryEmbedding
+func (s *Service) TrackSearchLatency(searchType string, duration time.Duration) error {
+	metadata := map[string]interface{}{
+		"search_type": searchType,
+		"duration_ms": float64(duration.Microseconds()) / 1000,
+	}
+	return s.TrackEvent(EventSearchLatency, 1, metadata)
+}
+
 // TrackSupportSummarization records GPT calls for support summarization (billable)
 func (s *Service) TrackSupportSummarization(tokens int, model string) error {
 	metadata := map[string]interface{}{
@@ -241,11 +219,55 @@ func (s *Service) TrackSupportSummarization(tokens int, model string) error {
 	return s.TrackEvent(EventSupportSummarization, 1, metadata)
 }
 
+// TrackPromptInjection records a user query flagged as a prompt-injection attempt
+// action is "stripped" when the query was cleaned and answered, or "refused"
+func (s *Service) TrackPromptInjection(action string) error {
+	metadata := map[string]interface{}{
+		"action": action,
+	}
+	return s.TrackEvent(EventPromptInjection, 1, metadata)
+}
+
+// TrackLanguageCorrection records a corrective re-prompt sent because the reply was in the wrong language
+func (s *Service) TrackLanguageCorrection(language string, corrected bool, tokens int) error {
+	metadata := map[string]interface{}{
+		"language":  language,
+		"corrected": corrected,
+		"tokens":    tokens,
+	}
+	return s.TrackEvent(EventLanguageCorrection, 1, metadata)
+}
+
+// TrackConversationSaveFailure records a chat transcript of messageCount messages that failed to save
+func (s *Service) TrackConversationSaveFailure(messageCount int) error {
+	metadata := map[string]interface{}{
+		"messages": messageCount,
+	}
+	return s.TrackEvent(EventConversationSaveFail, 1, metadata)
+}
+
+// TrackSessionTokenCap records a chat request refused because its session reached the token cap
+func (s *Service) TrackSessionTokenCap(sessionTokens int, maxTokens int) error {
+	metadata := map[string]interface{}{
+		"session_tokens": sessionTokens,
+		"max_tokens":     maxTokens,
+	}
+	return s.TrackEvent(EventSessionTokenCap, 1, metadata)
+}
+
+// TrackEmbeddingRegeneration records the start or finish of an admin-triggered embedding run
+// phase is "started" or "finished"; success is only meaningful once finished
+func (s *Service) TrackEmbeddingRegeneration(jobID string, phase string, success bool) error {
+	metadata := map[string]interface{}{
+		"job_id":  jobID,
+		"phase":   phase,
+		"success": success,
+	}
+	return s.TrackEvent(EventEmbeddingRegen, 1, metadata)
+}
+
 // GetSummary retrieves analytics summary for a time period
 func (s *Service) GetSummary(period string) (*models.AnalyticsSummary, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	now := time.Now().UTC()
 	var startDate, endDate time.Time
 
@@ -269,6 +291,36 @@ func (s *Service) GetSummary(period string) (*models.AnalyticsSummary, error) {
 		endDate = now
 	}
 
+	return s.summarize(period, startDate, endDate)
+}
+
+// GetSummaryRange retrieves the analytics summary for whole UTC days from start through end
+// (both inclusive), e.g. a calendar month for accounting
+func (s *Service) GetSummaryRange(start, end time.Time) (*models.AnalyticsSummary, error) {
+	startDate, endDate, err := dayRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return s.summarize(PeriodCustom, startDate, endDate)
+}
+
+// dayRange widens start and end to the first and last instant of their UTC days
+func dayRange(start, end time.Time) (time.Time, time.Time, error) {
+	start = start.UTC()
+	end = end.UTC()
+	startDate := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if startDate.After(endDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %s > %s", ErrInvalidRange, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	}
+	return startDate, endDate, nil
+}
+
+// summarize aggregates analytics_daily and the live table counts between startDate and endDate
+func (s *Service) summarize(period string, startDate, endDate time.Time) (*models.AnalyticsSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
 	summary := &models.AnalyticsSummary{
 		Period:    period,
 		StartDate: startDate,
@@ -345,6 +397,33 @@ func (s *Service) GetSummary(period string) (*models.AnalyticsSummary, error) {
 		summary.OpenAITokensUsed += supportTokens // Add to total tokens
 	}
 
+	// Get search latency percentiles per search type
+	latencyQuery := `
+		SELECT metadata->>'search_type' AS search_type, COUNT(*) AS searches,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY (metadata->>'duration_ms')::float8) AS p50,
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY (metadata->>'duration_ms')::float8) AS p95,
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY (metadata->>'duration_ms')::float8) AS p99
+		FROM analytics_events
+		WHERE event_type = $1 AND created_at >= $2 AND created_at <= $3
+		AND metadata->>'search_type' IS NOT NULL AND metadata->>'duration_ms' IS NOT NULL
+		GROUP BY metadata->>'search_type'
+	`
+	latencyRows, err := s.writeClient.GetDB().QueryContext(ctx, latencyQuery, EventSearchLatency, startDate, endDate)
+	if err == nil {
+		for latencyRows.Next() {
+			var searchType string
+			var latency models.SearchLatencyPercentiles
+			if err := latencyRows.Scan(&searchType, &latency.Count, &latency.P50Ms, &latency.P95Ms, &latency.P99Ms); err != nil {
+				continue
+			}
+			if summary.SearchLatency == nil {
+				summary.SearchLatency = make(map[string]models.SearchLatencyPercentiles)
+			}
+			summary.SearchLatency[searchType] = latency
+		}
+		_ = latencyRows.Close()
+	}
+
 	// Get email and thread counts from actual tables
 	emailCountQuery := `SELECT COUNT(*) FROM emails WHERE created_at >= $1 AND created_at <= $2`
 	err = s.writeClient.GetDB().QueryRowContext(ctx, emailCountQuery, startDate, endDate).Scan(&summary.TotalEmails)
@@ -367,6 +446,46 @@ func (s *Service) GetSummary(period string) (*models.AnalyticsSummary, error) {
 	return summary, nil
 }
 
+// GetEmbeddingFreshness returns row counts and last update times for the embeddings tables,
+// plus when product embedding generation last ran (tracked even when nothing changed)
+func (s *Service) GetEmbeddingFreshness() (*models.EmbeddingFreshnessResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := s.writeClient.GetDB()
+	freshness := &models.EmbeddingFreshnessResponse{}
+
+	tables := []struct {
+		query string
+		dest  *models.TableFreshness
+	}{
+		{`SELECT COUNT(*), MAX(updated_at) FROM product_embeddings`, &freshness.ProductEmbeddings},
+		{`SELECT COUNT(*), MAX(updated_at) FROM email_embeddings`, &freshness.EmailEmbeddings},
+	}
+	for _, table := range tables {
+		var lastUpdated sql.NullTime
+		if err := db.QueryRowContext(ctx, table.query).Scan(&table.dest.RowCount, &lastUpdated); err != nil {
+			return nil, fmt.Errorf("failed to get embedding freshness: %w", err)
+		}
+		if lastUpdated.Valid {
+			table.dest.LastUpdatedAt = &lastUpdated.Time
+		}
+	}
+
+	var lastRun sql.NullTime
+	lastRunQuery := `SELECT MAX(created_at) FROM analytics_events WHERE event_type = $1`
+	if err := db.QueryRowContext(ctx, lastRunQuery, EventProductEmbeddings).Scan(&lastRun); err != nil {
+		return nil, fmt.Errorf("failed to get last embedding generation: %w", err)
+	}
+	if lastRun.Valid {
+		age := int64(time.Since(lastRun.Time).Seconds())
+		freshness.LastGenerationAt = &lastRun.Time
+		freshness.LastGenerationAgeSeconds = &age
+	}
+
+	return freshness, nil
+}
+
 // GetDailyReport generates a report suitable for Slack notifications
 func (s *Service) GetDailyReport() (*models.AnalyticsSummary, error) {
 	// Get yesterday's data (complete day)
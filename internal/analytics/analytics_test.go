@@ -0,0 +1,143 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	return &Service{writeClient: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))}, mock
+}
+
+func TestGetEmbeddingFreshness(t *testing.T) {
+	service, mock := newTestService(t)
+
+	productUpdated := time.Now().Add(-2 * time.Hour)
+	lastRun := time.Now().Add(-30 * time.Minute)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(updated_at\) FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(1500, productUpdated))
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(updated_at\) FROM email_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, nil))
+	mock.ExpectQuery(`SELECT MAX\(created_at\) FROM analytics_events WHERE event_type = \$1`).
+		WithArgs(EventProductEmbeddings).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(lastRun))
+
+	freshness, err := service.GetEmbeddingFreshness()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1500, freshness.ProductEmbeddings.RowCount)
+	require.NotNil(t, freshness.ProductEmbeddings.LastUpdatedAt)
+	assert.WithinDuration(t, productUpdated, *freshness.ProductEmbeddings.LastUpdatedAt, time.Second)
+
+	assert.Equal(t, 0, freshness.EmailEmbeddings.RowCount)
+	assert.Nil(t, freshness.EmailEmbeddings.LastUpdatedAt)
+
+	require.NotNil(t, freshness.LastGenerationAgeSeconds)
+	assert.InDelta(t, 1800, *freshness.LastGenerationAgeSeconds, 5)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEmbeddingFreshness_NeverRan(t *testing.T) {
+	service, mock := newTestService(t)
+
+	mock.ExpectQuery(`FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, nil))
+	mock.ExpectQuery(`FROM email_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, nil))
+	mock.ExpectQuery(`FROM analytics_events`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+
+	freshness, err := service.GetEmbeddingFreshness()
+	require.NoError(t, err)
+	assert.Nil(t, freshness.LastGenerationAt)
+	assert.Nil(t, freshness.LastGenerationAgeSeconds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSummaryRange_ClampsToUTCDays(t *testing.T) {
+	service, mock := newTestService(t)
+
+	// 01:30 on Feb 1 in Israel is still Jan 31 UTC, so the range starts a day early
+	israel := time.FixedZone("IST", 2*60*60)
+	start := time.Date(2026, 2, 1, 1, 30, 0, 0, israel)
+	end := time.Date(2026, 2, 28, 15, 0, 0, 0, time.UTC)
+	startDate := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+
+	mock.ExpectQuery(`FROM analytics_daily\s+WHERE date >= \$1 AND date <= \$2`).
+		WithArgs("2026-01-31", "2026-02-28").
+		WillReturnRows(sqlmock.NewRows([]string{"event_type", "total"}).
+			AddRow(EventConversation, 120).
+			AddRow(EventOpenAICall, 130))
+	mock.ExpectQuery(`FROM analytics_events`).WithArgs(EventOpenAICall, startDate, endDate).
+		WillReturnRows(sqlmock.NewRows([]string{"total_tokens"}).AddRow(5000))
+	mock.ExpectQuery(`FROM analytics_events`).WithArgs(EventSupportSummarization, startDate, endDate).
+		WillReturnRows(sqlmock.NewRows([]string{"total_tokens"}).AddRow(250))
+	mock.ExpectQuery(`percentile_cont\(0.5\) WITHIN GROUP \(ORDER BY \(metadata->>'duration_ms'\)::float8\).*GROUP BY metadata->>'search_type'`).
+		WithArgs(EventSearchLatency, startDate, endDate).
+		WillReturnRows(sqlmock.NewRows([]string{"search_type", "searches", "p50", "p95", "p99"}).
+			AddRow("product_search", 118, 210.5, 640.0, 910.25).
+			AddRow("email_search", 80, 95.0, 180.0, 320.0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM emails WHERE created_at >= \$1 AND created_at <= \$2`).WithArgs(startDate, endDate).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(40))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM email_threads`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM product_embeddings`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1500))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM email_embeddings`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(60))
+
+	summary, err := service.GetSummaryRange(start, end)
+	require.NoError(t, err)
+
+	assert.Equal(t, PeriodCustom, summary.Period)
+	assert.Equal(t, startDate, summary.StartDate)
+	assert.Equal(t, endDate, summary.EndDate)
+	assert.Equal(t, 120, summary.TotalConversations)
+	assert.Equal(t, 130, summary.OpenAICalls)
+	assert.Equal(t, 5250, summary.OpenAITokensUsed)
+	assert.Equal(t, 40, summary.TotalEmails)
+	assert.Equal(t, models.SearchLatencyPercentiles{Count: 118, P50Ms: 210.5, P95Ms: 640, P99Ms: 910.25}, summary.SearchLatency["product_search"])
+	assert.Equal(t, 80, summary.SearchLatency["email_search"].Count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSummaryRange_SingleDayAndInvalidRange(t *testing.T) {
+	service, mock := newTestService(t)
+
+	day := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM analytics_daily`).WithArgs("2026-02-10", "2026-02-10").
+		WillReturnRows(sqlmock.NewRows([]string{"event_type", "total"}))
+	summary, err := service.GetSummaryRange(day, day.Add(10*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, day.Add(24*time.Hour-time.Nanosecond), summary.EndDate)
+	assert.Nil(t, summary.SearchLatency)
+
+	_, err = service.GetSummaryRange(day, day.AddDate(0, 0, -1))
+	require.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestTrackSearchLatency(t *testing.T) {
+	service, mock := newTestService(t)
+
+	mock.ExpectExec(`INSERT INTO analytics_events`).
+		WithArgs(EventSearchLatency, 1, `{"duration_ms":212.345,"search_type":"product_search"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO analytics_daily`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	require.NoError(t, service.TrackSearchLatency("product_search", 212345*time.Microsecond))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
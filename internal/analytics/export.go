@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ids/internal/models"
+)
+
+// ExportedEvent is one analytics_events row with its metadata decoded
+type ExportedEvent struct {
+	EventType string
+	Count     int
+	CreatedAt time.Time
+	Metadata  map[string]interface{} // Top-level metadata keys; numbers are json.Number (nil when the event has none)
+}
+
+// EventMetadataKeys returns the distinct top-level metadata keys of the events between whole UTC days
+// start and end (both inclusive), sorted, so an export can fix its columns before streaming rows
+func (s *Service) EventMetadataKeys(ctx context.Context, start, end time.Time) ([]string, error) {
+	startDate, endDate, err := dayRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT DISTINCT jsonb_object_keys(metadata) AS key
+		FROM analytics_events
+		WHERE created_at >= $1 AND created_at <= $2
+		AND jsonb_typeof(metadata) = 'object'
+		ORDER BY key
+	`
+	var keys []string
+	if err := s.writeClient.GetDB().SelectContext(ctx, &keys, query, startDate, endDate); err != nil {
+		return nil, fmt.Errorf("failed to get analytics metadata keys: %w", err)
+	}
+	return keys, nil
+}
+
+// ExportEvents calls fn for each event between whole UTC days start and end (both inclusive), oldest
+// first. Rows are read from the database one at a time, so a long range isn't held in memory.
+// An error from fn stops the export and is returned.
+func (s *Service) ExportEvents(ctx context.Context, start, end time.Time, fn func(ExportedEvent) error) error {
+	startDate, endDate, err := dayRange(start, end)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT id, event_type, COALESCE(count, 0) AS count, metadata, created_at
+		FROM analytics_events
+		WHERE created_at >= $1 AND created_at <= $2
+		ORDER BY created_at, id
+	`
+	rows, err := s.writeClient.GetDB().QueryxContext(ctx, query, startDate, endDate)
+	if err != nil {
+		return fmt.Errorf("failed to export analytics events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var event models.AnalyticsEvent
+		if err := rows.StructScan(&event); err != nil {
+			return fmt.Errorf("failed to read analytics event: %w", err)
+		}
+
+		exported := ExportedEvent{EventType: event.EventType, Count: event.Count, CreatedAt: event.CreatedAt.UTC()}
+		if event.Metadata != nil {
+			decoder := json.NewDecoder(bytes.NewReader([]byte(*event.Metadata)))
+			decoder.UseNumber()
+			if err := decoder.Decode(&exported.Metadata); err != nil {
+				fmt.Printf("[ANALYTICS] Warning: Skipping unreadable metadata of event %d: %v\n", event.ID, err)
+				exported.Metadata = nil
+			}
+		}
+
+		if err := fn(exported); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to export analytics events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportEvents_DecodesMetadataInOrder(t *testing.T) {
+	service, mock := newTestService(t)
+
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM analytics_events\s+WHERE created_at >= \$1 AND created_at <= \$2\s+ORDER BY created_at, id`).
+		WithArgs(start, end.AddDate(0, 0, 1).Add(-time.Nanosecond)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "count", "metadata", "created_at"}).
+			AddRow(1, EventOpenAICall, 1, `{"tokens": 42, "model": "gpt-4o-mini"}`, created).
+			AddRow(2, EventSupportEscalation, 1, nil, created.Add(time.Minute)).
+			AddRow(3, EventConversation, 1, `not json`, created.Add(2*time.Minute)))
+
+	var events []ExportedEvent
+	err := service.ExportEvents(context.Background(), start, end, func(event ExportedEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, EventOpenAICall, events[0].EventType)
+	assert.Equal(t, json.Number("42"), events[0].Metadata["tokens"])
+	assert.Equal(t, "gpt-4o-mini", events[0].Metadata["model"])
+	assert.Equal(t, created, events[0].CreatedAt)
+	assert.Nil(t, events[1].Metadata)
+	assert.Nil(t, events[2].Metadata)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportEvents_CallbackErrorStops(t *testing.T) {
+	service, mock := newTestService(t)
+
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM analytics_events`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "count", "metadata", "created_at"}).
+			AddRow(1, EventConversation, 1, nil, day).
+			AddRow(2, EventConversation, 1, nil, day))
+
+	calls := 0
+	errDisconnected := errors.New("client disconnected")
+	err := service.ExportEvents(context.Background(), day, day, func(ExportedEvent) error {
+		calls++
+		return errDisconnected
+	})
+	require.ErrorIs(t, err, errDisconnected)
+	assert.Equal(t, 1, calls)
+}
+
+func TestEventMetadataKeys(t *testing.T) {
+	service, mock := newTestService(t)
+
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT DISTINCT jsonb_object_keys\(metadata\) AS key\s+FROM analytics_events`).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("model").AddRow("tokens"))
+
+	keys, err := service.EventMetadataKeys(context.Background(), day, day)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"model", "tokens"}, keys)
+
+	_, err = service.EventMetadataKeys(context.Background(), day, day.AddDate(0, 0, -1))
+	require.ErrorIs(t, err, ErrInvalidRange)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -107,9 +107,7 @@ func Middleware(authManager *Manager) echo.MiddlewareFunc {
 			}
 
 			if token == "" || !authManager.ValidateToken(token) {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Unauthorized. Please login first.",
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized. Please login first.")
 			}
 
 			// Store token in context for handlers to use
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,15 +16,76 @@ type CacheItem struct {
 type Cache struct {
 	items map[string]*CacheItem
 	mutex sync.RWMutex
+
+	embeddingTTL   time.Duration // Maximum age of a cached query embedding (0 disables embedding caching)
+	embeddingModel string        // Last-seen embedding model; a change invalidates cached vectors
+
+	stopSweep chan struct{} // Closed by Close to stop the background sweeper (nil without one)
+	closeOnce sync.Once
 }
 
+// DefaultSweepInterval is how often the server's cache removes expired entries
+const DefaultSweepInterval = time.Minute
+
 // New creates a new cache instance
+// Expired items are only evicted when read; use NewWithSweep for long-lived caches.
 func New() *Cache {
 	return &Cache{
-		items: make(map[string]*CacheItem),
+		items:        make(map[string]*CacheItem),
+		embeddingTTL: EmbeddingCacheTTL,
+	}
+}
+
+// NewWithSweep creates a cache that removes expired items every interval in the background
+// Call Close to stop the sweeper.
+func NewWithSweep(interval time.Duration) *Cache {
+	c := New()
+	c.stopSweep = make(chan struct{})
+	go c.sweep(interval)
+	return c
+}
+
+// sweep deletes expired items every interval until Close is called
+func (c *Cache) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.stopSweep:
+			return
+		}
 	}
 }
 
+// DeleteExpired removes every expired item and returns how many were removed
+func (c *Cache) DeleteExpired() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for key, item := range c.items {
+		if now.After(item.ExpiresAt) {
+			delete(c.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Close stops the background sweeper. It is safe to call more than once, and on caches
+// created with New.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		if c.stopSweep != nil {
+			close(c.stopSweep)
+		}
+	})
+}
+
 // Get retrieves an item from the cache
 func (c *Cache) Get(key string) (interface{}, bool) {
 	c.mutex.RLock()
@@ -73,16 +135,100 @@ func (c *Cache) Clear() {
 	c.items = make(map[string]*CacheItem)
 }
 
+// SessionTokens constants
+const (
+	SessionTokensTTL    = 24 * time.Hour // Token totals expire a day after a session's last reply
+	SessionTokensPrefix = "session_tokens:"
+)
+
+// SessionTokens returns the cumulative OpenAI tokens recorded for a chat session
+func (c *Cache) SessionTokens(sessionID string) int {
+	data, exists := c.Get(SessionTokensPrefix + sessionID)
+	if !exists {
+		return 0
+	}
+
+	tokens, ok := data.(int)
+	if !ok {
+		return 0
+	}
+
+	return tokens
+}
+
+// AddSessionTokens adds tokens to a chat session's running total and returns the new total
+// The read-modify-write holds the lock so concurrent replies in one session are not lost
+func (c *Cache) AddSessionTokens(sessionID string, tokens int) int {
+	key := SessionTokensPrefix + sessionID
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	total := tokens
+	if item, exists := c.items[key]; exists && time.Now().Before(item.ExpiresAt) {
+		if current, ok := item.Data.(int); ok {
+			total += current
+		}
+	}
+
+	c.items[key] = &CacheItem{
+		Data:      total,
+		ExpiresAt: time.Now().Add(SessionTokensTTL),
+	}
+	return total
+}
+
 // EmbeddingCache constants
 const (
-	EmbeddingCacheTTL    = 5 * time.Minute // Cache embeddings for 5 minutes
+	EmbeddingCacheTTL    = 5 * time.Minute // Default maximum age of cached embeddings (EMBEDDING_CACHE_TTL)
 	EmbeddingCachePrefix = "emb:"
 )
 
-// GetEmbedding retrieves a cached embedding for a query
-func (c *Cache) GetEmbedding(query string) ([]float32, bool) {
-	key := EmbeddingCachePrefix + query
-	data, exists := c.Get(key)
+// embeddingKey namespaces a cached query vector by the model that produced it
+func embeddingKey(model, query string) string {
+	return EmbeddingCachePrefix + model + ":" + query
+}
+
+// SetEmbeddingTTL sets the maximum age of cached query embeddings (0 disables embedding caching)
+func (c *Cache) SetEmbeddingTTL(ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.embeddingTTL = max(ttl, 0)
+}
+
+// SetEmbeddingModel records the effective embedding model
+// When it differs from the last-seen model, cached query vectors are dropped and true is returned
+func (c *Cache) SetEmbeddingModel(model string) bool {
+	c.mutex.Lock()
+	previous := c.embeddingModel
+	c.embeddingModel = model
+	c.mutex.Unlock()
+
+	if previous == "" || previous == model {
+		return false
+	}
+	c.InvalidateEmbeddingCache()
+	return true
+}
+
+// InvalidateEmbeddingCache removes every cached query embedding and returns how many were removed
+func (c *Cache) InvalidateEmbeddingCache() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := 0
+	for key := range c.items {
+		if strings.HasPrefix(key, EmbeddingCachePrefix) {
+			delete(c.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// GetEmbedding retrieves a cached embedding for a query embedded with model
+func (c *Cache) GetEmbedding(model, query string) ([]float32, bool) {
+	data, exists := c.Get(embeddingKey(model, query))
 	if !exists {
 		return nil, false
 	}
@@ -95,10 +241,23 @@ func (c *Cache) GetEmbedding(query string) ([]float32, bool) {
 	return embedding, true
 }
 
-// SetEmbedding stores an embedding in the cache
-func (c *Cache) SetEmbedding(query string, embedding []float32) {
-	key := EmbeddingCachePrefix + query
-	c.Set(key, embedding, EmbeddingCacheTTL)
+// SetEmbedding stores an embedding for a query embedded with model
+func (c *Cache) SetEmbedding(model, query string, embedding []float32) {
+	c.mutex.RLock()
+	ttl := c.embeddingTTL
+	c.mutex.RUnlock()
+
+	if ttl <= 0 {
+		return
+	}
+	c.Set(embeddingKey(model, query), embedding, ttl)
+}
+
+// EmbeddingTTL returns the maximum age of cached query embeddings
+func (c *Cache) EmbeddingTTL() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.embeddingTTL
 }
 
 // EmbeddingCacheStats returns statistics about the embedding cache
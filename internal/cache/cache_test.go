@@ -334,6 +334,34 @@ func TestCache_NilValue(t *testing.T) {
 	assert.Nil(t, val)
 }
 
+func TestCache_SessionTokens(t *testing.T) {
+	cache := New()
+
+	assert.Equal(t, 0, cache.SessionTokens("session-1"))
+	assert.Equal(t, 1200, cache.AddSessionTokens("session-1", 1200))
+	assert.Equal(t, 2000, cache.AddSessionTokens("session-1", 800))
+	assert.Equal(t, 2000, cache.SessionTokens("session-1"))
+
+	// Sessions are tracked independently
+	assert.Equal(t, 0, cache.SessionTokens("session-2"))
+}
+
+func TestCache_AddSessionTokensConcurrent(t *testing.T) {
+	cache := New()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.AddSessionTokens("session-1", 10)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 500, cache.SessionTokens("session-1"))
+}
+
 func BenchmarkCache_Set(b *testing.B) {
 	cache := New()
 	b.ResetTimer()
@@ -342,6 +370,70 @@ func BenchmarkCache_Set(b *testing.B) {
 	}
 }
 
+func TestCache_EmbeddingKeyedByModel(t *testing.T) {
+	cache := New()
+	cache.SetEmbedding("text-embedding-3-small", "holster", []float32{0.1, 0.2})
+
+	got, found := cache.GetEmbedding("text-embedding-3-small", "holster")
+	assert.True(t, found)
+	assert.Equal(t, []float32{0.1, 0.2}, got)
+
+	_, found = cache.GetEmbedding("text-embedding-3-large", "holster")
+	assert.False(t, found, "a vector from another model must not be reused")
+}
+
+func TestCache_ModelChangeClearsStaleEmbeddings(t *testing.T) {
+	cache := New()
+	assert.False(t, cache.SetEmbeddingModel("text-embedding-3-small"), "first model seen is not a change")
+	cache.SetEmbedding("text-embedding-3-small", "holster", []float32{0.1, 0.2})
+	cache.Set("other", "value", time.Minute)
+
+	assert.False(t, cache.SetEmbeddingModel("text-embedding-3-small"))
+	_, found := cache.GetEmbedding("text-embedding-3-small", "holster")
+	assert.True(t, found, "same model keeps cached vectors")
+
+	assert.True(t, cache.SetEmbeddingModel("text-embedding-3-large"))
+	_, embeddings := cache.EmbeddingCacheStats()
+	assert.Equal(t, 0, embeddings)
+	_, found = cache.GetEmbedding("text-embedding-3-small", "holster")
+	assert.False(t, found)
+
+	value, found := cache.Get("other")
+	assert.True(t, found, "non-embedding entries survive invalidation")
+	assert.Equal(t, "value", value)
+}
+
+func TestCache_InvalidateEmbeddingCache(t *testing.T) {
+	cache := New()
+	cache.SetEmbedding("m", "a", []float32{1})
+	cache.SetEmbedding("m", "b", []float32{2})
+	cache.Set("session_tokens:s1", 10, time.Minute)
+
+	assert.Equal(t, 2, cache.InvalidateEmbeddingCache())
+	total, embeddings := cache.EmbeddingCacheStats()
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 0, embeddings)
+}
+
+func TestCache_EmbeddingTTL(t *testing.T) {
+	cache := New()
+	assert.Equal(t, EmbeddingCacheTTL, cache.EmbeddingTTL())
+
+	cache.SetEmbeddingTTL(50 * time.Millisecond)
+	cache.SetEmbedding("m", "holster", []float32{1})
+	_, found := cache.GetEmbedding("m", "holster")
+	assert.True(t, found)
+
+	time.Sleep(100 * time.Millisecond)
+	_, found = cache.GetEmbedding("m", "holster")
+	assert.False(t, found, "embeddings older than the TTL are regenerated")
+
+	cache.SetEmbeddingTTL(0)
+	cache.SetEmbedding("m", "holster", []float32{1})
+	_, found = cache.GetEmbedding("m", "holster")
+	assert.False(t, found, "a zero TTL disables embedding caching")
+}
+
 func BenchmarkCache_Get(b *testing.B) {
 	cache := New()
 	cache.Set("key", "value", 10*time.Second)
@@ -366,3 +458,50 @@ func BenchmarkCache_ConcurrentSetGet(b *testing.B) {
 		}
 	})
 }
+
+func TestCache_DeleteExpired(t *testing.T) {
+	cache := New()
+	cache.Set("expired", "value", -time.Second)
+	cache.Set("live", "value", time.Minute)
+
+	assert.Equal(t, 1, cache.DeleteExpired())
+	assert.Len(t, cache.items, 1)
+	_, exists := cache.items["live"]
+	assert.True(t, exists)
+}
+
+func TestNewWithSweep_RemovesExpiredUnreadKeys(t *testing.T) {
+	cache := NewWithSweep(10 * time.Millisecond)
+	defer cache.Close()
+
+	cache.Set("rate:session-1", 1, 5*time.Millisecond)
+	cache.Set("live", "value", time.Minute)
+
+	// The expired key is never read, so only the sweeper can remove it
+	assert.Eventually(t, func() bool {
+		cache.mutex.RLock()
+		defer cache.mutex.RUnlock()
+		_, exists := cache.items["rate:session-1"]
+		return !exists
+	}, time.Second, 5*time.Millisecond)
+
+	val, exists := cache.Get("live")
+	assert.True(t, exists)
+	assert.Equal(t, "value", val)
+}
+
+func TestCache_CloseStopsSweeper(t *testing.T) {
+	cache := NewWithSweep(5 * time.Millisecond)
+	cache.Close()
+	cache.Close() // Safe to call twice
+
+	// Give a running sweeper time to fire; after Close the expired key stays until read
+	cache.Set("expired", "value", -time.Second)
+	time.Sleep(30 * time.Millisecond)
+	cache.mutex.RLock()
+	_, exists := cache.items["expired"]
+	cache.mutex.RUnlock()
+	assert.True(t, exists)
+
+	New().Close() // No sweeper to stop
+}
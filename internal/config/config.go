@@ -10,26 +10,106 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// Product retrieval modes (SEARCH_MODE)
+const (
+	SearchModeVector = "vector" // pgvector cosine similarity only
+	SearchModeHybrid = "hybrid" // pgvector and full-text rank merged with reciprocal rank fusion
+)
+
+// pgvector distance metrics (VECTOR_DISTANCE_METRIC)
+const (
+	DistanceMetricCosine       = "cosine"        // Cosine distance (<=>)
+	DistanceMetricL2           = "l2"            // Euclidean distance (<->)
+	DistanceMetricInnerProduct = "inner_product" // Negative inner product (<#>)
+)
+
+// pgvector index types for product embeddings (VECTOR_INDEX_TYPE)
+const (
+	IndexTypeHNSW    = "hnsw"    // Graph index: best recall and speed, needs the most memory
+	IndexTypeIVFFlat = "ivfflat" // Inverted lists: smaller and faster to build, lower recall
+)
+
+// Product description fields included in embedding text (EMBEDDING_DESCRIPTION_FIELDS)
+const (
+	DescriptionFieldsFull  = "full"  // Full description only
+	DescriptionFieldsShort = "short" // Short description only
+	DescriptionFieldsBoth  = "both"  // Full and short description
+)
+
+// Email context thread rankings (EMAIL_CONTEXT_RANKING)
+const (
+	EmailRankingSimilarity = "similarity" // Most similar threads first
+	EmailRankingRecency    = "recency"    // Most recently active threads (last_date) first
+	EmailRankingHybrid     = "hybrid"     // Similarity and recency weighted equally
+)
+
+// DefaultChatModel is the chat completion model used when CHAT_MODEL is not set
+const DefaultChatModel = "gpt-4o-mini"
+
+// Handling of a product_embeddings table whose vector size differs from the embedding model's (EMBEDDING_DIMENSION_MISMATCH)
+const (
+	DimensionMismatchFail     = "fail"     // Abort table setup
+	DimensionMismatchWarn     = "warn"     // Log a warning and keep the table (inserts fail until it is fixed)
+	DimensionMismatchRecreate = "recreate" // Drop the embeddings, checksums and Qdrant products collection so every product is re-embedded
+)
+
+// Email embedding granularity (EMAIL_EMBEDDING_GRANULARITY)
+const (
+	EmailGranularityIndividual = "individual" // Per-email embeddings only
+	EmailGranularityThread     = "thread"     // Thread embeddings; emails of threads at the size threshold aren't embedded individually
+	EmailGranularityBoth       = "both"       // Per-email and thread embeddings
+)
+
 // Config holds all configuration for the application
 type Config struct {
-	Port                   string
-	DatabaseURL            string // Remote database (via SSH tunnel) - read-only for product data
-	EmbeddingsDatabaseURL  string // Local MariaDB - for storing embeddings and email data
-	Version                string
-	LogLevel               string
-	OpenAIKey              string
-	WaitForTunnel          bool   // Whether to wait for SSH tunnel to be ready
-	OpenAITimeout          int    // OpenAI API timeout in seconds
-	EmbeddingScheduleHours int    // Embedding generation schedule interval in hours
-	EnableEmailContext     bool   // Whether to include email history in chat responses
-	ACSConnectionString    string // Azure Communication Services connection string for sending emails
-	SupportEmail           string // Support email address (default: support@israeldefensestore.com)
+	Port                    string
+	DatabaseURL             string // Remote database (via SSH tunnel) - read-only for product data
+	EmbeddingsDatabaseURL   string // Local MariaDB - for storing embeddings and email data
+	EmbeddingsSchema        string // PostgreSQL schema for embedding/analytics/email tables, isolates tenants (empty = default search_path)
+	DBMaxOpenConns          int    // Maximum open connections per database pool (product DB and embeddings DB)
+	DBMaxIdleConns          int    // Maximum idle connections kept per database pool
+	DBConnMaxLifetime       int    // Seconds a pooled connection is reused before it is closed (0 = forever)
+	Version                 string
+	LogLevel                string
+	OpenAIKey               string
+	WaitForTunnel           bool     // Whether to wait for SSH tunnel to be ready
+	GzipMinLength           int      // Minimum /api response size in bytes before gzip compression is applied
+	MaxRequestBodyBytes     int      // Maximum /api request body size in bytes, after decompression (0 = unlimited)
+	OpenAITimeout           int      // OpenAI API timeout in seconds
+	OpenAIMaxRetries        int      // Retries on OpenAI 429/5xx responses before the error is returned
+	OpenAIRetryDeadline     int      // Total seconds spent retrying one OpenAI request (0 = bounded by the request context)
+	ChatModel               string   // OpenAI chat completion model; also the default Azure GPT deployment name
+	ChatModelOverrides      []string // Models (or Azure deployments) an X-Model header may select per chat request (empty disables overrides)
+	EmbeddingScheduleHours  int      // Embedding generation schedule interval in hours
+	EmbeddingScheduleMin    int      // Minimum allowed schedule interval in hours (EmbeddingScheduleHours is clamped to this)
+	EmbeddingScheduleMax    int      // Maximum allowed schedule interval in hours (EmbeddingScheduleHours is clamped to this)
+	EmbeddingConcurrency    int      // Maximum embedding batches processed concurrently during generation
+	EnableEmailContext      bool     // Whether chat searches imported support emails for context (needs imported email embeddings)
+	EmailSearchLimit        int      // Number of similar email threads retrieved per chat query, before EmailContextThreadLimit applies
+	EmailThreadConcurrency  int      // Maximum concurrent thread-email fetches for the chat context
+	EmailThreadFetchTimeout int      // Per-thread email fetch timeout in seconds
+	EmailServiceRetry       int      // Seconds between attempts to create the chat email service after a failure (0 = every request)
+	EmailContextBodyLength  int      // Maximum characters of each email body shown in the chat context
+	EmailContextThreadLimit int      // Maximum similar email threads rendered in the chat context
+	EmailContextRanking     string   // How the rendered threads are picked from the EmailSearchLimit candidates: similarity, recency or hybrid
+	EnableCustomerHistory   bool     // Whether to add a returning-customer note to the chat context when the conversation contains an email address
+	RebuildThreadAggregates bool     // Recompute thread email counts, dates and participants from the emails table after each email import
+	ACSConnectionString     string   // Azure Communication Services connection string for sending emails
+	SupportEmail            string   // Support email address (default: support@israeldefensestore.com)
+	ShippingConfigFile      string   // Optional JSON file with shipping countries, regions and transit times (empty = bundled default)
+	LanguagesConfigFile     string   // Optional JSON file with the response languages and their prompt instructions (empty = bundled default)
 
 	// Azure OpenAI Configuration (primary provider - falls back to OpenAI if not configured)
 	AzureOpenAIEndpoint            string // Azure OpenAI endpoint (e.g., https://xxx.openai.azure.com/)
 	AzureOpenAIKey                 string // Azure OpenAI API key
-	AzureOpenAIGPTDeployment       string // Deployment name for GPT model (e.g., gpt-4o-mini)
+	AzureOpenAIGPTDeployment       string // Deployment name for GPT model (default: CHAT_MODEL)
 	AzureOpenAIEmbeddingDeployment string // Deployment name for embedding model (e.g., text-embedding-3-small)
+	EmbeddingDimensions            int    // Optional assertion of the embedding model's output size, which is probed at startup (0 = any size)
+	OnDimensionMismatch            string // What CreateEmbeddingsTable does when the stored vector size differs: fail, warn or recreate
+	EmbeddingInputPrefix           string // Instruction prepended to product/email document text before embedding (empty = none)
+	EmbeddingDescriptionFields     string // Product description text embedded: full (description), short (short_description) or both
+	QueryInputPrefix               string // Instruction prepended to search queries before embedding (empty = none)
+	EmbeddingCacheTTL              int    // Seconds a cached query embedding is reused before it is regenerated (0 disables the cache)
 
 	// Analytics Configuration
 	GoogleAnalyticsID string // Google Analytics 4 Measurement ID (e.g., G-XXXXXXXXXX)
@@ -41,6 +121,58 @@ type Config struct {
 	// Qdrant Configuration
 	QdrantURL     string // Qdrant server URL (e.g., ids-qdrant:6334 for gRPC)
 	QdrantEnabled bool   // Feature flag to enable Qdrant for vector search (dual-write always enabled when URL is set)
+
+	// Email Embedding Configuration
+	EmailEmbeddingGranularity string // Which email embeddings are generated: individual, thread or both
+	EmailThreadMinEmails      int    // Minimum emails in a thread before it gets a thread embedding
+
+	// IMAP Import Configuration (cmd/import-imap)
+	IMAPHost      string // IMAP server host; the connection always uses TLS
+	IMAPPort      int    // IMAP server port
+	IMAPUsername  string // Mailbox login
+	IMAPPassword  string // Mailbox password or app password
+	IMAPMailbox   string // Mailbox folder to import
+	IMAPBatchSize int    // Messages fetched and stored per batch; sync progress is saved after each batch
+
+	// Chat Context Configuration
+	CompactContext              bool              // List products in the LLM context without similarity, tags and URLs to save tokens (the frontend still gets product links)
+	ContextSortMode             string            // Product ordering in the LLM context: similarity, price_asc, price_desc, stock_first, newest
+	EnforceResponseLanguage     bool              // Re-prompt once when the reply is not in the customer's language
+	IncludeCategoriesInContext  bool              // List product categories in the LLM context product lines
+	IncludeVariationsInContext  bool              // List product variation options (e.g. sizes) in the LLM context product lines
+	LanguageConfidenceThreshold float64           // Detections below this confidence fall back to English (0 disables)
+	MaxChatRequestsPerMinute    int               // Chat requests allowed per session (or client IP) per minute (0 = unlimited)
+	MaxContextProducts          int               // Maximum number of products listed in the LLM context
+	MaxSessionTokens            int               // Cumulative OpenAI tokens allowed per chat session (0 = unlimited)
+	ShowSKUInResponse           bool              // Include product SKUs in the LLM context and product listings
+	StockStatusMapping          map[string]string // WooCommerce stock_status -> availability (available, backorder, unavailable)
+
+	// Search Configuration
+	RecencyBoostWeight   float64 // Maximum similarity boost for newly published products (0 disables)
+	RecencyWindowDays    int     // Products older than this many days get no recency boost
+	SimilarityWeight     float64 // Weight (alpha) of vector similarity in the final search score
+	KeywordWeight        float64 // Weight (beta) of the keyword/boost score in the final search score
+	TagPhraseBoost       float64 // Keyword score added when a whole tag phrase (e.g. "Right Hand") appears in the query
+	EnableTermBoosting   bool    // Apply keyword/tag boosting on top of vector similarity (false = vector order; recency boost is separate)
+	EnableTokenFiltering bool    // Drop products missing required query tokens (false returns raw similarity results)
+	SearchMinSimilarity  float64 // Minimum similarity for product searches (chat and search endpoint default; 0 keeps all)
+	MinResultsForMatch   int     // Fewer products than this passing the search filters are answered as no match (1 keeps any result)
+	SearchMode           string  // Product retrieval: "vector" (pgvector only) or "hybrid" (pgvector + full-text rank fused with RRF)
+	DistanceMetric       string  // pgvector distance for product and email search: cosine, l2 or inner_product (similarities are normalized to 0-1)
+	HNSWEfSearch         int     // hnsw.ef_search set for each product search (higher = better recall, slower; 0 keeps the server default of 40)
+	IndexType            string  // pgvector index on product_embeddings: hnsw or ivfflat (switching rebuilds it)
+	IVFFlatProbes        int     // ivfflat.probes set for each product search (higher = better recall, slower; 0 keeps the server default of 1)
+	SearchRequireTitle   bool    // Exclude products with an empty post_title from search (false lists them by slug or SKU)
+	SearchInStockOnly    bool    // Default in-stock-only filtering for the product search endpoint
+	SearchDebugEnabled   bool    // Allow debug=true on the product search endpoint to return a relevance trace
+	SynonymsPerToken     int     // Maximum synonyms added per query token (0 = unlimited)
+	SynonymsTotal        int     // Maximum synonyms added per query across all tokens (0 = unlimited)
+	KeywordRulesFile     string  // Optional JSON file mapping title substrings to extra embedding keywords
+
+	// Merchandising Configuration
+	PromotedTags          []string // Tags (e.g. featured, promoted) whose products are kept in results when loosely relevant
+	PromotedMinSimilarity float64  // Loose relevance bar a promoted product's raw similarity must pass
+	PromotedMaxResults    int      // Maximum promoted products guaranteed a place in one result set
 }
 
 // Load initializes and returns application configuration
@@ -51,24 +183,54 @@ func Load() *Config {
 	}
 
 	config := &Config{
-		Port:                   getEnv("PORT", "8080"),
-		DatabaseURL:            os.Getenv("DATABASE_URL"),            // Remote DB via SSH
-		EmbeddingsDatabaseURL:  os.Getenv("EMBEDDINGS_DATABASE_URL"), // Local MariaDB
-		Version:                getEnv("VERSION", "1.0.0"),
-		LogLevel:               getEnv("LOG_LEVEL", "info"),
-		OpenAIKey:              os.Getenv("OPENAI_API_KEY"),
-		WaitForTunnel:          getEnvBool("WAIT_FOR_TUNNEL", true),                       // Default true for production safety
-		OpenAITimeout:          getEnvInt("OPENAI_TIMEOUT", 60),                           // Default 60 seconds
-		EmbeddingScheduleHours: getEnvInt("EMBEDDING_SCHEDULE_INTERVAL_HOURS", 168),       // Default 168 hours (1 week)
-		EnableEmailContext:     getEnvBool("ENABLE_EMAIL_CONTEXT", true),                  // Default true to use email history
-		ACSConnectionString:    os.Getenv("ACS_CONNECTION_STRING"),                        // Azure Communication Services for emails
-		SupportEmail:           getEnv("SUPPORT_EMAIL", "support@israeldefensestore.com"), // Support email address
+		Port:                    getEnv("PORT", "8080"),
+		DatabaseURL:             os.Getenv("DATABASE_URL"),               // Remote DB via SSH
+		EmbeddingsDatabaseURL:   os.Getenv("EMBEDDINGS_DATABASE_URL"),    // Local MariaDB
+		EmbeddingsSchema:        os.Getenv("EMBEDDINGS_SCHEMA"),          // Per-tenant schema in a shared Postgres
+		DBMaxOpenConns:          getEnvInt("DB_MAX_OPEN_CONNS", 10),      // Default 10 connections
+		DBMaxIdleConns:          getEnvInt("DB_MAX_IDLE_CONNS", 5),       // Default 5 idle connections
+		DBConnMaxLifetime:       getEnvInt("DB_CONN_MAX_LIFETIME", 3600), // Default 1 hour
+		Version:                 getEnv("VERSION", "1.0.0"),
+		LogLevel:                getEnv("LOG_LEVEL", "info"),
+		OpenAIKey:               os.Getenv("OPENAI_API_KEY"),
+		WaitForTunnel:           getEnvBool("WAIT_FOR_TUNNEL", true),                       // Default true for production safety
+		GzipMinLength:           getEnvInt("GZIP_MIN_LENGTH", 1024),                        // Default 1 KB
+		MaxRequestBodyBytes:     getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20),                // Default 1 MB
+		OpenAITimeout:           getEnvInt("OPENAI_TIMEOUT", 60),                           // Default 60 seconds
+		OpenAIMaxRetries:        getEnvInt("OPENAI_MAX_RETRIES", 3),                        // Default 3 retries
+		OpenAIRetryDeadline:     getEnvInt("OPENAI_RETRY_DEADLINE", 60),                    // Default 60 seconds
+		ChatModel:               getEnv("CHAT_MODEL", DefaultChatModel),                    // Default gpt-4o-mini; any model name is accepted
+		ChatModelOverrides:      getEnvList("CHAT_MODEL_OVERRIDES", ""),                    // Default empty (X-Model rejected)
+		EmbeddingScheduleHours:  getEnvInt("EMBEDDING_SCHEDULE_INTERVAL_HOURS", 168),       // Default 168 hours (1 week)
+		EmbeddingScheduleMin:    getEnvInt("EMBEDDING_SCHEDULE_MIN_HOURS", 1),              // Default 1 hour
+		EmbeddingScheduleMax:    getEnvInt("EMBEDDING_SCHEDULE_MAX_HOURS", 8760),           // Default 8760 hours (1 year)
+		EmbeddingConcurrency:    getEnvInt("EMBEDDING_CONCURRENCY", 3),                     // Default 3 batches in flight
+		EnableEmailContext:      getEnvBool("ENABLE_EMAIL_CONTEXT", false),                 // Default false until emails are imported
+		EmailSearchLimit:        getEnvInt("EMAIL_SEARCH_LIMIT", 5),                        // Default 5 candidates
+		EmailThreadConcurrency:  getEnvInt("EMAIL_THREAD_FETCH_CONCURRENCY", 3),            // Default 3 (one per context thread)
+		EmailThreadFetchTimeout: getEnvInt("EMAIL_THREAD_FETCH_TIMEOUT", 3),                // Default 3 seconds
+		EmailServiceRetry:       getEnvInt("EMAIL_SERVICE_RETRY_INTERVAL", 60),             // Default 60 seconds
+		EmailContextBodyLength:  getEnvInt("EMAIL_CONTEXT_BODY_LENGTH", 300),               // Default 300 characters
+		EmailContextThreadLimit: getEnvInt("EMAIL_CONTEXT_THREAD_LIMIT", 3),                // Default 3 threads
+		EmailContextRanking:     getEnv("EMAIL_CONTEXT_RANKING", EmailRankingSimilarity),   // Default keeps vector-search ranking
+		EnableCustomerHistory:   getEnvBool("ENABLE_CUSTOMER_HISTORY", false),              // Default false (one participant lookup per chat turn)
+		RebuildThreadAggregates: getEnvBool("REBUILD_THREAD_AGGREGATES", false),            // Default false (use /api/admin/threads/rebuild-aggregates)
+		ACSConnectionString:     os.Getenv("ACS_CONNECTION_STRING"),                        // Azure Communication Services for emails
+		SupportEmail:            getEnv("SUPPORT_EMAIL", "support@israeldefensestore.com"), // Support email address
+		ShippingConfigFile:      getEnv("SHIPPING_CONFIG_FILE", ""),                        // Default empty (bundled shipping data)
+		LanguagesConfigFile:     getEnv("LANGUAGES_CONFIG_FILE", ""),                       // Default empty (bundled languages)
 
 		// Azure OpenAI (primary) - falls back to OpenAI if not configured
 		AzureOpenAIEndpoint:            os.Getenv("AZURE_OPENAI_ENDPOINT"),
 		AzureOpenAIKey:                 os.Getenv("AZURE_OPENAI_KEY"),
-		AzureOpenAIGPTDeployment:       getEnv("AZURE_OPENAI_GPT_DEPLOYMENT", "gpt-4o-mini"),
+		AzureOpenAIGPTDeployment:       os.Getenv("AZURE_OPENAI_GPT_DEPLOYMENT"), // Default: CHAT_MODEL
 		AzureOpenAIEmbeddingDeployment: getEnv("AZURE_OPENAI_EMBEDDING_DEPLOYMENT", "text-embedding-3-small"),
+		EmbeddingDimensions:            getEnvInt("EMBEDDING_DIMENSIONS", 0),
+		OnDimensionMismatch:            getEnv("EMBEDDING_DIMENSION_MISMATCH", DimensionMismatchFail), // Default fail keeps stored embeddings
+		EmbeddingInputPrefix:           os.Getenv("EMBEDDING_INPUT_PREFIX"),
+		EmbeddingDescriptionFields:     getEnv("EMBEDDING_DESCRIPTION_FIELDS", DescriptionFieldsBoth),
+		QueryInputPrefix:               os.Getenv("QUERY_INPUT_PREFIX"),
+		EmbeddingCacheTTL:              getEnvInt("EMBEDDING_CACHE_TTL", 300), // Default 5 minutes
 
 		// Analytics
 		GoogleAnalyticsID: os.Getenv("GOOGLE_ANALYTICS_ID"), // Optional: GA4 Measurement ID
@@ -80,11 +242,251 @@ func Load() *Config {
 		// Qdrant
 		QdrantURL:     getEnv("QDRANT_URL", "ids-qdrant:6334"), // Default to in-cluster service
 		QdrantEnabled: getEnvBool("QDRANT_ENABLED", false),     // Feature flag for Qdrant search reads
+
+		// Email embeddings
+		EmailEmbeddingGranularity: getEnv("EMAIL_EMBEDDING_GRANULARITY", EmailGranularityBoth), // Default both (per-email and thread)
+		EmailThreadMinEmails:      getEnvInt("EMAIL_THREAD_EMBEDDING_MIN_EMAILS", 2),           // Default 2 (threads with a reply)
+
+		// IMAP import
+		IMAPHost:      os.Getenv("IMAP_HOST"),
+		IMAPPort:      getEnvInt("IMAP_PORT", 993), // Default IMAPS port
+		IMAPUsername:  os.Getenv("IMAP_USERNAME"),
+		IMAPPassword:  os.Getenv("IMAP_PASSWORD"),
+		IMAPMailbox:   getEnv("IMAP_MAILBOX", "INBOX"),  // Default inbox
+		IMAPBatchSize: getEnvInt("IMAP_BATCH_SIZE", 50), // Default 50 messages
+
+		// Chat context
+		CompactContext:              getEnvBool("COMPACT_PRODUCT_CONTEXT", false),                                                        // Default verbose product lines
+		ContextSortMode:             getEnv("CONTEXT_SORT_MODE", "similarity"),                                                           // Default keeps vector-search ranking
+		EnforceResponseLanguage:     getEnvBool("ENFORCE_RESPONSE_LANGUAGE", false),                                                      // Opt-in: a retry costs an extra GPT call
+		IncludeCategoriesInContext:  getEnvBool("INCLUDE_CATEGORIES_IN_CONTEXT", false),                                                  // Opt-in: longer product lines
+		IncludeVariationsInContext:  getEnvBool("INCLUDE_VARIATIONS_IN_CONTEXT", false),                                                  // Opt-in: longer product lines
+		LanguageConfidenceThreshold: getEnvFloat("LANGUAGE_CONFIDENCE_THRESHOLD", 0),                                                     // Default 0 trusts every detection
+		MaxChatRequestsPerMinute:    getEnvInt("CHAT_RATE_LIMIT_PER_MINUTE", 20),                                                         // Default 20 requests per minute
+		MaxContextProducts:          getEnvInt("MAX_CONTEXT_PRODUCTS", 15),                                                               // Default 15 products
+		MaxSessionTokens:            getEnvInt("MAX_SESSION_TOKENS", 0),                                                                  // Default 0 disables the cap
+		ShowSKUInResponse:           getEnvBool("SHOW_SKU_IN_RESPONSE", false),                                                           // Default hides SKUs from customers
+		StockStatusMapping:          getEnvMap("STOCK_STATUS_MAPPING", "instock=available,onbackorder=backorder,outofstock=unavailable"), // Backorders shown with an annotation by default
+
+		// Search
+		RecencyBoostWeight:   getEnvFloat("RECENCY_BOOST_WEIGHT", 0),                 // Default disabled
+		RecencyWindowDays:    getEnvInt("RECENCY_WINDOW_DAYS", 90),                   // Default 90 days
+		SimilarityWeight:     getEnvFloat("SEARCH_SIMILARITY_WEIGHT", 1.0),           // Default 1.0 keeps similarity + boost scoring
+		KeywordWeight:        getEnvFloat("SEARCH_KEYWORD_WEIGHT", 1.0),              // Default 1.0 keeps similarity + boost scoring
+		TagPhraseBoost:       getEnvFloat("TAG_PHRASE_BOOST", 0.1),                   // Default 0.1 on top of the capped token boost
+		EnableTermBoosting:   getEnvBool("ENABLE_TERM_BOOSTING", true),               // Default true; disable for A/B tests
+		EnableTokenFiltering: getEnvBool("ENABLE_TOKEN_FILTERING", true),             // Default true; disable for experiments
+		SearchMinSimilarity:  getEnvFloat("SEARCH_MIN_SIMILARITY", 0),                // Default 0 returns every match
+		MinResultsForMatch:   getEnvInt("MIN_RESULTS_FOR_MATCH", 1),                  // Default 1 answers with any result
+		SearchMode:           getEnv("SEARCH_MODE", SearchModeVector),                // Default vector; hybrid adds full-text rank
+		DistanceMetric:       getEnv("VECTOR_DISTANCE_METRIC", DistanceMetricCosine), // Default cosine matches the original HNSW index
+		HNSWEfSearch:         getEnvInt("HNSW_EF_SEARCH", 0),                         // Default 0 keeps the pgvector setting
+		IndexType:            getEnv("VECTOR_INDEX_TYPE", IndexTypeHNSW),             // Default HNSW matches the original index
+		IVFFlatProbes:        getEnvInt("IVFFLAT_PROBES", 0),                         // Default 0 keeps the pgvector setting
+		SearchRequireTitle:   getEnvBool("SEARCH_REQUIRE_TITLE", true),               // Default true hides untitled products
+		SearchInStockOnly:    getEnvBool("SEARCH_IN_STOCK_ONLY", false),              // Default false returns all stock statuses
+		SearchDebugEnabled:   getEnvBool("SEARCH_DEBUG_ENABLED", false),              // Default false keeps search internals private
+		SynonymsPerToken:     getEnvInt("SYNONYMS_PER_TOKEN", 5),                     // Default 5 synonyms per token
+		SynonymsTotal:        getEnvInt("SYNONYMS_TOTAL", 20),                        // Default 20 synonyms per query
+		KeywordRulesFile:     getEnv("PRODUCT_KEYWORD_RULES_FILE", ""),               // Default empty (no keyword rules)
+
+		// Merchandising
+		PromotedTags:          getEnvList("PROMOTED_TAGS", ""),             // Default empty (no promoted products)
+		PromotedMinSimilarity: getEnvFloat("PROMOTED_MIN_SIMILARITY", 0.3), // Default 0.3 keeps promotions out of unrelated queries
+		PromotedMaxResults:    getEnvInt("PROMOTED_MAX_RESULTS", 2),        // Default 2 promoted products per search
 	}
 
+	config.Validate()
+
 	return config
 }
 
+// Validate clamps out-of-range values to safe bounds, logging a warning for each adjustment
+// A zero or negative schedule interval would make time.NewTicker panic
+func (c *Config) Validate() {
+	if c.EmbeddingScheduleMin < 1 {
+		log.Printf("Warning: EMBEDDING_SCHEDULE_MIN_HOURS=%d is invalid, using 1", c.EmbeddingScheduleMin)
+		c.EmbeddingScheduleMin = 1
+	}
+	if c.EmbeddingScheduleMax < c.EmbeddingScheduleMin {
+		log.Printf("Warning: EMBEDDING_SCHEDULE_MAX_HOURS=%d is below the minimum, using %d", c.EmbeddingScheduleMax, c.EmbeddingScheduleMin)
+		c.EmbeddingScheduleMax = c.EmbeddingScheduleMin
+	}
+
+	if c.EmbeddingScheduleHours < c.EmbeddingScheduleMin {
+		log.Printf("Warning: EMBEDDING_SCHEDULE_INTERVAL_HOURS=%d is below the minimum, using %d", c.EmbeddingScheduleHours, c.EmbeddingScheduleMin)
+		c.EmbeddingScheduleHours = c.EmbeddingScheduleMin
+	} else if c.EmbeddingScheduleHours > c.EmbeddingScheduleMax {
+		log.Printf("Warning: EMBEDDING_SCHEDULE_INTERVAL_HOURS=%d is above the maximum, using %d", c.EmbeddingScheduleHours, c.EmbeddingScheduleMax)
+		c.EmbeddingScheduleHours = c.EmbeddingScheduleMax
+	}
+
+	if c.OpenAIMaxRetries < 0 {
+		log.Printf("Warning: OPENAI_MAX_RETRIES=%d is negative, using 0 (no retries)", c.OpenAIMaxRetries)
+		c.OpenAIMaxRetries = 0
+	}
+	if c.OpenAIRetryDeadline < 0 {
+		log.Printf("Warning: OPENAI_RETRY_DEADLINE=%d is negative, using 0 (request context only)", c.OpenAIRetryDeadline)
+		c.OpenAIRetryDeadline = 0
+	}
+
+	c.ChatModel = strings.TrimSpace(c.ChatModel)
+	if c.ChatModel == "" {
+		log.Printf("Warning: CHAT_MODEL is empty, using %s", DefaultChatModel)
+		c.ChatModel = DefaultChatModel
+	}
+	c.AzureOpenAIGPTDeployment = strings.TrimSpace(c.AzureOpenAIGPTDeployment)
+	if c.AzureOpenAIGPTDeployment == "" {
+		c.AzureOpenAIGPTDeployment = c.ChatModel
+	}
+
+	if c.EmbeddingConcurrency < 1 {
+		log.Printf("Warning: EMBEDDING_CONCURRENCY=%d is invalid, using 3", c.EmbeddingConcurrency)
+		c.EmbeddingConcurrency = 3
+	}
+
+	if c.EmbeddingDimensions < 0 {
+		log.Printf("Warning: EMBEDDING_DIMENSIONS=%d is invalid, using the probed size", c.EmbeddingDimensions)
+		c.EmbeddingDimensions = 0
+	}
+	c.OnDimensionMismatch = strings.ToLower(strings.TrimSpace(c.OnDimensionMismatch))
+	if c.OnDimensionMismatch != DimensionMismatchFail && c.OnDimensionMismatch != DimensionMismatchWarn &&
+		c.OnDimensionMismatch != DimensionMismatchRecreate {
+		log.Printf("Warning: EMBEDDING_DIMENSION_MISMATCH=%q is invalid, using %s", c.OnDimensionMismatch, DimensionMismatchFail)
+		c.OnDimensionMismatch = DimensionMismatchFail
+	}
+	if c.EmbeddingCacheTTL < 0 {
+		log.Printf("Warning: EMBEDDING_CACHE_TTL=%d is negative, using 0 (cache disabled)", c.EmbeddingCacheTTL)
+		c.EmbeddingCacheTTL = 0
+	}
+
+	c.EmbeddingDescriptionFields = strings.ToLower(strings.TrimSpace(c.EmbeddingDescriptionFields))
+	if c.EmbeddingDescriptionFields != DescriptionFieldsFull && c.EmbeddingDescriptionFields != DescriptionFieldsShort &&
+		c.EmbeddingDescriptionFields != DescriptionFieldsBoth {
+		log.Printf("Warning: EMBEDDING_DESCRIPTION_FIELDS=%q is invalid, using %s", c.EmbeddingDescriptionFields, DescriptionFieldsBoth)
+		c.EmbeddingDescriptionFields = DescriptionFieldsBoth
+	}
+
+	c.EmailEmbeddingGranularity = strings.ToLower(strings.TrimSpace(c.EmailEmbeddingGranularity))
+	if c.EmailEmbeddingGranularity != EmailGranularityIndividual && c.EmailEmbeddingGranularity != EmailGranularityThread &&
+		c.EmailEmbeddingGranularity != EmailGranularityBoth {
+		log.Printf("Warning: EMAIL_EMBEDDING_GRANULARITY=%q is invalid, using %s", c.EmailEmbeddingGranularity, EmailGranularityBoth)
+		c.EmailEmbeddingGranularity = EmailGranularityBoth
+	}
+	if c.EmailThreadMinEmails < 2 {
+		log.Printf("Warning: EMAIL_THREAD_EMBEDDING_MIN_EMAILS=%d is below 2, using 2", c.EmailThreadMinEmails)
+		c.EmailThreadMinEmails = 2
+	}
+	if c.IMAPPort <= 0 {
+		log.Printf("Warning: IMAP_PORT=%d is invalid, using 993", c.IMAPPort)
+		c.IMAPPort = 993
+	}
+	if c.IMAPBatchSize <= 0 {
+		log.Printf("Warning: IMAP_BATCH_SIZE=%d is invalid, using 50", c.IMAPBatchSize)
+		c.IMAPBatchSize = 50
+	}
+
+	c.SearchMode = strings.ToLower(strings.TrimSpace(c.SearchMode))
+	if c.SearchMode != SearchModeVector && c.SearchMode != SearchModeHybrid {
+		log.Printf("Warning: SEARCH_MODE=%q is invalid, using %s", c.SearchMode, SearchModeVector)
+		c.SearchMode = SearchModeVector
+	}
+
+	c.DistanceMetric = strings.ToLower(strings.TrimSpace(c.DistanceMetric))
+	if c.DistanceMetric != DistanceMetricCosine && c.DistanceMetric != DistanceMetricL2 && c.DistanceMetric != DistanceMetricInnerProduct {
+		log.Printf("Warning: VECTOR_DISTANCE_METRIC=%q is invalid, using %s", c.DistanceMetric, DistanceMetricCosine)
+		c.DistanceMetric = DistanceMetricCosine
+	}
+
+	if c.MaxSessionTokens < 0 {
+		log.Printf("Warning: MAX_SESSION_TOKENS=%d is negative, disabling the session token cap", c.MaxSessionTokens)
+		c.MaxSessionTokens = 0
+	}
+
+	if c.DBMaxOpenConns < 1 {
+		log.Printf("Warning: DB_MAX_OPEN_CONNS=%d is invalid, using 10", c.DBMaxOpenConns)
+		c.DBMaxOpenConns = 10
+	}
+	if c.DBMaxIdleConns < 0 {
+		log.Printf("Warning: DB_MAX_IDLE_CONNS=%d is negative, using 0", c.DBMaxIdleConns)
+		c.DBMaxIdleConns = 0
+	} else if c.DBMaxIdleConns > c.DBMaxOpenConns {
+		log.Printf("Warning: DB_MAX_IDLE_CONNS=%d is above DB_MAX_OPEN_CONNS, using %d", c.DBMaxIdleConns, c.DBMaxOpenConns)
+		c.DBMaxIdleConns = c.DBMaxOpenConns
+	}
+	if c.DBConnMaxLifetime < 0 {
+		log.Printf("Warning: DB_CONN_MAX_LIFETIME=%d is negative, using 0 (connections are reused forever)", c.DBConnMaxLifetime)
+		c.DBConnMaxLifetime = 0
+	}
+
+	if c.MaxRequestBodyBytes < 0 {
+		log.Printf("Warning: MAX_REQUEST_BODY_BYTES=%d is negative, disabling the request body limit", c.MaxRequestBodyBytes)
+		c.MaxRequestBodyBytes = 0
+	}
+
+	if c.MaxChatRequestsPerMinute < 0 {
+		log.Printf("Warning: CHAT_RATE_LIMIT_PER_MINUTE=%d is negative, disabling chat rate limiting", c.MaxChatRequestsPerMinute)
+		c.MaxChatRequestsPerMinute = 0
+	}
+
+	c.IndexType = strings.ToLower(strings.TrimSpace(c.IndexType))
+	if c.IndexType != IndexTypeHNSW && c.IndexType != IndexTypeIVFFlat {
+		log.Printf("Warning: VECTOR_INDEX_TYPE=%q is invalid, using %s", c.IndexType, IndexTypeHNSW)
+		c.IndexType = IndexTypeHNSW
+	}
+
+	// 32768 is pgvector's maximum number of IVFFlat lists
+	if c.IVFFlatProbes < 0 || c.IVFFlatProbes > 32768 {
+		log.Printf("Warning: IVFFLAT_PROBES=%d is outside 0-32768, using the server setting", c.IVFFlatProbes)
+		c.IVFFlatProbes = 0
+	}
+
+	if c.HNSWEfSearch < 0 || c.HNSWEfSearch > 1000 {
+		log.Printf("Warning: HNSW_EF_SEARCH=%d is outside 0-1000, using the server setting", c.HNSWEfSearch)
+		c.HNSWEfSearch = 0
+	}
+
+	if c.MinResultsForMatch < 1 {
+		log.Printf("Warning: MIN_RESULTS_FOR_MATCH=%d is invalid, using 1", c.MinResultsForMatch)
+		c.MinResultsForMatch = 1
+	}
+
+	if c.PromotedMinSimilarity < 0 || c.PromotedMinSimilarity > 1 {
+		log.Printf("Warning: PROMOTED_MIN_SIMILARITY=%g is outside 0-1, using 0.3", c.PromotedMinSimilarity)
+		c.PromotedMinSimilarity = 0.3
+	}
+	if c.PromotedMaxResults < 0 {
+		log.Printf("Warning: PROMOTED_MAX_RESULTS=%d is negative, using 0", c.PromotedMaxResults)
+		c.PromotedMaxResults = 0
+	}
+
+	if c.EmailServiceRetry < 0 {
+		log.Printf("Warning: EMAIL_SERVICE_RETRY_INTERVAL=%d is negative, using 60", c.EmailServiceRetry)
+		c.EmailServiceRetry = 60
+	}
+
+	if c.EmailSearchLimit <= 0 {
+		log.Printf("Warning: EMAIL_SEARCH_LIMIT=%d is invalid, using 5", c.EmailSearchLimit)
+		c.EmailSearchLimit = 5
+	}
+
+	if c.EmailContextThreadLimit <= 0 {
+		log.Printf("Warning: EMAIL_CONTEXT_THREAD_LIMIT=%d is invalid, using 3", c.EmailContextThreadLimit)
+		c.EmailContextThreadLimit = 3
+	}
+
+	c.EmailContextRanking = strings.ToLower(strings.TrimSpace(c.EmailContextRanking))
+	if c.EmailContextRanking != EmailRankingSimilarity && c.EmailContextRanking != EmailRankingRecency && c.EmailContextRanking != EmailRankingHybrid {
+		log.Printf("Warning: EMAIL_CONTEXT_RANKING=%q is invalid, using %s", c.EmailContextRanking, EmailRankingSimilarity)
+		c.EmailContextRanking = EmailRankingSimilarity
+	}
+
+	if c.LanguageConfidenceThreshold < 0 || c.LanguageConfidenceThreshold > 1 {
+		log.Printf("Warning: LANGUAGE_CONFIDENCE_THRESHOLD=%g is outside 0-1, using 0", c.LanguageConfidenceThreshold)
+		c.LanguageConfidenceThreshold = 0
+	}
+}
+
 // getEnv gets an environment variable with a default fallback
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -103,12 +505,51 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvFloat gets an environment variable as float with a default fallback
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvMap gets an environment variable as a comma-separated list of key=value pairs
+// Keys are lowercased; malformed pairs are skipped
+func getEnvMap(key, defaultValue string) map[string]string {
+	value := getEnv(key, defaultValue)
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// getEnvList gets an environment variable as a comma-separated list
+// Items are lowercased and trimmed; empty items are skipped
+func getEnvList(key, defaultValue string) []string {
+	var result []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 // getEnvBool gets an environment variable as boolean with a default fallback
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
 			return boolValue
 		}
+		log.Printf("Warning: %s=%q is not a boolean, using %t", key, value, defaultValue)
 	}
 	return defaultValue
 }
@@ -123,6 +564,11 @@ func (c *Config) HasOpenAIFallback() bool {
 	return c.OpenAIKey != ""
 }
 
+// HasIMAP returns true if an IMAP mailbox is configured for import
+func (c *Config) HasIMAP() bool {
+	return c.IMAPHost != "" && c.IMAPUsername != "" && c.IMAPPassword != ""
+}
+
 // SetupLogger configures zerolog with JSON output and single-line format
 func (c *Config) SetupLogger() zerolog.Logger {
 	// Configure zerolog to output JSON without newlines
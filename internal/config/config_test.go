@@ -303,7 +303,7 @@ func TestLoad_EdgeCaseValues(t *testing.T) {
 
 	cfg := Load()
 	assert.Equal(t, 999999, cfg.OpenAITimeout)
-	assert.Equal(t, 999999, cfg.EmbeddingScheduleHours)
+	assert.Equal(t, 8760, cfg.EmbeddingScheduleHours) // Clamped to the default maximum
 
 	// Test zero values
 	_ = os.Setenv("OPENAI_TIMEOUT", "0")
@@ -311,7 +311,430 @@ func TestLoad_EdgeCaseValues(t *testing.T) {
 
 	cfg = Load()
 	assert.Equal(t, 0, cfg.OpenAITimeout)
-	assert.Equal(t, 0, cfg.EmbeddingScheduleHours)
+	assert.Equal(t, 1, cfg.EmbeddingScheduleHours) // Clamped to the default minimum
+}
+
+func TestLoad_EmbeddingScheduleClamping(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected int
+	}{
+		{name: "zero", env: map[string]string{"EMBEDDING_SCHEDULE_INTERVAL_HOURS": "0"}, expected: 1},
+		{name: "negative", env: map[string]string{"EMBEDDING_SCHEDULE_INTERVAL_HOURS": "-5"}, expected: 1},
+		{name: "within range", env: map[string]string{"EMBEDDING_SCHEDULE_INTERVAL_HOURS": "24"}, expected: 24},
+		{name: "custom minimum", env: map[string]string{"EMBEDDING_SCHEDULE_INTERVAL_HOURS": "2", "EMBEDDING_SCHEDULE_MIN_HOURS": "6"}, expected: 6},
+		{name: "custom maximum", env: map[string]string{"EMBEDDING_SCHEDULE_INTERVAL_HOURS": "500", "EMBEDDING_SCHEDULE_MAX_HOURS": "48"}, expected: 48},
+		{name: "invalid minimum falls back to 1", env: map[string]string{"EMBEDDING_SCHEDULE_INTERVAL_HOURS": "0", "EMBEDDING_SCHEDULE_MIN_HOURS": "-3"}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := Load()
+			assert.Equal(t, tt.expected, cfg.EmbeddingScheduleHours)
+		})
+	}
+}
+
+func TestLoad_LanguageConfidenceThreshold(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 0.0, Load().LanguageConfidenceThreshold)
+
+	t.Setenv("LANGUAGE_CONFIDENCE_THRESHOLD", "0.4")
+	assert.Equal(t, 0.4, Load().LanguageConfidenceThreshold)
+
+	t.Setenv("LANGUAGE_CONFIDENCE_THRESHOLD", "1.5")
+	assert.Equal(t, 0.0, Load().LanguageConfidenceThreshold)
+}
+
+func TestLoad_MaxSessionTokens(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 0, Load().MaxSessionTokens)
+
+	t.Setenv("MAX_SESSION_TOKENS", "20000")
+	assert.Equal(t, 20000, Load().MaxSessionTokens)
+
+	t.Setenv("MAX_SESSION_TOKENS", "-5")
+	assert.Equal(t, 0, Load().MaxSessionTokens)
+}
+
+func TestLoad_MaxChatRequestsPerMinute(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 20, Load().MaxChatRequestsPerMinute)
+
+	t.Setenv("CHAT_RATE_LIMIT_PER_MINUTE", "0")
+	assert.Equal(t, 0, Load().MaxChatRequestsPerMinute)
+
+	t.Setenv("CHAT_RATE_LIMIT_PER_MINUTE", "-3")
+	assert.Equal(t, 0, Load().MaxChatRequestsPerMinute)
+}
+
+func TestLoad_MaxRequestBodyBytes(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 1<<20, Load().MaxRequestBodyBytes)
+
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "4096")
+	assert.Equal(t, 4096, Load().MaxRequestBodyBytes)
+
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "-1")
+	assert.Equal(t, 0, Load().MaxRequestBodyBytes)
+}
+
+func TestLoad_DistanceMetric(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, DistanceMetricCosine, Load().DistanceMetric)
+
+	t.Setenv("VECTOR_DISTANCE_METRIC", " L2 ")
+	assert.Equal(t, DistanceMetricL2, Load().DistanceMetric)
+
+	t.Setenv("VECTOR_DISTANCE_METRIC", "inner_product")
+	assert.Equal(t, DistanceMetricInnerProduct, Load().DistanceMetric)
+
+	t.Setenv("VECTOR_DISTANCE_METRIC", "manhattan")
+	assert.Equal(t, DistanceMetricCosine, Load().DistanceMetric)
+}
+
+func TestLoad_PromotedTags(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Empty(t, cfg.PromotedTags)
+	assert.Equal(t, 0.3, cfg.PromotedMinSimilarity)
+	assert.Equal(t, 2, cfg.PromotedMaxResults)
+
+	t.Setenv("PROMOTED_TAGS", " Featured, ,Promoted ")
+	t.Setenv("PROMOTED_MIN_SIMILARITY", "1.5")
+	t.Setenv("PROMOTED_MAX_RESULTS", "-1")
+	cfg = Load()
+	assert.Equal(t, []string{"featured", "promoted"}, cfg.PromotedTags)
+	assert.Equal(t, 0.3, cfg.PromotedMinSimilarity)
+	assert.Equal(t, 0, cfg.PromotedMaxResults)
+}
+
+func TestLoad_EmbeddingConcurrency(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 3, Load().EmbeddingConcurrency)
+
+	t.Setenv("EMBEDDING_CONCURRENCY", "8")
+	assert.Equal(t, 8, Load().EmbeddingConcurrency)
+
+	t.Setenv("EMBEDDING_CONCURRENCY", "0")
+	assert.Equal(t, 3, Load().EmbeddingConcurrency)
+}
+
+func TestLoad_OpenAIRetries(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Equal(t, 3, cfg.OpenAIMaxRetries)
+	assert.Equal(t, 60, cfg.OpenAIRetryDeadline)
+
+	t.Setenv("OPENAI_MAX_RETRIES", "5")
+	t.Setenv("OPENAI_RETRY_DEADLINE", "120")
+	cfg = Load()
+	assert.Equal(t, 5, cfg.OpenAIMaxRetries)
+	assert.Equal(t, 120, cfg.OpenAIRetryDeadline)
+
+	t.Setenv("OPENAI_MAX_RETRIES", "-1")
+	t.Setenv("OPENAI_RETRY_DEADLINE", "-1")
+	cfg = Load()
+	assert.Equal(t, 0, cfg.OpenAIMaxRetries)
+	assert.Equal(t, 0, cfg.OpenAIRetryDeadline)
+}
+
+func TestLoad_EmbeddingDimensions(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 0, Load().EmbeddingDimensions)
+
+	t.Setenv("EMBEDDING_DIMENSIONS", "3072")
+	assert.Equal(t, 3072, Load().EmbeddingDimensions)
+
+	t.Setenv("EMBEDDING_DIMENSIONS", "-1")
+	assert.Equal(t, 0, Load().EmbeddingDimensions)
+}
+
+func TestLoad_EmbeddingCacheTTL(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 300, Load().EmbeddingCacheTTL)
+
+	t.Setenv("EMBEDDING_CACHE_TTL", "3600")
+	assert.Equal(t, 3600, Load().EmbeddingCacheTTL)
+
+	t.Setenv("EMBEDDING_CACHE_TTL", "-5")
+	assert.Equal(t, 0, Load().EmbeddingCacheTTL)
+}
+
+func TestLoad_RebuildThreadAggregates(t *testing.T) {
+	clearEnv(t)
+	assert.False(t, Load().RebuildThreadAggregates)
+
+	t.Setenv("REBUILD_THREAD_AGGREGATES", "true")
+	assert.True(t, Load().RebuildThreadAggregates)
+}
+
+func TestLoad_EnableEmailContext(t *testing.T) {
+	clearEnv(t)
+	assert.False(t, Load().EnableEmailContext)
+
+	t.Setenv("ENABLE_EMAIL_CONTEXT", "true")
+	assert.True(t, Load().EnableEmailContext)
+
+	// Unparsable values keep the default
+	t.Setenv("ENABLE_EMAIL_CONTEXT", "sometimes")
+	assert.False(t, Load().EnableEmailContext)
+}
+
+func TestLoad_EmailContextLimits(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Equal(t, 5, cfg.EmailSearchLimit)
+	assert.Equal(t, 3, cfg.EmailContextThreadLimit)
+
+	t.Setenv("EMAIL_SEARCH_LIMIT", "10")
+	t.Setenv("EMAIL_CONTEXT_THREAD_LIMIT", "2")
+	cfg = Load()
+	assert.Equal(t, 10, cfg.EmailSearchLimit)
+	assert.Equal(t, 2, cfg.EmailContextThreadLimit)
+
+	t.Setenv("EMAIL_SEARCH_LIMIT", "0")
+	t.Setenv("EMAIL_CONTEXT_THREAD_LIMIT", "-1")
+	cfg = Load()
+	assert.Equal(t, 5, cfg.EmailSearchLimit)
+	assert.Equal(t, 3, cfg.EmailContextThreadLimit)
+}
+
+func TestLoad_EmailServiceRetry(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 60, Load().EmailServiceRetry)
+
+	t.Setenv("EMAIL_SERVICE_RETRY_INTERVAL", "0")
+	assert.Equal(t, 0, Load().EmailServiceRetry)
+
+	t.Setenv("EMAIL_SERVICE_RETRY_INTERVAL", "-10")
+	assert.Equal(t, 60, Load().EmailServiceRetry)
+}
+
+func TestLoad_CompactContext(t *testing.T) {
+	clearEnv(t)
+	assert.False(t, Load().CompactContext)
+
+	t.Setenv("COMPACT_PRODUCT_CONTEXT", "true")
+	assert.True(t, Load().CompactContext)
+}
+
+func TestLoad_IndexType(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Equal(t, IndexTypeHNSW, cfg.IndexType)
+	assert.Zero(t, cfg.IVFFlatProbes)
+
+	t.Setenv("VECTOR_INDEX_TYPE", "IVFFlat")
+	t.Setenv("IVFFLAT_PROBES", "10")
+	cfg = Load()
+	assert.Equal(t, IndexTypeIVFFlat, cfg.IndexType)
+	assert.Equal(t, 10, cfg.IVFFlatProbes)
+
+	t.Setenv("VECTOR_INDEX_TYPE", "diskann")
+	t.Setenv("IVFFLAT_PROBES", "-1")
+	cfg = Load()
+	assert.Equal(t, IndexTypeHNSW, cfg.IndexType)
+	assert.Zero(t, cfg.IVFFlatProbes)
+}
+
+func TestLoad_EmailContextRanking(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, EmailRankingSimilarity, Load().EmailContextRanking)
+
+	t.Setenv("EMAIL_CONTEXT_RANKING", " Recency ")
+	assert.Equal(t, EmailRankingRecency, Load().EmailContextRanking)
+
+	t.Setenv("EMAIL_CONTEXT_RANKING", "hybrid")
+	assert.Equal(t, EmailRankingHybrid, Load().EmailContextRanking)
+
+	t.Setenv("EMAIL_CONTEXT_RANKING", "newest")
+	assert.Equal(t, EmailRankingSimilarity, Load().EmailContextRanking)
+}
+
+func TestLoad_IncludeCategoriesAndVariations(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.False(t, cfg.IncludeCategoriesInContext)
+	assert.False(t, cfg.IncludeVariationsInContext)
+
+	t.Setenv("INCLUDE_CATEGORIES_IN_CONTEXT", "true")
+	t.Setenv("INCLUDE_VARIATIONS_IN_CONTEXT", "true")
+	cfg = Load()
+	assert.True(t, cfg.IncludeCategoriesInContext)
+	assert.True(t, cfg.IncludeVariationsInContext)
+}
+
+func TestLoad_IMAP(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.False(t, cfg.HasIMAP())
+	assert.Equal(t, 993, cfg.IMAPPort)
+	assert.Equal(t, "INBOX", cfg.IMAPMailbox)
+	assert.Equal(t, 50, cfg.IMAPBatchSize)
+
+	t.Setenv("IMAP_HOST", "imap.example.com")
+	t.Setenv("IMAP_USERNAME", "support@example.com")
+	t.Setenv("IMAP_PASSWORD", "secret")
+	t.Setenv("IMAP_PORT", "0")
+	t.Setenv("IMAP_BATCH_SIZE", "-5")
+	cfg = Load()
+	assert.True(t, cfg.HasIMAP())
+	assert.Equal(t, 993, cfg.IMAPPort)
+	assert.Equal(t, 50, cfg.IMAPBatchSize)
+}
+
+func TestLoad_ChatModel(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Equal(t, DefaultChatModel, cfg.ChatModel)
+	assert.Equal(t, DefaultChatModel, cfg.AzureOpenAIGPTDeployment)
+
+	// Azure deployments default to the chat model unless named explicitly
+	t.Setenv("CHAT_MODEL", " gpt-4.1-mini ")
+	cfg = Load()
+	assert.Equal(t, "gpt-4.1-mini", cfg.ChatModel)
+	assert.Equal(t, "gpt-4.1-mini", cfg.AzureOpenAIGPTDeployment)
+
+	t.Setenv("AZURE_OPENAI_GPT_DEPLOYMENT", "ids-chat")
+	assert.Equal(t, "ids-chat", Load().AzureOpenAIGPTDeployment)
+
+	t.Setenv("CHAT_MODEL", "   ")
+	assert.Equal(t, DefaultChatModel, Load().ChatModel)
+}
+
+func TestLoad_ChatModelOverrides(t *testing.T) {
+	clearEnv(t)
+	assert.Empty(t, Load().ChatModelOverrides)
+
+	t.Setenv("CHAT_MODEL_OVERRIDES", "gpt-4.1-mini, GPT-4o,")
+	assert.Equal(t, []string{"gpt-4.1-mini", "gpt-4o"}, Load().ChatModelOverrides)
+}
+
+func TestLoad_OnDimensionMismatch(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, DimensionMismatchFail, Load().OnDimensionMismatch)
+
+	t.Setenv("EMBEDDING_DIMENSION_MISMATCH", " Recreate ")
+	assert.Equal(t, DimensionMismatchRecreate, Load().OnDimensionMismatch)
+
+	t.Setenv("EMBEDDING_DIMENSION_MISMATCH", "ignore")
+	assert.Equal(t, DimensionMismatchFail, Load().OnDimensionMismatch)
+}
+
+func TestLoad_DBPool(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Equal(t, 10, cfg.DBMaxOpenConns)
+	assert.Equal(t, 5, cfg.DBMaxIdleConns)
+	assert.Equal(t, 3600, cfg.DBConnMaxLifetime)
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "25")
+	t.Setenv("DB_MAX_IDLE_CONNS", "8")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "300")
+	cfg = Load()
+	assert.Equal(t, 25, cfg.DBMaxOpenConns)
+	assert.Equal(t, 8, cfg.DBMaxIdleConns)
+	assert.Equal(t, 300, cfg.DBConnMaxLifetime)
+
+	// Idle connections can't exceed the open limit
+	t.Setenv("DB_MAX_OPEN_CONNS", "4")
+	assert.Equal(t, 4, Load().DBMaxIdleConns)
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "0")
+	t.Setenv("DB_MAX_IDLE_CONNS", "-1")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "-60")
+	cfg = Load()
+	assert.Equal(t, 10, cfg.DBMaxOpenConns)
+	assert.Equal(t, 0, cfg.DBMaxIdleConns)
+	assert.Equal(t, 0, cfg.DBConnMaxLifetime)
+}
+
+func TestLoad_MinResultsForMatch(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 1, Load().MinResultsForMatch)
+
+	t.Setenv("MIN_RESULTS_FOR_MATCH", "3")
+	assert.Equal(t, 3, Load().MinResultsForMatch)
+
+	t.Setenv("MIN_RESULTS_FOR_MATCH", "0")
+	assert.Equal(t, 1, Load().MinResultsForMatch)
+}
+
+func TestLoad_HNSWEfSearch(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, 0, Load().HNSWEfSearch)
+
+	t.Setenv("HNSW_EF_SEARCH", "200")
+	assert.Equal(t, 200, Load().HNSWEfSearch)
+
+	t.Setenv("HNSW_EF_SEARCH", "5000")
+	assert.Equal(t, 0, Load().HNSWEfSearch)
+}
+
+func TestLoad_EmbeddingDescriptionFields(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, DescriptionFieldsBoth, Load().EmbeddingDescriptionFields)
+
+	t.Setenv("EMBEDDING_DESCRIPTION_FIELDS", " Short ")
+	assert.Equal(t, DescriptionFieldsShort, Load().EmbeddingDescriptionFields)
+
+	t.Setenv("EMBEDDING_DESCRIPTION_FIELDS", "excerpt")
+	assert.Equal(t, DescriptionFieldsBoth, Load().EmbeddingDescriptionFields)
+}
+
+func TestLoad_EmailEmbeddingGranularity(t *testing.T) {
+	clearEnv(t)
+	cfg := Load()
+	assert.Equal(t, EmailGranularityBoth, cfg.EmailEmbeddingGranularity)
+	assert.Equal(t, 2, cfg.EmailThreadMinEmails)
+
+	t.Setenv("EMAIL_EMBEDDING_GRANULARITY", "Thread")
+	t.Setenv("EMAIL_THREAD_EMBEDDING_MIN_EMAILS", "3")
+	cfg = Load()
+	assert.Equal(t, EmailGranularityThread, cfg.EmailEmbeddingGranularity)
+	assert.Equal(t, 3, cfg.EmailThreadMinEmails)
+
+	t.Setenv("EMAIL_EMBEDDING_GRANULARITY", "per-email")
+	t.Setenv("EMAIL_THREAD_EMBEDDING_MIN_EMAILS", "1")
+	cfg = Load()
+	assert.Equal(t, EmailGranularityBoth, cfg.EmailEmbeddingGranularity)
+	assert.Equal(t, 2, cfg.EmailThreadMinEmails)
+}
+
+func TestLoad_SearchMode(t *testing.T) {
+	clearEnv(t)
+	assert.Equal(t, SearchModeVector, Load().SearchMode)
+
+	t.Setenv("SEARCH_MODE", " Hybrid ")
+	assert.Equal(t, SearchModeHybrid, Load().SearchMode)
+
+	t.Setenv("SEARCH_MODE", "keyword")
+	assert.Equal(t, SearchModeVector, Load().SearchMode)
+}
+
+func TestLoad_StockStatusMapping(t *testing.T) {
+	clearEnv(t)
+
+	cfg := Load()
+	assert.Equal(t, map[string]string{
+		"instock":     "available",
+		"onbackorder": "backorder",
+		"outofstock":  "unavailable",
+	}, cfg.StockStatusMapping)
+
+	t.Setenv("STOCK_STATUS_MAPPING", " InStock = available, onbackorder=unavailable, broken, =x ")
+	cfg = Load()
+	assert.Equal(t, map[string]string{
+		"instock":     "available",
+		"onbackorder": "unavailable",
+	}, cfg.StockStatusMapping)
 }
 
 func TestLoad_SpecialCharacters(t *testing.T) {
@@ -359,6 +782,56 @@ func clearEnv(t *testing.T) {
 		"WAIT_FOR_TUNNEL",
 		"OPENAI_TIMEOUT",
 		"EMBEDDING_SCHEDULE_INTERVAL_HOURS",
+		"EMBEDDING_SCHEDULE_MIN_HOURS",
+		"EMBEDDING_SCHEDULE_MAX_HOURS",
+		"STOCK_STATUS_MAPPING",
+		"EMBEDDING_DIMENSIONS",
+		"LANGUAGE_CONFIDENCE_THRESHOLD",
+		"SEARCH_MODE",
+		"MAX_SESSION_TOKENS",
+		"EMBEDDING_CONCURRENCY",
+		"OPENAI_MAX_RETRIES",
+		"OPENAI_RETRY_DEADLINE",
+		"EMBEDDING_CACHE_TTL",
+		"REBUILD_THREAD_AGGREGATES",
+		"EMBEDDING_DESCRIPTION_FIELDS",
+		"SHIPPING_CONFIG_FILE",
+		"EMAIL_EMBEDDING_GRANULARITY",
+		"EMAIL_THREAD_EMBEDDING_MIN_EMAILS",
+		"CHAT_RATE_LIMIT_PER_MINUTE",
+		"MAX_REQUEST_BODY_BYTES",
+		"VECTOR_DISTANCE_METRIC",
+		"PROMOTED_TAGS",
+		"PROMOTED_MIN_SIMILARITY",
+		"PROMOTED_MAX_RESULTS",
+		"SEARCH_DEBUG_ENABLED",
+		"ENABLE_CUSTOMER_HISTORY",
+		"ENABLE_EMAIL_CONTEXT",
+		"EMAIL_SEARCH_LIMIT",
+		"EMAIL_CONTEXT_THREAD_LIMIT",
+		"EMAIL_SERVICE_RETRY_INTERVAL",
+		"COMPACT_PRODUCT_CONTEXT",
+		"IMAP_HOST",
+		"IMAP_PORT",
+		"IMAP_USERNAME",
+		"IMAP_PASSWORD",
+		"IMAP_MAILBOX",
+		"IMAP_BATCH_SIZE",
+		"CHAT_MODEL",
+		"CHAT_MODEL_OVERRIDES",
+		"EMBEDDING_DIMENSION_MISMATCH",
+		"AZURE_OPENAI_GPT_DEPLOYMENT",
+		"DB_MAX_OPEN_CONNS",
+		"DB_MAX_IDLE_CONNS",
+		"DB_CONN_MAX_LIFETIME",
+		"LANGUAGES_CONFIG_FILE",
+		"MIN_RESULTS_FOR_MATCH",
+		"HNSW_EF_SEARCH",
+		"INCLUDE_CATEGORIES_IN_CONTEXT",
+		"INCLUDE_VARIATIONS_IN_CONTEXT",
+		"EMAIL_CONTEXT_RANKING",
+		"VECTOR_INDEX_TYPE",
+		"IVFFLAT_PROBES",
 	}
 
 	for _, v := range vars {
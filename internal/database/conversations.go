@@ -1,12 +1,30 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"ids/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	// saveConversationAttempts bounds retries of SaveConversation on transient errors
+	saveConversationAttempts = 3
 )
 
+// ErrSessionNotFound is returned when no chat session has the requested session ID
+var ErrSessionNotFound = errors.New("session not found")
+
+// saveConversationBackoff is the delay before each retry (multiplied by the attempt number)
+var saveConversationBackoff = 200 * time.Millisecond
+
 // ConversationService handles conversation session storage
 type ConversationService struct {
 	writeClient *WriteClient
@@ -18,61 +36,10 @@ func NewConversationService(writeClient *WriteClient) (*ConversationService, err
 		return nil, fmt.Errorf("write client is required for conversation service")
 	}
 
-	service := &ConversationService{
+	// The conversation tables are created by the schema migrations (SchemaMigrations)
+	return &ConversationService{
 		writeClient: writeClient,
-	}
-
-	// Create tables if they don't exist
-	if err := service.CreateTables(); err != nil {
-		return nil, fmt.Errorf("failed to create conversation tables: %w", err)
-	}
-
-	return service, nil
-}
-
-// CreateTables creates the conversation tables in the database
-func (s *ConversationService) CreateTables() error {
-	queries := []string{
-		// Chat sessions table
-		`CREATE TABLE IF NOT EXISTS chat_sessions (
-			id SERIAL PRIMARY KEY,
-			session_id VARCHAR(36) UNIQUE NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			email_sent BOOLEAN DEFAULT FALSE,
-			email_html TEXT
-		)`,
-		// Create indexes
-		`CREATE INDEX IF NOT EXISTS idx_chat_sessions_session_id ON chat_sessions(session_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_chat_sessions_created_at ON chat_sessions(created_at DESC)`,
-		// Session messages table
-		`CREATE TABLE IF NOT EXISTS session_messages (
-			id SERIAL PRIMARY KEY,
-			session_id VARCHAR(36) NOT NULL,
-			role VARCHAR(20) NOT NULL,
-			message TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (session_id) REFERENCES chat_sessions(session_id) ON DELETE CASCADE
-		)`,
-		// Create indexes
-		`CREATE INDEX IF NOT EXISTS idx_session_messages_session_id ON session_messages(session_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_session_messages_created_at ON session_messages(created_at)`,
-	}
-
-	for _, query := range queries {
-		_, err := s.writeClient.ExecuteWriteQuery(query)
-		if err != nil {
-			// Check if it's a "already exists" error (PostgreSQL error code 42P07)
-			if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "42P07") {
-				// Table/index already exists, that's fine
-				continue
-			}
-			// Log other errors but don't fail - tables might already exist
-			fmt.Printf("[CONVERSATIONS] Warning: Error creating table/index: %v\n", err)
-		}
-	}
-
-	return nil
+	}, nil
 }
 
 // SaveSession creates or updates a session
@@ -108,6 +75,84 @@ func (s *ConversationService) SaveMessage(sessionID string, role, message string
 	return nil
 }
 
+// SaveConversation persists the session and all messages in a single transaction
+// Either every message is stored or none are; transient errors are retried a bounded number of times
+// messages is the full conversation so far - messages already saved for the session are skipped.
+// Canceling ctx rolls back the attempt in progress and stops further retries.
+func (s *ConversationService) SaveConversation(ctx context.Context, sessionID string, messages []models.ConversationMessage) error {
+	var err error
+	for attempt := 1; attempt <= saveConversationAttempts; attempt++ {
+		err = s.writeClient.WithTransactionContext(ctx, func(tx *sqlx.Tx) error {
+			return saveConversationTx(ctx, tx, sessionID, messages)
+		})
+		if err == nil || !isTransientError(err) {
+			break
+		}
+		if attempt < saveConversationAttempts {
+			fmt.Printf("[CONVERSATIONS] Transient error saving conversation (attempt %d/%d): %v\n", attempt, saveConversationAttempts, err)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("failed to save conversation: %w", errors.Join(err, ctx.Err()))
+			case <-time.After(saveConversationBackoff * time.Duration(attempt)):
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return nil
+}
+
+// saveConversationTx writes the session and its messages using the given transaction
+func saveConversationTx(ctx context.Context, tx *sqlx.Tx, sessionID string, messages []models.ConversationMessage) error {
+	sessionQuery := `
+		INSERT INTO chat_sessions (session_id, created_at, updated_at)
+		VALUES ($1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (session_id) DO UPDATE SET
+			updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := tx.ExecContext(ctx, sessionQuery, sessionID); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	// The session upsert locks its row, so concurrent saves for the same session see a stable position
+	// Sessions saved before positions existed (until migration 6 numbers them) count their rows instead
+	var lastPosition int
+	positionQuery := `SELECT COALESCE(MAX(position), COUNT(*) - 1) FROM session_messages WHERE session_id = $1`
+	if err := tx.GetContext(ctx, &lastPosition, positionQuery, sessionID); err != nil {
+		return fmt.Errorf("failed to get saved message position: %w", err)
+	}
+
+	messageQuery := `
+		INSERT INTO session_messages (session_id, role, message, position, created_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (session_id, position) DO NOTHING
+	`
+	for position := lastPosition + 1; position < len(messages); position++ {
+		msg := messages[position]
+		if _, err := tx.ExecContext(ctx, messageQuery, sessionID, msg.Role, msg.Message, position); err != nil {
+			return fmt.Errorf("failed to save message: %w", err)
+		}
+	}
+	return nil
+}
+
+// isTransientError reports whether a database error is worth retrying
+// (serialization failures, deadlocks and dropped connections)
+func isTransientError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "40", "08": // transaction rollback, connection exception
+			return true
+		}
+	}
+	return false
+}
+
 // UpdateSessionEmail updates a session with email information
 func (s *ConversationService) UpdateSessionEmail(sessionID string, emailHTML string) error {
 	query := `
@@ -153,6 +198,31 @@ func (s *ConversationService) GetSessions(limit, offset int) ([]models.ChatSessi
 	return sessions, nil
 }
 
+// ListSessions retrieves one page of sessions, newest first, with the pagination metadata
+func (s *ConversationService) ListSessions(limit, offset int) ([]models.ChatSession, PageInfo, error) {
+	query := `
+		SELECT
+			cs.id,
+			cs.session_id,
+			cs.created_at,
+			cs.updated_at,
+			cs.email_sent,
+			COUNT(sm.id) as message_count
+		FROM chat_sessions cs
+		LEFT JOIN session_messages sm ON cs.session_id = sm.session_id
+		GROUP BY cs.id, cs.session_id, cs.created_at, cs.updated_at, cs.email_sent
+		ORDER BY cs.created_at DESC, cs.id DESC
+	`
+	countQuery := `SELECT COUNT(*) FROM chat_sessions`
+
+	sessions := []models.ChatSession{}
+	page, err := s.writeClient.QueryPage(&sessions, query, countQuery, limit, offset)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, page, nil
+}
+
 // GetSessionCount returns the total number of sessions
 func (s *ConversationService) GetSessionCount() (int, error) {
 	var count int
@@ -180,17 +250,20 @@ func (s *ConversationService) GetSessionDetails(sessionID string) (*models.ChatS
 		WHERE session_id = $1
 	`
 	err := s.writeClient.ExecuteWriteQuerySingle(&session, query, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
 	// Get messages
-	var messages []models.SessionMessage
+	messages := []models.SessionMessage{}
 	msgQuery := `
 		SELECT id, session_id, role, message, created_at
 		FROM session_messages
 		WHERE session_id = $1
-		ORDER BY created_at ASC
+		ORDER BY position NULLS LAST, created_at, id
 	`
 	err = s.writeClient.ExecuteWriteQueryWithResult(&messages, msgQuery, sessionID)
 	if err != nil {
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConversationService(t *testing.T) (*ConversationService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	originalBackoff := saveConversationBackoff
+	saveConversationBackoff = 0
+	t.Cleanup(func() { saveConversationBackoff = originalBackoff })
+	return &ConversationService{writeClient: NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))}, mock
+}
+
+// expectLastPosition expects the lookup of the highest saved message position for a session
+func expectLastPosition(mock sqlmock.Sqlmock, sessionID string, position int) {
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(position\), COUNT\(\*\) - 1\) FROM session_messages`).
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(position))
+}
+
+var testTranscript = []models.ConversationMessage{
+	{Role: "user", Message: "Do you have Glock holsters?"},
+	{Role: "assistant", Message: "Yes, we have several."},
+	{Role: "user", Message: "Show me the black one"},
+}
+
+func TestSaveConversation_CommitsAllMessages(t *testing.T) {
+	service, mock := newTestConversationService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLastPosition(mock, "session-1", -1)
+	for i, msg := range testTranscript {
+		mock.ExpectExec(`INSERT INTO session_messages`).
+			WithArgs("session-1", msg.Role, msg.Message, i).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	require.NoError(t, service.SaveConversation(context.Background(), "session-1", testTranscript))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveConversation_RollsBackOnMidWriteFailure(t *testing.T) {
+	service, mock := newTestConversationService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLastPosition(mock, "session-1", -1)
+	mock.ExpectExec(`INSERT INTO session_messages`).
+		WithArgs("session-1", "user", "Do you have Glock holsters?", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO session_messages`).
+		WithArgs("session-1", "assistant", "Yes, we have several.", 1).
+		WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	err := service.SaveConversation(context.Background(), "session-1", testTranscript)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+	// No commit and no retry: the first message is rolled back with the rest
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveConversation_RetriesTransientErrors(t *testing.T) {
+	service, mock := newTestConversationService(t)
+	messages := testTranscript[:1]
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").
+		WillReturnError(&pq.Error{Code: "40001", Message: "could not serialize access"})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLastPosition(mock, "session-1", -1)
+	mock.ExpectExec(`INSERT INTO session_messages`).
+		WithArgs("session-1", messages[0].Role, messages[0].Message, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, service.SaveConversation(context.Background(), "session-1", messages))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveConversation_StopsRetryingWhenCanceled(t *testing.T) {
+	service, mock := newTestConversationService(t)
+	saveConversationBackoff = time.Hour
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").
+		WillReturnError(&pq.Error{Code: "40001", Message: "could not serialize access"})
+	mock.ExpectRollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := service.SaveConversation(ctx, "session-1", testTranscript)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// The backoff is cut short and no second attempt starts
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveConversation_SkipsAlreadySavedMessages(t *testing.T) {
+	service, mock := newTestConversationService(t)
+
+	// First request: user question plus AI response
+	firstTurn := []models.ConversationMessage{
+		{Role: "user", Message: "Do you have Glock holsters?"},
+		{Role: "assistant", Message: "Yes, we have several."},
+	}
+	// Second request: the frontend resends the history with the next question, plus the new AI response
+	secondTurn := append(append([]models.ConversationMessage{}, firstTurn...),
+		models.ConversationMessage{Role: "user", Message: "Show me the black one"},
+		models.ConversationMessage{Role: "assistant", Message: "Here is the black holster."},
+	)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLastPosition(mock, "session-1", -1)
+	for i, msg := range firstTurn {
+		mock.ExpectExec(`INSERT INTO session_messages`).
+			WithArgs("session-1", msg.Role, msg.Message, i).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLastPosition(mock, "session-1", len(firstTurn)-1)
+	// Only the two new messages are inserted - the overlapping history is not written again
+	for i := len(firstTurn); i < len(secondTurn); i++ {
+		mock.ExpectExec(`INSERT INTO session_messages`).
+			WithArgs("session-1", secondTurn[i].Role, secondTurn[i].Message, i).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	require.NoError(t, service.SaveConversation(context.Background(), "session-1", firstTurn))
+	require.NoError(t, service.SaveConversation(context.Background(), "session-1", secondTurn))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveConversation_LegacySessionWithoutPositions(t *testing.T) {
+	service, mock := newTestConversationService(t)
+
+	// Two messages were saved before positions existed; MAX(position) is NULL, so their count is used
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO chat_sessions`).WithArgs("legacy-session").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLastPosition(mock, "legacy-session", 1)
+	mock.ExpectExec(`INSERT INTO session_messages`).
+		WithArgs("legacy-session", testTranscript[2].Role, testTranscript[2].Message, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, service.SaveConversation(context.Background(), "legacy-session", testTranscript))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, isTransientError(&pq.Error{Code: "40P01"}))
+	assert.True(t, isTransientError(&pq.Error{Code: "08006"}))
+	assert.False(t, isTransientError(&pq.Error{Code: "23505"}))
+	assert.False(t, isTransientError(errors.New("syntax error")))
+}
+
+var sessionColumns = []string{"id", "session_id", "created_at", "updated_at", "email_sent", "message_count"}
+
+func TestGetSessionDetails_ReturnsOrderedMessages(t *testing.T) {
+	service, mock := newTestConversationService(t)
+	createdAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM chat_sessions\s+WHERE session_id = \$1`).
+		WithArgs("session-1").
+		WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(1, "session-1", createdAt, createdAt, true, 2))
+	mock.ExpectQuery(`FROM session_messages\s+WHERE session_id = \$1\s+ORDER BY position NULLS LAST, created_at, id`).
+		WithArgs("session-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "role", "message", "created_at"}).
+			AddRow(10, "session-1", "user", testTranscript[0].Message, createdAt).
+			AddRow(11, "session-1", "assistant", testTranscript[1].Message, createdAt))
+	mock.ExpectQuery(`SELECT email_html FROM chat_sessions`).
+		WithArgs("session-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email_html"}).AddRow("<p>support</p>"))
+
+	detail, err := service.GetSessionDetails("session-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, detail.Session.MessageCount)
+	assert.True(t, detail.Session.EmailSent)
+	require.Len(t, detail.Messages, 2)
+	assert.Equal(t, "user", detail.Messages[0].Role)
+	assert.Equal(t, "assistant", detail.Messages[1].Role)
+	require.NotNil(t, detail.EmailHTML)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSessionDetails_NotFound(t *testing.T) {
+	service, mock := newTestConversationService(t)
+
+	mock.ExpectQuery(`FROM chat_sessions\s+WHERE session_id = \$1`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(sessionColumns))
+
+	_, err := service.GetSessionDetails("missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSessionDetails_EmptySession(t *testing.T) {
+	service, mock := newTestConversationService(t)
+	createdAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM chat_sessions\s+WHERE session_id = \$1`).
+		WithArgs("session-1").
+		WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(1, "session-1", createdAt, createdAt, false, 0))
+	mock.ExpectQuery(`FROM session_messages`).
+		WithArgs("session-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "role", "message", "created_at"}))
+
+	detail, err := service.GetSessionDetails("session-1")
+	require.NoError(t, err)
+
+	assert.NotNil(t, detail.Messages)
+	assert.Empty(t, detail.Messages)
+	assert.Nil(t, detail.EmailHTML)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListSessions(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("page with message counts", func(t *testing.T) {
+		service, mock := newTestConversationService(t)
+
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM chat_sessions`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(`COUNT\(sm.id\) as message_count.*ORDER BY cs.created_at DESC, cs.id DESC LIMIT \$1 OFFSET \$2`).
+			WithArgs(2, 0).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).
+				AddRow(3, "session-3", createdAt, createdAt, true, 4).
+				AddRow(2, "session-2", createdAt, createdAt, false, 0))
+
+		sessions, page, err := service.ListSessions(2, 0)
+		require.NoError(t, err)
+
+		require.Len(t, sessions, 2)
+		assert.Equal(t, 4, sessions[0].MessageCount)
+		assert.True(t, sessions[0].EmailSent)
+		assert.Equal(t, 0, sessions[1].MessageCount)
+		assert.Equal(t, PageInfo{Total: 3, Limit: 2, Offset: 0, HasMore: true}, page)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no sessions", func(t *testing.T) {
+		service, mock := newTestConversationService(t)
+
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM chat_sessions`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`FROM chat_sessions cs`).
+			WithArgs(20, 0).
+			WillReturnRows(sqlmock.NewRows(sessionColumns))
+
+		sessions, page, err := service.ListSessions(20, 0)
+		require.NoError(t, err)
+
+		assert.NotNil(t, sessions)
+		assert.Empty(t, sessions)
+		assert.False(t, page.HasMore)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
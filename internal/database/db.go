@@ -32,9 +32,7 @@ func New(databaseURL string) (*sqlx.DB, error) {
 	}
 
 	// Configure connection pool settings
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	ApplyPoolSettings(db, DefaultPoolSettings)
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
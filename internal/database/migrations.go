@@ -0,0 +1,273 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration is an ordered schema change applied once and recorded in schema_migrations
+// Statements must be idempotent (e.g. ADD COLUMN IF NOT EXISTS) so a partially applied
+// or re-run migration is harmless; the same goes for Down
+type Migration struct {
+	Version     int
+	Description string
+	Table       string   // Table altered by the migration; skipped until the table exists
+	Creates     []string // Tables created by a baseline migration (instead of Table); only recorded when they all exist
+	Statements  []string
+	Down        []string // Statements reverting the migration (empty = irreversible)
+}
+
+// ProductTextSearchColumn is the generated tsvector over title, tags and SKU used by hybrid search
+// The 'simple' configuration skips stemming so SKUs and model numbers (e.g. P365XL) match exactly
+const ProductTextSearchColumn = `ts tsvector GENERATED ALWAYS AS (
+	to_tsvector('simple', COALESCE(post_title, '') || ' ' || COALESCE(tags, '') || ' ' || COALESCE(sku, ''))
+) STORED`
+
+// ProductTextSearchIndex is the GIN index backing full-text queries on product_embeddings.ts
+const ProductTextSearchIndex = `CREATE INDEX IF NOT EXISTS idx_product_embeddings_ts ON product_embeddings USING gin (ts)`
+
+// keepOnRollback is the Down statement of data-only migrations whose changes stay valid when
+// rolled back; reverting one only removes its schema_migrations row
+const keepOnRollback = `SELECT 1`
+
+// sessionMessagePositionBackfill numbers the session_messages rows without a position, from 0 in
+// created_at order per session; sessions mixing numbered and unnumbered rows, which no release
+// writes, are left alone
+const sessionMessagePositionBackfill = `
+	UPDATE session_messages SET position = sub.rn
+	FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY created_at, id) - 1 AS rn
+		FROM session_messages
+		WHERE position IS NULL
+		  AND session_id NOT IN (SELECT session_id FROM session_messages WHERE position IS NOT NULL)
+	) sub
+	WHERE session_messages.id = sub.id
+`
+
+// Migrations alters tables created by earlier releases
+// The baseline (see SchemaMigrations) creates tables with the latest columns, so these only
+// matter for databases created before the column was introduced. Append new steps
+// with the next version number; never reorder or edit applied ones.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "add product_embeddings.published_at",
+		Table:       "product_embeddings",
+		Statements:  []string{`ALTER TABLE product_embeddings ADD COLUMN IF NOT EXISTS published_at TIMESTAMP`},
+		Down:        []string{`ALTER TABLE product_embeddings DROP COLUMN IF EXISTS published_at`},
+	},
+	{
+		Version:     2,
+		Description: "add session_messages.position",
+		Table:       "session_messages",
+		Statements: []string{
+			`ALTER TABLE session_messages ADD COLUMN IF NOT EXISTS position INT`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_session_messages_position ON session_messages(session_id, position)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_session_messages_position`,
+			`ALTER TABLE session_messages DROP COLUMN IF EXISTS position`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add product_embeddings.model",
+		Table:       "product_embeddings",
+		Statements:  []string{`ALTER TABLE product_embeddings ADD COLUMN IF NOT EXISTS model TEXT`},
+		Down:        []string{`ALTER TABLE product_embeddings DROP COLUMN IF EXISTS model`},
+	},
+	{
+		Version:     4,
+		Description: "add product_embeddings.image_url",
+		Table:       "product_embeddings",
+		Statements:  []string{`ALTER TABLE product_embeddings ADD COLUMN IF NOT EXISTS image_url TEXT`},
+		Down:        []string{`ALTER TABLE product_embeddings DROP COLUMN IF EXISTS image_url`},
+	},
+	{
+		Version:     5,
+		Description: "add product_embeddings.ts full-text column",
+		Table:       "product_embeddings",
+		Statements: []string{
+			`ALTER TABLE product_embeddings ADD COLUMN IF NOT EXISTS ` + ProductTextSearchColumn,
+			ProductTextSearchIndex,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_product_embeddings_ts`,
+			`ALTER TABLE product_embeddings DROP COLUMN IF EXISTS ts`,
+		},
+	},
+	{
+		// Messages saved before positions existed, so the next save doesn't insert the whole
+		// conversation again; the numbers match the conversation order and are kept on rollback
+		Version:     6,
+		Description: "number session_messages saved before positions",
+		Table:       "session_messages",
+		Statements:  []string{sessionMessagePositionBackfill},
+		Down:        []string{keepOnRollback},
+	},
+	{
+		Version:     7,
+		Description: "index product_embeddings.published_at",
+		Table:       "product_embeddings",
+		Statements:  []string{productPublishedAtIndex},
+		Down:        []string{`DROP INDEX IF EXISTS idx_product_embeddings_published_at`},
+	},
+}
+
+// RunMigrations applies pending migrations in version order and returns how many were applied
+// Already-applied versions are skipped, so it is safe to call on every startup
+func (wc *WriteClient) RunMigrations(migrations []Migration) (int, error) {
+	versions, err := wc.AppliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if len(migration.Creates) > 0 {
+			if err := wc.applyBaseline(migration); err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
+
+		var exists bool
+		if err := wc.ExecuteWriteQuerySingle(&exists, `SELECT to_regclass($1) IS NOT NULL`, migration.Table); err != nil {
+			return count, fmt.Errorf("migration %d: failed to check table %s: %w", migration.Version, migration.Table, err)
+		}
+		if !exists {
+			// The table will be created with the latest columns; apply on a later startup
+			continue
+		}
+
+		err := wc.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+			for _, statement := range migration.Statements {
+				if _, err := tx.Exec(statement); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`, migration.Version, migration.Description)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		fmt.Printf("[MIGRATIONS] Applied migration %d: %s\n", migration.Version, migration.Description)
+		count++
+	}
+
+	return count, nil
+}
+
+// applyBaseline records a baseline migration, running its statements unless every table it
+// creates already exists (databases set up before migrations tracked table creation)
+func (wc *WriteClient) applyBaseline(migration Migration) error {
+	ran := false
+	err := wc.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+		for _, table := range migration.Creates {
+			var exists bool
+			if err := tx.Get(&exists, `SELECT to_regclass($1) IS NOT NULL`, table); err != nil {
+				return fmt.Errorf("failed to check table %s: %w", table, err)
+			}
+			if !exists {
+				ran = true
+				break
+			}
+		}
+		if ran {
+			for _, statement := range migration.Statements {
+				if _, err := tx.Exec(statement); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`, migration.Version, migration.Description)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+	}
+
+	if ran {
+		fmt.Printf("[MIGRATIONS] Applied migration %d: %s\n", migration.Version, migration.Description)
+	} else {
+		fmt.Printf("[MIGRATIONS] Recorded migration %d (%s): its tables already exist\n", migration.Version, migration.Description)
+	}
+	return nil
+}
+
+// RollbackMigrations reverts the last steps applied migrations, newest first, and returns how many
+// were reverted. Each one runs its Down statements and deletes its schema_migrations row in one
+// transaction, so the next RunMigrations applies it again. An applied version missing from
+// migrations or without Down statements stops the rollback.
+func (wc *WriteClient) RollbackMigrations(migrations []Migration, steps int) (int, error) {
+	versions, err := wc.AppliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	count := 0
+	for i := len(versions) - 1; i >= 0 && count < steps; i-- {
+		migration, ok := byVersion[versions[i]]
+		if !ok {
+			return count, fmt.Errorf("migration %d is applied but unknown", versions[i])
+		}
+		if len(migration.Down) == 0 {
+			return count, fmt.Errorf("migration %d (%s) can't be rolled back", migration.Version, migration.Description)
+		}
+
+		err := wc.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+			for _, statement := range migration.Down {
+				if _, err := tx.Exec(statement); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		fmt.Printf("[MIGRATIONS] Rolled back migration %d: %s\n", migration.Version, migration.Description)
+		count++
+	}
+
+	return count, nil
+}
+
+// AppliedMigrations returns the recorded migration versions in ascending order, creating
+// schema_migrations on first use
+func (wc *WriteClient) AppliedMigrations() ([]int, error) {
+	if _, err := wc.ExecuteSchemaQuery(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var versions []int
+	if err := wc.ExecuteWriteQueryWithResult(&versions, `SELECT version FROM schema_migrations`); err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %w", err)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
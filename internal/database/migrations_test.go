@@ -0,0 +1,304 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Description: "add widgets.color", Table: "widgets", Statements: []string{`ALTER TABLE widgets ADD COLUMN IF NOT EXISTS color TEXT`},
+		Down: []string{`ALTER TABLE widgets DROP COLUMN IF EXISTS color`}},
+	{Version: 2, Description: "add gadgets.size", Table: "gadgets", Statements: []string{`ALTER TABLE gadgets ADD COLUMN IF NOT EXISTS size INT`},
+		Down: []string{`ALTER TABLE gadgets DROP COLUMN IF EXISTS size`}},
+}
+
+func expectMigrationsTable(mock sqlmock.Sqlmock, applied ...int) {
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, version := range applied {
+		rows.AddRow(version)
+	}
+	mock.ExpectQuery(`SELECT version FROM schema_migrations`).WillReturnRows(rows)
+}
+
+func expectApply(mock sqlmock.Sqlmock, migration Migration) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WithArgs(migration.Table).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	for _, statement := range migration.Statements {
+		mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(migration.Version, migration.Description).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestRunMigrations_IdempotentAcrossRuns(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	// First startup applies everything
+	expectMigrationsTable(mock)
+	expectApply(mock, testMigrations[0])
+	expectApply(mock, testMigrations[1])
+
+	applied, err := wc.RunMigrations(testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+
+	// Second startup sees both versions recorded and changes nothing
+	expectMigrationsTable(mock, 1, 2)
+
+	applied, err = wc.RunMigrations(testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunMigrations_SkipsMissingTables(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	expectMigrationsTable(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WithArgs("widgets").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectApply(mock, testMigrations[1])
+
+	applied, err := wc.RunMigrations(testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunMigrations_TenantSchemaPinsSearchPath(t *testing.T) {
+	wc, mock := newMockWriteClient(t, "tenant_a")
+	migration := testMigrations[0]
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL search_path TO "tenant_a", public`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT version FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WithArgs(migration.Table).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL search_path TO "tenant_a", public`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(migration.Statements[0])).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := wc.RunMigrations(testMigrations[:1])
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectRollback(mock sqlmock.Sqlmock, migration Migration) {
+	mock.ExpectBegin()
+	for _, statement := range migration.Down {
+		mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version = $1`)).
+		WithArgs(migration.Version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestRollbackMigrations_NewestFirst(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	// Rows come back unordered; the newest version is reverted first
+	expectMigrationsTable(mock, 2, 1)
+	expectRollback(mock, testMigrations[1])
+
+	reverted, err := wc.RollbackMigrations(testMigrations, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+
+	// The reverted version is applied again on the next run
+	expectMigrationsTable(mock, 1)
+	expectApply(mock, testMigrations[1])
+
+	applied, err := wc.RunMigrations(testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollbackMigrations_StopsAtIrreversibleMigration(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+	migrations := []Migration{
+		{Version: 1, Description: "backfill widgets", Table: "widgets", Statements: []string{`UPDATE widgets SET color = 'black'`}},
+		testMigrations[1],
+	}
+
+	expectMigrationsTable(mock, 1, 2)
+	expectRollback(mock, migrations[1])
+
+	reverted, err := wc.RollbackMigrations(migrations, 5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migration 1 (backfill widgets) can't be rolled back")
+	assert.Equal(t, 1, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollbackMigrations_UnknownVersion(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+	expectMigrationsTable(mock, 1, 7)
+
+	_, err := wc.RollbackMigrations(testMigrations, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migration 7 is applied but unknown")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollbackMigrations_FailedStatementKeepsVersion(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+	expectMigrationsTable(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(testMigrations[0].Down[0])).WillReturnError(errors.New("lock timeout"))
+	mock.ExpectRollback()
+
+	reverted, err := wc.RollbackMigrations(testMigrations, 1)
+	require.Error(t, err)
+	assert.Zero(t, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrations_VersionsIncrease(t *testing.T) {
+	migrations := SchemaMigrations(1536)
+	for i := 1; i < len(migrations); i++ {
+		assert.Greater(t, migrations[i].Version, migrations[i-1].Version, "migration %d", i)
+	}
+	for _, migration := range migrations {
+		assert.True(t, migration.Table != "" || len(migration.Creates) > 0, "migration %d should name its tables", migration.Version)
+		assert.NotEmpty(t, migration.Statements)
+		assert.NotEmpty(t, migration.Down, "migration %d should be reversible", migration.Version)
+	}
+}
+
+var testBaseline = Migration{
+	Version: 0, Description: "create tables", Creates: []string{"widgets", "gadgets"},
+	Statements: []string{`CREATE TABLE IF NOT EXISTS widgets (id INT)`, `CREATE TABLE IF NOT EXISTS gadgets (id INT)`},
+	Down:       []string{`DROP TABLE IF EXISTS gadgets`, `DROP TABLE IF EXISTS widgets`},
+}
+
+// expectBaselineCheck expects the existence check of each table created by testBaseline
+func expectBaselineCheck(mock sqlmock.Sqlmock, exists ...bool) {
+	for i, table := range testBaseline.Creates[:len(exists)] {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+			WithArgs(table).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists[i]))
+	}
+}
+
+func TestRunMigrations_BaselineCreatesMissingTables(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	// gadgets is missing, so the whole (idempotent) baseline runs
+	expectMigrationsTable(mock)
+	mock.ExpectBegin()
+	expectBaselineCheck(mock, true, false)
+	for _, statement := range testBaseline.Statements {
+		mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(0, testBaseline.Description).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectApply(mock, testMigrations[0])
+
+	applied, err := wc.RunMigrations(append([]Migration{testBaseline}, testMigrations[0]))
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunMigrations_BaselineRecordedForExistingTables(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	// A database set up before the baseline, with later migrations already recorded
+	expectMigrationsTable(mock, 1, 2)
+	mock.ExpectBegin()
+	expectBaselineCheck(mock, true, true)
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(0, testBaseline.Description).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := wc.RunMigrations(append([]Migration{testBaseline}, testMigrations...))
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaMigrations_BaselineSizesVectorColumns(t *testing.T) {
+	baseline := SchemaMigrations(3072)[0]
+	require.Equal(t, 0, baseline.Version)
+
+	var vectorColumns int
+	for _, statement := range baseline.Statements {
+		vectorColumns += strings.Count(statement, "vector(3072)")
+		// Indexes on columns added by later migrations stay in those migrations, so a partial
+		// database from an older release doesn't fail the baseline
+		assert.NotContains(t, statement, "idx_product_embeddings_published_at")
+		assert.NotContains(t, statement, "idx_product_embeddings_ts")
+		assert.NotContains(t, statement, "idx_session_messages_position")
+	}
+	assert.Equal(t, 2, vectorColumns, "product_embeddings and email_embeddings")
+	assert.Len(t, baseline.Down, len(baseline.Creates))
+}
+
+func TestMigrateSchema_EnablesVectorBeforeMigrating(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+	versions := make([]int, 0, len(SchemaMigrations(1536)))
+	for _, migration := range SchemaMigrations(1536) {
+		versions = append(versions, migration.Version)
+	}
+
+	mock.ExpectExec(`CREATE EXTENSION IF NOT EXISTS vector`).WillReturnError(errors.New("permission denied"))
+	expectMigrationsTable(mock, versions...)
+
+	applied, err := wc.MigrateSchema(1536)
+	require.NoError(t, err, "a missing CREATE privilege is only a warning")
+	assert.Zero(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrations_BackfillsLegacyPositionsOnce(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+	backfill := Migrations[5]
+	require.Equal(t, 6, backfill.Version)
+	require.Equal(t, "session_messages", backfill.Table)
+
+	expectMigrationsTable(mock, 1, 2, 3, 4, 5, 7)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WithArgs("session_messages").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE session_messages SET position = sub.rn\s+FROM \(\s+SELECT id, ROW_NUMBER\(\) OVER \(PARTITION BY session_id ORDER BY created_at, id\) - 1 AS rn`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(backfill.Version, backfill.Description).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := wc.RunMigrations(Migrations)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+
+	// Later startups don't scan session_messages again
+	expectMigrationsTable(mock, 1, 2, 3, 4, 5, 6, 7)
+	applied, err = wc.RunMigrations(Migrations)
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PageInfo describes one page of a LIMIT/OFFSET listing
+type PageInfo struct {
+	Total   int  `json:"total"`    // Total rows matching the listing
+	Limit   int  `json:"limit"`    // Page size
+	Offset  int  `json:"offset"`   // Current offset
+	HasMore bool `json:"has_more"` // Whether rows remain after this page
+}
+
+// NewPageInfo assembles pagination metadata for a page starting at offset
+func NewPageInfo(total, limit, offset int) PageInfo {
+	return PageInfo{
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: offset+limit < total,
+	}
+}
+
+// QueryPage runs a paginated listing: query is scanned into dest with LIMIT/OFFSET
+// appended, and countQuery (sharing the same args) provides the total row count
+// query must not contain its own LIMIT/OFFSET; the appended placeholders follow args
+func (wc *WriteClient) QueryPage(dest interface{}, query, countQuery string, limit, offset int, args ...interface{}) (PageInfo, error) {
+	if limit <= 0 {
+		return PageInfo{}, fmt.Errorf("page limit must be positive, got %d", limit)
+	}
+	if offset < 0 {
+		return PageInfo{}, fmt.Errorf("page offset must not be negative, got %d", offset)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var total int
+	if err := wc.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return PageInfo{}, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	pageQuery := fmt.Sprintf("%s LIMIT $%d OFFSET $%d", query, len(args)+1, len(args)+2)
+	pageArgs := append(append([]interface{}{}, args...), limit, offset)
+	if err := wc.db.SelectContext(ctx, dest, pageQuery, pageArgs...); err != nil {
+		return PageInfo{}, fmt.Errorf("failed to query page: %w", err)
+	}
+
+	return NewPageInfo(total, limit, offset), nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pageRow struct {
+	ID    int    `db:"id"`
+	Title string `db:"title"`
+}
+
+func TestNewPageInfo_HasMore(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		limit   int
+		offset  int
+		hasMore bool
+	}{
+		{name: "first of several pages", total: 45, limit: 20, offset: 0, hasMore: true},
+		{name: "last partial page", total: 45, limit: 20, offset: 40, hasMore: false},
+		{name: "page ends exactly at total", total: 40, limit: 20, offset: 20, hasMore: false},
+		{name: "offset past total", total: 5, limit: 20, offset: 60, hasMore: false},
+		{name: "empty listing", total: 0, limit: 20, offset: 0, hasMore: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := NewPageInfo(tt.total, tt.limit, tt.offset)
+			assert.Equal(t, tt.total, page.Total)
+			assert.Equal(t, tt.limit, page.Limit)
+			assert.Equal(t, tt.offset, page.Offset)
+			assert.Equal(t, tt.hasMore, page.HasMore)
+		})
+	}
+}
+
+func TestQueryPage_AssemblesCountAndPage(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM emails WHERE thread_id = \$1`).
+		WithArgs("thread-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT id, title FROM emails WHERE thread_id = \$1 ORDER BY id LIMIT \$2 OFFSET \$3`).
+		WithArgs("thread-1", 2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(1, "Holster").AddRow(2, "Sling"))
+
+	var rows []pageRow
+	page, err := wc.QueryPage(&rows,
+		`SELECT id, title FROM emails WHERE thread_id = $1 ORDER BY id`,
+		`SELECT COUNT(*) FROM emails WHERE thread_id = $1`,
+		2, 0, "thread-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, []pageRow{{ID: 1, Title: "Holster"}, {ID: 2, Title: "Sling"}}, rows)
+	assert.Equal(t, PageInfo{Total: 3, Limit: 2, Offset: 0, HasMore: true}, page)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryPage_NoArgs(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM chat_sessions`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT id, title FROM chat_sessions ORDER BY id LIMIT \$1 OFFSET \$2`).
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(3, "Plate Carrier"))
+
+	var rows []pageRow
+	page, err := wc.QueryPage(&rows, `SELECT id, title FROM chat_sessions ORDER BY id`, `SELECT COUNT(*) FROM chat_sessions`, 2, 2)
+	require.NoError(t, err)
+
+	assert.Len(t, rows, 1)
+	assert.False(t, page.HasMore)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryPage_Errors(t *testing.T) {
+	wc, mock := newMockWriteClient(t)
+
+	var rows []pageRow
+	_, err := wc.QueryPage(&rows, `SELECT id, title FROM chat_sessions`, `SELECT COUNT(*) FROM chat_sessions`, 0, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "limit must be positive")
+
+	_, err = wc.QueryPage(&rows, `SELECT id, title FROM chat_sessions`, `SELECT COUNT(*) FROM chat_sessions`, 10, -1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "offset must not be negative")
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM chat_sessions`).WillReturnError(errors.New("connection reset"))
+	_, err = wc.QueryPage(&rows, `SELECT id, title FROM chat_sessions`, `SELECT COUNT(*) FROM chat_sessions`, 10, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to count rows")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PoolSettings sizes a database connection pool
+type PoolSettings struct {
+	MaxOpenConns    int           // Maximum open connections (0 = unlimited)
+	MaxIdleConns    int           // Maximum idle connections kept for reuse
+	ConnMaxLifetime time.Duration // Maximum time a connection is reused (0 = forever)
+}
+
+// DefaultPoolSettings is applied by New and NewWriteClient until ApplyPoolSettings overrides it
+var DefaultPoolSettings = PoolSettings{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Hour}
+
+// ApplyPoolSettings resizes the connection pool of db
+func ApplyPoolSettings(db *sqlx.DB, settings PoolSettings) {
+	db.SetMaxOpenConns(settings.MaxOpenConns)
+	db.SetMaxIdleConns(settings.MaxIdleConns)
+	db.SetConnMaxLifetime(settings.ConnMaxLifetime)
+}
+
+// ApplyPoolSettings resizes the write client's connection pool
+func (wc *WriteClient) ApplyPoolSettings(settings PoolSettings) {
+	ApplyPoolSettings(wc.db, settings)
+}
+
+// GetPoolStats returns the write client's connection pool statistics
+func (wc *WriteClient) GetPoolStats() sql.DBStats {
+	return wc.db.Stats()
+}
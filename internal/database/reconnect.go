@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReconnectPolicy controls how ReadDB watches and re-establishes its connection
+type ReconnectPolicy struct {
+	CheckInterval  time.Duration // Time between pings of a live connection
+	InitialBackoff time.Duration // Wait after the first failed attempt; doubled after each failure
+	MaxBackoff     time.Duration // Upper bound for the wait between attempts
+	PingTimeout    time.Duration // Timeout of each health ping
+}
+
+// DefaultReconnectPolicy retries quickly at first and backs off to once a minute while the tunnel is down
+var DefaultReconnectPolicy = ReconnectPolicy{
+	CheckInterval:  30 * time.Second,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	PingTimeout:    5 * time.Second,
+}
+
+// ReconnectStatus describes the reconnection state of a ReadDB
+type ReconnectStatus struct {
+	Reconnecting bool      // The connection is missing or failing and attempts are in progress
+	Attempts     int       // Failed attempts since the connection was lost
+	LastError    string    // Error of the last failed ping or attempt
+	LastAttempt  time.Time // Time of the last attempt (zero before the first)
+	NextAttempt  time.Time // Time of the next attempt while reconnecting
+	ConnectedAt  time.Time // Time the current connection was established by the loop (zero for the startup connection)
+}
+
+// ReadDB holds the read-only product database connection, which Maintain replaces after the
+// database comes back (e.g. an SSH tunnel restarts). A nil *ReadDB has no connection.
+type ReadDB struct {
+	mu     sync.RWMutex
+	db     *sqlx.DB
+	status ReconnectStatus
+}
+
+// NewReadDB wraps the connection opened at startup; db may be nil when it failed
+func NewReadDB(db *sqlx.DB) *ReadDB {
+	return &ReadDB{db: db, status: ReconnectStatus{Reconnecting: db == nil}}
+}
+
+// Get returns the current connection, nil while the database is unavailable
+func (r *ReadDB) Get() *sqlx.DB {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.db
+}
+
+// Status returns the current reconnection state
+func (r *ReadDB) Status() ReconnectStatus {
+	if r == nil {
+		return ReconnectStatus{}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+// Maintain pings the connection every CheckInterval and, while it is missing or its pings fail,
+// calls connect with exponential backoff until it succeeds. The new connection replaces the old
+// one, which is closed, and is passed to onConnect (may be nil). Maintain returns when ctx is done.
+func (r *ReadDB) Maintain(ctx context.Context, policy ReconnectPolicy, connect func() (*sqlx.DB, error), onConnect func(*sqlx.DB)) {
+	backoff := policy.InitialBackoff
+	for {
+		if !r.Status().Reconnecting {
+			if !sleepContext(ctx, policy.CheckInterval) {
+				return
+			}
+			if err := r.ping(ctx, policy.PingTimeout); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Printf("[DB_RECONNECT] Read database ping failed, reconnecting: %v\n", err)
+				r.markLost(err)
+				backoff = policy.InitialBackoff
+			}
+			continue
+		}
+
+		db, err := connect()
+		if err != nil {
+			next := time.Now().Add(backoff)
+			attempts := r.recordFailure(err, next)
+			fmt.Printf("[DB_RECONNECT] Attempt %d failed, retrying in %v: %v\n", attempts, backoff, err)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, policy.MaxBackoff)
+			continue
+		}
+
+		attempts := r.swap(db)
+		fmt.Printf("[DB_RECONNECT] ✅ Read database connection restored after %d failed attempts\n", attempts)
+		if onConnect != nil {
+			onConnect(db)
+		}
+		backoff = policy.InitialBackoff
+	}
+}
+
+// ping checks the current connection
+func (r *ReadDB) ping(ctx context.Context, timeout time.Duration) error {
+	db := r.Get()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return db.PingContext(pingCtx)
+}
+
+// markLost starts reconnecting after a failed ping; the old connection is kept until a new one is up
+func (r *ReadDB) markLost(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = ReconnectStatus{Reconnecting: true, LastError: err.Error(), NextAttempt: time.Now()}
+}
+
+// recordFailure records a failed attempt and returns the number of failed attempts so far
+func (r *ReadDB) recordFailure(err error, next time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Attempts++
+	r.status.LastError = err.Error()
+	r.status.LastAttempt = time.Now()
+	r.status.NextAttempt = next
+	return r.status.Attempts
+}
+
+// swap replaces the connection, closes the old one and returns the failed attempts before it
+func (r *ReadDB) swap(db *sqlx.DB) int {
+	r.mu.Lock()
+	old := r.db
+	attempts := r.status.Attempts
+	now := time.Now()
+	r.db = db
+	r.status = ReconnectStatus{LastAttempt: now, ConnectedAt: now}
+	r.mu.Unlock()
+
+	if old != nil && old != db {
+		if err := old.Close(); err != nil {
+			fmt.Printf("[DB_RECONNECT] Warning: Error closing the previous connection: %v\n", err)
+		}
+	}
+	return attempts
+}
+
+// sleepContext waits for d and reports false when ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testReconnectPolicy = ReconnectPolicy{
+	CheckInterval:  time.Millisecond,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     4 * time.Millisecond,
+	PingTimeout:    time.Second,
+}
+
+func newPingMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	return sqlx.NewDb(mockDB, "sqlmock"), mock
+}
+
+// maintainUntilConnected runs Maintain until onConnect fires and returns the connection it received
+func maintainUntilConnected(t *testing.T, readDB *ReadDB, connect func() (*sqlx.DB, error)) *sqlx.DB {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	connected := make(chan *sqlx.DB, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		readDB.Maintain(ctx, testReconnectPolicy, connect, func(db *sqlx.DB) {
+			connected <- db
+			cancel()
+		})
+	}()
+
+	select {
+	case db := <-connected:
+		<-done
+		return db
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatal("timed out waiting for the reconnection")
+		return nil
+	}
+}
+
+func TestReadDB_ReconnectsWithBackoff(t *testing.T) {
+	readDB := NewReadDB(nil)
+	assert.Nil(t, readDB.Get())
+	assert.True(t, readDB.Status().Reconnecting)
+
+	live, _ := newPingMockDB(t)
+	calls := 0
+	got := maintainUntilConnected(t, readDB, func() (*sqlx.DB, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return live, nil
+	})
+
+	assert.Equal(t, 3, calls)
+	assert.Same(t, live, got)
+	assert.Same(t, live, readDB.Get())
+	status := readDB.Status()
+	assert.False(t, status.Reconnecting)
+	assert.Zero(t, status.Attempts)
+	assert.False(t, status.ConnectedAt.IsZero())
+}
+
+func TestReadDB_FailedPingSwapsConnection(t *testing.T) {
+	stale, staleMock := newPingMockDB(t)
+	staleMock.ExpectPing().WillReturnError(errors.New("broken pipe"))
+	staleMock.ExpectClose()
+	readDB := NewReadDB(stale)
+	assert.False(t, readDB.Status().Reconnecting)
+
+	live, _ := newPingMockDB(t)
+	got := maintainUntilConnected(t, readDB, func() (*sqlx.DB, error) { return live, nil })
+
+	assert.Same(t, live, got)
+	assert.Same(t, live, readDB.Get())
+	assert.NoError(t, staleMock.ExpectationsWereMet())
+}
+
+func TestReadDB_RecordsFailedAttempts(t *testing.T) {
+	readDB := NewReadDB(nil)
+	attempts := readDB.recordFailure(errors.New("connection refused"), time.Now().Add(time.Second))
+	attempts = readDB.recordFailure(errors.New("timeout"), time.Now().Add(2*time.Second))
+
+	status := readDB.Status()
+	assert.Equal(t, 2, attempts)
+	assert.True(t, status.Reconnecting)
+	assert.Equal(t, 2, status.Attempts)
+	assert.Equal(t, "timeout", status.LastError)
+	assert.True(t, status.NextAttempt.After(status.LastAttempt))
+}
+
+func TestReadDB_Nil(t *testing.T) {
+	var readDB *ReadDB
+	assert.Nil(t, readDB.Get())
+	assert.Equal(t, ReconnectStatus{}, readDB.Status())
+}
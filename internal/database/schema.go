@@ -0,0 +1,243 @@
+package database
+
+import "fmt"
+
+// productPublishedAtIndex backs the recency boost; published_at was added by migration 1, so the
+// index is migration 7 rather than part of the baseline
+const productPublishedAtIndex = `CREATE INDEX IF NOT EXISTS idx_product_embeddings_published_at ON product_embeddings(published_at)`
+
+// productEmbeddingsTables creates product_embeddings with vector(vectorDimensions) and
+// product_checksums, with the latest columns and the indexes on columns every release had
+func productEmbeddingsTables(vectorDimensions int) []string {
+	return []string{
+		// Product metadata is denormalized for search performance
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS product_embeddings (
+			product_id INT PRIMARY KEY,
+			embedding vector(%d) NOT NULL,
+			post_title TEXT,
+			post_name TEXT,
+			description TEXT,
+			short_description TEXT,
+			sku TEXT,
+			min_price TEXT,
+			max_price TEXT,
+			stock_status TEXT,
+			stock_quantity NUMERIC,
+			tags TEXT,
+			published_at TIMESTAMP,
+			model TEXT,
+			image_url TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			%s
+		)`, vectorDimensions, ProductTextSearchColumn),
+
+		// Checksums of the embedded product text, to skip unchanged products
+		`CREATE TABLE IF NOT EXISTS product_checksums (
+			product_id INT PRIMARY KEY,
+			checksum TEXT NOT NULL,
+			last_checked TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(product_id)
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_embeddings_product_id ON product_embeddings(product_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_embeddings_post_title ON product_embeddings(post_title) WHERE post_title IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_product_checksums_product_id ON product_checksums(product_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_checksums_last_checked ON product_checksums(last_checked)`,
+	}
+}
+
+// ProductEmbeddingsSchema creates product_embeddings and product_checksums with every column and
+// index the migrations would add, for recreating them at a new vector size
+func ProductEmbeddingsSchema(vectorDimensions int) []string {
+	return append(productEmbeddingsTables(vectorDimensions), productPublishedAtIndex, ProductTextSearchIndex)
+}
+
+// synonymsTable holds the query expansion synonyms, manageable without recompiling
+const synonymsTable = `CREATE TABLE IF NOT EXISTS synonyms (
+	term TEXT NOT NULL,
+	synonym TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (term, synonym)
+)`
+
+// emailTables creates the imported emails, their threads and email_embeddings with vector(vectorDimensions)
+// The HNSW index on email_embeddings depends on VECTOR_DISTANCE_METRIC and is created by CreateEmailTables.
+func emailTables(vectorDimensions int) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS emails (
+			id SERIAL PRIMARY KEY,
+			message_id VARCHAR(255) UNIQUE NOT NULL,
+			subject TEXT NOT NULL,
+			from_addr TEXT NOT NULL,
+			to_addr TEXT NOT NULL,
+			date TIMESTAMP NOT NULL,
+			body TEXT NOT NULL,
+			thread_id VARCHAR(255),
+			in_reply_to VARCHAR(255),
+			"references" TEXT,
+			is_customer BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS email_threads (
+			thread_id VARCHAR(255) PRIMARY KEY,
+			subject TEXT NOT NULL,
+			email_count INT DEFAULT 1,
+			first_date TIMESTAMP NOT NULL,
+			last_date TIMESTAMP NOT NULL,
+			summary TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Distinct From/To addresses per thread, for "has this customer contacted us before" lookups
+		`CREATE TABLE IF NOT EXISTS thread_participants (
+			thread_id VARCHAR(255) NOT NULL,
+			address VARCHAR(320) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (thread_id, address),
+			FOREIGN KEY (thread_id) REFERENCES email_threads(thread_id) ON DELETE CASCADE
+		)`,
+
+		// Attachment filenames and content types per email (the content itself is not stored)
+		`CREATE TABLE IF NOT EXISTS email_attachments (
+			id SERIAL PRIMARY KEY,
+			email_id INT NOT NULL,
+			filename TEXT NOT NULL,
+			content_type VARCHAR(255) NOT NULL,
+			is_inline BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+		)`,
+
+		// Incremental import progress per mailbox (see ImportFromIMAP)
+		`CREATE TABLE IF NOT EXISTS email_sync_state (
+			source VARCHAR(512) PRIMARY KEY,
+			uid_validity BIGINT NOT NULL,
+			last_uid BIGINT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS email_embeddings (
+			id SERIAL PRIMARY KEY,
+			email_id INT,
+			thread_id VARCHAR(255),
+			embedding vector(%d) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (email_id),
+			UNIQUE (thread_id),
+			FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+		)`, vectorDimensions),
+
+		`CREATE INDEX IF NOT EXISTS idx_emails_message_id ON emails(message_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_emails_thread_id ON emails(thread_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_emails_date ON emails(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_emails_is_customer ON emails(is_customer)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_threads_first_date ON email_threads(first_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_threads_last_date ON email_threads(last_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_thread_participants_address ON thread_participants(address)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_attachments_email_id ON email_attachments(email_id)`,
+	}
+}
+
+// analyticsTables holds the tracked events and their daily aggregates for faster queries
+var analyticsTables = []string{
+	`CREATE TABLE IF NOT EXISTS analytics_events (
+		id SERIAL PRIMARY KEY,
+		event_type VARCHAR(50) NOT NULL,
+		count INT DEFAULT 1,
+		metadata JSONB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_event_type ON analytics_events(event_type)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_created_at ON analytics_events(created_at)`,
+	`CREATE TABLE IF NOT EXISTS analytics_daily (
+		id SERIAL PRIMARY KEY,
+		date DATE NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		total_count INT DEFAULT 0,
+		metadata JSONB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(date, event_type)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_daily_date ON analytics_daily(date)`,
+}
+
+// conversationTables holds the chat sessions and their messages
+// The unique (session_id, position) index and the numbering of older rows are migrations 2 and 6.
+var conversationTables = []string{
+	`CREATE TABLE IF NOT EXISTS chat_sessions (
+		id SERIAL PRIMARY KEY,
+		session_id VARCHAR(36) UNIQUE NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		email_sent BOOLEAN DEFAULT FALSE,
+		email_html TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_chat_sessions_session_id ON chat_sessions(session_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_chat_sessions_created_at ON chat_sessions(created_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS session_messages (
+		id SERIAL PRIMARY KEY,
+		session_id VARCHAR(36) NOT NULL,
+		role VARCHAR(20) NOT NULL,
+		message TEXT NOT NULL,
+		position INT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (session_id) REFERENCES chat_sessions(session_id) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_session_messages_session_id ON session_messages(session_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_session_messages_created_at ON session_messages(created_at)`,
+}
+
+// baselineTables lists the tables created by the baseline migration, dependents last
+var baselineTables = []string{
+	"product_embeddings", "product_checksums", "synonyms",
+	"emails", "email_threads", "thread_participants", "email_attachments", "email_sync_state", "email_embeddings",
+	"analytics_events", "analytics_daily",
+	"chat_sessions", "session_messages",
+}
+
+// baselineMigration creates every table, sizing the vector columns for the embedding model
+// (vectorDimensions). Indexes on columns added by later migrations are left to those migrations,
+// so the baseline also runs cleanly against a database holding only some tables from an older release.
+func baselineMigration(vectorDimensions int) Migration {
+	var statements []string
+	statements = append(statements, productEmbeddingsTables(vectorDimensions)...)
+	statements = append(statements, synonymsTable)
+	statements = append(statements, emailTables(vectorDimensions)...)
+	statements = append(statements, analyticsTables...)
+	statements = append(statements, conversationTables...)
+
+	down := make([]string, 0, len(baselineTables))
+	for i := len(baselineTables) - 1; i >= 0; i-- {
+		down = append(down, `DROP TABLE IF EXISTS `+baselineTables[i])
+	}
+
+	return Migration{
+		Version:     0,
+		Description: "create tables",
+		Creates:     baselineTables,
+		Statements:  statements,
+		Down:        down,
+	}
+}
+
+// SchemaMigrations returns the baseline followed by Migrations, with vector columns of
+// vectorDimensions (the embedding model's output size)
+func SchemaMigrations(vectorDimensions int) []Migration {
+	return append([]Migration{baselineMigration(vectorDimensions)}, Migrations...)
+}
+
+// MigrateSchema enables pgvector and applies the pending SchemaMigrations, returning how many were applied
+func (wc *WriteClient) MigrateSchema(vectorDimensions int) (int, error) {
+	// Outside the migration transaction: without the privilege to create it, an extension
+	// installed by an administrator is still usable
+	if _, err := wc.ExecuteWriteQuery(`CREATE EXTENSION IF NOT EXISTS vector SCHEMA public`); err != nil {
+		fmt.Printf("[MIGRATIONS] Warning: Failed to create vector extension (may already exist): %v\n", err)
+	}
+	return wc.RunMigrations(SchemaMigrations(vectorDimensions))
+}
@@ -4,20 +4,33 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql" // Keep for remote MySQL DB
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq" // Add for local PostgreSQL DB
+	"github.com/lib/pq" // Add for local PostgreSQL DB
 )
 
+// schemaNamePattern restricts schema names to plain, unquoted PostgreSQL identifiers
+var schemaNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // WriteClient provides write access to the database for embedding operations
 type WriteClient struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	schema string // PostgreSQL schema holding this tenant's tables (empty = default search_path)
 }
 
 // NewWriteClient creates a new write-enabled database client (supports both MySQL and PostgreSQL)
-func NewWriteClient(databaseURL string) (*WriteClient, error) {
+// schema: Optional PostgreSQL schema used for all tables, isolating tenants that share one database
+func NewWriteClient(databaseURL string, schema ...string) (*WriteClient, error) {
+	tenantSchema := firstSchema(schema)
+	if tenantSchema != "" && !schemaNamePattern.MatchString(tenantSchema) {
+		return nil, fmt.Errorf("invalid database schema name %q", tenantSchema)
+	}
+
 	// Parse the URL to replace read-only user with write user
 	writeURL := convertToWriteURL(databaseURL)
 
@@ -31,15 +44,24 @@ func NewWriteClient(databaseURL string) (*WriteClient, error) {
 		driver = driverPostgres
 	}
 
+	if tenantSchema != "" {
+		if driver != driverPostgres {
+			return nil, fmt.Errorf("database schema %q requires a PostgreSQL embeddings database", tenantSchema)
+		}
+		var err error
+		writeURL, err = withSearchPath(writeURL, tenantSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	db, err := sqlx.Connect(driver, writeURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database with write access: %v", err)
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	ApplyPoolSettings(db, DefaultPoolSettings)
 
 	// Test the connection
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -49,7 +71,84 @@ func NewWriteClient(databaseURL string) (*WriteClient, error) {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	return &WriteClient{db: db}, nil
+	wc := &WriteClient{db: db, schema: tenantSchema}
+	if err := wc.EnsureSchema(); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// NewWriteClientFromDB wraps an existing database connection in a WriteClient
+// Useful when the caller manages the connection (e.g. tests using sqlmock)
+func NewWriteClientFromDB(db *sqlx.DB, schema ...string) *WriteClient {
+	return &WriteClient{db: db, schema: firstSchema(schema)}
+}
+
+// firstSchema returns the optional schema argument, or "" when none was given
+func firstSchema(schema []string) string {
+	if len(schema) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(schema[0])
+}
+
+// withSearchPath adds a search_path runtime parameter to a PostgreSQL URL so every
+// pooled connection resolves unqualified table names in the tenant schema first
+// public stays on the path so shared extensions like pgvector remain visible
+func withSearchPath(databaseURL, schema string) (string, error) {
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse database URL: %v", err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema+",public")
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// Schema returns the configured tenant schema (empty when using the default search_path)
+func (wc *WriteClient) Schema() string {
+	return wc.schema
+}
+
+// EnsureSchema creates the tenant schema if one is configured
+func (wc *WriteClient) EnsureSchema() error {
+	if wc.schema == "" {
+		return nil
+	}
+	if _, err := wc.ExecuteWriteQuery("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(wc.schema)); err != nil {
+		return fmt.Errorf("failed to create schema %s: %w", wc.schema, err)
+	}
+	return nil
+}
+
+// ExecuteSchemaQuery executes a DDL statement (CREATE TABLE/INDEX, ALTER TABLE) inside the tenant schema
+// The search_path is pinned for the statement's transaction, so DDL never lands in another
+// schema even when a connection pooler drops the connection-level search_path
+func (wc *WriteClient) ExecuteSchemaQuery(query string, args ...interface{}) (sql.Result, error) {
+	if wc.schema == "" {
+		return wc.ExecuteWriteQuery(query, args...)
+	}
+
+	var result sql.Result
+	err := wc.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+		var err error
+		result, err = tx.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// WithSchemaTransaction runs fn in a transaction whose search_path is pinned to the tenant schema (if any)
+func (wc *WriteClient) WithSchemaTransaction(fn func(tx *sqlx.Tx) error) error {
+	return wc.WithTransaction(func(tx *sqlx.Tx) error {
+		if wc.schema != "" {
+			if _, err := tx.Exec("SET LOCAL search_path TO " + pq.QuoteIdentifier(wc.schema) + ", public"); err != nil {
+				return fmt.Errorf("failed to set search_path: %w", err)
+			}
+		}
+		return fn(tx)
+	})
 }
 
 // GetDB returns the underlying database connection
@@ -59,7 +158,12 @@ func (wc *WriteClient) GetDB() *sqlx.DB {
 
 // ExecuteWriteQuery executes a write query and returns the result
 func (wc *WriteClient) ExecuteWriteQuery(query string, args ...interface{}) (sql.Result, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return wc.ExecuteWriteQueryContext(context.Background(), query, args...)
+}
+
+// ExecuteWriteQueryContext is ExecuteWriteQuery bounded by ctx as well as the query timeout
+func (wc *WriteClient) ExecuteWriteQueryContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	return wc.db.ExecContext(ctx, query, args...)
@@ -67,7 +171,12 @@ func (wc *WriteClient) ExecuteWriteQuery(query string, args ...interface{}) (sql
 
 // ExecuteWriteQueryWithResult executes a write query and scans the result into dest
 func (wc *WriteClient) ExecuteWriteQueryWithResult(dest interface{}, query string, args ...interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return wc.ExecuteWriteQueryWithResultContext(context.Background(), dest, query, args...)
+}
+
+// ExecuteWriteQueryWithResultContext is ExecuteWriteQueryWithResult bounded by ctx as well as the query timeout
+func (wc *WriteClient) ExecuteWriteQueryWithResultContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	return wc.db.SelectContext(ctx, dest, query, args...)
@@ -81,6 +190,34 @@ func (wc *WriteClient) ExecuteWriteQuerySingle(dest interface{}, query string, a
 	return wc.db.GetContext(ctx, dest, query, args...)
 }
 
+// WithTransaction runs fn inside a transaction, committing on success and rolling back on error
+func (wc *WriteClient) WithTransaction(fn func(tx *sqlx.Tx) error) error {
+	return wc.WithTransactionContext(context.Background(), fn)
+}
+
+// WithTransactionContext is WithTransaction bounded by ctx; canceling ctx rolls the transaction back
+func (wc *WriteClient) WithTransactionContext(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := wc.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			fmt.Printf("[DATABASE] Warning: Failed to roll back transaction: %v\n", rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // Close closes the database connection
 func (wc *WriteClient) Close() error {
 	return wc.db.Close()
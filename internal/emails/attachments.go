@@ -0,0 +1,127 @@
+package emails
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"ids/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// defaultAttachmentContentType is recorded for attachments without a Content-Type
+const defaultAttachmentContentType = "application/octet-stream"
+
+// partAttachment reports whether a MIME part is an attachment and returns its metadata
+// Parts with Content-Disposition: attachment, and any part naming a file (inline images,
+// forwarded documents), are attachments. The filename comes from the Content-Disposition
+// filename parameter, falling back to the Content-Type name parameter.
+func partAttachment(contentType, contentDisposition string) (models.EmailAttachment, bool) {
+	mediaType, typeParams, _ := mime.ParseMediaType(contentType)
+	disposition, dispositionParams, _ := mime.ParseMediaType(contentDisposition)
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return models.EmailAttachment{}, false
+	}
+
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = typeParams["name"]
+	}
+	filename = attachmentBaseName(decodeHeader(filename))
+	if filename == "" && disposition != "attachment" {
+		return models.EmailAttachment{}, false
+	}
+
+	if mediaType == "" {
+		mediaType = defaultAttachmentContentType
+	}
+	return models.EmailAttachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		IsInline:    disposition == "inline",
+	}, true
+}
+
+// attachmentBaseName strips any client-side directory (Unix or Windows) from a filename
+func attachmentBaseName(filename string) string {
+	filename = strings.TrimSpace(filename)
+	if i := strings.LastIndexAny(filename, `/\`); i >= 0 {
+		filename = filename[i+1:]
+	}
+	return filename
+}
+
+// attachmentNames returns the non-empty attachment filenames, in order
+func attachmentNames(attachments []models.EmailAttachment) []string {
+	var names []string
+	for _, attachment := range attachments {
+		if attachment.Filename != "" {
+			names = append(names, attachment.Filename)
+		}
+	}
+	return names
+}
+
+// storeAttachments replaces the stored attachment metadata of email
+// Emails without attachments are skipped, so re-imports of attachment-less emails cost no query.
+func (ees *EmailEmbeddingService) storeAttachments(email *models.Email) error {
+	if len(email.Attachments) == 0 {
+		return nil
+	}
+
+	filenames := make([]string, len(email.Attachments))
+	contentTypes := make([]string, len(email.Attachments))
+	inline := make([]bool, len(email.Attachments))
+	for i, attachment := range email.Attachments {
+		filenames[i] = attachment.Filename
+		contentTypes[i] = attachment.ContentType
+		inline[i] = attachment.IsInline
+	}
+
+	// Deleting and inserting in one statement keeps re-imports from duplicating rows
+	query := `
+		WITH cleared AS (
+			DELETE FROM email_attachments WHERE email_id = $1
+		)
+		INSERT INTO email_attachments (email_id, filename, content_type, is_inline)
+		SELECT $1, a.filename, a.content_type, a.is_inline
+		FROM unnest($2::text[], $3::text[], $4::boolean[]) AS a(filename, content_type, is_inline)
+	`
+	if _, err := ees.db.ExecuteWriteQuery(query, email.ID, pq.Array(filenames), pq.Array(contentTypes), pq.Array(inline)); err != nil {
+		return fmt.Errorf("failed to store email attachments: %w", err)
+	}
+	return nil
+}
+
+// loadAttachments fills in the Attachments of emails from email_attachments
+func (ees *EmailEmbeddingService) loadAttachments(emails []models.Email) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(emails))
+	byID := make(map[int]int, len(emails))
+	for i, email := range emails {
+		ids[i] = email.ID
+		byID[email.ID] = i
+	}
+
+	query := `
+		SELECT email_id, filename, content_type, is_inline
+		FROM email_attachments
+		WHERE email_id = ANY($1)
+		ORDER BY email_id, id
+	`
+	var attachments []models.EmailAttachment
+	if err := ees.db.ExecuteWriteQueryWithResult(&attachments, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to load email attachments: %w", err)
+	}
+
+	for _, attachment := range attachments {
+		if i, ok := byID[attachment.EmailID]; ok {
+			emails[i].Attachments = append(emails[i].Attachments, attachment)
+		}
+	}
+	return nil
+}
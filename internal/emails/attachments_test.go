@@ -0,0 +1,131 @@
+package emails
+
+import (
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attachmentRows returns empty email_attachments rows
+func attachmentRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"email_id", "filename", "content_type", "is_inline"})
+}
+
+// expectAttachments expects the attachment lookup for the email IDs (a Postgres array literal)
+func expectAttachments(mock sqlmock.Sqlmock, ids string, rows *sqlmock.Rows) {
+	mock.ExpectQuery(`FROM email_attachments\s+WHERE email_id = ANY\(\$1\)`).WithArgs(ids).WillReturnRows(rows)
+}
+
+func TestPartAttachment(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		disposition string
+		want        models.EmailAttachment
+		ok          bool
+	}{
+		{
+			name:        "attachment disposition",
+			contentType: "application/pdf",
+			disposition: `attachment; filename="invoice.pdf"`,
+			want:        models.EmailAttachment{Filename: "invoice.pdf", ContentType: "application/pdf"},
+			ok:          true,
+		},
+		{
+			name:        "inline image named by content type",
+			contentType: `image/png; name="logo.png"`,
+			disposition: "inline",
+			want:        models.EmailAttachment{Filename: "logo.png", ContentType: "image/png", IsInline: true},
+			ok:          true,
+		},
+		{
+			name:        "encoded word filename",
+			contentType: "application/pdf",
+			disposition: `attachment; filename="=?UTF-8?Q?caf=C3=A9_menu.pdf?="`,
+			want:        models.EmailAttachment{Filename: "café menu.pdf", ContentType: "application/pdf"},
+			ok:          true,
+		},
+		{
+			name:        "windows path",
+			contentType: "application/octet-stream",
+			disposition: `attachment; filename="C:\\Users\\me\\receipt.pdf"`,
+			want:        models.EmailAttachment{Filename: "receipt.pdf", ContentType: "application/octet-stream"},
+			ok:          true,
+		},
+		{
+			name:        "rfc 2231 filename",
+			contentType: "",
+			disposition: "attachment; filename*=UTF-8''order%20form.docx",
+			want:        models.EmailAttachment{Filename: "order form.docx", ContentType: defaultAttachmentContentType},
+			ok:          true,
+		},
+		{
+			name:        "unnamed attachment",
+			contentType: "text/csv",
+			disposition: "attachment",
+			want:        models.EmailAttachment{ContentType: "text/csv"},
+			ok:          true,
+		},
+		{
+			name:        "inline body text",
+			contentType: "text/plain; charset=utf-8",
+			disposition: "inline",
+		},
+		{
+			name:        "plain body part",
+			contentType: "text/html",
+		},
+		{
+			name:        "nested multipart",
+			contentType: `multipart/alternative; boundary="inner"`,
+			disposition: `attachment; filename="x"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := partAttachment(tt.contentType, tt.disposition)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStoreAttachments(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	email := &models.Email{ID: 12, Attachments: []models.EmailAttachment{
+		{Filename: "invoice.pdf", ContentType: "application/pdf"},
+		{Filename: "logo.png", ContentType: "image/png", IsInline: true},
+	}}
+	mock.ExpectExec(`DELETE FROM email_attachments WHERE email_id = \$1\s+\)\s+INSERT INTO email_attachments \(email_id, filename, content_type, is_inline\)`).
+		WithArgs(12, `{"invoice.pdf","logo.png"}`, `{"application/pdf","image/png"}`, "{f,t}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	require.NoError(t, service.storeAttachments(email))
+
+	// Emails without attachments don't touch the table
+	require.NoError(t, service.storeAttachments(&models.Email{ID: 13}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadAttachments(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	emails := []models.Email{{ID: 5}, {ID: 9}}
+	expectAttachments(mock, "{5,9}", attachmentRows().
+		AddRow(9, "invoice.pdf", "application/pdf", false).
+		AddRow(9, "photo.jpg", "image/jpeg", true))
+
+	require.NoError(t, service.loadAttachments(emails))
+	assert.Empty(t, emails[0].Attachments)
+	require.Len(t, emails[1].Attachments, 2)
+	assert.Equal(t, "photo.jpg", emails[1].Attachments[1].Filename)
+	assert.True(t, emails[1].Attachments[1].IsInline)
+	assert.Equal(t, []string{"invoice.pdf", "photo.jpg"}, attachmentNames(emails[1].Attachments))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
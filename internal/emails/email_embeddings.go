@@ -3,15 +3,21 @@ package emails
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"ids/internal/analytics"
 	"ids/internal/cache"
 	"ids/internal/config"
 	"ids/internal/database"
 	"ids/internal/models"
+	idsopenai "ids/internal/openai"
+	"ids/internal/utils"
 	"ids/internal/vectordb"
 
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -29,28 +35,56 @@ type EmailEmbeddingService struct {
 	db           *database.WriteClient
 	cache        *cache.Cache
 	qdrantClient *vectordb.QdrantClient // Qdrant client for dual-write (optional)
+	analytics    *analytics.Service     // Tracks thread summarization usage (optional)
+	dimensions   int                    // Embedding vector size probed from the embedding model
+	metric       string                 // pgvector distance metric: cosine, l2 or inner_product (VECTOR_DISTANCE_METRIC)
+
+	documentPrefix string // Prepended to email/thread text before embedding (EMBEDDING_INPUT_PREFIX)
+	queryPrefix    string // Prepended to search queries before embedding (QUERY_INPUT_PREFIX)
+
+	granularity     string // Which embeddings are generated: individual, thread or both (EMAIL_EMBEDDING_GRANULARITY)
+	threadMinEmails int    // Minimum thread size for a thread embedding (EMAIL_THREAD_EMBEDDING_MIN_EMAILS)
 }
 
 // NewEmailEmbeddingService creates a new email embedding service
 // embeddingCache: Optional cache for query embeddings (can be nil)
 func NewEmailEmbeddingService(cfg *config.Config, writeClient *database.WriteClient, embeddingCache ...*cache.Cache) (*EmailEmbeddingService, error) {
+	if err := idsopenai.ValidateEmbeddingDimensions(string(openai.SmallEmbedding3), cfg.EmbeddingDimensions); err != nil {
+		return nil, err
+	}
+
 	client := openai.NewClient(cfg.OpenAIKey)
 
 	// Test the connection
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+	resp, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
 		Input: []string{"test"},
 		Model: openai.SmallEmbedding3,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to OpenAI API: %v", err)
 	}
+	if len(resp.Data) == 0 || len(resp.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embedding model %s returned an empty vector", openai.SmallEmbedding3)
+	}
+	dimensions := len(resp.Data[0].Embedding)
+	if err := idsopenai.CheckProbedDimensions(string(openai.SmallEmbedding3), dimensions, cfg.EmbeddingDimensions); err != nil {
+		return nil, err
+	}
 
 	service := &EmailEmbeddingService{
-		client: client,
-		db:     writeClient,
+		client:     client,
+		db:         writeClient,
+		dimensions: dimensions,
+		metric:     cfg.DistanceMetric,
+
+		documentPrefix: cfg.EmbeddingInputPrefix,
+		queryPrefix:    cfg.QueryInputPrefix,
+
+		granularity:     cfg.EmailEmbeddingGranularity,
+		threadMinEmails: cfg.EmailThreadMinEmails,
 	}
 
 	// Set cache if provided
@@ -69,82 +103,24 @@ func (ees *EmailEmbeddingService) SetQdrantClient(client *vectordb.QdrantClient)
 	}
 }
 
-// CreateEmailTables creates the necessary database tables (PostgreSQL-compatible with pgvector)
+// vectorDimensions returns the probed embedding size, defaulting to text-embedding-3-small's
+func (ees *EmailEmbeddingService) vectorDimensions() int {
+	if ees.dimensions > 0 {
+		return ees.dimensions
+	}
+	return vectordb.VectorDimensions
+}
+
+// CreateEmailTables applies the schema migrations, which create the email tables sized for the
+// embedding model, and ensures the email_embeddings vector index
 func (ees *EmailEmbeddingService) CreateEmailTables() error {
-	// Enable pgvector extension first
-	if _, err := ees.db.ExecuteWriteQuery(`CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
-		fmt.Printf("Warning: Failed to create vector extension (may already exist): %v\n", err)
-	}
-
-	queries := []string{
-		// Emails table
-		`CREATE TABLE IF NOT EXISTS emails (
-			id SERIAL PRIMARY KEY,
-			message_id VARCHAR(255) UNIQUE NOT NULL,
-			subject TEXT NOT NULL,
-			from_addr TEXT NOT NULL,
-			to_addr TEXT NOT NULL,
-			date TIMESTAMP NOT NULL,
-			body TEXT NOT NULL,
-			thread_id VARCHAR(255),
-			in_reply_to VARCHAR(255),
-			"references" TEXT,
-			is_customer BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-
-		// Email threads table
-		`CREATE TABLE IF NOT EXISTS email_threads (
-			thread_id VARCHAR(255) PRIMARY KEY,
-			subject TEXT NOT NULL,
-			email_count INT DEFAULT 1,
-			first_date TIMESTAMP NOT NULL,
-			last_date TIMESTAMP NOT NULL,
-			summary TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-
-		// Email embeddings table - using pgvector for 1536-dimensional embeddings
-		`CREATE TABLE IF NOT EXISTS email_embeddings (
-			id SERIAL PRIMARY KEY,
-			email_id INT,
-			thread_id VARCHAR(255),
-			embedding vector(1536) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE (email_id),
-			UNIQUE (thread_id),
-			FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
-		)`,
-	}
-
-	for _, query := range queries {
-		if _, err := ees.db.ExecuteWriteQuery(query); err != nil {
-			return fmt.Errorf("failed to create table: %w", err)
-		}
-	}
-
-	// Create indexes separately
-	indexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_emails_message_id ON emails(message_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_emails_thread_id ON emails(thread_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_emails_date ON emails(date)`,
-		`CREATE INDEX IF NOT EXISTS idx_emails_is_customer ON emails(is_customer)`,
-		`CREATE INDEX IF NOT EXISTS idx_email_threads_first_date ON email_threads(first_date)`,
-		`CREATE INDEX IF NOT EXISTS idx_email_threads_last_date ON email_threads(last_date)`,
-		// HNSW index for fast cosine similarity search with pgvector
-		// m=16: number of connections per layer (higher = better recall, more memory)
-		// ef_construction=100: size of dynamic candidate list for construction (higher = better index quality, slower build)
-		`CREATE INDEX IF NOT EXISTS idx_email_embeddings_hnsw ON email_embeddings USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 100)`,
-	}
-
-	for _, query := range indexes {
-		if _, err := ees.db.ExecuteWriteQuery(query); err != nil {
-			// Ignore errors for index creation (they might already exist)
-			fmt.Printf("Warning: Failed to create index: %v\n", err)
-		}
+	if _, err := ees.db.MigrateSchema(ees.vectorDimensions()); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	// HNSW index for fast similarity search with pgvector under VECTOR_DISTANCE_METRIC
+	if _, err := ees.db.ExecuteSchemaQuery(vectordb.HNSWIndexQuery("email_embeddings", ees.metric)); err != nil {
+		fmt.Printf("Warning: Failed to create index: %v\n", err)
 	}
 
 	return nil
@@ -169,10 +145,21 @@ func (ees *EmailEmbeddingService) StoreEmail(email *models.Email) error {
 			in_reply_to = EXCLUDED.in_reply_to,
 			"references" = EXCLUDED."references",
 			is_customer = EXCLUDED.is_customer,
-			updated_at = CURRENT_TIMESTAMP
+			updated_at = CASE
+				WHEN (emails.subject, emails.from_addr, emails.to_addr, emails.date, emails.body, emails.thread_id,
+				      emails.in_reply_to, emails."references", emails.is_customer)
+				     IS DISTINCT FROM
+				     (EXCLUDED.subject, EXCLUDED.from_addr, EXCLUDED.to_addr, EXCLUDED.date, EXCLUDED.body, EXCLUDED.thread_id,
+				      EXCLUDED.in_reply_to, EXCLUDED."references", EXCLUDED.is_customer)
+				THEN CURRENT_TIMESTAMP
+				ELSE emails.updated_at
+			END
+		RETURNING id
 	`
 
-	result, err := ees.db.ExecuteWriteQuery(query,
+	// RETURNING id lets callers embed exactly the emails they just stored; updated_at only moves
+	// when a re-imported email changed, so GenerateEmbeddingsForEmails can skip unchanged ones
+	err := ees.db.ExecuteWriteQuerySingle(&email.ID, query,
 		email.MessageID,
 		email.Subject,
 		email.From,
@@ -208,15 +195,14 @@ func (ees *EmailEmbeddingService) StoreEmail(email *models.Email) error {
 		return fmt.Errorf("failed to store email: %w", err)
 	}
 
-	// Check if this was an insert or update
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		// Email already exists and unchanged
-		return nil
-	}
-
 	// Update thread information
-	return ees.updateThread(threadID, email)
+	if err := ees.updateThread(threadID, email); err != nil {
+		return err
+	}
+	if err := ees.storeParticipants(threadID, email); err != nil {
+		return err
+	}
+	return ees.storeAttachments(email)
 }
 
 // updateThread updates or creates a thread entry
@@ -268,9 +254,142 @@ func (ees *EmailEmbeddingService) updateThread(threadID string, email *models.Em
 	return err
 }
 
+// MergeThreads folds fragmented threads into primaryID: their emails are reassigned to the
+// primary thread along with their participants, its count and dates are recomputed from the
+// emails, and the merged thread rows are deleted. Thread embeddings of all involved threads are dropped so the next
+// GenerateThreadEmbeddings run re-embeds the merged conversation.
+func (ees *EmailEmbeddingService) MergeThreads(primaryID string, otherIDs []string) error {
+	primaryID = strings.TrimSpace(primaryID)
+	if primaryID == "" {
+		return fmt.Errorf("primary thread ID is required")
+	}
+
+	seen := map[string]bool{primaryID: true}
+	var mergeIDs []string
+	for _, id := range otherIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		mergeIDs = append(mergeIDs, id)
+	}
+	if len(mergeIDs) == 0 {
+		return fmt.Errorf("no other threads to merge into %s", primaryID)
+	}
+
+	invalidated := append([]string{primaryID}, mergeIDs...)
+	var moved int64
+
+	err := ees.db.WithTransaction(func(tx *sqlx.Tx) error {
+		var exists bool
+		if err := tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM email_threads WHERE thread_id = $1)`, primaryID); err != nil {
+			return fmt.Errorf("failed to check primary thread: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("primary thread %s not found", primaryID)
+		}
+
+		result, err := tx.Exec(`
+			UPDATE emails
+			SET thread_id = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE thread_id = ANY($2)
+		`, primaryID, pq.Array(mergeIDs))
+		if err != nil {
+			return fmt.Errorf("failed to reassign emails: %w", err)
+		}
+		moved, _ = result.RowsAffected()
+
+		if _, err := tx.Exec(`
+			UPDATE email_threads et
+			SET email_count = stats.email_count,
+			    first_date = stats.first_date,
+			    last_date = stats.last_date,
+			    updated_at = CURRENT_TIMESTAMP
+			FROM (
+				SELECT COUNT(*) AS email_count, MIN(date) AS first_date, MAX(date) AS last_date
+				FROM emails
+				WHERE thread_id = $1
+			) stats
+			WHERE et.thread_id = $1
+		`, primaryID); err != nil {
+			return fmt.Errorf("failed to recompute primary thread: %w", err)
+		}
+
+		// Thread-level embeddings have email_id NULL; per-email embeddings stay valid
+		if _, err := tx.Exec(`DELETE FROM email_embeddings WHERE email_id IS NULL AND thread_id = ANY($1)`, pq.Array(invalidated)); err != nil {
+			return fmt.Errorf("failed to invalidate thread embeddings: %w", err)
+		}
+
+		// Participants of the merged threads move to the primary; their own rows cascade below
+		if _, err := tx.Exec(`
+			INSERT INTO thread_participants (thread_id, address)
+			SELECT $1, address FROM thread_participants WHERE thread_id = ANY($2)
+			ON CONFLICT (thread_id, address) DO NOTHING
+		`, primaryID, pq.Array(mergeIDs)); err != nil {
+			return fmt.Errorf("failed to merge thread participants: %w", err)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM email_threads WHERE thread_id = ANY($1)`, pq.Array(mergeIDs)); err != nil {
+			return fmt.Errorf("failed to delete merged threads: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ees.qdrantClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := ees.qdrantClient.DeleteEmailThreads(ctx, invalidated); err != nil {
+			// Log error but don't fail - PostgreSQL is the primary store
+			fmt.Printf("[EMAIL_THREADS] Warning: Failed to delete merged threads from Qdrant: %v\n", err)
+		}
+	}
+
+	fmt.Printf("[EMAIL_THREADS] Merged %d threads into %s (%d emails reassigned)\n", len(mergeIDs), primaryID, moved)
+	return nil
+}
+
+// RebuildThreadAggregates recomputes email_count, first_date and last_date of every thread
+// from the emails table and returns how many threads were corrected. Threads left without
+// emails keep their dates and get a count of 0.
+func (ees *EmailEmbeddingService) RebuildThreadAggregates() (int, error) {
+	result, err := ees.db.ExecuteWriteQuery(`
+		UPDATE email_threads et
+		SET email_count = stats.email_count,
+		    first_date = COALESCE(stats.first_date, et.first_date),
+		    last_date = COALESCE(stats.last_date, et.last_date),
+		    updated_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT t.thread_id, COUNT(e.id) AS email_count, MIN(e.date) AS first_date, MAX(e.date) AS last_date
+			FROM email_threads t
+			LEFT JOIN emails e ON e.thread_id = t.thread_id
+			GROUP BY t.thread_id
+		) stats
+		WHERE et.thread_id = stats.thread_id
+		  AND (et.email_count IS DISTINCT FROM stats.email_count
+		    OR et.first_date IS DISTINCT FROM COALESCE(stats.first_date, et.first_date)
+		    OR et.last_date IS DISTINCT FROM COALESCE(stats.last_date, et.last_date))
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rebuild thread aggregates: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rebuilt threads: %w", err)
+	}
+
+	fmt.Printf("[EMAIL_THREADS] Rebuilt aggregates for %d threads\n", updated)
+	return int(updated), nil
+}
+
 // EmailEmbeddingStats contains statistics about email embedding generation
 type EmailEmbeddingStats struct {
-	EmailsProcessed  int
+	EmailsProcessed  int // Emails embedded and stored
+	EmailsFailed     int // Emails whose embedding batch or store failed
 	ThreadsProcessed int
 	Success          bool
 }
@@ -292,13 +411,91 @@ func (ees *EmailEmbeddingService) GenerateEmailEmbeddingsWithStats() (*EmailEmbe
 		       e.body, e.thread_id, e.in_reply_to, e."references", e.is_customer
 		FROM emails e
 		LEFT JOIN email_embeddings ee ON ee.email_id = e.id
-		WHERE ee.id IS NULL
+		WHERE ee.id IS NULL` + ees.threadCoverageFilter() + `
+		ORDER BY e.date DESC
+	`
+
+	emails, err := ees.fetchEmails(query)
+	if err != nil {
+		return stats, err
+	}
+
+	fmt.Printf("[EMAIL_EMBEDDINGS] Found %d emails to process\n", len(emails))
+
+	stats.EmailsProcessed, stats.EmailsFailed = ees.processEmailsInBatches(emails)
+	if stats.EmailsFailed > 0 {
+		return stats, fmt.Errorf("failed to embed %d of %d emails", stats.EmailsFailed, len(emails))
+	}
+
+	fmt.Println("[EMAIL_EMBEDDINGS] Email embedding generation complete")
+	stats.Success = true
+	return stats, nil
+}
+
+// GenerateEmbeddingsForEmails generates embeddings only for the given email IDs that have no
+// embedding yet or changed since they were embedded (emails.updated_at, see StoreEmail)
+// Used by incremental imports so each batch embeds just the new or changed emails it stored,
+// instead of re-scanning the whole un-embedded set or re-embedding re-imported mail every time
+func (ees *EmailEmbeddingService) GenerateEmbeddingsForEmails(ids []int) (*EmailEmbeddingStats, error) {
+	stats := &EmailEmbeddingStats{}
+	if len(ids) == 0 {
+		stats.Success = true
+		return stats, nil
+	}
+
+	fmt.Printf("[EMAIL_EMBEDDINGS] Generating embeddings for %d stored emails...\n", len(ids))
+
+	query := `
+		SELECT e.id, e.message_id, e.subject, e.from_addr, e.to_addr, e.date,
+		       e.body, e.thread_id, e.in_reply_to, e."references", e.is_customer
+		FROM emails e
+		LEFT JOIN email_embeddings ee ON ee.email_id = e.id
+		WHERE e.id = ANY($1)
+		  AND (ee.id IS NULL OR e.updated_at > ee.updated_at)` + ees.threadCoverageFilter() + `
 		ORDER BY e.date DESC
 	`
 
-	rows, err := ees.db.GetDB().Query(query)
+	emails, err := ees.fetchEmails(query, pq.Array(ids))
 	if err != nil {
-		return stats, fmt.Errorf("failed to fetch emails: %w", err)
+		return stats, err
+	}
+	if skipped := len(ids) - len(emails); skipped > 0 {
+		fmt.Printf("[EMAIL_EMBEDDINGS] Skipping %d emails that are already embedded and unchanged (or covered by thread embeddings)\n", skipped)
+	}
+
+	stats.EmailsProcessed, stats.EmailsFailed = ees.processEmailsInBatches(emails)
+	if stats.EmailsFailed > 0 {
+		return stats, fmt.Errorf("failed to embed %d of %d emails", stats.EmailsFailed, len(emails))
+	}
+
+	fmt.Printf("[EMAIL_EMBEDDINGS] Embedded %d emails\n", stats.EmailsProcessed)
+	stats.Success = true
+	return stats, nil
+}
+
+// threadCoverageFilter returns the extra WHERE condition that, at "thread" granularity, skips
+// emails whose thread is large enough to get a thread embedding; empty for other granularities
+func (ees *EmailEmbeddingService) threadCoverageFilter() string {
+	if ees.granularity != config.EmailGranularityThread {
+		return ""
+	}
+	return fmt.Sprintf(`
+		  AND NOT EXISTS (
+			SELECT 1 FROM email_threads et
+			WHERE et.thread_id = e.thread_id AND et.email_count >= %d
+		  )`, ees.minThreadEmails())
+}
+
+// minThreadEmails returns the minimum thread size for a thread embedding (defaults to 2)
+func (ees *EmailEmbeddingService) minThreadEmails() int {
+	return max(ees.threadMinEmails, 2)
+}
+
+// fetchEmails runs an email SELECT and scans the rows into models.Email
+func (ees *EmailEmbeddingService) fetchEmails(query string, args ...interface{}) ([]models.Email, error) {
+	rows, err := ees.db.GetDB().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch emails: %w", err)
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -336,13 +533,20 @@ func (ees *EmailEmbeddingService) GenerateEmailEmbeddingsWithStats() (*EmailEmbe
 	}
 
 	if err = rows.Err(); err != nil {
-		return stats, fmt.Errorf("failed to iterate emails: %w", err)
+		return nil, fmt.Errorf("failed to iterate emails: %w", err)
 	}
 
-	fmt.Printf("[EMAIL_EMBEDDINGS] Found %d emails to process\n", len(emails))
-	stats.EmailsProcessed = len(emails)
+	// Attachment filenames are part of the embedded text
+	if err := ees.loadAttachments(emails); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
 
-	// Process in batches
+// processEmailsInBatches embeds emails in API-sized batches, continuing past failed batches,
+// and returns the number of emails embedded and failed
+func (ees *EmailEmbeddingService) processEmailsInBatches(emails []models.Email) (embedded, failed int) {
 	batchSize := 50
 	for i := 0; i < len(emails); i += batchSize {
 		end := i + batchSize
@@ -353,23 +557,23 @@ func (ees *EmailEmbeddingService) GenerateEmailEmbeddingsWithStats() (*EmailEmbe
 		batch := emails[i:end]
 		fmt.Printf("[EMAIL_EMBEDDINGS] Processing batch %d-%d...\n", i+1, end)
 
-		if err := ees.processEmailBatch(batch); err != nil {
+		stored, err := ees.processEmailBatch(batch)
+		if err != nil {
 			fmt.Printf("[EMAIL_EMBEDDINGS] Error processing batch: %v\n", err)
 			// Continue with next batch
 		}
+		embedded += stored
+		failed += len(batch) - stored
 	}
-
-	fmt.Println("[EMAIL_EMBEDDINGS] Email embedding generation complete")
-	stats.Success = true
-	return stats, nil
+	return embedded, failed
 }
 
-// processEmailBatch processes a batch of emails and generates embeddings
-func (ees *EmailEmbeddingService) processEmailBatch(emails []models.Email) error {
+// processEmailBatch generates and stores embeddings for a batch of emails and returns how many were stored
+func (ees *EmailEmbeddingService) processEmailBatch(emails []models.Email) (int, error) {
 	// Build texts for embedding
 	texts := make([]string, len(emails))
 	for i, email := range emails {
-		texts[i] = ees.buildEmailText(email)
+		texts[i] = idsopenai.WithInputPrefix(ees.documentPrefix, ees.buildEmailText(email))
 	}
 
 	// Generate embeddings
@@ -381,10 +585,11 @@ func (ees *EmailEmbeddingService) processEmailBatch(emails []models.Email) error
 		Model: openai.SmallEmbedding3,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to generate embeddings: %w", err)
+		return 0, fmt.Errorf("failed to generate embeddings: %w", err)
 	}
 
 	// Store embeddings
+	stored := 0
 	for i, embeddingData := range resp.Data {
 		email := emails[i]
 		embedding := make([]float64, len(embeddingData.Embedding))
@@ -394,10 +599,12 @@ func (ees *EmailEmbeddingService) processEmailBatch(emails []models.Email) error
 
 		if err := ees.storeEmailEmbedding(email.ID, nil, embedding); err != nil {
 			fmt.Printf("[EMAIL_EMBEDDINGS] Failed to store embedding for email %d: %v\n", email.ID, err)
+			continue
 		}
+		stored++
 	}
 
-	return nil
+	return stored, nil
 }
 
 // GenerateThreadEmbeddings generates embeddings for email threads
@@ -408,6 +615,10 @@ func (ees *EmailEmbeddingService) GenerateThreadEmbeddings() error {
 
 // GenerateThreadEmbeddingsWithStats generates thread embeddings and returns statistics
 func (ees *EmailEmbeddingService) GenerateThreadEmbeddingsWithStats() (int, error) {
+	if ees.granularity == config.EmailGranularityIndividual {
+		fmt.Println("[THREAD_EMBEDDINGS] Skipped - EMAIL_EMBEDDING_GRANULARITY is individual")
+		return 0, nil
+	}
 	fmt.Println("[THREAD_EMBEDDINGS] Starting thread embedding generation...")
 
 	// Get threads without thread-level embeddings
@@ -416,11 +627,11 @@ func (ees *EmailEmbeddingService) GenerateThreadEmbeddingsWithStats() (int, erro
 		SELECT et.thread_id, et.subject, et.email_count, et.first_date, et.last_date
 		FROM email_threads et
 		LEFT JOIN email_embeddings ee ON ee.thread_id = et.thread_id AND ee.email_id IS NULL
-		WHERE ee.id IS NULL AND et.email_count >= 2
+		WHERE ee.id IS NULL AND et.email_count >= $1
 		ORDER BY et.last_date DESC
 	`
 
-	rows, err := ees.db.GetDB().Query(query)
+	rows, err := ees.db.GetDB().Query(query, ees.minThreadEmails())
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch threads: %w", err)
 	}
@@ -469,6 +680,46 @@ func (ees *EmailEmbeddingService) GenerateThreadEmbeddingsWithStats() (int, erro
 // generateThreadEmbedding generates an embedding for a complete thread
 func (ees *EmailEmbeddingService) generateThreadEmbedding(threadID string) error {
 	// Get all emails in thread
+	emails, err := ees.GetThreadEmails(context.Background(), threadID)
+	if err != nil {
+		return err
+	}
+
+	if len(emails) == 0 {
+		return nil
+	}
+
+	// Build thread text (conversation flow)
+	text := idsopenai.WithInputPrefix(ees.documentPrefix, ees.buildThreadText(emails))
+
+	// Generate embedding
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := ees.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: []string{text},
+		Model: openai.SmallEmbedding3,
+	})
+	if err != nil {
+		return err
+	}
+
+	embedding := make([]float64, len(resp.Data[0].Embedding))
+	for j, v := range resp.Data[0].Embedding {
+		embedding[j] = float64(v)
+	}
+
+	return ees.storeEmailEmbedding(0, &threadID, embedding)
+}
+
+// GetThreadEmails retrieves all emails in a thread, oldest first
+func (ees *EmailEmbeddingService) GetThreadEmails(ctx context.Context, threadID string) ([]models.Email, error) {
+	return ThreadEmails(ctx, ees.db, threadID, 0)
+}
+
+// ThreadEmails retrieves the first limit emails of a thread (all of them when limit is 0), oldest first
+// It only needs the database, so callers without an embedding service can load thread details.
+func ThreadEmails(ctx context.Context, db *database.WriteClient, threadID string, limit int) ([]models.Email, error) {
 	query := `
 		SELECT id, message_id, subject, from_addr, to_addr, date, body, thread_id, 
 		       in_reply_to, "references", is_customer
@@ -476,10 +727,15 @@ func (ees *EmailEmbeddingService) generateThreadEmbedding(threadID string) error
 		WHERE thread_id = $1
 		ORDER BY date ASC
 	`
+	args := []interface{}{threadID}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
 
-	rows, err := ees.db.GetDB().Query(query, threadID)
+	rows, err := db.GetDB().QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -506,7 +762,7 @@ func (ees *EmailEmbeddingService) generateThreadEmbedding(threadID string) error
 			&email.IsCustomer,
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		email.ThreadID = threadIDPtr
@@ -516,34 +772,10 @@ func (ees *EmailEmbeddingService) generateThreadEmbedding(threadID string) error
 	}
 
 	if err = rows.Err(); err != nil {
-		return err
-	}
-
-	if len(emails) == 0 {
-		return nil
-	}
-
-	// Build thread text (conversation flow)
-	text := ees.buildThreadText(emails)
-
-	// Generate embedding
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	resp, err := ees.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-		Input: []string{text},
-		Model: openai.SmallEmbedding3,
-	})
-	if err != nil {
-		return err
+		return nil, err
 	}
 
-	embedding := make([]float64, len(resp.Data[0].Embedding))
-	for j, v := range resp.Data[0].Embedding {
-		embedding[j] = float64(v)
-	}
-
-	return ees.storeEmailEmbedding(0, &threadID, embedding)
+	return emails, nil
 }
 
 // buildEmailText creates text representation for a single email
@@ -558,8 +790,13 @@ func (ees *EmailEmbeddingService) buildEmailText(email models.Email) string {
 		parts = append(parts, "From: Support")
 	}
 
-	// Clean and truncate body
-	body := email.Body
+	// Filenames let searches like "the email with the invoice PDF" find the email
+	if names := attachmentNames(email.Attachments); len(names) > 0 {
+		parts = append(parts, "Attachments: "+strings.Join(names, ", "))
+	}
+
+	// Drop quoted prior messages, then clean and truncate body
+	body := stripQuotedText(email.Body)
 	body = strings.TrimSpace(body)
 	if len(body) > 2000 {
 		body = body[:2000] + "..."
@@ -583,7 +820,8 @@ func (ees *EmailEmbeddingService) buildThreadText(emails []models.Email) string
 			role = "Support"
 		}
 
-		body := strings.TrimSpace(email.Body)
+		// Each reply contributes only its new text; quoted history is already in the thread
+		body := strings.TrimSpace(stripQuotedText(email.Body))
 		if len(body) > 500 {
 			body = body[:500] + "..."
 		}
@@ -597,6 +835,10 @@ func (ees *EmailEmbeddingService) buildThreadText(emails []models.Email) string
 // storeEmailEmbedding stores an embedding for an email or thread using pgvector
 // Also writes thread embeddings to Qdrant if dual-write is enabled
 func (ees *EmailEmbeddingService) storeEmailEmbedding(emailID int, threadID *string, embedding []float64) error {
+	if ees.dimensions > 0 && len(embedding) != ees.dimensions {
+		return fmt.Errorf("email embedding has %d dimensions, expected %d", len(embedding), ees.dimensions)
+	}
+
 	// Convert embedding to pgvector format
 	embeddingStr := formatVectorForPgvector(embedding)
 
@@ -673,50 +915,81 @@ func formatFloat32VectorForPgvector(embedding []float32) string {
 
 // SearchSimilarEmails finds emails or threads similar to a query using pgvector
 func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, searchThreads bool) ([]models.EmailSearchResult, error) {
-	searchType := "individual emails"
-	if searchThreads {
-		searchType = "email threads"
+	return ees.SearchSimilarEmailsFiltered(query, limit, searchThreads, EmailSearchFilters{})
+}
+
+// SearchSimilarEmailsFiltered is SearchSimilarEmails restricted by filters (date range, customer
+// emails, sender); an empty filter runs the same query as SearchSimilarEmails
+func (ees *EmailEmbeddingService) SearchSimilarEmailsFiltered(query string, limit int, searchThreads bool, filters EmailSearchFilters) ([]models.EmailSearchResult, error) {
+	if isEmptyQuery(query) {
+		return []models.EmailSearchResult{}, nil
 	}
-	fmt.Printf("[EMAIL_EMBEDDINGS] 🔍 Querying EMAIL EMBEDDINGS datasource - Query: '%s', Limit: %d, Type: %s\n", query, limit, searchType)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	fmt.Printf("[EMAIL_EMBEDDINGS] 🔍 Querying EMAIL EMBEDDINGS datasource - Query: '%s', Limit: %d, Type: %s\n", query, limit, emailSearchType(searchThreads))
 
-	// Try to get embedding from cache first
-	var queryEmbedding []float32
-	if ees.cache != nil {
-		if cachedEmbedding, found := ees.cache.GetEmbedding(query); found {
-			fmt.Printf("[EMAIL_EMBEDDINGS] ✓ Cache HIT - using cached query embedding\n")
-			queryEmbedding = cachedEmbedding
-		}
+	queryVectorStr, err := ees.queryVector(query)
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate embedding if not in cache
-	if queryEmbedding == nil {
-		fmt.Printf("[EMAIL_EMBEDDINGS] Generating query embedding...\n")
-		resp, err := ees.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-			Input: []string{query},
-			Model: openai.SmallEmbedding3,
-		})
-		if err != nil {
-			fmt.Printf("[EMAIL_EMBEDDINGS] ❌ ERROR: Failed to generate query embedding: %v\n", err)
-			return nil, err
-		}
-		queryEmbedding = resp.Data[0].Embedding
+	return ees.searchByVector(queryVectorStr, limit, searchThreads, filters)
+}
 
-		// Store in cache for future requests
-		if ees.cache != nil {
-			ees.cache.SetEmbedding(query, queryEmbedding)
-			fmt.Printf("[EMAIL_EMBEDDINGS] ✓ Cached query embedding for future use\n")
-		}
+// SearchSimilarEmailsAndThreads searches individual email and thread embeddings with one query
+// embedding and merges them into a single list ranked by similarity, so callers get the most
+// relevant items whether they are standalone emails or whole threads. Each result's Kind tells
+// them apart; limit caps the combined list.
+func (ees *EmailEmbeddingService) SearchSimilarEmailsAndThreads(query string, limit int, filters EmailSearchFilters) ([]models.EmailSearchResult, error) {
+	if isEmptyQuery(query) {
+		return []models.EmailSearchResult{}, nil
 	}
+	fmt.Printf("[EMAIL_EMBEDDINGS] 🔍 Querying EMAIL EMBEDDINGS datasource - Query: '%s', Limit: %d, Type: emails and threads\n", query, limit)
 
-	fmt.Printf("[EMAIL_EMBEDDINGS] Query embedding ready (dimensions: %d)\n", len(queryEmbedding))
+	queryVectorStr, err := ees.queryVector(query)
+	if err != nil {
+		return nil, err
+	}
 
-	// Convert query embedding to pgvector format
-	queryVectorStr := formatFloat32VectorForPgvector(queryEmbedding)
+	// Either kind may fill the whole combined limit
+	threads, err := ees.searchByVector(queryVectorStr, limit, true, filters)
+	if err != nil {
+		return nil, err
+	}
+	individual, err := ees.searchByVector(queryVectorStr, limit, false, filters)
+	if err != nil {
+		return nil, err
+	}
 
-	// Use pgvector for similarity search - database calculates similarity
+	return mergeSearchResults(threads, individual, limit), nil
+}
+
+// mergeSearchResults combines thread and email results into one list sorted by similarity
+// (threads first on ties, as they carry more context), truncated to limit when positive
+func mergeSearchResults(threads, emails []models.EmailSearchResult, limit int) []models.EmailSearchResult {
+	merged := make([]models.EmailSearchResult, 0, len(threads)+len(emails))
+	merged = append(merged, threads...)
+	merged = append(merged, emails...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Similarity > merged[j].Similarity
+	})
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// emailSearchType describes a search for logging
+func emailSearchType(searchThreads bool) string {
+	if searchThreads {
+		return "email threads"
+	}
+	return "individual emails"
+}
+
+// searchByVector runs the pgvector search for a formatted query vector
+func (ees *EmailEmbeddingService) searchByVector(queryVectorStr string, limit int, searchThreads bool, filters EmailSearchFilters) ([]models.EmailSearchResult, error) {
+	// Use pgvector for similarity search - database calculates the distance, which is
+	// normalized to a 0-1 similarity below (VECTOR_DISTANCE_METRIC)
 	// CTE-based queries for better performance with HNSW index
 	var dbQuery string
 	if searchThreads {
@@ -725,16 +998,16 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 		dbQuery = `
 			WITH ranked_threads AS (
 				SELECT thread_id,
-				       1 - (embedding <=> $1::vector) AS similarity
+				       embedding <=> $1::vector AS distance
 				FROM email_embeddings
-				WHERE thread_id IS NOT NULL
+				WHERE thread_id IS NOT NULL` + filterSQL(filters, threadFilterPredicates) + `
 				ORDER BY embedding <=> $1::vector
 				LIMIT $2
 			)
 			SELECT '' as embedding_str, e.id, e.message_id, e.subject, e.from_addr, e.to_addr,
 			       e.date, e.body, e.thread_id, e.is_customer,
 			       et.thread_id, et.subject, et.email_count, et.first_date, et.last_date,
-			       rt.similarity
+			       COALESCE(et.summary, ''), rt.distance
 			FROM ranked_threads rt
 			JOIN email_threads et ON et.thread_id = rt.thread_id
 			JOIN LATERAL (
@@ -743,21 +1016,26 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 				ORDER BY date DESC 
 				LIMIT 1
 			) e ON true
-			ORDER BY rt.similarity DESC
+			ORDER BY rt.distance
 		`
 	} else {
 		dbQuery = `
 			SELECT ee.embedding::text, e.id, e.message_id, e.subject, e.from_addr, e.to_addr,
 			       e.date, e.body, e.thread_id, e.is_customer,
-			       1 - (ee.embedding <=> $1::vector) AS similarity
+			       ee.embedding <=> $1::vector AS distance
 			FROM email_embeddings ee
 			JOIN emails e ON e.id = ee.email_id
-			WHERE ee.email_id IS NOT NULL
+			WHERE ee.email_id IS NOT NULL` + filterSQL(filters, emailFilterPredicates) + `
 			ORDER BY ee.embedding <=> $1::vector
 			LIMIT $2
 		`
 	}
 
+	queryArgs := []interface{}{queryVectorStr, limit}
+	if !filters.IsEmpty() {
+		queryArgs = append(queryArgs, filters.args()...)
+	}
+
 	var rows interface{ Close() error }
 	var scanErr error
 
@@ -765,7 +1043,7 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 
 	if searchThreads {
 		// Thread search with CTE - uses limit parameter
-		rowsResult, err := ees.db.GetDB().Query(dbQuery, queryVectorStr, limit)
+		rowsResult, err := ees.db.GetDB().Query(vectordb.WithDistanceOperator(dbQuery, ees.metric), queryArgs...)
 		if err != nil {
 			return nil, err
 		}
@@ -782,14 +1060,15 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 			var threadID, threadSubject *string
 			var emailCount *int
 			var firstDate, lastDate *time.Time
-			var similarity float64
+			var threadSummary string
+			var distance float64
 
 			scanErr = rowsResult.Scan(
 				&embeddingStr,
 				&email.ID, &email.MessageID, &email.Subject, &email.From, &email.To,
 				&email.Date, &email.Body, &email.ThreadID, &email.IsCustomer,
 				&threadID, &threadSubject, &emailCount, &firstDate, &lastDate,
-				&similarity,
+				&threadSummary, &distance,
 			)
 
 			if scanErr != nil {
@@ -799,17 +1078,29 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 
 			result := models.EmailSearchResult{
 				Email:      email,
-				Similarity: similarity,
+				Kind:       models.EmailResultThread,
+				Similarity: vectordb.NormalizeSimilarity(ees.metric, distance),
 				Embedding:  nil, // Don't need to store embedding in results
 			}
 
 			if threadID != nil {
+				// Aggregates can be NULL on partially imported threads; fall back to the latest email
 				result.Thread = &models.EmailThread{
-					ThreadID:   *threadID,
-					Subject:    *threadSubject,
-					EmailCount: *emailCount,
-					FirstDate:  *firstDate,
-					LastDate:   *lastDate,
+					ThreadID: *threadID,
+					Subject:  email.Subject,
+					Summary:  threadSummary,
+				}
+				if threadSubject != nil && *threadSubject != "" {
+					result.Thread.Subject = *threadSubject
+				}
+				if emailCount != nil {
+					result.Thread.EmailCount = *emailCount
+				}
+				if firstDate != nil {
+					result.Thread.FirstDate = *firstDate
+				}
+				if lastDate != nil {
+					result.Thread.LastDate = *lastDate
 				}
 			}
 
@@ -818,7 +1109,7 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 		// Results are already sorted by similarity and limited by the CTE query
 	} else {
 		// Individual email search with pgvector ORDER BY
-		rowsResult, err := ees.db.GetDB().Query(dbQuery, queryVectorStr, limit)
+		rowsResult, err := ees.db.GetDB().Query(vectordb.WithDistanceOperator(dbQuery, ees.metric), queryArgs...)
 		if err != nil {
 			return nil, err
 		}
@@ -832,13 +1123,13 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 		for rowsResult.Next() {
 			var embeddingStr string
 			var email models.Email
-			var similarity float64
+			var distance float64
 
 			scanErr = rowsResult.Scan(
 				&embeddingStr,
 				&email.ID, &email.MessageID, &email.Subject, &email.From, &email.To,
 				&email.Date, &email.Body, &email.ThreadID, &email.IsCustomer,
-				&similarity,
+				&distance,
 			)
 
 			if scanErr != nil {
@@ -848,7 +1139,8 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 
 			result := models.EmailSearchResult{
 				Email:      email,
-				Similarity: similarity,
+				Kind:       models.EmailResultEmail,
+				Similarity: vectordb.NormalizeSimilarity(ees.metric, distance),
 				Embedding:  nil, // Don't need to store embedding in results
 			}
 
@@ -856,10 +1148,63 @@ func (ees *EmailEmbeddingService) SearchSimilarEmails(query string, limit int, s
 		}
 	}
 
-	fmt.Printf("[EMAIL_EMBEDDINGS] ✅ EMAIL EMBEDDINGS query complete - Returning %d %s\n", len(results), searchType)
+	fmt.Printf("[EMAIL_EMBEDDINGS] ✅ EMAIL EMBEDDINGS query complete - Returning %d %s\n", len(results), emailSearchType(searchThreads))
 	if len(results) > 0 {
 		fmt.Printf("[EMAIL_EMBEDDINGS] Top result similarity: %.3f\n", results[0].Similarity)
 	}
 
 	return results, nil
 }
+
+// isEmptyQuery reports (and logs) a query that is empty after normalization;
+// embedding it would waste an API call and only return noise
+func isEmptyQuery(query string) bool {
+	if utils.NormalizeQuery(query) != "" {
+		return false
+	}
+	fmt.Printf("[EMAIL_EMBEDDINGS] Skipping search: query is empty after normalization\n")
+	return true
+}
+
+// queryVector embeds a search query (using the query embedding cache) in pgvector format
+func (ees *EmailEmbeddingService) queryVector(query string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Cache by the prefixed input so a QUERY_INPUT_PREFIX change never reuses stale vectors
+	input := idsopenai.WithInputPrefix(ees.queryPrefix, query)
+
+	// Try to get embedding from cache first
+	var queryEmbedding []float32
+	if ees.cache != nil {
+		if cachedEmbedding, found := ees.cache.GetEmbedding(string(openai.SmallEmbedding3), input); found {
+			fmt.Printf("[EMAIL_EMBEDDINGS] ✓ Cache HIT - using cached query embedding\n")
+			queryEmbedding = cachedEmbedding
+		}
+	}
+
+	// Generate embedding if not in cache
+	if queryEmbedding == nil {
+		fmt.Printf("[EMAIL_EMBEDDINGS] Generating query embedding...\n")
+		resp, err := ees.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input: []string{input},
+			Model: openai.SmallEmbedding3,
+		})
+		if err != nil {
+			fmt.Printf("[EMAIL_EMBEDDINGS] ❌ ERROR: Failed to generate query embedding: %v\n", err)
+			return "", err
+		}
+		queryEmbedding = resp.Data[0].Embedding
+
+		// Store in cache for future requests
+		if ees.cache != nil {
+			ees.cache.SetEmbedding(string(openai.SmallEmbedding3), input, queryEmbedding)
+			fmt.Printf("[EMAIL_EMBEDDINGS] ✓ Cached query embedding for future use\n")
+		}
+	}
+
+	fmt.Printf("[EMAIL_EMBEDDINGS] Query embedding ready (dimensions: %d)\n", len(queryEmbedding))
+
+	// Convert query embedding to pgvector format
+	return formatFloat32VectorForPgvector(queryEmbedding), nil
+}
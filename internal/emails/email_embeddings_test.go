@@ -0,0 +1,537 @@
+package emails
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeOpenAIServer returns a server answering /embeddings with one vector per input
+func newFakeOpenAIServer(t *testing.T, requestedInputs *[][]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if requestedInputs != nil {
+			*requestedInputs = append(*requestedInputs, req.Input)
+		}
+
+		data := make([]map[string]interface{}, len(req.Input))
+		for i := range req.Input {
+			data[i] = map[string]interface{}{
+				"object":    "embedding",
+				"index":     i,
+				"embedding": []float32{0.1, 0.2, 0.3},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"data":   data,
+			"model":  "text-embedding-3-small",
+		})
+	}))
+}
+
+// newTestEmailService builds an EmailEmbeddingService backed by sqlmock and a fake OpenAI server
+func newTestEmailService(t *testing.T, requestedInputs *[][]string) (*EmailEmbeddingService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	server := newFakeOpenAIServer(t, requestedInputs)
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+
+	return &EmailEmbeddingService{
+		client: openai.NewClientWithConfig(clientConfig),
+		db:     database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")),
+	}, mock
+}
+
+func TestGenerateEmbeddingsForEmails_OnlyEmbedsSpecifiedEmails(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+
+	now := time.Now()
+	columns := []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+	mock.ExpectQuery(`WHERE e.id = ANY\(\$1\)\s+AND \(ee.id IS NULL OR e.updated_at > ee.updated_at\)`).
+		WithArgs("{3,7}").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "<a@x>", "Holster question", "a@x.com", "support@ids.com", now, "Do you ship holsters?", nil, nil, nil, true).
+			AddRow(7, "<b@x>", "Re: Holster question", "support@ids.com", "a@x.com", now, "Yes we do.", nil, nil, nil, false))
+	expectAttachments(mock, "{3,7}", attachmentRows().AddRow(3, "invoice-1042.pdf", "application/pdf", false))
+
+	mock.ExpectExec(`INSERT INTO email_embeddings \(email_id, embedding\)`).
+		WithArgs(3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO email_embeddings \(email_id, embedding\)`).
+		WithArgs(7, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	stats, err := service.GenerateEmbeddingsForEmails([]int{3, 7})
+	require.NoError(t, err)
+
+	assert.True(t, stats.Success)
+	assert.Equal(t, 2, stats.EmailsProcessed)
+	require.Len(t, requestedInputs, 1)
+	require.Len(t, requestedInputs[0], 2)
+	assert.Contains(t, requestedInputs[0][0], "Attachments: invoice-1042.pdf")
+	assert.NotContains(t, requestedInputs[0][1], "Attachments:")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenerateEmbeddingsForEmails_EmptyIDs(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+
+	stats, err := service.GenerateEmbeddingsForEmails(nil)
+	require.NoError(t, err)
+
+	assert.True(t, stats.Success)
+	assert.Equal(t, 0, stats.EmailsProcessed)
+	assert.Empty(t, requestedInputs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenerateEmbeddingsForEmails_SkipsUnchangedEmails(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+
+	// Email 3 was re-imported unchanged, so the diff query only returns the new email 7
+	now := time.Now()
+	columns := []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+	mock.ExpectQuery(`LEFT JOIN email_embeddings ee ON ee.email_id = e.id\s+WHERE e.id = ANY\(\$1\)\s+AND \(ee.id IS NULL OR e.updated_at > ee.updated_at\)`).
+		WithArgs("{3,7}").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(7, "<b@x>", "Re: Holster question", "support@ids.com", "a@x.com", now, "Yes we do.", nil, nil, nil, false))
+	expectAttachments(mock, "{7}", attachmentRows())
+	mock.ExpectExec(`INSERT INTO email_embeddings \(email_id, embedding\)`).
+		WithArgs(7, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	stats, err := service.GenerateEmbeddingsForEmails([]int{3, 7})
+	require.NoError(t, err)
+
+	assert.True(t, stats.Success)
+	assert.Equal(t, 1, stats.EmailsProcessed)
+	require.Len(t, requestedInputs, 1)
+	assert.Len(t, requestedInputs[0], 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenerateEmbeddingsForEmails_ReportsFailedBatches(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"rate limited"}}`, http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	service.client = openai.NewClientWithConfig(clientConfig)
+
+	now := time.Now()
+	columns := []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+	mock.ExpectQuery(`WHERE e.id = ANY\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "<a@x>", "Holster question", "a@x.com", "support@ids.com", now, "Do you ship holsters?", nil, nil, nil, true))
+	expectAttachments(mock, "{3}", attachmentRows())
+
+	stats, err := service.GenerateEmbeddingsForEmails([]int{3})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to embed 1 of 1 emails")
+
+	assert.False(t, stats.Success)
+	assert.Zero(t, stats.EmailsProcessed)
+	assert.Equal(t, 1, stats.EmailsFailed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStoreEmail_ReimportKeepsUpdatedAtUnlessChanged(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	email := &models.Email{MessageID: "<a@x>", Subject: "Holster question", From: "a@x.com", To: "support@ids.com", Date: time.Now(), Body: "Do you ship holsters?", IsCustomer: true}
+
+	// updated_at only moves when the stored content differs, so unchanged re-imports aren't re-embedded
+	mock.ExpectQuery(`ON CONFLICT \(message_id\) DO UPDATE SET[\s\S]+updated_at = CASE\s+WHEN \(emails.subject[\s\S]+IS DISTINCT FROM[\s\S]+THEN CURRENT_TIMESTAMP\s+ELSE emails.updated_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM email_threads`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`INSERT INTO email_threads`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO thread_participants`).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	require.NoError(t, service.StoreEmail(email))
+	assert.Equal(t, 5, email.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStoreEmailEmbedding_RejectsDimensionMismatch(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	service.dimensions = 1536
+
+	err := service.storeEmailEmbedding(3, nil, []float64{0.1, 0.2, 0.3})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected 1536")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenerateEmbeddingsForEmails_AppliesDocumentPrefix(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+	service.documentPrefix = "passage:"
+	service.queryPrefix = "query:"
+
+	now := time.Now()
+	columns := []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+	mock.ExpectQuery(`WHERE e.id = ANY\(\$1\)\s+AND \(ee.id IS NULL OR e.updated_at > ee.updated_at\)`).
+		WithArgs("{3}").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "<a@x>", "Holster question", "a@x.com", "support@ids.com", now, "Do you ship holsters?", nil, nil, nil, true))
+	expectAttachments(mock, "{3}", attachmentRows())
+	mock.ExpectExec(`INSERT INTO email_embeddings \(email_id, embedding\)`).
+		WithArgs(3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err := service.GenerateEmbeddingsForEmails([]int{3})
+	require.NoError(t, err)
+
+	require.Len(t, requestedInputs, 1)
+	require.Len(t, requestedInputs[0], 1)
+	assert.True(t, strings.HasPrefix(requestedInputs[0][0], "passage: "), requestedInputs[0][0])
+	assert.NotContains(t, requestedInputs[0][0], "query:")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilarEmails_AppliesQueryPrefix(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+	service.documentPrefix = "passage:"
+	service.queryPrefix = "query:"
+
+	mock.ExpectQuery(`FROM email_embeddings ee`).
+		WillReturnRows(sqlmock.NewRows([]string{"embedding", "id"}))
+
+	_, err := service.SearchSimilarEmails("glock holster", 5, false)
+	require.NoError(t, err)
+
+	require.Len(t, requestedInputs, 1)
+	assert.Equal(t, []string{"query: glock holster"}, requestedInputs[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilarEmails_ThreadRowWithNullAggregates(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	date := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	columns := []string{"embedding_str", "id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "is_customer",
+		"thread_id", "subject", "email_count", "first_date", "last_date", "summary", "distance"}
+	mock.ExpectQuery(`WITH ranked_threads AS`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("", 4, "<4@x>", "Re: Holster sizing", "a@x.com", "support@ids.com", date, "Medium fits.", "thread-a", false,
+				"thread-a", nil, nil, nil, nil, "", 0.2).
+			AddRow("", 9, "<9@x>", "Sizing", "b@x.com", "support@ids.com", date, "Which size?", "thread-b", true,
+				"thread-b", "Plate carrier sizing", 3, date, date, "Sizing help", 0.3))
+
+	results, err := service.SearchSimilarEmails("holster sizing", 5, true)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// A thread missing its subject and aggregates still comes back, titled by its latest email
+	require.NotNil(t, results[0].Thread)
+	assert.Equal(t, "thread-a", results[0].Thread.ThreadID)
+	assert.Equal(t, "Re: Holster sizing", results[0].Thread.Subject)
+	assert.Zero(t, results[0].Thread.EmailCount)
+	assert.True(t, results[0].Thread.LastDate.IsZero())
+	assert.InDelta(t, 0.8, results[0].Similarity, 1e-9)
+	assert.Equal(t, models.EmailResultThread, results[0].Kind)
+
+	require.NotNil(t, results[1].Thread)
+	assert.Equal(t, "Plate carrier sizing", results[1].Thread.Subject)
+	assert.Equal(t, 3, results[1].Thread.EmailCount)
+	assert.Equal(t, date, results[1].Thread.FirstDate)
+	assert.Equal(t, "Sizing help", results[1].Thread.Summary)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilarEmailsAndThreads_MergesBySimilarity(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+
+	date := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	threadColumns := []string{"embedding_str", "id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "is_customer",
+		"thread_id", "subject", "email_count", "first_date", "last_date", "summary", "distance"}
+	emailColumns := []string{"embedding", "id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "is_customer", "distance"}
+
+	mock.ExpectQuery(`WITH ranked_threads AS`).
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnRows(sqlmock.NewRows(threadColumns).
+			AddRow("", 4, "<4@x>", "Re: Holster sizing", "a@x.com", "support@ids.com", date, "Medium fits.", "thread-a", false,
+				"thread-a", "Holster sizing", 2, date, date, "", 0.3).
+			AddRow("", 9, "<9@x>", "Re: Returns", "b@x.com", "support@ids.com", date, "Label sent.", "thread-b", false,
+				"thread-b", "Returns", 4, date, date, "", 0.6))
+	mock.ExpectQuery(`WHERE ee.email_id IS NOT NULL`).
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnRows(sqlmock.NewRows(emailColumns).
+			AddRow("", 12, "<12@x>", "Holster for Glock 19", "c@x.com", "support@ids.com", date, "Which holster?", nil, true, 0.1).
+			AddRow("", 13, "<13@x>", "Invoice", "d@x.com", "support@ids.com", date, "Invoice please", nil, true, 0.5))
+
+	results, err := service.SearchSimilarEmailsAndThreads("holster sizing", 3, EmailSearchFilters{})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	// Both searches share one query embedding
+	assert.Len(t, requestedInputs, 1)
+
+	assert.Equal(t, models.EmailResultEmail, results[0].Kind)
+	assert.Equal(t, 12, results[0].Email.ID)
+	assert.Nil(t, results[0].Thread)
+	assert.Equal(t, models.EmailResultThread, results[1].Kind)
+	require.NotNil(t, results[1].Thread)
+	assert.Equal(t, "thread-a", results[1].Thread.ThreadID)
+	assert.Equal(t, models.EmailResultEmail, results[2].Kind)
+	assert.Equal(t, 13, results[2].Email.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeSearchResults_PrefersThreadsOnTies(t *testing.T) {
+	threads := []models.EmailSearchResult{{Kind: models.EmailResultThread, Similarity: 0.8}}
+	individual := []models.EmailSearchResult{
+		{Kind: models.EmailResultEmail, Similarity: 0.9},
+		{Kind: models.EmailResultEmail, Similarity: 0.8},
+	}
+
+	merged := mergeSearchResults(threads, individual, 0)
+	require.Len(t, merged, 3)
+	assert.Equal(t, models.EmailResultEmail, merged[0].Kind)
+	assert.Equal(t, models.EmailResultThread, merged[1].Kind)
+	assert.Equal(t, models.EmailResultEmail, merged[2].Kind)
+
+	assert.Len(t, mergeSearchResults(threads, individual, 2), 2)
+}
+
+func TestSearchSimilarEmails_EmptyQuery(t *testing.T) {
+	var requestedInputs [][]string
+	service, mock := newTestEmailService(t, &requestedInputs)
+
+	for _, query := range []string{"", "   ", "\t\n\u200b"} {
+		results, err := service.SearchSimilarEmails(query, 5, true)
+		require.NoError(t, err)
+		assert.NotNil(t, results)
+		assert.Empty(t, results)
+	}
+
+	assert.Empty(t, requestedInputs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeThreads_ReassignsEmailsAndDropsMergedThreads(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM email_threads WHERE thread_id = \$1\)`).
+		WithArgs("thread-a").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE emails\s+SET thread_id = \$1, updated_at = CURRENT_TIMESTAMP\s+WHERE thread_id = ANY\(\$2\)`).
+		WithArgs("thread-a", pq.Array([]string{"thread-b", "thread-c"})).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`UPDATE email_threads et\s+SET email_count = stats.email_count.*WHERE thread_id = \$1\s+\) stats\s+WHERE et.thread_id = \$1`).
+		WithArgs("thread-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM email_embeddings WHERE email_id IS NULL AND thread_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]string{"thread-a", "thread-b", "thread-c"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO thread_participants \(thread_id, address\)\s+SELECT \$1, address FROM thread_participants WHERE thread_id = ANY\(\$2\)`).
+		WithArgs("thread-a", pq.Array([]string{"thread-b", "thread-c"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM email_threads WHERE thread_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]string{"thread-b", "thread-c"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	// The primary and duplicate IDs are ignored
+	err := service.MergeThreads("thread-a", []string{"thread-b", "thread-a", " thread-c ", "thread-b", ""})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeThreads_MissingPrimaryRollsBack(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM email_threads WHERE thread_id = \$1\)`).
+		WithArgs("thread-a").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	err := service.MergeThreads("thread-a", []string{"thread-b"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "primary thread thread-a not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeThreads_RejectsInvalidInput(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	err := service.MergeThreads("  ", []string{"thread-b"})
+	assert.ErrorContains(t, err, "primary thread ID is required")
+
+	err = service.MergeThreads("thread-a", []string{"thread-a", ""})
+	assert.ErrorContains(t, err, "no other threads to merge")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRebuildThreadAggregates_RecomputesFromEmails(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	mock.ExpectExec(`UPDATE email_threads et\s+SET email_count = stats.email_count.*` +
+		`SELECT t.thread_id, COUNT\(e.id\) AS email_count, MIN\(e.date\) AS first_date, MAX\(e.date\) AS last_date\s+` +
+		`FROM email_threads t\s+LEFT JOIN emails e ON e.thread_id = t.thread_id\s+GROUP BY t.thread_id\s+\) stats\s+` +
+		`WHERE et.thread_id = stats.thread_id`).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	updated, err := service.RebuildThreadAggregates()
+	require.NoError(t, err)
+	assert.Equal(t, 4, updated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRebuildThreadAggregates_ReturnsQueryError(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	mock.ExpectExec(`UPDATE email_threads et`).WillReturnError(errors.New("connection refused"))
+
+	updated, err := service.RebuildThreadAggregates()
+	assert.ErrorContains(t, err, "failed to rebuild thread aggregates")
+	assert.Equal(t, 0, updated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailEmbeddingGranularity_GenerationCounts(t *testing.T) {
+	emailColumns := []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+	threadColumns := []string{"thread_id", "subject", "email_count", "first_date", "last_date"}
+	now := time.Now()
+
+	// Emails 1 and 2 form thread-a; email 3 has no thread
+	threadEmail := func(rows *sqlmock.Rows, id int) *sqlmock.Rows {
+		return rows.AddRow(id, fmt.Sprintf("<%d@x>", id), "Holster question", "a@x.com", "support@ids.com", now, "Do you ship holsters?", "thread-a", nil, nil, true)
+	}
+	standalone := func(rows *sqlmock.Rows) *sqlmock.Rows {
+		return rows.AddRow(3, "<3@x>", "Sizing", "b@x.com", "support@ids.com", now, "Which size fits a Glock 19?", nil, nil, nil, true)
+	}
+
+	tests := []struct {
+		granularity     string
+		emailQuery      string
+		embeddedEmails  []int
+		expectThreads   bool
+		expectedInputs  int
+		expectedThreads int
+	}{
+		{
+			granularity:     config.EmailGranularityBoth,
+			emailQuery:      `WHERE ee.id IS NULL\s+ORDER BY e.date DESC`,
+			embeddedEmails:  []int{1, 2, 3},
+			expectThreads:   true,
+			expectedInputs:  4,
+			expectedThreads: 1,
+		},
+		{
+			granularity:     config.EmailGranularityThread,
+			emailQuery:      `WHERE ee.id IS NULL\s+AND NOT EXISTS \(\s+SELECT 1 FROM email_threads et\s+WHERE et.thread_id = e.thread_id AND et.email_count >= 2\s+\)\s+ORDER BY e.date DESC`,
+			embeddedEmails:  []int{3},
+			expectThreads:   true,
+			expectedInputs:  2,
+			expectedThreads: 1,
+		},
+		{
+			granularity:     config.EmailGranularityIndividual,
+			emailQuery:      `WHERE ee.id IS NULL\s+ORDER BY e.date DESC`,
+			embeddedEmails:  []int{1, 2, 3},
+			expectThreads:   false,
+			expectedInputs:  3,
+			expectedThreads: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity, func(t *testing.T) {
+			var requestedInputs [][]string
+			service, mock := newTestEmailService(t, &requestedInputs)
+			service.granularity = tt.granularity
+
+			// The database applies the coverage filter, so only uncovered emails come back
+			rows := sqlmock.NewRows(emailColumns)
+			for _, id := range tt.embeddedEmails {
+				if id == 3 {
+					standalone(rows)
+				} else {
+					threadEmail(rows, id)
+				}
+			}
+			mock.ExpectQuery(tt.emailQuery).WillReturnRows(rows)
+			mock.ExpectQuery(`FROM email_attachments`).WillReturnRows(attachmentRows())
+			for _, id := range tt.embeddedEmails {
+				mock.ExpectExec(`INSERT INTO email_embeddings \(email_id, embedding\)`).
+					WithArgs(id, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(int64(id), 1))
+			}
+
+			if tt.expectThreads {
+				mock.ExpectQuery(`WHERE ee.id IS NULL AND et.email_count >= \$1`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(threadColumns).AddRow("thread-a", "Holster question", 2, now, now))
+				mock.ExpectQuery(`FROM emails\s+WHERE thread_id = \$1`).
+					WithArgs("thread-a").
+					WillReturnRows(threadEmail(threadEmail(sqlmock.NewRows(emailColumns), 1), 2))
+				mock.ExpectExec(`INSERT INTO email_embeddings \(thread_id, embedding\)`).
+					WithArgs("thread-a", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(10, 1))
+			}
+
+			stats, err := service.GenerateEmailEmbeddingsWithStats()
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.embeddedEmails), stats.EmailsProcessed)
+
+			threads, err := service.GenerateThreadEmbeddingsWithStats()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedThreads, threads)
+
+			embedded := 0
+			for _, inputs := range requestedInputs {
+				embedded += len(inputs)
+			}
+			assert.Equal(t, tt.expectedInputs, embedded)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestThreadCoverageFilter_UsesThreadSizeThreshold(t *testing.T) {
+	service := &EmailEmbeddingService{granularity: config.EmailGranularityThread, threadMinEmails: 4}
+	assert.Contains(t, service.threadCoverageFilter(), "et.email_count >= 4")
+
+	service.granularity = config.EmailGranularityBoth
+	assert.Empty(t, service.threadCoverageFilter())
+	assert.Empty(t, (&EmailEmbeddingService{}).threadCoverageFilter(), "unset granularity embeds both")
+}
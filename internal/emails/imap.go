@@ -0,0 +1,271 @@
+package emails
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+
+	"ids/internal/config"
+	"ids/internal/models"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// imapMailbox is the part of an IMAP session the import needs, so the sync can be tested without a server
+type imapMailbox interface {
+	// Select opens a mailbox read-only and returns its UIDVALIDITY
+	Select(name string) (uint32, error)
+	// SearchUIDs returns the UIDs above after, ascending
+	SearchUIDs(after uint32) ([]uint32, error)
+	// FetchRaw returns the raw RFC 822 message of each UID; expunged messages are missing from the map
+	FetchRaw(uids []uint32) (map[uint32][]byte, error)
+	Logout() error
+}
+
+// IMAPImportStats summarizes an IMAP import run
+type IMAPImportStats struct {
+	Fetched   int    // Messages fetched from the server
+	Stored    int    // Messages stored as emails
+	Skipped   int    // Messages that could not be parsed (not retried on the next run)
+	StoredIDs []int  // IDs of the stored emails, for embedding
+	LastUID   uint32 // Highest UID imported; the next run starts after it
+}
+
+// IMAPBatchCallback is called after each batch is stored, before its progress is saved, with the IDs
+// of the emails it stored
+type IMAPBatchCallback func(storedIDs []int) error
+
+// imapSyncState is the stored import progress of one mailbox
+type imapSyncState struct {
+	UIDValidity int64 `db:"uid_validity"`
+	LastUID     int64 `db:"last_uid"`
+}
+
+// ImportFromIMAP imports the messages that arrived in the configured IMAP mailbox since the last run
+// Progress (the mailbox UIDVALIDITY and the last imported UID) is saved in email_sync_state after
+// every batch, so an interrupted import resumes where it stopped. When the server resets the
+// mailbox UIDVALIDITY the whole mailbox is imported again; stored emails are updated in place by
+// Message-ID. onBatch may be nil.
+func ImportFromIMAP(cfg *config.Config, service *EmailEmbeddingService, onBatch IMAPBatchCallback) (*IMAPImportStats, error) {
+	if !cfg.HasIMAP() {
+		return nil, fmt.Errorf("IMAP not configured: set IMAP_HOST, IMAP_USERNAME and IMAP_PASSWORD")
+	}
+
+	mailbox, err := dialIMAP(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := mailbox.Logout(); err != nil {
+			fmt.Printf("Warning: Error logging out of IMAP server: %v\n", err)
+		}
+	}()
+
+	return service.syncIMAPMailbox(mailbox, imapSyncSource(cfg), cfg.IMAPMailbox, cfg.IMAPBatchSize, service.StoreEmail, onBatch)
+}
+
+// imapSyncSource identifies a mailbox in email_sync_state
+func imapSyncSource(cfg *config.Config) string {
+	return fmt.Sprintf("imap://%s@%s/%s", cfg.IMAPUsername, net.JoinHostPort(cfg.IMAPHost, strconv.Itoa(cfg.IMAPPort)), cfg.IMAPMailbox)
+}
+
+// syncIMAPMailbox imports the messages of mailbox name above the saved UID in batches, saving
+// progress after each batch. Unparsable messages are skipped; a storage error stops the sync
+// without saving the batch, so it is fetched again on the next run.
+func (ees *EmailEmbeddingService) syncIMAPMailbox(mailbox imapMailbox, source, name string, batchSize int,
+	store func(*models.Email) error, onBatch IMAPBatchCallback) (*IMAPImportStats, error) {
+	uidValidity, err := mailbox.Select(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select IMAP mailbox %s: %w", name, err)
+	}
+
+	state, err := ees.loadIMAPSyncState(source)
+	if err != nil {
+		return nil, err
+	}
+	stats := &IMAPImportStats{}
+	if state != nil && state.UIDValidity == int64(uidValidity) {
+		stats.LastUID = uint32(state.LastUID)
+	} else if state != nil {
+		fmt.Printf("[IMAP_IMPORT] UIDVALIDITY of %s changed (%d -> %d), importing the whole mailbox again\n",
+			name, state.UIDValidity, uidValidity)
+	}
+
+	uids, err := mailbox.SearchUIDs(stats.LastUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search IMAP mailbox %s: %w", name, err)
+	}
+	fmt.Printf("[IMAP_IMPORT] %d new messages in %s since UID %d\n", len(uids), name, stats.LastUID)
+
+	for start := 0; start < len(uids); start += batchSize {
+		batch := uids[start:min(start+batchSize, len(uids))]
+		messages, err := mailbox.FetchRaw(batch)
+		if err != nil {
+			return stats, fmt.Errorf("failed to fetch IMAP messages: %w", err)
+		}
+
+		var storedIDs []int
+		for _, uid := range batch {
+			raw, ok := messages[uid]
+			if !ok {
+				continue
+			}
+			stats.Fetched++
+
+			email, err := parseEmailMessage(bytes.NewReader(raw))
+			if err != nil {
+				fmt.Printf("[IMAP_IMPORT] Warning: Skipping message UID %d: %v\n", uid, err)
+				stats.Skipped++
+				continue
+			}
+			if err := store(email); err != nil {
+				return stats, fmt.Errorf("failed to store message UID %d: %w", uid, err)
+			}
+			stats.Stored++
+			if email.ID > 0 {
+				storedIDs = append(storedIDs, email.ID)
+			}
+		}
+
+		if onBatch != nil {
+			if err := onBatch(storedIDs); err != nil {
+				return stats, err
+			}
+		}
+		lastUID := batch[len(batch)-1]
+		if err := ees.saveIMAPSyncState(source, uidValidity, lastUID); err != nil {
+			return stats, err
+		}
+		stats.LastUID = lastUID
+		stats.StoredIDs = append(stats.StoredIDs, storedIDs...)
+		fmt.Printf("[IMAP_IMPORT] Imported batch: %d emails (through UID %d)\n", len(storedIDs), lastUID)
+	}
+
+	return stats, nil
+}
+
+// loadIMAPSyncState returns the saved progress for source, or nil on the first run
+func (ees *EmailEmbeddingService) loadIMAPSyncState(source string) (*imapSyncState, error) {
+	var states []imapSyncState
+	query := `SELECT uid_validity, last_uid FROM email_sync_state WHERE source = $1`
+	if err := ees.db.ExecuteWriteQueryWithResult(&states, query, source); err != nil {
+		return nil, fmt.Errorf("failed to load IMAP sync state: %w", err)
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+	return &states[0], nil
+}
+
+// saveIMAPSyncState records that source is imported through lastUID
+func (ees *EmailEmbeddingService) saveIMAPSyncState(source string, uidValidity, lastUID uint32) error {
+	query := `
+		INSERT INTO email_sync_state (source, uid_validity, last_uid)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (source) DO UPDATE SET
+			uid_validity = EXCLUDED.uid_validity,
+			last_uid = EXCLUDED.last_uid,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := ees.db.ExecuteWriteQuery(query, source, int64(uidValidity), int64(lastUID)); err != nil {
+		return fmt.Errorf("failed to save IMAP sync state: %w", err)
+	}
+	return nil
+}
+
+// imapClient adapts a go-imap client to imapMailbox
+type imapClient struct {
+	c *client.Client
+}
+
+// dialIMAP connects to the configured server over TLS and logs in
+func dialIMAP(cfg *config.Config) (*imapClient, error) {
+	addr := net.JoinHostPort(cfg.IMAPHost, strconv.Itoa(cfg.IMAPPort))
+	c, err := client.DialTLS(addr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to IMAP server %s: %w", addr, err)
+	}
+	if err := c.Login(cfg.IMAPUsername, cfg.IMAPPassword); err != nil {
+		_ = c.Logout()
+		return nil, fmt.Errorf("IMAP login failed for %s: %w", cfg.IMAPUsername, err)
+	}
+	fmt.Printf("[IMAP_IMPORT] Connected to %s as %s\n", addr, cfg.IMAPUsername)
+	return &imapClient{c: c}, nil
+}
+
+// Select opens a mailbox read-only, so the import never changes message flags
+func (m *imapClient) Select(name string) (uint32, error) {
+	status, err := m.c.Select(name, true)
+	if err != nil {
+		return 0, err
+	}
+	return status.UidValidity, nil
+}
+
+// SearchUIDs returns the UIDs above after, ascending
+func (m *imapClient) SearchUIDs(after uint32) ([]uint32, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(after+1, 0) // 0 is "*"
+	uids, err := m.c.UidSearch(criteria)
+	if err != nil {
+		return nil, err
+	}
+	return uidsAfter(uids, after), nil
+}
+
+// uidsAfter keeps the UIDs above after, ascending
+// "N:*" always matches the highest UID in the mailbox, even when it is below N
+func uidsAfter(uids []uint32, after uint32) []uint32 {
+	filtered := make([]uint32, 0, len(uids))
+	for _, uid := range uids {
+		if uid > after {
+			filtered = append(filtered, uid)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i] < filtered[j] })
+	return filtered
+}
+
+// FetchRaw fetches the full messages without marking them as seen
+func (m *imapClient) FetchRaw(uids []uint32) (map[uint32][]byte, error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	go func() {
+		done <- m.c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+	}()
+
+	raw := make(map[uint32][]byte, len(uids))
+	var readErr error
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		data, err := io.ReadAll(body)
+		if err != nil && readErr == nil {
+			readErr = fmt.Errorf("failed to read message UID %d: %w", msg.Uid, err)
+		}
+		raw[msg.Uid] = data
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return raw, nil
+}
+
+// Logout ends the IMAP session
+func (m *imapClient) Logout() error {
+	return m.c.Logout()
+}
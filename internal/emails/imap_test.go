@@ -0,0 +1,200 @@
+package emails
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMailbox serves raw messages by UID
+type fakeMailbox struct {
+	uidValidity uint32
+	messages    map[uint32][]byte
+	searched    []uint32
+	fetched     [][]uint32
+	fetchErr    error
+}
+
+func (f *fakeMailbox) Select(string) (uint32, error) { return f.uidValidity, nil }
+
+func (f *fakeMailbox) SearchUIDs(after uint32) ([]uint32, error) {
+	f.searched = append(f.searched, after)
+	var uids []uint32
+	for uid := range f.messages {
+		uids = append(uids, uid)
+	}
+	return uidsAfter(uids, after), nil
+}
+
+func (f *fakeMailbox) FetchRaw(uids []uint32) (map[uint32][]byte, error) {
+	f.fetched = append(f.fetched, uids)
+	if f.fetchErr != nil {
+		return nil, f.fetchErr
+	}
+	raw := make(map[uint32][]byte)
+	for _, uid := range uids {
+		if msg, ok := f.messages[uid]; ok {
+			raw[uid] = msg
+		}
+	}
+	return raw, nil
+}
+
+func (f *fakeMailbox) Logout() error { return nil }
+
+// rawMessage builds a minimal RFC 822 message
+func rawMessage(id int) []byte {
+	return []byte(fmt.Sprintf("Message-ID: <%d@example.com>\r\nFrom: buyer@example.com\r\nTo: support@ids.com\r\n"+
+		"Subject: Order %d\r\nDate: Mon, 2 Feb 2026 10:00:00 +0000\r\n\r\nWhere is order %d?\r\n", id, id, id))
+}
+
+// storeWithIDs assigns sequential IDs instead of writing to the database
+func storeWithIDs(stored *[]string) func(*models.Email) error {
+	return func(email *models.Email) error {
+		*stored = append(*stored, email.MessageID)
+		email.ID = len(*stored)
+		return nil
+	}
+}
+
+const testIMAPSource = "imap://support@ids.com@imap.example.com:993/INBOX"
+
+func TestSyncIMAPMailbox_FirstRunImportsInBatches(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	mailbox := &fakeMailbox{uidValidity: 7, messages: map[uint32][]byte{
+		3: rawMessage(3), 5: rawMessage(5), 8: rawMessage(8),
+	}}
+
+	mock.ExpectQuery(`SELECT uid_validity, last_uid FROM email_sync_state WHERE source = \$1`).
+		WithArgs(testIMAPSource).
+		WillReturnRows(sqlmock.NewRows([]string{"uid_validity", "last_uid"}))
+	mock.ExpectExec(`INSERT INTO email_sync_state`).WithArgs(testIMAPSource, int64(7), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO email_sync_state`).WithArgs(testIMAPSource, int64(7), int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var stored []string
+	var batches [][]int
+	stats, err := service.syncIMAPMailbox(mailbox, testIMAPSource, "INBOX", 2, storeWithIDs(&stored), func(ids []int) error {
+		batches = append(batches, ids)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint32{0}, mailbox.searched)
+	assert.Equal(t, [][]uint32{{3, 5}, {8}}, mailbox.fetched)
+	assert.Equal(t, []string{"<3@example.com>", "<5@example.com>", "<8@example.com>"}, stored)
+	assert.Equal(t, [][]int{{1, 2}, {3}}, batches)
+	assert.Equal(t, 3, stats.Stored)
+	assert.Equal(t, []int{1, 2, 3}, stats.StoredIDs)
+	assert.Equal(t, uint32(8), stats.LastUID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncIMAPMailbox_ResumesAfterSavedUID(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	mailbox := &fakeMailbox{uidValidity: 7, messages: map[uint32][]byte{
+		3: rawMessage(3), 5: rawMessage(5), 8: rawMessage(8),
+	}}
+
+	mock.ExpectQuery(`SELECT uid_validity, last_uid FROM email_sync_state`).
+		WillReturnRows(sqlmock.NewRows([]string{"uid_validity", "last_uid"}).AddRow(7, 5))
+	mock.ExpectExec(`INSERT INTO email_sync_state`).WithArgs(testIMAPSource, int64(7), int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var stored []string
+	stats, err := service.syncIMAPMailbox(mailbox, testIMAPSource, "INBOX", 50, storeWithIDs(&stored), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint32{5}, mailbox.searched)
+	assert.Equal(t, []string{"<8@example.com>"}, stored)
+	assert.Equal(t, uint32(8), stats.LastUID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncIMAPMailbox_UIDValidityChangeReimports(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	mailbox := &fakeMailbox{uidValidity: 9, messages: map[uint32][]byte{1: rawMessage(1)}}
+
+	mock.ExpectQuery(`SELECT uid_validity, last_uid FROM email_sync_state`).
+		WillReturnRows(sqlmock.NewRows([]string{"uid_validity", "last_uid"}).AddRow(7, 40))
+	mock.ExpectExec(`INSERT INTO email_sync_state`).WithArgs(testIMAPSource, int64(9), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var stored []string
+	_, err := service.syncIMAPMailbox(mailbox, testIMAPSource, "INBOX", 50, storeWithIDs(&stored), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint32{0}, mailbox.searched)
+	assert.Equal(t, []string{"<1@example.com>"}, stored)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncIMAPMailbox_StoreErrorKeepsBatchForNextRun(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	mailbox := &fakeMailbox{uidValidity: 7, messages: map[uint32][]byte{
+		3: rawMessage(3), 5: []byte("not an email"), 8: rawMessage(8),
+	}}
+
+	mock.ExpectQuery(`SELECT uid_validity, last_uid FROM email_sync_state`).
+		WillReturnRows(sqlmock.NewRows([]string{"uid_validity", "last_uid"}))
+	// The first batch is saved even though UID 5 can't be parsed
+	mock.ExpectExec(`INSERT INTO email_sync_state`).WithArgs(testIMAPSource, int64(7), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	stored := 0
+	stats, err := service.syncIMAPMailbox(mailbox, testIMAPSource, "INBOX", 2, func(email *models.Email) error {
+		if email.MessageID == "<8@example.com>" {
+			return errors.New("connection reset")
+		}
+		stored++
+		email.ID = stored
+		return nil
+	}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UID 8")
+
+	assert.Equal(t, 1, stats.Stored)
+	assert.Equal(t, 1, stats.Skipped)
+	assert.Equal(t, uint32(5), stats.LastUID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncIMAPMailbox_FetchErrorStopsWithoutSaving(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	mailbox := &fakeMailbox{uidValidity: 7, messages: map[uint32][]byte{3: rawMessage(3)}, fetchErr: errors.New("timeout")}
+
+	mock.ExpectQuery(`SELECT uid_validity, last_uid FROM email_sync_state`).
+		WillReturnRows(sqlmock.NewRows([]string{"uid_validity", "last_uid"}))
+
+	_, err := service.syncIMAPMailbox(mailbox, testIMAPSource, "INBOX", 50, func(*models.Email) error {
+		t.Fatal("nothing should be stored")
+		return nil
+	}, nil)
+	require.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUIDsAfter(t *testing.T) {
+	// "N:*" matches the highest UID even when it is below N
+	assert.Empty(t, uidsAfter([]uint32{12}, 40))
+	assert.Equal(t, []uint32{41, 43}, uidsAfter([]uint32{43, 40, 41}, 40))
+}
+
+func TestImportFromIMAP_RequiresConfiguration(t *testing.T) {
+	_, err := ImportFromIMAP(&config.Config{IMAPHost: "imap.example.com"}, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "IMAP not configured")
+}
+
+func TestIMAPSyncSource(t *testing.T) {
+	cfg := &config.Config{IMAPHost: "imap.example.com", IMAPPort: 993, IMAPUsername: "support@ids.com", IMAPMailbox: "INBOX"}
+	assert.Equal(t, testIMAPSource, imapSyncSource(cfg))
+}
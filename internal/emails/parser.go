@@ -3,6 +3,7 @@ package emails
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -16,6 +17,10 @@ import (
 	"time"
 
 	"ids/internal/models"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 // ParseEMLFile parses a single EML file
@@ -66,6 +71,13 @@ type MBOXBatchCallback func(batch []*models.Email, progress MBOXProgress) error
 // ParseMBOXFileStreaming parses an MBOX file in batches with progress tracking
 // This is memory-efficient for large MBOX files (70GB+)
 func ParseMBOXFileStreaming(filename string, batchSize int, callback MBOXBatchCallback) error {
+	return ParseMBOXFileStreamingContext(context.Background(), filename, batchSize, callback)
+}
+
+// ParseMBOXFileStreamingContext is ParseMBOXFileStreaming stopped when ctx is canceled
+// Cancellation is checked between emails, so a long import can be abandoned without waiting
+// for the whole file; batches already passed to callback are not rolled back.
+func ParseMBOXFileStreamingContext(ctx context.Context, filename string, batchSize int, callback MBOXBatchCallback) error {
 	file, err := os.Open(filename)
 	if err != nil {
 		return fmt.Errorf("failed to open MBOX file: %w", err)
@@ -98,6 +110,10 @@ func ParseMBOXFileStreaming(filename string, batchSize int, callback MBOXBatchCa
 
 		// MBOX format: each email starts with "From " (with space)
 		if strings.HasPrefix(line, "From ") && currentEmail.Len() > 0 {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("MBOX parsing canceled after %d emails: %w", emailCount, err)
+			}
+
 			// Parse the accumulated email
 			email, err := parseEmailMessage(&currentEmail)
 			if err != nil {
@@ -148,6 +164,9 @@ func ParseMBOXFileStreaming(filename string, batchSize int, callback MBOXBatchCa
 
 	// Process remaining batch
 	if len(currentBatch) > 0 {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("MBOX parsing canceled after %d emails: %w", emailCount, err)
+		}
 		progress := MBOXProgress{
 			BytesProcessed:   bytesProcessed,
 			TotalBytes:       totalBytes,
@@ -243,12 +262,13 @@ func parseEmailMessage(r io.Reader) (*models.Email, error) {
 		email.References = &references
 	}
 
-	// Extract body
-	body, err := extractBody(msg)
+	// Extract body and attachment metadata
+	body, attachments, err := extractBody(msg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract body: %w", err)
 	}
 	email.Body = body
+	email.Attachments = attachments
 
 	// Determine if this is from a customer (simple heuristic)
 	// You can customize this based on your domain
@@ -260,16 +280,16 @@ func parseEmailMessage(r io.Reader) (*models.Email, error) {
 	return email, nil
 }
 
-// extractBody extracts the body text from an email message
-func extractBody(msg *mail.Message) (string, error) {
+// extractBody extracts the body text and attachment metadata from an email message
+func extractBody(msg *mail.Message) (string, []models.EmailAttachment, error) {
 	contentType := msg.Header.Get("Content-Type")
 	if contentType == "" {
 		// Plain text email
 		body, err := io.ReadAll(msg.Body)
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
-		return string(body), nil
+		return string(body), nil, nil
 	}
 
 	mediaType, params, err := mime.ParseMediaType(contentType)
@@ -277,9 +297,9 @@ func extractBody(msg *mail.Message) (string, error) {
 		// Fallback: read as plain text
 		body, err := io.ReadAll(msg.Body)
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
-		return string(body), nil
+		return string(body), nil, nil
 	}
 
 	if strings.HasPrefix(mediaType, "multipart/") {
@@ -287,15 +307,23 @@ func extractBody(msg *mail.Message) (string, error) {
 		return extractMultipartBody(msg.Body, params["boundary"])
 	}
 
+	// A message consisting of a single attached file has no body text
+	if attachment, ok := partAttachment(contentType, msg.Header.Get("Content-Disposition")); ok {
+		return "", []models.EmailAttachment{attachment}, nil
+	}
+
 	// Single part message
-	return extractSinglePartBody(msg.Body, mediaType, msg.Header.Get("Content-Transfer-Encoding"))
+	body, err := extractSinglePartBody(msg.Body, params["charset"], msg.Header.Get("Content-Transfer-Encoding"))
+	return body, nil, err
 }
 
-// extractMultipartBody extracts text from multipart email
-func extractMultipartBody(body io.Reader, boundary string) (string, error) {
+// extractMultipartBody extracts text and attachment metadata from multipart email
+// Attachment contents are skipped; only their filenames and content types are collected.
+func extractMultipartBody(body io.Reader, boundary string) (string, []models.EmailAttachment, error) {
 	mr := multipart.NewReader(body, boundary)
 	var textParts []string
 	var htmlParts []string
+	var attachments []models.EmailAttachment
 
 	for {
 		part, err := mr.NextPart()
@@ -303,14 +331,32 @@ func extractMultipartBody(body io.Reader, boundary string) (string, error) {
 			break
 		}
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
 
 		partContentType := part.Header.Get("Content-Type")
-		mediaType, _, _ := mime.ParseMediaType(partContentType)
-		transferEncoding := part.Header.Get("Content-Transfer-Encoding")
+		mediaType, params, _ := mime.ParseMediaType(partContentType)
+
+		if attachment, ok := partAttachment(partContentType, part.Header.Get("Content-Disposition")); ok {
+			attachments = append(attachments, attachment)
+			continue
+		}
+
+		if strings.HasPrefix(mediaType, "multipart/") {
+			// Nested multipart, e.g. multipart/alternative inside multipart/mixed
+			if nestedBoundary, ok := params["boundary"]; ok {
+				nested, nestedAttachments, err := extractMultipartBody(part, nestedBoundary)
+				if err == nil {
+					if nested != "" {
+						textParts = append(textParts, nested)
+					}
+					attachments = append(attachments, nestedAttachments...)
+				}
+			}
+			continue
+		}
 
-		content, err := extractSinglePartBody(part, mediaType, transferEncoding)
+		content, err := extractSinglePartBody(part, params["charset"], part.Header.Get("Content-Transfer-Encoding"))
 		if err != nil {
 			continue
 		}
@@ -319,34 +365,24 @@ func extractMultipartBody(body io.Reader, boundary string) (string, error) {
 			textParts = append(textParts, content)
 		} else if strings.HasPrefix(mediaType, "text/html") {
 			htmlParts = append(htmlParts, content)
-		} else if strings.HasPrefix(mediaType, "multipart/") {
-			// Nested multipart (rare but possible)
-			_, params, _ := mime.ParseMediaType(partContentType)
-			if nestedBoundary, ok := params["boundary"]; ok {
-				nested, err := extractMultipartBody(part, nestedBoundary)
-				if err == nil {
-					textParts = append(textParts, nested)
-				}
-			}
 		}
 	}
 
 	// Prefer plain text over HTML
 	if len(textParts) > 0 {
-		return strings.Join(textParts, "\n\n"), nil
+		return strings.Join(textParts, "\n\n"), attachments, nil
 	}
 
 	// Fallback to HTML (basic cleanup)
 	if len(htmlParts) > 0 {
-		html := strings.Join(htmlParts, "\n\n")
-		return cleanHTML(html), nil
+		return cleanHTML(strings.Join(htmlParts, "\n\n")), attachments, nil
 	}
 
-	return "", nil
+	return "", attachments, nil
 }
 
-// extractSinglePartBody extracts text from a single part
-func extractSinglePartBody(body io.Reader, mediaType, transferEncoding string) (string, error) {
+// extractSinglePartBody extracts text from a single part, transcoded from charset to UTF-8
+func extractSinglePartBody(body io.Reader, charset, transferEncoding string) (string, error) {
 	reader := body
 
 	// Handle transfer encoding
@@ -362,85 +398,206 @@ func extractSinglePartBody(body io.Reader, mediaType, transferEncoding string) (
 		return "", err
 	}
 
-	return string(content), nil
+	return decodeCharset(content, charset), nil
 }
 
-// cleanHTML removes HTML tags (basic implementation)
-func cleanHTML(html string) string {
-	// Remove script and style tags with their contents
-	html = removeTagsWithContent(html, "script")
-	html = removeTagsWithContent(html, "style")
-
-	// Replace common HTML entities
-	html = strings.ReplaceAll(html, "&nbsp;", " ")
-	html = strings.ReplaceAll(html, "&lt;", "<")
-	html = strings.ReplaceAll(html, "&gt;", ">")
-	html = strings.ReplaceAll(html, "&amp;", "&")
-	html = strings.ReplaceAll(html, "&quot;", "\"")
-	html = strings.ReplaceAll(html, "&#39;", "'")
-	html = strings.ReplaceAll(html, "<br>", "\n")
-	html = strings.ReplaceAll(html, "<br/>", "\n")
-	html = strings.ReplaceAll(html, "<br />", "\n")
-	html = strings.ReplaceAll(html, "</p>", "\n\n")
-	html = strings.ReplaceAll(html, "</div>", "\n")
-
-	// Remove all remaining HTML tags
-	var result strings.Builder
-	inTag := false
-	for _, char := range html {
-		if char == '<' {
-			inTag = true
-			continue
-		}
-		if char == '>' {
-			inTag = false
-			continue
-		}
-		if !inTag {
-			result.WriteRune(char)
-		}
+// decodeCharset transcodes content from charset (e.g. ISO-8859-1, Windows-1255) to UTF-8
+// Content without a charset, in UTF-8 or in an unrecognized charset is returned as is.
+func decodeCharset(content []byte, charset string) string {
+	charset = strings.TrimSpace(charset)
+	if charset == "" {
+		return string(content)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(content)
 	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return string(content)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return string(content)
+	}
+	return string(decoded)
+}
+
+// htmlSkippedTags have contents that are never shown as text
+var htmlSkippedTags = map[atom.Atom]bool{atom.Script: true, atom.Style: true, atom.Head: true}
+
+// htmlParagraphTags are separated from surrounding text by a blank line
+var htmlParagraphTags = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Blockquote: true, atom.Pre: true,
+}
+
+// htmlLineTags start and end on their own line
+var htmlLineTags = map[atom.Atom]bool{
+	atom.Div: true, atom.Tr: true, atom.Li: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Dt: true, atom.Dd: true,
+}
 
-	// Clean up whitespace
-	text := result.String()
-	text = strings.TrimSpace(text)
+// htmlTextWriter accumulates converted text, tracking line breaks since the last visible text so
+// adjacent block tags (</li><li>, </tr><tr>) don't stack blank lines
+type htmlTextWriter struct {
+	out      strings.Builder
+	newlines int
+}
 
-	// Remove excessive newlines
-	for strings.Contains(text, "\n\n\n") {
-		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+// write appends text
+func (w *htmlTextWriter) write(text string) {
+	w.out.WriteString(text)
+	if strings.TrimSpace(text) != "" {
+		w.newlines = 0
 	}
+}
+
+// breakLines ends the current line, leaving n-1 blank lines before the next text
+func (w *htmlTextWriter) breakLines(n int) {
+	for ; w.newlines < n; w.newlines++ {
+		w.out.WriteString("\n")
+	}
+}
 
-	return text
+// htmlList tracks an open <ul> or <ol> so list items get bullets or numbers
+type htmlList struct {
+	ordered bool
+	items   int
 }
 
-// removeTagsWithContent removes HTML tags and their content
-func removeTagsWithContent(html, tag string) string {
-	openTag := "<" + tag
-	closeTag := "</" + tag + ">"
+// cleanHTML converts an HTML email body to plain text
+// Paragraphs, headings and lists are kept as line breaks ("- " bullets, "1. " numbering), table
+// cells are joined with " | " one row per line, links keep their text, and <script>, <style>
+// and <head> contents are dropped. Entities are decoded by the tokenizer.
+func cleanHTML(htmlBody string) string {
+	var out htmlTextWriter
+	tokenizer := html.NewTokenizer(strings.NewReader(htmlBody))
+
+	var lists []htmlList
+	skipDepth := 0
+	preDepth := 0
+	rowCells := 0
 
 	for {
-		start := strings.Index(strings.ToLower(html), strings.ToLower(openTag))
-		if start == -1 {
-			break
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break // io.EOF, or malformed input past which nothing more can be read
 		}
+		token := tokenizer.Token()
 
-		// Find the closing tag
-		end := strings.Index(strings.ToLower(html[start:]), strings.ToLower(closeTag))
-		if end == -1 {
-			break
-		}
-		end += start + len(closeTag)
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if htmlSkippedTags[token.DataAtom] {
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+
+			switch {
+			case token.DataAtom == atom.Br || token.DataAtom == atom.Hr:
+				// Consecutive <br>s are intentional blank lines
+				out.write("\n")
+				out.newlines++
+			case htmlParagraphTags[token.DataAtom]:
+				out.breakLines(2)
+			case htmlLineTags[token.DataAtom]:
+				out.breakLines(1)
+			}
+
+			switch token.DataAtom {
+			case atom.Ul, atom.Ol:
+				lists = append(lists, htmlList{ordered: token.DataAtom == atom.Ol})
+			case atom.Li:
+				if len(lists) > 0 && lists[len(lists)-1].ordered {
+					lists[len(lists)-1].items++
+					out.write(fmt.Sprintf("%d. ", lists[len(lists)-1].items))
+				} else {
+					out.write("- ")
+				}
+			case atom.Tr:
+				rowCells = 0
+			case atom.Td, atom.Th:
+				if rowCells > 0 {
+					out.write(" | ")
+				}
+				rowCells++
+			case atom.Pre:
+				if tokenType == html.StartTagToken {
+					preDepth++
+				}
+			}
+
+		case html.EndTagToken:
+			if htmlSkippedTags[token.DataAtom] {
+				skipDepth = max(skipDepth-1, 0)
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+
+			switch {
+			case htmlParagraphTags[token.DataAtom]:
+				out.breakLines(2)
+			case htmlLineTags[token.DataAtom]:
+				out.breakLines(1)
+			}
+
+			switch token.DataAtom {
+			case atom.Ul, atom.Ol:
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+			case atom.Pre:
+				preDepth = max(preDepth-1, 0)
+			}
 
-		// Remove the section
-		html = html[:start] + html[end:]
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			text := token.Data
+			if preDepth == 0 {
+				// Source line breaks are plain whitespace outside <pre>
+				text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(text)
+			}
+			out.write(text)
+		}
 	}
 
-	return html
+	return normalizeTextLines(out.out.String())
 }
 
-// decodeHeader decodes MIME encoded headers
+// normalizeTextLines collapses runs of spaces within each line and keeps at most one blank line
+// between paragraphs, trimming the result
+func normalizeTextLines(text string) string {
+	lines := strings.Split(text, "\n")
+	normalized := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank && len(normalized) > 0 {
+				normalized = append(normalized, "")
+			}
+			blank = true
+			continue
+		}
+		normalized = append(normalized, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(normalized, "\n"))
+}
+
+// decodeHeader decodes MIME encoded headers, including words in non-UTF-8 charsets
 func decodeHeader(header string) string {
-	dec := new(mime.WordDecoder)
+	dec := &mime.WordDecoder{CharsetReader: charsetReader}
 	decoded, err := dec.DecodeHeader(header)
 	if err != nil {
 		return header
@@ -448,6 +605,15 @@ func decodeHeader(header string) string {
 	return decoded
 }
 
+// charsetReader transcodes encoded-word text from charset to UTF-8 for mime.WordDecoder
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %w", charset, err)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
 // GenerateThreadID generates a thread ID from email headers
 func GenerateThreadID(email *models.Email) string {
 	// Try to extract thread ID from References or In-Reply-To
@@ -0,0 +1,241 @@
+package emails
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mixedEmailWithAttachments = "From: Buyer <buyer@example.com>\r\n" +
+	"To: support@israeldefensestore.com\r\n" +
+	"Subject: Invoice for order 1042\r\n" +
+	"Message-ID: <order-1042@example.com>\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/related; boundary=\"related\"\r\n" +
+	"\r\n" +
+	"--related\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"alt\"\r\n" +
+	"\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Please find the invoice attached.\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Please find the invoice attached.</p>\r\n" +
+	"--alt--\r\n" +
+	"--related\r\n" +
+	"Content-Type: image/png; name=\"logo.png\"\r\n" +
+	"Content-Disposition: inline\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--related--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+	"\r\n" +
+	"not part of the body\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=\"invoice-1042.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--outer--\r\n"
+
+func TestParseEmailMessage_Attachments(t *testing.T) {
+	email, err := parseEmailMessage(strings.NewReader(mixedEmailWithAttachments))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Please find the invoice attached.", email.Body)
+	assert.Equal(t, []models.EmailAttachment{
+		{Filename: "logo.png", ContentType: "image/png", IsInline: true},
+		{Filename: "notes.txt", ContentType: "text/plain"},
+		{Filename: "invoice-1042.pdf", ContentType: "application/pdf"},
+	}, email.Attachments)
+}
+
+func TestParseEmailMessage_SinglePartAttachment(t *testing.T) {
+	raw := "From: buyer@example.com\r\n" +
+		"Subject: Scan\r\n" +
+		"Content-Type: application/pdf; name=\"scan.pdf\"\r\n" +
+		"Content-Disposition: attachment\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQK\r\n"
+
+	email, err := parseEmailMessage(strings.NewReader(raw))
+	require.NoError(t, err)
+
+	assert.Empty(t, email.Body)
+	assert.Equal(t, []models.EmailAttachment{{Filename: "scan.pdf", ContentType: "application/pdf"}}, email.Attachments)
+}
+
+func TestParseEmailMessage_NoAttachments(t *testing.T) {
+	raw := "From: buyer@example.com\r\n" +
+		"Subject: Sizing\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Which size fits a Glock 19?\r\n"
+
+	email, err := parseEmailMessage(strings.NewReader(raw))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Which size fits a Glock 19?\r\n", email.Body)
+	assert.Empty(t, email.Attachments)
+}
+
+func TestParseEMLFile_Charsets(t *testing.T) {
+	tests := []struct {
+		file    string
+		subject string
+		body    string
+	}{
+		{
+			file:    "testdata/windows-1255.eml",
+			subject: "שאלה על נרתיק",
+			body:    "שלום, האם יש לכם נרתיק לגלוק 19?\r\nתודה רבה",
+		},
+		{
+			file:    "testdata/latin1.eml",
+			subject: "Bestellung für Ausrüstung",
+			body:    "Grüße aus München! Ich möchte eine Tasche für meine Ausrüstung bestellen.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			email, err := ParseEMLFile(tt.file)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.subject, email.Subject)
+			assert.Equal(t, tt.body, strings.TrimSpace(email.Body))
+		})
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	latin1 := []byte{'c', 'a', 'f', 0xe9}
+	assert.Equal(t, "café", decodeCharset(latin1, "iso-8859-1"))
+	assert.Equal(t, "café", decodeCharset(latin1, " Windows-1252 "))
+
+	// Missing, UTF-8 and unknown charsets leave the content untouched
+	assert.Equal(t, "café", decodeCharset([]byte("café"), ""))
+	assert.Equal(t, "café", decodeCharset([]byte("café"), "UTF-8"))
+	assert.Equal(t, string(latin1), decodeCharset(latin1, "x-unknown"))
+}
+
+func TestCleanHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs and line breaks",
+			html: "<html><head><title>Order</title></head><body><p>Hello,</p>\n<p>Your order\n   has shipped.<br>Tracking below.</p></body></html>",
+			want: "Hello,\n\nYour order has shipped.\nTracking below.",
+		},
+		{
+			name: "script and style contents are dropped",
+			html: `<style>p { color: red; }</style><p>Visible</p><script type="text/javascript">var x = "<p>hidden</p>";</script>`,
+			want: "Visible",
+		},
+		{
+			name: "unordered and ordered lists",
+			html: "<p>Options:</p><ul><li>Holster</li><li>Mag pouch</li></ul><ol><li>Pick a size</li><li>Check out</li></ol>",
+			want: "Options:\n\n- Holster\n- Mag pouch\n\n1. Pick a size\n2. Check out",
+		},
+		{
+			name: "table rows and cells",
+			html: "<table><tr><th>Item</th><th>Qty</th></tr><tr><td>Plate carrier</td><td>2</td></tr></table>",
+			want: "Item | Qty\nPlate carrier | 2",
+		},
+		{
+			name: "links keep their text without attributes",
+			html: `<p>See <a href="https://israeldefensestore.com/product/holster" title="Holster &quot;X&quot;">our holster page</a> for sizes.</p>`,
+			want: "See our holster page for sizes.",
+		},
+		{
+			name: "entities are decoded",
+			html: "<div>Fits&nbsp;Glock&nbsp;19 &amp; 17 &lt;3 &#39;quoted&#39;</div>",
+			want: "Fits Glock 19 & 17 <3 'quoted'",
+		},
+		{
+			name: "empty divs add no blank lines",
+			html: "<div><div>Thanks,</div><div></div><div></div><div>Support</div></div>",
+			want: "Thanks,\nSupport",
+		},
+		{
+			name: "div with a line break is a blank line",
+			html: "<div>Thanks,</div><div><br></div><div>Support</div>",
+			want: "Thanks,\n\nSupport",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cleanHTML(tt.html))
+		})
+	}
+}
+
+// writeMBOX writes count minimal messages to an MBOX file and returns its path
+func writeMBOX(t *testing.T, count int) string {
+	t.Helper()
+	var mbox strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&mbox, "From buyer@example.com Mon Feb  2 10:00:00 2026\n"+
+			"Message-ID: <%d@example.com>\nFrom: buyer@example.com\nTo: support@ids.com\nSubject: Order %d\n\nWhere is order %d?\n\n", i, i, i)
+	}
+	path := filepath.Join(t.TempDir(), "inbox.mbox")
+	require.NoError(t, os.WriteFile(path, []byte(mbox.String()), 0o644))
+	return path
+}
+
+func TestParseMBOXFileStreamingContext_StopsWhenCanceled(t *testing.T) {
+	path := writeMBOX(t, 6)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var batches []MBOXProgress
+	err := ParseMBOXFileStreamingContext(ctx, path, 2, func(batch []*models.Email, progress MBOXProgress) error {
+		batches = append(batches, progress)
+		cancel()
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, batches, 1)
+	assert.Equal(t, 2, batches[0].CurrentBatchSize)
+	assert.Less(t, batches[0].PercentComplete, 100.0)
+}
+
+func TestParseMBOXFileStreaming_ReportsProgress(t *testing.T) {
+	path := writeMBOX(t, 3)
+
+	var ids []string
+	var last MBOXProgress
+	err := ParseMBOXFileStreaming(path, 2, func(batch []*models.Email, progress MBOXProgress) error {
+		for _, email := range batch {
+			ids = append(ids, email.MessageID)
+		}
+		last = progress
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"<1@example.com>", "<2@example.com>", "<3@example.com>"}, ids)
+	assert.Equal(t, 3, last.EmailsProcessed)
+	assert.Equal(t, 100.0, last.PercentComplete)
+}
@@ -0,0 +1,180 @@
+package emails
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"ids/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// addressPattern finds bare addresses in headers net/mail cannot parse
+var addressPattern = regexp.MustCompile(`[^\s<>"',;:()\[\]]+@[^\s<>"',;:()\[\]]+\.[^\s<>"',;:()\[\]]+`)
+
+// ExtractParticipants returns the distinct lowercase addresses in the given From/To headers,
+// in order of first appearance. Display names are dropped; malformed headers fall back to
+// picking out anything shaped like an address.
+func ExtractParticipants(headers ...string) []string {
+	var participants []string
+	seen := make(map[string]struct{})
+
+	for _, header := range headers {
+		for _, address := range headerAddresses(header) {
+			address = strings.ToLower(strings.TrimSpace(address))
+			if address == "" {
+				continue
+			}
+			if _, ok := seen[address]; ok {
+				continue
+			}
+			seen[address] = struct{}{}
+			participants = append(participants, address)
+		}
+	}
+	return participants
+}
+
+// headerAddresses returns the addresses of one address-list header
+func headerAddresses(header string) []string {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+	if list, err := mail.ParseAddressList(header); err == nil {
+		addresses := make([]string, len(list))
+		for i, address := range list {
+			addresses[i] = address.Address
+		}
+		return addresses
+	}
+	return addressPattern.FindAllString(header, -1)
+}
+
+// storeParticipants records the email's From and To addresses as participants of its thread
+func (ees *EmailEmbeddingService) storeParticipants(threadID string, email *models.Email) error {
+	participants := ExtractParticipants(email.From, email.To)
+	if len(participants) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO thread_participants (thread_id, address)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT (thread_id, address) DO NOTHING
+	`
+	if _, err := ees.db.ExecuteWriteQuery(query, threadID, pq.Array(participants)); err != nil {
+		return fmt.Errorf("failed to store thread participants: %w", err)
+	}
+	return nil
+}
+
+// RebuildThreadParticipants recomputes thread_participants from the From/To headers of every
+// stored email and returns how many threads have participants. It runs in one transaction, so
+// lookups never see a partially rebuilt table.
+func (ees *EmailEmbeddingService) RebuildThreadParticipants() (int, error) {
+	var rows []struct {
+		ThreadID string `db:"thread_id"`
+		From     string `db:"from_addr"`
+		To       string `db:"to_addr"`
+	}
+	query := `
+		SELECT e.thread_id, e.from_addr, e.to_addr
+		FROM emails e
+		JOIN email_threads et ON et.thread_id = e.thread_id
+		ORDER BY e.thread_id, e.date, e.id
+	`
+	if err := ees.db.ExecuteWriteQueryWithResult(&rows, query); err != nil {
+		return 0, fmt.Errorf("failed to load email participants: %w", err)
+	}
+
+	var threadIDs []string
+	headers := make(map[string][]string)
+	for _, row := range rows {
+		if _, ok := headers[row.ThreadID]; !ok {
+			threadIDs = append(threadIDs, row.ThreadID)
+		}
+		headers[row.ThreadID] = append(headers[row.ThreadID], row.From, row.To)
+	}
+
+	threads := 0
+	err := ees.db.WithTransaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM thread_participants`); err != nil {
+			return fmt.Errorf("failed to clear thread participants: %w", err)
+		}
+		for _, threadID := range threadIDs {
+			participants := ExtractParticipants(headers[threadID]...)
+			if len(participants) == 0 {
+				continue
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO thread_participants (thread_id, address)
+				SELECT $1, unnest($2::text[])
+			`, threadID, pq.Array(participants)); err != nil {
+				return fmt.Errorf("failed to store participants of thread %s: %w", threadID, err)
+			}
+			threads++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("[EMAIL_THREADS] Rebuilt participants for %d threads\n", threads)
+	return threads, nil
+}
+
+// FindThreadsByParticipant returns the threads address took part in, most recent first
+func (ees *EmailEmbeddingService) FindThreadsByParticipant(address string) ([]models.EmailThread, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return nil, fmt.Errorf("participant address is required")
+	}
+
+	query := `
+		SELECT et.thread_id, et.subject, et.email_count, et.first_date, et.last_date,
+		       COALESCE(et.summary, '') AS summary, et.created_at, et.updated_at
+		FROM thread_participants tp
+		JOIN email_threads et ON et.thread_id = tp.thread_id
+		WHERE tp.address = $1
+		ORDER BY et.last_date DESC, et.thread_id
+	`
+	threads := []models.EmailThread{}
+	if err := ees.db.ExecuteWriteQueryWithResult(&threads, query, address); err != nil {
+		return nil, fmt.Errorf("failed to find threads by participant: %w", err)
+	}
+	return threads, nil
+}
+
+// customerHistorySubjects is the number of recent thread subjects kept in a CustomerHistory
+const customerHistorySubjects = 3
+
+// GetCustomerHistory summarizes the threads email took part in, for the chat context
+// A customer without prior threads gets a history with ThreadCount 0.
+func (ees *EmailEmbeddingService) GetCustomerHistory(email string) (*models.CustomerHistory, error) {
+	threads, err := ees.FindThreadsByParticipant(email)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &models.CustomerHistory{
+		Email:       strings.ToLower(strings.TrimSpace(email)),
+		ThreadCount: len(threads),
+		Subjects:    []string{},
+	}
+	// Threads are ordered by last_date, most recent first
+	for _, thread := range threads {
+		if thread.LastDate.After(history.LastContact) {
+			history.LastContact = thread.LastDate
+		}
+		subject := strings.TrimSpace(thread.Subject)
+		if subject == "" || len(history.Subjects) == customerHistorySubjects {
+			continue
+		}
+		history.Subjects = append(history.Subjects, subject)
+	}
+	return history, nil
+}
@@ -0,0 +1,151 @@
+package emails
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractParticipants(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  []string
+		expected []string
+	}{
+		{
+			name:     "display names are dropped and addresses lowercased",
+			headers:  []string{`"John Doe" <John.Doe@Example.com>`, "support@israeldefensestore.com"},
+			expected: []string{"john.doe@example.com", "support@israeldefensestore.com"},
+		},
+		{
+			name:     "address lists",
+			headers:  []string{"a@example.com", "b@example.com, Carol <c@example.com>"},
+			expected: []string{"a@example.com", "b@example.com", "c@example.com"},
+		},
+		{
+			name:     "duplicates across headers keep first appearance",
+			headers:  []string{"Customer <buyer@example.com>", "support@store.com, BUYER@example.com", "support@store.com"},
+			expected: []string{"buyer@example.com", "support@store.com"},
+		},
+		{
+			name:     "encoded display names",
+			headers:  []string{"=?UTF-8?B?16nXnNeV150=?= <shalom@example.co.il>"},
+			expected: []string{"shalom@example.co.il"},
+		},
+		{
+			name:     "malformed headers fall back to bare addresses",
+			headers:  []string{"John Doe john@example.com; <jane@example.com", "undisclosed-recipients:;"},
+			expected: []string{"john@example.com", "jane@example.com"},
+		},
+		{
+			name:     "empty headers",
+			headers:  []string{"", "  "},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ExtractParticipants(tt.headers...))
+		})
+	}
+}
+
+func TestRebuildThreadParticipants_DedupsPerThread(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	mock.ExpectQuery(`SELECT e.thread_id, e.from_addr, e.to_addr\s+FROM emails e\s+JOIN email_threads et`).
+		WillReturnRows(sqlmock.NewRows([]string{"thread_id", "from_addr", "to_addr"}).
+			AddRow("thread-a", "Buyer <buyer@example.com>", "support@store.com").
+			AddRow("thread-a", "support@store.com", "BUYER@example.com").
+			AddRow("thread-b", "other@example.com", "support@store.com"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM thread_participants`).WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec(`INSERT INTO thread_participants \(thread_id, address\)\s+SELECT \$1, unnest\(\$2::text\[\]\)`).
+		WithArgs("thread-a", pq.Array([]string{"buyer@example.com", "support@store.com"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO thread_participants`).
+		WithArgs("thread-b", pq.Array([]string{"other@example.com", "support@store.com"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	threads, err := service.RebuildThreadParticipants()
+	require.NoError(t, err)
+	assert.Equal(t, 2, threads)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRebuildThreadParticipants_RollsBackOnInsertError(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	mock.ExpectQuery(`FROM emails e`).
+		WillReturnRows(sqlmock.NewRows([]string{"thread_id", "from_addr", "to_addr"}).
+			AddRow("thread-a", "buyer@example.com", "support@store.com"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM thread_participants`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO thread_participants`).WillReturnError(errors.New("connection refused"))
+	mock.ExpectRollback()
+
+	threads, err := service.RebuildThreadParticipants()
+	assert.ErrorContains(t, err, "failed to store participants of thread thread-a")
+	assert.Zero(t, threads)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindThreadsByParticipant(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	date := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM thread_participants tp\s+JOIN email_threads et ON et.thread_id = tp.thread_id\s+WHERE tp.address = \$1\s+ORDER BY et.last_date DESC`).
+		WithArgs("buyer@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"thread_id", "subject", "email_count", "first_date", "last_date", "summary", "created_at", "updated_at"}).
+			AddRow("thread-a", "Holster question", 3, date, date, "", date, date))
+
+	threads, err := service.FindThreadsByParticipant("  Buyer@Example.com ")
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	assert.Equal(t, "thread-a", threads[0].ThreadID)
+	assert.Equal(t, 3, threads[0].EmailCount)
+
+	_, err = service.FindThreadsByParticipant(" ")
+	assert.ErrorContains(t, err, "participant address is required")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCustomerHistory(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+	newer := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	older := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM thread_participants tp`).
+		WithArgs("buyer@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"thread_id", "subject", "email_count", "first_date", "last_date", "summary", "created_at", "updated_at"}).
+			AddRow("thread-a", "Holster sizing", 3, newer, newer, "", newer, newer).
+			AddRow("thread-b", " ", 1, older, older, "", older, older).
+			AddRow("thread-c", "Order delayed", 2, older, older, "", older, older).
+			AddRow("thread-d", "Return label", 2, older, older, "", older, older).
+			AddRow("thread-e", "Plate carrier", 1, older, older, "", older, older))
+
+	history, err := service.GetCustomerHistory(" Buyer@Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "buyer@example.com", history.Email)
+	assert.Equal(t, 5, history.ThreadCount)
+	assert.Equal(t, []string{"Holster sizing", "Order delayed", "Return label"}, history.Subjects)
+	assert.Equal(t, newer, history.LastContact)
+
+	mock.ExpectQuery(`FROM thread_participants tp`).
+		WithArgs("new@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"thread_id", "subject", "email_count", "first_date", "last_date", "summary", "created_at", "updated_at"}))
+
+	history, err = service.GetCustomerHistory("new@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, history.ThreadCount)
+	assert.Empty(t, history.Subjects)
+	assert.True(t, history.LastContact.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
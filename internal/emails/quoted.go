@@ -0,0 +1,96 @@
+package emails
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// replyHeaderPattern matches Gmail/Apple Mail reply headers: "On Mon, 2 Feb 2026, Dana <d@x.com> wrote:"
+	replyHeaderPattern = regexp.MustCompile(`(?i)^on\s.+\swrote:$`)
+	// hebrewReplyHeaderPattern matches Gmail's Hebrew reply header: "בתאריך ..., מאת ... כתב/ה:"
+	hebrewReplyHeaderPattern = regexp.MustCompile(`^בתאריך\s.+כתב(/ה)?:$`)
+	// originalMessagePattern matches Outlook's "-----Original Message-----" separator
+	originalMessagePattern = regexp.MustCompile(`(?i)^-{2,}\s*original message\s*-{2,}$`)
+	// underscoreSeparatorPattern matches the rule Outlook puts above a quoted "From:" block
+	underscoreSeparatorPattern = regexp.MustCompile(`^_{10,}$`)
+	// outlookFieldPattern matches the header fields of an Outlook quoted block
+	outlookFieldPattern = regexp.MustCompile(`(?i)^(sent|date|to|cc|subject):`)
+)
+
+// outlookHeaderLookahead is how many lines after "From:" are searched for the rest of an Outlook header
+const outlookHeaderLookahead = 3
+
+// stripQuotedText removes quoted prior messages from a reply body, so threads don't embed the same
+// text once per reply. Lines starting with ">" are dropped, and everything from a reply header
+// ("On ... wrote:", "-----Original Message-----", an Outlook "From:/Sent:" block) onward is cut.
+// A body that is nothing but quoted text (e.g. a bare forward) is returned unchanged.
+func stripQuotedText(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+
+	kept := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		if isQuoteHeader(lines, i) {
+			break
+		}
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+			continue
+		}
+		kept = append(kept, lines[i])
+	}
+
+	stripped := strings.TrimSpace(strings.Join(kept, "\n"))
+	if stripped == "" {
+		return body
+	}
+	return stripped
+}
+
+// isQuoteHeader reports whether lines[i] starts a quoted prior message
+func isQuoteHeader(lines []string, i int) bool {
+	line := strings.TrimSpace(lines[i])
+	switch {
+	case line == "":
+		return false
+	case replyHeaderPattern.MatchString(line), hebrewReplyHeaderPattern.MatchString(line):
+		return true
+	case originalMessagePattern.MatchString(line):
+		return true
+	case underscoreSeparatorPattern.MatchString(line):
+		next := nextNonEmptyLine(lines, i+1)
+		return next >= 0 && isOutlookHeader(lines, next)
+	}
+
+	// Gmail wraps long headers: "On Mon, 2 Feb 2026 at 10:00, Dana Cohen <dana@example.com>" / "wrote:"
+	if strings.HasPrefix(strings.ToLower(line), "on ") && i+1 < len(lines) {
+		if joined := line + " " + strings.TrimSpace(lines[i+1]); replyHeaderPattern.MatchString(joined) {
+			return true
+		}
+	}
+
+	return isOutlookHeader(lines, i)
+}
+
+// isOutlookHeader reports whether lines[i] is the "From:" line of an Outlook quoted block,
+// i.e. followed closely by "Sent:", "To:" or "Subject:" fields
+func isOutlookHeader(lines []string, i int) bool {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(lines[i])), "from:") {
+		return false
+	}
+	for j := i + 1; j < len(lines) && j <= i+outlookHeaderLookahead; j++ {
+		if outlookFieldPattern.MatchString(strings.TrimSpace(lines[j])) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextNonEmptyLine returns the index of the first non-blank line at or after start, or -1
+func nextNonEmptyLine(lines []string, start int) int {
+	for j := start; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) != "" {
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package emails
+
+import (
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripQuotedText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "gmail reply",
+			body: "Medium should fit a 34 inch waist.\n\nOn Mon, Feb 2, 2026 at 10:00 AM Dana <dana@example.com> wrote:\n> Which size fits a 34 inch waist?\n> Thanks",
+			want: "Medium should fit a 34 inch waist.",
+		},
+		{
+			name: "gmail reply with wrapped header",
+			body: "Thanks, ordering now.\r\n\r\nOn Mon, Feb 2, 2026 at 10:00 AM Israel Defense Store Support <support@israeldefensestore.com>\r\nwrote:\r\n\r\n> Medium should fit.\r\n",
+			want: "Thanks, ordering now.",
+		},
+		{
+			name: "hebrew gmail reply",
+			body: "תודה רבה!\n\nבתאריך יום ב׳, 2 בפבר׳ 2026 ב-10:00 מאת Support <support@israeldefensestore.com>‏ כתב/ה:\n> המידה M מתאימה",
+			want: "תודה רבה!",
+		},
+		{
+			name: "outlook reply",
+			body: "Please send the invoice again.\n\nFrom: Support <support@israeldefensestore.com>\nSent: Monday, February 2, 2026 10:00 AM\nTo: Dana <dana@example.com>\nSubject: RE: Order 1042\n\nYour order has shipped.",
+			want: "Please send the invoice again.",
+		},
+		{
+			name: "outlook reply with separator",
+			body: "Got it, thanks.\n\n________________________________\nFrom: Support <support@israeldefensestore.com>\nDate: Monday, February 2, 2026\nSubject: Order 1042\n\nYour order has shipped.",
+			want: "Got it, thanks.",
+		},
+		{
+			name: "outlook original message",
+			body: "See below.\n\n-----Original Message-----\nFrom: Dana\nThe holster arrived scratched.",
+			want: "See below.",
+		},
+		{
+			name: "inline quotes between answers",
+			body: "> Do you ship to Canada?\nYes, within 7 days.\n> And the US?\nYes, within 5 days.",
+			want: "Yes, within 7 days.\nYes, within 5 days.",
+		},
+		{
+			name: "from in the text is not a header",
+			body: "From: my experience the medium runs large.\nI would order a small.",
+			want: "From: my experience the medium runs large.\nI would order a small.",
+		},
+		{
+			name: "only quoted text is kept as is",
+			body: "> Which size fits a 34 inch waist?",
+			want: "> Which size fits a 34 inch waist?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stripQuotedText(tt.body))
+		})
+	}
+}
+
+func TestBuildTexts_StripQuotedReplies(t *testing.T) {
+	service := &EmailEmbeddingService{}
+	question := models.Email{Subject: "Holster sizing", Body: "Which size fits a 34 inch waist?", IsCustomer: true}
+	answer := models.Email{
+		Subject: "Re: Holster sizing",
+		Body:    "Medium should fit.\n\nOn Mon, Feb 2, 2026 at 10:00 AM Dana <dana@example.com> wrote:\n> Which size fits a 34 inch waist?",
+	}
+
+	emailText := service.buildEmailText(answer)
+	assert.Contains(t, emailText, "Message: Medium should fit.")
+	assert.NotContains(t, emailText, "34 inch")
+
+	threadText := service.buildThreadText([]models.Email{question, answer})
+	assert.Equal(t, "Thread: Holster sizing | Customer: Which size fits a 34 inch waist? | Support: Medium should fit.", threadText)
+}
@@ -0,0 +1,70 @@
+package emails
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EmailSearchFilters narrow SearchSimilarEmailsFiltered results with SQL predicates applied
+// before the pgvector ordering. The zero value matches every email.
+type EmailSearchFilters struct {
+	Since        *time.Time // Threads last active (emails sent) at or after Since
+	Until        *time.Time // Threads last active (emails sent) at or before Until
+	CustomerOnly bool       // Customer emails only; threads need at least one matching customer email
+	From         string     // Case-insensitive substring of the sender address or name
+}
+
+// IsEmpty reports whether no filter is set
+func (f EmailSearchFilters) IsEmpty() bool {
+	return f.Since == nil && f.Until == nil && !f.CustomerOnly && strings.TrimSpace(f.From) == ""
+}
+
+// args returns the query parameters for the filter predicates; unset filters bind NULL
+func (f EmailSearchFilters) args() []interface{} {
+	var from interface{}
+	if trimmed := strings.TrimSpace(f.From); trimmed != "" {
+		from = strings.ToLower(trimmed)
+	}
+	return []interface{}{f.Since, f.Until, f.CustomerOnly, from}
+}
+
+// threadFilterPredicates returns the EmailSearchFilters predicates over email_embeddings thread rows,
+// binding parameters $first to $first+3. The date range applies to email_threads.last_date; the
+// customer and sender filters require a matching email in the thread.
+func threadFilterPredicates(first int) string {
+	return fmt.Sprintf(`
+				  AND EXISTS (
+					SELECT 1 FROM email_threads ft
+					WHERE ft.thread_id = email_embeddings.thread_id
+					  AND ($%[1]d::timestamp IS NULL OR ft.last_date >= $%[1]d::timestamp)
+					  AND ($%[2]d::timestamp IS NULL OR ft.last_date <= $%[2]d::timestamp)
+				  )
+				  AND (($%[3]d::boolean IS NOT TRUE AND $%[4]d::text IS NULL) OR EXISTS (
+					SELECT 1 FROM emails fe
+					WHERE fe.thread_id = email_embeddings.thread_id
+					  AND ($%[3]d::boolean IS NOT TRUE OR fe.is_customer)
+					  AND ($%[4]d::text IS NULL OR strpos(lower(fe.from_addr), $%[4]d::text) > 0)
+				  ))`,
+		first, first+1, first+2, first+3)
+}
+
+// emailFilterPredicates returns the EmailSearchFilters predicates over individual emails (alias e),
+// binding parameters $first to $first+3. The date range applies to the email's own date.
+func emailFilterPredicates(first int) string {
+	return fmt.Sprintf(`
+			  AND ($%[1]d::timestamp IS NULL OR e.date >= $%[1]d::timestamp)
+			  AND ($%[2]d::timestamp IS NULL OR e.date <= $%[2]d::timestamp)
+			  AND ($%[3]d::boolean IS NOT TRUE OR e.is_customer)
+			  AND ($%[4]d::text IS NULL OR strpos(lower(e.from_addr), $%[4]d::text) > 0)`,
+		first, first+1, first+2, first+3)
+}
+
+// filterSQL returns the predicates for filters starting at parameter $3 (after the query vector
+// and limit), or nothing when no filter is set so unfiltered searches keep their plan
+func filterSQL(filters EmailSearchFilters, predicates func(first int) string) string {
+	if filters.IsEmpty() {
+		return ""
+	}
+	return predicates(3)
+}
@@ -0,0 +1,95 @@
+package emails
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailSearchFilters_IsEmpty(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.True(t, EmailSearchFilters{}.IsEmpty())
+	assert.True(t, EmailSearchFilters{From: "  "}.IsEmpty())
+	assert.False(t, EmailSearchFilters{Since: &since}.IsEmpty())
+	assert.False(t, EmailSearchFilters{CustomerOnly: true}.IsEmpty())
+	assert.False(t, EmailSearchFilters{From: "dana"}.IsEmpty())
+}
+
+func TestEmailSearchFilters_ArgsBindNullForUnsetFilters(t *testing.T) {
+	until := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	args := EmailSearchFilters{Until: &until, From: " Dana@Example.com "}.args()
+	require.Len(t, args, 4)
+	assert.Nil(t, args[0])
+	assert.Equal(t, &until, args[1])
+	assert.Equal(t, false, args[2])
+	assert.Equal(t, "dana@example.com", args[3])
+
+	assert.Nil(t, EmailSearchFilters{CustomerOnly: true}.args()[3])
+}
+
+func TestSearchSimilarEmailsFiltered_ThreadSearch(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)
+	columns := []string{"embedding_str", "id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "is_customer",
+		"thread_id", "subject", "email_count", "first_date", "last_date", "summary", "distance"}
+
+	// Predicates sit inside the CTE, ahead of the pgvector ordering and limit
+	mock.ExpectQuery(`WHERE thread_id IS NOT NULL\s+AND EXISTS \(\s+SELECT 1 FROM email_threads ft.*ft.last_date >= \$3::timestamp.*ft.last_date <= \$4::timestamp.*fe.is_customer.*strpos\(lower\(fe.from_addr\), \$6::text\) > 0.*ORDER BY embedding <=> \$1::vector\s+LIMIT \$2`).
+		WithArgs(sqlmock.AnyArg(), 5, &since, &until, true, "dana").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("", 4, "<4@x>", "Re: Holster sizing", "dana@example.com", "support@ids.com", date, "Medium fits.", "thread-a", true,
+				"thread-a", "Holster sizing", 2, date, date, "", 0.2))
+
+	results, err := service.SearchSimilarEmailsFiltered("holster sizing", 5, true, EmailSearchFilters{
+		Since:        &since,
+		Until:        &until,
+		CustomerOnly: true,
+		From:         "Dana",
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.NotNil(t, results[0].Thread)
+	assert.Equal(t, "thread-a", results[0].Thread.ThreadID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilarEmailsFiltered_EmailSearch(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)
+	columns := []string{"embedding", "id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "is_customer", "distance"}
+
+	mock.ExpectQuery(`WHERE ee.email_id IS NOT NULL\s+AND \(\$3::timestamp IS NULL OR e.date >= \$3::timestamp\).*e.date <= \$4::timestamp.*\$5::boolean IS NOT TRUE OR e.is_customer.*strpos\(lower\(e.from_addr\), \$6::text\) > 0\)\s+ORDER BY ee.embedding <=> \$1::vector\s+LIMIT \$2`).
+		WithArgs(sqlmock.AnyArg(), 3, &since, nil, false, nil).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("[0.1,0.2,0.3]", 7, "<7@x>", "Shipping", "buyer@example.com", "support@ids.com", date, "Where is my order?", nil, true, 0.1))
+
+	results, err := service.SearchSimilarEmailsFiltered("shipping", 3, false, EmailSearchFilters{Since: &since})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 7, results[0].Email.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilarEmailsFiltered_EmptyFiltersKeepUnfilteredQuery(t *testing.T) {
+	service, mock := newTestEmailService(t, nil)
+
+	columns := []string{"embedding", "id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "is_customer", "distance"}
+	mock.ExpectQuery(`WHERE ee.email_id IS NOT NULL\s+ORDER BY ee.embedding <=> \$1::vector\s+LIMIT \$2`).
+		WithArgs(sqlmock.AnyArg(), 5).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	results, err := service.SearchSimilarEmails("shipping", 5, false)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,126 @@
+package emails
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"ids/internal/analytics"
+	"ids/internal/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Thread summarization settings
+const (
+	threadSummaryModel     = openai.GPT4oMini
+	threadSummaryMaxTokens = 300
+	threadSummaryMaxInput  = 12000 // Longest thread text sent for summarization, in characters
+)
+
+// threadSummaryPrompt instructs the model on the summaries shown to the chat model as past conversations
+const threadSummaryPrompt = "You summarize customer support email threads for a tactical gear store. " +
+	"In 2-4 sentences, state the customer's question or issue, the products involved, and how support resolved it. " +
+	"Leave out greetings, signatures, names, email addresses and phone numbers."
+
+// SetAnalyticsService sets the analytics service used to track summarization usage (optional)
+func (ees *EmailEmbeddingService) SetAnalyticsService(analyticsService *analytics.Service) {
+	ees.analytics = analyticsService
+}
+
+// SummarizeThread asks the chat completion API for a short summary of a thread and stores it
+// in email_threads.summary, where the chat handler prefers it over the raw email bodies
+func (ees *EmailEmbeddingService) SummarizeThread(threadID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	emails, err := ees.GetThreadEmails(ctx, threadID)
+	if err != nil {
+		return fmt.Errorf("failed to get thread emails: %w", err)
+	}
+	if len(emails) == 0 {
+		return fmt.Errorf("thread %s has no emails", threadID)
+	}
+
+	resp, err := ees.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: threadSummaryModel,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: threadSummaryPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: buildSummaryInput(emails)},
+		},
+		MaxTokens:   threadSummaryMaxTokens,
+		Temperature: 0.3,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to summarize thread: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return fmt.Errorf("no summary returned for thread %s", threadID)
+	}
+
+	// Track thread summarization (billable)
+	if ees.analytics != nil {
+		if err := ees.analytics.TrackSupportSummarization(resp.Usage.TotalTokens, threadSummaryModel); err != nil {
+			fmt.Printf("[THREAD_SUMMARIES] Warning: Failed to track summarization: %v\n", err)
+		}
+	}
+
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if summary == "" {
+		return fmt.Errorf("empty summary returned for thread %s", threadID)
+	}
+
+	query := `UPDATE email_threads SET summary = $1, updated_at = CURRENT_TIMESTAMP WHERE thread_id = $2`
+	if _, err := ees.db.ExecuteWriteQuery(query, summary, threadID); err != nil {
+		return fmt.Errorf("failed to store thread summary: %w", err)
+	}
+	return nil
+}
+
+// SummarizeMissingThreads summarizes every thread without a summary and returns how many were summarized
+// Failures are logged and skipped so one bad thread doesn't stop the run.
+func (ees *EmailEmbeddingService) SummarizeMissingThreads() (int, error) {
+	var threadIDs []string
+	query := `
+		SELECT thread_id FROM email_threads
+		WHERE summary IS NULL OR TRIM(summary) = ''
+		ORDER BY last_date DESC
+	`
+	if err := ees.db.ExecuteWriteQueryWithResult(&threadIDs, query); err != nil {
+		return 0, fmt.Errorf("failed to get threads without summaries: %w", err)
+	}
+
+	fmt.Printf("[THREAD_SUMMARIES] Summarizing %d threads\n", len(threadIDs))
+	summarized := 0
+	for _, threadID := range threadIDs {
+		if err := ees.SummarizeThread(threadID); err != nil {
+			fmt.Printf("[THREAD_SUMMARIES] Error summarizing thread %s: %v\n", threadID, err)
+			continue
+		}
+		summarized++
+	}
+
+	fmt.Printf("[THREAD_SUMMARIES] Summarized %d of %d threads\n", summarized, len(threadIDs))
+	return summarized, nil
+}
+
+// buildSummaryInput renders a thread as a transcript, truncated to threadSummaryMaxInput characters
+func buildSummaryInput(emails []models.Email) string {
+	var transcript strings.Builder
+	fmt.Fprintf(&transcript, "Subject: %s\n", emails[0].Subject)
+
+	for _, email := range emails {
+		role := "Support"
+		if email.IsCustomer {
+			role = "Customer"
+		}
+		fmt.Fprintf(&transcript, "\n%s: %s\n", role, strings.TrimSpace(email.Body))
+	}
+
+	text := []rune(transcript.String())
+	if len(text) > threadSummaryMaxInput {
+		return string(text[:threadSummaryMaxInput]) + "..."
+	}
+	return string(text)
+}
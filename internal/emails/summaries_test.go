@@ -0,0 +1,129 @@
+package emails
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var threadEmailColumns = []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+
+// newSummaryTestService builds an EmailEmbeddingService whose chat completions return summary
+// The user message of every completion request is recorded in prompts.
+func newSummaryTestService(t *testing.T, summary string, prompts *[]string) (*EmailEmbeddingService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, threadSummaryModel, req.Model)
+		*prompts = append(*prompts, req.Messages[len(req.Messages)-1].Content)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: summary}}},
+			Usage:   openai.Usage{TotalTokens: 120},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+
+	return &EmailEmbeddingService{
+		client: openai.NewClientWithConfig(clientConfig),
+		db:     database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")),
+	}, mock
+}
+
+func expectThreadEmails(mock sqlmock.Sqlmock, threadID string, bodies ...string) {
+	rows := sqlmock.NewRows(threadEmailColumns)
+	for i, body := range bodies {
+		rows.AddRow(i+1, threadID+"-msg", "Holster sizing", "buyer@example.com", "support@store.com",
+			time.Date(2026, 1, 1+i, 0, 0, 0, 0, time.UTC), body, threadID, nil, nil, i%2 == 0)
+	}
+	mock.ExpectQuery(`FROM emails\s+WHERE thread_id = \$1`).WithArgs(threadID).WillReturnRows(rows)
+}
+
+func TestSummarizeThread_StoresSummary(t *testing.T) {
+	var prompts []string
+	service, mock := newSummaryTestService(t, "  Customer asked about sizing; support suggested medium.  ", &prompts)
+
+	expectThreadEmails(mock, "thread-a", "Which size fits a Glock 19?", "The medium fits the Glock 19.")
+	mock.ExpectExec(`UPDATE email_threads SET summary = \$1, updated_at = CURRENT_TIMESTAMP WHERE thread_id = \$2`).
+		WithArgs("Customer asked about sizing; support suggested medium.", "thread-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, service.SummarizeThread("thread-a"))
+
+	require.Len(t, prompts, 1)
+	assert.Contains(t, prompts[0], "Subject: Holster sizing")
+	assert.Contains(t, prompts[0], "Customer: Which size fits a Glock 19?")
+	assert.Contains(t, prompts[0], "Support: The medium fits the Glock 19.")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSummarizeThread_EmptyThread(t *testing.T) {
+	var prompts []string
+	service, mock := newSummaryTestService(t, "unused", &prompts)
+
+	expectThreadEmails(mock, "thread-a")
+
+	err := service.SummarizeThread("thread-a")
+	assert.ErrorContains(t, err, "thread thread-a has no emails")
+	assert.Empty(t, prompts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSummarizeThread_EmptySummaryIsNotStored(t *testing.T) {
+	var prompts []string
+	service, mock := newSummaryTestService(t, "   ", &prompts)
+
+	expectThreadEmails(mock, "thread-a", "Which size fits a Glock 19?")
+
+	err := service.SummarizeThread("thread-a")
+	assert.ErrorContains(t, err, "empty summary")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSummarizeMissingThreads_SkipsFailures(t *testing.T) {
+	var prompts []string
+	service, mock := newSummaryTestService(t, "Short summary.", &prompts)
+
+	mock.ExpectQuery(`SELECT thread_id FROM email_threads\s+WHERE summary IS NULL OR TRIM\(summary\) = ''`).
+		WillReturnRows(sqlmock.NewRows([]string{"thread_id"}).AddRow("thread-a").AddRow("thread-b"))
+	mock.ExpectQuery(`FROM emails\s+WHERE thread_id = \$1`).WithArgs("thread-a").WillReturnError(errors.New("connection reset"))
+	expectThreadEmails(mock, "thread-b", "Do you ship to Canada?")
+	mock.ExpectExec(`UPDATE email_threads SET summary`).
+		WithArgs("Short summary.", "thread-b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	summarized, err := service.SummarizeMissingThreads()
+	require.NoError(t, err)
+	assert.Equal(t, 1, summarized)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildSummaryInput_Truncates(t *testing.T) {
+	input := buildSummaryInput([]models.Email{{Subject: "Long", Body: strings.Repeat("ש", threadSummaryMaxInput), IsCustomer: true}})
+
+	assert.True(t, strings.HasSuffix(input, "..."))
+	assert.Equal(t, threadSummaryMaxInput+3, len([]rune(input)))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"ids/internal/cache"
@@ -23,9 +24,41 @@ type EmbeddingService struct {
 	db            *sqlx.DB              // MariaDB - only for reading product data when generating embeddings
 	writeClient   *database.WriteClient // PostgreSQL - for searching embeddings
 	tagTokenSet   map[string]struct{}
+	productMu     sync.RWMutex           // Guards db and tagTokenSet, which are replaced when the read database reconnects
 	cache         *cache.Cache           // Query embedding cache
 	qdrantClient  *vectordb.QdrantClient // Qdrant client for vector search (optional)
 	qdrantEnabled bool                   // Feature flag for Qdrant search reads
+	recencyBoost  float64                // Maximum boost for newly published products (0 disables)
+	recencyWindow time.Duration          // Age after which products get no recency boost
+
+	boosting       bool         // Apply keyword and tag boosts on top of vector similarity (ENABLE_TERM_BOOSTING)
+	tokenFiltering bool         // Drop results missing required query tokens (ENABLE_TOKEN_FILTERING)
+	minSimilarity  float64      // Drop results scoring below this similarity (SEARCH_MIN_SIMILARITY)
+	weights        scoreWeights // Weighting between vector similarity and keyword score
+
+	synonyms         map[string][]string // Query token -> synonyms for term boosting (synonyms table or defaults)
+	synonymsPerToken int                 // Maximum synonyms added per query token (0 = unlimited)
+	synonymsTotal    int                 // Maximum synonyms added per query (0 = unlimited)
+
+	documentPrefix string      // Prepended to product text before embedding (EMBEDDING_INPUT_PREFIX)
+	queryPrefix    string      // Prepended to search queries before embedding (QUERY_INPUT_PREFIX)
+	searchMode     string      // pgvector retrieval: vector or hybrid full-text fusion (SEARCH_MODE)
+	requireTitle   bool        // Exclude untitled products instead of showing them by slug or SKU (SEARCH_REQUIRE_TITLE)
+	distanceMetric string      // pgvector distance metric: cosine, l2 or inner_product (VECTOR_DISTANCE_METRIC)
+	tuning         indexTuning // Per-query pgvector index settings (HNSW_EF_SEARCH, IVFFLAT_PROBES)
+
+	promotion promotion // Keeps loosely relevant products with PROMOTED_TAGS in results
+
+	descriptionFields string // Descriptions included in the product text: full, short or both (EMBEDDING_DESCRIPTION_FIELDS)
+}
+
+// SearchOptions controls how vector search results are refined for a single query
+type SearchOptions struct {
+	Boosting       bool          // Apply keyword and tag boosts on top of vector similarity
+	TokenFiltering bool          // Drop results missing required query tokens
+	MinSimilarity  float64       // Drop results scoring below this similarity (0 keeps all)
+	Filters        SearchFilters // Facet predicates applied in SQL before ranking (forces pgvector search)
+	Trace          *SearchTrace  // Filled with the search internals when non-nil (debug responses)
 }
 
 // ProductEmbedding represents a product with its vector embedding
@@ -33,6 +66,7 @@ type ProductEmbedding struct {
 	Product    models.Product `json:"product"`
 	Embedding  []float64      `json:"embedding"`
 	Similarity float64        `json:"similarity,omitempty"`
+	Distance   float64        `json:"-"` // Raw pgvector distance behind Similarity
 }
 
 // NewEmbeddingService creates a new embedding service
@@ -58,15 +92,41 @@ func NewEmbeddingService(cfg *config.Config, db *sqlx.DB, writeClient *database.
 		client.GetProviderName(), client.GetEmbeddingModel())
 
 	service := &EmbeddingService{
-		client:      client,
-		db:          db,
-		writeClient: writeClient,
+		client:        client,
+		db:            db,
+		writeClient:   writeClient,
+		recencyBoost:  cfg.RecencyBoostWeight,
+		recencyWindow: time.Duration(cfg.RecencyWindowDays) * 24 * time.Hour,
+
+		boosting:       cfg.EnableTermBoosting,
+		tokenFiltering: cfg.EnableTokenFiltering,
+		minSimilarity:  cfg.SearchMinSimilarity,
+		weights:        scoreWeights{Similarity: cfg.SimilarityWeight, Keyword: cfg.KeywordWeight, TagPhrase: cfg.TagPhraseBoost},
+
+		synonyms:         loadSynonymMap(writeClient, "EMBEDDING_SERVICE"),
+		synonymsPerToken: cfg.SynonymsPerToken,
+		synonymsTotal:    cfg.SynonymsTotal,
+
+		documentPrefix: cfg.EmbeddingInputPrefix,
+		queryPrefix:    cfg.QueryInputPrefix,
+		searchMode:     cfg.SearchMode,
+		requireTitle:   cfg.SearchRequireTitle,
+		distanceMetric: cfg.DistanceMetric,
+		tuning:         indexTuningFromConfig(cfg),
+
+		promotion: newPromotion(cfg.PromotedTags, cfg.PromotedMinSimilarity, cfg.PromotedMaxResults),
+
+		descriptionFields: cfg.EmbeddingDescriptionFields,
 	}
 
 	// Set cache if provided
 	if len(embeddingCache) > 0 && embeddingCache[0] != nil {
 		service.cache = embeddingCache[0]
-		fmt.Printf("[EMBEDDING_SERVICE] Query embedding cache enabled (TTL: %v)\n", cache.EmbeddingCacheTTL)
+		// Vectors from a previous embedding model are not comparable with the current index
+		if service.cache.SetEmbeddingModel(service.client.GetEmbeddingModel()) {
+			fmt.Printf("[EMBEDDING_SERVICE] Embedding model changed to %s, cleared cached query embeddings\n", service.client.GetEmbeddingModel())
+		}
+		fmt.Printf("[EMBEDDING_SERVICE] Query embedding cache enabled (TTL: %v)\n", service.cache.EmbeddingTTL())
 	}
 
 	// Load tag tokens from MariaDB (only needed when generating embeddings)
@@ -90,6 +150,33 @@ func (es *EmbeddingService) SetQdrantClient(client *vectordb.QdrantClient, enabl
 	}
 }
 
+// SetProductDB swaps in a new read database connection, e.g. after it reconnects, and reloads the tag tokens
+func (es *EmbeddingService) SetProductDB(db *sqlx.DB) {
+	es.productMu.Lock()
+	es.db = db
+	es.productMu.Unlock()
+
+	if db != nil {
+		if err := es.loadTagTokens(); err != nil {
+			fmt.Printf("[EMBEDDING_SERVICE] WARNING: Failed to load tag tokens for filtering: %v\n", err)
+		}
+	}
+}
+
+// productDB returns the read database connection, nil while it is unavailable
+func (es *EmbeddingService) productDB() *sqlx.DB {
+	es.productMu.RLock()
+	defer es.productMu.RUnlock()
+	return es.db
+}
+
+// tagTokens returns the product tag tokens used for query token filtering
+func (es *EmbeddingService) tagTokens() map[string]struct{} {
+	es.productMu.RLock()
+	defer es.productMu.RUnlock()
+	return es.tagTokenSet
+}
+
 func (es *EmbeddingService) loadTagTokens() error {
 	fmt.Printf("[EMBEDDING_SERVICE] Loading product tag tokens for query filtering...\n")
 
@@ -104,7 +191,7 @@ func (es *EmbeddingService) loadTagTokens() error {
 	defer cancel()
 
 	var tagNames []string
-	if err := es.db.SelectContext(ctx, &tagNames, query); err != nil {
+	if err := es.productDB().SelectContext(ctx, &tagNames, query); err != nil {
 		return fmt.Errorf("failed to load product tags: %w", err)
 	}
 
@@ -116,13 +203,74 @@ func (es *EmbeddingService) loadTagTokens() error {
 		}
 	}
 
+	es.productMu.Lock()
 	es.tagTokenSet = tokenSet
+	es.productMu.Unlock()
 	fmt.Printf("[EMBEDDING_SERVICE] Loaded %d unique tag tokens\n", len(tokenSet))
 	return nil
 }
 
+// ListProductsMissingEmbeddings returns source products that have no row in product_embeddings,
+// ordered by product ID. Products live in MariaDB and embeddings in PostgreSQL, so the diff is done in Go.
+func (es *EmbeddingService) ListProductsMissingEmbeddings(limit, offset int) ([]models.Product, error) {
+	query := `
+		SELECT
+			p.ID,
+			p.post_title,
+			p.post_name,
+			l.sku,
+			l.stock_status
+		FROM wpjr_wc_product_meta_lookup l
+		JOIN wpjr_posts p ON p.ID = l.product_id
+		WHERE p.post_type = 'product'
+			AND p.post_status IN ('publish','private')
+		ORDER BY p.ID
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var products []models.Product
+	db := es.productDB()
+	if db == nil {
+		return nil, fmt.Errorf("product database not connected")
+	}
+	if err := db.SelectContext(ctx, &products, query); err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	var embeddedIDs []int
+	if err := es.writeClient.ExecuteWriteQueryWithResult(&embeddedIDs, "SELECT product_id FROM product_embeddings"); err != nil {
+		return nil, fmt.Errorf("failed to fetch embedded product IDs: %w", err)
+	}
+
+	embedded := make(map[int]struct{}, len(embeddedIDs))
+	for _, id := range embeddedIDs {
+		embedded[id] = struct{}{}
+	}
+
+	missing := make([]models.Product, 0)
+	skipped := 0
+	for _, product := range products {
+		if _, ok := embedded[product.ID]; ok {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		if len(missing) >= limit {
+			break
+		}
+		missing = append(missing, product)
+	}
+
+	return missing, nil
+}
+
 // GenerateProductEmbeddings generates embeddings for all products
-func (es *EmbeddingService) GenerateProductEmbeddings() error {
+// Canceling ctx stops generation before the next batch
+func (es *EmbeddingService) GenerateProductEmbeddings(ctx context.Context) error {
 	fmt.Printf("[EMBEDDING_GEN] ===== STARTING EMBEDDING GENERATION =====\n")
 
 	// Get all products from database
@@ -155,10 +303,14 @@ func (es *EmbeddingService) GenerateProductEmbeddings() error {
 
 	fmt.Printf("[EMBEDDING_GEN] Fetching products from database...\n")
 	var products []models.Product
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	err := es.db.SelectContext(ctx, &products, query)
+	db := es.productDB()
+	if db == nil {
+		return fmt.Errorf("product database not connected")
+	}
+	err := db.SelectContext(fetchCtx, &products, query)
 	if err != nil {
 		fmt.Printf("[EMBEDDING_GEN] ERROR: Failed to fetch products: %v\n", err)
 		return fmt.Errorf("failed to fetch products: %v", err)
@@ -172,6 +324,10 @@ func (es *EmbeddingService) GenerateProductEmbeddings() error {
 	fmt.Printf("[EMBEDDING_GEN] Processing %d products in %d batches of %d\n", len(products), totalBatches, batchSize)
 
 	for i := 0; i < len(products); i += batchSize {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("embedding generation stopped before batch %d/%d: %w", (i/batchSize)+1, totalBatches, err)
+		}
+
 		end := i + batchSize
 		if end > len(products) {
 			end = len(products)
@@ -181,7 +337,7 @@ func (es *EmbeddingService) GenerateProductEmbeddings() error {
 		fmt.Printf("[EMBEDDING_GEN] Processing batch %d/%d (products %d-%d)...\n", batchNum, totalBatches, i+1, end)
 
 		batch := products[i:end]
-		if err := es.processBatch(batch); err != nil {
+		if err := es.processBatch(ctx, batch); err != nil {
 			fmt.Printf("[EMBEDDING_GEN] ERROR: Failed to process batch %d-%d: %v\n", i, end, err)
 			return fmt.Errorf("failed to process batch %d-%d: %v", i, end, err)
 		}
@@ -196,10 +352,11 @@ func (es *EmbeddingService) GenerateProductEmbeddings() error {
 // processBatch processes a batch of products and generates embeddings
 // processBatchCommon is a shared helper for processing batches of products
 func processBatchCommon(
+	ctx context.Context,
 	products []models.Product,
 	client *idsopenai.Client,
 	buildText func(models.Product) string,
-	storeEmbedding func(models.Product, []float64) error,
+	storeEmbedding func(context.Context, models.Product, []float64) error,
 	logPrefix string,
 ) error {
 	fmt.Printf("[%s] Processing batch of %d products\n", logPrefix, len(products))
@@ -213,10 +370,10 @@ func processBatchCommon(
 
 	// Generate embeddings using unified client (Azure/OpenAI with fallback)
 	fmt.Printf("[%s] Sending batch to %s API...\n", logPrefix, client.GetProviderName())
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	apiCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	embeddings, err := client.CreateEmbeddings(ctx, texts)
+	embeddings, err := client.CreateEmbeddings(apiCtx, texts)
 	if err != nil {
 		fmt.Printf("[%s] ERROR: Failed to generate embeddings: %v\n", logPrefix, err)
 		return fmt.Errorf("failed to generate embeddings: %v", err)
@@ -233,7 +390,7 @@ func processBatchCommon(
 		for j, v := range embeddingData {
 			embedding[j] = float64(v)
 		}
-		if err := storeEmbedding(product, embedding); err != nil {
+		if err := storeEmbedding(ctx, product, embedding); err != nil {
 			fmt.Printf("[%s] ERROR: Failed to store embedding for product %d: %v\n", logPrefix, product.ID, err)
 			return fmt.Errorf("failed to store embedding for product %d: %v", product.ID, err)
 		}
@@ -243,16 +400,40 @@ func processBatchCommon(
 	return nil
 }
 
-func (es *EmbeddingService) processBatch(products []models.Product) error {
+func (es *EmbeddingService) processBatch(ctx context.Context, products []models.Product) error {
 	return processBatchCommon(
+		ctx,
 		products,
 		es.client,
-		es.buildProductText,
+		es.documentText,
 		es.storeEmbedding,
 		"EMBEDDING_GEN",
 	)
 }
 
+// documentText returns the embedding input for a product, including the document prefix
+func (es *EmbeddingService) documentText(product models.Product) string {
+	return idsopenai.WithInputPrefix(es.documentPrefix, es.buildProductText(product))
+}
+
+// queryText returns the embedding input for a search query, including the query prefix
+func (es *EmbeddingService) queryText(query string) string {
+	return idsopenai.WithInputPrefix(es.queryPrefix, query)
+}
+
+// descriptionFields reports which product descriptions go into the embedding text
+// An unset mode keeps both, matching the EMBEDDING_DESCRIPTION_FIELDS default
+func descriptionFields(mode string) (full, short bool) {
+	switch mode {
+	case config.DescriptionFieldsFull:
+		return true, false
+	case config.DescriptionFieldsShort:
+		return false, true
+	default:
+		return true, true
+	}
+}
+
 // buildProductText creates a comprehensive text representation of a product
 func (es *EmbeddingService) buildProductText(product models.Product) string {
 	var parts []string
@@ -262,14 +443,13 @@ func (es *EmbeddingService) buildProductText(product models.Product) string {
 		parts = append(parts, product.PostTitle)
 	}
 
-	// Add description
-	if product.Description != nil && *product.Description != "" {
+	// Add the descriptions selected by EMBEDDING_DESCRIPTION_FIELDS
+	includeFull, includeShort := descriptionFields(es.descriptionFields)
+	if includeFull && product.Description != nil && *product.Description != "" {
 		desc := cleanHTMLDescription(*product.Description)
 		parts = append(parts, desc)
 	}
-
-	// Add short description
-	if product.ShortDescription != nil && *product.ShortDescription != "" {
+	if includeShort && product.ShortDescription != nil && *product.ShortDescription != "" {
 		parts = append(parts, *product.ShortDescription)
 	}
 
@@ -301,7 +481,7 @@ func (es *EmbeddingService) buildProductText(product models.Product) string {
 }
 
 // storeEmbedding stores a product embedding in PostgreSQL using pgvector
-func (es *EmbeddingService) storeEmbedding(product models.Product, embedding []float64) error {
+func (es *EmbeddingService) storeEmbedding(ctx context.Context, product models.Product, embedding []float64) error {
 	if es.writeClient == nil {
 		return fmt.Errorf("PostgreSQL write client not available")
 	}
@@ -318,7 +498,7 @@ func (es *EmbeddingService) storeEmbedding(product models.Product, embedding []f
 			updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := es.writeClient.ExecuteWriteQuery(query, product.ID, embeddingStr)
+	_, err := es.writeClient.ExecuteWriteQueryContext(ctx, query, product.ID, embeddingStr)
 	if err != nil {
 		return fmt.Errorf("failed to store embedding: %v", err)
 	}
@@ -326,18 +506,52 @@ func (es *EmbeddingService) storeEmbedding(product models.Product, embedding []f
 	return nil
 }
 
+// DefaultSearchOptions returns the search options configured for this service
+func (es *EmbeddingService) DefaultSearchOptions() SearchOptions {
+	return SearchOptions{
+		Boosting:       es.boosting,
+		TokenFiltering: es.tokenFiltering,
+		MinSimilarity:  es.minSimilarity,
+	}
+}
+
+// normalizeSearchQuery normalizes query and reports whether anything is left to search for;
+// embedding an empty query would waste an API call and only return noise
+func normalizeSearchQuery(query, logPrefix string) (string, bool) {
+	query = utils.NormalizeQuery(query)
+	if query == "" {
+		fmt.Printf("[%s] Skipping search: query is empty after normalization\n", logPrefix)
+		return "", false
+	}
+	return query, true
+}
+
 // SearchSimilarProducts finds products similar to the query using pgvector similarity
 // Uses Qdrant if enabled (QDRANT_ENABLED=true), otherwise falls back to PostgreSQL pgvector
-func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]ProductEmbedding, bool, error) {
+func (es *EmbeddingService) SearchSimilarProducts(ctx context.Context, query string, limit int) ([]ProductEmbedding, bool, error) {
+	return es.SearchSimilarProductsWithOptions(ctx, query, limit, es.DefaultSearchOptions())
+}
+
+// SearchSimilarProductsWithOptions is SearchSimilarProducts with per-query overrides of the
+// boosting, token filtering and minimum similarity settings
+func (es *EmbeddingService) SearchSimilarProductsWithOptions(ctx context.Context, query string, limit int, opts SearchOptions) ([]ProductEmbedding, bool, error) {
+	// Normalize so equivalent queries share an embedding and cache entry
+	query, ok := normalizeSearchQuery(query, "PRODUCT_EMBEDDINGS")
+	if !ok {
+		return []ProductEmbedding{}, false, nil
+	}
 	fmt.Printf("[PRODUCT_EMBEDDINGS] 🔍 Querying PRODUCT EMBEDDINGS datasource - Query: '%s', Limit: %d\n", query, limit)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	// Cache by the prefixed input so a QUERY_INPUT_PREFIX change never reuses stale vectors
+	input := es.queryText(query)
+
 	// Try to get embedding from cache first
 	var queryEmbedding []float32
 	if es.cache != nil {
-		if cachedEmbedding, found := es.cache.GetEmbedding(query); found {
+		if cachedEmbedding, found := es.cache.GetEmbedding(es.client.GetEmbeddingModel(), input); found {
 			fmt.Printf("[VECTOR_SEARCH] ✓ Cache HIT - using cached query embedding\n")
 			queryEmbedding = cachedEmbedding
 		}
@@ -346,7 +560,7 @@ func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]Pr
 	// Generate embedding if not in cache
 	if queryEmbedding == nil {
 		fmt.Printf("[VECTOR_SEARCH] Generating query embedding via %s...\n", es.client.GetProviderName())
-		embeddings, err := es.client.CreateEmbeddings(ctx, []string{query})
+		embeddings, err := es.client.CreateEmbeddings(ctx, []string{input})
 		if err != nil {
 			fmt.Printf("[VECTOR_SEARCH] ERROR: Failed to generate query embedding: %v\n", err)
 			return nil, false, fmt.Errorf("failed to generate query embedding: %v", err)
@@ -355,7 +569,7 @@ func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]Pr
 
 		// Store in cache for future requests
 		if es.cache != nil {
-			es.cache.SetEmbedding(query, queryEmbedding)
+			es.cache.SetEmbedding(es.client.GetEmbeddingModel(), input, queryEmbedding)
 			fmt.Printf("[VECTOR_SEARCH] ✓ Cached query embedding for future use\n")
 		}
 	}
@@ -363,9 +577,10 @@ func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]Pr
 	fmt.Printf("[VECTOR_SEARCH] Query embedding ready (dimensions: %d)\n", len(queryEmbedding))
 
 	// Use Qdrant for search if enabled
-	if es.qdrantEnabled && es.qdrantClient != nil {
+	// Filtered searches stay on pgvector, where the filters run as SQL predicates
+	if es.qdrantEnabled && es.qdrantClient != nil && opts.Filters.IsEmpty() {
 		fmt.Printf("[PRODUCT_EMBEDDINGS] Using Qdrant for vector search...\n")
-		return es.searchWithQdrant(ctx, query, queryEmbedding, limit)
+		return es.searchWithQdrant(ctx, query, queryEmbedding, limit, opts)
 	}
 
 	// Fall back to PostgreSQL pgvector
@@ -384,7 +599,7 @@ func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]Pr
 		fetchLimit = 50
 	}
 
-	rows, err := es.writeClient.GetDB().QueryContext(ctx, queryProductEmbeddingsPgvector, queryVectorStr, fetchLimit)
+	rows, err := queryProductCandidates(ctx, es.writeClient, es.searchMode, es.distanceMetric, query, queryVectorStr, fetchLimit, es.requireTitle, opts.Filters, es.tuning, "VECTOR_SEARCH")
 	if err != nil {
 		fmt.Printf("[PRODUCT_EMBEDDINGS] ❌ ERROR: Failed to execute pgvector query: %v\n", err)
 		return nil, false, fmt.Errorf("failed to execute pgvector query: %v", err)
@@ -395,7 +610,7 @@ func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]Pr
 		}
 	}()
 
-	results := ScanProductEmbeddingRows(rows, "VECTOR_SEARCH")
+	results := applyTitlePolicy(ScanProductEmbeddingRows(rows.Rows, es.distanceMetric, "VECTOR_SEARCH"), es.requireTitle, "VECTOR_SEARCH")
 
 	fmt.Printf("[VECTOR_SEARCH] pgvector returned %d products (already sorted by similarity)\n", len(results))
 
@@ -412,21 +627,25 @@ func (es *EmbeddingService) SearchSimilarProducts(query string, limit int) ([]Pr
 		}
 	}
 
-	requiredTokens := es.requiredTokensFromQuery(query)
-	fallbackToSimilarity := applyTokenFiltering(&results, requiredTokens, es.tagTokenSet)
+	opts.Trace.recordQuery(traceBackendPgvector, query, es.requiredTokensFromQuery)
+	opts.Trace.recordCandidates(results)
+	promoted := es.promotion.candidates(results)
+	fallbackToSimilarity := es.refineResults(&results, query, opts)
+	results = includePromoted(results, promoted, limit, "VECTOR_SEARCH")
 
 	// Return top results
 	if limit > 0 && limit < len(results) {
 		fmt.Printf("[VECTOR_SEARCH] Limiting results to top %d (from %d total)\n", limit, len(results))
 		results = results[:limit]
 	}
+	opts.Trace.recordResults(results, fallbackToSimilarity)
 
 	fmt.Printf("[PRODUCT_EMBEDDINGS] ✅ PRODUCT EMBEDDINGS query complete - Returning %d products (fallback=%t)\n", len(results), fallbackToSimilarity)
 	return results, fallbackToSimilarity, nil
 }
 
 // searchWithQdrant performs vector search using Qdrant
-func (es *EmbeddingService) searchWithQdrant(ctx context.Context, query string, queryEmbedding []float32, limit int) ([]ProductEmbedding, bool, error) {
+func (es *EmbeddingService) searchWithQdrant(ctx context.Context, query string, queryEmbedding []float32, limit int, opts SearchOptions) ([]ProductEmbedding, bool, error) {
 	// Fetch more results than requested to allow for token filtering
 	fetchLimit := limit * 3
 	if fetchLimit < 50 {
@@ -467,11 +686,17 @@ func (es *EmbeddingService) searchWithQdrant(ctx context.Context, query string,
 			ShortDescription: &shortDescription,
 		}
 
+		if r.Payload.PublishedAt > 0 {
+			publishedAt := time.Unix(r.Payload.PublishedAt, 0).UTC()
+			product.PublishedAt = &publishedAt
+		}
+
 		results = append(results, ProductEmbedding{
 			Product:    product,
 			Similarity: float64(r.Similarity),
 		})
 	}
+	results = applyTitlePolicy(results, es.requireTitle, "VECTOR_SEARCH")
 
 	// Log top 5 results for debugging
 	if len(results) > 0 {
@@ -486,20 +711,71 @@ func (es *EmbeddingService) searchWithQdrant(ctx context.Context, query string,
 		}
 	}
 
-	// Apply token filtering
-	requiredTokens := es.requiredTokensFromQuery(query)
-	fallbackToSimilarity := applyTokenFiltering(&results, requiredTokens, es.tagTokenSet)
+	// Apply boosting and token filtering
+	opts.Trace.recordQuery(traceBackendQdrant, query, es.requiredTokensFromQuery)
+	opts.Trace.recordCandidates(results)
+	promoted := es.promotion.candidates(results)
+	fallbackToSimilarity := es.refineResults(&results, query, opts)
+	results = includePromoted(results, promoted, limit, "VECTOR_SEARCH")
 
 	// Return top results
 	if limit > 0 && limit < len(results) {
 		fmt.Printf("[VECTOR_SEARCH] Limiting results to top %d (from %d total)\n", limit, len(results))
 		results = results[:limit]
 	}
+	opts.Trace.recordResults(results, fallbackToSimilarity)
 
 	fmt.Printf("[PRODUCT_EMBEDDINGS] ✅ Qdrant search complete - Returning %d products (fallback=%t)\n", len(results), fallbackToSimilarity)
 	return results, fallbackToSimilarity, nil
 }
 
+// refineResults applies boosting, token filtering and the minimum similarity to vector results
+// Returns whether token filtering fell back to pure similarity; always false when filtering is disabled
+func (es *EmbeddingService) refineResults(results *[]ProductEmbedding, query string, opts SearchOptions) bool {
+	if opts.Boosting {
+		es.applyTermBoosting(results, query)
+	} else {
+		fmt.Printf("[VECTOR_SEARCH] Term boosting disabled, keeping vector order\n")
+	}
+	// Weighted by RECENCY_BOOST_WEIGHT alone, so turning term boosting off doesn't change it
+	es.applyRecencyBoost(*results)
+
+	fallbackToSimilarity := false
+	if opts.TokenFiltering {
+		requiredTokens := es.requiredTokensFromQuery(query)
+		fallbackToSimilarity = applyTokenFiltering(results, requiredTokens, es.tagTokens())
+	} else {
+		fmt.Printf("[VECTOR_SEARCH] Token filtering disabled, returning raw similarity results\n")
+	}
+
+	// Applied last so boosted scores count; an empty result means nothing relevant was found
+	filterByMinSimilarity(results, opts.MinSimilarity, "VECTOR_SEARCH")
+
+	return fallbackToSimilarity
+}
+
+// applyTermBoosting combines vector similarity with the keyword and tag phrase score of each result
+func (es *EmbeddingService) applyTermBoosting(results *[]ProductEmbedding, query string) {
+	queryTokens := expandSynonymsCapped(utils.ExtractMeaningfulTokens(query), es.synonyms, es.synonymsPerToken, es.synonymsTotal)
+	applyTermBoostingPgvector(results, query, queryTokens, es.weights)
+}
+
+// filterByMinSimilarity drops results scoring below minSimilarity (0 keeps all)
+// A fully filtered result set is left as an empty, non-nil slice
+func filterByMinSimilarity(results *[]ProductEmbedding, minSimilarity float64, logPrefix string) {
+	if minSimilarity <= 0 {
+		return
+	}
+	filtered := make([]ProductEmbedding, 0, len(*results))
+	for _, result := range *results {
+		if result.Similarity >= minSimilarity {
+			filtered = append(filtered, result)
+		}
+	}
+	fmt.Printf("[%s] %d of %d products meet minimum similarity %.2f\n", logPrefix, len(filtered), len(*results), minSimilarity)
+	*results = filtered
+}
+
 // applyTokenFiltering applies token-based filtering to results
 func applyTokenFiltering(results *[]ProductEmbedding, requiredTokens []string, tagTokenSet map[string]struct{}) bool {
 	if len(requiredTokens) == 0 {
@@ -542,8 +818,9 @@ func (es *EmbeddingService) requiredTokensFromQuery(query string) []string {
 	required := make([]string, 0, len(tokens))
 	seen := make(map[string]struct{})
 
+	tagTokenSet := es.tagTokens()
 	for _, token := range tokens {
-		_, isKnownTagToken := es.tagTokenSet[token]
+		_, isKnownTagToken := tagTokenSet[token]
 		if !isKnownTagToken && !utils.TokenHasDigit(token) {
 			continue
 		}
@@ -596,6 +873,6 @@ func (es *EmbeddingService) CreateEmbeddingsTable() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := es.db.ExecContext(ctx, query)
+	_, err := es.productDB().ExecContext(ctx, query)
 	return err
 }
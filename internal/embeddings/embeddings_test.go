@@ -0,0 +1,244 @@
+package embeddings
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ids/internal/cache"
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/models"
+	idsopenai "ids/internal/openai"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEmbeddingService builds an EmbeddingService backed by sqlmock read and write databases
+func newTestEmbeddingService(t *testing.T) (*EmbeddingService, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	t.Helper()
+
+	readDB, readMock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = readDB.Close() })
+
+	writeDB, writeMock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = writeDB.Close() })
+
+	return &EmbeddingService{
+		db:          sqlx.NewDb(readDB, "mysql"),
+		writeClient: database.NewWriteClientFromDB(sqlx.NewDb(writeDB, "postgres")),
+	}, readMock, writeMock
+}
+
+func TestListProductsMissingEmbeddings(t *testing.T) {
+	service, readMock, writeMock := newTestEmbeddingService(t)
+
+	productColumns := []string{"ID", "post_title", "post_name", "sku", "stock_status"}
+	readMock.ExpectQuery(`FROM wpjr_wc_product_meta_lookup l\s+JOIN wpjr_posts p`).
+		WillReturnRows(sqlmock.NewRows(productColumns).
+			AddRow(1, "Holster", "holster", "HOL-1", "instock").
+			AddRow(2, "Magazine", "magazine", nil, "instock").
+			AddRow(3, "Plate Carrier", "plate-carrier", "PC-3", "outofstock").
+			AddRow(4, "Sling", "sling", nil, "instock").
+			AddRow(5, "Belt", "belt", "BLT-5", "onbackorder"))
+	writeMock.ExpectQuery(`SELECT product_id FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(1).AddRow(3))
+
+	products, err := service.ListProductsMissingEmbeddings(2, 1)
+	require.NoError(t, err)
+
+	require.Len(t, products, 2)
+	assert.Equal(t, 4, products[0].ID)
+	assert.Equal(t, 5, products[1].ID)
+	assert.Equal(t, "Belt", products[1].PostTitle)
+	assert.NoError(t, readMock.ExpectationsWereMet())
+	assert.NoError(t, writeMock.ExpectationsWereMet())
+}
+
+func TestListProductsMissingEmbeddings_AllEmbedded(t *testing.T) {
+	service, readMock, writeMock := newTestEmbeddingService(t)
+
+	readMock.ExpectQuery(`FROM wpjr_wc_product_meta_lookup l`).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "post_title", "post_name", "sku", "stock_status"}).
+			AddRow(1, "Holster", "holster", nil, "instock"))
+	writeMock.ExpectQuery(`SELECT product_id FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(1))
+
+	products, err := service.ListProductsMissingEmbeddings(50, 0)
+	require.NoError(t, err)
+
+	assert.NotNil(t, products)
+	assert.Empty(t, products)
+}
+
+func TestRefineResults_TokenFiltering(t *testing.T) {
+	glockTags := "glock, owb"
+	newResults := func() []ProductEmbedding {
+		return []ProductEmbedding{
+			{Product: models.Product{ID: 1, PostTitle: "Sig P320 Holster"}, Similarity: 0.85},
+			{Product: models.Product{ID: 2, PostTitle: "Glock 19 Holster", Tags: &glockTags}, Similarity: 0.80},
+		}
+	}
+	service := &EmbeddingService{tagTokenSet: map[string]struct{}{"glock": {}}}
+	enabled := SearchOptions{TokenFiltering: true}
+	disabled := SearchOptions{TokenFiltering: false}
+
+	results := newResults()
+	fallback := service.refineResults(&results, "glock holster", enabled)
+	assert.False(t, fallback)
+	require.Len(t, results, 1)
+	assert.Equal(t, 2, results[0].Product.ID)
+
+	// Nothing matches the required token: filtering falls back to similarity
+	results = newResults()
+	assert.True(t, service.refineResults(&results, "kydex glock 43", enabled))
+	assert.Len(t, results, 2)
+
+	results = newResults()
+	assert.False(t, service.refineResults(&results, "glock holster", disabled))
+	assert.Equal(t, newResults(), results)
+
+	results = newResults()
+	assert.False(t, service.refineResults(&results, "kydex glock 43", disabled))
+	assert.Equal(t, newResults(), results)
+}
+
+func TestRefineResults_MinSimilarity(t *testing.T) {
+	service := &EmbeddingService{}
+	results := []ProductEmbedding{
+		{Product: models.Product{ID: 1}, Similarity: 0.85},
+		{Product: models.Product{ID: 2}, Similarity: 0.60},
+		{Product: models.Product{ID: 3}, Similarity: 0.40},
+	}
+
+	service.refineResults(&results, "holster", SearchOptions{MinSimilarity: 0.6})
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].Product.ID)
+	assert.Equal(t, 2, results[1].Product.ID)
+}
+
+func TestRefineResults_AllBelowMinSimilarity(t *testing.T) {
+	glockTags := "glock"
+	service := &EmbeddingService{tagTokenSet: map[string]struct{}{"glock": {}}}
+	results := []ProductEmbedding{
+		{Product: models.Product{ID: 1, PostTitle: "Sig P320 Holster"}, Similarity: 0.35},
+		{Product: models.Product{ID: 2, PostTitle: "Glock Mag Pouch", Tags: &glockTags}, Similarity: 0.30},
+	}
+
+	// Token filtering falls back first, then the threshold removes everything
+	fallback := service.refineResults(&results, "kydex glock 43", SearchOptions{TokenFiltering: true, MinSimilarity: 0.5})
+	assert.True(t, fallback)
+	assert.NotNil(t, results)
+	assert.Empty(t, results)
+}
+
+func TestRefineResults_TermBoosting(t *testing.T) {
+	tags := "molle, plate carrier"
+	newResults := func() []ProductEmbedding {
+		return []ProductEmbedding{
+			{Product: models.Product{ID: 1, PostTitle: "Tactical Vest"}, Similarity: 0.80},
+			{Product: models.Product{ID: 2, PostTitle: "Plate Carrier", Tags: &tags}, Similarity: 0.70},
+		}
+	}
+	service := &EmbeddingService{weights: defaultScoreWeights}
+
+	results := newResults()
+	service.refineResults(&results, "plate carrier", SearchOptions{Boosting: true})
+	assert.Equal(t, []int{2, 1}, resultIDs(results))
+	assert.Greater(t, results[0].Similarity, 0.70)
+
+	// Disabled (ENABLE_TERM_BOOSTING=false) keeps the pure vector order and scores
+	results = newResults()
+	service.refineResults(&results, "plate carrier", SearchOptions{Boosting: false})
+	assert.Equal(t, newResults(), results)
+}
+
+func TestRefineResults_RecencyBoostWithoutTermBoosting(t *testing.T) {
+	published := time.Now().Add(-time.Hour)
+	results := []ProductEmbedding{
+		{Product: models.Product{ID: 1, PostTitle: "Tactical Vest"}, Similarity: 0.80},
+		{Product: models.Product{ID: 2, PostTitle: "New Vest", PublishedAt: &published}, Similarity: 0.70},
+	}
+	service := &EmbeddingService{recencyBoost: 0.2, recencyWindow: 30 * 24 * time.Hour}
+
+	service.refineResults(&results, "vest", SearchOptions{Boosting: false})
+	assert.Equal(t, []int{2, 1}, resultIDs(results))
+}
+
+func TestSearchSimilarProductsWithOptions_BoostingOverrideChangesOrder(t *testing.T) {
+	service, _, writeMock := newTestEmbeddingService(t)
+	client, err := idsopenai.NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536})
+	require.NoError(t, err)
+	service.client = client
+	service.cache = cache.New()
+	service.cache.SetEmbedding(client.GetEmbeddingModel(), "plate carrier", []float32{0.1, 0.2})
+	service.weights = defaultScoreWeights
+
+	tags := "molle, plate carrier"
+	columns := []string{"product_id", "embedding", "post_title", "post_name", "description", "short_description", "sku",
+		"min_price", "max_price", "stock_status", "stock_quantity", "tags", "published_at", "distance"}
+	search := func(boosting bool) []int {
+		writeMock.ExpectQuery(`FROM product_embeddings`).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, "[0.1,0.2]", "Tactical Vest", nil, nil, nil, nil, nil, nil, "instock", nil, nil, nil, 0.20).
+				AddRow(2, "[0.1,0.2]", "Plate Carrier", nil, nil, nil, nil, nil, nil, "instock", nil, tags, nil, 0.30))
+		results, _, err := service.SearchSimilarProductsWithOptions(context.Background(), "plate carrier", 10, SearchOptions{Boosting: boosting})
+		require.NoError(t, err)
+		return resultIDs(results)
+	}
+
+	assert.Equal(t, []int{2, 1}, search(true), "keyword and tag boosts lift the plate carrier")
+	assert.Equal(t, []int{1, 2}, search(false), "boost=false keeps the vector order")
+	assert.NoError(t, writeMock.ExpectationsWereMet())
+}
+
+func TestDefaultSearchOptions(t *testing.T) {
+	service := &EmbeddingService{boosting: true, tokenFiltering: false, minSimilarity: 0.4}
+	assert.Equal(t, SearchOptions{Boosting: true, TokenFiltering: false, MinSimilarity: 0.4}, service.DefaultSearchOptions())
+}
+
+func TestEmbeddingService_InputPrefixes(t *testing.T) {
+	product := models.Product{ID: 1, PostTitle: "Glock 19 Holster"}
+
+	service := &EmbeddingService{}
+	assert.Equal(t, service.buildProductText(product), service.documentText(product))
+	assert.Equal(t, "glock holster", service.queryText("glock holster"))
+
+	service = &EmbeddingService{documentPrefix: "Represent this product for retrieval:", queryPrefix: "query:"}
+	assert.Equal(t, "Represent this product for retrieval: "+service.buildProductText(product), service.documentText(product))
+	assert.Equal(t, "query: glock holster", service.queryText("glock holster"))
+}
+
+func TestSearchSimilarProducts_EmptyQuery(t *testing.T) {
+	service, _, writeMock := newTestEmbeddingService(t)
+
+	for _, query := range []string{"", "   ", "\t\n\u200b"} {
+		results, fallback, err := service.SearchSimilarProducts(context.Background(), query, 10)
+		require.NoError(t, err)
+		assert.False(t, fallback)
+		assert.NotNil(t, results)
+		assert.Empty(t, results)
+	}
+
+	// The nil OpenAI client and unprimed sqlmock prove no embedding or query was attempted
+	assert.NoError(t, writeMock.ExpectationsWereMet())
+}
+
+func TestGenerateProductEmbeddings_CanceledContext(t *testing.T) {
+	service, readMock, writeMock := newTestEmbeddingService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The nil OpenAI client would panic if generation continued past the canceled context
+	err := service.GenerateProductEmbeddings(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.NoError(t, readMock.ExpectationsWereMet())
+	assert.NoError(t, writeMock.ExpectationsWereMet())
+}
@@ -0,0 +1,55 @@
+package embeddings
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// SearchFilters are facet constraints applied as SQL predicates on the denormalized
+// product_embeddings columns, before the pgvector ranking. The zero value matches every product.
+type SearchFilters struct {
+	Tags          []string // Products carrying at least one of these tags (case-insensitive)
+	StockStatuses []string // Products with one of these stock_status values
+	PriceMin      *float64 // Products whose max_price is at least PriceMin
+	PriceMax      *float64 // Products whose min_price is at most PriceMax
+}
+
+// IsEmpty reports whether no filter is set
+func (f SearchFilters) IsEmpty() bool {
+	return len(f.Tags) == 0 && len(f.StockStatuses) == 0 && f.PriceMin == nil && f.PriceMax == nil
+}
+
+// args returns the query parameters for productFilterPredicates; unset filters bind NULL
+func (f SearchFilters) args() []interface{} {
+	tags := make([]string, 0, len(f.Tags))
+	for _, tag := range f.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return []interface{}{textArrayOrNull(tags), textArrayOrNull(f.StockStatuses), f.PriceMin, f.PriceMax}
+}
+
+// textArrayOrNull binds values as a Postgres text[], or NULL when empty
+func textArrayOrNull(values []string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return pq.Array(values)
+}
+
+// productFilterPredicates returns the SearchFilters WHERE predicates over product_embeddings,
+// binding parameters $first to $first+3. A NULL parameter disables its predicate.
+// tags is stored comma-separated; prices are TEXT, so empty values never match a price bound.
+func productFilterPredicates(first int) string {
+	return fmt.Sprintf(`($%[1]d::text[] IS NULL OR EXISTS (
+				SELECT 1 FROM unnest(string_to_array(lower(tags), ',')) AS tag
+				WHERE trim(tag) = ANY($%[1]d::text[])
+			))
+			AND ($%[2]d::text[] IS NULL OR stock_status = ANY($%[2]d::text[]))
+			AND ($%[3]d::numeric IS NULL OR NULLIF(max_price, '')::numeric >= $%[3]d::numeric)
+			AND ($%[4]d::numeric IS NULL OR NULLIF(min_price, '')::numeric <= $%[4]d::numeric)`,
+		first, first+1, first+2, first+3)
+}
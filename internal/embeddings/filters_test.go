@@ -0,0 +1,85 @@
+package embeddings
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchFilters_IsEmpty(t *testing.T) {
+	priceMax := 100.0
+	assert.True(t, SearchFilters{}.IsEmpty())
+	assert.True(t, SearchFilters{Tags: []string{}}.IsEmpty())
+	assert.False(t, SearchFilters{Tags: []string{"holster"}}.IsEmpty())
+	assert.False(t, SearchFilters{StockStatuses: []string{"instock"}}.IsEmpty())
+	assert.False(t, SearchFilters{PriceMax: &priceMax}.IsEmpty())
+}
+
+func TestQueryProductCandidates_Filters(t *testing.T) {
+	columns := []string{"product_id", "embedding", "post_title", "post_name", "description", "short_description",
+		"sku", "min_price", "max_price", "stock_status", "stock_quantity", "tags", "published_at", "distance"}
+	priceMin, priceMax := 50.0, 150.0
+	filters := SearchFilters{
+		Tags:          []string{" Holster", "OWB", ""},
+		StockStatuses: []string{"instock", "onbackorder"},
+		PriceMin:      &priceMin,
+		PriceMax:      &priceMax,
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		expected string
+		prefix   []interface{}
+	}{
+		{
+			name:     "vector mode",
+			mode:     config.SearchModeVector,
+			expected: `WHERE \(NOT \$3::boolean .*\)\s+AND \(\$4::text\[\] IS NULL OR EXISTS .*trim\(tag\) = ANY\(\$4::text\[\]\).*stock_status = ANY\(\$5::text\[\]\).*NULLIF\(max_price, ''\)::numeric >= \$6::numeric.*NULLIF\(min_price, ''\)::numeric <= \$7::numeric\)\s+ORDER BY embedding <=> \$1::vector`,
+			prefix:   []interface{}{"[0.1]", 50, true},
+		},
+		{
+			name:     "hybrid mode filters both candidate lists",
+			mode:     config.SearchModeHybrid,
+			expected: `vector_candidates AS .*ANY\(\$6::text\[\]\).*ORDER BY embedding <=> \$1::vector.*text_candidates AS .*ANY\(\$6::text\[\]\).*<= \$9::numeric\)\s+ORDER BY text_rank DESC`,
+			prefix:   []interface{}{"[0.1]", 50, "'holster'", rrfK, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			writeClient := database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))
+
+			args := make([]interface{}, 0, len(tt.prefix)+4)
+			args = append(args, tt.prefix...)
+			args = append(args, "{\"holster\",\"owb\"}", "{\"instock\",\"onbackorder\"}", 50.0, 150.0)
+			mock.ExpectQuery(`(?s)` + tt.expected).
+				WithArgs(toDriverValues(args)...).
+				WillReturnRows(sqlmock.NewRows(columns))
+
+			rows, err := queryProductCandidates(context.Background(), writeClient, tt.mode, config.DistanceMetricCosine, "holster", "[0.1]", 50, true, filters, indexTuning{}, "TEST")
+			require.NoError(t, err)
+			require.NoError(t, rows.Close())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func toDriverValues(values []interface{}) []driver.Value {
+	converted := make([]driver.Value, len(values))
+	for i, value := range values {
+		converted[i] = value
+	}
+	return converted
+}
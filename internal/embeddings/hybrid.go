@@ -0,0 +1,188 @@
+package embeddings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/vectordb"
+)
+
+// rrfK dampens the reciprocal rank fusion score so no single ranking dominates
+// (60 is the constant from the original RRF paper)
+const rrfK = 60
+
+// queryProductEmbeddingsHybrid fuses the pgvector ranking with a full-text ranking over
+// product_embeddings.ts (title, tags, SKU) using reciprocal rank fusion
+// $1 is the query vector, $2 the limit, $3 the tsquery, $4 the RRF constant, $5
+// whether an empty post_title is excluded and $6-$9 the SearchFilters
+// Returns the same columns as queryProductEmbeddingsPgvector; distance stays the vector
+// distance so boosting and SEARCH_MIN_SIMILARITY behave the same in both modes
+var queryProductEmbeddingsHybrid = fmt.Sprintf(`
+	WITH vector_candidates AS (
+		SELECT product_id, embedding <=> $1::vector AS distance
+		FROM product_embeddings
+		WHERE (NOT $5::boolean OR (post_title IS NOT NULL AND post_title != ''))
+			AND %[1]s
+		ORDER BY embedding <=> $1::vector
+		LIMIT $2
+	),
+	vector_ranked AS (
+		SELECT product_id, ROW_NUMBER() OVER (ORDER BY distance) AS rank
+		FROM vector_candidates
+	),
+	text_candidates AS (
+		SELECT product_id, ts_rank_cd(ts, to_tsquery('simple', $3)) AS text_rank
+		FROM product_embeddings
+		WHERE ts @@ to_tsquery('simple', $3)
+			AND (NOT $5::boolean OR (post_title IS NOT NULL AND post_title != ''))
+			AND %[1]s
+		ORDER BY text_rank DESC
+		LIMIT $2
+	),
+	text_ranked AS (
+		SELECT product_id, ROW_NUMBER() OVER (ORDER BY text_rank DESC) AS rank
+		FROM text_candidates
+	),
+	fused AS (
+		SELECT product_id, SUM(1.0 / ($4::int + rank)) AS score
+		FROM (
+			SELECT product_id, rank FROM vector_ranked
+			UNION ALL
+			SELECT product_id, rank FROM text_ranked
+		) ranked
+		GROUP BY product_id
+	)
+	SELECT
+		pe.product_id,
+		pe.embedding::text,
+		COALESCE(pe.post_title, '') as post_title,
+		pe.post_name,
+		pe.description,
+		pe.short_description,
+		pe.sku,
+		pe.min_price,
+		pe.max_price,
+		pe.stock_status,
+		pe.stock_quantity,
+		pe.tags,
+		pe.published_at,
+		pe.embedding <=> $1::vector AS distance
+	FROM fused f
+	JOIN product_embeddings pe ON pe.product_id = f.product_id
+	ORDER BY f.score DESC, pe.product_id
+	LIMIT $2
+`, productFilterPredicates(6))
+
+// indexTuning holds the per-query pgvector index settings of product searches
+type indexTuning struct {
+	EfSearch int // hnsw.ef_search, 0 keeps the server setting (HNSW_EF_SEARCH)
+	Probes   int // ivfflat.probes, 0 keeps the server setting (IVFFLAT_PROBES)
+}
+
+// indexTuningFromConfig returns the index settings of cfg
+func indexTuningFromConfig(cfg *config.Config) indexTuning {
+	return indexTuning{EfSearch: cfg.HNSWEfSearch, Probes: cfg.IVFFlatProbes}
+}
+
+// statements returns the SET LOCAL statements applying the tuned settings
+func (t indexTuning) statements() []string {
+	var statements []string
+	if t.EfSearch > 0 {
+		statements = append(statements, vectordb.EfSearchQuery(t.EfSearch))
+	}
+	if t.Probes > 0 {
+		statements = append(statements, vectordb.ProbesQuery(t.Probes))
+	}
+	return statements
+}
+
+// candidateRows are the rows of a product candidate query
+// When index settings are tuned the query runs in its own read transaction, which Close ends.
+type candidateRows struct {
+	*sql.Rows
+	tx *sql.Tx
+}
+
+// Close closes the rows and ends the transaction holding the index settings, if any
+func (r *candidateRows) Close() error {
+	err := r.Rows.Close()
+	if r.tx != nil {
+		if rbErr := r.tx.Rollback(); err == nil && rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			err = rbErr
+		}
+	}
+	return err
+}
+
+// queryProductCandidates runs the pgvector search for the configured SEARCH_MODE
+// Hybrid mode falls back to pure vector search when the query has no searchable terms
+// requireTitle excludes products with an empty post_title (SEARCH_REQUIRE_TITLE)
+// metric selects the pgvector distance operator (VECTOR_DISTANCE_METRIC) and filters
+// restrict the candidates in SQL before ranking. tuning sets hnsw.ef_search and
+// ivfflat.probes for this query only; unset values keep the server settings.
+func queryProductCandidates(ctx context.Context, writeClient *database.WriteClient, mode, metric, query, queryVector string, limit int, requireTitle bool, filters SearchFilters, tuning indexTuning, logPrefix string) (*candidateRows, error) {
+	sqlQuery := vectordb.WithDistanceOperator(queryProductEmbeddingsPgvector, metric)
+	args := append([]interface{}{queryVector, limit, requireTitle}, filters.args()...)
+	if mode == config.SearchModeHybrid {
+		if tsQuery := hybridTSQuery(query); tsQuery != "" {
+			fmt.Printf("[%s] Hybrid search: fusing vector and full-text ranks (tsquery: %s)\n", logPrefix, tsQuery)
+			sqlQuery = vectordb.WithDistanceOperator(queryProductEmbeddingsHybrid, metric)
+			args = append([]interface{}{queryVector, limit, tsQuery, rrfK, requireTitle}, filters.args()...)
+		} else {
+			fmt.Printf("[%s] Hybrid search: no full-text terms in query, using vector ranking only\n", logPrefix)
+		}
+	}
+
+	statements := tuning.statements()
+	if len(statements) == 0 {
+		rows, err := writeClient.GetDB().QueryContext(ctx, sqlQuery, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &candidateRows{Rows: rows}, nil
+	}
+
+	// SET LOCAL keeps the setting from leaking to other queries on the pooled connection
+	tx, err := writeClient.GetDB().BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("failed to apply %q: %w", statement, err)
+		}
+	}
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return &candidateRows{Rows: rows, tx: tx}, nil
+}
+
+// hybridTSQuery builds an OR tsquery from the query's letter/digit runs, so a product
+// matching any term (e.g. just the SKU) ranks in the full-text half of hybrid search
+// Terms are quoted lexemes, so user input can never inject tsquery operators
+func hybridTSQuery(query string) string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := make(map[string]struct{}, len(terms))
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+		quoted = append(quoted, "'"+term+"'")
+	}
+	return strings.Join(quoted, " | ")
+}
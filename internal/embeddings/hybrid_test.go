@@ -0,0 +1,122 @@
+package embeddings
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHybridTSQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "single sku", query: "P365XL", expected: "'p365xl'"},
+		{name: "terms are ORed", query: "glock 19 holster", expected: "'glock' | '19' | 'holster'"},
+		{name: "punctuation splits terms", query: "P365-XL holster!", expected: "'p365' | 'xl' | 'holster'"},
+		{name: "operators cannot be injected", query: "holster & !sling:* | 'x'", expected: "'holster' | 'sling' | 'x'"},
+		{name: "duplicates removed", query: "holster Holster", expected: "'holster'"},
+		{name: "hebrew", query: "נרתיק לגלוק", expected: "'נרתיק' | 'לגלוק'"},
+		{name: "no terms", query: "?!", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, hybridTSQuery(tt.query))
+		})
+	}
+}
+
+func TestQueryProductCandidates(t *testing.T) {
+	columns := []string{"product_id", "embedding", "post_title", "post_name", "description", "short_description",
+		"sku", "min_price", "max_price", "stock_status", "stock_quantity", "tags", "published_at", "distance"}
+
+	tests := []struct {
+		name     string
+		mode     string
+		metric   string
+		query    string
+		expected string
+		args     []driver.Value
+		// A distance of 0.58 as the similarity implied by metric
+		similarity float64
+	}{
+		{name: "vector mode", mode: config.SearchModeVector, metric: config.DistanceMetricCosine, query: "P365XL", expected: `ORDER BY embedding <=> \$1::vector\s+LIMIT \$2\s*$`, args: []driver.Value{"[0.1]", 50, true, nil, nil, nil, nil}, similarity: 0.42},
+		{name: "hybrid mode fuses full-text rank", mode: config.SearchModeHybrid, metric: config.DistanceMetricCosine, query: "P365XL", expected: `WITH vector_candidates AS .*ts @@ to_tsquery\('simple', \$3\).*ORDER BY f.score DESC`, args: []driver.Value{"[0.1]", 50, "'p365xl'", rrfK, true, nil, nil, nil, nil}, similarity: 0.42},
+		{name: "hybrid mode without terms", mode: config.SearchModeHybrid, metric: config.DistanceMetricCosine, query: "?!", expected: `ORDER BY embedding <=> \$1::vector\s+LIMIT \$2\s*$`, args: []driver.Value{"[0.1]", 50, true, nil, nil, nil, nil}, similarity: 0.42},
+		{name: "l2 metric", mode: config.SearchModeVector, metric: config.DistanceMetricL2, query: "P365XL", expected: `embedding <-> \$1::vector AS distance.*ORDER BY embedding <-> \$1::vector\s+LIMIT \$2\s*$`, args: []driver.Value{"[0.1]", 50, true, nil, nil, nil, nil}, similarity: 1 - 0.58*0.58/2},
+		{name: "hybrid l2 metric", mode: config.SearchModeHybrid, metric: config.DistanceMetricL2, query: "P365XL", expected: `ORDER BY embedding <-> \$1::vector.*pe.embedding <-> \$1::vector AS distance`, args: []driver.Value{"[0.1]", 50, "'p365xl'", rrfK, true, nil, nil, nil, nil}, similarity: 1 - 0.58*0.58/2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			writeClient := database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))
+
+			mock.ExpectQuery(`(?s)` + tt.expected).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(7, "[0.1]", "P365XL Holster", nil, nil, nil, "HOL-P365XL", nil, nil, "instock", nil, nil, nil, 0.58))
+
+			rows, err := queryProductCandidates(context.Background(), writeClient, tt.mode, tt.metric, tt.query, "[0.1]", 50, true, SearchFilters{}, indexTuning{}, "TEST")
+			require.NoError(t, err)
+			results := ScanProductEmbeddingRows(rows.Rows, tt.metric, "TEST")
+			require.NoError(t, rows.Close())
+
+			require.Len(t, results, 1)
+			assert.Equal(t, 7, results[0].Product.ID)
+			assert.InDelta(t, tt.similarity, results[0].Similarity, 1e-9)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryProductCandidates_EfSearch(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	writeClient := database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))
+
+	// The setting is scoped to a transaction of its own, ended when the rows are closed
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL hnsw.ef_search = 200`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`ORDER BY embedding <=> \$1::vector`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}))
+	mock.ExpectRollback()
+
+	rows, err := queryProductCandidates(context.Background(), writeClient, config.SearchModeVector, config.DistanceMetricCosine, "holster", "[0.1]", 50, true, SearchFilters{}, indexTuning{EfSearch: 200}, "TEST")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryProductCandidates_Probes(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	writeClient := database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL hnsw.ef_search = 100`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET LOCAL ivfflat.probes = 10`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`ORDER BY embedding <=> \$1::vector`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}))
+	mock.ExpectRollback()
+
+	tuning := indexTuningFromConfig(&config.Config{HNSWEfSearch: 100, IVFFlatProbes: 10})
+	rows, err := queryProductCandidates(context.Background(), writeClient, config.SearchModeVector, config.DistanceMetricCosine, "holster", "[0.1]", 50, true, SearchFilters{}, tuning, "TEST")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,59 @@
+package embeddings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LoadKeywordRules reads keyword rules from a JSON object mapping a title substring to
+// extra keywords, e.g. {"P-IX+": "Recover Tactical P-IX+ AR Platform Conversion Kit"}
+// An empty path means no rules
+func LoadKeywordRules(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyword rules file %s: %v", path, err)
+	}
+
+	var rules map[string]string
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse keyword rules file %s: %v", path, err)
+	}
+	for substring := range rules {
+		if strings.TrimSpace(substring) == "" {
+			delete(rules, substring)
+		}
+	}
+	return rules, nil
+}
+
+// matchKeywordRules returns the extra keywords for every rule whose substring appears
+// in the title (case-insensitive), ordered by substring for stable embedding text
+func matchKeywordRules(title string, rules map[string]string) []string {
+	if len(rules) == 0 || title == "" {
+		return nil
+	}
+
+	lowerTitle := strings.ToLower(title)
+	var matched []string
+	for substring := range rules {
+		if strings.Contains(lowerTitle, strings.ToLower(substring)) {
+			matched = append(matched, substring)
+		}
+	}
+	sort.Strings(matched)
+
+	keywords := make([]string, 0, len(matched))
+	for _, substring := range matched {
+		if extra := strings.TrimSpace(rules[substring]); extra != "" {
+			keywords = append(keywords, extra)
+		}
+	}
+	return keywords
+}
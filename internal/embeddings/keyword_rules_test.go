@@ -0,0 +1,84 @@
+package embeddings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func keywordTestProduct() models.Product {
+	return models.Product{
+		ID:               13925,
+		PostTitle:        "AR Platform Conversion Kit - Recover Tactical P-IX+",
+		Description:      strPtr("<p>Turns your pistol into a carbine</p>"),
+		ShortDescription: strPtr("Conversion kit"),
+		Tags:             strPtr("conversion kit, glock"),
+		SKU:              strPtr("PIX-BLK"),
+		MinPrice:         strPtr("199.00"),
+		MaxPrice:         strPtr("249.00"),
+		StockStatus:      strPtr("instock"),
+	}
+}
+
+func TestBuildProductText_NoRulesIsPlainConcatenation(t *testing.T) {
+	product := keywordTestProduct()
+	expected := "AR Platform Conversion Kit - Recover Tactical P-IX+ | " +
+		cleanHTMLDescription(*product.Description) + " | " +
+		"Conversion kit | Tags: conversion kit, glock | SKU: PIX-BLK | Price: $199.00 - $249.00 | Stock: instock"
+
+	for name, rules := range map[string]map[string]string{
+		"no rules":          nil,
+		"no matching rules": {"Holster": "Duty Holster"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			wes := &WriteEmbeddingService{keywordRules: rules}
+			assert.Equal(t, expected, wes.buildProductText(product))
+		})
+	}
+}
+
+func TestBuildProductText_AppendsMatchingRuleKeywords(t *testing.T) {
+	wes := &WriteEmbeddingService{keywordRules: map[string]string{
+		"p-ix+":   "AR Platform Conversion Kit",
+		"Recover": "Brand: Recover Tactical",
+	}}
+
+	text := wes.buildProductText(keywordTestProduct())
+	assert.Contains(t, text, "Stock: instock | Brand: Recover Tactical | AR Platform Conversion Kit")
+}
+
+func TestCalculateProductChecksum_ChangesWithMatchingRules(t *testing.T) {
+	product := keywordTestProduct()
+	plain := (&WriteEmbeddingService{}).calculateProductChecksum(product)
+
+	assert.Equal(t, plain, (&WriteEmbeddingService{keywordRules: map[string]string{"Holster": "Duty Holster"}}).calculateProductChecksum(product))
+	assert.NotEqual(t, plain, (&WriteEmbeddingService{keywordRules: map[string]string{"Recover": "Recover Tactical"}}).calculateProductChecksum(product))
+}
+
+func TestLoadKeywordRules(t *testing.T) {
+	rules, err := LoadKeywordRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"P-IX+": "Recover Tactical P-IX+", " ": "ignored"}`), 0o600))
+	rules, err = LoadKeywordRules(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"P-IX+": "Recover Tactical P-IX+"}, rules)
+
+	require.NoError(t, os.WriteFile(path, []byte(`["not", "a", "map"]`), 0o600))
+	_, err = LoadKeywordRules(path)
+	assert.Error(t, err)
+
+	_, err = LoadKeywordRules(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"ids/internal/models"
+	"ids/internal/vectordb"
 )
 
 // FormatVectorForPgvector converts a float64 slice to pgvector string format
@@ -29,16 +30,18 @@ func FormatFloat32VectorForPgvector(embedding []float32) string {
 }
 
 // ScanProductEmbeddingRow scans a row from pgvector query results into a ProductEmbedding
+// The distance column is normalized to a 0-1 similarity for metric
 // Returns the product embedding and any error encountered
-func ScanProductEmbeddingRow(rows *sql.Rows, logPrefix string) (*ProductEmbedding, error) {
+func ScanProductEmbeddingRow(rows *sql.Rows, metric, logPrefix string) (*ProductEmbedding, error) {
 	var productID int
 	var embeddingStr string
 	var product models.Product
-	var similarity float64
+	var distance float64
 
 	// Use sql.NullString for nullable fields
 	var postName, description, shortDescription, sku, minPrice, maxPrice, stockStatus, tags sql.NullString
 	var stockQuantity sql.NullFloat64
+	var publishedAt sql.NullTime
 
 	err := rows.Scan(
 		&productID,
@@ -53,7 +56,8 @@ func ScanProductEmbeddingRow(rows *sql.Rows, logPrefix string) (*ProductEmbeddin
 		&stockStatus,
 		&stockQuantity,
 		&tags,
-		&similarity,
+		&publishedAt,
+		&distance,
 	)
 
 	if err != nil {
@@ -64,19 +68,24 @@ func ScanProductEmbeddingRow(rows *sql.Rows, logPrefix string) (*ProductEmbeddin
 	// Convert nullable fields to pointers
 	product = convertNullableFieldsToProduct(product, postName, description, shortDescription, sku, minPrice, maxPrice, stockStatus, tags, stockQuantity)
 
+	if publishedAt.Valid {
+		product.PublishedAt = &publishedAt.Time
+	}
+
 	product.ID = productID
 	return &ProductEmbedding{
 		Product:    product,
 		Embedding:  nil, // Don't need to store embedding in results
-		Similarity: similarity,
+		Similarity: vectordb.NormalizeSimilarity(metric, distance),
+		Distance:   distance,
 	}, nil
 }
 
 // ScanProductEmbeddingRows scans all rows from pgvector query results
-func ScanProductEmbeddingRows(rows *sql.Rows, logPrefix string) []ProductEmbedding {
+func ScanProductEmbeddingRows(rows *sql.Rows, metric, logPrefix string) []ProductEmbedding {
 	var results []ProductEmbedding
 	for rows.Next() {
-		result, err := ScanProductEmbeddingRow(rows, logPrefix)
+		result, err := ScanProductEmbeddingRow(rows, metric, logPrefix)
 		if err != nil {
 			continue // Skip invalid rows
 		}
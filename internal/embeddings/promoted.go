@@ -0,0 +1,134 @@
+package embeddings
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// promotion keeps merchandised products in results when they are loosely relevant
+// Products carrying one of tags whose raw similarity reaches minSimilarity survive token
+// filtering, SEARCH_MIN_SIMILARITY and the result limit, up to maxResults per search.
+type promotion struct {
+	tags          map[string]struct{} // Lowercase tags marking a promoted product (PROMOTED_TAGS)
+	minSimilarity float64             // Loose relevance bar on the raw vector similarity (PROMOTED_MIN_SIMILARITY)
+	maxResults    int                 // Maximum promoted products kept per search (PROMOTED_MAX_RESULTS)
+}
+
+// newPromotion builds the promotion policy; it is disabled when tags is empty or maxResults is 0
+func newPromotion(tags []string, minSimilarity float64, maxResults int) promotion {
+	p := promotion{minSimilarity: minSimilarity, maxResults: maxResults}
+	if len(tags) == 0 || maxResults <= 0 {
+		return p
+	}
+	p.tags = make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			p.tags[tag] = struct{}{}
+		}
+	}
+	return p
+}
+
+// enabled reports whether any promoted tags are configured
+func (p promotion) enabled() bool {
+	return len(p.tags) > 0
+}
+
+// isPromoted reports whether product carries one of the promoted tags
+func (p promotion) isPromoted(product ProductEmbedding) bool {
+	if product.Product.Tags == nil {
+		return false
+	}
+	for _, tag := range strings.Split(*product.Product.Tags, ",") {
+		if _, ok := p.tags[strings.ToLower(strings.TrimSpace(tag))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns copies of the best promoted products passing the relevance bar
+// Call it on raw vector results, before boosting and filtering change them.
+func (p promotion) candidates(results []ProductEmbedding) []ProductEmbedding {
+	if !p.enabled() {
+		return nil
+	}
+	var promoted []ProductEmbedding
+	for _, result := range results {
+		if len(promoted) == p.maxResults {
+			break
+		}
+		if result.Similarity >= p.minSimilarity && p.isPromoted(result) {
+			promoted = append(promoted, result)
+		}
+	}
+	return promoted
+}
+
+// includePromoted places every promoted candidate within the first limit results (all results when
+// limit is 0), displacing the lowest-ranked others, and keeps the list ordered by similarity
+// A candidate still in results keeps its refined (boosted) score.
+func includePromoted(results, promoted []ProductEmbedding, limit int, logPrefix string) []ProductEmbedding {
+	if len(promoted) == 0 {
+		return results
+	}
+
+	window := len(results)
+	if limit > 0 && limit < window {
+		window = limit
+	}
+	inWindow := make(map[int]struct{}, window)
+	for _, result := range results[:window] {
+		inWindow[result.Product.ID] = struct{}{}
+	}
+
+	refined := make(map[int]ProductEmbedding, len(results))
+	for _, result := range results {
+		refined[result.Product.ID] = result
+	}
+
+	var missing []ProductEmbedding
+	for _, candidate := range promoted {
+		if _, ok := inWindow[candidate.Product.ID]; ok {
+			continue
+		}
+		if result, ok := refined[candidate.Product.ID]; ok {
+			candidate = result
+		}
+		missing = append(missing, candidate)
+	}
+	if len(missing) == 0 {
+		return results
+	}
+
+	keep := len(results)
+	if limit > 0 {
+		keep = min(keep, max(limit-len(missing), 0))
+	}
+	merged := make([]ProductEmbedding, 0, len(results)+len(missing))
+	merged = append(merged, results[:keep]...)
+	merged = append(merged, missing...)
+	// Remaining results past the limit stay after the promoted products
+	for _, result := range results[keep:] {
+		if !containsProduct(missing, result.Product.ID) {
+			merged = append(merged, result)
+		}
+	}
+	sort.SliceStable(merged[:keep+len(missing)], func(i, j int) bool {
+		return merged[i].Similarity > merged[j].Similarity
+	})
+
+	fmt.Printf("[%s] Included %d promoted products\n", logPrefix, len(missing))
+	return merged
+}
+
+// containsProduct reports whether results contains productID
+func containsProduct(results []ProductEmbedding, productID int) bool {
+	for _, result := range results {
+		if result.Product.ID == productID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package embeddings
+
+import (
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func promotedTestResults() []ProductEmbedding {
+	featured := "Featured, holster"
+	glock := "glock"
+	return []ProductEmbedding{
+		{Product: models.Product{ID: 1, PostTitle: "Glock 19 Holster", Tags: &glock}, Similarity: 0.82},
+		{Product: models.Product{ID: 2, PostTitle: "Glock 17 Holster", Tags: &glock}, Similarity: 0.80},
+		{Product: models.Product{ID: 3, PostTitle: "Glock 26 Holster", Tags: &glock}, Similarity: 0.78},
+		{Product: models.Product{ID: 4, PostTitle: "Masada Holster", Tags: &featured}, Similarity: 0.45},
+	}
+}
+
+func resultIDs(results []ProductEmbedding) []int {
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.Product.ID
+	}
+	return ids
+}
+
+func TestIncludePromoted_SurvivesLimitAndFiltering(t *testing.T) {
+	service := &EmbeddingService{
+		tagTokenSet: map[string]struct{}{"glock": {}},
+		promotion:   newPromotion([]string{"featured"}, 0.3, 2),
+	}
+	results := promotedTestResults()
+
+	promoted := service.promotion.candidates(results)
+	require.Len(t, promoted, 1)
+
+	// Token filtering drops the Masada holster (no "glock"), and the limit would cut it anyway
+	service.refineResults(&results, "glock holster", SearchOptions{TokenFiltering: true, MinSimilarity: 0.5})
+	assert.Equal(t, []int{1, 2, 3}, resultIDs(results))
+
+	// The lowest-ranked result makes room and moves past the limit the caller applies next
+	results = includePromoted(results, promoted, 3, "TEST")
+	assert.Equal(t, []int{1, 2, 4, 3}, resultIDs(results))
+}
+
+func TestIncludePromoted_UnrelatedQuery(t *testing.T) {
+	promotion := newPromotion([]string{"featured"}, 0.3, 2)
+	results := promotedTestResults()
+	for i := range results {
+		results[i].Similarity -= 0.25 // A query loosely matching everything
+	}
+
+	assert.Empty(t, promotion.candidates(results), "promoted products below the relevance bar are not forced in")
+	assert.Equal(t, []int{1, 2, 3, 4}, resultIDs(includePromoted(results, nil, 3, "TEST")))
+}
+
+func TestIncludePromoted_AlreadyInResults(t *testing.T) {
+	promotion := newPromotion([]string{"featured"}, 0.3, 2)
+	results := promotedTestResults()
+
+	promoted := promotion.candidates(results)
+	assert.Equal(t, []int{1, 2, 3, 4}, resultIDs(includePromoted(results, promoted, 0, "TEST")))
+	assert.Equal(t, []int{1, 2, 3, 4}, resultIDs(includePromoted(results, promoted, 10, "TEST")))
+}
+
+func TestIncludePromoted_KeepsRefinedScoreAndOrder(t *testing.T) {
+	featured := "featured"
+	results := []ProductEmbedding{
+		{Product: models.Product{ID: 1}, Similarity: 0.90},
+		{Product: models.Product{ID: 2}, Similarity: 0.70},
+		{Product: models.Product{ID: 3}, Similarity: 0.60},
+		{Product: models.Product{ID: 4, Tags: &featured}, Similarity: 0.65}, // Boosted, but past the limit
+	}
+	promoted := []ProductEmbedding{{Product: models.Product{ID: 4, Tags: &featured}, Similarity: 0.40}}
+
+	merged := includePromoted(results, promoted, 3, "TEST")
+	assert.Equal(t, []int{1, 2, 4, 3}, resultIDs(merged))
+	assert.Equal(t, 0.65, merged[2].Similarity)
+}
+
+func TestPromotion_MaxResults(t *testing.T) {
+	featured := "featured"
+	var results []ProductEmbedding
+	for id := 1; id <= 5; id++ {
+		results = append(results, ProductEmbedding{Product: models.Product{ID: id, Tags: &featured}, Similarity: 0.5})
+	}
+
+	assert.Len(t, newPromotion([]string{"featured"}, 0.3, 2).candidates(results), 2)
+	assert.Empty(t, newPromotion([]string{"featured"}, 0.3, 0).candidates(results))
+	assert.Empty(t, newPromotion(nil, 0.3, 2).candidates(results))
+}
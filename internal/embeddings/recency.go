@@ -0,0 +1,54 @@
+package embeddings
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// mysqlDateTimeLayout is the format WordPress stores post_date in
+const mysqlDateTimeLayout = "2006-01-02 15:04:05"
+
+// parsePostDate converts a WordPress post_date into a time
+// Returns nil for NULL or zero dates ("0000-00-00 00:00:00")
+func parsePostDate(postDate sql.NullString) *time.Time {
+	if !postDate.Valid {
+		return nil
+	}
+	parsed, err := time.Parse(mysqlDateTimeLayout, postDate.String)
+	if err != nil || parsed.IsZero() {
+		return nil
+	}
+	return &parsed
+}
+
+// applyRecencyBoost boosts recently published products when a recency weight is configured
+func (es *EmbeddingService) applyRecencyBoost(results []ProductEmbedding) {
+	if es.recencyBoost <= 0 || es.recencyWindow <= 0 {
+		return
+	}
+	fmt.Printf("[VECTOR_SEARCH] Applying recency boost (weight: %.2f, window: %v)\n", es.recencyBoost, es.recencyWindow)
+	applyRecencyBoost(results, es.recencyBoost, es.recencyWindow, time.Now())
+}
+
+// applyRecencyBoost adds up to weight to each product's similarity, decaying linearly
+// to zero over window, then re-sorts by similarity. Undated products are not boosted.
+func applyRecencyBoost(results []ProductEmbedding, weight float64, window time.Duration, now time.Time) {
+	for i := range results {
+		publishedAt := results[i].Product.PublishedAt
+		if publishedAt == nil {
+			continue
+		}
+
+		age := now.Sub(*publishedAt)
+		if age < 0 {
+			age = 0
+		}
+		if age >= window {
+			continue
+		}
+		results[i].Similarity += weight * (1 - float64(age)/float64(window))
+	}
+
+	sortBySimilarity(results)
+}
@@ -0,0 +1,49 @@
+package embeddings
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePostDate(t *testing.T) {
+	parsed := parsePostDate(sql.NullString{String: "2024-03-15 10:30:00", Valid: true})
+	require.NotNil(t, parsed)
+	assert.Equal(t, time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC), *parsed)
+
+	assert.Nil(t, parsePostDate(sql.NullString{}))
+	assert.Nil(t, parsePostDate(sql.NullString{String: "0000-00-00 00:00:00", Valid: true}))
+	assert.Nil(t, parsePostDate(sql.NullString{String: "not a date", Valid: true}))
+}
+
+func TestApplyRecencyBoost(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		published := now.AddDate(0, 0, -days)
+		return &published
+	}
+
+	results := []ProductEmbedding{
+		{Product: models.Product{ID: 1, PublishedAt: daysAgo(400)}, Similarity: 0.80},
+		{Product: models.Product{ID: 2}, Similarity: 0.78},
+		{Product: models.Product{ID: 3, PublishedAt: daysAgo(45)}, Similarity: 0.75},
+		{Product: models.Product{ID: 4, PublishedAt: daysAgo(0)}, Similarity: 0.70},
+	}
+
+	applyRecencyBoost(results, 0.2, 90*24*time.Hour, now)
+
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.Product.ID
+	}
+	assert.Equal(t, []int{4, 3, 1, 2}, ids)
+	assert.InDelta(t, 0.90, results[0].Similarity, 1e-9) // Published today: full boost
+	assert.InDelta(t, 0.85, results[1].Similarity, 1e-9) // Halfway through the window: half boost
+	assert.InDelta(t, 0.80, results[2].Similarity, 1e-9) // Outside the window: no boost
+	assert.InDelta(t, 0.78, results[3].Similarity, 1e-9) // Undated: no boost
+}
@@ -0,0 +1,46 @@
+package embeddings
+
+import (
+	"fmt"
+	"time"
+
+	"ids/internal/database"
+	"ids/internal/vectordb"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReindexEmbeddings rebuilds the product_embeddings HNSW index for the configured distance metric
+// with the given build parameters
+func (wes *WriteEmbeddingService) ReindexEmbeddings(m, efConstruction int) error {
+	return ReindexProductEmbeddings(wes.writeDB, wes.distanceMetric, m, efConstruction)
+}
+
+// ReindexProductEmbeddings drops and recreates the product_embeddings HNSW index for metric with
+// the given m and ef_construction in one transaction, so a failed build keeps the old index.
+// Searches wait for the table lock until the new index is committed.
+func ReindexProductEmbeddings(writeClient *database.WriteClient, metric string, m, efConstruction int) error {
+	if err := vectordb.ValidateHNSWParams(m, efConstruction); err != nil {
+		return err
+	}
+
+	indexName := vectordb.HNSWIndexName("product_embeddings", metric)
+	fmt.Printf("[REINDEX] Rebuilding %s (m = %d, ef_construction = %d)...\n", indexName, m, efConstruction)
+	start := time.Now()
+
+	err := writeClient.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DROP INDEX IF EXISTS ` + indexName); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", indexName, err)
+		}
+		if _, err := tx.Exec(vectordb.HNSWIndexQueryWithParams("product_embeddings", metric, m, efConstruction)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", indexName, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[REINDEX] ✅ Rebuilt %s in %v\n", indexName, time.Since(start).Round(time.Millisecond))
+	return nil
+}
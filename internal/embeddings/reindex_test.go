@@ -0,0 +1,59 @@
+package embeddings
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReindexEmbeddings_RebuildsIndexInTransaction(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{
+		writeDB:        database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"), "tenant_a"),
+		distanceMetric: config.DistanceMetricL2,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL search_path TO "tenant_a", public`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DROP INDEX IF EXISTS idx_product_embeddings_hnsw_l2`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ON product_embeddings USING hnsw (embedding vector_l2_ops) WITH (m = 32, ef_construction = 200)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, wes.ReindexEmbeddings(32, 200))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReindexEmbeddings_FailedBuildKeepsOldIndex(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	writeClient := database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP INDEX IF EXISTS idx_product_embeddings_hnsw`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_product_embeddings_hnsw`).WillReturnError(errors.New("out of memory"))
+	mock.ExpectRollback()
+
+	err = ReindexProductEmbeddings(writeClient, config.DistanceMetricCosine, 16, 100)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create idx_product_embeddings_hnsw")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReindexEmbeddings_RejectsInvalidParams(t *testing.T) {
+	// Nothing reaches the database
+	err := ReindexProductEmbeddings(nil, config.DistanceMetricCosine, 64, 100)
+	assert.ErrorContains(t, err, "ef_construction must be at least 2*m")
+}
@@ -0,0 +1,133 @@
+package embeddings
+
+import (
+	"fmt"
+	"strings"
+
+	"ids/internal/database"
+)
+
+// defaultSynonyms maps query tokens to extra tokens used for term boosting
+// Used when the synonyms table is empty or cannot be read
+var defaultSynonyms = map[string][]string{
+	"dubon":   {"doobon", "parka", "coat"},
+	"doobon":  {"dubon", "parka", "coat"},
+	"coat":    {"jacket", "parka"},
+	"jacket":  {"coat", "parka"},
+	"recover": {"recovertactical"},
+	"p-ix":    {"pix", "p-ix+"},
+	"pix":     {"p-ix", "p-ix+"},
+}
+
+// synonymPair is a row of the synonyms table
+type synonymPair struct {
+	Term    string `db:"term"`
+	Synonym string `db:"synonym"`
+}
+
+// buildSynonymMap builds a lowercase lookup where each pair works in both directions
+func buildSynonymMap(pairs []synonymPair) map[string][]string {
+	synonyms := make(map[string][]string)
+	for _, pair := range pairs {
+		addSynonymPair(synonyms, pair.Term, pair.Synonym)
+	}
+	return synonyms
+}
+
+// addSynonymPair adds term <-> synonym to the lookup, skipping duplicates
+func addSynonymPair(synonyms map[string][]string, term, synonym string) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	synonym = strings.ToLower(strings.TrimSpace(synonym))
+	if term == "" || synonym == "" || term == synonym {
+		return
+	}
+	appendUnique(synonyms, term, synonym)
+	appendUnique(synonyms, synonym, term)
+}
+
+func appendUnique(synonyms map[string][]string, key, value string) {
+	for _, existing := range synonyms[key] {
+		if existing == value {
+			return
+		}
+	}
+	synonyms[key] = append(synonyms[key], value)
+}
+
+// copySynonyms returns a copy of the lookup so callers can extend it safely
+func copySynonyms(src map[string][]string) map[string][]string {
+	dst := make(map[string][]string, len(src))
+	for term, values := range src {
+		dst[term] = append([]string(nil), values...)
+	}
+	return dst
+}
+
+// loadSynonyms reads the synonyms table, falling back to the bundled defaults when it is
+// empty or unavailable (e.g. before CreateEmbeddingsTable has run)
+func (wes *WriteEmbeddingService) loadSynonyms() map[string][]string {
+	return loadSynonymMap(wes.writeDB, "WRITE_EMBEDDING_SERVICE")
+}
+
+// loadSynonymMap reads the synonyms table into a lookup, or copies the bundled defaults
+// when writeDB is nil or the table is empty or unreadable
+func loadSynonymMap(writeDB *database.WriteClient, logPrefix string) map[string][]string {
+	if writeDB == nil {
+		return copySynonyms(defaultSynonyms)
+	}
+
+	var pairs []synonymPair
+	if err := writeDB.ExecuteWriteQueryWithResult(&pairs, `SELECT term, synonym FROM synonyms ORDER BY term, synonym`); err != nil {
+		fmt.Printf("[%s] Warning: Failed to load synonyms, using defaults: %v\n", logPrefix, err)
+		return copySynonyms(defaultSynonyms)
+	}
+	if len(pairs) == 0 {
+		return copySynonyms(defaultSynonyms)
+	}
+
+	fmt.Printf("[%s] Loaded %d synonym pairs\n", logPrefix, len(pairs))
+	return buildSynonymMap(pairs)
+}
+
+// AddSynonym stores a synonym pair and makes it available to searches immediately
+// Pairs are case-insensitive and bidirectional. Once the table has rows it replaces
+// the bundled defaults the next time synonyms are loaded.
+func (wes *WriteEmbeddingService) AddSynonym(term, synonym string) error {
+	term = strings.ToLower(strings.TrimSpace(term))
+	synonym = strings.ToLower(strings.TrimSpace(synonym))
+	if term == "" || synonym == "" {
+		return fmt.Errorf("term and synonym are required")
+	}
+	if term == synonym {
+		return fmt.Errorf("synonym must differ from term")
+	}
+
+	query := `
+		INSERT INTO synonyms (term, synonym)
+		VALUES ($1, $2)
+		ON CONFLICT (term, synonym) DO NOTHING
+	`
+	if _, err := wes.writeDB.ExecuteWriteQuery(query, term, synonym); err != nil {
+		return fmt.Errorf("failed to store synonym: %v", err)
+	}
+
+	wes.synonymsMu.Lock()
+	defer wes.synonymsMu.Unlock()
+	if wes.synonyms == nil {
+		wes.synonyms = make(map[string][]string)
+	}
+	addSynonymPair(wes.synonyms, term, synonym)
+	return nil
+}
+
+// expandSynonyms adds synonyms to the token list
+func (wes *WriteEmbeddingService) expandSynonyms(tokens []string) []string {
+	wes.synonymsMu.RLock()
+	defer wes.synonymsMu.RUnlock()
+
+	synonyms := wes.synonyms
+	if synonyms == nil {
+		synonyms = defaultSynonyms
+	}
+	return expandSynonymsCapped(tokens, synonyms, wes.synonymsPerToken, wes.synonymsTotal)
+}
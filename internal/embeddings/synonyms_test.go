@@ -0,0 +1,69 @@
+package embeddings
+
+import (
+	"regexp"
+	"testing"
+
+	"ids/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSynonymTestService(t *testing.T) (*WriteEmbeddingService, sqlmock.Sqlmock) {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	return &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))}, mock
+}
+
+func TestLoadSynonyms_FromTableIsBidirectional(t *testing.T) {
+	wes, mock := newSynonymTestService(t)
+	mock.ExpectQuery(`SELECT term, synonym FROM synonyms`).
+		WillReturnRows(sqlmock.NewRows([]string{"term", "synonym"}).
+			AddRow("Vest", "plate carrier").
+			AddRow("vest", "chest rig"))
+
+	synonyms := wes.loadSynonyms()
+	assert.Equal(t, []string{"plate carrier", "chest rig"}, synonyms["vest"])
+	assert.Equal(t, []string{"vest"}, synonyms["plate carrier"])
+	assert.Equal(t, []string{"vest"}, synonyms["chest rig"])
+	assert.NotContains(t, synonyms, "dubon")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadSynonyms_EmptyTableUsesDefaults(t *testing.T) {
+	wes, mock := newSynonymTestService(t)
+	mock.ExpectQuery(`SELECT term, synonym FROM synonyms`).
+		WillReturnRows(sqlmock.NewRows([]string{"term", "synonym"}))
+
+	assert.Equal(t, defaultSynonyms, wes.loadSynonyms())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddSynonym(t *testing.T) {
+	wes, mock := newSynonymTestService(t)
+	wes.synonyms = copySynonyms(defaultSynonyms)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO synonyms (term, synonym)`)).
+		WithArgs("molle", "pals").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, wes.AddSynonym(" MOLLE ", "PALS"))
+	assert.Equal(t, []string{"molle", "pals"}, wes.expandSynonyms([]string{"molle"}))
+	assert.Equal(t, []string{"Pals", "molle"}, wes.expandSynonyms([]string{"Pals"}))
+	assert.Contains(t, wes.expandSynonyms([]string{"dubon"}), "parka")
+	assert.NotContains(t, defaultSynonyms, "molle")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddSynonym_Validation(t *testing.T) {
+	wes, mock := newSynonymTestService(t)
+
+	assert.Error(t, wes.AddSynonym("", "pals"))
+	assert.Error(t, wes.AddSynonym("Vest", "vest"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
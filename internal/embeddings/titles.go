@@ -0,0 +1,49 @@
+package embeddings
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"ids/internal/models"
+)
+
+// slugSeparators turns a WordPress slug like "glock-19-holster" into readable words
+var slugSeparators = strings.NewReplacer("-", " ", "_", " ")
+
+// displayTitle returns the product title, falling back to its slug and then its SKU
+// for catalogs where the title lives elsewhere. Returns "" when none are set.
+func displayTitle(product models.Product) string {
+	if title := strings.TrimSpace(product.PostTitle); title != "" {
+		return product.PostTitle
+	}
+	if product.PostName != nil && strings.TrimSpace(*product.PostName) != "" {
+		name := *product.PostName
+		// WordPress stores non-ASCII (e.g. Hebrew) slugs percent-encoded
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		return strings.TrimSpace(slugSeparators.Replace(name))
+	}
+	if product.SKU != nil {
+		return strings.TrimSpace(*product.SKU)
+	}
+	return ""
+}
+
+// applyTitlePolicy drops untitled products when requireTitle is set (SEARCH_REQUIRE_TITLE),
+// otherwise gives them a display title from their slug or SKU
+func applyTitlePolicy(results []ProductEmbedding, requireTitle bool, logPrefix string) []ProductEmbedding {
+	kept := results[:0]
+	for _, result := range results {
+		if strings.TrimSpace(result.Product.PostTitle) == "" {
+			if requireTitle {
+				fmt.Printf("[%s] Skipping product %d: empty title\n", logPrefix, result.Product.ID)
+				continue
+			}
+			result.Product.PostTitle = displayTitle(result.Product)
+		}
+		kept = append(kept, result)
+	}
+	return kept
+}
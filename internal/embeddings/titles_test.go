@@ -0,0 +1,95 @@
+package embeddings
+
+import (
+	"context"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisplayTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		product  models.Product
+		expected string
+	}{
+		{name: "title wins", product: models.Product{PostTitle: "Glock 19 Holster", PostName: strPtr("glock-19-holster"), SKU: strPtr("HOL-G19")}, expected: "Glock 19 Holster"},
+		{name: "slug fallback", product: models.Product{PostName: strPtr("glock-19-holster"), SKU: strPtr("HOL-G19")}, expected: "glock 19 holster"},
+		{name: "percent-encoded hebrew slug", product: models.Product{PostName: strPtr("%d7%a0%d7%a8%d7%aa%d7%99%d7%a7")}, expected: "נרתיק"},
+		{name: "sku fallback", product: models.Product{PostTitle: "  ", PostName: strPtr(""), SKU: strPtr("HOL-G19")}, expected: "HOL-G19"},
+		{name: "nothing to show", product: models.Product{}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, displayTitle(tt.product))
+		})
+	}
+}
+
+func untitledResults() []ProductEmbedding {
+	return []ProductEmbedding{
+		{Product: models.Product{ID: 1, PostTitle: "Plate Carrier"}, Similarity: 0.9},
+		{Product: models.Product{ID: 2, PostName: strPtr("magazine-pouch")}, Similarity: 0.8},
+		{Product: models.Product{ID: 3, SKU: strPtr("SLG-01")}, Similarity: 0.7},
+	}
+}
+
+func TestApplyTitlePolicy_RequireTitle(t *testing.T) {
+	results := applyTitlePolicy(untitledResults(), true, "TEST")
+	require.Len(t, results, 1)
+	assert.Equal(t, "Plate Carrier", results[0].Product.PostTitle)
+}
+
+func TestApplyTitlePolicy_FallbackTitles(t *testing.T) {
+	results := applyTitlePolicy(untitledResults(), false, "TEST")
+	require.Len(t, results, 3)
+	assert.Equal(t, "Plate Carrier", results[0].Product.PostTitle)
+	assert.Equal(t, "magazine pouch", results[1].Product.PostTitle)
+	assert.Equal(t, "SLG-01", results[2].Product.PostTitle)
+}
+
+func TestQueryProductCandidates_UntitledProducts(t *testing.T) {
+	columns := []string{"product_id", "embedding", "post_title", "post_name", "description", "short_description",
+		"sku", "min_price", "max_price", "stock_status", "stock_quantity", "tags", "published_at", "distance"}
+
+	for _, requireTitle := range []bool{true, false} {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		writeClient := database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))
+
+		rows := sqlmock.NewRows(columns).
+			AddRow(1, "[0.1]", "Plate Carrier", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, 0.1)
+		if !requireTitle {
+			// The SQL filter is off, so the database also returns the untitled product
+			rows.AddRow(2, "[0.1]", "", "magazine-pouch", nil, nil, "MAG-01", nil, nil, nil, nil, nil, nil, 0.2)
+		}
+		mock.ExpectQuery(`NOT \$3::boolean OR \(post_title IS NOT NULL AND post_title != ''\)`).
+			WithArgs("[0.1]", 50, requireTitle, nil, nil, nil, nil).
+			WillReturnRows(rows)
+
+		result, err := queryProductCandidates(context.Background(), writeClient, config.SearchModeVector, config.DistanceMetricCosine, "pouch", "[0.1]", 50, requireTitle, SearchFilters{}, indexTuning{}, "TEST")
+		require.NoError(t, err)
+		results := applyTitlePolicy(ScanProductEmbeddingRows(result.Rows, config.DistanceMetricCosine, "TEST"), requireTitle, "TEST")
+		require.NoError(t, result.Close())
+
+		titles := make([]string, len(results))
+		for i, r := range results {
+			titles[i] = r.Product.PostTitle
+		}
+		if requireTitle {
+			assert.Equal(t, []string{"Plate Carrier"}, titles)
+		} else {
+			assert.Equal(t, []string{"Plate Carrier", "magazine pouch"}, titles)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+		_ = mockDB.Close()
+	}
+}
@@ -0,0 +1,64 @@
+package embeddings
+
+import "ids/internal/utils"
+
+// Search backends reported in SearchTrace
+const (
+	traceBackendPgvector = "pgvector"
+	traceBackendQdrant   = "qdrant"
+)
+
+// SearchTrace records how a product search reached its results, for relevance tuning
+// Pass one in SearchOptions.Trace; the search fills it in place.
+type SearchTrace struct {
+	Backend              string              // Vector store that served the search (pgvector or qdrant)
+	QueryTokens          []string            // Meaningful tokens of the normalized query
+	RequiredTokens       []string            // Tokens every result must contain when token filtering is on
+	FallbackToSimilarity bool                // Token filtering removed every result and was skipped
+	Results              map[int]ResultTrace // Scoring of each raw candidate, keyed by product ID
+}
+
+// ResultTrace is the scoring of one search candidate
+type ResultTrace struct {
+	Distance      float64 // Raw pgvector distance (0 for Qdrant, which only returns similarity)
+	RawSimilarity float64 // Vector similarity before boosts
+	Boost         float64 // Final similarity minus RawSimilarity
+}
+
+// recordQuery stores the query tokens; a nil trace is a no-op and skips requiredTokens
+func (t *SearchTrace) recordQuery(backend, query string, requiredTokens func(query string) []string) {
+	if t == nil {
+		return
+	}
+	t.Backend = backend
+	t.QueryTokens = utils.ExtractMeaningfulTokens(query)
+	t.RequiredTokens = requiredTokens(query)
+}
+
+// recordCandidates stores the raw distance and similarity of every candidate before refinement
+func (t *SearchTrace) recordCandidates(results []ProductEmbedding) {
+	if t == nil {
+		return
+	}
+	t.Results = make(map[int]ResultTrace, len(results))
+	for _, result := range results {
+		t.Results[result.Product.ID] = ResultTrace{
+			Distance:      result.Distance,
+			RawSimilarity: result.Similarity,
+		}
+	}
+}
+
+// recordResults stores the boost each returned result received and whether token filtering fell back
+func (t *SearchTrace) recordResults(results []ProductEmbedding, fallbackToSimilarity bool) {
+	if t == nil {
+		return
+	}
+	t.FallbackToSimilarity = fallbackToSimilarity
+	for _, result := range results {
+		if trace, ok := t.Results[result.Product.ID]; ok {
+			trace.Boost = result.Similarity - trace.RawSimilarity
+			t.Results[result.Product.ID] = trace
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package embeddings
+
+import (
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTrace_RecordsRawScoresAndBoosts(t *testing.T) {
+	trace := &SearchTrace{}
+	candidates := []ProductEmbedding{
+		{Product: models.Product{ID: 1}, Similarity: 0.8, Distance: 0.2},
+		{Product: models.Product{ID: 2}, Similarity: 0.7, Distance: 0.3},
+		{Product: models.Product{ID: 3}, Similarity: 0.6, Distance: 0.4},
+	}
+
+	trace.recordQuery(traceBackendPgvector, "glock 19 holster", func(string) []string { return []string{"19"} })
+	trace.recordCandidates(candidates)
+
+	// Boosting reorders results; product 3 was filtered out
+	returned := []ProductEmbedding{
+		{Product: models.Product{ID: 2}, Similarity: 0.95},
+		{Product: models.Product{ID: 1}, Similarity: 0.8},
+	}
+	trace.recordResults(returned, true)
+
+	assert.Equal(t, traceBackendPgvector, trace.Backend)
+	assert.Equal(t, []string{"glock", "19", "holster"}, trace.QueryTokens)
+	assert.Equal(t, []string{"19"}, trace.RequiredTokens)
+	assert.True(t, trace.FallbackToSimilarity)
+	assert.Equal(t, ResultTrace{Distance: 0.2, RawSimilarity: 0.8}, trace.Results[1])
+	assert.Equal(t, 0.3, trace.Results[2].Distance)
+	assert.InDelta(t, 0.25, trace.Results[2].Boost, 1e-9)
+	assert.Equal(t, ResultTrace{Distance: 0.4, RawSimilarity: 0.6}, trace.Results[3])
+}
+
+func TestSearchTrace_NilIsNoop(t *testing.T) {
+	var trace *SearchTrace
+	called := false
+
+	trace.recordQuery(traceBackendQdrant, "holster", func(string) []string { called = true; return nil })
+	trace.recordCandidates([]ProductEmbedding{{Product: models.Product{ID: 1}}})
+	trace.recordResults(nil, false)
+
+	assert.False(t, called)
+}
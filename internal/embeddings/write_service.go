@@ -5,8 +5,11 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"ids/internal/config"
@@ -15,6 +18,10 @@ import (
 	idsopenai "ids/internal/openai"
 	"ids/internal/utils"
 	"ids/internal/vectordb"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -31,6 +38,7 @@ const (
 			l.max_price,
 			l.stock_status,
 			l.stock_quantity,
+			p.post_date,
 			GROUP_CONCAT(DISTINCT t.name ORDER BY t.name SEPARATOR ', ') AS tags
 		FROM wpjr_wc_product_meta_lookup l
 		JOIN wpjr_posts p ON p.ID = l.product_id
@@ -41,48 +49,94 @@ const (
 		WHERE p.post_type = 'product'
 			AND p.post_status IN ('publish','private')
 		GROUP BY
-			p.ID, p.post_title, p.post_name, p.post_content, p.post_excerpt,
+			p.ID, p.post_title, p.post_name, p.post_content, p.post_excerpt, p.post_date,
 			l.sku, l.min_price, l.max_price, l.stock_status, l.stock_quantity
 		ORDER BY p.ID
 	`
 
-	// queryProductEmbeddingsPgvector fetches product embeddings with similarity using pgvector
-	// The $1 parameter is the query vector, $2 is the limit
-	queryProductEmbeddingsPgvector = `
-		SELECT
-			product_id,
-			embedding::text,
-			COALESCE(post_title, '') as post_title,
-			post_name,
-			description,
-			short_description,
-			sku,
-			min_price,
-			max_price,
-			stock_status,
-			stock_quantity,
-			tags,
-			1 - (embedding <=> $1::vector) AS similarity
-		FROM product_embeddings
-		WHERE post_title IS NOT NULL AND post_title != ''
-		ORDER BY embedding <=> $1::vector
-		LIMIT $2
-	`
-
 	stockStatusUnknown = "unknown"
 )
 
+// queryProductEmbeddingsPgvector fetches product embeddings with their distance using pgvector
+// The $1 parameter is the query vector, $2 is the limit, $3 whether an empty post_title is excluded,
+// and $4-$7 the SearchFilters (see productFilterPredicates)
+// Written with the cosine operator; queryProductCandidates applies VECTOR_DISTANCE_METRIC
+var queryProductEmbeddingsPgvector = fmt.Sprintf(`
+	SELECT
+		product_id,
+		embedding::text,
+		COALESCE(post_title, '') as post_title,
+		post_name,
+		description,
+		short_description,
+		sku,
+		min_price,
+		max_price,
+		stock_status,
+		stock_quantity,
+		tags,
+		published_at,
+		embedding <=> $1::vector AS distance
+	FROM product_embeddings
+	WHERE (NOT $3::boolean OR (post_title IS NOT NULL AND post_title != ''))
+		AND %s
+	ORDER BY embedding <=> $1::vector
+	LIMIT $2
+`, productFilterPredicates(4))
+
 // WriteEmbeddingService handles vector embeddings with write access
 type WriteEmbeddingService struct {
 	client       *idsopenai.Client      // Unified client with Azure/OpenAI fallback
-	readDB       *sql.DB                // Remote MySQL for reading products
+	readDB       *sql.DB                // Remote MySQL for reading products (nil while unavailable)
+	readMu       sync.RWMutex           // Guards readDB, which is replaced when the read database reconnects
 	writeDB      *database.WriteClient  // Local PostgreSQL for writing embeddings
 	qdrantClient *vectordb.QdrantClient // Qdrant client for dual-write (optional)
+	weights      scoreWeights           // Weighting between vector similarity and keyword score
+	termBoosting bool                   // Apply keyword/tag boosting (false keeps pure pgvector order)
+	dimensions   int                    // Embedding vector size probed from the embedding model
+	onMismatch   string                 // Stored vector size differs from dimensions: fail, warn or recreate (EMBEDDING_DIMENSION_MISMATCH)
+	keywordRules map[string]string      // Title substring -> extra embedding keywords (PRODUCT_KEYWORD_RULES_FILE)
+
+	minSimilarity  float64     // Drop results scoring below this similarity (SEARCH_MIN_SIMILARITY)
+	documentPrefix string      // Prepended to product text before embedding (EMBEDDING_INPUT_PREFIX)
+	queryPrefix    string      // Prepended to search queries before embedding (QUERY_INPUT_PREFIX)
+	searchMode     string      // pgvector retrieval: vector or hybrid full-text fusion (SEARCH_MODE)
+	requireTitle   bool        // Exclude untitled products instead of showing them by slug or SKU (SEARCH_REQUIRE_TITLE)
+	distanceMetric string      // pgvector distance metric: cosine, l2 or inner_product (VECTOR_DISTANCE_METRIC)
+	indexType      string      // pgvector index on product_embeddings (VECTOR_INDEX_TYPE)
+	tuning         indexTuning // Per-query pgvector index settings (HNSW_EF_SEARCH, IVFFLAT_PROBES)
+
+	promotion promotion // Keeps loosely relevant products with PROMOTED_TAGS in results
+
+	synonymsMu sync.RWMutex
+	synonyms   map[string][]string // Query token -> synonyms for term boosting (synonyms table or defaults)
+
+	synonymsPerToken int // Maximum synonyms added per query token (0 = unlimited)
+	synonymsTotal    int // Maximum synonyms added per query (0 = unlimited)
+
+	concurrency int // Maximum batches in flight during generation (EMBEDDING_CONCURRENCY)
+
+	descriptionFields string // Descriptions included in the product text: full, short or both (EMBEDDING_DESCRIPTION_FIELDS)
 }
 
+// scoreWeights controls how vector similarity and keyword score combine into the final score
+type scoreWeights struct {
+	Similarity float64 // alpha
+	Keyword    float64 // beta
+	TagPhrase  float64 // Added to the keyword score for an exact tag phrase match (outside the token boost cap)
+}
+
+// defaultScoreWeights reproduces the original similarity + capped boost scoring
+var defaultScoreWeights = scoreWeights{Similarity: 1.0, Keyword: 1.0}
+
 // NewWriteEmbeddingService creates a new write-enabled embedding service
 // qdrantClient: Optional Qdrant client for dual-write (pass nil to disable)
 func NewWriteEmbeddingService(cfg *config.Config, readDB *sql.DB, writeClient *database.WriteClient, qdrantClient ...*vectordb.QdrantClient) (*WriteEmbeddingService, error) {
+	keywordRules, err := LoadKeywordRules(cfg.KeywordRulesFile)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create unified client with Azure OpenAI (primary) and OpenAI (fallback)
 	client, err := idsopenai.NewClient(cfg)
 	if err != nil {
@@ -96,6 +150,10 @@ func NewWriteEmbeddingService(cfg *config.Config, readDB *sql.DB, writeClient *d
 	if err := client.TestConnection(ctx); err != nil {
 		return nil, err
 	}
+	dimensions, err := client.EmbeddingDimensions(ctx) // Cached by TestConnection
+	if err != nil {
+		return nil, err
+	}
 
 	fmt.Printf("[WRITE_EMBEDDING_SERVICE] Using %s for embeddings (model: %s)\n",
 		client.GetProviderName(), client.GetEmbeddingModel())
@@ -104,11 +162,38 @@ func NewWriteEmbeddingService(cfg *config.Config, readDB *sql.DB, writeClient *d
 		client:  client,
 		readDB:  readDB,
 		writeDB: writeClient,
+		weights: scoreWeights{Similarity: cfg.SimilarityWeight, Keyword: cfg.KeywordWeight, TagPhrase: cfg.TagPhraseBoost},
+
+		termBoosting: cfg.EnableTermBoosting,
+		dimensions:   dimensions,
+		onMismatch:   cfg.OnDimensionMismatch,
+		keywordRules: keywordRules,
+
+		minSimilarity:  cfg.SearchMinSimilarity,
+		documentPrefix: cfg.EmbeddingInputPrefix,
+		queryPrefix:    cfg.QueryInputPrefix,
+		searchMode:     cfg.SearchMode,
+		requireTitle:   cfg.SearchRequireTitle,
+		distanceMetric: cfg.DistanceMetric,
+		indexType:      cfg.IndexType,
+		tuning:         indexTuningFromConfig(cfg),
+
+		promotion: newPromotion(cfg.PromotedTags, cfg.PromotedMinSimilarity, cfg.PromotedMaxResults),
+
+		synonymsPerToken: cfg.SynonymsPerToken,
+		synonymsTotal:    cfg.SynonymsTotal,
+
+		concurrency: cfg.EmbeddingConcurrency,
+
+		descriptionFields: cfg.EmbeddingDescriptionFields,
 	}
 
+	service.synonyms = service.loadSynonyms()
+
 	// Set Qdrant client if provided
 	if len(qdrantClient) > 0 && qdrantClient[0] != nil {
 		service.qdrantClient = qdrantClient[0]
+		service.qdrantClient.SetVectorDimensions(dimensions)
 		fmt.Printf("[WRITE_EMBEDDING_SERVICE] Qdrant dual-write enabled\n")
 
 		// Ensure Qdrant collections exist
@@ -129,10 +214,12 @@ func (wes *WriteEmbeddingService) calculateProductChecksum(product models.Produc
 	if product.PostName != nil {
 		parts = append(parts, fmt.Sprintf("name:%s", *product.PostName))
 	}
-	if product.Description != nil {
+	// Only the descriptions that are embedded, so switching EMBEDDING_DESCRIPTION_FIELDS re-embeds affected products
+	includeFull, includeShort := descriptionFields(wes.descriptionFields)
+	if includeFull && product.Description != nil {
 		parts = append(parts, fmt.Sprintf("desc:%s", *product.Description))
 	}
-	if product.ShortDescription != nil {
+	if includeShort && product.ShortDescription != nil {
 		parts = append(parts, fmt.Sprintf("short:%s", *product.ShortDescription))
 	}
 	if product.SKU != nil {
@@ -153,6 +240,14 @@ func (wes *WriteEmbeddingService) calculateProductChecksum(product models.Produc
 	if product.Tags != nil {
 		parts = append(parts, fmt.Sprintf("tags:%s", *product.Tags))
 	}
+	// Re-embed every product when the document prefix changes
+	if wes.documentPrefix != "" {
+		parts = append(parts, fmt.Sprintf("prefix:%s", wes.documentPrefix))
+	}
+	// Re-embed matching products when their keyword rules change
+	if keywords := matchKeywordRules(product.PostTitle, wes.keywordRules); len(keywords) > 0 {
+		parts = append(parts, fmt.Sprintf("keywords:%s", strings.Join(keywords, ",")))
+	}
 
 	content := strings.Join(parts, "|")
 	hash := sha256.Sum256([]byte(content))
@@ -160,11 +255,11 @@ func (wes *WriteEmbeddingService) calculateProductChecksum(product models.Produc
 }
 
 // getStoredChecksums retrieves all stored product checksums from the database
-func (wes *WriteEmbeddingService) getStoredChecksums() (map[int]string, error) {
+func (wes *WriteEmbeddingService) getStoredChecksums(ctx context.Context) (map[int]string, error) {
 	checksums := make(map[int]string)
 	query := `SELECT product_id, checksum FROM product_checksums`
 
-	rows, err := wes.writeDB.GetDB().Query(query)
+	rows, err := wes.writeDB.GetDB().QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch checksums: %v", err)
 	}
@@ -186,8 +281,61 @@ func (wes *WriteEmbeddingService) getStoredChecksums() (map[int]string, error) {
 	return checksums, nil
 }
 
+// staleProductIDs returns the stored product IDs that are missing from the current product list
+func staleProductIDs(storedIDs []int64, products []models.Product) []int64 {
+	current := make(map[int64]struct{}, len(products))
+	for _, product := range products {
+		current[int64(product.ID)] = struct{}{}
+	}
+
+	var stale []int64
+	for _, id := range storedIDs {
+		if _, ok := current[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+	return stale
+}
+
+// deleteStaleEmbeddings removes embeddings and checksums for products that no longer exist
+// in WooCommerce (deleted or unpublished) and returns the number of products removed
+func (wes *WriteEmbeddingService) deleteStaleEmbeddings(ctx context.Context, products []models.Product) (int, error) {
+	var storedIDs []int64
+	if err := wes.writeDB.ExecuteWriteQueryWithResultContext(ctx, &storedIDs, `SELECT product_id FROM product_embeddings`); err != nil {
+		return 0, fmt.Errorf("failed to fetch stored product IDs: %v", err)
+	}
+
+	staleIDs := staleProductIDs(storedIDs, products)
+	if len(staleIDs) == 0 {
+		return 0, nil
+	}
+
+	err := wes.writeDB.WithTransactionContext(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM product_embeddings WHERE product_id = ANY($1)`, pq.Array(staleIDs)); err != nil {
+			return fmt.Errorf("failed to delete stale embeddings: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM product_checksums WHERE product_id = ANY($1)`, pq.Array(staleIDs)); err != nil {
+			return fmt.Errorf("failed to delete stale checksums: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("[WRITE_EMBEDDING_GEN] Deleted embeddings for %d products no longer in the store\n", len(staleIDs))
+
+	// Keep Qdrant in sync (non-blocking - PostgreSQL is the source of truth)
+	if wes.qdrantClient != nil {
+		if err := wes.qdrantClient.DeleteProducts(ctx, staleIDs); err != nil {
+			fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Failed to delete stale products from Qdrant: %v\n", err)
+		}
+	}
+
+	return len(staleIDs), nil
+}
+
 // updateProductChecksum stores or updates the checksum for a product
-func (wes *WriteEmbeddingService) updateProductChecksum(productID int, checksum string) error {
+func (wes *WriteEmbeddingService) updateProductChecksum(ctx context.Context, productID int, checksum string) error {
 	query := `
 		INSERT INTO product_checksums (product_id, checksum, last_checked)
 		VALUES ($1, $2, CURRENT_TIMESTAMP)
@@ -196,33 +344,74 @@ func (wes *WriteEmbeddingService) updateProductChecksum(productID int, checksum
 			last_checked = CURRENT_TIMESTAMP
 	`
 
-	_, err := wes.writeDB.ExecuteWriteQuery(query, productID, checksum)
+	_, err := wes.writeDB.ExecuteWriteQueryContext(ctx, query, productID, checksum)
 	return err
 }
 
 // EmbeddingStats contains statistics about an embedding generation run
 type EmbeddingStats struct {
-	TotalProducts   int
-	ChangedProducts int
-	Success         bool
+	TotalProducts    int
+	ChangedProducts  int
+	DeletedProducts  int // Stale embeddings removed for products no longer in the store
+	BatchesProcessed int
+	Provider         string // Embedding provider used for the run (e.g. "Azure OpenAI")
+	Model            string // Embedding model/deployment used for the run
+	Success          bool
+}
+
+// syncPublishedDates updates publish dates for stored embeddings in a single query
+func (wes *WriteEmbeddingService) syncPublishedDates(ctx context.Context, products []models.Product) error {
+	ids := make([]int64, 0, len(products))
+	dates := make([]string, 0, len(products))
+	for _, product := range products {
+		if product.PublishedAt == nil {
+			continue
+		}
+		ids = append(ids, int64(product.ID))
+		dates = append(dates, product.PublishedAt.Format(time.RFC3339))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE product_embeddings pe
+		SET published_at = v.published_at
+		FROM unnest($1::int[], $2::timestamp[]) AS v(product_id, published_at)
+		WHERE pe.product_id = v.product_id
+			AND pe.published_at IS DISTINCT FROM v.published_at
+	`
+	_, err := wes.writeDB.ExecuteWriteQueryContext(ctx, query, pq.Array(ids), pq.Array(dates))
+	return err
 }
 
 // GenerateProductEmbeddings generates embeddings only for products that have changed
-func (wes *WriteEmbeddingService) GenerateProductEmbeddings() error {
-	_, err := wes.GenerateProductEmbeddingsWithStats()
+func (wes *WriteEmbeddingService) GenerateProductEmbeddings(ctx context.Context) error {
+	_, err := wes.GenerateProductEmbeddingsWithStats(ctx)
 	return err
 }
 
 // GenerateProductEmbeddingsWithStats generates embeddings and returns statistics
-func (wes *WriteEmbeddingService) GenerateProductEmbeddingsWithStats() (*EmbeddingStats, error) {
+// Batches run concurrently (EMBEDDING_CONCURRENCY). Canceling ctx or a failing batch stops
+// new batches from starting; finished batches keep their checksums, so the next run
+// resumes with the remaining products.
+func (wes *WriteEmbeddingService) GenerateProductEmbeddingsWithStats(ctx context.Context) (*EmbeddingStats, error) {
 	stats := &EmbeddingStats{}
+	if wes.client != nil {
+		stats.Provider = wes.client.GetProviderName()
+		stats.Model = wes.client.GetEmbeddingModel()
+	}
 	fmt.Printf("[WRITE_EMBEDDING_GEN] ===== STARTING INCREMENTAL EMBEDDING GENERATION =====\n")
 
 	fmt.Printf("[WRITE_EMBEDDING_GEN] Fetching products from database...\n")
 	var allProducts []models.Product
 
 	// Use readDB (MySQL) for reading products from remote database
-	rows, err := wes.readDB.Query(queryProducts)
+	readDB := wes.productDB()
+	if readDB == nil {
+		return stats, fmt.Errorf("failed to fetch products: product database connection not available")
+	}
+	rows, err := readDB.QueryContext(ctx, queryProducts)
 	if err != nil {
 		fmt.Printf("[WRITE_EMBEDDING_GEN] ERROR: Failed to fetch products: %v\n", err)
 		return stats, fmt.Errorf("failed to fetch products: %v", err)
@@ -233,8 +422,10 @@ func (wes *WriteEmbeddingService) GenerateProductEmbeddingsWithStats() (*Embeddi
 		}
 	}()
 
+	scanErrors := 0
 	for rows.Next() {
 		var product models.Product
+		var postDate sql.NullString
 		err := rows.Scan(
 			&product.ID,
 			&product.PostTitle,
@@ -246,21 +437,36 @@ func (wes *WriteEmbeddingService) GenerateProductEmbeddingsWithStats() (*Embeddi
 			&product.MaxPrice,
 			&product.StockStatus,
 			&product.StockQuantity,
+			&postDate,
 			&product.Tags,
 		)
 		if err != nil {
 			fmt.Printf("[WRITE_EMBEDDING_GEN] ERROR: Failed to scan product: %v\n", err)
+			scanErrors++
 			continue
 		}
+		product.PublishedAt = parsePostDate(postDate)
 		allProducts = append(allProducts, product)
 	}
 
 	fmt.Printf("[WRITE_EMBEDDING_GEN] Found %d total products in database\n", len(allProducts))
 	stats.TotalProducts = len(allProducts)
 
+	// Remove embeddings for deleted/unpublished products so they stop surfacing in search
+	// Skipped when the product list may be incomplete, to avoid deleting live products
+	if rows.Err() != nil || scanErrors > 0 || len(allProducts) == 0 {
+		fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Product list may be incomplete, skipping stale embedding cleanup\n")
+	} else {
+		deleted, err := wes.deleteStaleEmbeddings(ctx, allProducts)
+		if err != nil {
+			fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Failed to delete stale embeddings: %v\n", err)
+		}
+		stats.DeletedProducts = deleted
+	}
+
 	// Get stored checksums
 	fmt.Printf("[WRITE_EMBEDDING_GEN] Fetching stored product checksums...\n")
-	storedChecksums, err := wes.getStoredChecksums()
+	storedChecksums, err := wes.getStoredChecksums(ctx)
 	if err != nil {
 		fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Failed to fetch checksums (will process all products): %v\n", err)
 		storedChecksums = make(map[int]string)
@@ -278,11 +484,16 @@ func (wes *WriteEmbeddingService) GenerateProductEmbeddingsWithStats() (*Embeddi
 	}
 
 	fmt.Printf("[WRITE_EMBEDDING_GEN] Found %d changed/new products out of %d total\n", len(changedProducts), len(allProducts))
+
+	// Publish dates don't affect the embedding, so keep them in sync without re-embedding
+	if err := wes.syncPublishedDates(ctx, allProducts); err != nil {
+		fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Failed to sync product publish dates: %v\n", err)
+	}
 	stats.ChangedProducts = len(changedProducts)
 
 	if len(changedProducts) == 0 {
 		fmt.Printf("[WRITE_EMBEDDING_GEN] No products changed. Skipping embedding generation.\n")
-		fmt.Printf("[WRITE_EMBEDDING_GEN] ===== EMBEDDING GENERATION COMPLETE (NO CHANGES) =====\n")
+		fmt.Printf("[WRITE_EMBEDDING_GEN] ===== EMBEDDING GENERATION COMPLETE (NO CHANGES, %d DELETED) =====\n", stats.DeletedProducts)
 		stats.Success = true
 		return stats, nil
 	}
@@ -290,35 +501,40 @@ func (wes *WriteEmbeddingService) GenerateProductEmbeddingsWithStats() (*Embeddi
 	// Process changed products in batches to avoid API limits
 	batchSize := 100
 	totalBatches := (len(changedProducts) + batchSize - 1) / batchSize
-	fmt.Printf("[WRITE_EMBEDDING_GEN] Processing %d changed products in %d batches of %d\n", len(changedProducts), totalBatches, batchSize)
-
-	for i := 0; i < len(changedProducts); i += batchSize {
-		end := i + batchSize
-		if end > len(changedProducts) {
-			end = len(changedProducts)
-		}
-
-		batchNum := (i / batchSize) + 1
-		fmt.Printf("[WRITE_EMBEDDING_GEN] Processing batch %d/%d (products %d-%d)...\n", batchNum, totalBatches, i+1, end)
+	concurrency := max(wes.concurrency, 1)
+	fmt.Printf("[WRITE_EMBEDDING_GEN] Processing %d changed products in %d batches of %d (%d concurrent)\n", len(changedProducts), totalBatches, batchSize, concurrency)
 
-		batch := changedProducts[i:end]
-		if err := wes.processBatch(batch); err != nil {
-			fmt.Printf("[WRITE_EMBEDDING_GEN] ERROR: Failed to process batch %d-%d: %v\n", i, end, err)
-			return stats, fmt.Errorf("failed to process batch %d-%d: %v", i, end, err)
+	completed, err := runBatches(ctx, changedProducts, batchSize, concurrency, func(batchCtx context.Context, batch []models.Product) error {
+		if err := wes.processBatch(batchCtx, batch); err != nil {
+			return err
 		}
 
 		// Update checksums for successfully processed products
+		// Uses ctx rather than batchCtx so a failing sibling batch doesn't discard this batch's progress
 		for _, product := range batch {
 			checksum := wes.calculateProductChecksum(product)
-			if err := wes.updateProductChecksum(product.ID, checksum); err != nil {
+			if err := wes.updateProductChecksum(ctx, product.ID, checksum); err != nil {
 				fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Failed to update checksum for product %d: %v\n", product.ID, err)
 			}
 		}
+		return nil
+	})
+	stats.BatchesProcessed = completed
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			fmt.Printf("[WRITE_EMBEDDING_GEN] Generation canceled after %d/%d batches; progress is saved\n", completed, totalBatches)
+		}
+		return stats, err
+	}
 
-		fmt.Printf("[WRITE_EMBEDDING_GEN] Completed batch %d/%d\n", batchNum, totalBatches)
+	// An IVFFlat index built over far fewer rows (e.g. before the first run) is rebuilt for the new count
+	if wes.indexType == config.IndexTypeIVFFlat {
+		if err := wes.ensureProductVectorIndex(); err != nil {
+			fmt.Printf("[WRITE_EMBEDDING_GEN] WARNING: Failed to rebuild vector index: %v\n", err)
+		}
 	}
 
-	fmt.Printf("[WRITE_EMBEDDING_GEN] ===== EMBEDDING GENERATION COMPLETE =====\n")
+	fmt.Printf("[WRITE_EMBEDDING_GEN] ===== EMBEDDING GENERATION COMPLETE (%d CHANGED, %d DELETED) =====\n", stats.ChangedProducts, stats.DeletedProducts)
 	stats.Success = true
 	return stats, nil
 }
@@ -330,17 +546,93 @@ func (wes *WriteEmbeddingService) GenerateSingleProductEmbedding(productID int)
 	return fmt.Errorf("GenerateSingleProductEmbedding not yet implemented for dual-database setup")
 }
 
+// runBatches calls process for each batch of products on a pool of up to concurrency workers
+// The first failing batch cancels the context passed to in-flight batches and no further
+// batches start; every batch error is returned, joined. Returns the number of completed batches;
+// a canceled ctx returns its error without starting another batch.
+func runBatches(ctx context.Context, products []models.Product, batchSize, concurrency int, process func(context.Context, []models.Product) error) (int, error) {
+	totalBatches := (len(products) + batchSize - 1) / batchSize
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(concurrency, 1))
+
+	var (
+		mu        sync.Mutex
+		started   int
+		completed int
+		batchErrs []error
+	)
+
+	for i := 0; i < len(products); i += batchSize {
+		// A failed batch cancels groupCtx, so this also stops scheduling after an error
+		if groupCtx.Err() != nil {
+			break
+		}
+
+		end := i + batchSize
+		if end > len(products) {
+			end = len(products)
+		}
+
+		start := i
+		batchNum := (i / batchSize) + 1
+		group.Go(func() error {
+			// Go may have waited for a free worker; don't start once the run is canceled
+			if groupCtx.Err() != nil {
+				return nil
+			}
+			mu.Lock()
+			started++
+			mu.Unlock()
+
+			fmt.Printf("[WRITE_EMBEDDING_GEN] Processing batch %d/%d (products %d-%d)...\n", batchNum, totalBatches, start+1, end)
+
+			if err := process(groupCtx, products[start:end]); err != nil {
+				fmt.Printf("[WRITE_EMBEDDING_GEN] ERROR: Failed to process batch %d-%d: %v\n", start, end, err)
+				err = fmt.Errorf("failed to process batch %d-%d: %w", start, end, err)
+				mu.Lock()
+				batchErrs = append(batchErrs, err)
+				mu.Unlock()
+				return err
+			}
+
+			mu.Lock()
+			completed++
+			mu.Unlock()
+			fmt.Printf("[WRITE_EMBEDDING_GEN] Completed batch %d/%d\n", batchNum, totalBatches)
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	// Batch errors don't wrap ctx.Err(), so report a shutdown explicitly
+	if err := ctx.Err(); err != nil {
+		batchErrs = append(batchErrs, fmt.Errorf("embedding generation stopped after %d/%d batches (%d started): %w", completed, totalBatches, started, err))
+	}
+	if len(batchErrs) > 0 {
+		return completed, errors.Join(batchErrs...)
+	}
+
+	return completed, nil
+}
+
 // processBatch processes a batch of products and generates embeddings
-func (wes *WriteEmbeddingService) processBatch(products []models.Product) error {
+func (wes *WriteEmbeddingService) processBatch(ctx context.Context, products []models.Product) error {
 	return processBatchCommon(
+		ctx,
 		products,
 		wes.client,
-		wes.buildProductText,
+		wes.documentText,
 		wes.storeEmbedding,
 		"WRITE_EMBEDDING_GEN",
 	)
 }
 
+// documentText returns the embedding input for a product, including the document prefix
+func (wes *WriteEmbeddingService) documentText(product models.Product) string {
+	return idsopenai.WithInputPrefix(wes.documentPrefix, wes.buildProductText(product))
+}
+
 // buildProductText creates a comprehensive text representation of a product
 func (wes *WriteEmbeddingService) buildProductText(product models.Product) string {
 	var parts []string
@@ -350,14 +642,13 @@ func (wes *WriteEmbeddingService) buildProductText(product models.Product) strin
 		parts = append(parts, product.PostTitle)
 	}
 
-	// Add description
-	if product.Description != nil && *product.Description != "" {
+	// Add the descriptions selected by EMBEDDING_DESCRIPTION_FIELDS
+	includeFull, includeShort := descriptionFields(wes.descriptionFields)
+	if includeFull && product.Description != nil && *product.Description != "" {
 		desc := cleanHTMLDescription(*product.Description)
 		parts = append(parts, desc)
 	}
-
-	// Add short description
-	if product.ShortDescription != nil && *product.ShortDescription != "" {
+	if includeShort && product.ShortDescription != nil && *product.ShortDescription != "" {
 		parts = append(parts, *product.ShortDescription)
 	}
 
@@ -385,52 +676,19 @@ func (wes *WriteEmbeddingService) buildProductText(product models.Product) strin
 		parts = append(parts, "Stock: "+*product.StockStatus)
 	}
 
-	// Also, let's check if we can include the "Recover" tag if it's missing but in the title.
-	if strings.Contains(product.PostTitle, "Recover") && (product.Tags == nil || !strings.Contains(*product.Tags, "Recover")) {
-		parts = append(parts, "Brand: Recover Tactical")
-	}
-
-	// Fetch variations if it's a variable product
-	// We need access to DB here, but buildProductText is a method on WriteEmbeddingService which has db access
-	// However, the current signature doesn't allow easy DB access inside the loop without N+1 queries.
-	// For now, let's just rely on the fact that we might need to fetch variations in the main query.
-	// But changing the main query is complex.
-	// Let's try to append "Recover Tactical P-IX+" explicitly if it's in the title, to boost it.
-	// Actually, the issue is likely that the user query "Recover Tactical P-IX+" matches the title "AR Platform Conversion Kit... Recover Tactical P-IX+"
-	// but the similarity is low because the query is short and the title/desc is long and generic.
-	// Let's try to boost the title importance by repeating it or putting it at the end.
-
-	// Also, let's check if we can include the "Recover" tag if it's missing but in the title.
-	if strings.Contains(product.PostTitle, "Recover") && (product.Tags == nil || !strings.Contains(*product.Tags, "Recover")) {
-		parts = append(parts, "Brand: Recover Tactical")
-	}
-
-	// Fetch variations for this product to get more specific keywords
-	// This is an N+1 query but it's only during embedding generation which is a background process
-	// TODO: Temporarily disabled - needs to use readDB for querying remote MySQL
-	// Code removed to fix linter warning about nil slice range
-
-	// Force boost for P-IX by adding explicit keywords from the query that failed
-	// The user query was: "AR Platform Conversion Kit For Glock - Recover Tactical P-IX+"
-	// The product title is: "AR Platform Conversion Kit For Glock Pistols, Sig P365, Springfield Hellcat Pro, Ramon, IWI Masada - Recover Tactical P-IX+"
-	// It seems the title is very long and might be diluting the match.
-	// Let's repeat the core product name to increase its weight.
-	if strings.Contains(product.PostTitle, "P-IX+") {
-		parts = append(parts, "Recover Tactical P-IX+")
-		parts = append(parts, "Recover Tactical P-IX+")
-		parts = append(parts, "AR Platform Conversion Kit")
-	}
+	// Add configured keywords for matching titles (PRODUCT_KEYWORD_RULES_FILE)
+	parts = append(parts, matchKeywordRules(product.PostTitle, wes.keywordRules)...)
 
-	text := strings.Join(parts, " | ")
-	if product.ID == 13925 {
-		fmt.Printf("[DEBUG] Product 13925 Text: %s\n", text)
-	}
-	return text
+	return strings.Join(parts, " | ")
 }
 
 // storeEmbedding stores a product embedding with metadata in PostgreSQL using pgvector
 // Also writes to Qdrant if dual-write is enabled
-func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embedding []float64) error {
+func (wes *WriteEmbeddingService) storeEmbedding(ctx context.Context, product models.Product, embedding []float64) error {
+	if wes.dimensions > 0 && len(embedding) != wes.dimensions {
+		return fmt.Errorf("embedding for product %d has %d dimensions, expected %d", product.ID, len(embedding), wes.dimensions)
+	}
+
 	// Convert embedding to pgvector format
 	embeddingStr := FormatVectorForPgvector(embedding)
 
@@ -440,10 +698,10 @@ func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embeddi
 		INSERT INTO product_embeddings (
 			product_id, embedding, 
 			post_title, post_name, description, short_description,
-			sku, min_price, max_price, stock_status, stock_quantity, tags,
+			sku, min_price, max_price, stock_status, stock_quantity, tags, published_at,
 			created_at, updated_at
 		)
-		VALUES ($1, $2::vector, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		VALUES ($1, $2::vector, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		ON CONFLICT (product_id) DO UPDATE SET
 			embedding = EXCLUDED.embedding,
 			post_title = EXCLUDED.post_title,
@@ -456,6 +714,7 @@ func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embeddi
 			stock_status = EXCLUDED.stock_status,
 			stock_quantity = EXCLUDED.stock_quantity,
 			tags = EXCLUDED.tags,
+			published_at = EXCLUDED.published_at,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
@@ -476,7 +735,12 @@ func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embeddi
 		stockQuantity = nil
 	}
 
-	_, err := wes.writeDB.ExecuteWriteQuery(query,
+	var publishedAt interface{}
+	if product.PublishedAt != nil {
+		publishedAt = *product.PublishedAt
+	}
+
+	_, err := wes.writeDB.ExecuteWriteQueryContext(ctx, query,
 		product.ID,
 		embeddingStr,
 		product.PostTitle,
@@ -489,6 +753,7 @@ func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embeddi
 		stockStatus,
 		stockQuantity,
 		tags,
+		publishedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to store embedding in PostgreSQL: %v", err)
@@ -514,8 +779,11 @@ func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embeddi
 			Description:      safeString(product.Description),
 			ShortDescription: safeString(product.ShortDescription),
 		}
+		if product.PublishedAt != nil {
+			payload.PublishedAt = product.PublishedAt.Unix()
+		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
 		if err := wes.qdrantClient.UpsertProduct(ctx, product.ID, embedding32, payload); err != nil {
@@ -527,6 +795,20 @@ func (wes *WriteEmbeddingService) storeEmbedding(product models.Product, embeddi
 	return nil
 }
 
+// SetProductDB swaps in a new read database connection, e.g. after it reconnects
+func (wes *WriteEmbeddingService) SetProductDB(db *sql.DB) {
+	wes.readMu.Lock()
+	defer wes.readMu.Unlock()
+	wes.readDB = db
+}
+
+// productDB returns the read database connection, nil while it is unavailable
+func (wes *WriteEmbeddingService) productDB() *sql.DB {
+	wes.readMu.RLock()
+	defer wes.readMu.RUnlock()
+	return wes.readDB
+}
+
 // safeString safely extracts string value from pointer, returning empty string if nil
 func safeString(ptr *string) string {
 	if ptr == nil {
@@ -543,82 +825,218 @@ func getStringValue(ptr *string) interface{} {
 	return *ptr
 }
 
-// CreateEmbeddingsTable creates the table for storing product embeddings with metadata
-func (wes *WriteEmbeddingService) CreateEmbeddingsTable() error {
-	// Enable pgvector extension first
-	if _, err := wes.writeDB.ExecuteWriteQuery(`CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
-		fmt.Printf("[EMBEDDING_SERVICE] Warning: Failed to create vector extension (may already exist): %v\n", err)
+// VectorDimensions returns the probed embedding size, defaulting to text-embedding-3-small's
+func (wes *WriteEmbeddingService) VectorDimensions() int {
+	if wes.dimensions > 0 {
+		return wes.dimensions
 	}
+	return vectordb.VectorDimensions
+}
 
-	// PostgreSQL table with product metadata denormalized for search performance
-	// Using vector(1536) for text-embedding-3-small embeddings
-	query := `
-		CREATE TABLE IF NOT EXISTS product_embeddings (
-			product_id INT PRIMARY KEY,
-			embedding vector(1536) NOT NULL,
-			post_title TEXT,
-			post_name TEXT,
-			description TEXT,
-			short_description TEXT,
-			sku TEXT,
-			min_price TEXT,
-			max_price TEXT,
-			stock_status TEXT,
-			stock_quantity NUMERIC,
-			tags TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`
+// detectedDimensions returns the embedding model's output size, probing it when the service
+// wasn't built by NewWriteEmbeddingService (which probes at startup)
+func (wes *WriteEmbeddingService) detectedDimensions() (int, error) {
+	if wes.dimensions > 0 {
+		return wes.dimensions, nil
+	}
+	if wes.client == nil {
+		return 0, fmt.Errorf("embedding model output size is unknown")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return wes.client.EmbeddingDimensions(ctx)
+}
 
-	if _, err := wes.writeDB.ExecuteWriteQuery(query); err != nil {
+// reconcileVectorDimensions compares an existing product_embeddings vector size with the one the
+// embedding model produces and applies EMBEDDING_DIMENSION_MISMATCH
+func (wes *WriteEmbeddingService) reconcileVectorDimensions() error {
+	stored, err := wes.storedVectorDimensions()
+	if err != nil {
+		return err
+	}
+	detected, err := wes.detectedDimensions()
+	if err != nil {
 		return err
 	}
 
-	// Create product checksums table to track changes
-	checksumQuery := `
-		CREATE TABLE IF NOT EXISTS product_checksums (
-			product_id INT PRIMARY KEY,
-			checksum TEXT NOT NULL,
-			last_checked TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(product_id)
-		)
-	`
-
-	if _, err := wes.writeDB.ExecuteWriteQuery(checksumQuery); err != nil {
+	recreate, err := dimensionMismatchAction(wes.onMismatch, stored, detected)
+	if err != nil || !recreate {
 		return err
 	}
 
-	// Create indexes separately (PostgreSQL syntax)
-	indexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_product_embeddings_product_id ON product_embeddings(product_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_product_embeddings_post_title ON product_embeddings(post_title) WHERE post_title IS NOT NULL`,
-		`CREATE INDEX IF NOT EXISTS idx_product_checksums_product_id ON product_checksums(product_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_product_checksums_last_checked ON product_checksums(last_checked)`,
-		// HNSW index for fast cosine similarity search with pgvector
-		// m=16: number of connections per layer (higher = better recall, more memory)
-		// ef_construction=100: size of dynamic candidate list for construction (higher = better index quality, slower build)
-		`CREATE INDEX IF NOT EXISTS idx_product_embeddings_hnsw ON product_embeddings USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 100)`,
+	fmt.Printf("[EMBEDDING_SERVICE] ⚠️  WARNING: Dropping product_embeddings (vector(%d)) to recreate it as vector(%d); ALL product embeddings will be regenerated\n",
+		stored, detected)
+
+	// Qdrant goes first: if it fails the tables are kept, so the next run retries both
+	if wes.qdrantClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := wes.qdrantClient.RecreateProductsCollection(ctx); err != nil {
+			return err
+		}
 	}
-	for _, indexQuery := range indexes {
-		if _, err := wes.writeDB.ExecuteWriteQuery(indexQuery); err != nil {
-			// Log but don't fail on index creation errors
-			fmt.Printf("[EMBEDDING_SERVICE] Warning: Failed to create index: %v\n", err)
+
+	// Checksums go too, otherwise unchanged products would never be embedded again
+	return wes.writeDB.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+		for _, table := range []string{"product_embeddings", "product_checksums"} {
+			if _, err := tx.Exec(`DROP TABLE IF EXISTS ` + table); err != nil {
+				return fmt.Errorf("failed to drop %s: %w", table, err)
+			}
+		}
+		for _, statement := range database.ProductEmbeddingsSchema(detected) {
+			if _, err := tx.Exec(statement); err != nil {
+				return fmt.Errorf("failed to recreate product embedding tables: %w", err)
+			}
 		}
+		return nil
+	})
+}
+
+// dimensionMismatchAction decides what to do with a product_embeddings table of stored dimensions
+// (0 when it doesn't exist yet) for embeddings of detected dimensions
+func dimensionMismatchAction(mode string, stored, detected int) (recreate bool, err error) {
+	if stored == 0 || stored == detected {
+		return false, nil
+	}
+
+	switch mode {
+	case config.DimensionMismatchRecreate:
+		return true, nil
+	case config.DimensionMismatchWarn:
+		fmt.Printf("[EMBEDDING_SERVICE] ⚠️  WARNING: product_embeddings stores vector(%d) but the embedding model returns %d dimensions; storing embeddings will fail (EMBEDDING_DIMENSION_MISMATCH=warn)\n",
+			stored, detected)
+		return false, nil
+	default:
+		return false, fmt.Errorf("product_embeddings stores vector(%d) but the embedding model returns %d dimensions: set EMBEDDING_DIMENSION_MISMATCH=recreate to rebuild the table",
+			stored, detected)
+	}
+}
+
+// ivfflatIndexLists returns the lists an existing IVFFlat index was built with (0 when unknown)
+func ivfflatIndexLists(tx *sqlx.Tx, indexName string) (int, error) {
+	var options pq.StringArray
+	if err := tx.Get(&options, `SELECT COALESCE(reloptions, '{}') FROM pg_class WHERE oid = to_regclass($1)`, indexName); err != nil {
+		return 0, fmt.Errorf("failed to read %s options: %w", indexName, err)
+	}
+	for _, option := range options {
+		if value, ok := strings.CutPrefix(option, "lists="); ok {
+			return strconv.Atoi(value)
+		}
+	}
+	return 0, nil
+}
+
+// storedVectorDimensions returns the vector size of product_embeddings.embedding, or 0 when the table doesn't exist
+func (wes *WriteEmbeddingService) storedVectorDimensions() (int, error) {
+	table := "product_embeddings"
+	if schema := wes.writeDB.Schema(); schema != "" {
+		table = pq.QuoteIdentifier(schema) + "." + table
+	}
+
+	// pgvector keeps the declared dimensions in the column's type modifier
+	var dimensions []int
+	query := `SELECT atttypmod FROM pg_attribute WHERE attrelid = to_regclass($1) AND attname = 'embedding' AND NOT attisdropped`
+	if err := wes.writeDB.ExecuteWriteQueryWithResult(&dimensions, query, table); err != nil {
+		return 0, fmt.Errorf("failed to read product_embeddings vector size: %w", err)
+	}
+	if len(dimensions) == 0 || dimensions[0] < 0 {
+		return 0, nil
+	}
+	return dimensions[0], nil
+}
+
+// CreateEmbeddingsTable applies the schema migrations, which create the product embedding tables
+// sized for the embedding model, reconciles an existing table of another size and ensures the vector index
+func (wes *WriteEmbeddingService) CreateEmbeddingsTable() error {
+	if _, err := wes.writeDB.MigrateSchema(wes.VectorDimensions()); err != nil {
+		return err
+	}
+
+	if err := wes.reconcileVectorDimensions(); err != nil {
+		return err
+	}
+
+	// Vector index for fast similarity search under VECTOR_INDEX_TYPE and VECTOR_DISTANCE_METRIC
+	if err := wes.ensureProductVectorIndex(); err != nil {
+		fmt.Printf("[EMBEDDING_SERVICE] Warning: Failed to create vector index: %v\n", err)
 	}
 	return nil
 }
 
+// ensureProductVectorIndex creates the product_embeddings vector index of the configured type
+// (VECTOR_INDEX_TYPE), dropping the other type's index for the metric so a switch doesn't keep
+// both. An existing HNSW index is left alone; an existing IVFFlat index is rebuilt when its lists
+// drifted far from the ones the current row count needs (e.g. it was built on an empty table).
+func (wes *WriteEmbeddingService) ensureProductVectorIndex() error {
+	indexName := vectordb.HNSWIndexName("product_embeddings", wes.distanceMetric)
+	otherName := vectordb.IVFFlatIndexName("product_embeddings", wes.distanceMetric)
+	if wes.indexType == config.IndexTypeIVFFlat {
+		indexName, otherName = otherName, indexName
+	}
+
+	return wes.writeDB.WithSchemaTransaction(func(tx *sqlx.Tx) error {
+		var exists bool
+		if err := tx.Get(&exists, `SELECT to_regclass($1) IS NOT NULL`, indexName); err != nil {
+			return fmt.Errorf("failed to check %s: %w", indexName, err)
+		}
+		ivfflat := wes.indexType == config.IndexTypeIVFFlat
+		if exists && !ivfflat {
+			return nil
+		}
+
+		var rows int
+		if ivfflat {
+			if err := tx.Get(&rows, `SELECT COUNT(*) FROM product_embeddings`); err != nil {
+				return fmt.Errorf("failed to count product embeddings: %w", err)
+			}
+		}
+
+		if exists {
+			lists, err := ivfflatIndexLists(tx, indexName)
+			if err != nil {
+				return err
+			}
+			if !vectordb.IVFFlatListsDrifted(lists, rows) {
+				return nil
+			}
+			fmt.Printf("[EMBEDDING_SERVICE] Rebuilding %s: built with %d lists, %d embeddings need %d\n", indexName, lists, rows, vectordb.IVFFlatLists(rows))
+			if _, err := tx.Exec(`DROP INDEX IF EXISTS ` + indexName); err != nil {
+				return fmt.Errorf("failed to drop %s: %w", indexName, err)
+			}
+		} else if _, err := tx.Exec(`DROP INDEX IF EXISTS ` + otherName); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", otherName, err)
+		}
+
+		query := vectordb.HNSWIndexQuery("product_embeddings", wes.distanceMetric)
+		if ivfflat {
+			lists := vectordb.IVFFlatLists(rows)
+			fmt.Printf("[EMBEDDING_SERVICE] Building %s with %d lists for %d embeddings\n", indexName, lists, rows)
+			query = vectordb.IVFFlatIndexQuery("product_embeddings", wes.distanceMetric, lists)
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create %s: %w", indexName, err)
+		}
+		return nil
+	})
+}
+
 // SearchSimilarProducts finds products similar to the query using pgvector similarity
-func (wes *WriteEmbeddingService) SearchSimilarProducts(query string, limit int) ([]ProductEmbedding, error) {
+// Canceling ctx (e.g. the client disconnecting) aborts the embedding call and the database query
+func (wes *WriteEmbeddingService) SearchSimilarProducts(ctx context.Context, query string, limit int) ([]ProductEmbedding, error) {
+	// Normalize so equivalent queries produce the same embedding
+	query, ok := normalizeSearchQuery(query, "WRITE_VECTOR_SEARCH")
+	if !ok {
+		return []ProductEmbedding{}, nil
+	}
 	fmt.Printf("[WRITE_VECTOR_SEARCH] Starting pgvector search for query: '%s' with limit: %d\n", query, limit)
 
 	// Generate embedding for the query using unified client
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	fmt.Printf("[WRITE_VECTOR_SEARCH] Generating query embedding via %s...\n", wes.client.GetProviderName())
-	embeddings, err := wes.client.CreateEmbeddings(ctx, []string{query})
+	embeddings, err := wes.client.CreateEmbeddings(ctx, []string{idsopenai.WithInputPrefix(wes.queryPrefix, query)})
 	if err != nil {
 		fmt.Printf("[WRITE_VECTOR_SEARCH] ERROR: Failed to generate query embedding: %v\n", err)
 		return nil, fmt.Errorf("failed to generate query embedding: %v", err)
@@ -629,7 +1047,7 @@ func (wes *WriteEmbeddingService) SearchSimilarProducts(query string, limit int)
 	// Convert query embedding to pgvector format
 	queryVectorStr := FormatFloat32VectorForPgvector(embeddings[0])
 
-	// Use pgvector's <=> operator for cosine distance (database handles similarity calculation)
+	// Use pgvector's distance operator for VECTOR_DISTANCE_METRIC (database handles the distance calculation)
 	// Fetch more results than requested to allow for term-based filtering
 	fetchLimit := limit * 3
 	if fetchLimit < 50 {
@@ -638,7 +1056,7 @@ func (wes *WriteEmbeddingService) SearchSimilarProducts(query string, limit int)
 
 	fmt.Printf("[WRITE_VECTOR_SEARCH] Executing pgvector query with HNSW index...\n")
 
-	rows, err := wes.writeDB.GetDB().QueryContext(ctx, queryProductEmbeddingsPgvector, queryVectorStr, fetchLimit)
+	rows, err := queryProductCandidates(ctx, wes.writeDB, wes.searchMode, wes.distanceMetric, query, queryVectorStr, fetchLimit, wes.requireTitle, SearchFilters{}, wes.tuning, "WRITE_VECTOR_SEARCH")
 	if err != nil {
 		fmt.Printf("[WRITE_VECTOR_SEARCH] ERROR: Failed to execute pgvector query: %v\n", err)
 		return nil, fmt.Errorf("failed to execute pgvector query: %v", err)
@@ -649,7 +1067,7 @@ func (wes *WriteEmbeddingService) SearchSimilarProducts(query string, limit int)
 		}
 	}()
 
-	results := ScanProductEmbeddingRows(rows, "WRITE_VECTOR_SEARCH")
+	results := applyTitlePolicy(ScanProductEmbeddingRows(rows.Rows, wes.distanceMetric, "WRITE_VECTOR_SEARCH"), wes.requireTitle, "WRITE_VECTOR_SEARCH")
 
 	if err = rows.Err(); err != nil {
 		fmt.Printf("[WRITE_VECTOR_SEARCH] ERROR: Error iterating product embedding rows: %v\n", err)
@@ -671,10 +1089,15 @@ func (wes *WriteEmbeddingService) SearchSimilarProducts(query string, limit int)
 		}
 	}
 
-	// Apply term-based filtering for better relevance
-	queryTokens := utils.ExtractMeaningfulTokens(query)
-	queryTokens = wes.expandSynonyms(queryTokens)
-	applyTermBoostingPgvector(&results, query, queryTokens)
+	// Promoted products are picked by raw similarity, before boosting reorders results
+	promoted := wes.promotion.candidates(results)
+
+	// Apply term-based boosting for better relevance
+	wes.applyTermBoosting(&results, query)
+
+	// Drop weak matches so nonsense queries don't return loosely related products
+	filterByMinSimilarity(&results, wes.minSimilarity, "WRITE_VECTOR_SEARCH")
+	results = includePromoted(results, promoted, limit, "WRITE_VECTOR_SEARCH")
 
 	// Return top results
 	if limit > 0 && limit < len(results) {
@@ -686,11 +1109,26 @@ func (wes *WriteEmbeddingService) SearchSimilarProducts(query string, limit int)
 	return results, nil
 }
 
+// applyTermBoosting boosts results by keyword and tag matches unless ENABLE_TERM_BOOSTING is off
+func (wes *WriteEmbeddingService) applyTermBoosting(results *[]ProductEmbedding, query string) {
+	if !wes.termBoosting {
+		fmt.Printf("[WRITE_VECTOR_SEARCH] Term boosting disabled, keeping pgvector order\n")
+		return
+	}
+
+	queryTokens := utils.ExtractMeaningfulTokens(query)
+	queryTokens = wes.expandSynonyms(queryTokens)
+	applyTermBoostingPgvector(results, query, queryTokens, wes.weights)
+}
+
 // applyTermBoostingPgvector applies term-based boosting to pgvector results
-func applyTermBoostingPgvector(results *[]ProductEmbedding, query string, queryTokens []string) {
+func applyTermBoostingPgvector(results *[]ProductEmbedding, query string, queryTokens []string, weights scoreWeights) {
 	for i := range *results {
-		boost := calculateBoost((*results)[i].Product, query, queryTokens)
-		(*results)[i].Similarity += boost
+		keywordScore := calculateBoost((*results)[i].Product, query, queryTokens)
+		if matchesTagPhrase((*results)[i].Product, query) {
+			keywordScore += weights.TagPhrase
+		}
+		(*results)[i].Similarity = combinedScore((*results)[i].Similarity, keywordScore, weights)
 	}
 
 	// Re-sort after boosting
@@ -733,6 +1171,11 @@ func convertNullableFieldsToProduct(
 	return product
 }
 
+// combinedScore computes the final search score as alpha*similarity + beta*keywordScore
+func combinedScore(similarity, keywordScore float64, weights scoreWeights) float64 {
+	return weights.Similarity*similarity + weights.Keyword*keywordScore
+}
+
 // calculateBoost calculates the boost value based on term matching
 func calculateBoost(product models.Product, query string, queryTokens []string) float64 {
 	boost := 0.0
@@ -769,6 +1212,27 @@ func calculateBoost(product models.Product, query string, queryTokens []string)
 	return boost
 }
 
+// matchesTagPhrase reports whether a whole tag (comma-separated) appears as a phrase in the query
+// Single-word tags only count when they equal the whole query; token matching already covers them
+func matchesTagPhrase(product models.Product, query string) bool {
+	if product.Tags == nil {
+		return false
+	}
+
+	normalizedQuery := " " + strings.Join(strings.Fields(strings.ToLower(query)), " ") + " "
+	for _, tag := range strings.Split(*product.Tags, ",") {
+		words := strings.Fields(strings.ToLower(tag))
+		if len(words) == 0 {
+			continue
+		}
+		phrase := " " + strings.Join(words, " ") + " "
+		if phrase == normalizedQuery || (len(words) > 1 && strings.Contains(normalizedQuery, phrase)) {
+			return true
+		}
+	}
+	return false
+}
+
 // cleanHTMLDescription cleans HTML tags from a description string and limits its length
 func cleanHTMLDescription(desc string) string {
 	// Clean HTML tags and limit length
@@ -797,18 +1261,10 @@ func sortBySimilarity(results []ProductEmbedding) {
 	}
 }
 
-// expandSynonyms adds synonyms to the token list
-func (wes *WriteEmbeddingService) expandSynonyms(tokens []string) []string {
-	synonyms := map[string][]string{
-		"dubon":   {"doobon", "parka", "coat"},
-		"doobon":  {"dubon", "parka", "coat"},
-		"coat":    {"jacket", "parka"},
-		"jacket":  {"coat", "parka"},
-		"recover": {"recovertactical"},
-		"p-ix":    {"pix", "p-ix+"},
-		"pix":     {"p-ix", "p-ix+"},
-	}
-
+// expandSynonymsCapped adds up to perToken synonyms for each token and at most total synonyms overall
+// Original tokens are always kept; synonyms are taken in map-value order so capping is deterministic
+// A cap of 0 or less means unlimited
+func expandSynonymsCapped(tokens []string, synonyms map[string][]string, perToken, total int) []string {
 	var expanded []string
 	seen := make(map[string]struct{})
 
@@ -817,13 +1273,23 @@ func (wes *WriteEmbeddingService) expandSynonyms(tokens []string) []string {
 			expanded = append(expanded, token)
 			seen[token] = struct{}{}
 		}
+	}
 
-		if syns, ok := synonyms[token]; ok {
-			for _, syn := range syns {
-				if _, ok := seen[syn]; !ok {
-					expanded = append(expanded, syn)
-					seen[syn] = struct{}{}
-				}
+	added := 0
+	for _, token := range tokens {
+		addedForToken := 0
+		for _, syn := range synonyms[strings.ToLower(token)] {
+			if total > 0 && added >= total {
+				return expanded
+			}
+			if perToken > 0 && addedForToken >= perToken {
+				break
+			}
+			if _, ok := seen[syn]; !ok {
+				expanded = append(expanded, syn)
+				seen[syn] = struct{}{}
+				added++
+				addedForToken++
 			}
 		}
 	}
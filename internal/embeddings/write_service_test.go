@@ -0,0 +1,640 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombinedScore(t *testing.T) {
+	tests := []struct {
+		name         string
+		similarity   float64
+		keywordScore float64
+		weights      scoreWeights
+		expected     float64
+	}{
+		{name: "default weights add the boost", similarity: 0.6, keywordScore: 0.3, weights: defaultScoreWeights, expected: 0.9},
+		{name: "similarity only", similarity: 0.6, keywordScore: 0.3, weights: scoreWeights{Similarity: 1, Keyword: 0}, expected: 0.6},
+		{name: "keyword only", similarity: 0.6, keywordScore: 0.3, weights: scoreWeights{Similarity: 0, Keyword: 1}, expected: 0.3},
+		{name: "blended", similarity: 0.6, keywordScore: 0.3, weights: scoreWeights{Similarity: 0.7, Keyword: 0.3}, expected: 0.51},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, combinedScore(tt.similarity, tt.keywordScore, tt.weights), 1e-9)
+		})
+	}
+}
+
+func TestApplyTermBoostingPgvector_Weighting(t *testing.T) {
+	tags := "molle, plate carrier"
+	newResults := func() []ProductEmbedding {
+		return []ProductEmbedding{
+			{Product: models.Product{ID: 1, PostTitle: "Tactical Vest"}, Similarity: 0.80},
+			{Product: models.Product{ID: 2, PostTitle: "Plate Carrier", Tags: &tags}, Similarity: 0.70},
+		}
+	}
+	query := "plate carrier"
+	tokens := []string{"plate", "carrier"}
+
+	// Default weights: keyword match (capped at 0.3) lifts the plate carrier above the vest
+	results := newResults()
+	applyTermBoostingPgvector(&results, query, tokens, defaultScoreWeights)
+	assert.Equal(t, 2, results[0].Product.ID)
+	assert.InDelta(t, 1.0, results[0].Similarity, 1e-9)
+	assert.InDelta(t, 0.8, results[1].Similarity, 1e-9)
+
+	// Similarity-only weights keep the pure vector ranking
+	results = newResults()
+	applyTermBoostingPgvector(&results, query, tokens, scoreWeights{Similarity: 1, Keyword: 0})
+	assert.Equal(t, 1, results[0].Product.ID)
+	assert.InDelta(t, 0.8, results[0].Similarity, 1e-9)
+	assert.InDelta(t, 0.7, results[1].Similarity, 1e-9)
+}
+
+func TestApplyTermBoosting_Disabled(t *testing.T) {
+	tags := "molle, plate carrier"
+	newResults := func() []ProductEmbedding {
+		return []ProductEmbedding{
+			{Product: models.Product{ID: 1, PostTitle: "Tactical Vest"}, Similarity: 0.80},
+			{Product: models.Product{ID: 2, PostTitle: "Plate Carrier", Tags: &tags}, Similarity: 0.70},
+		}
+	}
+
+	disabled := &WriteEmbeddingService{weights: defaultScoreWeights, termBoosting: false}
+	results := newResults()
+	disabled.applyTermBoosting(&results, "plate carrier")
+	assert.Equal(t, newResults(), results)
+
+	enabled := &WriteEmbeddingService{weights: defaultScoreWeights, termBoosting: true}
+	results = newResults()
+	enabled.applyTermBoosting(&results, "plate carrier")
+	assert.Equal(t, 2, results[0].Product.ID)
+	assert.Greater(t, results[0].Similarity, 0.70)
+}
+
+func TestMatchesTagPhrase(t *testing.T) {
+	tags := func(value string) models.Product {
+		return models.Product{PostTitle: "Holster", Tags: &value}
+	}
+
+	assert.True(t, matchesTagPhrase(tags("glock, Right Hand, owb"), "right hand"))
+	assert.True(t, matchesTagPhrase(tags("glock, Right Hand, owb"), "glock 19 holster right  hand"))
+	assert.True(t, matchesTagPhrase(tags("glock, owb"), "Glock"))
+	assert.False(t, matchesTagPhrase(tags("glock, owb"), "glock holster"))
+	assert.False(t, matchesTagPhrase(tags("right side, hand gun"), "right hand"))
+	assert.False(t, matchesTagPhrase(tags("Right Handed"), "right hand"))
+	assert.False(t, matchesTagPhrase(models.Product{PostTitle: "Holster"}, "right hand"))
+}
+
+func TestApplyTermBoostingPgvector_TagPhraseOutranksScatteredTokens(t *testing.T) {
+	exact := "glock, Right Hand"
+	scattered := "right side, hand gun, glock"
+	results := []ProductEmbedding{
+		{Product: models.Product{ID: 1, PostTitle: "OWB Holster", Tags: &scattered}, Similarity: 0.72},
+		{Product: models.Product{ID: 2, PostTitle: "IWB Holster", Tags: &exact}, Similarity: 0.70},
+	}
+	weights := scoreWeights{Similarity: 1, Keyword: 1, TagPhrase: 0.1}
+
+	applyTermBoostingPgvector(&results, "right hand", []string{"right", "hand"}, weights)
+
+	assert.Equal(t, 2, results[0].Product.ID)
+	assert.InDelta(t, 1.10, results[0].Similarity, 1e-9) // 0.70 + 0.3 capped tokens + 0.1 phrase
+	assert.InDelta(t, 1.02, results[1].Similarity, 1e-9) // 0.72 + 0.3 capped tokens
+}
+
+func TestExpandSynonymsCapped(t *testing.T) {
+	synonyms := map[string][]string{
+		"vest":   {"carrier", "rig", "chest", "plate", "armor", "harness"},
+		"pouch":  {"bag", "case", "holder"},
+		"holder": {"pouch"},
+	}
+
+	t.Run("unlimited keeps original behaviour", func(t *testing.T) {
+		expanded := expandSynonymsCapped([]string{"vest"}, synonyms, 0, 0)
+		assert.Equal(t, []string{"vest", "carrier", "rig", "chest", "plate", "armor", "harness"}, expanded)
+	})
+
+	t.Run("per-token cap takes synonyms in order", func(t *testing.T) {
+		expanded := expandSynonymsCapped([]string{"vest", "pouch"}, synonyms, 2, 0)
+		assert.Equal(t, []string{"vest", "pouch", "carrier", "rig", "bag", "case"}, expanded)
+	})
+
+	t.Run("total cap bounds the expanded set", func(t *testing.T) {
+		expanded := expandSynonymsCapped([]string{"vest", "pouch"}, synonyms, 5, 4)
+		assert.Equal(t, []string{"vest", "pouch", "carrier", "rig", "chest", "plate"}, expanded)
+	})
+
+	t.Run("synonyms already in the query don't count toward the caps", func(t *testing.T) {
+		expanded := expandSynonymsCapped([]string{"holder", "pouch"}, synonyms, 1, 1)
+		assert.Equal(t, []string{"holder", "pouch", "bag"}, expanded)
+	})
+
+	t.Run("deterministic across runs", func(t *testing.T) {
+		first := expandSynonymsCapped([]string{"vest", "pouch"}, synonyms, 3, 5)
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, first, expandSynonymsCapped([]string{"vest", "pouch"}, synonyms, 3, 5))
+		}
+	})
+}
+
+func TestRunBatches_CancellationStopsFurtherBatches(t *testing.T) {
+	products := make([]models.Product, 25)
+	for i := range products {
+		products[i] = models.Product{ID: i + 1}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var processed [][]int
+	completed, err := runBatches(ctx, products, 10, 1, func(_ context.Context, batch []models.Product) error {
+		ids := make([]int, len(batch))
+		for i, p := range batch {
+			ids[i] = p.ID
+		}
+		processed = append(processed, ids)
+		// Shutdown arrives while the first batch is in flight; it still completes
+		cancel()
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, completed)
+	assert.Len(t, processed, 1)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, processed[0])
+}
+
+func TestRunBatches_ProcessesAllBatches(t *testing.T) {
+	products := make([]models.Product, 25)
+
+	var sizes []int
+	completed, err := runBatches(context.Background(), products, 10, 1, func(_ context.Context, batch []models.Product) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, completed)
+	assert.Equal(t, []int{10, 10, 5}, sizes)
+}
+
+func TestRunBatches_StopsOnBatchError(t *testing.T) {
+	products := make([]models.Product, 25)
+	failure := errors.New("rate limited")
+
+	calls := 0
+	completed, err := runBatches(context.Background(), products, 10, 1, func(_ context.Context, batch []models.Product) error {
+		calls++
+		if calls == 2 {
+			return failure
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 1, completed)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRunBatches_BoundedConcurrency(t *testing.T) {
+	products := make([]models.Product, 100)
+
+	var inFlight, peak, processed atomic.Int32
+	completed, err := runBatches(context.Background(), products, 10, 3, func(_ context.Context, batch []models.Product) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		processed.Add(int32(len(batch)))
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 10, completed)
+	assert.Equal(t, int32(100), processed.Load())
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+	assert.Greater(t, peak.Load(), int32(1), "batches should overlap")
+}
+
+func TestRunBatches_ErrorCancelsRemainingAndAggregates(t *testing.T) {
+	products := make([]models.Product, 100)
+	for i := range products {
+		products[i] = models.Product{ID: i + 1}
+	}
+
+	var calls atomic.Int32
+	siblingStarted := make(chan struct{})
+	completed, err := runBatches(context.Background(), products, 10, 2, func(ctx context.Context, batch []models.Product) error {
+		calls.Add(1)
+		switch batch[0].ID {
+		case 1:
+			// Fails once its sibling is in flight, canceling the sibling
+			<-siblingStarted
+			return errors.New("rate limited")
+		case 11:
+			close(siblingStarted)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to process batch 0-10: rate limited")
+	assert.Contains(t, err.Error(), "failed to process batch 10-20")
+	assert.ErrorIs(t, err, context.Canceled, "the sibling's cancellation is aggregated too")
+	assert.Equal(t, 0, completed)
+	assert.Equal(t, int32(2), calls.Load(), "no batches start after a failure")
+}
+
+func TestRunBatches_CompletedCountsOnlySuccessfulBatches(t *testing.T) {
+	products := make([]models.Product, 50)
+	for i := range products {
+		products[i] = models.Product{ID: i + 1}
+	}
+
+	var mu sync.Mutex
+	var succeeded []int
+	completed, err := runBatches(context.Background(), products, 10, 5, func(_ context.Context, batch []models.Product) error {
+		if batch[0].ID == 21 {
+			return errors.New("insert failed")
+		}
+		mu.Lock()
+		succeeded = append(succeeded, batch[0].ID)
+		mu.Unlock()
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, len(succeeded), completed)
+	assert.NotContains(t, succeeded, 21)
+}
+
+func TestCreateEmbeddingsTable_TargetsConfiguredSchema(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{
+		writeDB:    database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"), "tenant_a"),
+		dimensions: 3072,
+	}
+	expectSearchPath := func() {
+		mock.ExpectExec(`SET LOCAL search_path TO "tenant_a", public`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	mock.MatchExpectationsInOrder(true)
+	mock.ExpectExec(`CREATE EXTENSION IF NOT EXISTS vector SCHEMA public`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	expectSearchPath()
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT version FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+
+	// A fresh schema: the baseline creates every table, sized for the probed embedding model
+	migrations := database.SchemaMigrations(3072)
+	require.Contains(t, migrations[0].Statements[0], "embedding vector(3072) NOT NULL")
+	mock.ExpectBegin()
+	expectSearchPath()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WithArgs("product_embeddings").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	for _, statement := range migrations[0].Statements {
+		mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(0, migrations[0].Description).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The later migrations find the new tables and are recorded as no-ops
+	for _, migration := range migrations[1:] {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WithArgs(migration.Table).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectBegin()
+		expectSearchPath()
+		for _, statement := range migration.Statements {
+			mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(migration.Version, migration.Description).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	mock.ExpectQuery(`SELECT atttypmod FROM pg_attribute WHERE attrelid = to_regclass\(\$1\)`).
+		WithArgs(`"tenant_a".product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"atttypmod"}).AddRow(3072))
+	mock.ExpectBegin()
+	expectSearchPath()
+	mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).WithArgs("idx_product_embeddings_hnsw").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`DROP INDEX IF EXISTS idx_product_embeddings_ivfflat`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_product_embeddings_hnsw`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, wes.CreateEmbeddingsTable())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureProductVectorIndex_SwitchesToIVFFlat(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{
+		writeDB:        database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")),
+		distanceMetric: config.DistanceMetricL2,
+		indexType:      config.IndexTypeIVFFlat,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).WithArgs("idx_product_embeddings_ivfflat_l2").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(48000))
+	mock.ExpectExec(`DROP INDEX IF EXISTS idx_product_embeddings_hnsw_l2`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS idx_product_embeddings_ivfflat_l2 ON product_embeddings USING ivfflat (embedding vector_l2_ops) WITH (lists = 48)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, wes.ensureProductVectorIndex())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureProductVectorIndex_KeepsExistingIndex(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{
+		writeDB:   database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")),
+		indexType: config.IndexTypeIVFFlat,
+	}
+
+	// Its lists still suit the row count: neither dropped nor rebuilt, so repeated runs keep the index
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).WithArgs("idx_product_embeddings_ivfflat").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(50000))
+	mock.ExpectQuery(`SELECT COALESCE\(reloptions, '\{\}'\) FROM pg_class`).WithArgs("idx_product_embeddings_ivfflat").
+		WillReturnRows(sqlmock.NewRows([]string{"reloptions"}).AddRow("{lists=48}"))
+	mock.ExpectCommit()
+
+	require.NoError(t, wes.ensureProductVectorIndex())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureProductVectorIndex_RebuildsIVFFlatBuiltOnEmptyTable(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{
+		writeDB:   database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")),
+		indexType: config.IndexTypeIVFFlat,
+	}
+
+	// Built with lists = 1 before the first generation filled the table
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).WithArgs("idx_product_embeddings_ivfflat").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(48000))
+	mock.ExpectQuery(`FROM pg_class`).WithArgs("idx_product_embeddings_ivfflat").
+		WillReturnRows(sqlmock.NewRows([]string{"reloptions"}).AddRow("{lists=1}"))
+	mock.ExpectExec(`DROP INDEX IF EXISTS idx_product_embeddings_ivfflat`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`USING ivfflat (embedding vector_cosine_ops) WITH (lists = 48)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, wes.ensureProductVectorIndex())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDimensionMismatchAction(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		stored       int
+		wantRecreate bool
+		wantErr      bool
+	}{
+		{name: "no table yet", mode: config.DimensionMismatchFail, stored: 0},
+		{name: "matching table", mode: config.DimensionMismatchFail, stored: 1536},
+		{name: "fail aborts", mode: config.DimensionMismatchFail, stored: 3072, wantErr: true},
+		{name: "warn proceeds", mode: config.DimensionMismatchWarn, stored: 3072},
+		{name: "recreate drops", mode: config.DimensionMismatchRecreate, stored: 3072, wantRecreate: true},
+		{name: "recreate keeps matching table", mode: config.DimensionMismatchRecreate, stored: 1536},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recreate, err := dimensionMismatchAction(tt.mode, tt.stored, 1536)
+			assert.Equal(t, tt.wantRecreate, recreate)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "vector(3072)")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReconcileVectorDimensions(t *testing.T) {
+	storedRows := func(dimensions int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"atttypmod"}).AddRow(dimensions)
+	}
+
+	t.Run("recreate rebuilds embeddings and checksums", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = mockDB.Close() }()
+		wes := &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")), dimensions: 1536, onMismatch: config.DimensionMismatchRecreate}
+
+		mock.ExpectQuery(`FROM pg_attribute`).WithArgs("product_embeddings").WillReturnRows(storedRows(3072))
+		mock.ExpectBegin()
+		mock.ExpectExec(`DROP TABLE IF EXISTS product_embeddings`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DROP TABLE IF EXISTS product_checksums`).WillReturnResult(sqlmock.NewResult(0, 0))
+		// Recreated in the same transaction with every migrated column and index
+		schema := database.ProductEmbeddingsSchema(1536)
+		require.Contains(t, schema[0], "embedding vector(1536) NOT NULL")
+		for _, statement := range schema {
+			mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectCommit()
+
+		require.NoError(t, wes.reconcileVectorDimensions())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed checksum drop keeps embeddings", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = mockDB.Close() }()
+		wes := &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")), dimensions: 1536, onMismatch: config.DimensionMismatchRecreate}
+
+		mock.ExpectQuery(`FROM pg_attribute`).WillReturnRows(storedRows(3072))
+		mock.ExpectBegin()
+		mock.ExpectExec(`DROP TABLE IF EXISTS product_embeddings`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DROP TABLE IF EXISTS product_checksums`).WillReturnError(errors.New("lock timeout"))
+		mock.ExpectRollback()
+
+		assert.ErrorContains(t, wes.reconcileVectorDimensions(), "failed to drop product_checksums")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("compares against the detected size", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = mockDB.Close() }()
+		wes := &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")), dimensions: 3072, onMismatch: config.DimensionMismatchRecreate}
+
+		// A 3072-dimension model matches the stored vector(3072), even though the fallback size is 1536
+		mock.ExpectQuery(`FROM pg_attribute`).WillReturnRows(storedRows(3072))
+
+		require.NoError(t, wes.reconcileVectorDimensions())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	for _, mode := range []string{config.DimensionMismatchFail, config.DimensionMismatchWarn} {
+		t.Run(mode+" keeps the table", func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			wes := &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")), dimensions: 1536, onMismatch: mode}
+
+			// No DROP is expected; sqlmock fails any unexpected statement
+			mock.ExpectQuery(`FROM pg_attribute`).WillReturnRows(storedRows(3072))
+
+			err = wes.reconcileVectorDimensions()
+			assert.Equal(t, mode == config.DimensionMismatchFail, err != nil)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStaleProductIDs(t *testing.T) {
+	products := []models.Product{{ID: 1}, {ID: 3}}
+	assert.Equal(t, []int64{2, 4}, staleProductIDs([]int64{1, 2, 3, 4}, products))
+	assert.Empty(t, staleProductIDs([]int64{1, 3}, products))
+}
+
+func TestDeleteStaleEmbeddings(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))}
+
+	mock.ExpectQuery(`SELECT product_id FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(1).AddRow(2).AddRow(3))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM product_embeddings WHERE product_id = ANY\(\$1\)`).
+		WithArgs("{2}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM product_checksums WHERE product_id = ANY\(\$1\)`).
+		WithArgs("{2}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deleted, err := wes.deleteStaleEmbeddings(context.Background(), []models.Product{{ID: 1}, {ID: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteStaleEmbeddings_NothingStale(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	wes := &WriteEmbeddingService{writeDB: database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres"))}
+
+	mock.ExpectQuery(`SELECT product_id FROM product_embeddings`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(1))
+
+	deleted, err := wes.deleteStaleEmbeddings(context.Background(), []models.Product{{ID: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteEmbeddingService_DocumentPrefix(t *testing.T) {
+	product := keywordTestProduct()
+
+	plain := &WriteEmbeddingService{queryPrefix: "query:"}
+	assert.Equal(t, plain.buildProductText(product), plain.documentText(product))
+
+	prefixed := &WriteEmbeddingService{documentPrefix: "passage:", queryPrefix: "query:"}
+	assert.Equal(t, "passage: "+prefixed.buildProductText(product), prefixed.documentText(product))
+
+	// Changing the document prefix must invalidate stored checksums so products are re-embedded
+	assert.NotEqual(t, plain.calculateProductChecksum(product), prefixed.calculateProductChecksum(product))
+}
+
+func TestWriteEmbeddingService_DescriptionFields(t *testing.T) {
+	product := keywordTestProduct()
+	fullDesc := cleanHTMLDescription(*product.Description)
+	rest := " | Tags: conversion kit, glock | SKU: PIX-BLK | Price: $199.00 - $249.00 | Stock: instock"
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{mode: "", expected: product.PostTitle + " | " + fullDesc + " | Conversion kit" + rest},
+		{mode: config.DescriptionFieldsBoth, expected: product.PostTitle + " | " + fullDesc + " | Conversion kit" + rest},
+		{mode: config.DescriptionFieldsFull, expected: product.PostTitle + " | " + fullDesc + rest},
+		{mode: config.DescriptionFieldsShort, expected: product.PostTitle + " | Conversion kit" + rest},
+	}
+
+	checksums := make(map[string]string)
+	for _, tt := range tests {
+		wes := &WriteEmbeddingService{descriptionFields: tt.mode}
+		assert.Equal(t, tt.expected, wes.buildProductText(product), "mode %q", tt.mode)
+		assert.Equal(t, tt.expected, (&EmbeddingService{descriptionFields: tt.mode}).buildProductText(product), "mode %q", tt.mode)
+		checksums[tt.mode] = wes.calculateProductChecksum(product)
+	}
+
+	// The default keeps existing checksums; each narrower setting changes them
+	assert.Equal(t, checksums[""], checksums[config.DescriptionFieldsBoth])
+	assert.NotEqual(t, checksums[config.DescriptionFieldsBoth], checksums[config.DescriptionFieldsFull])
+	assert.NotEqual(t, checksums[config.DescriptionFieldsBoth], checksums[config.DescriptionFieldsShort])
+	assert.NotEqual(t, checksums[config.DescriptionFieldsFull], checksums[config.DescriptionFieldsShort])
+
+	// Edits to a description that isn't embedded don't trigger re-embedding
+	edited := product
+	edited.ShortDescription = strPtr("Updated excerpt")
+	full := &WriteEmbeddingService{descriptionFields: config.DescriptionFieldsFull}
+	assert.Equal(t, full.calculateProductChecksum(product), full.calculateProductChecksum(edited))
+	both := &WriteEmbeddingService{}
+	assert.NotEqual(t, both.calculateProductChecksum(product), both.calculateProductChecksum(edited))
+}
+
+func TestWriteSearchSimilarProducts_EmptyQuery(t *testing.T) {
+	wes := &WriteEmbeddingService{}
+
+	for _, query := range []string{"", "   ", "\t\n\u200b"} {
+		results, err := wes.SearchSimilarProducts(context.Background(), query, 10)
+		require.NoError(t, err)
+		assert.NotNil(t, results)
+		assert.Empty(t, results)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"ids/internal/embeddings"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListMissingEmbeddingsHandler handles listing products that have no embedding yet
+// @Summary List products missing embeddings
+// @Description Get a paginated list of products without a product embedding, for targeting backfills
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Number of products per page" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} models.MissingEmbeddingsResponse
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/admin/embeddings/missing [get]
+func ListMissingEmbeddingsHandler(embeddingService *embeddings.EmbeddingService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Get pagination parameters
+		limit := 50 // default
+		offset := 0 // default
+
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 500 {
+				limit = parsed
+			}
+		}
+
+		if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+			if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+				offset = parsed
+			}
+		}
+
+		// Fetch one extra product to know whether another page exists
+		products, err := embeddingService.ListProductsMissingEmbeddings(limit+1, offset)
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to list products missing embeddings: %v", err))
+		}
+
+		hasMore := len(products) > limit
+		if hasMore {
+			products = products[:limit]
+		}
+
+		return c.JSON(http.StatusOK, models.MissingEmbeddingsResponse{
+			Products: products,
+			Limit:    limit,
+			Offset:   offset,
+			HasMore:  hasMore,
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"ids/internal/analytics"
+	"ids/internal/database"
+	"ids/internal/embeddings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// embeddingGenerator is the part of WriteEmbeddingService used for on-demand regeneration
+type embeddingGenerator interface {
+	GenerateProductEmbeddingsWithStats(ctx context.Context) (*embeddings.EmbeddingStats, error)
+}
+
+// RegenerateEmbeddingsResponse represents the response from triggering an embedding run
+type RegenerateEmbeddingsResponse struct {
+	JobID   string `json:"job_id"`
+	Message string `json:"message"`
+}
+
+// EmbeddingRunStats is the JSON view of the stats from the last finished run
+type EmbeddingRunStats struct {
+	TotalProducts    int    `json:"total_products"`
+	ChangedProducts  int    `json:"changed_products"`
+	DeletedProducts  int    `json:"deleted_products"`
+	BatchesProcessed int    `json:"batches_processed"`
+	Provider         string `json:"provider,omitempty"`
+	Model            string `json:"model,omitempty"`
+	Success          bool   `json:"success"`
+}
+
+// RegenerationStatus reports the current or most recent on-demand embedding run
+type RegenerationStatus struct {
+	Running    bool               `json:"running"`
+	JobID      string             `json:"job_id,omitempty"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	LastStats  *EmbeddingRunStats `json:"last_stats,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// EmbeddingRegenerationJob runs product embedding generation on demand, one run at a time
+type EmbeddingRegenerationJob struct {
+	generator        embeddingGenerator
+	analyticsService *analytics.Service
+
+	mu     sync.Mutex
+	status RegenerationStatus
+	done   chan struct{} // Closed when the current run finishes (used by tests)
+}
+
+// NewEmbeddingRegenerationJob creates a job runner around the write embedding service
+func NewEmbeddingRegenerationJob(generator embeddingGenerator, analyticsService *analytics.Service) *EmbeddingRegenerationJob {
+	return &EmbeddingRegenerationJob{
+		generator:        generator,
+		analyticsService: analyticsService,
+	}
+}
+
+// Start launches a run in the background and returns its job ID
+// When a run is already in progress it returns that run's ID and false
+func (j *EmbeddingRegenerationJob) Start() (string, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.status.Running {
+		return j.status.JobID, false
+	}
+
+	now := time.Now().UTC()
+	jobID := fmt.Sprintf("embeddings-%d", now.UnixMilli())
+	j.status = RegenerationStatus{
+		Running:   true,
+		JobID:     jobID,
+		StartedAt: &now,
+		LastStats: j.status.LastStats,
+	}
+	j.done = make(chan struct{})
+
+	go j.run(jobID, j.done)
+
+	return jobID, true
+}
+
+// Status returns a snapshot of the current or most recent run
+func (j *EmbeddingRegenerationJob) Status() RegenerationStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// run generates embeddings and records the result
+// It uses a background context so the run outlives the HTTP request that triggered it
+func (j *EmbeddingRegenerationJob) run(jobID string, done chan struct{}) {
+	defer close(done)
+	fmt.Printf("[EMBEDDING_REGEN] Starting on-demand embedding generation (job %s)\n", jobID)
+	j.track(jobID, "started", false)
+
+	start := time.Now()
+	stats, err := j.generator.GenerateProductEmbeddingsWithStats(context.Background())
+
+	finished := time.Now().UTC()
+	j.mu.Lock()
+	j.status.Running = false
+	j.status.FinishedAt = &finished
+	if stats != nil {
+		j.status.LastStats = &EmbeddingRunStats{
+			TotalProducts:    stats.TotalProducts,
+			ChangedProducts:  stats.ChangedProducts,
+			DeletedProducts:  stats.DeletedProducts,
+			BatchesProcessed: stats.BatchesProcessed,
+			Provider:         stats.Provider,
+			Model:            stats.Model,
+			Success:          stats.Success,
+		}
+	}
+	if err != nil {
+		j.status.Error = err.Error()
+	}
+	j.mu.Unlock()
+
+	success := err == nil && stats != nil && stats.Success
+	if err != nil {
+		fmt.Printf("[EMBEDDING_REGEN] ERROR: Job %s failed: %v\n", jobID, err)
+	} else {
+		fmt.Printf("[EMBEDDING_REGEN] Job %s finished in %v\n", jobID, time.Since(start).Round(time.Second))
+	}
+
+	j.track(jobID, "finished", success)
+	if j.analyticsService != nil && stats != nil {
+		// Keeps /api/admin/embeddings/freshness in step with on-demand runs
+		if err := j.analyticsService.TrackProductEmbeddings(stats.TotalProducts, stats.ChangedProducts, success); err != nil {
+			fmt.Printf("[EMBEDDING_REGEN] Warning: Failed to track product embeddings: %v\n", err)
+		}
+	}
+}
+
+// track records a start/finish event, ignoring a missing analytics service
+func (j *EmbeddingRegenerationJob) track(jobID, phase string, success bool) {
+	if j.analyticsService == nil {
+		return
+	}
+	if err := j.analyticsService.TrackEmbeddingRegeneration(jobID, phase, success); err != nil {
+		fmt.Printf("[EMBEDDING_REGEN] Warning: Failed to track %s event: %v\n", phase, err)
+	}
+}
+
+// RegenerateEmbeddingsHandler triggers product embedding regeneration
+// @Summary Regenerate product embeddings
+// @Description Starts an incremental product embedding run in the background and returns immediately with a job ID
+// @Tags admin
+// @Produce json
+// @Success 202 {object} RegenerateEmbeddingsResponse
+// @Failure 401 {object} models.APIError
+// @Failure 409 {object} RegenerateEmbeddingsResponse
+// @Failure 503 {object} models.APIError
+// @Router /api/admin/regenerate-embeddings [post]
+func RegenerateEmbeddingsHandler(job *EmbeddingRegenerationJob, readDB *database.ReadDB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Products are read from the product database, which may still be reconnecting
+		if readDB.Get() == nil {
+			fmt.Printf("[EMBEDDING_REGEN] ERROR: Database connection not available\n")
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "Database connection not available")
+		}
+
+		jobID, started := job.Start()
+		if !started {
+			return c.JSON(http.StatusConflict, RegenerateEmbeddingsResponse{
+				JobID:   jobID,
+				Message: "Embedding regeneration is already in progress",
+			})
+		}
+
+		return c.JSON(http.StatusAccepted, RegenerateEmbeddingsResponse{
+			JobID:   jobID,
+			Message: "Embedding regeneration started",
+		})
+	}
+}
+
+// RegenerateEmbeddingsStatusHandler reports the state of on-demand embedding regeneration
+// @Summary Get embedding regeneration status
+// @Description Reports whether an on-demand embedding run is in progress and the stats of the last finished run
+// @Tags admin
+// @Produce json
+// @Success 200 {object} RegenerationStatus
+// @Failure 401 {object} models.APIError
+// @Router /api/admin/regenerate-embeddings/status [get]
+func RegenerateEmbeddingsStatusHandler(job *EmbeddingRegenerationJob) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, job.Status())
+	}
+}
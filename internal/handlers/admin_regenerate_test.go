@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ids/internal/database"
+	"ids/internal/embeddings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGenerator blocks each run until release is closed, then returns its canned result
+type fakeGenerator struct {
+	release chan struct{}
+	stats   *embeddings.EmbeddingStats
+	err     error
+}
+
+func (f *fakeGenerator) GenerateProductEmbeddingsWithStats(_ context.Context) (*embeddings.EmbeddingStats, error) {
+	<-f.release
+	return f.stats, f.err
+}
+
+func serveRegenerate(t *testing.T, handler echo.HandlerFunc, method string) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(method, "/api/admin/regenerate-embeddings", nil), rec)
+	require.NoError(t, handler(c))
+	return rec
+}
+
+// connectedReadDB returns a ReadDB holding a (mock) product database connection
+func connectedReadDB(t *testing.T) *database.ReadDB {
+	t.Helper()
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	return database.NewReadDB(sqlx.NewDb(mockDB, "sqlmock"))
+}
+
+func TestRegenerateEmbeddings_AcceptsAndReportsStatus(t *testing.T) {
+	generator := &fakeGenerator{
+		release: make(chan struct{}),
+		stats:   &embeddings.EmbeddingStats{TotalProducts: 120, ChangedProducts: 12, BatchesProcessed: 1, Success: true},
+	}
+	job := NewEmbeddingRegenerationJob(generator, nil)
+	readDB := connectedReadDB(t)
+
+	rec := serveRegenerate(t, RegenerateEmbeddingsHandler(job, readDB), http.MethodPost)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var accepted RegenerateEmbeddingsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &accepted))
+	assert.NotEmpty(t, accepted.JobID)
+
+	// A second trigger while running is rejected with the running job's ID
+	rec = serveRegenerate(t, RegenerateEmbeddingsHandler(job, readDB), http.MethodPost)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	var conflict RegenerateEmbeddingsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conflict))
+	assert.Equal(t, accepted.JobID, conflict.JobID)
+
+	rec = serveRegenerate(t, RegenerateEmbeddingsStatusHandler(job), http.MethodGet)
+	var running RegenerationStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &running))
+	assert.True(t, running.Running)
+	assert.Equal(t, accepted.JobID, running.JobID)
+	assert.Nil(t, running.LastStats)
+
+	close(generator.release)
+	<-job.done
+
+	rec = serveRegenerate(t, RegenerateEmbeddingsStatusHandler(job), http.MethodGet)
+	var finished RegenerationStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &finished))
+	assert.False(t, finished.Running)
+	assert.NotNil(t, finished.FinishedAt)
+	require.NotNil(t, finished.LastStats)
+	assert.Equal(t, 12, finished.LastStats.ChangedProducts)
+	assert.True(t, finished.LastStats.Success)
+	assert.Empty(t, finished.Error)
+}
+
+func TestRegenerateEmbeddings_UnavailableWithoutProductDB(t *testing.T) {
+	job := NewEmbeddingRegenerationJob(&fakeGenerator{release: make(chan struct{})}, nil)
+
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/api/admin/regenerate-embeddings", nil), httptest.NewRecorder())
+	err := RegenerateEmbeddingsHandler(job, database.NewReadDB(nil))(c)
+
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	assert.False(t, job.Status().Running, "no run starts while the product database is down")
+}
+
+func TestRegenerateEmbeddings_RecordsFailure(t *testing.T) {
+	generator := &fakeGenerator{
+		release: make(chan struct{}),
+		stats:   &embeddings.EmbeddingStats{TotalProducts: 120, ChangedProducts: 12},
+		err:     errors.New("rate limited"),
+	}
+	close(generator.release)
+	job := NewEmbeddingRegenerationJob(generator, nil)
+
+	_, started := job.Start()
+	require.True(t, started)
+	<-job.done
+
+	status := job.Status()
+	assert.False(t, status.Running)
+	assert.Equal(t, "rate limited", status.Error)
+	require.NotNil(t, status.LastStats)
+	assert.False(t, status.LastStats.Success)
+
+	// The next run can start once the failed one finished
+	_, started = job.Start()
+	assert.True(t, started)
+	<-job.done
+}
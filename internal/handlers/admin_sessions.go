@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -33,24 +34,18 @@ func init() {
 // @Produce json
 // @Param request body models.AdminAuthRequest true "Login credentials"
 // @Success 200 {object} models.AdminAuthResponse
-// @Failure 401 {object} models.AdminAuthResponse
+// @Failure 401 {object} models.APIError
 // @Router /api/admin/login [post]
 func AdminLoginHandler(authManager *auth.Manager) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var req models.AdminAuthRequest
 		if err := c.Bind(&req); err != nil {
-			return c.JSON(http.StatusBadRequest, models.AdminAuthResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Invalid request body: %v", err),
-			})
+			return respondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		}
 
 		token, err := authManager.Authenticate(req.Username, req.Password)
 		if err != nil {
-			return c.JSON(http.StatusUnauthorized, models.AdminAuthResponse{
-				Success: false,
-				Error:   "Invalid username or password",
-			})
+			return respondError(c, http.StatusUnauthorized, "Invalid username or password")
 		}
 
 		return c.JSON(http.StatusOK, models.AdminAuthResponse{
@@ -69,8 +64,8 @@ func AdminLoginHandler(authManager *auth.Manager) echo.HandlerFunc {
 // @Param limit query int false "Number of sessions per page" default(20)
 // @Param offset query int false "Offset for pagination" default(0)
 // @Success 200 {object} models.SessionListResponse
-// @Failure 401 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
 // @Router /api/admin/sessions [get]
 func ListSessionsHandler(conversationService *database.ConversationService) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -93,9 +88,7 @@ func ListSessionsHandler(conversationService *database.ConversationService) echo
 		// Get sessions
 		sessions, err := conversationService.GetSessions(limit, offset)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": fmt.Sprintf("Failed to get sessions: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get sessions: %v", err))
 		}
 
 		// Ensure sessions is never nil
@@ -106,9 +99,7 @@ func ListSessionsHandler(conversationService *database.ConversationService) echo
 		// Get total count
 		total, err := conversationService.GetSessionCount()
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": fmt.Sprintf("Failed to get session count: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get session count: %v", err))
 		}
 
 		// Convert timestamps to Israel timezone
@@ -137,25 +128,24 @@ func ListSessionsHandler(conversationService *database.ConversationService) echo
 // @Produce json
 // @Param sessionId path string true "Session ID (UUID)"
 // @Success 200 {object} models.ChatSessionDetail
-// @Failure 401 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
 // @Router /api/admin/sessions/{sessionId} [get]
 func GetSessionHandler(conversationService *database.ConversationService) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		sessionID := c.Param("sessionId")
 		if sessionID == "" {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": "Session ID is required",
-			})
+			return respondError(c, http.StatusBadRequest, "Session ID is required")
 		}
 
 		// Get session details
 		sessionDetail, err := conversationService.GetSessionDetails(sessionID)
+		if errors.Is(err, database.ErrSessionNotFound) {
+			return respondError(c, http.StatusNotFound, "Session not found")
+		}
 		if err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": fmt.Sprintf("Session not found: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get session: %v", err))
 		}
 
 		// Convert timestamps to Israel timezone
@@ -177,31 +167,25 @@ func GetSessionHandler(conversationService *database.ConversationService) echo.H
 // @Produce html
 // @Param sessionId path string true "Session ID (UUID)"
 // @Success 200 {string} string "HTML content"
-// @Failure 401 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
 // @Router /api/admin/sessions/{sessionId}/email [get]
 func GetSessionEmailHandler(conversationService *database.ConversationService) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		sessionID := c.Param("sessionId")
 		if sessionID == "" {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": "Session ID is required",
-			})
+			return respondError(c, http.StatusBadRequest, "Session ID is required")
 		}
 
 		// Get email HTML
 		emailHTML, err := conversationService.GetSessionEmailHTML(sessionID)
 		if err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": fmt.Sprintf("Email not found for session: %v", err),
-			})
+			return respondError(c, http.StatusNotFound, fmt.Sprintf("Email not found for session: %v", err))
 		}
 
 		if emailHTML == nil {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "No email found for this session",
-			})
+			return respondError(c, http.StatusNotFound, "No email found for this session")
 		}
 
 		// Return HTML content
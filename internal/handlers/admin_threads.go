@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ids/internal/emails"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MergeThreadsHandler handles merging fragmented email threads into one
+// @Summary Merge email threads
+// @Description Reassign the emails of duplicate threads to a primary thread and drop the merged threads. Thread embeddings are regenerated on the next thread embedding run.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body models.MergeThreadsRequest true "Threads to merge"
+// @Success 200 {object} models.MergeThreadsResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/admin/threads/merge [post]
+func MergeThreadsHandler(emailService *emails.EmailEmbeddingService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req models.MergeThreadsRequest
+		if err := c.Bind(&req); err != nil {
+			return respondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		}
+
+		if strings.TrimSpace(req.PrimaryThreadID) == "" {
+			return respondError(c, http.StatusBadRequest, "primary_thread_id is required")
+		}
+		if len(req.ThreadIDs) == 0 {
+			return respondError(c, http.StatusBadRequest, "thread_ids must list at least one thread to merge")
+		}
+
+		if err := emailService.MergeThreads(req.PrimaryThreadID, req.ThreadIDs); err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to merge threads: %v", err))
+		}
+
+		return c.JSON(http.StatusOK, models.MergeThreadsResponse{
+			Success:         true,
+			PrimaryThreadID: strings.TrimSpace(req.PrimaryThreadID),
+			MergedThreads:   len(req.ThreadIDs),
+		})
+	}
+}
+
+// RebuildThreadAggregatesHandler recomputes thread counts and dates from the stored emails
+// @Summary Rebuild email thread aggregates
+// @Description Recompute email_count, first_date, last_date and the participants of every thread from the emails table, fixing drift after deletes or merges
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.RebuildThreadAggregatesResponse
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/admin/threads/rebuild-aggregates [post]
+func RebuildThreadAggregatesHandler(emailService *emails.EmailEmbeddingService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		updated, err := emailService.RebuildThreadAggregates()
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to rebuild thread aggregates: %v", err))
+		}
+
+		participantThreads, err := emailService.RebuildThreadParticipants()
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to rebuild thread participants: %v", err))
+		}
+
+		return c.JSON(http.StatusOK, models.RebuildThreadAggregatesResponse{
+			Success:            true,
+			ThreadsUpdated:     updated,
+			ParticipantThreads: participantThreads,
+		})
+	}
+}
+
+// ThreadsByParticipantHandler looks up the email threads an address took part in
+// @Summary Find email threads by participant
+// @Description List the threads whose emails were sent from or to an address, answering whether a customer contacted us before
+// @Tags admin
+// @Produce json
+// @Param address query string true "Participant email address (case-insensitive)"
+// @Success 200 {object} models.ThreadParticipantLookupResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/admin/threads/by-participant [get]
+func ThreadsByParticipantHandler(emailService *emails.EmailEmbeddingService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		address := strings.ToLower(strings.TrimSpace(c.QueryParam("address")))
+		if address == "" {
+			return respondError(c, http.StatusBadRequest, "address query parameter is required")
+		}
+
+		threads, err := emailService.FindThreadsByParticipant(address)
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to find threads: %v", err))
+		}
+
+		return c.JSON(http.StatusOK, models.ThreadParticipantLookupResponse{
+			Address: address,
+			Threads: threads,
+			Total:   len(threads),
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"ids/internal/analytics"
 	"ids/internal/config"
@@ -20,7 +22,7 @@ import (
 // @Produce json
 // @Param period query string false "Time period (today, yesterday, last_7_days, last_30_days)" default(yesterday)
 // @Success 200 {object} models.AnalyticsResponse
-// @Failure 500 {object} models.AnalyticsResponse
+// @Failure 500 {object} models.APIError
 // @Router /api/analytics [get]
 func AnalyticsHandler(analyticsService *analytics.Service) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -34,10 +36,7 @@ func AnalyticsHandler(analyticsService *analytics.Service) echo.HandlerFunc {
 		summary, err := analyticsService.GetSummary(period)
 		if err != nil {
 			fmt.Printf("[ANALYTICS] ERROR: Failed to get analytics summary: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, models.AnalyticsResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to get analytics summary: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get analytics summary: %v", err))
 		}
 
 		fmt.Printf("[ANALYTICS] ✅ Analytics summary retrieved successfully\n")
@@ -48,6 +47,57 @@ func AnalyticsHandler(analyticsService *analytics.Service) echo.HandlerFunc {
 	}
 }
 
+// AnalyticsRangeHandler returns the analytics summary for a custom date range
+// @Summary Get analytics summary for a date range
+// @Description Get analytics summary for whole UTC days from start through end (both inclusive), e.g. a month for accounting
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param start query string true "First day (YYYY-MM-DD)"
+// @Param end query string true "Last day (YYYY-MM-DD)"
+// @Success 200 {object} models.AnalyticsResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/analytics/range [get]
+func AnalyticsRangeHandler(analyticsService *analytics.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start, end, err := parseDateRangeQuery(c)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+
+		fmt.Printf("[ANALYTICS] Fetching analytics summary from %s to %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
+
+		summary, err := analyticsService.GetSummaryRange(start, end)
+		if errors.Is(err, analytics.ErrInvalidRange) {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			fmt.Printf("[ANALYTICS] ERROR: Failed to get analytics summary: %v\n", err)
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get analytics summary: %v", err))
+		}
+
+		fmt.Printf("[ANALYTICS] ✅ Analytics summary retrieved successfully\n")
+		return c.JSON(http.StatusOK, models.AnalyticsResponse{
+			Success: true,
+			Summary: summary,
+		})
+	}
+}
+
+// parseDateRangeQuery reads the start and end query parameters (YYYY-MM-DD)
+func parseDateRangeQuery(c echo.Context) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", c.QueryParam("start"))
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("start must be a date in YYYY-MM-DD format")
+	}
+	end, err := time.Parse("2006-01-02", c.QueryParam("end"))
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("end must be a date in YYYY-MM-DD format")
+	}
+	return start, end, nil
+}
+
 // DailyReportHandler returns the daily analytics report (used by slack-notifications)
 // @Summary Get daily analytics report
 // @Description Get analytics report for the previous day, suitable for daily Slack notifications
@@ -55,7 +105,7 @@ func AnalyticsHandler(analyticsService *analytics.Service) echo.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Success 200 {object} models.AnalyticsResponse
-// @Failure 500 {object} models.AnalyticsResponse
+// @Failure 500 {object} models.APIError
 // @Router /api/analytics/daily-report [get]
 func DailyReportHandler(analyticsService *analytics.Service) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -64,10 +114,7 @@ func DailyReportHandler(analyticsService *analytics.Service) echo.HandlerFunc {
 		summary, err := analyticsService.GetDailyReport()
 		if err != nil {
 			fmt.Printf("[ANALYTICS] ERROR: Failed to generate daily report: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, models.AnalyticsResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to generate daily report: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to generate daily report: %v", err))
 		}
 
 		fmt.Printf("[ANALYTICS] ✅ Daily report generated successfully\n")
@@ -100,7 +147,7 @@ var weeklyReportRecipients = []string{
 // @Accept json
 // @Produce json
 // @Success 200 {object} models.AnalyticsResponse
-// @Failure 500 {object} models.AnalyticsResponse
+// @Failure 500 {object} models.APIError
 // @Router /api/analytics/weekly-report [get]
 func WeeklyReportHandler(analyticsService *analytics.Service, cfg *config.Config) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -109,10 +156,7 @@ func WeeklyReportHandler(analyticsService *analytics.Service, cfg *config.Config
 		summary, err := analyticsService.GetWeeklyReport()
 		if err != nil {
 			fmt.Printf("[ANALYTICS] ERROR: Failed to generate weekly report: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, models.AnalyticsResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to generate weekly report: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to generate weekly report: %v", err))
 		}
 
 		fmt.Printf("[ANALYTICS] ✅ Weekly report generated successfully\n")
@@ -144,3 +188,31 @@ func WeeklyReportHandler(analyticsService *analytics.Service, cfg *config.Config
 		})
 	}
 }
+
+// EmbeddingFreshnessHandler reports embedding table sizes and last update times
+// @Summary Get embedding freshness
+// @Description Row counts and last update times for product and email embeddings, and when generation last ran
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.EmbeddingFreshnessResponse
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/admin/embeddings/freshness [get]
+func EmbeddingFreshnessHandler(analyticsService *analytics.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		freshness, err := analyticsService.GetEmbeddingFreshness()
+		if err != nil {
+			fmt.Printf("[ANALYTICS] ERROR: Failed to get embedding freshness: %v\n", err)
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get embedding freshness: %v", err))
+		}
+
+		// Convert timestamps to Israel timezone
+		for _, ts := range []*time.Time{freshness.ProductEmbeddings.LastUpdatedAt, freshness.EmailEmbeddings.LastUpdatedAt, freshness.LastGenerationAt} {
+			if ts != nil {
+				*ts = ts.In(israelTZ)
+			}
+		}
+
+		return c.JSON(http.StatusOK, freshness)
+	}
+}
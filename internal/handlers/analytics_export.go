@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ids/internal/analytics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Analytics export formats (?format= or the Accept header)
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+)
+
+// exportMetadataPrefix names the flattened metadata columns, so they can't clash with the event columns
+const exportMetadataPrefix = "metadata."
+
+// exportFlushEvery is how many rows are written between flushes to the client
+const exportFlushEvery = 500
+
+// AnalyticsExportHandler streams the raw analytics events of a date range as CSV or JSON
+// @Summary Export analytics events
+// @Description Stream analytics_events rows for whole UTC days from start through end as CSV or a JSON array, with metadata keys flattened into metadata.<key> columns
+// @Tags admin
+// @Produce json
+// @Produce text/csv
+// @Param start query string true "First day (YYYY-MM-DD)"
+// @Param end query string true "Last day (YYYY-MM-DD)"
+// @Param format query string false "csv or json (default: from the Accept header, else json)"
+// @Success 200 {string} string "Exported events"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/admin/analytics/export [get]
+func AnalyticsExportHandler(analyticsService *analytics.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, err := exportFormat(c)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+		start, end, err := parseDateRangeQuery(c)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+
+		ctx := c.Request().Context()
+		keys, err := analyticsService.EventMetadataKeys(ctx, start, end)
+		if errors.Is(err, analytics.ErrInvalidRange) {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			fmt.Printf("[ANALYTICS] ERROR: Failed to export analytics events: %v\n", err)
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to export analytics events: %v", err))
+		}
+
+		fmt.Printf("[ANALYTICS] Exporting analytics events from %s to %s as %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"), format)
+
+		filename := fmt.Sprintf("analytics-events-%s-%s.%s", start.Format("2006-01-02"), end.Format("2006-01-02"), format)
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+		// Once rows are streaming the status can't change; a failure truncates the export and is logged
+		var rows int
+		if format == exportFormatCSV {
+			rows, err = writeEventsCSV(c, analyticsService, start, end, keys)
+		} else {
+			rows, err = writeEventsJSON(c, analyticsService, start, end)
+		}
+		if err != nil {
+			fmt.Printf("[ANALYTICS] ERROR: Analytics export stopped after %d events: %v\n", rows, err)
+			return nil
+		}
+
+		fmt.Printf("[ANALYTICS] ✅ Exported %d analytics events\n", rows)
+		return nil
+	}
+}
+
+// exportFormat picks csv or json from ?format=, falling back to the Accept header and then json
+func exportFormat(c echo.Context) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(c.QueryParam("format"))); format {
+	case exportFormatCSV, exportFormatJSON:
+		return format, nil
+	case "":
+		if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/csv") {
+			return exportFormatCSV, nil
+		}
+		return exportFormatJSON, nil
+	default:
+		return "", fmt.Errorf("format must be %s or %s", exportFormatCSV, exportFormatJSON)
+	}
+}
+
+// writeEventsCSV streams events as CSV with one column per metadata key
+func writeEventsCSV(c echo.Context, analyticsService *analytics.Service, start, end time.Time, keys []string) (int, error) {
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=UTF-8")
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	header := []string{"event_type", "count", "created_at"}
+	for _, key := range keys {
+		header = append(header, exportMetadataPrefix+key)
+	}
+	if err := writer.Write(header); err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	err := analyticsService.ExportEvents(c.Request().Context(), start, end, func(event analytics.ExportedEvent) error {
+		record := []string{event.EventType, strconv.Itoa(event.Count), event.CreatedAt.Format(time.RFC3339)}
+		for _, key := range keys {
+			record = append(record, csvValue(event.Metadata[key]))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			writer.Flush()
+			c.Response().Flush()
+		}
+		return writer.Error()
+	})
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	return rows, err
+}
+
+// writeEventsJSON streams events as a JSON array of flat objects
+func writeEventsJSON(c echo.Context, analyticsService *analytics.Service, start, end time.Time) (int, error) {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+
+	if _, err := c.Response().Write([]byte("[")); err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(c.Response())
+	rows := 0
+	err := analyticsService.ExportEvents(c.Request().Context(), start, end, func(event analytics.ExportedEvent) error {
+		object := map[string]interface{}{
+			"event_type": event.EventType,
+			"count":      event.Count,
+			"created_at": event.CreatedAt.Format(time.RFC3339),
+		}
+		for key, value := range event.Metadata {
+			object[exportMetadataPrefix+key] = value
+		}
+
+		if rows > 0 {
+			if _, err := c.Response().Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			c.Response().Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	_, err = c.Response().Write([]byte("]\n"))
+	return rows, err
+}
+
+// csvValue renders a metadata value for a CSV cell; nested values are written as JSON
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
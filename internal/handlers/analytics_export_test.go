@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ids/internal/analytics"
+	"ids/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newExportTestService returns an analytics service expecting the metadata key and event queries of one export
+func newExportTestService(t *testing.T) *analytics.Service {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	service, err := analytics.NewService(database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")))
+	require.NoError(t, err)
+
+	created := time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT DISTINCT jsonb_object_keys`).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("model").AddRow("tokens"))
+	mock.ExpectQuery(`ORDER BY created_at, id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "count", "metadata", "created_at"}).
+			AddRow(1, analytics.EventOpenAICall, 1, `{"tokens": 42, "model": "gpt-4o-mini"}`, created).
+			AddRow(2, analytics.EventSupportEscalation, 1, nil, created.Add(time.Minute)))
+	return service
+}
+
+func TestAnalyticsExportHandler_CSV(t *testing.T) {
+	service := newExportTestService(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/analytics/export?start=2026-02-01&end=2026-02-28", nil)
+	req.Header.Set(echo.HeaderAccept, "text/csv")
+	rec := httptest.NewRecorder()
+	require.NoError(t, AnalyticsExportHandler(service)(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/csv")
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "analytics-events-2026-02-01-2026-02-28.csv")
+	assert.Equal(t, "event_type,count,created_at,metadata.model,metadata.tokens\n"+
+		"openai_call,1,2026-02-03T09:00:00Z,gpt-4o-mini,42\n"+
+		"support_escalation,1,2026-02-03T09:01:00Z,,\n", rec.Body.String())
+}
+
+func TestAnalyticsExportHandler_JSON(t *testing.T) {
+	service := newExportTestService(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/analytics/export?start=2026-02-01&end=2026-02-28&format=json", nil)
+	req.Header.Set(echo.HeaderAccept, "text/csv") // ?format= wins
+	rec := httptest.NewRecorder()
+	require.NoError(t, AnalyticsExportHandler(service)(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var events []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+	require.Len(t, events, 2)
+	assert.Equal(t, "openai_call", events[0]["event_type"])
+	assert.Equal(t, float64(42), events[0]["metadata.tokens"])
+	assert.Equal(t, "gpt-4o-mini", events[0]["metadata.model"])
+	assert.Equal(t, "2026-02-03T09:01:00Z", events[1]["created_at"])
+	assert.NotContains(t, events[1], "metadata.tokens")
+}
+
+func TestAnalyticsExportHandler_RejectsUnknownFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/analytics/export?start=2026-02-01&end=2026-02-28&format=xlsx", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, AnalyticsExportHandler(nil)(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "format must be csv or json")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,13 +19,56 @@ import (
 	idsopenai "ids/internal/openai"
 	"ids/internal/utils"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/sashabaranov/go-openai"
 )
 
 const stockStatusInStock = "instock"
 
+// chatTurn holds what a chat request gathered before the LLM is called
+type chatTurn struct {
+	req             models.ChatRequest
+	userQuery       string
+	detectedLang    utils.Language
+	messages        []openai.ChatCompletionMessage
+	inStockProducts []embeddings.ProductEmbedding
+	similarEmails   []models.EmailSearchResult
+	productMetadata map[string]string
+	reply           *models.ChatResponse // Canned reply that skips the LLM (token cap, refused injection, shipping)
+	modelOverride   string               // Chat model requested by the X-Model header ("" = CHAT_MODEL)
+}
+
+// chatServices groups the dependencies shared by the JSON and streaming chat handlers
+type chatServices struct {
+	readDB              *database.ReadDB
+	cfg                 *config.Config
+	cache               *cache.Cache
+	embeddingService    *embeddings.EmbeddingService
+	emailService        *lazyEmailService
+	writeClient         *database.WriteClient // Loads the emails of context threads
+	analyticsService    *analytics.Service
+	conversationService *database.ConversationService
+}
+
+// newChatServices groups the chat dependencies; the email embedding service (with the shared
+// cache) is created on first use and retried every EMAIL_SERVICE_RETRY_INTERVAL while it fails
+func newChatServices(readDB *database.ReadDB, cfg *config.Config, cache *cache.Cache, embeddingService *embeddings.EmbeddingService, writeClient *database.WriteClient, analyticsService *analytics.Service, conversationService *database.ConversationService) *chatServices {
+	emailService := newLazyEmailService(func() (*emails.EmailEmbeddingService, error) {
+		return emails.NewEmailEmbeddingService(cfg, writeClient, cache)
+	}, time.Duration(cfg.EmailServiceRetry)*time.Second)
+
+	return &chatServices{
+		readDB:              readDB,
+		cfg:                 cfg,
+		cache:               cache,
+		embeddingService:    embeddingService,
+		emailService:        emailService,
+		writeClient:         writeClient,
+		analyticsService:    analyticsService,
+		conversationService: conversationService,
+	}
+}
+
 // ChatHandler handles chat requests with both product and email context
 // @Summary Chat with AI using enhanced vector search (products + email history)
 // @Description Send a conversation to the AI chatbot and get a response with product recommendations enhanced by similar past conversations
@@ -33,283 +77,453 @@ const stockStatusInStock = "instock"
 // @Produce json
 // @Param request body models.ChatRequest true "Chat request"
 // @Success 200 {object} models.ChatResponse
-// @Failure 400 {object} models.ChatResponse
-// @Failure 500 {object} models.ChatResponse
-// @Failure 503 {object} models.ChatResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Failure 503 {object} models.APIError
 // @Router /api/chat [post]
-//
-//nolint:gocyclo // Handler has necessary complexity for validation, search, and response building
-func ChatHandler(db *sqlx.DB, cfg *config.Config, cache *cache.Cache, embeddingService *embeddings.EmbeddingService, writeClient *database.WriteClient, analyticsService *analytics.Service, conversationService *database.ConversationService) echo.HandlerFunc {
-	// Create email embedding service with shared cache
-	emailService, err := emails.NewEmailEmbeddingService(cfg, writeClient, cache)
-	if err != nil {
-		fmt.Printf("[CHAT] Warning: Failed to create email service: %v\n", err)
-		emailService = nil // Will skip email search if not available
-	}
+func ChatHandler(readDB *database.ReadDB, cfg *config.Config, cache *cache.Cache, embeddingService *embeddings.EmbeddingService, writeClient *database.WriteClient, analyticsService *analytics.Service, conversationService *database.ConversationService) echo.HandlerFunc {
+	services := newChatServices(readDB, cfg, cache, embeddingService, writeClient, analyticsService, conversationService)
 
 	return func(c echo.Context) error {
 		fmt.Printf("[CHAT] ===== NEW CHAT REQUEST =====\n")
 
-		// Handle case where database connection is not available
-		if db == nil {
-			fmt.Printf("[CHAT] ERROR: Database connection not available\n")
-			return c.JSON(http.StatusServiceUnavailable, models.ChatResponse{
-				Error: "Database connection not available",
-			})
+		turn, err := services.prepareTurn(c)
+		if err != nil {
+			return err
 		}
-
-		// Check if OpenAI API key is configured
-		if cfg.OpenAIKey == "" {
-			fmt.Printf("[CHAT] ERROR: OpenAI API key not configured\n")
-			return c.JSON(http.StatusInternalServerError, models.ChatResponse{
-				Error: "OpenAI API key not configured",
-			})
+		if turn.reply != nil {
+			return c.JSON(http.StatusOK, *turn.reply)
 		}
 
-		// Parse request body
-		var req models.ChatRequest
-		if err := c.Bind(&req); err != nil {
-			fmt.Printf("[CHAT] ERROR: Invalid request body: %v\n", err)
-			return c.JSON(http.StatusBadRequest, models.ChatResponse{
-				Error: fmt.Sprintf("Invalid request body: %v", err),
-			})
+		client, err := newChatClient(cfg, turn.modelOverride, "CHAT")
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("[CHAT] Received conversation with %d messages\n", len(req.Conversation))
-		fmt.Printf("[CHAT] SessionID from request: '%s'\n", req.SessionID)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.OpenAITimeout)*time.Second)
+		defer cancel()
+
+		fmt.Printf("[CHAT] Sending chat request to %s...\n", client.GetProviderName())
+		resp, err := client.CreateChatCompletion(ctx, turn.messages, chatMaxTokens, chatTemperature)
 
-		// Validate conversation is not empty
-		if len(req.Conversation) == 0 {
-			fmt.Printf("[CHAT] ERROR: Empty conversation\n")
-			return c.JSON(http.StatusBadRequest, models.ChatResponse{
-				Error: "Conversation cannot be empty",
-			})
+		if err != nil {
+			fmt.Printf("[CHAT] ERROR: %s API error: %v\n", client.GetProviderName(), err)
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("%s API error: %v", client.GetProviderName(), err))
 		}
 
-		// Get the last user message
-		var userQuery string
-		for i := len(req.Conversation) - 1; i >= 0; i-- {
-			if strings.Contains(strings.ToLower(req.Conversation[i].Role), "user") {
-				userQuery = req.Conversation[i].Message
-				break
-			}
+		if len(resp.Choices) == 0 {
+			fmt.Printf("[CHAT] ERROR: No response from OpenAI\n")
+			return respondError(c, http.StatusInternalServerError, "No response from OpenAI")
 		}
 
-		if userQuery == "" {
-			fmt.Printf("[CHAT] ERROR: No user message found in conversation\n")
-			return c.JSON(http.StatusBadRequest, models.ChatResponse{
-				Error: "No user message found in conversation",
-			})
+		recordSessionTokens(cache, turn.req.SessionID, cfg.MaxSessionTokens, resp.Usage.TotalTokens)
+
+		// Optionally verify the reply language and re-prompt once on a mismatch
+		resp = services.enforceLanguage(ctx, client, turn, resp)
+
+		return c.JSON(http.StatusOK, services.finishTurn(c.Request().Context(), turn, resp.Choices[0].Message.Content, resp.Usage.TotalTokens, client.GetGPTModel()))
+	}
+}
+
+// Completion parameters shared by /api/chat and /api/chat/stream, so the two replies can't drift apart
+const (
+	chatMaxTokens   = 1500
+	chatTemperature = 0.7
+)
+
+// conversationSaveTimeout bounds saving the conversation (including retries) before the reply is sent
+const conversationSaveTimeout = 10 * time.Second
+
+// HeaderModelOverride selects the chat model of one request, for model experiments
+// Only models listed in CHAT_MODEL_OVERRIDES are accepted.
+const HeaderModelOverride = "X-Model"
+
+// newChatClient creates the unified OpenAI client (Azure primary, OpenAI fallback) for a chat turn
+// A non-empty model overrides the configured chat model for this turn.
+// Failures are returned as *echo.HTTPError, rendered by ErrorHandler in the standard envelope
+func newChatClient(cfg *config.Config, model, logPrefix string) (*idsopenai.Client, error) {
+	client, err := idsopenai.NewClient(cfg)
+	if err != nil {
+		fmt.Printf("[%s] ERROR: Failed to create OpenAI client: %v\n", logPrefix, err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to create OpenAI client: %v", err))
+	}
+	if model != "" {
+		fmt.Printf("[%s] Using model override: %s\n", logPrefix, model)
+		client = client.WithChatModel(model)
+	}
+	return client, nil
+}
+
+// chatModelOverride returns the model requested by the X-Model header, or "" when the header is absent
+// Models outside CHAT_MODEL_OVERRIDES are rejected with 400.
+func chatModelOverride(c echo.Context, cfg *config.Config) (string, error) {
+	requested := strings.ToLower(strings.TrimSpace(c.Request().Header.Get(HeaderModelOverride)))
+	if requested == "" {
+		return "", nil
+	}
+	if !slices.Contains(cfg.ChatModelOverrides, requested) {
+		fmt.Printf("[CHAT] ERROR: Model override %q is not allowed\n", requested)
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Model %q is not allowed", requested))
+	}
+	return requested, nil
+}
+
+// prepareTurn validates the request, runs the product and email searches and builds the LLM messages
+// Validation failures are returned as *echo.HTTPError, rendered by ErrorHandler in the standard envelope
+//
+//nolint:gocyclo // Handler has necessary complexity for validation, search, and context building
+func (s *chatServices) prepareTurn(c echo.Context) (*chatTurn, error) {
+	cfg := s.cfg
+	analyticsService := s.analyticsService
+
+	// Handle case where database connection is not available
+	if s.readDB.Get() == nil {
+		fmt.Printf("[CHAT] ERROR: Database connection not available\n")
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "Database connection not available")
+	}
+
+	// Check if OpenAI API key is configured
+	if cfg.OpenAIKey == "" {
+		fmt.Printf("[CHAT] ERROR: OpenAI API key not configured\n")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "OpenAI API key not configured")
+	}
+
+	modelOverride, err := chatModelOverride(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse request body
+	var req models.ChatRequest
+	if err := c.Bind(&req); err != nil {
+		fmt.Printf("[CHAT] ERROR: Invalid request body: %v\n", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+
+	fmt.Printf("[CHAT] Received conversation with %d messages\n", len(req.Conversation))
+	fmt.Printf("[CHAT] SessionID from request: '%s'\n", req.SessionID)
+
+	// Validate conversation is not empty
+	if len(req.Conversation) == 0 {
+		fmt.Printf("[CHAT] ERROR: Empty conversation\n")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Conversation cannot be empty")
+	}
+
+	// Get the last user message
+	var userQuery string
+	for i := len(req.Conversation) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(req.Conversation[i].Role), "user") {
+			userQuery = req.Conversation[i].Message
+			break
 		}
+	}
+
+	if userQuery == "" {
+		fmt.Printf("[CHAT] ERROR: No user message found in conversation\n")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "No user message found in conversation")
+	}
+
+	if err := validateProductFilters(req.Filters); err != nil {
+		fmt.Printf("[CHAT] ERROR: Invalid filters: %v\n", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid filters: %v", err))
+	}
 
-		fmt.Printf("[CHAT] Extracted user query: '%s'\n", userQuery)
+	fmt.Printf("[CHAT] Extracted user query: '%s'\n", userQuery)
 
-		// Check for shipping inquiry
-		if isShipping, country := IsShippingInquiry(userQuery); isShipping {
-			fmt.Printf("[CHAT] Detected shipping inquiry for country: %s\n", country)
-			response := GetShippingResponse(country)
-			return c.JSON(http.StatusOK, models.ChatResponse{
-				Response: response,
+	turn := &chatTurn{req: req, userQuery: userQuery, modelOverride: modelOverride}
+
+	// Refuse before search and the LLM once the session used up its token budget
+	if used, capped := sessionTokenCapReached(s.cache, req.SessionID, cfg.MaxSessionTokens); capped {
+		fmt.Printf("[CHAT] ⚠️  Session %s reached the token cap (%d/%d) - skipping OpenAI\n", req.SessionID, used, cfg.MaxSessionTokens)
+		trackSessionTokenCap(analyticsService, used, cfg.MaxSessionTokens)
+		turn.reply = &models.ChatResponse{
+			Response: sessionLimitMessage,
+			Products: make(map[string]string),
+		}
+		return turn, nil
+	}
+
+	// Detect prompt-injection attempts before the query reaches search or the LLM
+	if utils.LooksLikePromptInjection(userQuery) {
+		strippedQuery := utils.StripPromptInjection(userQuery)
+		if len(utils.ExtractMeaningfulTokens(strippedQuery)) == 0 {
+			fmt.Printf("[CHAT] ⚠️  Prompt injection detected with no remaining question - refusing\n")
+			trackPromptInjection(analyticsService, "refused")
+			turn.reply = &models.ChatResponse{
+				Response: promptInjectionRefusal,
 				Products: make(map[string]string),
-			})
+			}
+			return turn, nil
 		}
 
-		// Run product and email searches in parallel for better performance
-		var (
-			similarProducts      []embeddings.ProductEmbedding
-			fallbackToSimilarity bool
-			productErr           error
-			similarEmails        []models.EmailSearchResult
-			emailErr             error
-			wg                   sync.WaitGroup
-		)
+		fmt.Printf("[CHAT] ⚠️  Prompt injection detected - stripped query: '%s'\n", strippedQuery)
+		trackPromptInjection(analyticsService, "stripped")
+		replaceLastUserMessage(req.Conversation, strippedQuery)
+		userQuery = strippedQuery
+		turn.userQuery = userQuery
+	}
+
+	// Check for shipping inquiry
+	if isShipping, country := IsShippingInquiry(userQuery); isShipping {
+		fmt.Printf("[CHAT] Detected shipping inquiry for country: %s\n", country)
+		turn.reply = &models.ChatResponse{
+			Response: GetShippingResponse(country),
+			Products: make(map[string]string),
+		}
+		return turn, nil
+	}
 
-		searchStart := time.Now()
+	// Only features that need the email service trigger its (lazy) creation
+	var emailService *emails.EmailEmbeddingService
+	if cfg.EnableEmailContext || cfg.EnableCustomerHistory {
+		emailService = s.emailService.Get()
+	}
+
+	// Run product and email searches in parallel for better performance
+	var (
+		similarProducts      []embeddings.ProductEmbedding
+		fallbackToSimilarity bool
+		productErr           error
+		similarEmails        []models.EmailSearchResult
+		emailErr             error
+		wg                   sync.WaitGroup
+	)
+
+	searchStart := time.Now()
+
+	// Product search goroutine
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fmt.Printf("[CHAT] 🔍 DATASOURCE: Starting PRODUCT EMBEDDINGS search for query: '%s'\n", userQuery)
+		productStart := time.Now()
+		opts := s.embeddingService.DefaultSearchOptions()
+		opts.Filters = searchFilters(req.Filters, cfg.StockStatusMapping)
+		similarProducts, fallbackToSimilarity, productErr = s.embeddingService.SearchSimilarProductsWithOptions(c.Request().Context(), userQuery, 20, opts)
+		productDuration := time.Since(productStart)
+		if productErr != nil {
+			fmt.Printf("[CHAT] ❌ ERROR: Product embeddings search failed: %v (took %v)\n", productErr, productDuration)
+		} else {
+			fmt.Printf("[CHAT] ✅ DATASOURCE: PRODUCT EMBEDDINGS search completed - Found %d products (took %v, fallback=%t)\n", len(similarProducts), productDuration, fallbackToSimilarity)
+			trackSearchLatency(analyticsService, "product_search", productDuration)
+			// Track query embedding (billable - 1 embedding per product search)
+			if analyticsService != nil {
+				go func() { _ = analyticsService.TrackQueryEmbedding("product_search", "text-embedding-3-small") }()
+			}
+		}
+	}()
 
-		// Product search goroutine
+	// Email search goroutine (if enabled)
+	if cfg.EnableEmailContext && emailService != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			fmt.Printf("[CHAT] 🔍 DATASOURCE: Starting PRODUCT EMBEDDINGS search for query: '%s'\n", userQuery)
-			productStart := time.Now()
-			similarProducts, fallbackToSimilarity, productErr = embeddingService.SearchSimilarProducts(userQuery, 20)
-			productDuration := time.Since(productStart)
-			if productErr != nil {
-				fmt.Printf("[CHAT] ❌ ERROR: Product embeddings search failed: %v (took %v)\n", productErr, productDuration)
+			fmt.Printf("[CHAT] 🔍 DATASOURCE: Starting EMAIL EMBEDDINGS search for query: '%s'\n", userQuery)
+			emailStart := time.Now()
+			similarEmails, emailErr = emailService.SearchSimilarEmails(userQuery, cfg.EmailSearchLimit, true) // Search threads
+			emailDuration := time.Since(emailStart)
+			if emailErr != nil {
+				fmt.Printf("[CHAT] ❌ ERROR: Email embeddings search failed: %v (took %v)\n", emailErr, emailDuration)
 			} else {
-				fmt.Printf("[CHAT] ✅ DATASOURCE: PRODUCT EMBEDDINGS search completed - Found %d products (took %v, fallback=%t)\n", len(similarProducts), productDuration, fallbackToSimilarity)
-				// Track query embedding (billable - 1 embedding per product search)
+				fmt.Printf("[CHAT] ✅ DATASOURCE: EMAIL EMBEDDINGS search completed - Found %d similar email threads (took %v)\n", len(similarEmails), emailDuration)
+				trackSearchLatency(analyticsService, "email_search", emailDuration)
+				// Track query embedding (billable - 1 embedding per email search)
 				if analyticsService != nil {
-					go func() { _ = analyticsService.TrackQueryEmbedding("product_search", "text-embedding-3-small") }()
+					go func() { _ = analyticsService.TrackQueryEmbedding("email_search", "text-embedding-3-small") }()
 				}
 			}
 		}()
+	} else if !cfg.EnableEmailContext {
+		fmt.Printf("[CHAT] ⚠️  DATASOURCE: EMAIL EMBEDDINGS search skipped - Email context disabled in config\n")
+	} else if emailService == nil {
+		fmt.Printf("[CHAT] ⚠️  DATASOURCE: EMAIL EMBEDDINGS search skipped - Email service not available\n")
+	}
 
-		// Email search goroutine (if enabled)
-		if cfg.EnableEmailContext && emailService != nil {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				fmt.Printf("[CHAT] 🔍 DATASOURCE: Starting EMAIL EMBEDDINGS search for query: '%s'\n", userQuery)
-				emailStart := time.Now()
-				similarEmails, emailErr = emailService.SearchSimilarEmails(userQuery, 5, true) // Search threads
-				emailDuration := time.Since(emailStart)
-				if emailErr != nil {
-					fmt.Printf("[CHAT] ❌ ERROR: Email embeddings search failed: %v (took %v)\n", emailErr, emailDuration)
-				} else {
-					fmt.Printf("[CHAT] ✅ DATASOURCE: EMAIL EMBEDDINGS search completed - Found %d similar email threads (took %v)\n", len(similarEmails), emailDuration)
-					// Track query embedding (billable - 1 embedding per email search)
-					if analyticsService != nil {
-						go func() { _ = analyticsService.TrackQueryEmbedding("email_search", "text-embedding-3-small") }()
-					}
-				}
-			}()
-		} else if !cfg.EnableEmailContext {
-			fmt.Printf("[CHAT] ⚠️  DATASOURCE: EMAIL EMBEDDINGS search skipped - Email context disabled in config\n")
-		} else if emailService == nil {
-			fmt.Printf("[CHAT] ⚠️  DATASOURCE: EMAIL EMBEDDINGS search skipped - Email service not available\n")
-		}
+	// Wait for both searches to complete
+	wg.Wait()
+	totalSearchDuration := time.Since(searchStart)
+	fmt.Printf("[CHAT] 🏁 All searches completed in %v (parallel execution)\n", totalSearchDuration)
 
-		// Wait for both searches to complete
-		wg.Wait()
-		totalSearchDuration := time.Since(searchStart)
-		fmt.Printf("[CHAT] 🏁 All searches completed in %v (parallel execution)\n", totalSearchDuration)
+	// Check for product search error
+	if productErr != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to search products: %v", productErr))
+	}
 
-		// Check for product search error
-		if productErr != nil {
-			return c.JSON(http.StatusInternalServerError, models.ChatResponse{
-				Error: fmt.Sprintf("Failed to search products: %v", productErr),
-			})
-		}
+	// Too few results to trust are answered the same way as a search without matches
+	similarProducts = requireMinResults(similarProducts, cfg.MinResultsForMatch)
 
-		// Filter to in-stock products
-		var inStockProducts []embeddings.ProductEmbedding
-		for _, product := range similarProducts {
-			if product.Product.StockStatus != nil && *product.Product.StockStatus == stockStatusInStock {
-				inStockProducts = append(inStockProducts, product)
-			}
+	// Filter to in-stock (or backorderable) products
+	var inStockProducts []embeddings.ProductEmbedding
+	for _, product := range similarProducts {
+		if isAvailable(product, cfg.StockStatusMapping) {
+			inStockProducts = append(inStockProducts, product)
 		}
+	}
 
-		if len(inStockProducts) == 0 {
-			inStockProducts = similarProducts
-		}
+	if len(inStockProducts) == 0 {
+		inStockProducts = similarProducts
+	}
 
-		fmt.Printf("[CHAT] %d in-stock products\n", len(inStockProducts))
+	fmt.Printf("[CHAT] %d in-stock products\n", len(inStockProducts))
 
-		// Create product metadata for frontend
-		productMetadata := make(map[string]string)
-		for _, product := range inStockProducts {
-			if product.Product.PostName != nil && *product.Product.PostName != "" {
-				productMetadata[product.Product.PostTitle] = *product.Product.PostName
-			} else if product.Product.SKU != nil && *product.Product.SKU != "" {
-				productMetadata[product.Product.PostTitle] = *product.Product.SKU
-			} else {
-				productMetadata[product.Product.PostTitle] = fmt.Sprintf("product-%d", product.Product.ID)
-			}
+	// Order the filtered products according to the configured merchandising strategy
+	sortProductsForContext(inStockProducts, cfg.ContextSortMode, cfg.StockStatusMapping)
+
+	// Create product metadata for frontend
+	productMetadata := make(map[string]string)
+	for _, product := range inStockProducts {
+		if product.Product.PostName != nil && *product.Product.PostName != "" {
+			productMetadata[product.Product.PostTitle] = *product.Product.PostName
+		} else if product.Product.SKU != nil && *product.Product.SKU != "" {
+			productMetadata[product.Product.PostTitle] = *product.Product.SKU
+		} else {
+			productMetadata[product.Product.PostTitle] = fmt.Sprintf("product-%d", product.Product.ID)
 		}
+	}
 
-		// Build OpenAI messages with enhanced context
-		messages := buildOpenAIMessages(
-			req.Conversation,
-			inStockProducts,
+	// Pick the threads shown in the context (EMAIL_CONTEXT_RANKING) before fetching their emails
+	rankThreadsForContext(similarEmails, cfg.EmailContextRanking)
+
+	// Fetch the emails of the top threads for the context
+	var threadEmails [][]models.Email
+	if len(similarEmails) > 0 && s.writeClient != nil {
+		threadEmails = fetchThreadEmails(
+			c.Request().Context(),
 			similarEmails,
-			utils.Language{Code: utils.LangEnglish, Name: "English", Confidence: 1.0},
-			fallbackToSimilarity,
+			cfg.EmailContextThreadLimit,
+			getThreadEmails(s.writeClient),
+			cfg.EmailThreadConcurrency,
+			time.Duration(cfg.EmailThreadFetchTimeout)*time.Second,
 		)
+	}
 
-		// Create unified OpenAI client (Azure primary, OpenAI fallback) and get response
-		client, err := idsopenai.NewClient(cfg)
-		if err != nil {
-			fmt.Printf("[CHAT] ERROR: Failed to create OpenAI client: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, models.ChatResponse{
-				Error: fmt.Sprintf("Failed to create OpenAI client: %v", err),
-			})
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.OpenAITimeout)*time.Second)
-		defer cancel()
+	contextOpts := contextOptionsFromConfig(cfg)
+	if cfg.EnableCustomerHistory && emailService != nil {
+		contextOpts.CustomerHistory = lookupCustomerHistory(req.Conversation, emailService.GetCustomerHistory)
+	}
 
-		fmt.Printf("[CHAT] Sending chat request to %s...\n", client.GetProviderName())
-		resp, err := client.CreateChatCompletion(ctx, messages, 1500, 0.7)
+	// Build OpenAI messages with enhanced context
+	turn.detectedLang = utils.DetectLanguage(userQuery)
+	turn.messages = buildOpenAIMessages(
+		req.Conversation,
+		inStockProducts,
+		similarEmails,
+		threadEmails,
+		turn.detectedLang,
+		fallbackToSimilarity,
+		contextOpts,
+	)
+	turn.inStockProducts = inStockProducts
+	turn.similarEmails = similarEmails
+	turn.productMetadata = productMetadata
+
+	return turn, nil
+}
 
-		if err != nil {
-			fmt.Printf("[CHAT] ERROR: %s API error: %v\n", client.GetProviderName(), err)
-			return c.JSON(http.StatusInternalServerError, models.ChatResponse{
-				Error: fmt.Sprintf("%s API error: %v", client.GetProviderName(), err),
-			})
-		}
+// enforceLanguage re-prompts once when ENFORCE_RESPONSE_LANGUAGE is set and resp isn't in the
+// language the prompt asked for, counting the extra tokens and recording the correction
+func (s *chatServices) enforceLanguage(ctx context.Context, client chatCompleter, turn *chatTurn, resp *openai.ChatCompletionResponse) *openai.ChatCompletionResponse {
+	if !s.cfg.EnforceResponseLanguage {
+		return resp
+	}
 
-		if len(resp.Choices) == 0 {
-			fmt.Printf("[CHAT] ERROR: No response from OpenAI\n")
-			return c.JSON(http.StatusInternalServerError, models.ChatResponse{
-				Error: "No response from OpenAI",
-			})
-		}
+	// Enforce the language the prompt asked for (low-confidence detections fall back to English)
+	requestedLang := utils.FallbackToEnglish(turn.detectedLang, s.cfg.LanguageConfidenceThreshold)
+	resp, correction := enforceResponseLanguage(ctx, client, turn.messages, resp, requestedLang)
+	recordSessionTokens(s.cache, turn.req.SessionID, s.cfg.MaxSessionTokens, correction.Tokens)
+	if correction.Attempted && s.analyticsService != nil {
+		go func() {
+			if err := s.analyticsService.TrackLanguageCorrection(requestedLang.Code, correction.Corrected, correction.Tokens); err != nil {
+				fmt.Printf("[CHAT] Warning: Failed to track language correction: %v\n", err)
+			}
+		}()
+	}
+	return resp
+}
 
-		response := resp.Choices[0].Message.Content
-		if len(inStockProducts) > 0 {
-			response += fmt.Sprintf("\n\n**Found %d relevant products**", len(inStockProducts))
-		}
+// finishTurn appends the product count and support prompt to the LLM reply, records analytics,
+// saves the conversation and builds the response sent to the frontend
+// model is the chat model (or Azure deployment) that produced reply, recorded in analytics.
+// ctx is the request context; the save outlives a client disconnect but not conversationSaveTimeout.
+func (s *chatServices) finishTurn(ctx context.Context, turn *chatTurn, reply string, totalTokens int, model string) models.ChatResponse {
+	analyticsService := s.analyticsService
+	inStockProducts := turn.inStockProducts
+	similarEmails := turn.similarEmails
+	req := turn.req
+
+	response := reply
+	if len(inStockProducts) > 0 {
+		response += fmt.Sprintf("\n\n**Found %d relevant products**", len(inStockProducts))
+	}
 
-		// Track analytics
-		if analyticsService != nil {
-			totalTokens := 0
-			if resp.Usage.TotalTokens > 0 {
-				totalTokens = resp.Usage.TotalTokens
+	// Track analytics
+	if analyticsService != nil {
+		totalTokens = max(totalTokens, 0)
+		go func() {
+			if err := analyticsService.TrackConversation(len(inStockProducts), len(similarEmails), totalTokens, model); err != nil {
+				fmt.Printf("[CHAT] Warning: Failed to track analytics: %v\n", err)
 			}
-			go func() {
-				if err := analyticsService.TrackConversation(len(inStockProducts), len(similarEmails), totalTokens, string(openai.GPT4oMini)); err != nil {
-					fmt.Printf("[CHAT] Warning: Failed to track analytics: %v\n", err)
-				}
-			}()
-		}
+		}()
+	}
 
-		// Detect if customer is dissatisfied and needs support escalation
-		requestSupport := detectDissatisfaction(
-			req.Conversation,
-			userQuery,
-			inStockProducts,
-			similarEmails,
-		)
+	// Detect if customer is dissatisfied and needs support escalation
+	requestSupport := detectDissatisfaction(
+		req.Conversation,
+		turn.userQuery,
+		inStockProducts,
+		similarEmails,
+	)
+
+	if requestSupport {
+		response += "\n\nI notice you might need additional assistance. Would you like me to send this conversation to our support team? Please provide your email address so we can help you better."
+		fmt.Printf("[CHAT] ⚠️  Dissatisfaction detected - requesting support escalation\n")
+	}
 
-		if requestSupport {
-			response += "\n\nI notice you might need additional assistance. Would you like me to send this conversation to our support team? Please provide your email address so we can help you better."
-			fmt.Printf("[CHAT] ⚠️  Dissatisfaction detected - requesting support escalation\n")
+	fmt.Printf("[CHAT] 📊 DATASOURCE SUMMARY: Used %d product embeddings, %d email embeddings\n", len(inStockProducts), len(similarEmails))
+
+	// Save conversation to database if session_id is provided and conversation service is available
+	if req.SessionID != "" && s.conversationService != nil {
+		// Build the full transcript (user and assistant messages plus the AI response)
+		transcript := make([]models.ConversationMessage, 0, len(req.Conversation)+1)
+		for _, msg := range req.Conversation {
+			role := "user"
+			if strings.Contains(strings.ToLower(msg.Role), "assistant") ||
+				strings.Contains(strings.ToLower(msg.Role), "bot") ||
+				strings.Contains(strings.ToLower(msg.Role), "ai") {
+				role = "assistant"
+			}
+			transcript = append(transcript, models.ConversationMessage{Role: role, Message: msg.Message})
 		}
+		transcript = append(transcript, models.ConversationMessage{Role: "assistant", Message: response})
 
-		fmt.Printf("[CHAT] 📊 DATASOURCE SUMMARY: Used %d product embeddings, %d email embeddings\n", len(inStockProducts), len(similarEmails))
-
-		// Save conversation to database if session_id is provided and conversation service is available
-		if req.SessionID != "" && conversationService != nil {
-			go func() {
-				// Save all conversation messages (user and assistant)
-				for _, msg := range req.Conversation {
-					role := "user"
-					if strings.Contains(strings.ToLower(msg.Role), "assistant") ||
-						strings.Contains(strings.ToLower(msg.Role), "bot") ||
-						strings.Contains(strings.ToLower(msg.Role), "ai") {
-						role = "assistant"
-					}
-					if err := conversationService.SaveMessage(req.SessionID, role, msg.Message); err != nil {
-						fmt.Printf("[CHAT] Warning: Failed to save message: %v\n", err)
+		// Saved within the request, so server shutdown waits for the save and its retries
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), conversationSaveTimeout)
+		err := s.conversationService.SaveConversation(saveCtx, req.SessionID, transcript)
+		cancel()
+		if err != nil {
+			fmt.Printf("[CHAT] Warning: Failed to save conversation: %v\n", err)
+			if analyticsService != nil {
+				go func() {
+					if err := analyticsService.TrackConversationSaveFailure(len(transcript)); err != nil {
+						fmt.Printf("[CHAT] Warning: Failed to track conversation save failure: %v\n", err)
 					}
-				}
-				// Save the AI response
-				if err := conversationService.SaveMessage(req.SessionID, "assistant", response); err != nil {
-					fmt.Printf("[CHAT] Warning: Failed to save AI response: %v\n", err)
-				}
-			}()
-		} else if req.SessionID == "" {
-			fmt.Printf("[CHAT] Warning: No session_id provided, conversation not saved\n")
+				}()
+			}
 		}
+	} else if req.SessionID == "" {
+		fmt.Printf("[CHAT] Warning: No session_id provided, conversation not saved\n")
+	}
 
-		fmt.Printf("[CHAT] ===== REQUEST COMPLETE =====\n\n")
+	fmt.Printf("[CHAT] ===== REQUEST COMPLETE =====\n\n")
 
-		return c.JSON(http.StatusOK, models.ChatResponse{
-			Response:       response,
-			Products:       productMetadata,
-			RequestSupport: requestSupport,
-		})
+	totalProducts, hasMoreProducts := productResultCounts(inStockProducts, s.cfg.MaxContextProducts)
+
+	return models.ChatResponse{
+		Response:        response,
+		Products:        turn.productMetadata,
+		RequestSupport:  requestSupport,
+		HasMoreProducts: hasMoreProducts,
+		TotalProducts:   totalProducts,
 	}
 }
 
@@ -318,8 +532,10 @@ func buildOpenAIMessages(
 	conversation []models.ConversationMessage,
 	products []embeddings.ProductEmbedding,
 	emailThreads []models.EmailSearchResult,
+	threadEmails [][]models.Email,
 	detectedLang utils.Language,
 	fallbackToSimilarity bool,
+	opts contextOptions,
 ) []openai.ChatCompletionMessage {
 
 	systemPrompt := `You are an expert sales rep for Israel Defense Store (israeldefensestore.com) specializing in tactical gear.
@@ -363,6 +579,7 @@ RULES:
 - Only recommend products from the provided list
 - Use product tags for compatibility verification
 - Check stock status before recommending
+- Products marked "Available on Backorder" can be ordered now but ship later - say so when recommending them
 - Provide pricing and availability details
 - Format responses with **bold** for product names
 - Show the most relevant products first (ranked by similarity)
@@ -387,7 +604,12 @@ RESPONSE FORMAT:
 - For confirmed compatibility: **[Product Name]** - [Price] - [Stock] - Compatible with [Model]
 - For uncertain compatibility: **[Product Name]** - [Price] - [Stock] - ⚠️ Compatibility uncertain - please verify`
 
-	if fallbackToSimilarity {
+	if opts.ShowSKU {
+		systemPrompt += `
+- Include the SKU after the product name when it is listed: **[Product Name]** (SKU: [SKU])`
+	}
+
+	if fallbackToSimilarity && len(products) > 0 {
 		systemPrompt += `
 
 IMPORTANT:
@@ -397,18 +619,29 @@ IMPORTANT:
 	}
 
 	// Add language instruction
-	languageInstruction := utils.GetLanguageInstruction(detectedLang)
+	languageInstruction := utils.GetLanguageInstruction(detectedLang, opts.LanguageMinConfidence)
 
 	// Build product context
+	labels := productLabels(fallbackToSimilarity, len(products))
 	var productContext strings.Builder
-	productContext.WriteString("\n\n=== RELEVANT PRODUCTS ===\n")
+	productContext.WriteString("\n\n" + labels.Header + "\n")
+	if len(products) == 0 {
+		// Every search result fell below SEARCH_MIN_SIMILARITY (or nothing matched at all)
+		productContext.WriteString("\nNo relevant products were found for this query. Tell the customer nothing matching is available and do not recommend products that are not listed here.")
+	}
+	maxProducts := contextProductLimit(opts.MaxProducts)
 	for i, product := range products {
-		if i >= 15 {
-			fmt.Fprintf(&productContext, "\n... and %d more products available", len(products)-15)
+		if i >= maxProducts {
+			productContext.WriteString("\n" + fmt.Sprintf(labels.Overflow, len(products)-maxProducts))
 			break
 		}
 
-		fmt.Fprintf(&productContext, "\n**%s**", product.Product.PostTitle)
+		// Product data comes from WordPress and is untrusted - sanitize before it enters the prompt
+		fmt.Fprintf(&productContext, "\n**%s**", utils.SanitizePromptText(product.Product.PostTitle))
+
+		if opts.ShowSKU && product.Product.SKU != nil && *product.Product.SKU != "" {
+			fmt.Fprintf(&productContext, " - SKU: %s", utils.SanitizePromptText(*product.Product.SKU))
+		}
 
 		if product.Product.MinPrice != nil && product.Product.MaxPrice != nil {
 			if *product.Product.MinPrice == *product.Product.MaxPrice {
@@ -419,17 +652,33 @@ IMPORTANT:
 		}
 
 		if product.Product.StockStatus != nil {
-			if *product.Product.StockStatus == stockStatusInStock {
+			switch stockAvailability(product, opts.StockMapping) {
+			case availabilityAvailable:
 				productContext.WriteString(" - In Stock")
-			} else {
+			case availabilityBackorder:
+				productContext.WriteString(" - Available on Backorder")
+			default:
 				productContext.WriteString(" - Out of Stock")
 			}
 		}
 
+		// Categories and variations are opt-in compatibility details, so compact lines keep them too
+		if opts.Categories && product.Product.Categories != nil && *product.Product.Categories != "" {
+			fmt.Fprintf(&productContext, " - Categories: %s", utils.SanitizePromptText(*product.Product.Categories))
+		}
+		if opts.Variations && product.Product.Variations != nil && *product.Product.Variations != "" {
+			fmt.Fprintf(&productContext, " - Options: %s", utils.SanitizePromptText(*product.Product.Variations))
+		}
+
+		// Compact lines stop at availability; links reach the frontend through the product metadata
+		if opts.Compact {
+			continue
+		}
+
 		fmt.Fprintf(&productContext, " - Similarity: %.2f", product.Similarity)
 
 		if product.Product.Tags != nil && *product.Product.Tags != "" {
-			fmt.Fprintf(&productContext, " - Tags: %s", *product.Product.Tags)
+			fmt.Fprintf(&productContext, " - Tags: %s", utils.SanitizePromptText(*product.Product.Tags))
 		}
 
 		if product.Product.PostName != nil && *product.Product.PostName != "" {
@@ -445,19 +694,22 @@ IMPORTANT:
 		emailContext.WriteString("\n\n=== SIMILAR PAST CONVERSATIONS (for context) ===\n")
 		emailContext.WriteString("Learn from these similar customer interactions:\n")
 
+		maxThreads := contextThreadLimit(opts.EmailThreadLimit)
 		for i, result := range emailThreads {
-			if i >= 3 { // Limit to top 3 threads
+			if i >= maxThreads {
 				break
 			}
 
 			if result.Thread != nil {
 				fmt.Fprintf(&emailContext, "\n--- Thread: %s (Similarity: %.2f) ---\n", result.Thread.Subject, result.Similarity)
 
-				// Fetch thread emails
-				threadEmails, err := getThreadEmails(result.Thread.ThreadID)
-				if err == nil && len(threadEmails) > 0 {
-					for j, email := range threadEmails {
-						if j >= 5 { // Limit to 5 emails per thread
+				// A stored summary (SummarizeThread) covers the whole thread; otherwise show its raw
+				// emails, fetched beforehand and aligned with emailThreads
+				if hasThreadSummary(result.Thread) {
+					fmt.Fprintf(&emailContext, "Summary: %s\n", strings.TrimSpace(result.Thread.Summary))
+				} else if i < len(threadEmails) && len(threadEmails[i]) > 0 {
+					for j, email := range threadEmails[i] {
+						if j >= maxContextThreadEmails {
 							break
 						}
 
@@ -466,10 +718,7 @@ IMPORTANT:
 							role = "Support"
 						}
 
-						body := strings.TrimSpace(email.Body)
-						if len(body) > 300 {
-							body = body[:300] + "..."
-						}
+						body := truncateEmailBody(strings.TrimSpace(email.Body), opts.EmailBodyLength)
 
 						fmt.Fprintf(&emailContext, "%s: %s\n", role, body)
 					}
@@ -481,7 +730,7 @@ IMPORTANT:
 	}
 
 	// Combine all context
-	enhancedContext := systemPrompt + productContext.String() + emailContext.String() + "\n\n" + languageInstruction
+	enhancedContext := systemPrompt + productContext.String() + emailContext.String() + customerHistoryContext(opts.CustomerHistory) + "\n\n" + languageInstruction
 
 	messages := []openai.ChatCompletionMessage{
 		{
@@ -508,11 +757,41 @@ IMPORTANT:
 	return messages
 }
 
-// getThreadEmails retrieves all emails in a thread (helper function)
-func getThreadEmails(threadID string) ([]models.Email, error) {
-	// This is a simplified version - in production, you'd inject the DB connection
-	// For now, we'll return an error to use the summary instead
-	return nil, fmt.Errorf("thread detail retrieval not available in this context")
+// promptInjectionRefusal is returned when a query contains only an injection attempt
+const promptInjectionRefusal = "I'm here to help you find tactical gear from Israel Defense Store. What product can I help you with?"
+
+// trackPromptInjection records a detected prompt-injection attempt in the background
+func trackPromptInjection(analyticsService *analytics.Service, action string) {
+	if analyticsService == nil {
+		return
+	}
+	go func() {
+		if err := analyticsService.TrackPromptInjection(action); err != nil {
+			fmt.Printf("[CHAT] Warning: Failed to track prompt injection: %v\n", err)
+		}
+	}()
+}
+
+// trackSearchLatency records the duration of a successful chat search in the background
+func trackSearchLatency(analyticsService *analytics.Service, searchType string, duration time.Duration) {
+	if analyticsService == nil {
+		return
+	}
+	go func() {
+		if err := analyticsService.TrackSearchLatency(searchType, duration); err != nil {
+			fmt.Printf("[CHAT] Warning: Failed to track search latency: %v\n", err)
+		}
+	}()
+}
+
+// replaceLastUserMessage overwrites the most recent user message in the conversation
+func replaceLastUserMessage(conversation []models.ConversationMessage, message string) {
+	for i := len(conversation) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(conversation[i].Role), "user") {
+			conversation[i].Message = message
+			return
+		}
+	}
 }
 
 // detectDissatisfaction uses heuristics to detect if customer needs support escalation
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ids/internal/analytics"
+	"ids/internal/cache"
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/embeddings"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Server-sent event names used by the streaming chat endpoint
+const (
+	chatEventDelta   = "delta"   // models.ChatStreamDelta with the next piece of the reply
+	chatEventReplace = "replace" // models.ChatStreamDelta with a corrected reply replacing everything streamed so far
+	chatEventDone    = "done"    // models.ChatResponse with the full reply, products and request_support
+	chatEventError   = "error"   // models.APIError when the completion fails after the stream started
+)
+
+// chatStreamReceiver is the part of openai.ChatCompletionStream read by the streaming handler
+type chatStreamReceiver interface {
+	Recv() (openai.ChatCompletionStreamResponse, error)
+}
+
+// ChatStreamHandler streams chat responses to the client as server-sent events
+// @Summary Chat with AI and stream the response (server-sent events)
+// @Description Same as /api/chat, but the reply is streamed as "delta" events while it is generated. A final "done" event carries the full models.ChatResponse, including product metadata and the request_support flag. With ENFORCE_RESPONSE_LANGUAGE, a reply streamed in the wrong language is followed by a \"replace\" event carrying the corrected reply in full.
+// @Tags chat
+// @Accept json
+// @Produce text/event-stream
+// @Param request body models.ChatRequest true "Chat request"
+// @Success 200 {object} models.ChatStreamDelta
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Failure 503 {object} models.APIError
+// @Router /api/chat/stream [post]
+func ChatStreamHandler(readDB *database.ReadDB, cfg *config.Config, cache *cache.Cache, embeddingService *embeddings.EmbeddingService, writeClient *database.WriteClient, analyticsService *analytics.Service, conversationService *database.ConversationService) echo.HandlerFunc {
+	services := newChatServices(readDB, cfg, cache, embeddingService, writeClient, analyticsService, conversationService)
+
+	return func(c echo.Context) error {
+		fmt.Printf("[CHAT_STREAM] ===== NEW STREAMING CHAT REQUEST =====\n")
+
+		turn, err := services.prepareTurn(c)
+		if err != nil {
+			return err
+		}
+		if turn.reply != nil {
+			startEventStream(c)
+			if err := writeEvent(c, chatEventDelta, models.ChatStreamDelta{Content: turn.reply.Response}); err != nil {
+				return nil
+			}
+			_ = writeEvent(c, chatEventDone, turn.reply)
+			return nil
+		}
+
+		client, err := newChatClient(cfg, turn.modelOverride, "CHAT_STREAM")
+		if err != nil {
+			return err
+		}
+
+		// Bound by the request context as well, so a client disconnect stops generation
+		ctx, cancel := context.WithTimeout(c.Request().Context(), time.Duration(cfg.OpenAITimeout)*time.Second)
+		defer cancel()
+
+		fmt.Printf("[CHAT_STREAM] Sending streaming chat request to %s...\n", client.GetProviderName())
+		stream, err := client.CreateChatCompletionStream(ctx, turn.messages, chatMaxTokens, chatTemperature)
+		if err != nil {
+			fmt.Printf("[CHAT_STREAM] ERROR: %s API error: %v\n", client.GetProviderName(), err)
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("%s API error: %v", client.GetProviderName(), err))
+		}
+		defer stream.Close()
+
+		startEventStream(c)
+		reply, totalTokens, err := streamChatCompletion(c, stream)
+		recordSessionTokens(cache, turn.req.SessionID, cfg.MaxSessionTokens, totalTokens)
+		if err != nil {
+			fmt.Printf("[CHAT_STREAM] ERROR: Stream from %s failed: %v\n", client.GetProviderName(), err)
+			_ = writeEvent(c, chatEventError, models.APIError{
+				Code:      http.StatusInternalServerError,
+				Message:   fmt.Sprintf("%s API error: %v", client.GetProviderName(), err),
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			})
+			return nil
+		}
+
+		// The reply was already shown, so a language correction replaces it as a whole
+		if reply, err = services.correctStreamedLanguage(ctx, c, client, turn, reply); err != nil {
+			return nil
+		}
+
+		final := services.finishTurn(c.Request().Context(), turn, reply, totalTokens, client.GetGPTModel())
+
+		// Stream the product count and support prompt appended after the LLM reply
+		if suffix := strings.TrimPrefix(final.Response, reply); suffix != "" {
+			if err := writeEvent(c, chatEventDelta, models.ChatStreamDelta{Content: suffix}); err != nil {
+				return nil
+			}
+		}
+		_ = writeEvent(c, chatEventDone, final)
+		return nil
+	}
+}
+
+// correctStreamedLanguage applies ENFORCE_RESPONSE_LANGUAGE to a fully streamed reply and sends a
+// corrected reply as a "replace" event; the error is only set when that event can't be written
+func (s *chatServices) correctStreamedLanguage(ctx context.Context, c echo.Context, client chatCompleter, turn *chatTurn, reply string) (string, error) {
+	resp := s.enforceLanguage(ctx, client, turn, &openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply}}},
+	})
+	corrected := resp.Choices[0].Message.Content
+	if corrected == reply {
+		return reply, nil
+	}
+
+	fmt.Printf("[CHAT_STREAM] Replacing streamed reply with the language-corrected one\n")
+	if err := writeEvent(c, chatEventReplace, models.ChatStreamDelta{Content: corrected}); err != nil {
+		return reply, err
+	}
+	return corrected, nil
+}
+
+// streamChatCompletion forwards each content delta as an event and returns the full reply
+// and the token usage reported in the final chunk
+func streamChatCompletion(c echo.Context, stream chatStreamReceiver) (string, int, error) {
+	var reply strings.Builder
+	totalTokens := 0
+
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return reply.String(), totalTokens, nil
+		}
+		if err != nil {
+			return reply.String(), totalTokens, err
+		}
+
+		if chunk.Usage != nil {
+			totalTokens = chunk.Usage.TotalTokens
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		reply.WriteString(delta)
+		if err := writeEvent(c, chatEventDelta, models.ChatStreamDelta{Content: delta}); err != nil {
+			return reply.String(), totalTokens, fmt.Errorf("client disconnected: %w", err)
+		}
+	}
+}
+
+// startEventStream sends the server-sent event headers
+func startEventStream(c echo.Context) {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/event-stream")
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Flush()
+}
+
+// writeEvent writes one server-sent event with a JSON payload and flushes it to the client
+func writeEvent(c echo.Context, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+
+	if _, err := fmt.Fprintf(c.Response(), "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
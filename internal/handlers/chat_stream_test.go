@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/models"
+	"ids/internal/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChatStream replays canned chunks, then returns err (io.EOF when nil)
+type fakeChatStream struct {
+	chunks []openai.ChatCompletionStreamResponse
+	err    error
+}
+
+func (f *fakeChatStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(f.chunks) == 0 {
+		if f.err != nil {
+			return openai.ChatCompletionStreamResponse{}, f.err
+		}
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	chunk := f.chunks[0]
+	f.chunks = f.chunks[1:]
+	return chunk, nil
+}
+
+func deltaChunk(content string) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: content}}},
+	}
+}
+
+func newStreamContext() (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/api/chat/stream", nil), rec)
+	return c, rec
+}
+
+func TestStreamChatCompletion_WritesDeltasAndUsage(t *testing.T) {
+	c, rec := newStreamContext()
+	stream := &fakeChatStream{chunks: []openai.ChatCompletionStreamResponse{
+		deltaChunk("Try the "),
+		deltaChunk(""),
+		deltaChunk("Masada holster"),
+		{Usage: &openai.Usage{TotalTokens: 321}},
+	}}
+
+	startEventStream(c)
+	reply, tokens, err := streamChatCompletion(c, stream)
+	require.NoError(t, err)
+	assert.Equal(t, "Try the Masada holster", reply)
+	assert.Equal(t, 321, tokens)
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "no-cache", rec.Header().Get(echo.HeaderCacheControl))
+	assert.Equal(t,
+		"event: delta\ndata: {\"content\":\"Try the \"}\n\n"+
+			"event: delta\ndata: {\"content\":\"Masada holster\"}\n\n",
+		rec.Body.String())
+}
+
+func TestStreamChatCompletion_ReturnsStreamError(t *testing.T) {
+	c, _ := newStreamContext()
+	stream := &fakeChatStream{
+		chunks: []openai.ChatCompletionStreamResponse{deltaChunk("Partial")},
+		err:    errors.New("connection reset"),
+	}
+
+	reply, _, err := streamChatCompletion(c, stream)
+	require.Error(t, err)
+	assert.Equal(t, "Partial", reply)
+}
+
+func TestWriteEvent_DoneCarriesResponseMetadata(t *testing.T) {
+	c, rec := newStreamContext()
+
+	require.NoError(t, writeEvent(c, chatEventDone, models.ChatResponse{
+		Response:       "Here you go",
+		Products:       map[string]string{"Masada Holster": "masada-holster"},
+		RequestSupport: true,
+		TotalProducts:  1,
+	}))
+
+	assert.Equal(t,
+		"event: done\ndata: {\"response\":\"Here you go\",\"products\":{\"Masada Holster\":\"masada-holster\"},"+
+			"\"request_support\":true,\"has_more_products\":false,\"total_products\":1}\n\n",
+		rec.Body.String())
+}
+
+func TestCorrectStreamedLanguage_ReplacesWrongLanguageReply(t *testing.T) {
+	c, rec := newStreamContext()
+	services := &chatServices{cfg: &config.Config{EnforceResponseLanguage: true}}
+	turn := &chatTurn{detectedLang: utils.Language{Code: utils.LangHebrew, Name: "Hebrew", Confidence: 1}}
+	client := &fakeChatCompleter{responses: []string{"יש לנו נרתיק לגלוק 19 במלאי"}}
+
+	reply, err := services.correctStreamedLanguage(context.Background(), c, client, turn, "Yes, we have a Glock 19 holster in stock.")
+	require.NoError(t, err)
+
+	assert.Len(t, client.calls, 1)
+	assert.Equal(t, "יש לנו נרתיק לגלוק 19 במלאי", reply)
+	assert.Equal(t, "event: replace\ndata: {\"content\":\"יש לנו נרתיק לגלוק 19 במלאי\"}\n\n", rec.Body.String())
+}
+
+func TestCorrectStreamedLanguage_DisabledOrMatchingLanguage(t *testing.T) {
+	hebrew := utils.Language{Code: utils.LangHebrew, Name: "Hebrew", Confidence: 1}
+	tests := []struct {
+		name    string
+		enforce bool
+		reply   string
+	}{
+		{name: "enforcement disabled", enforce: false, reply: "Yes, we have a Glock 19 holster in stock."},
+		{name: "reply already in Hebrew", enforce: true, reply: "יש לנו נרתיק לגלוק 19 במלאי"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newStreamContext()
+			services := &chatServices{cfg: &config.Config{EnforceResponseLanguage: tt.enforce}}
+			client := &fakeChatCompleter{}
+
+			reply, err := services.correctStreamedLanguage(context.Background(), c, client, &chatTurn{detectedLang: hebrew}, tt.reply)
+			require.NoError(t, err)
+
+			assert.Empty(t, client.calls)
+			assert.Equal(t, tt.reply, reply)
+			assert.Empty(t, rec.Body.String())
+		})
+	}
+}
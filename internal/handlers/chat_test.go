@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChatClient_ReturnsHTTPErrorWithoutProvider(t *testing.T) {
+	client, err := newChatClient(&config.Config{}, "", "CHAT")
+	assert.Nil(t, client)
+
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusInternalServerError, httpErr.Code)
+	assert.Contains(t, httpErr.Message, "Failed to create OpenAI client")
+}
+
+func TestNewChatClient_UsesConfiguredProvider(t *testing.T) {
+	client, err := newChatClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536}, "", "CHAT_STREAM")
+	require.NoError(t, err)
+	assert.Equal(t, "OpenAI", client.GetProviderName())
+}
+
+func TestNewChatClient_AppliesModelOverride(t *testing.T) {
+	client, err := newChatClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536, ChatModel: "gpt-4o-mini"}, "gpt-4.1-mini", "CHAT")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4.1-mini", client.GetGPTModel())
+}
+
+func TestChatModelOverride(t *testing.T) {
+	cfg := &config.Config{ChatModelOverrides: []string{"gpt-4.1-mini", "gpt-4o"}}
+	newContext := func(model string) echo.Context {
+		req := httptest.NewRequest(http.MethodPost, "/api/chat", nil)
+		if model != "" {
+			req.Header.Set(HeaderModelOverride, model)
+		}
+		return echo.New().NewContext(req, httptest.NewRecorder())
+	}
+
+	model, err := chatModelOverride(newContext(""), cfg)
+	require.NoError(t, err)
+	assert.Empty(t, model)
+
+	model, err = chatModelOverride(newContext(" GPT-4.1-mini "), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4.1-mini", model)
+
+	_, err = chatModelOverride(newContext("o1-preview"), cfg)
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+
+	// Without CHAT_MODEL_OVERRIDES every override is rejected
+	_, err = chatModelOverride(newContext("gpt-4o"), &config.Config{})
+	require.Error(t, err)
+}
+
+func TestChatHandler_RejectsDisallowedModelBeforeSearch(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	cfg := &config.Config{OpenAIKey: "test-key", ChatModelOverrides: []string{"gpt-4o"}}
+	handler := ChatHandler(database.NewReadDB(db), cfg, nil, nil, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"conversation":[{"role":"user","message":"holster"}]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderModelOverride, "gpt-5")
+	err = handler(echo.New().NewContext(req, httptest.NewRecorder()))
+
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+	assert.Contains(t, httpErr.Message, "gpt-5")
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ids/internal/models"
+	"ids/internal/utils"
+)
+
+// conversationEmailRegex finds email addresses inside free-form chat messages
+var conversationEmailRegex = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+
+// customerHistoryLookup returns the prior threads of a customer address
+type customerHistoryLookup func(email string) (*models.CustomerHistory, error)
+
+// customerEmailFromConversation returns the most recent email address a user typed, e.g. when
+// asked for it during a support escalation. Assistant messages are ignored since they may
+// quote the support address.
+func customerEmailFromConversation(conversation []models.ConversationMessage) string {
+	for i := len(conversation) - 1; i >= 0; i-- {
+		if !strings.Contains(strings.ToLower(conversation[i].Role), "user") {
+			continue
+		}
+		matches := conversationEmailRegex.FindAllString(conversation[i].Message, -1)
+		if len(matches) > 0 {
+			return strings.ToLower(matches[len(matches)-1])
+		}
+	}
+	return ""
+}
+
+// lookupCustomerHistory fetches the history of the customer address found in the conversation
+// It returns nil when there is no address, the lookup fails or the customer has no prior threads.
+func lookupCustomerHistory(conversation []models.ConversationMessage, lookup customerHistoryLookup) *models.CustomerHistory {
+	email := customerEmailFromConversation(conversation)
+	if email == "" {
+		return nil
+	}
+
+	history, err := lookup(email)
+	if err != nil {
+		fmt.Printf("[CHAT] Warning: Failed to look up customer history: %v\n", err)
+		return nil
+	}
+	if history == nil || history.ThreadCount == 0 {
+		return nil
+	}
+
+	fmt.Printf("[CHAT] Returning customer with %d prior conversations\n", history.ThreadCount)
+	return history
+}
+
+// customerHistoryContext renders a brief returning-customer note for the LLM context
+// Subjects come from customer emails and are sanitized before they enter the prompt.
+func customerHistoryContext(history *models.CustomerHistory) string {
+	if history == nil || history.ThreadCount == 0 {
+		return ""
+	}
+
+	conversations := "conversations"
+	if history.ThreadCount == 1 {
+		conversations = "conversation"
+	}
+
+	var note strings.Builder
+	note.WriteString("\n\n=== CUSTOMER HISTORY ===\n")
+	fmt.Fprintf(&note, "Returning customer with %d prior %s", history.ThreadCount, conversations)
+	if len(history.Subjects) > 0 {
+		subjects := make([]string, len(history.Subjects))
+		for i, subject := range history.Subjects {
+			subjects[i] = fmt.Sprintf("%q", utils.SanitizePromptText(subject))
+		}
+		fmt.Fprintf(&note, " about %s", strings.Join(subjects, ", "))
+	}
+	if !history.LastContact.IsZero() {
+		fmt.Fprintf(&note, " (last contact %s)", history.LastContact.Format("2006-01-02"))
+	}
+	note.WriteString(".\nAcknowledge their previous contact where relevant, but do not reveal details of past conversations unless the customer brings them up.")
+	return note.String()
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ids/internal/models"
+	"ids/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomerEmailFromConversation(t *testing.T) {
+	conversation := []models.ConversationMessage{
+		{Role: "user", Message: "I wrote before from old@example.com"},
+		{Role: "assistant", Message: "Please provide your email address or write to support@israeldefensestore.com"},
+		{Role: "user", Message: "Sure, it's Buyer.Name+ids@Example.co.il thanks"},
+	}
+	assert.Equal(t, "buyer.name+ids@example.co.il", customerEmailFromConversation(conversation))
+
+	assert.Equal(t, "old@example.com", customerEmailFromConversation(conversation[:2]))
+	assert.Empty(t, customerEmailFromConversation([]models.ConversationMessage{
+		{Role: "assistant", Message: "Write to support@israeldefensestore.com"},
+		{Role: "user", Message: "no thanks"},
+	}))
+}
+
+func TestLookupCustomerHistory(t *testing.T) {
+	conversation := []models.ConversationMessage{{Role: "user", Message: "my email is buyer@example.com"}}
+	history := &models.CustomerHistory{Email: "buyer@example.com", ThreadCount: 2, Subjects: []string{"Holster sizing"}}
+
+	var looked []string
+	got := lookupCustomerHistory(conversation, func(email string) (*models.CustomerHistory, error) {
+		looked = append(looked, email)
+		return history, nil
+	})
+	assert.Same(t, history, got)
+	assert.Equal(t, []string{"buyer@example.com"}, looked)
+
+	noLookup := func(string) (*models.CustomerHistory, error) {
+		t.Fatal("lookup called without an email in the conversation")
+		return nil, nil
+	}
+	assert.Nil(t, lookupCustomerHistory([]models.ConversationMessage{{Role: "user", Message: "hello"}}, noLookup))
+
+	assert.Nil(t, lookupCustomerHistory(conversation, func(string) (*models.CustomerHistory, error) {
+		return nil, errors.New("db down")
+	}))
+	assert.Nil(t, lookupCustomerHistory(conversation, func(string) (*models.CustomerHistory, error) {
+		return &models.CustomerHistory{Email: "buyer@example.com"}, nil
+	}))
+}
+
+func TestBuildOpenAIMessages_CustomerHistory(t *testing.T) {
+	lang := utils.Language{Code: "en", Name: "English", Confidence: 1}
+	history := &models.CustomerHistory{
+		Email:       "buyer@example.com",
+		ThreadCount: 2,
+		Subjects:    []string{"Holster sizing", "Order delayed"},
+		LastContact: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+
+	messages := buildOpenAIMessages(nil, nil, nil, nil, lang, false, contextOptions{CustomerHistory: history})
+	require.NotEmpty(t, messages)
+	assert.Contains(t, messages[0].Content, "=== CUSTOMER HISTORY ===")
+	assert.Contains(t, messages[0].Content, `Returning customer with 2 prior conversations about "Holster sizing", "Order delayed" (last contact 2026-03-01).`)
+
+	messages = buildOpenAIMessages(nil, nil, nil, nil, lang, false, contextOptions{})
+	assert.NotContains(t, messages[0].Content, "CUSTOMER HISTORY")
+}
+
+func TestCustomerHistoryContext_SingleConversation(t *testing.T) {
+	note := customerHistoryContext(&models.CustomerHistory{ThreadCount: 1})
+	assert.Contains(t, note, "Returning customer with 1 prior conversation.")
+	assert.Empty(t, customerHistoryContext(nil))
+}
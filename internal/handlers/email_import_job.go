@@ -45,8 +45,8 @@ type JobStatus struct {
 // @Produce json
 // @Param request body TriggerEmailImportRequest false "Import job parameters"
 // @Success 200 {object} TriggerEmailImportResponse
-// @Failure 400 {object} TriggerEmailImportResponse
-// @Failure 500 {object} TriggerEmailImportResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
 // @Router /api/admin/import-emails [post]
 func TriggerEmailImportHandler(cfg *config.Config) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -55,10 +55,7 @@ func TriggerEmailImportHandler(cfg *config.Config) echo.HandlerFunc {
 		var req TriggerEmailImportRequest
 		if err := c.Bind(&req); err != nil {
 			fmt.Printf("[EMAIL_IMPORT_JOB] Invalid request: %v\n", err)
-			return c.JSON(http.StatusBadRequest, TriggerEmailImportResponse{
-				Success: false,
-				Error:   "Invalid request body",
-			})
+			return respondError(c, http.StatusBadRequest, "Invalid request body")
 		}
 
 		// Generate unique job name with timestamp
@@ -68,10 +65,7 @@ func TriggerEmailImportHandler(cfg *config.Config) echo.HandlerFunc {
 		k8sClient, err := k8s.NewClient("ids")
 		if err != nil {
 			fmt.Printf("[EMAIL_IMPORT_JOB] Failed to create Kubernetes client: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, TriggerEmailImportResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to create Kubernetes client: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to create Kubernetes client: %v", err))
 		}
 
 		// Create Kubernetes Job
@@ -86,10 +80,7 @@ func TriggerEmailImportHandler(cfg *config.Config) echo.HandlerFunc {
 
 		if err := k8sClient.CreateEmailImportJob(ctx, jobName, containerImage); err != nil {
 			fmt.Printf("[EMAIL_IMPORT_JOB] Failed to create job: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, TriggerEmailImportResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to create Kubernetes job: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to create Kubernetes job: %v", err))
 		}
 
 		fmt.Printf("[EMAIL_IMPORT_JOB] Successfully created job: %s\n", jobName)
@@ -110,8 +101,8 @@ func TriggerEmailImportHandler(cfg *config.Config) echo.HandlerFunc {
 // @Produce json
 // @Param jobName path string true "Job name"
 // @Success 200 {object} JobStatus
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
 // @Router /api/admin/email-import-status/{jobName} [get]
 func GetEmailImportStatusHandler(cfg *config.Config) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -123,9 +114,7 @@ func GetEmailImportStatusHandler(cfg *config.Config) echo.HandlerFunc {
 		k8sClient, err := k8s.NewClient("ids")
 		if err != nil {
 			fmt.Printf("[EMAIL_IMPORT_JOB] Failed to create Kubernetes client: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": fmt.Sprintf("Failed to create Kubernetes client: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to create Kubernetes client: %v", err))
 		}
 
 		// Get job status
@@ -135,9 +124,7 @@ func GetEmailImportStatusHandler(cfg *config.Config) echo.HandlerFunc {
 		job, err := k8sClient.GetJobStatus(ctx, jobName)
 		if err != nil {
 			fmt.Printf("[EMAIL_IMPORT_JOB] Failed to get job status: %v\n", err)
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": fmt.Sprintf("Job not found: %v", err),
-			})
+			return respondError(c, http.StatusNotFound, fmt.Sprintf("Job not found: %v", err))
 		}
 
 		// Determine status
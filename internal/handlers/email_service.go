@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ids/internal/emails"
+)
+
+// emailServiceFactory creates the email embedding service used for chat context
+type emailServiceFactory func() (*emails.EmailEmbeddingService, error)
+
+// lazyEmailService creates the email embedding service on first use and caches it
+// Failed creations are retried at most once per retryInterval, so a transient OpenAI or
+// database outage at startup doesn't disable email context until the next restart.
+type lazyEmailService struct {
+	create        emailServiceFactory
+	retryInterval time.Duration
+	now           func() time.Time
+
+	mu          sync.Mutex
+	service     *emails.EmailEmbeddingService
+	creating    bool
+	lastAttempt time.Time
+}
+
+// newLazyEmailService creates a provider retrying failed creations every retryInterval
+func newLazyEmailService(create emailServiceFactory, retryInterval time.Duration) *lazyEmailService {
+	return &lazyEmailService{
+		create:        create,
+		retryInterval: max(retryInterval, 0),
+		now:           time.Now,
+	}
+}
+
+// Get returns the email service, or nil while it is unavailable
+// Only one request attempts a creation at a time; concurrent requests skip email context
+// instead of waiting for it.
+func (l *lazyEmailService) Get() *emails.EmailEmbeddingService {
+	l.mu.Lock()
+	if l.service != nil || l.creating {
+		service := l.service
+		l.mu.Unlock()
+		return service
+	}
+	now := l.now()
+	if !l.lastAttempt.IsZero() && now.Sub(l.lastAttempt) < l.retryInterval {
+		l.mu.Unlock()
+		return nil
+	}
+	l.creating = true
+	l.lastAttempt = now
+	l.mu.Unlock()
+
+	service, err := l.create()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.creating = false
+	if err != nil {
+		fmt.Printf("[CHAT] Warning: Failed to create email service, retrying in %v: %v\n", l.retryInterval, err)
+		return nil
+	}
+	l.service = service
+	return service
+}
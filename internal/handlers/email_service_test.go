@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ids/internal/emails"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLazyEmailService returns a provider whose clock only moves when advance is called
+func newTestLazyEmailService(create emailServiceFactory, retryInterval time.Duration) (*lazyEmailService, func(time.Duration)) {
+	provider := newLazyEmailService(create, retryInterval)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+	return provider, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLazyEmailService_RecoversAfterInitFailure(t *testing.T) {
+	service := &emails.EmailEmbeddingService{}
+	attempts := 0
+	provider, advance := newTestLazyEmailService(func() (*emails.EmailEmbeddingService, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("failed to connect to OpenAI API")
+		}
+		return service, nil
+	}, time.Minute)
+
+	assert.Nil(t, provider.Get())
+	assert.Equal(t, 1, attempts)
+
+	// Within the retry interval the failure is not retried
+	advance(30 * time.Second)
+	assert.Nil(t, provider.Get())
+	assert.Equal(t, 1, attempts)
+
+	advance(30 * time.Second)
+	assert.Same(t, service, provider.Get())
+	assert.Equal(t, 2, attempts)
+
+	// Once created the service is cached
+	advance(time.Hour)
+	assert.Same(t, service, provider.Get())
+	assert.Equal(t, 2, attempts)
+}
+
+func TestLazyEmailService_ZeroIntervalRetriesEveryCall(t *testing.T) {
+	attempts := 0
+	provider, _ := newTestLazyEmailService(func() (*emails.EmailEmbeddingService, error) {
+		attempts++
+		return nil, errors.New("database unavailable")
+	}, 0)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, provider.Get())
+	}
+	assert.Equal(t, 3, attempts)
+}
+
+func TestLazyEmailService_SingleCreationAtATime(t *testing.T) {
+	service := &emails.EmailEmbeddingService{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	attempts := 0
+	provider := newLazyEmailService(func() (*emails.EmailEmbeddingService, error) {
+		attempts++
+		close(started)
+		<-release
+		return service, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Same(t, service, provider.Get())
+	}()
+
+	<-started
+	// A request arriving mid-creation skips email context instead of blocking
+	assert.Nil(t, provider.Get())
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, 1, attempts)
+	assert.Same(t, service, provider.Get())
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// respondError writes the standard JSON error envelope
+func respondError(c echo.Context, status int, message string) error {
+	return c.JSON(status, models.APIError{
+		Code:      status,
+		Message:   message,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	})
+}
+
+// ErrorHandler is the Echo HTTP error handler. It renders errors returned by handlers and
+// middleware (unknown routes, auth failures, recovered panics) in the standard envelope.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	message := http.StatusText(status)
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+		message = fmt.Sprint(httpErr.Message)
+	} else {
+		// Don't leak internal error details to clients
+		fmt.Printf("[HTTP] ERROR: Unhandled error on %s %s: %v\n", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = respondError(c, status, message)
+	}
+	if err != nil {
+		fmt.Printf("[HTTP] ERROR: Failed to write error response: %v\n", err)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newErrorTestServer returns an Echo instance wired like the server: request IDs and the error envelope
+func newErrorTestServer() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(middleware.RequestID())
+	e.Use(middleware.Recover())
+	return e
+}
+
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) models.APIError {
+	t.Helper()
+	var apiErr models.APIError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+	return apiErr
+}
+
+func TestErrorHandler_Envelope(t *testing.T) {
+	tests := []struct {
+		name            string
+		handler         echo.HandlerFunc
+		path            string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{
+			name: "handler error response",
+			handler: func(c echo.Context) error {
+				return respondError(c, http.StatusBadRequest, "Conversation cannot be empty")
+			},
+			path:            "/test",
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Conversation cannot be empty",
+		},
+		{
+			name: "returned HTTP error",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized. Please login first.")
+			},
+			path:            "/test",
+			expectedStatus:  http.StatusUnauthorized,
+			expectedMessage: "Unauthorized. Please login first.",
+		},
+		{
+			name:            "plain error hides details",
+			handler:         func(c echo.Context) error { return errors.New("pq: connection refused") },
+			path:            "/test",
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "Internal Server Error",
+		},
+		{
+			name:            "recovered panic",
+			handler:         func(c echo.Context) error { panic("boom") },
+			path:            "/test",
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "Internal Server Error",
+		},
+		{
+			name:            "unknown route",
+			handler:         func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+			path:            "/missing",
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "Not Found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newErrorTestServer()
+			e.GET("/test", tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			apiErr := decodeAPIError(t, rec)
+			assert.Equal(t, tt.expectedStatus, apiErr.Code)
+			assert.Equal(t, tt.expectedMessage, apiErr.Message)
+			assert.NotEmpty(t, apiErr.RequestID)
+			assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), apiErr.RequestID)
+		})
+	}
+}
+
+func TestErrorHandler_KeepsIncomingRequestID(t *testing.T) {
+	e := newErrorTestServer()
+	e.GET("/test", func(c echo.Context) error { return respondError(c, http.StatusNotFound, "Session not found") })
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-123")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	apiErr := decodeAPIError(t, rec)
+	assert.Equal(t, models.APIError{Code: http.StatusNotFound, Message: "Session not found", RequestID: "req-123"}, apiErr)
+}
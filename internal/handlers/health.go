@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"time"
@@ -9,7 +10,6 @@ import (
 	"ids/internal/database"
 	"ids/internal/models"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 )
 
@@ -40,21 +40,26 @@ func HealthHandler(version string) echo.HandlerFunc {
 
 // DBHealthHandler handles database health check requests
 // @Summary Database health check
-// @Description Get database connectivity status and latency
+// @Description Get database connectivity status, latency, connection pool statistics and background reconnection state
 // @Tags health
 // @Accept json
 // @Produce json
 // @Success 200 {object} models.DBHealthResponse
 // @Failure 503 {object} models.DBHealthResponse
 // @Router /api/healthz/db [get]
-func DBHealthHandler(db *sqlx.DB) echo.HandlerFunc {
+func DBHealthHandler(readDB *database.ReadDB, writeClient *database.WriteClient) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		db := readDB.Get()
 		response := models.DBHealthResponse{
 			Status:    "unknown",
 			Timestamp: time.Now().UTC(),
 			Connected: false,
 			Latency:   0,
 		}
+		if writeClient != nil {
+			response.EmbeddingsPool = poolStats(writeClient.GetPoolStats())
+		}
+		response.Reconnect = reconnectStatus(readDB.Status())
 
 		// Check if database connection exists
 		if db == nil {
@@ -62,6 +67,7 @@ func DBHealthHandler(db *sqlx.DB) echo.HandlerFunc {
 			response.Error = "Database connection not initialized"
 			return c.JSON(http.StatusServiceUnavailable, response)
 		}
+		response.Pool = poolStats(db.Stats())
 
 		// Measure database ping latency
 		start := time.Now()
@@ -94,6 +100,43 @@ func DBHealthHandler(db *sqlx.DB) echo.HandlerFunc {
 	}
 }
 
+// poolStats converts connection pool statistics for the health response
+func poolStats(stats sql.DBStats) *models.DBPoolStats {
+	return &models.DBPoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+// reconnectStatus converts the reconnection state for the health response
+// It is omitted while the startup connection has never been lost.
+func reconnectStatus(status database.ReconnectStatus) *models.DBReconnectStatus {
+	if !status.Reconnecting && status.Attempts == 0 && status.ConnectedAt.IsZero() {
+		return nil
+	}
+	response := &models.DBReconnectStatus{
+		Reconnecting: status.Reconnecting,
+		Attempts:     status.Attempts,
+		LastError:    status.LastError,
+	}
+	if !status.LastAttempt.IsZero() {
+		response.LastAttempt = &status.LastAttempt
+	}
+	if status.Reconnecting && !status.NextAttempt.IsZero() {
+		response.NextAttempt = &status.NextAttempt
+	}
+	if !status.ConnectedAt.IsZero() {
+		response.ConnectedAt = &status.ConnectedAt
+	}
+	return response
+}
+
 // RootHandler handles requests to the root endpoint
 // @Summary Root endpoint
 // @Description Get basic service information
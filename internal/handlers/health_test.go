@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"ids/internal/database"
 	"ids/internal/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -174,7 +175,7 @@ func TestDBHealthHandler(t *testing.T) {
 			}
 
 			// Execute
-			handler := DBHealthHandler(testDB)
+			handler := DBHealthHandler(database.NewReadDB(testDB), nil)
 			err := handler(c)
 
 			// Assert
@@ -190,6 +191,65 @@ func TestDBHealthHandler(t *testing.T) {
 	}
 }
 
+func TestDBHealthHandler_PoolStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	testDB := sqlx.NewDb(mockDB, "sqlmock")
+	database.ApplyPoolSettings(testDB, database.PoolSettings{MaxOpenConns: 7, MaxIdleConns: 2})
+
+	embeddingsDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = embeddingsDB.Close() }()
+	writeClient := database.NewWriteClientFromDB(sqlx.NewDb(embeddingsDB, "sqlmock"))
+	writeClient.ApplyPoolSettings(database.PoolSettings{MaxOpenConns: 3, MaxIdleConns: 1})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectRollback()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/healthz/db", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, DBHealthHandler(database.NewReadDB(testDB), writeClient)(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response models.DBHealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotNil(t, response.Pool)
+	assert.Equal(t, 7, response.Pool.MaxOpenConnections)
+	assert.Equal(t, 1, response.Pool.OpenConnections)
+	require.NotNil(t, response.EmbeddingsPool)
+	assert.Equal(t, 3, response.EmbeddingsPool.MaxOpenConnections)
+}
+
+func TestDBHealthHandler_ReportsReconnection(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/healthz/db", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, DBHealthHandler(database.NewReadDB(nil), nil)(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var response models.DBHealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotNil(t, response.Reconnect)
+	assert.True(t, response.Reconnect.Reconnecting)
+	assert.Nil(t, response.Pool)
+
+	// A startup connection that was never lost reports no reconnection state
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectRollback()
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, DBHealthHandler(database.NewReadDB(sqlx.NewDb(mockDB, "sqlmock")), nil)(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"reconnect"`)
+}
+
 func TestRootHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -272,7 +332,7 @@ func TestDBHealthHandler_Concurrency(t *testing.T) {
 	}
 
 	e := echo.New()
-	handler := DBHealthHandler(testDB)
+	handler := DBHealthHandler(database.NewReadDB(testDB), nil)
 
 	// Run 5 health checks to ensure handler is stable
 	for i := 0; i < 5; i++ {
@@ -306,7 +366,7 @@ func TestDBHealthHandler_ContextTimeout(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := DBHealthHandler(testDB)
+	handler := DBHealthHandler(database.NewReadDB(testDB), nil)
 	err = handler(c)
 
 	require.NoError(t, err)
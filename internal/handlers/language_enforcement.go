@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"ids/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// chatCompleter is the subset of the unified OpenAI client used for chat responses
+type chatCompleter interface {
+	CreateChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage, maxTokens int, temperature float32) (*openai.ChatCompletionResponse, error)
+}
+
+// languageCorrection describes the outcome of a corrective re-prompt
+type languageCorrection struct {
+	Attempted bool
+	Corrected bool
+	Tokens    int
+}
+
+// enforceResponseLanguage checks the generated reply against the requested language and,
+// on a mismatch, sends a single corrective re-prompt. The original response is kept
+// when the retry fails or is still in the wrong language.
+func enforceResponseLanguage(
+	ctx context.Context,
+	client chatCompleter,
+	messages []openai.ChatCompletionMessage,
+	resp *openai.ChatCompletionResponse,
+	requestedLang utils.Language,
+) (*openai.ChatCompletionResponse, languageCorrection) {
+	var result languageCorrection
+	if len(resp.Choices) == 0 || !utils.ResponseLanguageMismatch(resp.Choices[0].Message.Content, requestedLang) {
+		return resp, result
+	}
+
+	fmt.Printf("[CHAT] ⚠️  Response language mismatch (expected %s) - sending corrective re-prompt\n", requestedLang.Code)
+	result.Attempted = true
+
+	retryMessages := make([]openai.ChatCompletionMessage, 0, len(messages)+2)
+	retryMessages = append(retryMessages, messages...)
+	retryMessages = append(retryMessages,
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: resp.Choices[0].Message.Content,
+		},
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: "Rewrite your previous answer with the same content. " + utils.GetLanguageInstruction(requestedLang, 0), // requestedLang already passed the confidence check
+		},
+	)
+
+	retryResp, err := client.CreateChatCompletion(ctx, retryMessages, 1500, 0.7)
+	if err != nil {
+		fmt.Printf("[CHAT] Warning: Language correction failed: %v\n", err)
+		return resp, result
+	}
+	result.Tokens = retryResp.Usage.TotalTokens
+
+	if len(retryResp.Choices) == 0 || utils.ResponseLanguageMismatch(retryResp.Choices[0].Message.Content, requestedLang) {
+		fmt.Printf("[CHAT] Warning: Corrected response still not in %s - keeping original\n", requestedLang.Code)
+		return resp, result
+	}
+
+	result.Corrected = true
+	return retryResp, result
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"ids/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChatCompleter returns canned responses in order and records the messages it received
+type fakeChatCompleter struct {
+	responses []string
+	calls     [][]openai.ChatCompletionMessage
+}
+
+func (f *fakeChatCompleter) CreateChatCompletion(_ context.Context, messages []openai.ChatCompletionMessage, _ int, _ float32) (*openai.ChatCompletionResponse, error) {
+	f.calls = append(f.calls, messages)
+	content := f.responses[len(f.calls)-1]
+	return &openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: content}}},
+		Usage:   openai.Usage{TotalTokens: 42},
+	}, nil
+}
+
+func chatResponse(content string) *openai.ChatCompletionResponse {
+	return &openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: content}}},
+	}
+}
+
+func TestEnforceResponseLanguage_RetriesWrongLanguage(t *testing.T) {
+	hebrew := utils.Language{Code: utils.LangHebrew, Name: "Hebrew"}
+	client := &fakeChatCompleter{responses: []string{"יש לנו **Glock 19 Holster** במלאי"}}
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "יש לכם נרתיק לגלוק?"}}
+
+	resp, correction := enforceResponseLanguage(context.Background(), client, messages, chatResponse("Yes, we have a Glock 19 holster in stock."), hebrew)
+
+	require.Len(t, client.calls, 1)
+	retryMessages := client.calls[0]
+	require.Len(t, retryMessages, 3)
+	assert.Equal(t, openai.ChatMessageRoleAssistant, retryMessages[1].Role)
+	assert.Contains(t, retryMessages[2].Content, utils.GetLanguageInstruction(hebrew, 0))
+
+	assert.True(t, correction.Attempted)
+	assert.True(t, correction.Corrected)
+	assert.Equal(t, 42, correction.Tokens)
+	assert.Equal(t, "יש לנו **Glock 19 Holster** במלאי", resp.Choices[0].Message.Content)
+}
+
+func TestEnforceResponseLanguage_KeepsOriginalWhenRetryStillWrong(t *testing.T) {
+	hebrew := utils.Language{Code: utils.LangHebrew, Name: "Hebrew"}
+	client := &fakeChatCompleter{responses: []string{"Still English, sorry."}}
+
+	resp, correction := enforceResponseLanguage(context.Background(), client, nil, chatResponse("We have holsters."), hebrew)
+
+	assert.Len(t, client.calls, 1)
+	assert.True(t, correction.Attempted)
+	assert.False(t, correction.Corrected)
+	assert.Equal(t, "We have holsters.", resp.Choices[0].Message.Content)
+}
+
+func TestEnforceResponseLanguage_NoRetryWhenLanguageMatches(t *testing.T) {
+	hebrew := utils.Language{Code: utils.LangHebrew, Name: "Hebrew"}
+	client := &fakeChatCompleter{}
+
+	_, correction := enforceResponseLanguage(context.Background(), client, nil, chatResponse("יש לנו נרתיקים במלאי"), hebrew)
+
+	assert.Empty(t, client.calls)
+	assert.False(t, correction.Attempted)
+}
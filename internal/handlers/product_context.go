@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"ids/internal/config"
+	"ids/internal/embeddings"
+	"ids/internal/models"
+)
+
+// contextOptions controls how products are rendered in the LLM context
+type contextOptions struct {
+	MaxProducts  int               // Maximum products listed (MAX_CONTEXT_PRODUCTS)
+	ShowSKU      bool              // Include SKUs (SHOW_SKU_IN_RESPONSE)
+	Compact      bool              // Omit similarity, tags and URLs from product lines (COMPACT_PRODUCT_CONTEXT)
+	Categories   bool              // Include product categories (INCLUDE_CATEGORIES_IN_CONTEXT)
+	Variations   bool              // Include variation options (INCLUDE_VARIATIONS_IN_CONTEXT)
+	StockMapping map[string]string // stock_status -> availability (STOCK_STATUS_MAPPING)
+
+	EmailBodyLength       int     // Maximum characters per context email body (EMAIL_CONTEXT_BODY_LENGTH)
+	EmailThreadLimit      int     // Maximum similar email threads rendered (EMAIL_CONTEXT_THREAD_LIMIT)
+	LanguageMinConfidence float64 // Detections below this fall back to English (LANGUAGE_CONFIDENCE_THRESHOLD)
+
+	CustomerHistory *models.CustomerHistory // Prior threads of the customer in the conversation (ENABLE_CUSTOMER_HISTORY), nil to omit
+}
+
+// contextOptionsFromConfig builds context options from the application config
+func contextOptionsFromConfig(cfg *config.Config) contextOptions {
+	return contextOptions{
+		MaxProducts:  cfg.MaxContextProducts,
+		ShowSKU:      cfg.ShowSKUInResponse,
+		Compact:      cfg.CompactContext,
+		Categories:   cfg.IncludeCategoriesInContext,
+		Variations:   cfg.IncludeVariationsInContext,
+		StockMapping: cfg.StockStatusMapping,
+
+		EmailBodyLength:       cfg.EmailContextBodyLength,
+		EmailThreadLimit:      cfg.EmailContextThreadLimit,
+		LanguageMinConfidence: cfg.LanguageConfidenceThreshold,
+	}
+}
+
+// Product availability values used in STOCK_STATUS_MAPPING
+const (
+	availabilityAvailable   = "available"
+	availabilityBackorder   = "backorder"
+	availabilityUnavailable = "unavailable"
+)
+
+// defaultMaxContextProducts is used when MAX_CONTEXT_PRODUCTS is not positive
+const defaultMaxContextProducts = 15
+
+// productContextLabels are the product section strings of the LLM context for one search outcome
+type productContextLabels struct {
+	Header   string // Section header
+	Overflow string // Line closing a truncated listing; %d is the number of products left out
+}
+
+var (
+	// relevantProductLabels are used when the search found matching products
+	relevantProductLabels = productContextLabels{
+		Header:   "=== RELEVANT PRODUCTS ===",
+		Overflow: "... and %d more products available",
+	}
+	// alternativeProductLabels are used when no exact match was found and the listing falls back
+	// to the most similar products
+	alternativeProductLabels = productContextLabels{
+		Header:   "=== SIMILAR ALTERNATIVES ===",
+		Overflow: "... and %d more alternatives available",
+	}
+)
+
+// productLabels returns the product section strings for a search outcome
+// An empty fallback listing keeps the regular header, under which "no products found" is reported.
+func productLabels(fallbackToSimilarity bool, productCount int) productContextLabels {
+	if fallbackToSimilarity && productCount > 0 {
+		return alternativeProductLabels
+	}
+	return relevantProductLabels
+}
+
+// requireMinResults drops the search results when fewer than minResults passed the similarity and
+// token filters, so the prompt and the escalation heuristics handle the query as a no-match
+func requireMinResults(products []embeddings.ProductEmbedding, minResults int) []embeddings.ProductEmbedding {
+	if len(products) == 0 || len(products) >= minResults {
+		return products
+	}
+	fmt.Printf("[CHAT] Only %d products passed the search filters (MIN_RESULTS_FOR_MATCH=%d), answering as no match\n", len(products), minResults)
+	return nil
+}
+
+// Product context sort modes (CONTEXT_SORT_MODE)
+const (
+	ContextSortSimilarity = "similarity"
+	ContextSortPriceAsc   = "price_asc"
+	ContextSortPriceDesc  = "price_desc"
+	ContextSortStockFirst = "stock_first"
+	ContextSortNewest     = "newest"
+)
+
+// sortProductsForContext orders products before they are shown to the LLM
+// Unknown modes keep the similarity ranking returned by the search
+func sortProductsForContext(products []embeddings.ProductEmbedding, mode string, stockMapping map[string]string) {
+	switch mode {
+	case ContextSortPriceAsc:
+		sort.SliceStable(products, func(i, j int) bool {
+			return productPrice(products[i], math.Inf(1)) < productPrice(products[j], math.Inf(1))
+		})
+	case ContextSortPriceDesc:
+		sort.SliceStable(products, func(i, j int) bool {
+			return productPrice(products[i], math.Inf(-1)) > productPrice(products[j], math.Inf(-1))
+		})
+	case ContextSortStockFirst:
+		sort.SliceStable(products, func(i, j int) bool {
+			return availabilityRank(products[i], stockMapping) < availabilityRank(products[j], stockMapping)
+		})
+	case ContextSortNewest:
+		sort.SliceStable(products, func(i, j int) bool {
+			return isNewer(products[i], products[j])
+		})
+	default:
+		sort.SliceStable(products, func(i, j int) bool {
+			return products[i].Similarity > products[j].Similarity
+		})
+	}
+}
+
+// productPrice returns the product's minimum price, or missing when it has no parsable price
+// Passing +Inf/-Inf as missing keeps unpriced products at the end of either ordering
+func productPrice(product embeddings.ProductEmbedding, missing float64) float64 {
+	if product.Product.MinPrice == nil {
+		return missing
+	}
+	price, err := strconv.ParseFloat(*product.Product.MinPrice, 64)
+	if err != nil {
+		return missing
+	}
+	return price
+}
+
+// stockAvailability maps the product's WooCommerce stock status to an availability value
+// Statuses missing from the mapping fall back to available for instock and unavailable otherwise
+func stockAvailability(product embeddings.ProductEmbedding, stockMapping map[string]string) string {
+	if product.Product.StockStatus == nil {
+		return availabilityUnavailable
+	}
+	status := *product.Product.StockStatus
+	if availability, ok := stockMapping[status]; ok {
+		return availability
+	}
+	if status == stockStatusInStock {
+		return availabilityAvailable
+	}
+	return availabilityUnavailable
+}
+
+// isAvailable reports whether the product can be ordered now (in stock or on backorder)
+func isAvailable(product embeddings.ProductEmbedding, stockMapping map[string]string) bool {
+	availability := stockAvailability(product, stockMapping)
+	return availability == availabilityAvailable || availability == availabilityBackorder
+}
+
+// availabilityRank orders in-stock products before backorders before unavailable ones
+func availabilityRank(product embeddings.ProductEmbedding, stockMapping map[string]string) int {
+	switch stockAvailability(product, stockMapping) {
+	case availabilityAvailable:
+		return 0
+	case availabilityBackorder:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// isNewer reports whether a was published after b; undated products sort last
+func isNewer(a, b embeddings.ProductEmbedding) bool {
+	if a.Product.PublishedAt == nil {
+		return false
+	}
+	if b.Product.PublishedAt == nil {
+		return true
+	}
+	return a.Product.PublishedAt.After(*b.Product.PublishedAt)
+}
+
+// contextProductLimit returns the number of products listed in the LLM context
+func contextProductLimit(maxProducts int) int {
+	if maxProducts <= 0 {
+		return defaultMaxContextProducts
+	}
+	return maxProducts
+}
+
+// productResultCounts returns the post-filter product total and whether the
+// context was truncated, so the frontend can offer "show more"
+func productResultCounts(products []embeddings.ProductEmbedding, maxProducts int) (int, bool) {
+	total := len(products)
+	return total, total > contextProductLimit(maxProducts)
+}
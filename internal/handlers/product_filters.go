@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ids/internal/embeddings"
+	"ids/internal/models"
+)
+
+// parseProductFilters reads the tags (comma-separated), price_min and price_max query parameters
+// It returns nil when none is given.
+func parseProductFilters(values url.Values) (*models.ProductFilters, error) {
+	var filters models.ProductFilters
+	set := false
+
+	if raw := values.Get("tags"); raw != "" {
+		for _, tag := range strings.Split(raw, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filters.Tags = append(filters.Tags, tag)
+			}
+		}
+		set = len(filters.Tags) > 0
+	}
+
+	prices := []struct {
+		name   string
+		target **float64
+	}{
+		{"price_min", &filters.PriceMin},
+		{"price_max", &filters.PriceMax},
+	}
+	for _, price := range prices {
+		raw := values.Get(price.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", price.name, raw)
+		}
+		*price.target = &value
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	return &filters, validateProductFilters(&filters)
+}
+
+// validateProductFilters rejects negative prices and inverted price ranges
+func validateProductFilters(filters *models.ProductFilters) error {
+	if filters == nil {
+		return nil
+	}
+	if filters.PriceMin != nil && *filters.PriceMin < 0 {
+		return fmt.Errorf("price_min cannot be negative")
+	}
+	if filters.PriceMax != nil && *filters.PriceMax < 0 {
+		return fmt.Errorf("price_max cannot be negative")
+	}
+	if filters.PriceMin != nil && filters.PriceMax != nil && *filters.PriceMin > *filters.PriceMax {
+		return fmt.Errorf("price_min cannot exceed price_max")
+	}
+	return nil
+}
+
+// searchFilters converts request filters to the SQL filters of the embedding search
+// StockOnly becomes the stock_status values STOCK_STATUS_MAPPING treats as orderable.
+func searchFilters(filters *models.ProductFilters, stockMapping map[string]string) embeddings.SearchFilters {
+	if filters == nil {
+		return embeddings.SearchFilters{}
+	}
+	search := embeddings.SearchFilters{
+		Tags:     filters.Tags,
+		PriceMin: filters.PriceMin,
+		PriceMax: filters.PriceMax,
+	}
+	if filters.StockOnly {
+		search.StockStatuses = orderableStockStatuses(stockMapping)
+	}
+	return search
+}
+
+// orderableStockStatuses returns the stock_status values isAvailable accepts, sorted
+func orderableStockStatuses(stockMapping map[string]string) []string {
+	var statuses []string
+	for status, availability := range stockMapping {
+		if availability == availabilityAvailable || availability == availabilityBackorder {
+			statuses = append(statuses, status)
+		}
+	}
+	if _, mapped := stockMapping[stockStatusInStock]; !mapped {
+		statuses = append(statuses, stockStatusInStock)
+	}
+	sort.Strings(statuses)
+	return statuses
+}
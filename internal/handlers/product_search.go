@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"ids/internal/config"
+	"ids/internal/embeddings"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Product search endpoint limits
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// productSearcher is the subset of the embedding service used by the search endpoint
+type productSearcher interface {
+	DefaultSearchOptions() embeddings.SearchOptions
+	SearchSimilarProductsWithOptions(ctx context.Context, query string, limit int, opts embeddings.SearchOptions) ([]embeddings.ProductEmbedding, bool, error)
+}
+
+// productSearchParams holds the parsed and clamped search request
+type productSearchParams struct {
+	Query       string
+	Limit       int
+	Search      embeddings.SearchOptions
+	InStockOnly bool
+	Filters     *models.ProductFilters // Facet filters from the query, nil when none is given
+	Debug       bool                   // Return the relevance trace
+}
+
+// parseProductSearchParams reads the search query and per-request overrides, using the
+// config defaults for anything not given. Invalid booleans or numbers are rejected;
+// out-of-range limits and similarities are clamped.
+func parseProductSearchParams(values url.Values, defaults embeddings.SearchOptions, cfg *config.Config) (productSearchParams, error) {
+	params := productSearchParams{
+		Query:       strings.TrimSpace(values.Get("q")),
+		Limit:       defaultSearchLimit,
+		Search:      defaults,
+		InStockOnly: cfg.SearchInStockOnly,
+	}
+	params.Search.MinSimilarity = cfg.SearchMinSimilarity
+
+	if params.Query == "" {
+		return params, fmt.Errorf("query parameter 'q' is required")
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return params, fmt.Errorf("invalid limit: %q", raw)
+		}
+		params.Limit = min(max(limit, 1), maxSearchLimit)
+	}
+
+	boolOverrides := []struct {
+		name   string
+		target *bool
+	}{
+		{"boost", &params.Search.Boosting},
+		{"token_filter", &params.Search.TokenFiltering},
+		{"in_stock_only", &params.InStockOnly},
+		{"debug", &params.Debug},
+	}
+	for _, override := range boolOverrides {
+		raw := values.Get(override.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return params, fmt.Errorf("invalid %s: %q", override.name, raw)
+		}
+		*override.target = value
+	}
+
+	if raw := values.Get("min_similarity"); raw != "" {
+		minSimilarity, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return params, fmt.Errorf("invalid min_similarity: %q", raw)
+		}
+		params.Search.MinSimilarity = minSimilarity
+	}
+	params.Search.MinSimilarity = min(max(params.Search.MinSimilarity, 0), 1)
+
+	filters, err := parseProductFilters(values)
+	if err != nil {
+		return params, err
+	}
+	params.Filters = filters
+	// in_stock_only is pushed into the SQL filters so out-of-stock products don't use up the limit
+	if params.InStockOnly {
+		if params.Filters == nil {
+			params.Filters = &models.ProductFilters{}
+		}
+		params.Filters.StockOnly = true
+	}
+	params.Search.Filters = searchFilters(params.Filters, cfg.StockStatusMapping)
+
+	return params, nil
+}
+
+// ProductSearchHandler handles JSON product search requests
+// @Summary Search products
+// @Description Vector search over product embeddings. Query parameters override the configured search behavior for this request only.
+// @Tags search
+// @Produce json
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum results (1-50)" default(10)
+// @Param boost query bool false "Apply keyword and tag phrase boosts on top of vector similarity (default ENABLE_TERM_BOOSTING)"
+// @Param token_filter query bool false "Apply token filtering (default ENABLE_TOKEN_FILTERING)"
+// @Param min_similarity query number false "Minimum similarity, clamped to 0-1 (default SEARCH_MIN_SIMILARITY)"
+// @Param in_stock_only query bool false "Only return in-stock or backorderable products (default SEARCH_IN_STOCK_ONLY)"
+// @Param tags query string false "Only return products carrying one of these comma-separated tags"
+// @Param price_min query number false "Only return products priced at or above this"
+// @Param price_max query number false "Only return products priced at or below this"
+// @Param debug query bool false "Return the relevance trace (requires SEARCH_DEBUG_ENABLED)"
+// @Success 200 {object} models.ProductSearchResponse
+// @Failure 400 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/products/search [get]
+func ProductSearchHandler(cfg *config.Config, searcher productSearcher) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		params, err := parseProductSearchParams(c.QueryParams(), searcher.DefaultSearchOptions(), cfg)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+		if params.Debug {
+			if !cfg.SearchDebugEnabled {
+				return respondError(c, http.StatusForbidden, "Search debug traces are disabled (SEARCH_DEBUG_ENABLED)")
+			}
+			params.Search.Trace = &embeddings.SearchTrace{}
+		}
+
+		products, fallbackToSimilarity, err := searcher.SearchSimilarProductsWithOptions(c.Request().Context(), params.Query, params.Limit, params.Search)
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to search products: %v", err))
+		}
+
+		results := make([]models.ProductSearchResult, 0, len(products))
+		for _, product := range products {
+			if params.InStockOnly && !isAvailable(product, cfg.StockStatusMapping) {
+				continue
+			}
+			results = append(results, models.ProductSearchResult{
+				Product:    product.Product,
+				Similarity: product.Similarity,
+			})
+		}
+
+		var debug *models.ProductSearchDebug
+		if params.Search.Trace != nil {
+			debug = searchDebug(params.Search.Trace, results)
+		}
+
+		return c.JSON(http.StatusOK, models.ProductSearchResponse{
+			Query:                params.Query,
+			Results:              results,
+			Total:                len(results),
+			FallbackToSimilarity: fallbackToSimilarity,
+			Options: models.ProductSearchOptions{
+				Boosting:       params.Search.Boosting,
+				TokenFiltering: params.Search.TokenFiltering,
+				MinSimilarity:  params.Search.MinSimilarity,
+				InStockOnly:    params.InStockOnly,
+				Filters:        params.Filters,
+			},
+			Debug: debug,
+		})
+	}
+}
+
+// searchDebug builds the debug block from the search trace for the returned results
+func searchDebug(trace *embeddings.SearchTrace, results []models.ProductSearchResult) *models.ProductSearchDebug {
+	debug := &models.ProductSearchDebug{
+		Backend:              trace.Backend,
+		ExpandedTokens:       append([]string{}, trace.QueryTokens...),
+		RequiredTokens:       append([]string{}, trace.RequiredTokens...),
+		FallbackToSimilarity: trace.FallbackToSimilarity,
+		Results:              make([]models.ProductSearchResultDebug, 0, len(results)),
+	}
+	for _, result := range results {
+		scoring := trace.Results[result.Product.ID]
+		debug.Results = append(debug.Results, models.ProductSearchResultDebug{
+			ProductID:     result.Product.ID,
+			Distance:      scoring.Distance,
+			RawSimilarity: scoring.RawSimilarity,
+			Boost:         scoring.Boost,
+		})
+	}
+	return debug
+}
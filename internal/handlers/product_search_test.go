@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ids/internal/config"
+	"ids/internal/embeddings"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProductSearcher records the options it was called with and returns fixed products
+type fakeProductSearcher struct {
+	defaults embeddings.SearchOptions
+	products []embeddings.ProductEmbedding
+	err      error
+
+	calledLimit int
+	calledOpts  embeddings.SearchOptions
+}
+
+func (f *fakeProductSearcher) DefaultSearchOptions() embeddings.SearchOptions {
+	return f.defaults
+}
+
+func (f *fakeProductSearcher) SearchSimilarProductsWithOptions(ctx context.Context, query string, limit int, opts embeddings.SearchOptions) ([]embeddings.ProductEmbedding, bool, error) {
+	f.calledLimit = limit
+	f.calledOpts = opts
+	if opts.Trace != nil {
+		*opts.Trace = embeddings.SearchTrace{
+			Backend:        "pgvector",
+			QueryTokens:    []string{"glock", "holster"},
+			RequiredTokens: []string{"glock"},
+			Results: map[int]embeddings.ResultTrace{
+				1: {Distance: 0.3, RawSimilarity: 0.7, Boost: 0.2},
+				2: {Distance: 0.25, RawSimilarity: 0.75},
+			},
+		}
+	}
+	return f.products, false, f.err
+}
+
+func performProductSearch(t *testing.T, cfg *config.Config, searcher productSearcher, rawQuery string) (*httptest.ResponseRecorder, models.ProductSearchResponse) {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/products/search?"+rawQuery, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	require.NoError(t, ProductSearchHandler(cfg, searcher)(c))
+
+	var resp models.ProductSearchResponse
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp
+}
+
+func TestProductSearchHandler_Overrides(t *testing.T) {
+	cfg := &config.Config{SearchMinSimilarity: 0.2, StockStatusMapping: testStockMapping}
+	defaults := embeddings.SearchOptions{Boosting: true, TokenFiltering: true}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedLimit int
+		expectedOpts  embeddings.SearchOptions
+		expectedIDs   []int
+	}{
+		{
+			name:          "config defaults",
+			query:         "q=holster",
+			expectedLimit: defaultSearchLimit,
+			expectedOpts:  embeddings.SearchOptions{Boosting: true, TokenFiltering: true, MinSimilarity: 0.2},
+			expectedIDs:   []int{1, 2, 3, 4},
+		},
+		{
+			name:          "pure vector search",
+			query:         "q=holster&boost=false&token_filter=false&min_similarity=0",
+			expectedLimit: defaultSearchLimit,
+			expectedOpts:  embeddings.SearchOptions{Boosting: false, TokenFiltering: false, MinSimilarity: 0},
+			expectedIDs:   []int{1, 2, 3, 4},
+		},
+		{
+			name:          "in stock only keeps backorders",
+			query:         "q=holster&in_stock_only=true&limit=5",
+			expectedLimit: 5,
+			expectedOpts: embeddings.SearchOptions{Boosting: true, TokenFiltering: true, MinSimilarity: 0.2,
+				Filters: embeddings.SearchFilters{StockStatuses: []string{"instock", "onbackorder"}}},
+			expectedIDs: []int{2, 3},
+		},
+		{
+			name:          "out of range values are clamped",
+			query:         "q=holster&limit=500&min_similarity=1.7&token_filter=0",
+			expectedLimit: maxSearchLimit,
+			expectedOpts:  embeddings.SearchOptions{Boosting: true, TokenFiltering: false, MinSimilarity: 1},
+			expectedIDs:   []int{1, 2, 3, 4},
+		},
+		{
+			name:          "negative values are clamped",
+			query:         "q=holster&limit=-3&min_similarity=-0.5",
+			expectedLimit: 1,
+			expectedOpts:  embeddings.SearchOptions{Boosting: true, TokenFiltering: true, MinSimilarity: 0},
+			expectedIDs:   []int{1, 2, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searcher := &fakeProductSearcher{
+				defaults: defaults,
+				products: []embeddings.ProductEmbedding{
+					{Product: models.Product{ID: 1, StockStatus: strPtr("outofstock")}, Similarity: 0.9},
+					{Product: models.Product{ID: 2, StockStatus: strPtr("instock")}, Similarity: 0.8},
+					{Product: models.Product{ID: 3, StockStatus: strPtr("onbackorder")}, Similarity: 0.7},
+					{Product: models.Product{ID: 4}, Similarity: 0.6},
+				},
+			}
+
+			rec, resp := performProductSearch(t, cfg, searcher, tt.query)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			assert.Equal(t, tt.expectedLimit, searcher.calledLimit)
+			assert.Equal(t, tt.expectedOpts, searcher.calledOpts)
+			assert.Equal(t, tt.expectedOpts.MinSimilarity, resp.Options.MinSimilarity)
+			assert.Equal(t, tt.expectedOpts.Boosting, resp.Options.Boosting)
+			assert.Equal(t, tt.expectedOpts.TokenFiltering, resp.Options.TokenFiltering)
+
+			ids := make([]int, len(resp.Results))
+			for i, result := range resp.Results {
+				ids[i] = result.Product.ID
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, len(tt.expectedIDs), resp.Total)
+		})
+	}
+}
+
+func TestProductSearchHandler_InvalidRequests(t *testing.T) {
+	cfg := &config.Config{}
+
+	invalid := []string{"", "q=%20", "q=holster&boost=maybe", "q=holster&limit=ten", "q=holster&min_similarity=high",
+		"q=holster&price_min=cheap", "q=holster&price_max=-1", "q=holster&price_min=200&price_max=100"}
+	for _, query := range invalid {
+		t.Run(query, func(t *testing.T) {
+			searcher := &fakeProductSearcher{}
+			rec, _ := performProductSearch(t, cfg, searcher, query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Zero(t, searcher.calledLimit)
+		})
+	}
+}
+
+func TestProductSearchHandler_Filters(t *testing.T) {
+	cfg := &config.Config{StockStatusMapping: testStockMapping}
+	searcher := &fakeProductSearcher{}
+
+	rec, resp := performProductSearch(t, cfg, searcher, "q=holster&tags=Holster,%20OWB,&price_min=50&price_max=150&in_stock_only=true")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	priceMin, priceMax := 50.0, 150.0
+	assert.Equal(t, embeddings.SearchFilters{
+		Tags:          []string{"Holster", "OWB"},
+		StockStatuses: []string{"instock", "onbackorder"},
+		PriceMin:      &priceMin,
+		PriceMax:      &priceMax,
+	}, searcher.calledOpts.Filters)
+	assert.Equal(t, &models.ProductFilters{
+		Tags:      []string{"Holster", "OWB"},
+		StockOnly: true,
+		PriceMin:  &priceMin,
+		PriceMax:  &priceMax,
+	}, resp.Options.Filters)
+
+	rec, resp = performProductSearch(t, cfg, searcher, "q=holster&tags=,")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, searcher.calledOpts.Filters.IsEmpty())
+	assert.Nil(t, resp.Options.Filters)
+}
+
+func TestOrderableStockStatuses(t *testing.T) {
+	assert.Equal(t, []string{"instock", "onbackorder"}, orderableStockStatuses(testStockMapping))
+	assert.Equal(t, []string{"instock"}, orderableStockStatuses(nil))
+	assert.Equal(t, []string{"onbackorder"}, orderableStockStatuses(map[string]string{
+		"instock":     availabilityUnavailable,
+		"onbackorder": availabilityAvailable,
+	}))
+}
+
+func TestProductSearchHandler_Debug(t *testing.T) {
+	products := []embeddings.ProductEmbedding{
+		{Product: models.Product{ID: 1}, Similarity: 0.9},
+		{Product: models.Product{ID: 2}, Similarity: 0.75},
+	}
+
+	t.Run("disabled by config", func(t *testing.T) {
+		searcher := &fakeProductSearcher{products: products}
+		rec, _ := performProductSearch(t, &config.Config{}, searcher, "q=glock+holster&debug=true")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Zero(t, searcher.calledLimit)
+	})
+
+	t.Run("omitted without debug", func(t *testing.T) {
+		searcher := &fakeProductSearcher{products: products}
+		rec, resp := performProductSearch(t, &config.Config{SearchDebugEnabled: true}, searcher, "q=glock+holster")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, searcher.calledOpts.Trace)
+		assert.Nil(t, resp.Debug)
+		assert.NotContains(t, rec.Body.String(), `"debug"`)
+	})
+
+	t.Run("debug block shape", func(t *testing.T) {
+		searcher := &fakeProductSearcher{products: products}
+		rec, _ := performProductSearch(t, &config.Config{SearchDebugEnabled: true}, searcher, "q=glock+holster&debug=true")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Debug map[string]json.RawMessage `json:"debug"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.JSONEq(t, `"pgvector"`, string(body.Debug["backend"]))
+		assert.JSONEq(t, `["glock","holster"]`, string(body.Debug["expanded_tokens"]))
+		assert.JSONEq(t, `["glock"]`, string(body.Debug["required_tokens"]))
+		assert.JSONEq(t, `false`, string(body.Debug["fallback_to_similarity"]))
+		assert.JSONEq(t, `[
+			{"product_id": 1, "distance": 0.3, "raw_similarity": 0.7, "boost": 0.2},
+			{"product_id": 2, "distance": 0.25, "raw_similarity": 0.75, "boost": 0}
+		]`, string(body.Debug["results"]))
+		assert.Len(t, body.Debug, 5)
+	})
+}
+
+func TestProductSearchHandler_SearchError(t *testing.T) {
+	searcher := &fakeProductSearcher{err: errors.New("pgvector unavailable")}
+	rec, _ := performProductSearch(t, &config.Config{}, searcher, "q=holster")
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "pgvector unavailable")
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rateLimitSweepInterval is how often idle buckets are swept from the limiter
+const rateLimitSweepInterval = time.Minute
+
+// chatRateLimitMessage is returned with 429 once a conversation exceeds CHAT_RATE_LIMIT_PER_MINUTE
+const chatRateLimitMessage = "Too many chat requests. Please wait a moment and try again."
+
+// tokenBucket is the request allowance of one conversation
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// ChatRateLimiter enforces a per-conversation requests-per-minute limit with token buckets
+// Buckets hold up to perMinute requests and refill continuously at perMinute per minute.
+type ChatRateLimiter struct {
+	perMinute int
+	now       func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewChatRateLimiter creates a limiter allowing perMinute requests per conversation (0 disables it)
+func NewChatRateLimiter(perMinute int) *ChatRateLimiter {
+	return &ChatRateLimiter{
+		perMinute: max(perMinute, 0),
+		now:       time.Now,
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes one request from key's bucket
+// When the bucket is empty it returns false and how long until the next request is allowed.
+func (l *ChatRateLimiter) Allow(key string) (bool, time.Duration) {
+	if l.perMinute == 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	capacity := float64(l.perMinute)
+	refillPerSecond := capacity / 60
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: capacity}
+		l.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*refillPerSecond)
+	}
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / refillPerSecond * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops buckets idle long enough to have refilled completely, which behave like new ones
+// Runs at most once per rateLimitSweepInterval so expired sessions don't accumulate. Callers hold mu.
+func (l *ChatRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of conversations currently tracked
+func (l *ChatRateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Middleware rejects chat requests over the limit with 429 and a Retry-After header
+// Requests are keyed by the body's session_id, falling back to the client IP when it is absent.
+func (l *ChatRateLimiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l.perMinute == 0 {
+				return next(c)
+			}
+
+			key := rateLimitKey(c)
+			allowed, wait := l.Allow(key)
+			if !allowed {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				fmt.Printf("[RATE_LIMIT] Rejected chat request from %s, retry after %ds\n", key, retryAfter)
+				c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				return respondError(c, http.StatusTooManyRequests, chatRateLimitMessage)
+			}
+			return next(c)
+		}
+	}
+}
+
+// rateLimitKey returns "session:<id>" for requests carrying a session_id, else "ip:<client IP>"
+// The body is restored so the handler can bind it afterwards.
+func rateLimitKey(c echo.Context) string {
+	req := c.Request()
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		var payload struct {
+			SessionID string `json:"session_id"`
+		}
+		if err == nil && json.Unmarshal(body, &payload) == nil && payload.SessionID != "" {
+			return "session:" + payload.SessionID
+		}
+	}
+	return "ip:" + c.RealIP()
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRateLimiter returns a limiter whose clock only moves when advance is called
+func newTestRateLimiter(perMinute int) (*ChatRateLimiter, func(time.Duration)) {
+	limiter := NewChatRateLimiter(perMinute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestChatRateLimiter_RefillsOverTime(t *testing.T) {
+	limiter, advance := newTestRateLimiter(3)
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("session:a")
+		require.True(t, allowed, "request %d", i+1)
+	}
+	allowed, wait := limiter.Allow("session:a")
+	assert.False(t, allowed)
+	assert.Equal(t, 20*time.Second, wait)
+
+	// Other conversations have their own bucket
+	allowed, _ = limiter.Allow("session:b")
+	assert.True(t, allowed)
+
+	advance(20 * time.Second)
+	allowed, _ = limiter.Allow("session:a")
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow("session:a")
+	assert.False(t, allowed)
+}
+
+func TestChatRateLimiter_SweepsIdleBuckets(t *testing.T) {
+	limiter, advance := newTestRateLimiter(5)
+
+	limiter.Allow("session:a")
+	limiter.Allow("session:b")
+	advance(30 * time.Second)
+	limiter.Allow("session:b")
+	assert.Equal(t, 2, limiter.Len())
+
+	advance(45 * time.Second)
+	limiter.Allow("session:c")
+	assert.Equal(t, 2, limiter.Len(), "session:a has been idle for over a minute")
+}
+
+func TestChatRateLimiter_ZeroDisables(t *testing.T) {
+	limiter, _ := newTestRateLimiter(0)
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow("session:a")
+		require.True(t, allowed)
+	}
+	assert.Equal(t, 0, limiter.Len())
+}
+
+func TestChatRateLimiter_MiddlewareKeysBySessionThenIP(t *testing.T) {
+	limiter, _ := newTestRateLimiter(1)
+	var boundSessions []string
+	handler := limiter.Middleware()(func(c echo.Context) error {
+		var req struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		boundSessions = append(boundSessions, req.SessionID)
+		return c.NoContent(http.StatusOK)
+	})
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = "203.0.113.7:5555"
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler(e.NewContext(req, rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, serve(`{"message":"hi","session_id":"s1"}`).Code)
+	assert.Equal(t, http.StatusOK, serve(`{"message":"hi","session_id":"s2"}`).Code)
+	assert.Equal(t, http.StatusOK, serve(`{"message":"hi"}`).Code)
+	assert.Equal(t, []string{"s1", "s2", ""}, boundSessions, "the body is still readable by the handler")
+
+	rec := serve(`{"message":"again","session_id":"s1"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), chatRateLimitMessage)
+
+	// No session_id falls back to the client IP, which already used its request
+	rec = serve(`{"message":"again"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
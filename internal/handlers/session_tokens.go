@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+
+	"ids/internal/analytics"
+	"ids/internal/cache"
+)
+
+// sessionLimitMessage is returned instead of a reply once a session used up MAX_SESSION_TOKENS
+const sessionLimitMessage = "Thanks for chatting with us! This conversation has reached its session limit. Please start a new chat to continue, or contact our support team and we'll be happy to help."
+
+// sessionTokenCapReached reports whether a session already used maxTokens or more
+// A zero cap, an empty session ID or a missing cache disables the check
+func sessionTokenCapReached(store *cache.Cache, sessionID string, maxTokens int) (int, bool) {
+	if maxTokens <= 0 || sessionID == "" || store == nil {
+		return 0, false
+	}
+	used := store.SessionTokens(sessionID)
+	return used, used >= maxTokens
+}
+
+// recordSessionTokens adds a reply's OpenAI tokens to the session total when the cap is enabled
+func recordSessionTokens(store *cache.Cache, sessionID string, maxTokens int, tokens int) {
+	if maxTokens <= 0 || sessionID == "" || store == nil || tokens <= 0 {
+		return
+	}
+	total := store.AddSessionTokens(sessionID, tokens)
+	fmt.Printf("[CHAT] Session %s has used %d/%d tokens\n", sessionID, total, maxTokens)
+}
+
+// trackSessionTokenCap records a request refused by the session token cap in the background
+func trackSessionTokenCap(analyticsService *analytics.Service, sessionTokens int, maxTokens int) {
+	if analyticsService == nil {
+		return
+	}
+	go func() {
+		if err := analyticsService.TrackSessionTokenCap(sessionTokens, maxTokens); err != nil {
+			fmt.Printf("[CHAT] Warning: Failed to track session token cap: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"ids/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionTokenCap_DrivesSessionOverCap(t *testing.T) {
+	store := cache.New()
+	const maxTokens = 1000
+
+	// Each reply is allowed while the session is under the cap
+	for i := 0; i < 3; i++ {
+		_, capped := sessionTokenCapReached(store, "session-1", maxTokens)
+		assert.False(t, capped, "reply %d should be allowed", i+1)
+		recordSessionTokens(store, "session-1", maxTokens, 400)
+	}
+
+	used, capped := sessionTokenCapReached(store, "session-1", maxTokens)
+	assert.True(t, capped)
+	assert.Equal(t, 1200, used)
+
+	// Other sessions keep their own budget
+	_, capped = sessionTokenCapReached(store, "session-2", maxTokens)
+	assert.False(t, capped)
+}
+
+func TestSessionTokenCap_Disabled(t *testing.T) {
+	store := cache.New()
+	store.AddSessionTokens("session-1", 5000)
+
+	_, capped := sessionTokenCapReached(store, "session-1", 0)
+	assert.False(t, capped, "zero cap disables the check")
+
+	_, capped = sessionTokenCapReached(store, "", 1000)
+	assert.False(t, capped, "requests without a session are never capped")
+
+	_, capped = sessionTokenCapReached(nil, "session-1", 1000)
+	assert.False(t, capped, "nil cache disables the check")
+
+	// Nothing is recorded while the cap is off
+	recordSessionTokens(store, "session-2", 0, 400)
+	assert.Equal(t, 0, store.SessionTokens("session-2"))
+}
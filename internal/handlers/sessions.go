@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Session history pagination limits
+const (
+	defaultSessionPageSize = 20
+	maxSessionPageSize     = 100
+)
+
+// sessionStore is the subset of the conversation service used by the session history endpoints
+type sessionStore interface {
+	GetSessionDetails(sessionID string) (*models.ChatSessionDetail, error)
+	ListSessions(limit, offset int) ([]models.ChatSession, database.PageInfo, error)
+}
+
+// parseSessionPage reads the limit and offset query parameters, rejecting invalid numbers
+// Limits are clamped to 1-100 and negative offsets to 0.
+func parseSessionPage(c echo.Context) (int, int, error) {
+	limit, offset := defaultSessionPageSize, 0
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid limit: %q", raw)
+		}
+		limit = min(max(parsed, 1), maxSessionPageSize)
+	}
+
+	if raw := c.QueryParam("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid offset: %q", raw)
+		}
+		offset = max(parsed, 0)
+	}
+
+	return limit, offset, nil
+}
+
+// SessionHistoryHandler returns a chat session with its messages in conversation order
+// @Summary Get conversation history
+// @Description Get a chat session's metadata and ordered messages, so the frontend can restore a conversation
+// @Tags sessions
+// @Produce json
+// @Param session_id path string true "Session ID (UUID)"
+// @Success 200 {object} models.ChatSessionDetail
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/sessions/{session_id} [get]
+func SessionHistoryHandler(store sessionStore) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sessionID := c.Param("session_id")
+
+		detail, err := store.GetSessionDetails(sessionID)
+		if errors.Is(err, database.ErrSessionNotFound) {
+			return respondError(c, http.StatusNotFound, "Session not found")
+		}
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to get session: %v", err))
+		}
+
+		// The support email is for staff; email_sent still tells the client one went out
+		detail.EmailHTML = nil
+		return c.JSON(http.StatusOK, detail)
+	}
+}
+
+// SessionListHandler returns a page of chat sessions, newest first
+// @Summary List conversations
+// @Description Get a paginated list of chat sessions with their message counts
+// @Tags sessions
+// @Produce json
+// @Param limit query int false "Number of sessions per page (1-100)" default(20)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} models.SessionListResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/sessions [get]
+func SessionListHandler(store sessionStore) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, offset, err := parseSessionPage(c)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, err.Error())
+		}
+
+		sessions, page, err := store.ListSessions(limit, offset)
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to list sessions: %v", err))
+		}
+
+		return c.JSON(http.StatusOK, models.SessionListResponse{
+			Sessions: sessions,
+			Total:    page.Total,
+			Limit:    page.Limit,
+			Offset:   page.Offset,
+			HasMore:  page.HasMore,
+		})
+	}
+}
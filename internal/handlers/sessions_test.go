@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ids/internal/database"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSessionStore serves fixed sessions and records the requested page
+type fakeSessionStore struct {
+	details  map[string]*models.ChatSessionDetail
+	sessions []models.ChatSession
+	total    int
+	err      error
+
+	calledLimit  int
+	calledOffset int
+}
+
+func (f *fakeSessionStore) GetSessionDetails(sessionID string) (*models.ChatSessionDetail, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	detail, ok := f.details[sessionID]
+	if !ok {
+		return nil, database.ErrSessionNotFound
+	}
+	return detail, nil
+}
+
+func (f *fakeSessionStore) ListSessions(limit, offset int) ([]models.ChatSession, database.PageInfo, error) {
+	f.calledLimit, f.calledOffset = limit, offset
+	if f.err != nil {
+		return nil, database.PageInfo{}, f.err
+	}
+	return f.sessions, database.NewPageInfo(f.total, limit, offset), nil
+}
+
+func performSessionRequest(t *testing.T, handler echo.HandlerFunc, target, sessionID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+	if sessionID != "" {
+		c.SetParamNames("session_id")
+		c.SetParamValues(sessionID)
+	}
+	require.NoError(t, handler(c))
+	return rec
+}
+
+func TestSessionHistoryHandler(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	emailHTML := "<p>support</p>"
+	store := &fakeSessionStore{details: map[string]*models.ChatSessionDetail{
+		"session-1": {
+			Session: models.ChatSession{ID: 1, SessionID: "session-1", CreatedAt: createdAt, UpdatedAt: createdAt, EmailSent: true, MessageCount: 2},
+			Messages: []models.SessionMessage{
+				{ID: 10, SessionID: "session-1", Role: "user", Message: "Do you have Glock holsters?", CreatedAt: createdAt},
+				{ID: 11, SessionID: "session-1", Role: "assistant", Message: "Yes, we have several.", CreatedAt: createdAt},
+			},
+			EmailHTML: &emailHTML,
+		},
+		"session-empty": {
+			Session:  models.ChatSession{ID: 2, SessionID: "session-empty", CreatedAt: createdAt, UpdatedAt: createdAt},
+			Messages: []models.SessionMessage{},
+		},
+	}}
+
+	t.Run("session with messages", func(t *testing.T) {
+		rec := performSessionRequest(t, SessionHistoryHandler(store), "/api/sessions/session-1", "session-1")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var detail models.ChatSessionDetail
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+		assert.Equal(t, 2, detail.Session.MessageCount)
+		assert.True(t, detail.Session.EmailSent)
+		require.Len(t, detail.Messages, 2)
+		assert.Equal(t, "user", detail.Messages[0].Role)
+		assert.Equal(t, "assistant", detail.Messages[1].Role)
+		assert.NotContains(t, rec.Body.String(), "email_html")
+	})
+
+	t.Run("session without messages", func(t *testing.T) {
+		rec := performSessionRequest(t, SessionHistoryHandler(store), "/api/sessions/session-empty", "session-empty")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"messages":[]`)
+		assert.Contains(t, rec.Body.String(), `"message_count":0`)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		rec := performSessionRequest(t, SessionHistoryHandler(store), "/api/sessions/missing", "missing")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		failing := &fakeSessionStore{err: errors.New("connection refused")}
+		rec := performSessionRequest(t, SessionHistoryHandler(failing), "/api/sessions/session-1", "session-1")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestSessionListHandler(t *testing.T) {
+	t.Run("pagination", func(t *testing.T) {
+		store := &fakeSessionStore{
+			sessions: []models.ChatSession{{ID: 3, SessionID: "session-3", EmailSent: true, MessageCount: 4}},
+			total:    3,
+		}
+		rec := performSessionRequest(t, SessionListHandler(store), "/api/sessions?limit=1&offset=1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp models.SessionListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 1, store.calledLimit)
+		assert.Equal(t, 1, store.calledOffset)
+		assert.Equal(t, 3, resp.Total)
+		assert.True(t, resp.HasMore)
+		require.Len(t, resp.Sessions, 1)
+		assert.Equal(t, 4, resp.Sessions[0].MessageCount)
+		assert.True(t, resp.Sessions[0].EmailSent)
+	})
+
+	t.Run("no sessions", func(t *testing.T) {
+		store := &fakeSessionStore{sessions: []models.ChatSession{}}
+		rec := performSessionRequest(t, SessionListHandler(store), "/api/sessions", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"sessions":[]`)
+		assert.Contains(t, rec.Body.String(), `"has_more":false`)
+		assert.Equal(t, defaultSessionPageSize, store.calledLimit)
+	})
+
+	t.Run("out of range values are clamped", func(t *testing.T) {
+		store := &fakeSessionStore{sessions: []models.ChatSession{}}
+		rec := performSessionRequest(t, SessionListHandler(store), "/api/sessions?limit=500&offset=-5", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, maxSessionPageSize, store.calledLimit)
+		assert.Zero(t, store.calledOffset)
+	})
+
+	for _, query := range []string{"limit=ten", "offset=first"} {
+		t.Run(fmt.Sprintf("invalid %s", query), func(t *testing.T) {
+			store := &fakeSessionStore{}
+			rec := performSessionRequest(t, SessionListHandler(store), "/api/sessions?"+query, "")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Zero(t, store.calledLimit)
+		})
+	}
+}
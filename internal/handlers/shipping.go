@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"strings"
+	"sync"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
-// ShippingPolicyResponse is the canned response for shipping inquiries
-const ShippingPolicyResponse = `Hi,
+// shippingPolicyTemplate is the canned response for shipping inquiries
+// Placeholders are filled from the shipping config and the region of the detected country
+const shippingPolicyTemplate = `Hi,
 
 Thank you for your message and for your interest in our products.
 
@@ -17,12 +22,184 @@ Yes, we can ship to [COUNTRY]. However, as outlined in our **Shipping Policy**,
 Unfortunately, we do not have control over these charges and cannot predict their exact amount, as they vary from country to country. We always recommend **checking with your local customs office before placing an order** — especially for items like firearm conversion kits, which may be subject to additional scrutiny or regulation.
 
 **Shipping Times:**
-- **Standard Shipping:** Usually takes 14-21 business days
-- **Express Shipping (EMS):** Usually takes 5-10 business days
+- **[STANDARD_CARRIER]:** Usually takes [STANDARD_TIME]
+- **[EXPRESS_CARRIER]:** Usually takes [EXPRESS_TIME]
 
 If you have any other questions or need help placing your order, we're happy to assist.
 
-For our full shipping policy, please visit: https://israeldefensestore.com/shipping-policy`
+For our full shipping policy, please visit: [POLICY_URL]`
+
+// ShippingRegion groups countries that share transit time estimates
+type ShippingRegion struct {
+	Name         string   `json:"name"`
+	StandardTime string   `json:"standard_time,omitempty"` // Overrides ShippingConfig.StandardTime for this region
+	ExpressTime  string   `json:"express_time,omitempty"`  // Overrides ShippingConfig.ExpressTime for this region
+	Countries    []string `json:"countries"`               // Lowercase names matched in the message, in priority order
+}
+
+// ShippingConfig is the store-specific data behind shipping answers (SHIPPING_CONFIG_FILE)
+type ShippingConfig struct {
+	StandardCarrier string           `json:"standard_carrier"`
+	ExpressCarrier  string           `json:"express_carrier"`
+	StandardTime    string           `json:"standard_time"` // Default standard transit estimate
+	ExpressTime     string           `json:"express_time"`  // Default express transit estimate
+	PolicyURL       string           `json:"policy_url"`
+	Regions         []ShippingRegion `json:"regions"`
+}
+
+// defaultShippingConfig is the bundled shipping data, used when no SHIPPING_CONFIG_FILE is set
+var defaultShippingConfig = ShippingConfig{
+	StandardCarrier: "Standard Shipping",
+	ExpressCarrier:  "Express Shipping (EMS)",
+	StandardTime:    "14-21 business days",
+	ExpressTime:     "5-10 business days",
+	PolicyURL:       "https://israeldefensestore.com/shipping-policy",
+	Regions: []ShippingRegion{
+		{
+			Name: "international",
+			Countries: []string{
+				"ecuador", "usa", "united states", "uk", "united kingdom", "canada", "australia",
+				"germany", "france", "italy", "spain", "brazil", "argentina", "chile", "mexico",
+				"thailand", "philippines", "japan", "korea", "south korea", "india", "china",
+				"israel", "netherlands", "belgium", "sweden", "norway", "denmark", "finland",
+				"poland", "portugal", "greece", "turkey", "switzerland", "austria", "ireland",
+				"new zealand", "singapore", "malaysia", "indonesia", "vietnam", "taiwan",
+				"hong kong", "uae", "saudi arabia", "south africa", "egypt", "peru", "colombia",
+			},
+		},
+	},
+}
+
+// ShippingPolicyResponse is the canned response rendered with the bundled shipping data,
+// with [COUNTRY] left in place for the detected country
+var ShippingPolicyResponse = defaultShippingConfig.render("[COUNTRY]", nil)
+
+var (
+	shippingMu     sync.RWMutex
+	activeShipping = defaultShippingConfig
+)
+
+// LoadShippingConfig replaces the bundled shipping data with the JSON file at path
+// An empty path keeps the bundled default. Carrier, time and URL fields left empty in the
+// file fall back to the bundled values.
+func LoadShippingConfig(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read shipping config file %s: %v", path, err)
+	}
+
+	var loaded ShippingConfig
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse shipping config file %s: %v", path, err)
+	}
+	if len(loaded.Regions) == 0 {
+		return fmt.Errorf("shipping config file %s lists no regions", path)
+	}
+
+	setShippingConfig(loaded.withDefaults(defaultShippingConfig))
+	fmt.Printf("[SHIPPING] Loaded %d shipping regions from %s\n", len(loaded.Regions), path)
+	return nil
+}
+
+// setShippingConfig swaps the shipping data used by IsShippingInquiry and GetShippingResponse
+func setShippingConfig(cfg ShippingConfig) {
+	shippingMu.Lock()
+	defer shippingMu.Unlock()
+	activeShipping = cfg
+}
+
+// currentShippingConfig returns the shipping data in use
+func currentShippingConfig() ShippingConfig {
+	shippingMu.RLock()
+	defer shippingMu.RUnlock()
+	return activeShipping
+}
+
+// withDefaults fills empty top-level fields from fallback and normalizes country names
+func (sc ShippingConfig) withDefaults(fallback ShippingConfig) ShippingConfig {
+	if sc.StandardCarrier == "" {
+		sc.StandardCarrier = fallback.StandardCarrier
+	}
+	if sc.ExpressCarrier == "" {
+		sc.ExpressCarrier = fallback.ExpressCarrier
+	}
+	if sc.StandardTime == "" {
+		sc.StandardTime = fallback.StandardTime
+	}
+	if sc.ExpressTime == "" {
+		sc.ExpressTime = fallback.ExpressTime
+	}
+	if sc.PolicyURL == "" {
+		sc.PolicyURL = fallback.PolicyURL
+	}
+
+	regions := make([]ShippingRegion, len(sc.Regions))
+	for i, region := range sc.Regions {
+		countries := make([]string, 0, len(region.Countries))
+		for _, country := range region.Countries {
+			if country = strings.ToLower(strings.TrimSpace(country)); country != "" {
+				countries = append(countries, country)
+			}
+		}
+		region.Countries = countries
+		regions[i] = region
+	}
+	sc.Regions = regions
+	return sc
+}
+
+// findCountry returns the first configured country mentioned in lowerMsg and its region
+func (sc ShippingConfig) findCountry(lowerMsg string) (string, *ShippingRegion) {
+	for i := range sc.Regions {
+		for _, country := range sc.Regions[i].Countries {
+			if strings.Contains(lowerMsg, country) {
+				return country, &sc.Regions[i]
+			}
+		}
+	}
+	return "", nil
+}
+
+// regionForCountry returns the region whose display name matches country (case-insensitive)
+func (sc ShippingConfig) regionForCountry(country string) *ShippingRegion {
+	country = strings.ToLower(strings.TrimSpace(country))
+	if country == "" {
+		return nil
+	}
+	for i := range sc.Regions {
+		for _, name := range sc.Regions[i].Countries {
+			if name == country {
+				return &sc.Regions[i]
+			}
+		}
+	}
+	return nil
+}
+
+// render fills the policy template for country, using the region's estimates when set
+func (sc ShippingConfig) render(country string, region *ShippingRegion) string {
+	standardTime, expressTime := sc.StandardTime, sc.ExpressTime
+	if region != nil {
+		if region.StandardTime != "" {
+			standardTime = region.StandardTime
+		}
+		if region.ExpressTime != "" {
+			expressTime = region.ExpressTime
+		}
+	}
+
+	return strings.NewReplacer(
+		"[STANDARD_CARRIER]", sc.StandardCarrier,
+		"[STANDARD_TIME]", standardTime,
+		"[EXPRESS_CARRIER]", sc.ExpressCarrier,
+		"[EXPRESS_TIME]", expressTime,
+		"[POLICY_URL]", sc.PolicyURL,
+	).Replace(strings.Replace(shippingPolicyTemplate, "[COUNTRY]", country, 1))
+}
 
 // IsShippingInquiry checks if the user message is asking about shipping
 func IsShippingInquiry(message string) (bool, string) {
@@ -43,29 +220,14 @@ func IsShippingInquiry(message string) (bool, string) {
 		return false, ""
 	}
 
-	// Extract country if present (simple heuristic)
-	// This is a basic list, in a real app we might use a library or a longer list
-	countries := []string{
-		"ecuador", "usa", "united states", "uk", "united kingdom", "canada", "australia",
-		"germany", "france", "italy", "spain", "brazil", "argentina", "chile", "mexico",
-		"thailand", "philippines", "japan", "korea", "south korea", "india", "china",
-		"israel", "netherlands", "belgium", "sweden", "norway", "denmark", "finland",
-		"poland", "portugal", "greece", "turkey", "switzerland", "austria", "ireland",
-		"new zealand", "singapore", "malaysia", "indonesia", "vietnam", "taiwan",
-		"hong kong", "uae", "saudi arabia", "south africa", "egypt", "peru", "colombia",
-	}
-
+	// Extract the country using the configured country list (simple substring heuristic)
 	detectedCountry := "your country"
-	caser := cases.Title(language.English)
-	for _, country := range countries {
-		if strings.Contains(lowerMsg, country) {
-			// Capitalize first letter for display
-			if len(country) <= 3 {
-				detectedCountry = strings.ToUpper(country)
-			} else {
-				detectedCountry = caser.String(country)
-			}
-			break
+	if country, _ := currentShippingConfig().findCountry(lowerMsg); country != "" {
+		// Capitalize first letter for display
+		if len(country) <= 3 {
+			detectedCountry = strings.ToUpper(country)
+		} else {
+			detectedCountry = cases.Title(language.English).String(country)
 		}
 	}
 
@@ -73,6 +235,8 @@ func IsShippingInquiry(message string) (bool, string) {
 }
 
 // GetShippingResponse returns the formatted shipping response
+// Transit estimates come from the region of country when it is a configured country
 func GetShippingResponse(country string) string {
-	return strings.Replace(ShippingPolicyResponse, "[COUNTRY]", country, 1)
+	cfg := currentShippingConfig()
+	return cfg.render(country, cfg.regionForCountry(country))
 }
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsShippingInquiry(t *testing.T) {
@@ -309,3 +312,58 @@ func TestIsShippingInquiry_AllSupportedCountries(t *testing.T) {
 		})
 	}
 }
+
+// useShippingConfigFile loads a shipping config written to a temp file and restores the default afterwards
+func useShippingConfigFile(t *testing.T, content string) error {
+	t.Helper()
+	t.Cleanup(func() { setShippingConfig(defaultShippingConfig) })
+
+	path := filepath.Join(t.TempDir(), "shipping.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return LoadShippingConfig(path)
+}
+
+func TestLoadShippingConfig_RegionSpecificEstimates(t *testing.T) {
+	err := useShippingConfigFile(t, `{
+		"standard_carrier": "Israel Post",
+		"express_carrier": "DHL Express",
+		"standard_time": "10-20 business days",
+		"express_time": "4-8 business days",
+		"regions": [
+			{"name": "europe", "standard_time": "7-12 business days", "express_time": "3-5 business days", "countries": ["Germany", " france "]},
+			{"name": "rest_of_world", "countries": ["usa", "brazil"]}
+		]
+	}`)
+	require.NoError(t, err)
+
+	isShipping, country := IsShippingInquiry("Do you ship to France?")
+	assert.True(t, isShipping)
+	assert.Equal(t, "France", country)
+
+	europe := GetShippingResponse(country)
+	assert.Contains(t, europe, "Yes, we can ship to France")
+	assert.Contains(t, europe, "**Israel Post:** Usually takes 7-12 business days")
+	assert.Contains(t, europe, "**DHL Express:** Usually takes 3-5 business days")
+	// Unset fields keep the bundled values
+	assert.Contains(t, europe, "https://israeldefensestore.com/shipping-policy")
+
+	_, country = IsShippingInquiry("Can you ship to USA?")
+	world := GetShippingResponse(country)
+	assert.Contains(t, world, "**Israel Post:** Usually takes 10-20 business days")
+	assert.Contains(t, world, "**DHL Express:** Usually takes 4-8 business days")
+
+	// Countries missing from the file are no longer recognized
+	_, country = IsShippingInquiry("Can you ship to Japan?")
+	assert.Equal(t, "your country", country)
+	assert.Contains(t, GetShippingResponse(country), "10-20 business days")
+}
+
+func TestLoadShippingConfig_Errors(t *testing.T) {
+	assert.NoError(t, LoadShippingConfig(""), "an empty path keeps the bundled data")
+	assert.ErrorContains(t, LoadShippingConfig(filepath.Join(t.TempDir(), "missing.json")), "failed to read shipping config file")
+	assert.ErrorContains(t, useShippingConfigFile(t, `{"regions": [`), "failed to parse shipping config file")
+	assert.ErrorContains(t, useShippingConfigFile(t, `{"standard_carrier": "Israel Post"}`), "lists no regions")
+
+	// A failed load leaves the bundled data in place
+	assert.Equal(t, ShippingPolicyResponse, GetShippingResponse("[COUNTRY]"))
+}
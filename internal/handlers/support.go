@@ -13,6 +13,7 @@ import (
 	"ids/internal/database"
 	"ids/internal/email"
 	"ids/internal/models"
+	idsopenai "ids/internal/openai"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sashabaranov/go-openai"
@@ -33,8 +34,8 @@ const (
 // @Produce json
 // @Param request body models.SupportRequest true "Support request"
 // @Success 200 {object} models.SupportResponse
-// @Failure 400 {object} models.SupportResponse
-// @Failure 500 {object} models.SupportResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
 // @Router /api/chat/request-support [post]
 func SupportRequestHandler(cfg *config.Config, analyticsService *analytics.Service, conversationService *database.ConversationService) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -44,41 +45,33 @@ func SupportRequestHandler(cfg *config.Config, analyticsService *analytics.Servi
 		var req models.SupportRequest
 		if err := c.Bind(&req); err != nil {
 			fmt.Printf("[SUPPORT] ERROR: Invalid request body: %v\n", err)
-			return c.JSON(http.StatusBadRequest, models.SupportResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Invalid request body: %v", err),
-			})
+			return respondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		}
 
 		// Validate email format
 		if !emailRegex.MatchString(req.CustomerEmail) {
 			fmt.Printf("[SUPPORT] ERROR: Invalid email format: %s\n", req.CustomerEmail)
-			return c.JSON(http.StatusBadRequest, models.SupportResponse{
-				Success: false,
-				Error:   "Invalid email format. Please provide a valid email address.",
-			})
+			return respondError(c, http.StatusBadRequest, "Invalid email format. Please provide a valid email address.")
 		}
 
 		// Validate conversation is not empty
 		if len(req.Conversation) == 0 {
 			fmt.Printf("[SUPPORT] ERROR: Empty conversation\n")
-			return c.JSON(http.StatusBadRequest, models.SupportResponse{
-				Success: false,
-				Error:   "Conversation cannot be empty",
-			})
+			return respondError(c, http.StatusBadRequest, "Conversation cannot be empty")
 		}
 
 		// Check if OpenAI API key is configured
 		if cfg.OpenAIKey == "" {
 			fmt.Printf("[SUPPORT] ERROR: OpenAI API key not configured\n")
-			return c.JSON(http.StatusInternalServerError, models.SupportResponse{
-				Success: false,
-				Error:   "OpenAI API key not configured",
-			})
+			return respondError(c, http.StatusInternalServerError, "OpenAI API key not configured")
 		}
 
-		// Summarize conversation using OpenAI
-		summary, err := summarizeConversation(cfg.OpenAIKey, req.Conversation, analyticsService)
+		// Summarize conversation using the unified OpenAI client (Azure primary, OpenAI fallback)
+		var summary string
+		client, err := idsopenai.NewClient(cfg)
+		if err == nil {
+			summary, err = summarizeConversation(client, client.GetGPTModel(), req.Conversation, analyticsService)
+		}
 		if err != nil {
 			fmt.Printf("[SUPPORT] ERROR: Failed to summarize conversation: %v\n", err)
 			// Continue with basic summary if AI summarization fails
@@ -93,10 +86,7 @@ func SupportRequestHandler(cfg *config.Config, analyticsService *analytics.Servi
 		emailHTML, err := emailService.SendSupportEscalationEmail(req.CustomerEmail, summary, fullConversation)
 		if err != nil {
 			fmt.Printf("[SUPPORT] ERROR: Failed to send email: %v\n", err)
-			return c.JSON(http.StatusInternalServerError, models.SupportResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to send email: %v", err),
-			})
+			return respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to send email: %v", err))
 		}
 
 		fmt.Printf("[SUPPORT] ✅ Support escalation email sent successfully to %s\n", req.CustomerEmail)
@@ -132,8 +122,8 @@ func SupportRequestHandler(cfg *config.Config, analyticsService *analytics.Servi
 }
 
 // summarizeConversation uses OpenAI to generate a summary of the conversation
-func summarizeConversation(openAIKey string, conversation []models.ConversationMessage, analyticsService *analytics.Service) (string, error) {
-	client := openai.NewClient(openAIKey)
+// model is only recorded in analytics; the client picks the Azure deployment or OpenAI model itself
+func summarizeConversation(client chatCompleter, model string, conversation []models.ConversationMessage, analyticsService *analytics.Service) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -166,13 +156,7 @@ func summarizeConversation(openAIKey string, conversation []models.ConversationM
 		},
 	}
 
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:       openai.GPT4oMini,
-		Messages:    messages,
-		MaxTokens:   500,
-		Temperature: 0.7,
-	})
-
+	resp, err := client.CreateChatCompletion(ctx, messages, 500, 0.7)
 	if err != nil {
 		return "", err
 	}
@@ -188,7 +172,7 @@ func summarizeConversation(openAIKey string, conversation []models.ConversationM
 			if resp.Usage.TotalTokens > 0 {
 				tokens = resp.Usage.TotalTokens
 			}
-			if err := analyticsService.TrackSupportSummarization(tokens, string(openai.GPT4oMini)); err != nil {
+			if err := analyticsService.TrackSupportSummarization(tokens, model); err != nil {
 				fmt.Printf("[SUPPORT] Warning: Failed to track summarization: %v\n", err)
 			}
 		}()
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"testing"
+
+	"ids/internal/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeConversation_UsesChatClient(t *testing.T) {
+	client := &fakeChatCompleter{responses: []string{"The customer asked about holster sizing."}}
+	conversation := []models.ConversationMessage{
+		{Role: "user", Message: "Which holster fits a Glock 19?"},
+		{Role: "assistant", Message: "The Medium size fits it."},
+	}
+
+	summary, err := summarizeConversation(client, "gpt-4o-mini", conversation, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "The customer asked about holster sizing.", summary)
+
+	require.Len(t, client.calls, 1)
+	require.Len(t, client.calls[0], 2)
+	assert.Equal(t, openai.ChatMessageRoleSystem, client.calls[0][0].Role)
+	assert.Equal(t, "User: Which holster fits a Glock 19?\nAssistant: The Medium size fits it.\n", client.calls[0][1].Content)
+}
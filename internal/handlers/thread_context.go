@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/emails"
+	"ids/internal/models"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultContextThreads is used when EMAIL_CONTEXT_THREAD_LIMIT is not positive
+const defaultContextThreads = 3
+
+// contextThreadLimit returns the number of similar email threads rendered in the LLM context
+func contextThreadLimit(limit int) int {
+	if limit <= 0 {
+		return defaultContextThreads
+	}
+	return limit
+}
+
+// rankThreadsForContext orders the email search results before the top contextThreadLimit are
+// fetched and rendered (EMAIL_CONTEXT_RANKING). Hybrid averages similarity and recency, each scaled
+// to 0-1 across the candidates. Unknown rankings keep the similarity order; ties keep search order.
+func rankThreadsForContext(threads []models.EmailSearchResult, ranking string) {
+	switch ranking {
+	case config.EmailRankingRecency:
+		sort.SliceStable(threads, func(i, j int) bool {
+			return threadLastDate(threads[i]).After(threadLastDate(threads[j]))
+		})
+	case config.EmailRankingHybrid:
+		scores := hybridThreadScores(threads)
+		indexes := make([]int, len(threads))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.SliceStable(indexes, func(i, j int) bool { return scores[indexes[i]] > scores[indexes[j]] })
+		ranked := make([]models.EmailSearchResult, len(threads))
+		for i, index := range indexes {
+			ranked[i] = threads[index]
+		}
+		copy(threads, ranked)
+	default:
+		sort.SliceStable(threads, func(i, j int) bool {
+			return threads[i].Similarity > threads[j].Similarity
+		})
+	}
+}
+
+// hybridThreadScores returns the hybrid ranking score of each thread, aligned with threads
+func hybridThreadScores(threads []models.EmailSearchResult) []float64 {
+	if len(threads) == 0 {
+		return nil
+	}
+	minSim, maxSim := threads[0].Similarity, threads[0].Similarity
+	oldest, newest := threadLastDate(threads[0]), threadLastDate(threads[0])
+	for _, thread := range threads[1:] {
+		minSim, maxSim = min(minSim, thread.Similarity), max(maxSim, thread.Similarity)
+		if date := threadLastDate(thread); date.Before(oldest) {
+			oldest = date
+		} else if date.After(newest) {
+			newest = date
+		}
+	}
+
+	scores := make([]float64, len(threads))
+	for i, thread := range threads {
+		similarity, recency := 1.0, 1.0
+		if maxSim > minSim {
+			similarity = (thread.Similarity - minSim) / (maxSim - minSim)
+		}
+		if span := newest.Sub(oldest); span > 0 {
+			recency = float64(threadLastDate(thread).Sub(oldest)) / float64(span)
+		}
+		scores[i] = (similarity + recency) / 2
+	}
+	return scores
+}
+
+// threadLastDate returns the last activity of a search result: the thread's last_date, or the
+// email date for results without a thread
+func threadLastDate(result models.EmailSearchResult) time.Time {
+	if result.Thread != nil {
+		return result.Thread.LastDate
+	}
+	return result.Email.Date
+}
+
+// hasThreadSummary reports whether thread has a stored summary to render instead of its emails
+// A blank summary (e.g. an empty GPT reply) counts as none, so the emails are shown.
+func hasThreadSummary(thread *models.EmailThread) bool {
+	return strings.TrimSpace(thread.Summary) != ""
+}
+
+// maxContextThreadEmails is the number of emails rendered per context thread, oldest first
+const maxContextThreadEmails = 5
+
+// defaultEmailBodyLength is used when EMAIL_CONTEXT_BODY_LENGTH is not positive
+const defaultEmailBodyLength = 300
+
+// truncateEmailBody shortens a context email body to maxChars characters (runes, so
+// Hebrew text is never cut mid-character), appending "..." when it was truncated
+func truncateEmailBody(body string, maxChars int) string {
+	if maxChars <= 0 {
+		maxChars = defaultEmailBodyLength
+	}
+	runes := []rune(body)
+	if len(runes) <= maxChars {
+		return body
+	}
+	return string(runes[:maxChars]) + "..."
+}
+
+// threadEmailFetcher retrieves the emails of a single thread
+type threadEmailFetcher func(ctx context.Context, threadID string) ([]models.Email, error)
+
+// getThreadEmails returns a threadEmailFetcher loading the emails rendered for a thread from db
+func getThreadEmails(db *database.WriteClient) threadEmailFetcher {
+	return func(ctx context.Context, threadID string) ([]models.Email, error) {
+		return emails.ThreadEmails(ctx, db, threadID, maxContextThreadEmails)
+	}
+}
+
+// fetchThreadEmails fetches the emails of the top limit context threads concurrently
+// The result is aligned with threads (index i holds the emails of threads[i]) so the
+// context is assembled in the same order regardless of which fetch finishes first.
+// Failed or timed-out fetches leave their slot empty and the thread is rendered without emails.
+// Threads with a stored summary are skipped, since the summary is rendered instead of their emails.
+func fetchThreadEmails(ctx context.Context, threads []models.EmailSearchResult, limit int, fetch threadEmailFetcher, concurrency int, timeout time.Duration) [][]models.Email {
+	count := min(len(threads), contextThreadLimit(limit))
+	results := make([][]models.Email, count)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(concurrency, 1))
+
+	for i := 0; i < count; i++ {
+		if threads[i].Thread == nil || hasThreadSummary(threads[i].Thread) {
+			continue
+		}
+		threadID := threads[i].Thread.ThreadID
+		group.Go(func() error {
+			fetchCtx := groupCtx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				fetchCtx, cancel = context.WithTimeout(groupCtx, timeout)
+				defer cancel()
+			}
+
+			emails, err := fetch(fetchCtx, threadID)
+			if err != nil {
+				// One slow or failing thread should not drop the others
+				fmt.Printf("[CHAT] Warning: Failed to fetch emails for thread %s: %v\n", threadID, err)
+				return nil
+			}
+			results[i] = emails
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return results
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ids/internal/config"
+	"ids/internal/database"
+	"ids/internal/models"
+	"ids/internal/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testThreads returns four similar threads; only the top three are used in the context
+func testThreads() []models.EmailSearchResult {
+	threads := make([]models.EmailSearchResult, 4)
+	for i := range threads {
+		threads[i] = models.EmailSearchResult{
+			Thread:     &models.EmailThread{ThreadID: fmt.Sprintf("thread-%d", i), Subject: fmt.Sprintf("Subject %d", i)},
+			Similarity: 0.9 - float64(i)/10,
+		}
+	}
+	return threads
+}
+
+// slowFetcher returns one customer email per thread, finishing earlier threads last
+func slowFetcher(inFlight, peak *int32) threadEmailFetcher {
+	delays := map[string]time.Duration{"thread-0": 30 * time.Millisecond, "thread-1": 20 * time.Millisecond, "thread-2": 10 * time.Millisecond}
+	return func(ctx context.Context, threadID string) ([]models.Email, error) {
+		current := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			previous := atomic.LoadInt32(peak)
+			if current <= previous || atomic.CompareAndSwapInt32(peak, previous, current) {
+				break
+			}
+		}
+
+		select {
+		case <-time.After(delays[threadID]):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return []models.Email{{Body: "Question about " + threadID, IsCustomer: true}}, nil
+	}
+}
+
+func TestFetchThreadEmails_ConcurrentMatchesSerial(t *testing.T) {
+	threads := testThreads()
+
+	var serialInFlight, serialPeak int32
+	serial := fetchThreadEmails(context.Background(), threads, 0, slowFetcher(&serialInFlight, &serialPeak), 1, time.Second)
+
+	var concurrentInFlight, concurrentPeak int32
+	concurrent := fetchThreadEmails(context.Background(), threads, 0, slowFetcher(&concurrentInFlight, &concurrentPeak), 3, time.Second)
+
+	require.Len(t, concurrent, defaultContextThreads)
+	assert.Equal(t, serial, concurrent)
+	assert.Equal(t, int32(1), serialPeak)
+	assert.Greater(t, concurrentPeak, int32(1))
+
+	lang := utils.Language{Code: utils.LangEnglish}
+	serialMessages := buildOpenAIMessages(nil, nil, threads, serial, lang, false, contextOptions{})
+	concurrentMessages := buildOpenAIMessages(nil, nil, threads, concurrent, lang, false, contextOptions{})
+	assert.Equal(t, serialMessages[0].Content, concurrentMessages[0].Content)
+	assert.Contains(t, concurrentMessages[0].Content, "Customer: Question about thread-0")
+	assert.Less(t,
+		strings.Index(concurrentMessages[0].Content, "thread-0"),
+		strings.Index(concurrentMessages[0].Content, "thread-2"))
+	assert.NotContains(t, concurrentMessages[0].Content, "thread-3")
+}
+
+func TestFetchThreadEmails_TimeoutAndErrorsLeaveSlotEmpty(t *testing.T) {
+	threads := testThreads()
+	fetch := func(ctx context.Context, threadID string) ([]models.Email, error) {
+		switch threadID {
+		case "thread-0":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "thread-1":
+			return nil, errors.New("connection reset")
+		default:
+			return []models.Email{{Body: "ok"}}, nil
+		}
+	}
+
+	results := fetchThreadEmails(context.Background(), threads, 0, fetch, 3, 10*time.Millisecond)
+
+	require.Len(t, results, defaultContextThreads)
+	assert.Empty(t, results[0])
+	assert.Empty(t, results[1])
+	assert.Equal(t, []models.Email{{Body: "ok"}}, results[2])
+}
+
+func TestTruncateEmailBody(t *testing.T) {
+	assert.Equal(t, "short", truncateEmailBody("short", 10))
+	assert.Equal(t, "exactly10!", truncateEmailBody("exactly10!", 10))
+	assert.Equal(t, "hello...", truncateEmailBody("hello world", 5))
+	assert.Equal(t, "שלום...", truncateEmailBody("שלום עולם", 4))
+	assert.Equal(t, strings.Repeat("a", defaultEmailBodyLength)+"...", truncateEmailBody(strings.Repeat("a", 400), 0))
+}
+
+func TestBuildOpenAIMessages_TruncatesEmailBodies(t *testing.T) {
+	threads := testThreads()[:1]
+	threadEmails := [][]models.Email{{{Body: strings.Repeat("x", 50) + "TAIL", IsCustomer: true}}}
+	lang := utils.Language{Code: utils.LangEnglish}
+
+	messages := buildOpenAIMessages(nil, nil, threads, threadEmails, lang, false, contextOptions{EmailBodyLength: 50})
+	assert.Contains(t, messages[0].Content, "Customer: "+strings.Repeat("x", 50)+"...\n")
+	assert.NotContains(t, messages[0].Content, "TAIL")
+
+	messages = buildOpenAIMessages(nil, nil, threads, threadEmails, lang, false, contextOptions{EmailBodyLength: 100})
+	assert.Contains(t, messages[0].Content, "TAIL")
+}
+
+func TestFetchThreadEmails_SkipsSummarizedThreads(t *testing.T) {
+	threads := testThreads()
+	threads[1].Thread.Summary = "Customer asked about holster sizing; support recommended the medium."
+
+	var fetched []string
+	var mu sync.Mutex
+	fetch := func(ctx context.Context, threadID string) ([]models.Email, error) {
+		mu.Lock()
+		fetched = append(fetched, threadID)
+		mu.Unlock()
+		return []models.Email{{Body: "Question about " + threadID, IsCustomer: true}}, nil
+	}
+
+	results := fetchThreadEmails(context.Background(), threads, 0, fetch, 3, time.Second)
+	assert.ElementsMatch(t, []string{"thread-0", "thread-2"}, fetched)
+	assert.Empty(t, results[1])
+}
+
+func TestBuildOpenAIMessages_PrefersThreadSummary(t *testing.T) {
+	threads := testThreads()[:2]
+	threads[0].Thread.Summary = "Customer asked about holster sizing; support recommended the medium."
+	threadEmails := [][]models.Email{
+		{{Body: "Raw email of thread 0", IsCustomer: true}},
+		{{Body: "Raw email of thread 1", IsCustomer: true}},
+	}
+	lang := utils.Language{Code: utils.LangEnglish}
+
+	messages := buildOpenAIMessages(nil, nil, threads, threadEmails, lang, false, contextOptions{})
+	assert.Contains(t, messages[0].Content, "Summary: Customer asked about holster sizing; support recommended the medium.\n")
+	assert.NotContains(t, messages[0].Content, "Raw email of thread 0")
+	assert.Contains(t, messages[0].Content, "Customer: Raw email of thread 1")
+}
+
+func TestGetThreadEmails_PopulatesContext(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	mock.MatchExpectationsInOrder(false)
+
+	columns := []string{"id", "message_id", "subject", "from_addr", "to_addr", "date", "body", "thread_id", "in_reply_to", "references", "is_customer"}
+	threads := testThreads()
+	for i, thread := range threads[:defaultContextThreads] {
+		rows := sqlmock.NewRows(columns).
+			AddRow(i*2+1, thread.Thread.ThreadID+"-1", thread.Thread.Subject, "buyer@example.com", "support@store.com",
+				time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC), "Question in "+thread.Thread.ThreadID, thread.Thread.ThreadID, nil, nil, true).
+			AddRow(i*2+2, thread.Thread.ThreadID+"-2", "Re: "+thread.Thread.Subject, "support@store.com", "buyer@example.com",
+				time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC), "Answer in "+thread.Thread.ThreadID, thread.Thread.ThreadID, nil, nil, false)
+		mock.ExpectQuery(`FROM emails\s+WHERE thread_id = \$1\s+ORDER BY date ASC\s+LIMIT \$2`).
+			WithArgs(thread.Thread.ThreadID, maxContextThreadEmails).
+			WillReturnRows(rows)
+	}
+
+	fetch := getThreadEmails(database.NewWriteClientFromDB(sqlx.NewDb(mockDB, "postgres")))
+	threadEmails := fetchThreadEmails(context.Background(), threads, 0, fetch, 3, time.Second)
+	require.Len(t, threadEmails, defaultContextThreads)
+	for i := range threadEmails {
+		require.Len(t, threadEmails[i], 2, "thread %d", i)
+	}
+
+	lang := utils.Language{Code: "en", Name: "English", Confidence: 1}
+	messages := buildOpenAIMessages(nil, nil, threads, threadEmails, lang, false, contextOptions{})
+	content := messages[0].Content
+	assert.Contains(t, content, "=== SIMILAR PAST CONVERSATIONS (for context) ===")
+	assert.Contains(t, content, "--- Thread: Subject 0 (Similarity: 0.90) ---\nCustomer: Question in thread-0\nSupport: Answer in thread-0\n")
+	assert.Contains(t, content, "Support: Answer in thread-2")
+	assert.NotContains(t, content, "Subject 3")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildOpenAIMessages_EmailThreadLimit(t *testing.T) {
+	threads := testThreads()
+	lang := utils.Language{Code: "en", Name: "English", Confidence: 1}
+
+	tests := []struct {
+		limit    int
+		rendered int
+	}{
+		{limit: 0, rendered: defaultContextThreads},
+		{limit: 1, rendered: 1},
+		{limit: 2, rendered: 2},
+		{limit: 10, rendered: len(threads)},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("limit %d", tt.limit), func(t *testing.T) {
+			fetch := func(ctx context.Context, threadID string) ([]models.Email, error) {
+				return []models.Email{{Body: "Question about " + threadID, IsCustomer: true}}, nil
+			}
+			threadEmails := fetchThreadEmails(context.Background(), threads, tt.limit, fetch, 3, time.Second)
+			require.Len(t, threadEmails, tt.rendered)
+
+			content := buildOpenAIMessages(nil, nil, threads, threadEmails, lang, false, contextOptions{EmailThreadLimit: tt.limit})[0].Content
+			assert.Equal(t, tt.rendered, strings.Count(content, "--- Thread: "))
+			for i, thread := range threads {
+				if i < tt.rendered {
+					assert.Contains(t, content, "Customer: Question about "+thread.Thread.ThreadID)
+				} else {
+					assert.NotContains(t, content, thread.Thread.Subject)
+				}
+			}
+		})
+	}
+}
+
+// rankedThreads returns a fixed candidate set whose similarity and recency orders differ
+func rankedThreads() []models.EmailSearchResult {
+	thread := func(id string, similarity float64, lastDate time.Time) models.EmailSearchResult {
+		return models.EmailSearchResult{Thread: &models.EmailThread{ThreadID: id, LastDate: lastDate}, Similarity: similarity}
+	}
+	return []models.EmailSearchResult{
+		thread("old-exact", 0.90, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+		thread("recent-close", 0.85, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)),
+		thread("newest-loose", 0.70, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		thread("dated-loose", 0.60, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+}
+
+func threadIDs(threads []models.EmailSearchResult) []string {
+	ids := make([]string, len(threads))
+	for i, result := range threads {
+		ids[i] = result.Thread.ThreadID
+	}
+	return ids
+}
+
+func TestRankThreadsForContext(t *testing.T) {
+	tests := []struct {
+		ranking string
+		want    []string
+	}{
+		{config.EmailRankingSimilarity, []string{"old-exact", "recent-close", "newest-loose", "dated-loose"}},
+		{config.EmailRankingRecency, []string{"newest-loose", "recent-close", "dated-loose", "old-exact"}},
+		// Scores: recent-close 0.82, newest-loose 0.67, old-exact 0.50, dated-loose 0.17
+		{config.EmailRankingHybrid, []string{"recent-close", "newest-loose", "old-exact", "dated-loose"}},
+		{"unknown", []string{"old-exact", "recent-close", "newest-loose", "dated-loose"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ranking, func(t *testing.T) {
+			threads := rankedThreads()
+			rankThreadsForContext(threads, tt.ranking)
+			assert.Equal(t, tt.want, threadIDs(threads))
+		})
+	}
+}
+
+func TestRankThreadsForContext_HybridTiesKeepSearchOrder(t *testing.T) {
+	// Equal similarities and dates score alike, so the search order is kept
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	threads := []models.EmailSearchResult{
+		{Thread: &models.EmailThread{ThreadID: "a", LastDate: date}, Similarity: 0.8},
+		{Thread: &models.EmailThread{ThreadID: "b", LastDate: date}, Similarity: 0.8},
+	}
+	rankThreadsForContext(threads, config.EmailRankingHybrid)
+	assert.Equal(t, []string{"a", "b"}, threadIDs(threads))
+	assert.Equal(t, []float64{1, 1}, hybridThreadScores(threads))
+}
+
+func TestRankThreadsForContext_RecencyUsesEmailDateWithoutThread(t *testing.T) {
+	threads := []models.EmailSearchResult{
+		{Email: models.Email{ID: 1, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, Similarity: 0.9},
+		{Email: models.Email{ID: 2, Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}, Similarity: 0.5},
+	}
+	rankThreadsForContext(threads, config.EmailRankingRecency)
+	assert.Equal(t, 2, threads[0].Email.ID)
+}
+
+func TestRankThreadsForContext_PicksRenderedThreads(t *testing.T) {
+	// Only the top EMAIL_CONTEXT_THREAD_LIMIT ranked threads reach the context
+	threads := rankedThreads()
+	rankThreadsForContext(threads, config.EmailRankingRecency)
+	content := buildOpenAIMessages(nil, nil, threads, nil, utils.Language{Code: utils.LangEnglish}, false,
+		contextOptions{MaxProducts: 15, EmailThreadLimit: 1})[0].Content
+	assert.Contains(t, content, "(Similarity: 0.70)")
+	assert.NotContains(t, content, "(Similarity: 0.90)")
+}
+
+func TestBlankThreadSummary_RendersEmails(t *testing.T) {
+	// A whitespace-only summary is neither rendered nor allowed to hide the thread's emails
+	threads := testThreads()[:1]
+	threads[0].Thread.Summary = " \n\t"
+	fetch := func(ctx context.Context, threadID string) ([]models.Email, error) {
+		return []models.Email{{Body: "Raw email of " + threadID, IsCustomer: true}}, nil
+	}
+
+	threadEmails := fetchThreadEmails(context.Background(), threads, 0, fetch, 1, time.Second)
+	require.Len(t, threadEmails[0], 1)
+
+	content := buildOpenAIMessages(nil, nil, threads, threadEmails, utils.Language{Code: utils.LangEnglish}, false, contextOptions{})[0].Content
+	assert.NotContains(t, content, "Summary:")
+	assert.Contains(t, content, "Customer: Raw email of thread-0")
+}
@@ -236,12 +236,19 @@ echo "  - Total size: $total_size"
 echo ""
 
 # Run email import
+# import-emails takes one source per run (-eml for a file or directory, -mbox for a single file)
 echo "===== Step 1/3: Importing Emails to Database ====="
-/home/appuser/import-emails --eml-path /emails --mbox-path /emails
+if [ "$eml_count" -gt 0 ]; then
+  /home/appuser/import-emails -eml /emails
+fi
+find /emails -name "*.mbox" -type f | while read -r mbox; do
+  echo "Importing $mbox"
+  /home/appuser/import-emails -mbox "$mbox"
+done
 
 echo ""
 echo "===== Step 2/3: Generating Email Embeddings ====="
-# Email embeddings are generated during import
+# Email embeddings are generated per batch during import, for the emails each batch stored
 echo "✓ Email embeddings generated"
 
 echo ""
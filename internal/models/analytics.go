@@ -13,7 +13,7 @@ type AnalyticsEvent struct {
 
 // AnalyticsSummary represents aggregated analytics for a time period
 type AnalyticsSummary struct {
-	Period               string    `json:"period"`                 // "today", "yesterday", "last_7_days", "last_30_days"
+	Period               string    `json:"period"`                 // "today", "yesterday", "last_7_days", "last_30_days" or "custom"
 	TotalConversations   int       `json:"total_conversations"`    // Total chat conversations
 	ProductSuggestions   int       `json:"product_suggestions"`    // Total product suggestions made
 	TotalEmails          int       `json:"total_emails"`           // Total emails in database
@@ -37,6 +37,16 @@ type AnalyticsSummary struct {
 	QueryEmbeddings       int `json:"query_embeddings"`       // Per-search embedding generations (billable)
 	SupportSummarizations int `json:"support_summarizations"` // GPT calls for support summaries (billable)
 	SupportSummaryTokens  int `json:"support_summary_tokens"` // Tokens used for support summarizations
+	// Search latency by search type ("product_search", "email_search"); omitted when no searches were recorded
+	SearchLatency map[string]SearchLatencyPercentiles `json:"search_latency,omitempty"`
+}
+
+// SearchLatencyPercentiles summarizes the chat search durations of one search type
+type SearchLatencyPercentiles struct {
+	Count int     `json:"count"`  // Searches recorded
+	P50Ms float64 `json:"p50_ms"` // Median duration in milliseconds
+	P95Ms float64 `json:"p95_ms"` // 95th percentile duration in milliseconds
+	P99Ms float64 `json:"p99_ms"` // 99th percentile duration in milliseconds
 }
 
 // AnalyticsResponse represents the API response for analytics
@@ -47,6 +57,21 @@ type AnalyticsResponse struct {
 	Error   string            `json:"error,omitempty" example:""`
 }
 
+// TableFreshness describes the size and last write time of an embeddings table
+type TableFreshness struct {
+	RowCount      int        `json:"row_count" example:"1500"`                       // Number of rows
+	LastUpdatedAt *time.Time `json:"last_updated_at" example:"2023-01-01T00:00:00Z"` // Most recent updated_at (null when empty)
+}
+
+// EmbeddingFreshnessResponse reports how current the stored embeddings are
+// @Description Embedding freshness for ops triage
+type EmbeddingFreshnessResponse struct {
+	ProductEmbeddings        TableFreshness `json:"product_embeddings"`                                // product_embeddings table
+	EmailEmbeddings          TableFreshness `json:"email_embeddings"`                                  // email_embeddings table
+	LastGenerationAt         *time.Time     `json:"last_generation_at" example:"2023-01-01T00:00:00Z"` // Last product embedding run (even with no changes)
+	LastGenerationAgeSeconds *int64         `json:"last_generation_age_seconds" example:"3600"`        // Seconds since the last run
+}
+
 // OpenAIUsage represents OpenAI API usage details
 type OpenAIUsage struct {
 	PromptTokens     int    `json:"prompt_tokens"`
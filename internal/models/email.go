@@ -17,6 +17,16 @@ type Email struct {
 	IsCustomer bool      `db:"is_customer" json:"is_customer"` // true if from customer, false if from support
 	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+
+	Attachments []EmailAttachment `db:"-" json:"attachments,omitempty"` // Stored in email_attachments
+}
+
+// EmailAttachment describes a file attached to an email; only metadata is kept, not the content
+type EmailAttachment struct {
+	EmailID     int    `db:"email_id" json:"-"`
+	Filename    string `db:"filename" json:"filename"`
+	ContentType string `db:"content_type" json:"content_type"`
+	IsInline    bool   `db:"is_inline" json:"is_inline"` // Content-Disposition: inline (e.g. embedded images)
 }
 
 // EmailThread represents a conversation thread
@@ -41,10 +51,27 @@ type EmailEmbedding struct {
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
+// EmailResultKind tells whether a search result matched a single email or a whole thread
+type EmailResultKind string
+
+const (
+	EmailResultEmail  EmailResultKind = "email"  // Matched an individual email embedding
+	EmailResultThread EmailResultKind = "thread" // Matched a thread embedding; Email is its latest email
+)
+
 // EmailSearchResult represents an email with similarity score
 type EmailSearchResult struct {
-	Email      Email        `json:"email"`
-	Thread     *EmailThread `json:"thread,omitempty"`
-	Similarity float64      `json:"similarity"`
-	Embedding  []float64    `json:"-"`
+	Email      Email           `json:"email"`
+	Kind       EmailResultKind `json:"kind"`
+	Thread     *EmailThread    `json:"thread,omitempty"`
+	Similarity float64         `json:"similarity"`
+	Embedding  []float64       `json:"-"`
+}
+
+// CustomerHistory summarizes a customer's prior email threads for the chat context
+type CustomerHistory struct {
+	Email       string    `json:"email"`        // Normalized customer address
+	ThreadCount int       `json:"thread_count"` // Number of prior threads the customer took part in
+	Subjects    []string  `json:"subjects"`     // Subjects of the most recent threads, newest first
+	LastContact time.Time `json:"last_contact"` // Last email date across those threads (zero when none)
 }
@@ -13,27 +13,121 @@ type HealthResponse struct {
 // DBHealthResponse represents a database health check response
 // @Description Database health check response
 type DBHealthResponse struct {
-	Status    string        `json:"status" example:"healthy"`                   // Health status
-	Timestamp time.Time     `json:"timestamp" example:"2023-01-01T00:00:00Z"`   // Timestamp of the check
-	Connected bool          `json:"connected" example:"true"`                   // Database connection status
-	Latency   time.Duration `json:"latency" swaggertype:"string" example:"1ms"` // Database ping latency
-	Error     string        `json:"error,omitempty" example:""`                 // Error message if any
+	Status         string             `json:"status" example:"healthy"`                   // Health status
+	Timestamp      time.Time          `json:"timestamp" example:"2023-01-01T00:00:00Z"`   // Timestamp of the check
+	Connected      bool               `json:"connected" example:"true"`                   // Database connection status
+	Latency        time.Duration      `json:"latency" swaggertype:"string" example:"1ms"` // Database ping latency
+	Error          string             `json:"error,omitempty" example:""`                 // Error message if any
+	Pool           *DBPoolStats       `json:"pool,omitempty"`                             // Product database connection pool
+	EmbeddingsPool *DBPoolStats       `json:"embeddings_pool,omitempty"`                  // Embeddings database connection pool
+	Reconnect      *DBReconnectStatus `json:"reconnect,omitempty"`                        // Background reconnection state of the product database
+}
+
+// DBReconnectStatus reports the background reconnection to the product database
+// @Description Database reconnection status
+type DBReconnectStatus struct {
+	Reconnecting bool       `json:"reconnecting" example:"true"`                           // Reconnection attempts are in progress
+	Attempts     int        `json:"attempts" example:"3"`                                  // Failed attempts since the connection was lost
+	LastError    string     `json:"last_error,omitempty" example:"connection refused"`     // Error of the last failed ping or attempt
+	LastAttempt  *time.Time `json:"last_attempt,omitempty" example:"2023-01-01T00:00:00Z"` // Time of the last attempt
+	NextAttempt  *time.Time `json:"next_attempt,omitempty" example:"2023-01-01T00:00:04Z"` // Time of the next attempt while reconnecting
+	ConnectedAt  *time.Time `json:"connected_at,omitempty" example:"2023-01-01T00:00:00Z"` // Time the connection was last restored
+}
+
+// DBPoolStats reports the state of a database connection pool
+// @Description Database connection pool statistics
+type DBPoolStats struct {
+	MaxOpenConnections int           `json:"max_open_connections" example:"10"`               // Configured maximum open connections
+	OpenConnections    int           `json:"open_connections" example:"3"`                    // Established connections, in use and idle
+	InUse              int           `json:"in_use" example:"1"`                              // Connections currently in use
+	Idle               int           `json:"idle" example:"2"`                                // Idle connections
+	WaitCount          int64         `json:"wait_count" example:"0"`                          // Total times a query waited for a free connection
+	WaitDuration       time.Duration `json:"wait_duration" swaggertype:"string" example:"0s"` // Total time spent waiting for a connection
+	MaxIdleClosed      int64         `json:"max_idle_closed" example:"0"`                     // Connections closed because of the idle limit
+	MaxLifetimeClosed  int64         `json:"max_lifetime_closed" example:"0"`                 // Connections closed because of the lifetime limit
 }
 
 // Product represents a product from the database (minimal version for embeddings)
 // @Description Product information for embeddings
 type Product struct {
-	ID               int      `json:"id" db:"ID" example:"1"`                                        // Product ID
-	PostTitle        string   `json:"post_title" db:"post_title" example:"Sample Product"`           // Product title
-	PostName         *string  `json:"post_name" db:"post_name" example:"sample-product"`             // Product URL slug
-	Description      *string  `json:"description" db:"description" example:"Product description"`    // Product description
-	ShortDescription *string  `json:"short_description" db:"short_description" example:"Short desc"` // Short description
-	SKU              *string  `json:"sku" db:"sku" example:"SKU123"`                                 // Product SKU
-	MinPrice         *string  `json:"min_price" db:"min_price" example:"10.00"`                      // Minimum price
-	MaxPrice         *string  `json:"max_price" db:"max_price" example:"20.00"`                      // Maximum price
-	StockStatus      *string  `json:"stock_status" db:"stock_status" example:"instock"`              // Stock status
-	StockQuantity    *float64 `json:"stock_quantity" db:"stock_quantity" example:"100"`              // Stock quantity
-	Tags             *string  `json:"tags" db:"tags" example:"electronics,gadgets"`                  // Product tags
+	ID               int        `json:"id" db:"ID" example:"1"`                                               // Product ID
+	PostTitle        string     `json:"post_title" db:"post_title" example:"Sample Product"`                  // Product title
+	PostName         *string    `json:"post_name" db:"post_name" example:"sample-product"`                    // Product URL slug
+	Description      *string    `json:"description" db:"description" example:"Product description"`           // Product description
+	ShortDescription *string    `json:"short_description" db:"short_description" example:"Short desc"`        // Short description
+	SKU              *string    `json:"sku" db:"sku" example:"SKU123"`                                        // Product SKU
+	MinPrice         *string    `json:"min_price" db:"min_price" example:"10.00"`                             // Minimum price
+	MaxPrice         *string    `json:"max_price" db:"max_price" example:"20.00"`                             // Maximum price
+	StockStatus      *string    `json:"stock_status" db:"stock_status" example:"instock"`                     // Stock status
+	StockQuantity    *float64   `json:"stock_quantity" db:"stock_quantity" example:"100"`                     // Stock quantity
+	Tags             *string    `json:"tags" db:"tags" example:"electronics,gadgets"`                         // Product tags
+	PublishedAt      *time.Time `json:"published_at,omitempty" db:"post_date" example:"2023-01-01T00:00:00Z"` // Publish date (wpjr_posts.post_date)
+	Categories       *string    `json:"categories,omitempty" db:"categories" example:"Holsters, Glock"`       // Product categories (product_cat), comma-separated
+	Variations       *string    `json:"variations,omitempty" db:"variations" example:"Size: S, M, L"`         // Variation options by attribute, e.g. "Size: S, M, L; Color: Black"
+}
+
+// ProductSearchOptions are the search settings applied to a product search request
+// @Description Effective product search settings (config defaults plus query overrides)
+type ProductSearchOptions struct {
+	Boosting       bool            `json:"boosting" example:"true"`        // Score boosts applied on top of vector similarity
+	TokenFiltering bool            `json:"token_filtering" example:"true"` // Results missing required query tokens dropped
+	MinSimilarity  float64         `json:"min_similarity" example:"0.3"`   // Minimum similarity of returned products
+	InStockOnly    bool            `json:"in_stock_only" example:"false"`  // Only in-stock or backorderable products returned
+	Filters        *ProductFilters `json:"filters,omitempty"`              // Facet filters applied before ranking
+}
+
+// ProductFilters narrow a product search to matching facets before ranking
+// @Description Optional product facet filters; unset fields match every product
+type ProductFilters struct {
+	Tags      []string `json:"tags,omitempty" example:"holster,owb"` // Products carrying at least one of these tags
+	StockOnly bool     `json:"stock_only,omitempty" example:"true"`  // Only in-stock or backorderable products
+	PriceMin  *float64 `json:"price_min,omitempty" example:"50"`     // Products priced at or above this
+	PriceMax  *float64 `json:"price_max,omitempty" example:"150"`    // Products priced at or below this
+}
+
+// ProductSearchResult represents a single product search match
+// @Description Product search match with its similarity score
+type ProductSearchResult struct {
+	Product    Product `json:"product"`                   // Matched product
+	Similarity float64 `json:"similarity" example:"0.82"` // Final similarity score
+}
+
+// ProductSearchDebug is the relevance trace returned by the product search endpoint with debug=true
+// @Description Search internals for relevance tuning (requires SEARCH_DEBUG_ENABLED)
+type ProductSearchDebug struct {
+	Backend              string                     `json:"backend" example:"pgvector"`              // Vector store that served the search
+	ExpandedTokens       []string                   `json:"expanded_tokens" example:"glock,holster"` // Meaningful tokens of the normalized query
+	RequiredTokens       []string                   `json:"required_tokens" example:"glock"`         // Tokens results must contain when token filtering is on
+	FallbackToSimilarity bool                       `json:"fallback_to_similarity" example:"false"`  // Token filtering removed every result and was skipped
+	Results              []ProductSearchResultDebug `json:"results"`                                 // Scoring of each returned result, in result order
+}
+
+// ProductSearchResultDebug is the scoring of one returned product search result
+// @Description Raw vector score and boost of a search result
+type ProductSearchResultDebug struct {
+	ProductID     int     `json:"product_id" example:"1234"`     // Product ID of the result
+	Distance      float64 `json:"distance" example:"0.21"`       // Raw pgvector distance (0 when served by Qdrant)
+	RawSimilarity float64 `json:"raw_similarity" example:"0.79"` // Vector similarity before boosts
+	Boost         float64 `json:"boost" example:"0.03"`          // Score added on top of the raw similarity
+}
+
+// ProductSearchResponse represents the response from the product search endpoint
+// @Description Product search response payload
+type ProductSearchResponse struct {
+	Query                string                `json:"query" example:"glock holster"`          // Search query
+	Results              []ProductSearchResult `json:"results"`                                // Matched products in ranked order
+	Total                int                   `json:"total" example:"10"`                     // Number of results returned
+	FallbackToSimilarity bool                  `json:"fallback_to_similarity" example:"false"` // Token filtering removed everything and was skipped
+	Options              ProductSearchOptions  `json:"options"`                                // Effective search settings
+	Debug                *ProductSearchDebug   `json:"debug,omitempty"`                        // Relevance trace, only with debug=true
+}
+
+// APIError is the JSON error envelope returned by every endpoint
+// @Description Error response payload
+type APIError struct {
+	Code      int    `json:"code" example:"400"`                                      // HTTP status code
+	Message   string `json:"message" example:"Conversation cannot be empty"`          // Human-readable error message
+	RequestID string `json:"request_id,omitempty" example:"3f6c1b7e9a2d4c8fb1e0a5d7"` // Request ID (X-Request-ID) for correlating logs
 }
 
 // ConversationMessage represents a single message in a conversation
@@ -48,15 +142,24 @@ type ConversationMessage struct {
 type ChatRequest struct {
 	Conversation []ConversationMessage `json:"conversation"`         // Array of conversation messages
 	SessionID    string                `json:"session_id,omitempty"` // Session ID (UUID from frontend)
+	Filters      *ProductFilters       `json:"filters,omitempty"`    // Optional facet filters for the product search
 }
 
 // ChatResponse represents the response from the chat endpoint
 // @Description Chat response payload
 type ChatResponse struct {
-	Response       string            `json:"response" example:"Hello! How can I help you today?"` // AI response message
-	Error          string            `json:"error,omitempty" example:""`                          // Error message if any
-	Products       map[string]string `json:"products,omitempty"`                                  // Product name to SKU mapping for link generation
-	RequestSupport bool              `json:"request_support,omitempty" example:"false"`           // Whether to request customer email for support escalation
+	Response        string            `json:"response" example:"Hello! How can I help you today?"` // AI response message
+	Error           string            `json:"error,omitempty" example:""`                          // Error message if any
+	Products        map[string]string `json:"products,omitempty"`                                  // Product name to SKU mapping for link generation
+	RequestSupport  bool              `json:"request_support,omitempty" example:"false"`           // Whether to request customer email for support escalation
+	HasMoreProducts bool              `json:"has_more_products" example:"false"`                   // Whether more products matched than were shown to the AI
+	TotalProducts   int               `json:"total_products" example:"8"`                          // Number of matching products after stock filtering
+}
+
+// ChatStreamDelta is the payload of a "delta" event on the streaming chat endpoint
+// @Description Incremental chunk of a streamed chat response
+type ChatStreamDelta struct {
+	Content string `json:"content" example:"Hello! "` // Text to append to the response shown so far
 }
 
 // SupportRequest represents a request to escalate conversation to support
@@ -114,6 +217,46 @@ type SessionListResponse struct {
 	HasMore  bool          `json:"has_more" example:"true"` // Whether there are more sessions
 }
 
+// MissingEmbeddingsResponse represents a paginated list of products without embeddings
+// @Description Paginated list of products missing embeddings
+type MissingEmbeddingsResponse struct {
+	Products []Product `json:"products"`                // Products without a product_embeddings row
+	Limit    int       `json:"limit" example:"50"`      // Page size
+	Offset   int       `json:"offset" example:"0"`      // Current offset
+	HasMore  bool      `json:"has_more" example:"true"` // Whether there are more products
+}
+
+// MergeThreadsRequest represents a request to merge fragmented email threads
+// @Description Email thread merge request payload
+type MergeThreadsRequest struct {
+	PrimaryThreadID string   `json:"primary_thread_id" example:"thread-abc"`     // Thread that keeps the merged emails
+	ThreadIDs       []string `json:"thread_ids" example:"thread-def,thread-ghi"` // Threads folded into the primary thread
+}
+
+// MergeThreadsResponse represents the result of an email thread merge
+// @Description Email thread merge response payload
+type MergeThreadsResponse struct {
+	Success         bool   `json:"success" example:"true"`                 // Whether the merge succeeded
+	PrimaryThreadID string `json:"primary_thread_id" example:"thread-abc"` // Thread that now holds the emails
+	MergedThreads   int    `json:"merged_threads" example:"2"`             // Number of thread IDs submitted for merging
+}
+
+// RebuildThreadAggregatesResponse represents the result of recomputing thread aggregates
+// @Description Thread aggregate rebuild response payload
+type RebuildThreadAggregatesResponse struct {
+	Success            bool `json:"success" example:"true"`           // Whether the rebuild succeeded
+	ThreadsUpdated     int  `json:"threads_updated" example:"12"`     // Number of threads whose counts or dates were corrected
+	ParticipantThreads int  `json:"participant_threads" example:"40"` // Number of threads whose participants were rebuilt
+}
+
+// ThreadParticipantLookupResponse lists the email threads an address took part in
+// @Description Threads with a given participant, most recent first
+type ThreadParticipantLookupResponse struct {
+	Address string        `json:"address" example:"customer@example.com"` // Normalized participant address
+	Threads []EmailThread `json:"threads"`                                // Threads the address sent or received mail in
+	Total   int           `json:"total" example:"2"`                      // Number of threads found
+}
+
 // AdminAuthRequest represents admin login request
 // @Description Admin authentication request
 type AdminAuthRequest struct {
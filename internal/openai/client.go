@@ -5,6 +5,8 @@ package openai
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"ids/internal/config"
@@ -21,20 +23,35 @@ type Client struct {
 	gptModel     string
 	embedModel   openai.EmbeddingModel
 	providerName string
+	probe        *dimensionProbe // Shared by WithChatModel copies (nil = probe on every call)
 }
 
+// dimensionProbe caches the embedding size measured by EmbeddingDimensions
+type dimensionProbe struct {
+	mu         sync.Mutex
+	dimensions int
+}
+
+// dimensionProbeText is embedded to measure the embedding model's output size
+const dimensionProbeText = "dimension probe"
+
 // NewClient creates a new OpenAI client with Azure as primary and OpenAI as fallback
 func NewClient(cfg *config.Config) (*Client, error) {
 	client := &Client{
-		cfg: cfg,
+		cfg:   cfg,
+		probe: &dimensionProbe{},
 	}
 
 	// Try Azure OpenAI first (primary)
 	if cfg.UseAzureOpenAI() {
 		azureConfig := openai.DefaultAzureConfig(cfg.AzureOpenAIKey, cfg.AzureOpenAIEndpoint)
+		azureConfig.HTTPClient = newRetryDoer(azureConfig.HTTPClient, cfg.OpenAIMaxRetries, retryDeadline(cfg))
 		client.primary = openai.NewClientWithConfig(azureConfig)
 		client.useAzure = true
 		client.gptModel = cfg.AzureOpenAIGPTDeployment
+		if client.gptModel == "" {
+			client.gptModel = chatModel(cfg)
+		}
 		client.embedModel = openai.EmbeddingModel(cfg.AzureOpenAIEmbeddingDeployment)
 		client.providerName = "Azure OpenAI"
 
@@ -43,13 +60,15 @@ func NewClient(cfg *config.Config) (*Client, error) {
 
 	// Setup OpenAI as fallback (or primary if Azure not configured)
 	if cfg.HasOpenAIFallback() {
-		client.fallback = openai.NewClient(cfg.OpenAIKey)
+		openaiConfig := openai.DefaultConfig(cfg.OpenAIKey)
+		openaiConfig.HTTPClient = newRetryDoer(openaiConfig.HTTPClient, cfg.OpenAIMaxRetries, retryDeadline(cfg))
+		client.fallback = openai.NewClientWithConfig(openaiConfig)
 
 		if !client.useAzure {
 			// Use OpenAI as primary since Azure is not configured
 			client.primary = client.fallback
 			client.fallback = nil
-			client.gptModel = string(openai.GPT4oMini)
+			client.gptModel = chatModel(cfg)
 			client.embedModel = openai.SmallEmbedding3
 			client.providerName = "OpenAI"
 
@@ -63,23 +82,110 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		return nil, fmt.Errorf("no OpenAI provider configured: set AZURE_OPENAI_ENDPOINT + AZURE_OPENAI_KEY or OPENAI_API_KEY")
 	}
 
+	if err := ValidateEmbeddingDimensions(client.GetEmbeddingModel(), cfg.EmbeddingDimensions); err != nil {
+		return nil, err
+	}
+
 	return client, nil
 }
 
-// TestConnection verifies the API connection works
+// chatModel returns the OpenAI chat model (CHAT_MODEL), defaulting when the config wasn't validated
+func chatModel(cfg *config.Config) string {
+	if cfg.ChatModel == "" {
+		return config.DefaultChatModel
+	}
+	return cfg.ChatModel
+}
+
+// retryDeadline returns the total time budget for retrying one request (OPENAI_RETRY_DEADLINE)
+func retryDeadline(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.OpenAIRetryDeadline) * time.Second
+}
+
+// nativeEmbeddingDimensions lists the output size of known embedding models
+var nativeEmbeddingDimensions = map[string]int{
+	string(openai.SmallEmbedding3): 1536,
+	string(openai.LargeEmbedding3): 3072,
+	string(openai.AdaEmbeddingV2):  1536,
+}
+
+// ValidateEmbeddingDimensions rejects an EMBEDDING_DIMENSIONS value that doesn't match the
+// model's native output size. Unset dimensions (0) and unknown models (e.g. custom Azure
+// deployment names) are accepted.
+func ValidateEmbeddingDimensions(model string, dimensions int) error {
+	native, known := nativeEmbeddingDimensions[model]
+	if dimensions <= 0 || !known || native == dimensions {
+		return nil
+	}
+	return fmt.Errorf("EMBEDDING_DIMENSIONS=%d does not match embedding model %s, which outputs %d dimensions", dimensions, model, native)
+}
+
+// WithInputPrefix prepends an embedding instruction (EMBEDDING_INPUT_PREFIX, QUERY_INPUT_PREFIX)
+// to text, as expected by instructed embedding models. An empty prefix leaves text unchanged.
+func WithInputPrefix(prefix, text string) string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return text
+	}
+	return prefix + " " + text
+}
+
+// CheckProbedDimensions rejects an EMBEDDING_DIMENSIONS value that differs from the size the
+// embedding model actually returned; configured <= 0 (unset) skips the check
+func CheckProbedDimensions(model string, probed, configured int) error {
+	if configured <= 0 || probed == configured {
+		return nil
+	}
+	return fmt.Errorf("embedding model %s returns %d dimensions but EMBEDDING_DIMENSIONS=%d: unset EMBEDDING_DIMENSIONS or set it to %d",
+		model, probed, configured, probed)
+}
+
+// TestConnection verifies the API connection works and probes the embedding model's output size,
+// which sizes the pgvector tables and Qdrant collections. EMBEDDING_DIMENSIONS, when set, must match it.
 func (c *Client) TestConnection(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	_, err := c.CreateEmbeddings(ctx, []string{"test"})
+	dimensions, err := c.EmbeddingDimensions(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to %s: %v", c.providerName, err)
 	}
+	if c.cfg != nil {
+		if err := CheckProbedDimensions(c.GetEmbeddingModel(), dimensions, c.cfg.EmbeddingDimensions); err != nil {
+			return err
+		}
+	}
 
-	fmt.Printf("[OPENAI_CLIENT] Connection test successful (%s)\n", c.providerName)
+	fmt.Printf("[OPENAI_CLIENT] Connection test successful (%s, %d embedding dimensions)\n", c.providerName, dimensions)
 	return nil
 }
 
+// EmbeddingDimensions returns the embedding model's output size, measured by embedding a short
+// probe string on the first call and cached afterwards
+func (c *Client) EmbeddingDimensions(ctx context.Context) (int, error) {
+	if c.probe != nil {
+		c.probe.mu.Lock()
+		defer c.probe.mu.Unlock()
+		if c.probe.dimensions > 0 {
+			return c.probe.dimensions, nil
+		}
+	}
+
+	embeddings, err := c.CreateEmbeddings(ctx, []string{dimensionProbeText})
+	if err != nil {
+		return 0, err
+	}
+	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
+		return 0, fmt.Errorf("embedding model %s returned an empty vector", c.GetEmbeddingModel())
+	}
+
+	dimensions := len(embeddings[0])
+	if c.probe != nil {
+		c.probe.dimensions = dimensions
+	}
+	return dimensions, nil
+}
+
 // CreateEmbeddings generates embeddings for the given texts
 func (c *Client) CreateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
 	resp, err := c.primary.CreateEmbeddings(ctx, openai.EmbeddingRequest{
@@ -123,7 +229,7 @@ func (c *Client) CreateChatCompletion(ctx context.Context, messages []openai.Cha
 	if err != nil && c.fallback != nil {
 		// Try fallback provider with OpenAI model name
 		fmt.Printf("[OPENAI_CLIENT] Primary chat failed, trying fallback: %v\n", err)
-		req.Model = string(openai.GPT4oMini)
+		req.Model = chatModel(c.cfg)
 		resp, err = c.fallback.CreateChatCompletion(ctx, req)
 		if err != nil {
 			return nil, fmt.Errorf("both providers failed: %v", err)
@@ -136,6 +242,44 @@ func (c *Client) CreateChatCompletion(ctx context.Context, messages []openai.Cha
 	return &resp, nil
 }
 
+// CreateChatCompletionStream starts a streaming chat completion
+// Token usage arrives in the final chunk. The fallback only covers opening the stream:
+// once deltas reach the caller, a mid-stream failure is returned by Recv.
+func (c *Client) CreateChatCompletionStream(ctx context.Context, messages []openai.ChatCompletionMessage, maxTokens int, temperature float32) (*openai.ChatCompletionStream, error) {
+	req := openai.ChatCompletionRequest{
+		Model:         c.gptModel,
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Temperature:   temperature,
+		Stream:        true,
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	}
+
+	stream, err := c.primary.CreateChatCompletionStream(ctx, req)
+	if err != nil && c.fallback != nil {
+		// Try fallback provider with OpenAI model name
+		fmt.Printf("[OPENAI_CLIENT] Primary chat stream failed, trying fallback: %v\n", err)
+		req.Model = chatModel(c.cfg)
+		stream, err = c.fallback.CreateChatCompletionStream(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("both providers failed: %v", err)
+		}
+		fmt.Printf("[OPENAI_CLIENT] Fallback chat stream succeeded\n")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// WithChatModel returns a copy of the client whose chat completions request model (an Azure
+// deployment name when Azure is primary); the OpenAI fallback keeps CHAT_MODEL
+func (c *Client) WithChatModel(model string) *Client {
+	override := *c
+	override.gptModel = model
+	return &override
+}
+
 // GetProviderName returns the current primary provider name
 func (c *Client) GetProviderName() string {
 	return c.providerName
@@ -0,0 +1,203 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ids/internal/config"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEmbeddingDimensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      string
+		dimensions int
+		wantErr    bool
+	}{
+		{name: "small model default", model: "text-embedding-3-small", dimensions: 1536},
+		{name: "large model", model: "text-embedding-3-large", dimensions: 3072},
+		{name: "large model with small dimensions", model: "text-embedding-3-large", dimensions: 1536, wantErr: true},
+		{name: "small model with large dimensions", model: "text-embedding-3-small", dimensions: 3072, wantErr: true},
+		{name: "unknown deployment accepted", model: "my-local-embedder", dimensions: 768},
+		{name: "unset dimensions accepted", model: "text-embedding-3-large", dimensions: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEmbeddingDimensions(tt.model, tt.dimensions)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "EMBEDDING_DIMENSIONS")
+				assert.Contains(t, err.Error(), tt.model)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewClient_RejectsDimensionMismatch(t *testing.T) {
+	_, err := NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 3072})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "text-embedding-3-small")
+
+	client, err := NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536})
+	require.NoError(t, err)
+	assert.Equal(t, "text-embedding-3-small", client.GetEmbeddingModel())
+}
+
+func TestCheckProbedDimensions(t *testing.T) {
+	assert.NoError(t, CheckProbedDimensions("my-local-embedder", 768, 0), "unset EMBEDDING_DIMENSIONS accepts the probed size")
+	assert.NoError(t, CheckProbedDimensions("my-local-embedder", 768, 768))
+
+	err := CheckProbedDimensions("my-local-embedder", 768, 1536)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "returns 768 dimensions but EMBEDDING_DIMENSIONS=1536")
+}
+
+// newChatServer answers /chat/completions with status, recording the requested model
+func newChatServer(t *testing.T, status int, models *[]string) *openai.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		*models = append(*models, req.Model)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"message": "unavailable"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "ok"}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+func TestCreateChatCompletion_UsesConfiguredChatModel(t *testing.T) {
+	client, err := NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536, ChatModel: "gpt-4.1-mini"})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4.1-mini", client.GetGPTModel())
+
+	var models []string
+	client.primary = newChatServer(t, http.StatusOK, &models)
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "holster"}}
+	_, err = client.CreateChatCompletion(context.Background(), messages, 100, 0.7)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-4.1-mini"}, models)
+
+	// Without CHAT_MODEL (e.g. an unvalidated config) the previous default is kept
+	client, err = NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536})
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultChatModel, client.GetGPTModel())
+}
+
+func TestWithChatModel_OverridesOnlyTheCopy(t *testing.T) {
+	client, err := NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536, ChatModel: "gpt-4o-mini"})
+	require.NoError(t, err)
+
+	var models []string
+	client.primary = newChatServer(t, http.StatusOK, &models)
+	override := client.WithChatModel("gpt-4.1-mini")
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "holster"}}
+	_, err = override.CreateChatCompletion(context.Background(), messages, 100, 0.7)
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), messages, 100, 0.7)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-4.1-mini", "gpt-4o-mini"}, models)
+}
+
+func TestCreateChatCompletion_FallbackUsesChatModel(t *testing.T) {
+	client, err := NewClient(&config.Config{
+		AzureOpenAIEndpoint:      "https://ids.openai.azure.com/",
+		AzureOpenAIKey:           "azure-key",
+		AzureOpenAIGPTDeployment: "ids-chat",
+		OpenAIKey:                "test-key",
+		EmbeddingDimensions:      1536,
+		ChatModel:                "gpt-4.1-mini",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ids-chat", client.GetGPTModel())
+
+	var primaryModels, fallbackModels []string
+	client.primary = newChatServer(t, http.StatusServiceUnavailable, &primaryModels)
+	client.fallback = newChatServer(t, http.StatusOK, &fallbackModels)
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "holster"}}
+	_, err = client.CreateChatCompletion(context.Background(), messages, 100, 0.7)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ids-chat"}, primaryModels)
+	assert.Equal(t, []string{"gpt-4.1-mini"}, fallbackModels)
+}
+
+// newEmbeddingServer answers /embeddings with one vector of the given size per call
+func newEmbeddingServer(t *testing.T, dimensions int, calls *int) *openai.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"data":   []map[string]interface{}{{"object": "embedding", "index": 0, "embedding": make([]float32, dimensions)}},
+			"model":  "text-embedding-3-small",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+func TestEmbeddingDimensions_ProbesOnce(t *testing.T) {
+	client, err := NewClient(&config.Config{OpenAIKey: "test-key", EmbeddingDimensions: 1536})
+	require.NoError(t, err)
+	calls := 0
+	client.primary = newEmbeddingServer(t, 1536, &calls)
+
+	require.NoError(t, client.TestConnection(context.Background()))
+	dimensions, err := client.EmbeddingDimensions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1536, dimensions)
+	assert.Equal(t, 1, calls)
+
+	// Chat model copies share the measured size
+	_, err = client.WithChatModel("gpt-4o").EmbeddingDimensions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTestConnection_RejectsProbedDimensionMismatch(t *testing.T) {
+	// A custom Azure deployment isn't in the known-model table, so only the probe catches the mismatch
+	client, err := NewClient(&config.Config{
+		AzureOpenAIEndpoint:            "https://ids.openai.azure.com/",
+		AzureOpenAIKey:                 "azure-key",
+		AzureOpenAIEmbeddingDeployment: "ids-embeddings",
+		EmbeddingDimensions:            1536,
+	})
+	require.NoError(t, err)
+	calls := 0
+	client.primary = newEmbeddingServer(t, 3072, &calls)
+
+	err = client.TestConnection(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ids-embeddings returns 3072 dimensions but EMBEDDING_DIMENSIONS=1536")
+}
+
+func TestWithInputPrefix(t *testing.T) {
+	assert.Equal(t, "glock holster", WithInputPrefix("", "glock holster"))
+	assert.Equal(t, "glock holster", WithInputPrefix("   ", "glock holster"))
+	assert.Equal(t, "query: glock holster", WithInputPrefix("query:", "glock holster"))
+	assert.Equal(t, "Represent this product for retrieval: Holster", WithInputPrefix("Represent this product for retrieval: ", "Holster"))
+}
@@ -0,0 +1,121 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond // First backoff step, doubled on each retry
+	retryMaxDelay  = 20 * time.Second       // Cap for a single computed backoff step
+)
+
+// retryDoer retries OpenAI HTTP requests that hit a rate limit (429) or a transient 5xx,
+// with exponential backoff and jitter. A Retry-After header overrides the computed delay.
+// Retrying at the HTTP layer covers embeddings and chat alike, and happens per provider,
+// so the Azure -> OpenAI fallback only kicks in once the primary's retries are exhausted.
+type retryDoer struct {
+	next       openai.HTTPDoer
+	maxRetries int           // OPENAI_MAX_RETRIES
+	deadline   time.Duration // OPENAI_RETRY_DEADLINE (0 = bounded by the request context)
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// newRetryDoer wraps next with the retry policy from OPENAI_MAX_RETRIES and OPENAI_RETRY_DEADLINE
+func newRetryDoer(next openai.HTTPDoer, maxRetries int, deadline time.Duration) *retryDoer {
+	return &retryDoer{
+		next:       next,
+		maxRetries: maxRetries,
+		deadline:   deadline,
+		sleep:      sleepContext,
+	}
+}
+
+// Do sends the request, retrying retryable responses until they succeed or retries run out
+// The last response is returned as-is, so callers see the provider's original error
+func (r *retryDoer) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	// Requests with a body that can't be replayed are sent once
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body for retry: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := r.next.Do(req)
+		if err != nil || !replayable || !retryableStatus(resp.StatusCode) || attempt >= r.maxRetries {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp.Header.Get("Retry-After"), time.Now())
+		if r.deadline > 0 && time.Since(start)+delay > r.deadline {
+			fmt.Printf("[OPENAI_CLIENT] Status %d: retry deadline of %s reached, giving up\n", resp.StatusCode, r.deadline)
+			return resp, nil
+		}
+
+		fmt.Printf("[OPENAI_CLIENT] Status %d, retrying in %s (retry %d/%d)\n", resp.StatusCode, delay.Round(time.Millisecond), attempt+1, r.maxRetries)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if err := r.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before retry number attempt+1
+// A Retry-After header (seconds or HTTP date) wins; otherwise the delay doubles from
+// retryBaseDelay up to retryMaxDelay, with jitter so concurrent batches don't retry in lockstep
+func retryDelay(attempt int, retryAfter string, now time.Time) time.Duration {
+	if retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(retryAfter); err == nil {
+			return max(at.Sub(now), 0)
+		}
+	}
+
+	backoff := retryMaxDelay
+	if attempt < 16 {
+		backoff = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	// Equal jitter: wait between half and the full backoff
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
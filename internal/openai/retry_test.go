@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatusServer answers /embeddings with the given statuses in order, then 200 with one vector per call
+func newStatusServer(t *testing.T, statuses []int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		w.Header().Set("Content-Type", "application/json")
+		if n <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[n-1])
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"message": "Rate limit reached", "type": "requests"},
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"data":   []map[string]interface{}{{"object": "embedding", "index": 0, "embedding": []float32{0.1, 0.2}}},
+			"model":  "text-embedding-3-small",
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newRetryingTestClient returns a unified client pointed at server, recording backoff delays instead of sleeping
+func newRetryingTestClient(server *httptest.Server, maxRetries int, deadline time.Duration, delays *[]time.Duration) *Client {
+	doer := newRetryDoer(&http.Client{}, maxRetries, deadline)
+	doer.sleep = func(_ context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	clientConfig.HTTPClient = doer
+	return &Client{
+		primary:      openai.NewClientWithConfig(clientConfig),
+		embedModel:   openai.SmallEmbedding3,
+		providerName: "OpenAI",
+	}
+}
+
+func TestCreateEmbeddings_RetriesRateLimits(t *testing.T) {
+	server, calls := newStatusServer(t, []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, "")
+	var delays []time.Duration
+	client := newRetryingTestClient(server, 3, 0, &delays)
+
+	embeddings, err := client.CreateEmbeddings(context.Background(), []string{"holster"})
+	require.NoError(t, err)
+	assert.Len(t, embeddings, 1)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Len(t, delays, 2)
+}
+
+func TestCreateEmbeddings_HonorsRetryAfter(t *testing.T) {
+	server, _ := newStatusServer(t, []int{http.StatusTooManyRequests}, "7")
+	var delays []time.Duration
+	client := newRetryingTestClient(server, 3, 0, &delays)
+
+	_, err := client.CreateEmbeddings(context.Background(), []string{"holster"})
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{7 * time.Second}, delays)
+}
+
+func TestCreateEmbeddings_SurfacesQuotaErrorAfterRetries(t *testing.T) {
+	statuses := []int{429, 429, 429, 429, 429}
+	server, calls := newStatusServer(t, statuses, "")
+	var delays []time.Duration
+	client := newRetryingTestClient(server, 3, 0, &delays)
+
+	_, err := client.CreateEmbeddings(context.Background(), []string{"holster"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "429")
+	assert.Equal(t, int32(4), calls.Load(), "one attempt plus three retries")
+}
+
+func TestCreateEmbeddings_DeadlineStopsRetries(t *testing.T) {
+	server, calls := newStatusServer(t, []int{429, 429}, "30")
+	var delays []time.Duration
+	client := newRetryingTestClient(server, 3, 10*time.Second, &delays)
+
+	_, err := client.CreateEmbeddings(context.Background(), []string{"holster"})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load(), "a Retry-After past the deadline is not waited out")
+	assert.Empty(t, delays)
+}
+
+func TestCreateEmbeddings_DoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newStatusServer(t, []int{http.StatusBadRequest}, "")
+	var delays []time.Duration
+	client := newRetryingTestClient(server, 3, 0, &delays)
+
+	_, err := client.CreateEmbeddings(context.Background(), []string{"holster"})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 3*time.Second, retryDelay(0, "3", now))
+	assert.Equal(t, 90*time.Second, retryDelay(0, now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), retryDelay(0, now.Add(-time.Minute).Format(http.TimeFormat), now))
+
+	for attempt := 0; attempt < 6; attempt++ {
+		backoff := min(retryBaseDelay<<attempt, retryMaxDelay)
+		delay := retryDelay(attempt, "", now)
+		assert.GreaterOrEqual(t, delay, backoff/2)
+		assert.LessOrEqual(t, delay, backoff)
+	}
+	assert.LessOrEqual(t, retryDelay(100, "not-a-number", now), retryMaxDelay)
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"context"
+	"database/sql"
+	"strconv"
+	"strings"
 	"time"
 
 	"ids/internal/analytics"
@@ -9,8 +12,10 @@ import (
 	"ids/internal/cache"
 	"ids/internal/config"
 	"ids/internal/database"
+	"ids/internal/emails"
 	"ids/internal/embeddings"
 	"ids/internal/handlers"
+	"ids/internal/utils"
 	"ids/internal/vectordb"
 
 	"github.com/jmoiron/sqlx"
@@ -23,15 +28,22 @@ import (
 // Server represents the application server
 type Server struct {
 	echo                *echo.Echo
-	db                  *sqlx.DB
+	readDB              *database.ReadDB // Product database; replaced in the background when it reconnects
 	writeClient         *database.WriteClient
 	config              *config.Config
 	logger              zerolog.Logger
 	cache               *cache.Cache
 	embeddingService    *embeddings.EmbeddingService
+	emailService        *emails.EmailEmbeddingService
+	writeEmbedding      *embeddings.WriteEmbeddingService // Reads products for regenerationJob; nil when unavailable
+	regenerationJob     *handlers.EmbeddingRegenerationJob
 	analyticsService    *analytics.Service
 	conversationService *database.ConversationService
 	authManager         *auth.Manager
+	poolSettings        database.PoolSettings
+
+	background     context.Context    // Bounds work the server runs besides requests (read DB reconnects)
+	stopBackground context.CancelFunc // Called by Shutdown
 }
 
 // New creates a new server instance
@@ -40,7 +52,7 @@ func New(cfg *config.Config, db *sqlx.DB, logger zerolog.Logger) *Server {
 	var writeClient *database.WriteClient
 	if cfg.EmbeddingsDatabaseURL != "" {
 		var err error
-		writeClient, err = database.NewWriteClient(cfg.EmbeddingsDatabaseURL)
+		writeClient, err = database.NewWriteClient(cfg.EmbeddingsDatabaseURL, cfg.EmbeddingsSchema)
 		if err != nil {
 			logger.Warn().Err(err).Msg("Failed to initialize embeddings database connection")
 		} else {
@@ -48,14 +60,40 @@ func New(cfg *config.Config, db *sqlx.DB, logger zerolog.Logger) *Server {
 		}
 	}
 
-	// Initialize cache for query embeddings
-	embeddingCache := cache.New()
+	// Size both connection pools so the chat server and the embedding jobs share a known budget
+	poolSettings := database.PoolSettings{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.DBConnMaxLifetime) * time.Second,
+	}
+	if db != nil {
+		database.ApplyPoolSettings(db, poolSettings)
+	}
+	if writeClient != nil {
+		writeClient.ApplyPoolSettings(poolSettings)
+	}
+
+	// Store-specific shipping countries and transit times for shipping inquiries
+	if err := handlers.LoadShippingConfig(cfg.ShippingConfigFile); err != nil {
+		logger.Warn().Err(err).Msg("Failed to load shipping config, using bundled shipping data")
+	}
+
+	// Response languages and their prompt instructions; other detected languages are answered in English
+	if err := utils.LoadLanguageConfig(cfg.LanguagesConfigFile); err != nil {
+		logger.Warn().Err(err).Msg("Failed to load languages config, using bundled languages")
+	}
+
+	// Initialize cache for query embeddings, session token totals and other short-lived entries
+	// The sweeper removes entries that expire without being read again
+	embeddingCache := cache.NewWithSweep(cache.DefaultSweepInterval)
+	embeddingCache.SetEmbeddingTTL(time.Duration(cfg.EmbeddingCacheTTL) * time.Second)
 	logger.Info().Msg("Query embedding cache initialized")
 
 	// Initialize embedding service if OpenAI API key is available
 	// Note: db (MariaDB) is only used for reading product data when generating embeddings
 	// writeClient (PostgreSQL) is used for searching embeddings
 	var embeddingService *embeddings.EmbeddingService
+	var searchQdrantClient *vectordb.QdrantClient
 	if cfg.OpenAIKey != "" && writeClient != nil {
 		var err error
 		embeddingService, err = embeddings.NewEmbeddingService(cfg, db, writeClient, embeddingCache)
@@ -78,6 +116,7 @@ func New(cfg *config.Config, db *sqlx.DB, logger zerolog.Logger) *Server {
 					qdrantClient = nil
 				} else {
 					embeddingService.SetQdrantClient(qdrantClient, cfg.QdrantEnabled)
+					searchQdrantClient = qdrantClient
 					if cfg.QdrantEnabled {
 						logger.Info().Str("url", cfg.QdrantURL).Msg("Qdrant search enabled")
 					} else {
@@ -88,6 +127,50 @@ func New(cfg *config.Config, db *sqlx.DB, logger zerolog.Logger) *Server {
 		}
 	}
 
+	// Initialize write embedding service for admin-triggered regeneration
+	// Shares the Qdrant client so on-demand runs keep the dual-write in sync
+	// Without a product database yet, it gets the connection once the reconnect loop restores it
+	var writeEmbeddingService *embeddings.WriteEmbeddingService
+	if cfg.OpenAIKey != "" && writeClient != nil {
+		var productDB *sql.DB
+		if db != nil {
+			productDB = db.DB
+		}
+		var err error
+		writeEmbeddingService, err = embeddings.NewWriteEmbeddingService(cfg, productDB, writeClient, searchQdrantClient)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to initialize write embedding service, on-demand regeneration disabled")
+		} else {
+			logger.Info().Msg("Write embedding service initialized for on-demand regeneration")
+		}
+	}
+
+	// Create the tables and apply pending schema migrations before the services use them
+	// Vector columns follow the embedding model's size, as probed by the write embedding service
+	if writeClient != nil {
+		vectorDimensions := vectordb.VectorDimensions
+		if writeEmbeddingService != nil {
+			vectorDimensions = writeEmbeddingService.VectorDimensions()
+		}
+		if applied, err := writeClient.MigrateSchema(vectorDimensions); err != nil {
+			logger.Warn().Err(err).Msg("Failed to apply schema migrations")
+		} else if applied > 0 {
+			logger.Info().Int("applied", applied).Msg("Schema migrations applied")
+		}
+	}
+
+	// Initialize email embedding service for admin thread maintenance
+	var emailService *emails.EmailEmbeddingService
+	if cfg.OpenAIKey != "" && writeClient != nil {
+		var err error
+		emailService, err = emails.NewEmailEmbeddingService(cfg, writeClient, embeddingCache)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to initialize email embedding service")
+		} else {
+			logger.Info().Msg("Email embedding service initialized successfully")
+		}
+	}
+
 	// Initialize analytics service
 	var analyticsService *analytics.Service
 	if writeClient != nil {
@@ -100,6 +183,12 @@ func New(cfg *config.Config, db *sqlx.DB, logger zerolog.Logger) *Server {
 		}
 	}
 
+	// Admin-triggered regeneration runs on the write embedding service and is tracked in analytics
+	var regenerationJob *handlers.EmbeddingRegenerationJob
+	if writeEmbeddingService != nil {
+		regenerationJob = handlers.NewEmbeddingRegenerationJob(writeEmbeddingService, analyticsService)
+	}
+
 	// Initialize conversation service
 	var conversationService *database.ConversationService
 	if writeClient != nil {
@@ -115,13 +204,21 @@ func New(cfg *config.Config, db *sqlx.DB, logger zerolog.Logger) *Server {
 	// Initialize auth manager
 	authManager := auth.NewManager(cfg)
 
+	background, stopBackground := context.WithCancel(context.Background())
+
 	return &Server{
+		background:          background,
+		stopBackground:      stopBackground,
 		config:              cfg,
-		db:                  db,
+		readDB:              database.NewReadDB(db),
+		poolSettings:        poolSettings,
 		writeClient:         writeClient,
 		logger:              logger,
 		cache:               embeddingCache,
 		embeddingService:    embeddingService,
+		emailService:        emailService,
+		writeEmbedding:      writeEmbeddingService,
+		regenerationJob:     regenerationJob,
 		analyticsService:    analyticsService,
 		conversationService: conversationService,
 		authManager:         authManager,
@@ -144,6 +241,7 @@ func (s *Server) zerologMiddleware() echo.MiddlewareFunc {
 				Str("uri", req.RequestURI).
 				Str("remote_ip", c.RealIP()).
 				Int("status", res.Status).
+				Str("request_id", res.Header().Get(echo.HeaderXRequestID)).
 				Int64("latency_ms", time.Since(start).Milliseconds()).
 				Str("user_agent", req.UserAgent()).
 				Msg("HTTP request")
@@ -153,11 +251,42 @@ func (s *Server) zerologMiddleware() echo.MiddlewareFunc {
 	}
 }
 
+// gzipMiddleware compresses API responses of at least minLength bytes (GZIP_MIN_LENGTH)
+// Server-sent event streams are skipped: gzip buffers output and would hold back events
+func gzipMiddleware(minLength int) echo.MiddlewareFunc {
+	return middleware.GzipWithConfig(middleware.GzipConfig{
+		MinLength: minLength,
+		Skipper:   isEventStreamRequest,
+	})
+}
+
+// bodyLimitMiddleware rejects API request bodies over limit bytes with 413 (MAX_REQUEST_BODY_BYTES)
+// Registered after Decompress, so gzip request bodies are limited by their decompressed size.
+// A limit of 0 disables the check.
+func bodyLimitMiddleware(limit int) echo.MiddlewareFunc {
+	return middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Limit: strconv.Itoa(limit),
+		Skipper: func(echo.Context) bool {
+			return limit <= 0
+		},
+	})
+}
+
+// isEventStreamRequest reports whether a request is for a server-sent event stream
+func isEventStreamRequest(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream") ||
+		strings.HasSuffix(c.Path(), "/stream")
+}
+
 // Initialize sets up the Echo framework with middleware and routes
 func (s *Server) Initialize() {
 	s.echo = echo.New()
 
+	// Consistent JSON error envelope for errors returned by handlers and middleware
+	s.echo.HTTPErrorHandler = handlers.ErrorHandler
+
 	// Middleware
+	s.echo.Use(middleware.RequestID())
 	s.echo.Use(s.zerologMiddleware())
 	s.echo.Use(middleware.Recover())
 
@@ -177,15 +306,20 @@ func (s *Server) setupRoutes() {
 	api.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"*"}, // Allow all origins
 		AllowMethods:     []string{echo.GET, echo.POST, echo.PUT, echo.DELETE, echo.PATCH, echo.OPTIONS, echo.HEAD},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderXRequestedWith},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderXRequestedWith, handlers.HeaderModelOverride},
 		ExposeHeaders:    []string{echo.HeaderContentLength, echo.HeaderContentType, echo.HeaderContentDisposition},
 		AllowCredentials: false, // Set to false when using wildcard origins
 		MaxAge:           86400, // Cache preflight for 24 hours
 	}))
 
+	// Accept gzip request bodies (capped at MAX_REQUEST_BODY_BYTES) and compress responses for clients that support it
+	api.Use(middleware.Decompress())
+	api.Use(bodyLimitMiddleware(s.config.MaxRequestBodyBytes))
+	api.Use(gzipMiddleware(s.config.GzipMinLength))
+
 	// Health endpoints moved under /api prefix
 	api.GET("/healthz", handlers.HealthHandler(s.config.Version))
-	api.GET("/healthz/db", handlers.DBHealthHandler(s.db))
+	api.GET("/healthz/db", handlers.DBHealthHandler(s.readDB, s.writeClient))
 
 	// Swagger redirects (must be before wildcard route)
 	s.echo.GET("/swagger", func(c echo.Context) error {
@@ -204,16 +338,32 @@ func (s *Server) setupRoutes() {
 	api.GET("/config", handlers.ConfigHandler(s.config.GoogleAnalyticsID))
 
 	// Chat endpoint with product and email context (requires embedding service and write client)
+	// Both chat endpoints share one per-conversation rate limit (CHAT_RATE_LIMIT_PER_MINUTE)
 	if s.writeClient != nil && s.embeddingService != nil {
-		api.POST("/chat", handlers.ChatHandler(s.db, s.config, s.cache, s.embeddingService, s.writeClient, s.analyticsService, s.conversationService))
+		chatRateLimit := handlers.NewChatRateLimiter(s.config.MaxChatRequestsPerMinute).Middleware()
+		api.POST("/chat", handlers.ChatHandler(s.readDB, s.config, s.cache, s.embeddingService, s.writeClient, s.analyticsService, s.conversationService), chatRateLimit)
+		api.POST("/chat/stream", handlers.ChatStreamHandler(s.readDB, s.config, s.cache, s.embeddingService, s.writeClient, s.analyticsService, s.conversationService), chatRateLimit)
+	}
+
+	// JSON product search endpoint (requires embedding service)
+	if s.embeddingService != nil {
+		api.GET("/products/search", handlers.ProductSearchHandler(s.config, s.embeddingService))
 	}
 
 	// Support escalation endpoint
 	api.POST("/chat/request-support", handlers.SupportRequestHandler(s.config, s.analyticsService, s.conversationService))
 
+	// Conversation history endpoints (require conversation storage)
+	// Transcripts hold visitors' messages, so reading one back requires admin authentication like listing them
+	if s.conversationService != nil {
+		api.GET("/sessions/:session_id", handlers.SessionHistoryHandler(s.conversationService), auth.Middleware(s.authManager))
+		api.GET("/sessions", handlers.SessionListHandler(s.conversationService), auth.Middleware(s.authManager))
+	}
+
 	// Analytics endpoints
 	if s.analyticsService != nil {
 		api.GET("/analytics", handlers.AnalyticsHandler(s.analyticsService))
+		api.GET("/analytics/range", handlers.AnalyticsRangeHandler(s.analyticsService))
 		api.GET("/analytics/daily-report", handlers.DailyReportHandler(s.analyticsService))
 		api.GET("/analytics/weekly-report", handlers.WeeklyReportHandler(s.analyticsService, s.config))
 	}
@@ -233,6 +383,41 @@ func (s *Server) setupRoutes() {
 	adminSessions.GET("/:sessionId", handlers.GetSessionHandler(s.conversationService))
 	adminSessions.GET("/:sessionId/email", handlers.GetSessionEmailHandler(s.conversationService))
 
+	// Admin embeddings endpoints (require authentication)
+	adminEmbeddings := admin.Group("/embeddings")
+	adminEmbeddings.Use(auth.Middleware(s.authManager))
+	if s.analyticsService != nil {
+		adminEmbeddings.GET("/freshness", handlers.EmbeddingFreshnessHandler(s.analyticsService))
+	}
+	if s.embeddingService != nil {
+		adminEmbeddings.GET("/missing", handlers.ListMissingEmbeddingsHandler(s.embeddingService))
+	}
+
+	// Admin raw analytics export (require authentication)
+	if s.analyticsService != nil {
+		adminAnalytics := admin.Group("/analytics")
+		adminAnalytics.Use(auth.Middleware(s.authManager))
+		adminAnalytics.GET("/export", handlers.AnalyticsExportHandler(s.analyticsService))
+	}
+
+	// Admin on-demand embedding regeneration (require authentication)
+	// Registered even while the product database is down; triggering a run then returns 503
+	if s.regenerationJob != nil {
+		adminRegenerate := admin.Group("/regenerate-embeddings")
+		adminRegenerate.Use(auth.Middleware(s.authManager))
+		adminRegenerate.POST("", handlers.RegenerateEmbeddingsHandler(s.regenerationJob, s.readDB))
+		adminRegenerate.GET("/status", handlers.RegenerateEmbeddingsStatusHandler(s.regenerationJob))
+	}
+
+	// Admin email thread endpoints (require authentication)
+	if s.emailService != nil {
+		adminThreads := admin.Group("/threads")
+		adminThreads.Use(auth.Middleware(s.authManager))
+		adminThreads.POST("/merge", handlers.MergeThreadsHandler(s.emailService))
+		adminThreads.POST("/rebuild-aggregates", handlers.RebuildThreadAggregatesHandler(s.emailService))
+		adminThreads.GET("/by-participant", handlers.ThreadsByParticipantHandler(s.emailService))
+	}
+
 	// Handle favicon requests
 	s.echo.GET("/favicon.ico", func(c echo.Context) error {
 		return c.NoContent(204) // No content response for favicon
@@ -251,7 +436,42 @@ func (s *Server) setupRoutes() {
 }
 
 // Start starts the HTTP server
+// It returns http.ErrServerClosed after Shutdown.
 func (s *Server) Start() error {
+	if s.config.DatabaseURL != "" {
+		go s.readDB.Maintain(s.background, database.DefaultReconnectPolicy, s.connectReadDB, s.onReadDBConnected)
+	}
+
 	s.logger.Info().Str("port", s.config.Port).Msg("Server starting")
 	return s.echo.Start(":" + s.config.Port)
 }
+
+// Shutdown stops accepting requests and waits until in-flight ones finish or ctx is done, then
+// stops the read database reconnects and the cache sweeper
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.echo.Shutdown(ctx)
+	s.stopBackground()
+	s.cache.Close()
+	return err
+}
+
+// connectReadDB opens a new product database connection sized like the startup one
+func (s *Server) connectReadDB() (*sqlx.DB, error) {
+	db, err := database.New(s.config.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	database.ApplyPoolSettings(db, s.poolSettings)
+	return db, nil
+}
+
+// onReadDBConnected hands a restored product database connection to the services reading from it
+func (s *Server) onReadDBConnected(db *sqlx.DB) {
+	s.logger.Info().Msg("Database connection restored")
+	if s.embeddingService != nil {
+		s.embeddingService.SetProductDB(db)
+	}
+	if s.writeEmbedding != nil {
+		s.writeEmbedding.SetProductDB(db.DB)
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ids/internal/cache"
+	"ids/internal/handlers"
+	"ids/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGzipTestEcho serves a large JSON body, a small JSON body and an event stream under /api
+func newGzipTestEcho() *echo.Echo {
+	e := echo.New()
+	api := e.Group("/api")
+	api.Use(gzipMiddleware(1024))
+
+	products := make([]map[string]interface{}, 100)
+	for i := range products {
+		products[i] = map[string]interface{}{"id": i + 1, "name": "Tactical holster with retention and belt clip"}
+	}
+	api.GET("/search", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{"products": products})
+	})
+	api.GET("/healthz", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	})
+	api.GET("/chat/stream", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		return c.String(http.StatusOK, strings.Repeat("data: {\"token\":\"holster\"}\n\n", 100))
+	})
+	return e
+}
+
+func TestGzipMiddleware_CompressesLargeJSON(t *testing.T) {
+	e := newGzipTestEcho()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+
+	reader, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	var decoded map[string][]map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Len(t, decoded["products"], 100)
+}
+
+func TestGzipMiddleware_SkipsWithoutAcceptEncoding(t *testing.T) {
+	e := newGzipTestEcho()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search", nil))
+
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.True(t, json.Valid(rec.Body.Bytes()))
+}
+
+func TestGzipMiddleware_SkipsSmallResponses(t *testing.T) {
+	e := newGzipTestEcho()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/healthz", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestGzipMiddleware_SkipsEventStreams(t *testing.T) {
+	e := newGzipTestEcho()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chat/stream", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "data: "))
+}
+
+// newBodyLimitTestEcho echoes the length of POST /api/chat bodies behind a 1 KB limit
+func newBodyLimitTestEcho() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	api := e.Group("/api")
+	api.Use(middleware.Decompress())
+	api.Use(bodyLimitMiddleware(1024))
+	api.POST("/chat", func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, map[string]int{"length": len(body)})
+	})
+	return e
+}
+
+func TestBodyLimitMiddleware_RejectsOversizedBodies(t *testing.T) {
+	e := newBodyLimitTestEcho()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(strings.Repeat("a", 2048))))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	var apiErr models.APIError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, apiErr.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"length":16}`, rec.Body.String())
+}
+
+func TestBodyLimitMiddleware_LimitsDecompressedSize(t *testing.T) {
+	e := newBodyLimitTestEcho()
+
+	// 64 KB of zeros compresses far below the limit
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(make([]byte, 64*1024))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.Less(t, compressed.Len(), 1024)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", &compressed)
+	req.Header.Set(echo.HeaderContentEncoding, "gzip")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestBodyLimitMiddleware_ZeroDisables(t *testing.T) {
+	e := echo.New()
+	e.POST("/api/chat", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, bodyLimitMiddleware(0))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(strings.Repeat("a", 4096))))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestShutdown_StopsBackgroundWork(t *testing.T) {
+	background, stopBackground := context.WithCancel(context.Background())
+	embeddingCache := cache.NewWithSweep(time.Millisecond)
+	s := &Server{echo: echo.New(), cache: embeddingCache, background: background, stopBackground: stopBackground}
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	assert.ErrorIs(t, background.Err(), context.Canceled)
+
+	// The cache stays usable for requests that finish after shutdown; Close is idempotent
+	embeddingCache.Set("key", "value", time.Minute)
+	_, found := embeddingCache.Get("key")
+	assert.True(t, found)
+	embeddingCache.Close()
+}
@@ -1,8 +1,13 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"unicode"
 )
 
 // Language codes
@@ -14,8 +19,157 @@ const (
 	LangChinese  = "zh"
 	LangJapanese = "ja"
 	LangKorean   = "ko"
+
+	LangSpanish    = "es"
+	LangFrench     = "fr"
+	LangGerman     = "de"
+	LangItalian    = "it"
+	LangPortuguese = "pt"
+)
+
+// LanguageConfig is a language the assistant answers in (LANGUAGES_CONFIG_FILE)
+type LanguageConfig struct {
+	Code        string   `json:"code"`                // ISO 639-1 code, as returned by DetectLanguage
+	Name        string   `json:"name"`                // English name of the language
+	Instruction string   `json:"instruction"`         // Added to the system prompt; empty = the bundled or a generic instruction
+	Stopwords   []string `json:"stopwords,omitempty"` // Lowercase words that identify a Latin-script language
+}
+
+// LanguagesFile is the JSON layout of LANGUAGES_CONFIG_FILE
+type LanguagesFile struct {
+	Languages []LanguageConfig `json:"languages"`
+}
+
+// defaultLanguages is the bundled language set, used when no LANGUAGES_CONFIG_FILE is set
+// Latin-script languages with stopwords are told apart in this order on ties; English comes first.
+var defaultLanguages = []LanguageConfig{
+	{LangEnglish, "English", "Please respond in English.", []string{"the", "and", "is", "are", "you", "do", "does", "have", "has", "for", "with", "what", "how", "can", "this", "that", "my", "it", "of", "to", "in", "i", "any", "your"}},
+	{LangHebrew, "Hebrew", "Please respond in Hebrew (עברית).", nil},
+	{LangArabic, "Arabic", "Please respond in Arabic (العربية).", nil},
+	{LangRussian, "Russian", "Please respond in Russian (Русский).", nil},
+	{LangChinese, "Chinese", "Please respond in Chinese (中文).", nil},
+	{LangJapanese, "Japanese", "Please respond in Japanese (日本語).", nil},
+	{LangKorean, "Korean", "Please respond in Korean (한국어).", nil},
+	{LangSpanish, "Spanish", "Please respond in Spanish (Español).", []string{"el", "los", "las", "y", "es", "un", "una", "por", "para", "con", "tiene", "tienen", "hola", "gracias", "cómo", "qué", "mi", "usted", "hay", "pero"}},
+	{LangFrench, "French", "Please respond in French (Français).", []string{"le", "les", "des", "est", "et", "une", "pour", "avec", "vous", "je", "bonjour", "merci", "pas", "du", "sur", "avez", "mon", "ce", "qui"}},
+	{LangGerman, "German", "Please respond in German (Deutsch).", []string{"der", "die", "das", "und", "ist", "ich", "nicht", "mit", "für", "ein", "eine", "haben", "sie", "wie", "danke", "hallo", "mein", "auf", "gibt"}},
+	{LangItalian, "Italian", "Please respond in Italian (Italiano).", []string{"il", "lo", "gli", "della", "che", "è", "per", "sono", "ciao", "grazie", "questo", "non", "avete", "mio", "anche", "di"}},
+	{LangPortuguese, "Portuguese", "Please respond in Portuguese (Português).", []string{"o", "os", "é", "em", "um", "uma", "com", "não", "você", "obrigado", "olá", "do", "da", "vocês", "tem", "meu"}},
+}
+
+var (
+	languagesMu     sync.RWMutex
+	activeLanguages = defaultLanguages
 )
 
+// LoadLanguageConfig replaces the bundled language set with the JSON file at path
+// An empty path keeps the bundled default. Only the listed languages are answered in; anything
+// else falls back to English, which is always kept. Instructions and stopwords left empty for a
+// bundled language fall back to the bundled values.
+func LoadLanguageConfig(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read languages config file %s: %v", path, err)
+	}
+
+	var loaded LanguagesFile
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse languages config file %s: %v", path, err)
+	}
+	languages, err := withLanguageDefaults(loaded.Languages)
+	if err != nil {
+		return fmt.Errorf("invalid languages config file %s: %v", path, err)
+	}
+
+	setLanguages(languages)
+	fmt.Printf("[LANGUAGE] Loaded %d languages from %s\n", len(languages), path)
+	return nil
+}
+
+// withLanguageDefaults normalizes configured languages and fills empty fields from the bundled set
+// English is moved to the front (added when missing), since it is the fallback and wins ties.
+func withLanguageDefaults(configured []LanguageConfig) ([]LanguageConfig, error) {
+	if len(configured) == 0 {
+		return nil, fmt.Errorf("no languages listed")
+	}
+
+	bundled := make(map[string]LanguageConfig, len(defaultLanguages))
+	for _, lang := range defaultLanguages {
+		bundled[lang.Code] = lang
+	}
+
+	english := bundled[LangEnglish]
+	languages := []LanguageConfig{english}
+	seen := make(map[string]bool, len(configured))
+	for _, lang := range configured {
+		lang.Code = strings.ToLower(strings.TrimSpace(lang.Code))
+		if lang.Code == "" {
+			return nil, fmt.Errorf("language %q has no code", lang.Name)
+		}
+		if seen[lang.Code] {
+			return nil, fmt.Errorf("language %s is listed twice", lang.Code)
+		}
+		seen[lang.Code] = true
+
+		fallback, known := bundled[lang.Code]
+		if lang.Name == "" {
+			lang.Name = fallback.Name
+		}
+		if lang.Name == "" {
+			return nil, fmt.Errorf("language %s has no name", lang.Code)
+		}
+		if lang.Instruction == "" {
+			lang.Instruction = fallback.Instruction
+		}
+		if lang.Instruction == "" {
+			lang.Instruction = "Please respond in " + lang.Name + "."
+		}
+		if known && len(lang.Stopwords) == 0 {
+			lang.Stopwords = fallback.Stopwords
+		}
+		stopwords := make([]string, 0, len(lang.Stopwords))
+		for _, word := range lang.Stopwords {
+			stopwords = append(stopwords, strings.ToLower(strings.TrimSpace(word)))
+		}
+		lang.Stopwords = stopwords
+
+		if lang.Code == LangEnglish {
+			languages[0] = lang
+			continue
+		}
+		languages = append(languages, lang)
+	}
+	return languages, nil
+}
+
+// setLanguages swaps the language set used by DetectLanguage and GetLanguageInstruction
+func setLanguages(languages []LanguageConfig) {
+	languagesMu.Lock()
+	defer languagesMu.Unlock()
+	activeLanguages = languages
+}
+
+// currentLanguages returns the language set in use (English first)
+func currentLanguages() []LanguageConfig {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	return activeLanguages
+}
+
+// configuredLanguage returns the configured language with code
+func configuredLanguage(code string) (LanguageConfig, bool) {
+	for _, lang := range currentLanguages() {
+		if lang.Code == code {
+			return lang, true
+		}
+	}
+	return LanguageConfig{}, false
+}
+
 // Language represents a detected language
 type Language struct {
 	Code       string
@@ -41,8 +195,12 @@ func DetectLanguage(text string) Language {
 	// Calculate script ratios for different languages
 	ratios := calculateScriptRatios(text)
 
-	// Determine the language with the highest ratio
-	return determineLanguageFromRatios(ratios, text)
+	// Determine the language with the highest ratio; languages outside the configured set are answered in English
+	detected := determineLanguageFromRatios(ratios, text)
+	if _, ok := configuredLanguage(detected.Code); !ok {
+		return Language{Code: LangEnglish, Name: "English", Confidence: detected.Confidence}
+	}
+	return detected
 }
 
 // calculateScriptRatios calculates the ratio of characters for each script
@@ -114,6 +272,11 @@ func determineLanguageFromRatios(ratios []ScriptRatio, text string) Language {
 		}
 	}
 
+	// Latin script only: tell English from other European languages by their stopwords
+	if bestMatch.Code == LangEnglish {
+		return detectLatinLanguage(text)
+	}
+
 	// Special handling for Chinese vs Japanese
 	if bestMatch.Code == LangChinese || bestMatch.Code == LangJapanese {
 		return handleChineseJapanese(ratios, bestMatch, text)
@@ -122,6 +285,44 @@ func determineLanguageFromRatios(ratios []ScriptRatio, text string) Language {
 	return Language{Code: bestMatch.Code, Name: bestMatch.Name, Confidence: bestMatch.Ratio}
 }
 
+// detectLatinLanguage picks the configured Latin-script language whose stopwords occur most often
+// Confidence is the share of words that are stopwords of that language. Another language
+// only wins over English with at least two hits, so product names never flip the reply language.
+func detectLatinLanguage(text string) Language {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) == 0 {
+		return Language{Code: LangEnglish, Name: "English", Confidence: 0.0}
+	}
+
+	languages := currentLanguages() // English first
+	hits := make([]int, len(languages))
+	for i, lang := range languages {
+		for _, word := range words {
+			for _, stopword := range lang.Stopwords {
+				if word == stopword {
+					hits[i]++
+					break
+				}
+			}
+		}
+	}
+
+	best := 0 // English
+	for i := 1; i < len(languages); i++ {
+		if hits[i] >= 2 && hits[i] > hits[best] {
+			best = i
+		}
+	}
+
+	return Language{
+		Code:       languages[best].Code,
+		Name:       languages[best].Name,
+		Confidence: float64(hits[best]) / float64(len(words)),
+	}
+}
+
 // handleChineseJapanese handles the special case of distinguishing Chinese from Japanese
 func handleChineseJapanese(ratios []ScriptRatio, bestMatch ScriptRatio, text string) Language {
 	// Check for Hiragana/Katakana characters to distinguish Japanese from Chinese
@@ -138,32 +339,30 @@ func handleChineseJapanese(ratios []ScriptRatio, bestMatch ScriptRatio, text str
 	return Language{Code: LangChinese, Name: "Chinese", Confidence: bestMatch.Ratio}
 }
 
+// FallbackToEnglish returns English when lang was detected with less than minConfidence,
+// so a misdetected language never overrides the default; minConfidence 0 disables the check
+func FallbackToEnglish(lang Language, minConfidence float64) Language {
+	if minConfidence > 0 && lang.Code != LangEnglish && lang.Confidence < minConfidence {
+		return Language{Code: LangEnglish, Name: "English", Confidence: lang.Confidence}
+	}
+	return lang
+}
+
 // GetLanguageInstruction returns a language instruction for the AI based on detected language
-func GetLanguageInstruction(lang Language) string {
-	switch lang.Code {
-	case LangHebrew:
-		return "Please respond in Hebrew (עברית)."
-	case LangArabic:
-		return "Please respond in Arabic (العربية)."
-	case LangRussian:
-		return "Please respond in Russian (Русский)."
-	case LangChinese:
-		return "Please respond in Chinese (中文)."
-	case LangJapanese:
-		return "Please respond in Japanese (日本語)."
-	case LangKorean:
-		return "Please respond in Korean (한국어)."
-	case "es":
-		return "Please respond in Spanish (Español)."
-	case "fr":
-		return "Please respond in French (Français)."
-	case "de":
-		return "Please respond in German (Deutsch)."
-	case "it":
-		return "Please respond in Italian (Italiano)."
-	case "pt":
-		return "Please respond in Portuguese (Português)."
-	default:
-		return "Please respond in English."
+// Detections below minConfidence and languages outside the configured set fall back to English
+func GetLanguageInstruction(lang Language, minConfidence float64) string {
+	if configured, ok := configuredLanguage(FallbackToEnglish(lang, minConfidence).Code); ok {
+		return configured.Instruction
+	}
+	english, _ := configuredLanguage(LangEnglish)
+	return english.Instruction
+}
+
+// ResponseLanguageMismatch reports whether a generated response is not in the requested language
+// English requests are never flagged since product names and prices are always in Latin script
+func ResponseLanguageMismatch(response string, requested Language) bool {
+	if requested.Code == LangEnglish || strings.TrimSpace(response) == "" {
+		return false
 	}
+	return DetectLanguage(response).Code != requested.Code
 }
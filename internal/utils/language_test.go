@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -55,6 +58,41 @@ func TestDetectLanguage(t *testing.T) {
 			input:    "Hello שלום world",
 			expected: "he",
 		},
+		{
+			name:     "Hebrew question with English product name",
+			input:    "יש לכם נרתיק ל-Glock 19 במלאי?",
+			expected: "he",
+		},
+		{
+			name:     "Mixed Cyrillic and Latin",
+			input:    "Есть ли у вас кобура для Glock 19?",
+			expected: "ru",
+		},
+		{
+			name:     "English with product names",
+			input:    "Do you have the Masada holster for Glock 19 in stock?",
+			expected: "en",
+		},
+		{
+			name:     "Spanish text",
+			input:    "Hola, ¿tienen fundas para la Glock 19?",
+			expected: "es",
+		},
+		{
+			name:     "French text",
+			input:    "Bonjour, avez-vous des étuis pour le Glock 19?",
+			expected: "fr",
+		},
+		{
+			name:     "German text",
+			input:    "Hallo, gibt es ein Holster für die Glock 19?",
+			expected: "de",
+		},
+		{
+			name:     "Single foreign word stays English",
+			input:    "Gracias",
+			expected: "en",
+		},
 	}
 
 	for _, tt := range tests {
@@ -67,6 +105,27 @@ func TestDetectLanguage(t *testing.T) {
 	}
 }
 
+func TestDetectLanguage_Confidence(t *testing.T) {
+	english := DetectLanguage("Do you have any holsters for my pistol?")
+	if english.Code != LangEnglish || english.Confidence < 0.5 {
+		t.Errorf("expected confident English detection, got %+v", english)
+	}
+
+	hebrew := DetectLanguage("שלום, איך אני יכול לעזור לך?")
+	if hebrew.Code != LangHebrew || hebrew.Confidence < 0.5 {
+		t.Errorf("expected confident Hebrew detection, got %+v", hebrew)
+	}
+
+	// A Hebrew word in an English sentence is still detected as Hebrew, but with low confidence
+	mixed := DetectLanguage("Do you ship the Masada holster to תל אביב?")
+	if mixed.Code != LangHebrew || mixed.Confidence > 0.3 {
+		t.Errorf("expected low-confidence Hebrew detection, got %+v", mixed)
+	}
+	if got := FallbackToEnglish(mixed, 0.3).Code; got != LangEnglish {
+		t.Errorf("expected low-confidence mixed text to fall back to English, got %s", got)
+	}
+}
+
 func TestGetLanguageInstruction(t *testing.T) {
 	tests := []struct {
 		lang     Language
@@ -92,10 +151,162 @@ func TestGetLanguageInstruction(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.lang.Code, func(t *testing.T) {
-			result := GetLanguageInstruction(tt.lang)
+			result := GetLanguageInstruction(tt.lang, 0)
 			if result != tt.expected {
 				t.Errorf("GetLanguageInstruction(%v) = %q, expected %q", tt.lang, result, tt.expected)
 			}
 		})
 	}
 }
+
+func TestResponseLanguageMismatch(t *testing.T) {
+	hebrew := Language{Code: LangHebrew, Name: "Hebrew"}
+	english := Language{Code: LangEnglish, Name: "English"}
+
+	tests := []struct {
+		name      string
+		response  string
+		requested Language
+		expected  bool
+	}{
+		{name: "english reply to hebrew customer", response: "We have several holsters in stock.", requested: hebrew, expected: true},
+		{name: "hebrew reply with english product names", response: "יש לנו **Glock 19 Holster** במלאי במחיר $45", requested: hebrew, expected: false},
+		{name: "english requests are not checked", response: "יש לנו נרתיק במלאי", requested: english, expected: false},
+		{name: "empty response", response: "", requested: hebrew, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ResponseLanguageMismatch(tt.response, tt.requested)
+			if result != tt.expected {
+				t.Errorf("ResponseLanguageMismatch(%q, %s) = %v, expected %v", tt.response, tt.requested.Code, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetLanguageInstruction_ConfidenceThreshold(t *testing.T) {
+	hebrew := Language{Code: LangHebrew, Name: "Hebrew", Confidence: 0.3}
+
+	tests := []struct {
+		name          string
+		minConfidence float64
+		expected      string
+	}{
+		{name: "threshold disabled", minConfidence: 0, expected: "Please respond in Hebrew (עברית)."},
+		{name: "above threshold", minConfidence: 0.2, expected: "Please respond in Hebrew (עברית)."},
+		{name: "at threshold", minConfidence: 0.3, expected: "Please respond in Hebrew (עברית)."},
+		{name: "below threshold", minConfidence: 0.5, expected: "Please respond in English."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := GetLanguageInstruction(hebrew, tt.minConfidence); result != tt.expected {
+				t.Errorf("GetLanguageInstruction(%v, %g) = %q, expected %q", hebrew, tt.minConfidence, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFallbackToEnglish(t *testing.T) {
+	russian := Language{Code: LangRussian, Name: "Russian", Confidence: 0.05}
+
+	if got := FallbackToEnglish(russian, 0.1); got.Code != LangEnglish {
+		t.Errorf("FallbackToEnglish below threshold = %s, expected %s", got.Code, LangEnglish)
+	}
+	if got := FallbackToEnglish(russian, 0.05); got.Code != LangRussian {
+		t.Errorf("FallbackToEnglish at threshold = %s, expected %s", got.Code, LangRussian)
+	}
+	english := Language{Code: LangEnglish, Name: "English"}
+	if got := FallbackToEnglish(english, 0.9); got != english {
+		t.Errorf("FallbackToEnglish(English) = %v, expected unchanged", got)
+	}
+}
+
+// useLanguageConfigFile loads a languages config written to a temp file and restores the default afterwards
+func useLanguageConfigFile(t *testing.T, content string) error {
+	t.Helper()
+	t.Cleanup(func() { setLanguages(defaultLanguages) })
+
+	path := filepath.Join(t.TempDir(), "languages.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadLanguageConfig(path)
+}
+
+func TestLoadLanguageConfig_CustomLanguage(t *testing.T) {
+	err := useLanguageConfigFile(t, `{"languages": [
+		{"code": "ar"},
+		{"code": "nl", "name": "Dutch", "instruction": "Antwoord in het Nederlands.", "stopwords": ["de", "het", "een", "en", "ik", "jullie", "hebben"]},
+		{"code": "tr", "name": "Turkish"}
+	]}`)
+	if err != nil {
+		t.Fatalf("LoadLanguageConfig: %v", err)
+	}
+
+	dutch := DetectLanguage("Hebben jullie een holster voor de Glock?")
+	if dutch.Code != "nl" || dutch.Name != "Dutch" {
+		t.Errorf("expected Dutch text to be detected as nl, got %s", dutch.Code)
+	}
+	if got := GetLanguageInstruction(dutch, 0); got != "Antwoord in het Nederlands." {
+		t.Errorf("GetLanguageInstruction(nl) = %q", got)
+	}
+	if got := GetLanguageInstruction(Language{Code: "tr"}, 0); got != "Please respond in Turkish." {
+		t.Errorf("expected a generic instruction for a language without one, got %q", got)
+	}
+	if got := GetLanguageInstruction(Language{Code: LangArabic}, 0); got != "Please respond in Arabic (العربية)." {
+		t.Errorf("expected the bundled Arabic instruction, got %q", got)
+	}
+
+	// Hebrew isn't configured, so Hebrew customers are answered in English
+	if got := DetectLanguage("יש לכם נרתיק לגלוק?"); got.Code != LangEnglish {
+		t.Errorf("expected an unconfigured language to be detected as English, got %s", got.Code)
+	}
+	if got := GetLanguageInstruction(Language{Code: LangHebrew}, 0); got != "Please respond in English." {
+		t.Errorf("expected unconfigured Hebrew to fall back to English, got %q", got)
+	}
+}
+
+func TestLoadLanguageConfig_UnknownCodeFallsBackToEnglish(t *testing.T) {
+	err := useLanguageConfigFile(t, `{"languages": [{"code": "en", "instruction": "Reply in English only."}, {"code": "ru"}]}`)
+	if err != nil {
+		t.Fatalf("LoadLanguageConfig: %v", err)
+	}
+
+	if got := GetLanguageInstruction(Language{Code: "xx", Name: "Unknown"}, 0); got != "Reply in English only." {
+		t.Errorf("expected unknown codes to use the configured English instruction, got %q", got)
+	}
+	if got := DetectLanguage("Do you have this in stock?"); got.Code != LangEnglish {
+		t.Errorf("expected English stopwords to be kept, got %s", got.Code)
+	}
+	if got := DetectLanguage("¿Tienen fundas para la pistola? Gracias por la ayuda"); got.Code != LangEnglish {
+		t.Errorf("expected unconfigured Spanish to be detected as English, got %s", got.Code)
+	}
+}
+
+func TestLoadLanguageConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errText string
+	}{
+		{name: "malformed", content: `{"languages": [`, errText: "failed to parse languages config file"},
+		{name: "empty", content: `{"languages": []}`, errText: "no languages listed"},
+		{name: "missing code", content: `{"languages": [{"name": "Dutch"}]}`, errText: "has no code"},
+		{name: "unknown language without name", content: `{"languages": [{"code": "nl"}]}`, errText: "language nl has no name"},
+		{name: "duplicate", content: `{"languages": [{"code": "ru"}, {"code": "RU"}]}`, errText: "listed twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := useLanguageConfigFile(t, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("LoadLanguageConfig error = %v, expected %q", err, tt.errText)
+			}
+			if got := GetLanguageInstruction(Language{Code: LangHebrew}, 0); got != "Please respond in Hebrew (עברית)." {
+				t.Errorf("expected a failed load to keep the bundled languages, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// instructionPatterns match phrases that try to override the system prompt
+	instructionPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(the\s+)?(previous|prior|above|earlier|preceding)\s+(instructions|prompts?|rules|messages|context)\b`),
+		regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(a|an|in)\b`),
+		regexp.MustCompile(`(?i)\b(new|updated)\s+system\s+(prompt|instructions)\b`),
+		regexp.MustCompile(`(?i)\b(reveal|print|show|repeat)\s+(me\s+)?(your|the)\s+(system\s+prompt|hidden\s+instructions|initial\s+instructions)\b`),
+	}
+
+	// rolePrefixPattern matches chat role markers that could fake a new message in the prompt
+	rolePrefixPattern = regexp.MustCompile(`(?i)\b(system|assistant|user)\s*:`)
+
+	// jailbreakPatterns match common jailbreak phrasing in user queries
+	// Kept deliberately narrow so ordinary product questions are never flagged
+	jailbreakPatterns = append([]*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(developer|god|dan|unrestricted)\s+mode\b`),
+		regexp.MustCompile(`(?i)\bjailbr(eak|oken)\b`),
+		regexp.MustCompile(`(?i)\bpretend\s+(that\s+)?(you\s+are|to\s+be)\s+(an?\s+)?(unrestricted|unfiltered|uncensored|different\s+ai)\b`),
+		regexp.MustCompile(`(?i)\bdo\s+anything\s+now\b`),
+	}, instructionPatterns...)
+
+	// structuralPattern matches markdown/prompt structure that could fake a new context section
+	structuralPattern = regexp.MustCompile("(```+|={3,}|#{2,}|-{3,}|<\\|[a-z_]*\\|>)")
+
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// SanitizePromptText cleans untrusted text (e.g. product titles and tags from WordPress)
+// before it is embedded in an LLM prompt. It removes control characters, flattens newlines,
+// strips markdown/section markers and drops instruction-like phrases.
+func SanitizePromptText(text string) string {
+	if text == "" {
+		return text
+	}
+
+	// Replace control characters (including newlines) with spaces so a field stays on one line
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+
+	text = structuralPattern.ReplaceAllString(text, " ")
+
+	for _, pattern := range instructionPatterns {
+		text = pattern.ReplaceAllString(text, " ")
+	}
+	text = rolePrefixPattern.ReplaceAllString(text, " ")
+
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+}
+
+// LooksLikePromptInjection reports whether a user query contains common jailbreak
+// or instruction-override patterns
+func LooksLikePromptInjection(query string) bool {
+	for _, pattern := range jailbreakPatterns {
+		if pattern.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
+
+// StripPromptInjection removes jailbreak phrases from a user query, keeping the rest
+// of the question so legitimate product requests can still be answered
+func StripPromptInjection(query string) string {
+	for _, pattern := range jailbreakPatterns {
+		query = pattern.ReplaceAllString(query, " ")
+	}
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(query, " "))
+}
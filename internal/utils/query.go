@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// zeroWidthChars are invisible characters that change embeddings and cache keys without changing meaning
+var zeroWidthChars = strings.NewReplacer(
+	"\u200b", "", // zero width space
+	"\u200c", "", // zero width non-joiner
+	"\u200d", "", // zero width joiner
+	"\u2060", "", // word joiner
+	"\ufeff", "", // byte order mark / zero width no-break space
+)
+
+// NormalizeQuery canonicalizes a search query before embedding and caching
+// It applies NFC normalization, strips zero-width characters, trims and collapses whitespace
+func NormalizeQuery(query string) string {
+	query = norm.NFC.String(query)
+	query = zeroWidthChars.Replace(query)
+	return strings.Join(strings.Fields(query), " ")
+}
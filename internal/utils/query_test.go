@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "trims and collapses whitespace", input: "  glock   19\tholster \n", expected: "glock 19 holster"},
+		{name: "strips zero-width space", input: "glock\u200b 19", expected: "glock 19"},
+		{name: "strips byte order mark and joiners", input: "\ufeffplate\u200d carrier\u2060", expected: "plate carrier"},
+		{name: "empty", input: " \u200b ", expected: ""},
+		{name: "hebrew unchanged", input: "נרתיק לגלוק", expected: "נרתיק לגלוק"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NormalizeQuery(tt.input)
+			if result != tt.expected {
+				t.Errorf("NormalizeQuery(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeQuery_EquivalentVariants(t *testing.T) {
+	variants := [][]string{
+		// NFC (precomposed é) vs NFD (e + combining acute)
+		{"café jacket", "cafe\u0301 jacket"},
+		// Zero-width characters and stray whitespace
+		{"dubon coat", "dubon\u200b coat ", " \ufeffdubon  coat"},
+	}
+
+	for _, group := range variants {
+		expected := NormalizeQuery(group[0])
+		for _, variant := range group[1:] {
+			if result := NormalizeQuery(variant); result != expected {
+				t.Errorf("NormalizeQuery(%q) = %q, expected %q", variant, result, expected)
+			}
+		}
+	}
+}
@@ -15,14 +15,15 @@ const (
 	ProductsCollection     = "products"
 	EmailThreadsCollection = "email_threads"
 
-	// Vector dimensions for text-embedding-3-small
+	// Default vector dimensions (text-embedding-3-small); override with SetVectorDimensions
 	VectorDimensions = 1536
 )
 
 // QdrantClient wraps the Qdrant client with IDS-specific functionality
 type QdrantClient struct {
-	client *qdrant.Client
-	url    string
+	client     *qdrant.Client
+	url        string
+	dimensions uint64 // Vector size for new collections
 }
 
 // ProductPayload contains product metadata stored in Qdrant
@@ -37,6 +38,7 @@ type ProductPayload struct {
 	Tags             string `json:"tags"`
 	Description      string `json:"description"`
 	ShortDescription string `json:"short_description"`
+	PublishedAt      int64  `json:"published_at"` // Unix seconds, 0 when unknown
 }
 
 // EmailPayload contains email thread metadata stored in Qdrant
@@ -89,11 +91,19 @@ func NewQdrantClient(url string) (*QdrantClient, error) {
 	}
 
 	return &QdrantClient{
-		client: client,
-		url:    url,
+		client:     client,
+		url:        url,
+		dimensions: VectorDimensions,
 	}, nil
 }
 
+// SetVectorDimensions sets the vector size used when creating collections (probed from the embedding model)
+func (q *QdrantClient) SetVectorDimensions(dimensions int) {
+	if dimensions > 0 {
+		q.dimensions = uint64(dimensions)
+	}
+}
+
 // Close closes the Qdrant client connection
 func (q *QdrantClient) Close() error {
 	return q.client.Close()
@@ -123,6 +133,18 @@ func (q *QdrantClient) EnsureCollections(ctx context.Context) error {
 	return nil
 }
 
+// RecreateProductsCollection drops the products collection and creates it again with the current
+// vector size, so its points can be regenerated after the embedding model changes size
+func (q *QdrantClient) RecreateProductsCollection(ctx context.Context) error {
+	if err := q.client.DeleteCollection(ctx, ProductsCollection); err != nil {
+		return fmt.Errorf("failed to delete products collection: %w", err)
+	}
+	if err := q.ensureCollection(ctx, ProductsCollection); err != nil {
+		return fmt.Errorf("failed to create products collection: %w", err)
+	}
+	return nil
+}
+
 func (q *QdrantClient) ensureCollection(ctx context.Context, name string) error {
 	// Check if collection exists
 	exists, err := q.client.CollectionExists(ctx, name)
@@ -139,7 +161,7 @@ func (q *QdrantClient) ensureCollection(ctx context.Context, name string) error
 	err = q.client.CreateCollection(ctx, &qdrant.CreateCollection{
 		CollectionName: name,
 		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
-			Size:     VectorDimensions,
+			Size:     q.dimensions,
 			Distance: qdrant.Distance_Cosine,
 		}),
 	})
@@ -168,6 +190,7 @@ func (q *QdrantClient) UpsertProduct(ctx context.Context, productID int, embeddi
 				"tags":              payload.Tags,
 				"description":       payload.Description,
 				"short_description": payload.ShortDescription,
+				"published_at":      payload.PublishedAt,
 			}),
 		},
 	}
@@ -179,6 +202,23 @@ func (q *QdrantClient) UpsertProduct(ctx context.Context, productID int, embeddi
 	return err
 }
 
+// DeleteProducts removes product embeddings from Qdrant by product ID
+func (q *QdrantClient) DeleteProducts(ctx context.Context, productIDs []int64) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	ids := make([]*qdrant.PointId, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = qdrant.NewIDNum(uint64(id))
+	}
+
+	_, err := q.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: ProductsCollection,
+		Points:         qdrant.NewPointsSelector(ids...),
+	})
+	return err
+}
+
 // UpsertEmailThread inserts or updates an email thread embedding in Qdrant
 func (q *QdrantClient) UpsertEmailThread(ctx context.Context, threadID string, embedding []float32, payload EmailPayload) error {
 	// Use hash of thread ID as numeric ID
@@ -205,6 +245,23 @@ func (q *QdrantClient) UpsertEmailThread(ctx context.Context, threadID string, e
 	return err
 }
 
+// DeleteEmailThreads removes email thread embeddings from Qdrant by thread ID
+func (q *QdrantClient) DeleteEmailThreads(ctx context.Context, threadIDs []string) error {
+	if len(threadIDs) == 0 {
+		return nil
+	}
+	ids := make([]*qdrant.PointId, len(threadIDs))
+	for i, threadID := range threadIDs {
+		ids[i] = qdrant.NewIDNum(hashString(threadID))
+	}
+
+	_, err := q.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: EmailThreadsCollection,
+		Points:         qdrant.NewPointsSelector(ids...),
+	})
+	return err
+}
+
 // SearchProducts searches for similar products in Qdrant
 //
 //nolint:dupl // SearchProducts and SearchEmailThreads have similar structure but different types
@@ -272,6 +329,7 @@ func extractProductPayload(payload map[string]*qdrant.Value) ProductPayload {
 		Tags:             getStringValue(payload, "tags"),
 		Description:      getStringValue(payload, "description"),
 		ShortDescription: getStringValue(payload, "short_description"),
+		PublishedAt:      getIntValue(payload, "published_at"),
 	}
 }
 
@@ -0,0 +1,156 @@
+package vectordb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"ids/internal/config"
+)
+
+// cosineOperator is the pgvector operator search queries are written with
+// WithDistanceOperator swaps it for the configured metric's operator.
+const cosineOperator = "<=>"
+
+// DistanceOperator returns the pgvector distance operator for metric (cosine when unknown)
+func DistanceOperator(metric string) string {
+	switch metric {
+	case config.DistanceMetricL2:
+		return "<->"
+	case config.DistanceMetricInnerProduct:
+		return "<#>"
+	default:
+		return cosineOperator
+	}
+}
+
+// OperatorClass returns the pgvector operator class an HNSW or IVFFlat index needs to serve metric
+func OperatorClass(metric string) string {
+	switch metric {
+	case config.DistanceMetricL2:
+		return "vector_l2_ops"
+	case config.DistanceMetricInnerProduct:
+		return "vector_ip_ops"
+	default:
+		return "vector_cosine_ops"
+	}
+}
+
+// HNSWIndexName returns the name of table's HNSW index for metric
+// The cosine index keeps its original name so existing deployments don't rebuild it.
+func HNSWIndexName(table, metric string) string {
+	if metric == "" || metric == config.DistanceMetricCosine {
+		return "idx_" + table + "_hnsw"
+	}
+	return "idx_" + table + "_hnsw_" + metric
+}
+
+// Default HNSW build parameters, used when the index is first created
+// m: number of connections per layer (higher = better recall, more memory)
+// ef_construction: size of dynamic candidate list for construction (higher = better index quality, slower build)
+const (
+	DefaultHNSWM              = 16
+	DefaultHNSWEfConstruction = 100
+)
+
+// HNSWIndexQuery returns the CREATE INDEX statement for table's embedding column under metric
+// with the default build parameters
+func HNSWIndexQuery(table, metric string) string {
+	return HNSWIndexQueryWithParams(table, metric, DefaultHNSWM, DefaultHNSWEfConstruction)
+}
+
+// HNSWIndexQueryWithParams returns the CREATE INDEX statement for table's embedding column under
+// metric with the given build parameters (see ValidateHNSWParams)
+func HNSWIndexQueryWithParams(table, metric string, m, efConstruction int) string {
+	return fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s USING hnsw (embedding %s) WITH (m = %d, ef_construction = %d)`,
+		HNSWIndexName(table, metric), table, OperatorClass(metric), m, efConstruction)
+}
+
+// ValidateHNSWParams checks HNSW build parameters against pgvector's limits
+func ValidateHNSWParams(m, efConstruction int) error {
+	if m < 2 || m > 100 {
+		return fmt.Errorf("m must be between 2 and 100, got %d", m)
+	}
+	if efConstruction < 4 || efConstruction > 1000 {
+		return fmt.Errorf("ef_construction must be between 4 and 1000, got %d", efConstruction)
+	}
+	if efConstruction < 2*m {
+		return fmt.Errorf("ef_construction must be at least 2*m (%d), got %d", 2*m, efConstruction)
+	}
+	return nil
+}
+
+// EfSearchQuery returns the statement setting hnsw.ef_search (candidates kept during an HNSW scan;
+// higher = better recall, slower queries) for the current transaction only
+func EfSearchQuery(efSearch int) string {
+	return fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", efSearch)
+}
+
+// IVFFlatIndexName returns the name of table's IVFFlat index for metric
+func IVFFlatIndexName(table, metric string) string {
+	if metric == "" || metric == config.DistanceMetricCosine {
+		return "idx_" + table + "_ivfflat"
+	}
+	return "idx_" + table + "_ivfflat_" + metric
+}
+
+// maxIVFFlatLists is pgvector's upper limit for an IVFFlat index's lists
+const maxIVFFlatLists = 32768
+
+// IVFFlatLists returns the lists for an IVFFlat index over rows vectors, following pgvector's
+// guidance: rows/1000 up to 1M rows and sqrt(rows) above, at least 1
+func IVFFlatLists(rows int) int {
+	lists := rows / 1000
+	if rows > 1000000 {
+		lists = int(math.Sqrt(float64(rows)))
+	}
+	return min(max(lists, 1), maxIVFFlatLists)
+}
+
+// IVFFlatListsDrifted reports whether an IVFFlat index built with lists should be rebuilt for rows
+// vectors, i.e. its lists are at least a factor of two away from IVFFlatLists(rows)
+func IVFFlatListsDrifted(lists, rows int) bool {
+	want := IVFFlatLists(rows)
+	return lists*2 <= want || want*2 <= lists
+}
+
+// IVFFlatIndexQuery returns the CREATE INDEX statement for an IVFFlat index on table's embedding
+// column under metric with the given lists
+func IVFFlatIndexQuery(table, metric string, lists int) string {
+	return fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s USING ivfflat (embedding %s) WITH (lists = %d)`,
+		IVFFlatIndexName(table, metric), table, OperatorClass(metric), lists)
+}
+
+// ProbesQuery returns the statement setting ivfflat.probes (lists scanned during an IVFFlat
+// search; higher = better recall, slower queries) for the current transaction only
+func ProbesQuery(probes int) string {
+	return fmt.Sprintf("SET LOCAL ivfflat.probes = %d", probes)
+}
+
+// WithDistanceOperator rewrites a pgvector query written with the cosine operator for metric
+func WithDistanceOperator(query, metric string) string {
+	return strings.ReplaceAll(query, cosineOperator, DistanceOperator(metric))
+}
+
+// NormalizeSimilarity maps a pgvector distance under metric to a similarity in [0, 1]
+//
+// Every metric is mapped to the cosine similarity it implies for unit-length vectors (OpenAI
+// embeddings are normalized), so SEARCH_MIN_SIMILARITY and the chat relevance thresholds mean
+// the same thing whichever metric is configured:
+//   - cosine:        1 - d
+//   - l2:            1 - d²/2 (since ‖a-b‖² = 2 - 2·cos for unit vectors)
+//   - inner_product: -d (pgvector's <#> returns the negative inner product)
+//
+// Opposed vectors (cosine below 0) are clamped to 0, and rounding above 1 to 1.
+func NormalizeSimilarity(metric string, distance float64) float64 {
+	var similarity float64
+	switch metric {
+	case config.DistanceMetricL2:
+		similarity = 1 - distance*distance/2
+	case config.DistanceMetricInnerProduct:
+		similarity = -distance
+	default:
+		similarity = 1 - distance
+	}
+	return min(max(similarity, 0), 1)
+}
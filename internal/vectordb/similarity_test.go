@@ -0,0 +1,85 @@
+package vectordb
+
+import (
+	"math"
+	"testing"
+
+	"ids/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeSimilarity_Cosine(t *testing.T) {
+	assert.Equal(t, 1.0, NormalizeSimilarity(config.DistanceMetricCosine, 0))
+	assert.InDelta(t, 0.7, NormalizeSimilarity(config.DistanceMetricCosine, 0.3), 1e-9)
+	assert.Equal(t, 0.0, NormalizeSimilarity(config.DistanceMetricCosine, 1))
+	assert.Equal(t, 0.0, NormalizeSimilarity(config.DistanceMetricCosine, 1.6), "opposed vectors clamp to 0")
+	assert.Equal(t, 1.0, NormalizeSimilarity(config.DistanceMetricCosine, -1e-7), "rounding above 1 clamps to 1")
+	assert.InDelta(t, 0.7, NormalizeSimilarity("", 0.3), 1e-9, "unset metric is cosine")
+}
+
+func TestNormalizeSimilarity_L2(t *testing.T) {
+	assert.Equal(t, 1.0, NormalizeSimilarity(config.DistanceMetricL2, 0))
+	assert.Equal(t, 0.0, NormalizeSimilarity(config.DistanceMetricL2, math.Sqrt2), "orthogonal unit vectors")
+	assert.Equal(t, 0.0, NormalizeSimilarity(config.DistanceMetricL2, 2), "opposed unit vectors")
+
+	// Unit vectors with cosine similarity 0.7 score the same under both metrics
+	l2Distance := math.Sqrt(2 - 2*0.7)
+	assert.InDelta(t, NormalizeSimilarity(config.DistanceMetricCosine, 0.3), NormalizeSimilarity(config.DistanceMetricL2, l2Distance), 1e-9)
+}
+
+func TestNormalizeSimilarity_InnerProduct(t *testing.T) {
+	assert.InDelta(t, 0.7, NormalizeSimilarity(config.DistanceMetricInnerProduct, -0.7), 1e-9)
+	assert.Equal(t, 0.0, NormalizeSimilarity(config.DistanceMetricInnerProduct, 0.4))
+}
+
+func TestWithDistanceOperator(t *testing.T) {
+	query := `SELECT embedding <=> $1::vector AS distance FROM product_embeddings ORDER BY embedding <=> $1::vector`
+
+	assert.Equal(t, query, WithDistanceOperator(query, config.DistanceMetricCosine))
+	assert.Equal(t, `SELECT embedding <-> $1::vector AS distance FROM product_embeddings ORDER BY embedding <-> $1::vector`,
+		WithDistanceOperator(query, config.DistanceMetricL2))
+	assert.Equal(t, `SELECT embedding <#> $1::vector AS distance FROM product_embeddings ORDER BY embedding <#> $1::vector`,
+		WithDistanceOperator(query, config.DistanceMetricInnerProduct))
+}
+
+func TestHNSWIndexQuery(t *testing.T) {
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS idx_product_embeddings_hnsw ON product_embeddings USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 100)`,
+		HNSWIndexQuery("product_embeddings", config.DistanceMetricCosine))
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS idx_email_embeddings_hnsw_l2 ON email_embeddings USING hnsw (embedding vector_l2_ops) WITH (m = 16, ef_construction = 100)`,
+		HNSWIndexQuery("email_embeddings", config.DistanceMetricL2))
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS idx_product_embeddings_hnsw_inner_product ON product_embeddings USING hnsw (embedding vector_ip_ops) WITH (m = 32, ef_construction = 200)`,
+		HNSWIndexQueryWithParams("product_embeddings", config.DistanceMetricInnerProduct, 32, 200))
+}
+
+func TestValidateHNSWParams(t *testing.T) {
+	assert.NoError(t, ValidateHNSWParams(DefaultHNSWM, DefaultHNSWEfConstruction))
+	assert.NoError(t, ValidateHNSWParams(48, 96))
+	assert.ErrorContains(t, ValidateHNSWParams(1, 100), "m must be between 2 and 100")
+	assert.ErrorContains(t, ValidateHNSWParams(16, 2000), "ef_construction must be between 4 and 1000")
+	assert.ErrorContains(t, ValidateHNSWParams(64, 100), "ef_construction must be at least 2*m (128)")
+}
+
+func TestIVFFlatIndexQuery(t *testing.T) {
+	assert.Equal(t, "idx_product_embeddings_ivfflat", IVFFlatIndexName("product_embeddings", config.DistanceMetricCosine))
+	assert.Equal(t,
+		"CREATE INDEX IF NOT EXISTS idx_product_embeddings_ivfflat_inner_product ON product_embeddings USING ivfflat (embedding vector_ip_ops) WITH (lists = 100)",
+		IVFFlatIndexQuery("product_embeddings", config.DistanceMetricInnerProduct, 100))
+	assert.Equal(t, "SET LOCAL ivfflat.probes = 10", ProbesQuery(10))
+}
+
+func TestIVFFlatListsDrifted(t *testing.T) {
+	assert.False(t, IVFFlatListsDrifted(1, 0))
+	assert.True(t, IVFFlatListsDrifted(1, 48000), "built on an empty table before the first generation")
+	assert.False(t, IVFFlatListsDrifted(40, 48000), "small growth keeps the index")
+	assert.True(t, IVFFlatListsDrifted(200, 48000), "shrunk table")
+}
+
+func TestIVFFlatLists(t *testing.T) {
+	assert.Equal(t, 1, IVFFlatLists(0), "an empty table still gets one list")
+	assert.Equal(t, 1, IVFFlatLists(999))
+	assert.Equal(t, 50, IVFFlatLists(50000))
+	assert.Equal(t, 1000, IVFFlatLists(1000000))
+	assert.Equal(t, 2000, IVFFlatLists(4000000), "sqrt(rows) above 1M rows")
+	assert.Equal(t, 32768, IVFFlatLists(2000000000))
+}